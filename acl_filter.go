@@ -11,6 +11,89 @@ type AclFilter struct {
 	PermissionType            AclPermissionType
 }
 
+// AclFilterBuilder builds an AclFilter. The filter it starts from matches every
+// ACL, and each of its methods narrows it down.
+type AclFilterBuilder struct {
+	filter AclFilter
+}
+
+// NewAclFilterBuilder returns a builder of AclFilter matching the ACLs of any
+// resource, principal, host, operation and permission type.
+func NewAclFilterBuilder() *AclFilterBuilder {
+	return &AclFilterBuilder{filter: AclFilter{
+		ResourceType:              AclResourceAny,
+		ResourcePatternTypeFilter: AclPatternAny,
+		Operation:                 AclOperationAny,
+		PermissionType:            AclPermissionAny,
+	}}
+}
+
+// ResourceType matches the ACLs of resources of type resourceType.
+func (b *AclFilterBuilder) ResourceType(resourceType AclResourceType) *AclFilterBuilder {
+	b.filter.ResourceType = resourceType
+	return b
+}
+
+// Name matches the ACLs bound to the resource pattern name, whatever their
+// pattern type.
+func (b *AclFilterBuilder) Name(name string) *AclFilterBuilder {
+	return b.pattern(name, AclPatternAny)
+}
+
+// Literal matches the literal ACLs bound to the resource named name, "*"
+// matching the wildcard ACLs.
+func (b *AclFilterBuilder) Literal(name string) *AclFilterBuilder {
+	return b.pattern(name, AclPatternLiteral)
+}
+
+// Prefixed matches the prefixed ACLs bound to prefix. Prefixed ACLs require
+// Kafka 2.0 or later.
+func (b *AclFilterBuilder) Prefixed(prefix string) *AclFilterBuilder {
+	return b.pattern(prefix, AclPatternPrefixed)
+}
+
+// Match matches every ACL applying to the resource named name: the literal
+// ACLs bound to name, the wildcard ACLs and the prefixed ACLs bound to a prefix
+// of name.
+func (b *AclFilterBuilder) Match(name string) *AclFilterBuilder {
+	return b.pattern(name, AclPatternMatch)
+}
+
+func (b *AclFilterBuilder) pattern(name string, patternType AclResourcePatternType) *AclFilterBuilder {
+	b.filter.ResourceName = &name
+	b.filter.ResourcePatternTypeFilter = patternType
+	return b
+}
+
+// Principal matches the ACLs of principal, e.g. "User:alice".
+func (b *AclFilterBuilder) Principal(principal string) *AclFilterBuilder {
+	b.filter.Principal = &principal
+	return b
+}
+
+// Host matches the ACLs of host, "*" matching the ACLs of any host.
+func (b *AclFilterBuilder) Host(host string) *AclFilterBuilder {
+	b.filter.Host = &host
+	return b
+}
+
+// Operation matches the ACLs of operation.
+func (b *AclFilterBuilder) Operation(operation AclOperation) *AclFilterBuilder {
+	b.filter.Operation = operation
+	return b
+}
+
+// PermissionType matches the ACLs of permissionType.
+func (b *AclFilterBuilder) PermissionType(permissionType AclPermissionType) *AclFilterBuilder {
+	b.filter.PermissionType = permissionType
+	return b
+}
+
+// Build returns the filter.
+func (b *AclFilterBuilder) Build() AclFilter {
+	return b.filter
+}
+
 func (a *AclFilter) encode(pe packetEncoder) error {
 	pe.putInt8(int8(a.ResourceType))
 	if err := pe.putNullableString(a.ResourceName); err != nil {
@@ -2,6 +2,7 @@ package sarama
 
 import (
 	"fmt"
+	"math"
 	"strings"
 )
 
@@ -87,6 +88,27 @@ func (a *AclOperation) UnmarshalText(text []byte) error {
 	return nil
 }
 
+// AuthorizedOperationsOmitted is the authorized operations bitfield returned
+// when the authorized operations were not requested (KIP-430).
+const AuthorizedOperationsOmitted int32 = math.MinInt32
+
+// AclOperationsFromBitfield decodes an authorized operations bitfield (KIP-430),
+// in which the bit of each AclOperation value is set if the operation is
+// allowed, into the list of allowed operations. It returns nil when the
+// operations were omitted.
+func AclOperationsFromBitfield(bitfield int32) []AclOperation {
+	if bitfield == AuthorizedOperationsOmitted {
+		return nil
+	}
+	operations := []AclOperation{}
+	for op := AclOperationUnknown; op <= AclOperationIdempotentWrite; op++ {
+		if bitfield&(1<<op) != 0 {
+			operations = append(operations, op)
+		}
+	}
+	return operations
+}
+
 // ref: https://github.com/apache/kafka/blob/trunk/clients/src/main/java/org/apache/kafka/common/acl/AclPermissionType.java
 const (
 	AclPermissionUnknown AclPermissionType = iota
@@ -73,3 +73,25 @@ func TestAclResourcePatternTypeTextMarshal(t *testing.T) {
 		}
 	}
 }
+
+func TestAclOperationsFromBitfield(t *testing.T) {
+	if ops := AclOperationsFromBitfield(AuthorizedOperationsOmitted); ops != nil {
+		t.Errorf("expected no operations when omitted, got %v", ops)
+	}
+	if ops := AclOperationsFromBitfield(0); ops == nil || len(ops) != 0 {
+		t.Errorf("expected an empty list of operations, got %v", ops)
+	}
+
+	// the cluster operations of a principal allowed to describe and alter its configs
+	bitfield := int32(1<<AclOperationDescribe | 1<<AclOperationDescribeConfigs | 1<<AclOperationAlterConfigs)
+	ops := AclOperationsFromBitfield(bitfield)
+	want := []AclOperation{AclOperationDescribe, AclOperationDescribeConfigs, AclOperationAlterConfigs}
+	if len(ops) != len(want) {
+		t.Fatalf("got %v, want %v", ops, want)
+	}
+	for i := range want {
+		if ops[i] != want[i] {
+			t.Errorf("got %v, want %v", ops, want)
+		}
+	}
+}
@@ -7,6 +7,21 @@ type AddPartitionsToTxnRequest struct {
 	ProducerID      int64
 	ProducerEpoch   int16
 	TopicPartitions map[string][]int32
+	// Transactions replaces the single transaction fields above from version
+	// 4 (KIP-890), which batches several transactions in one request.
+	Transactions []*AddPartitionsToTxnTransaction
+}
+
+// AddPartitionsToTxnTransaction is one of the transactions of a version 4+
+// AddPartitionsToTxnRequest.
+type AddPartitionsToTxnTransaction struct {
+	TransactionalID string
+	ProducerID      int64
+	ProducerEpoch   int16
+	// VerifyOnly makes the coordinator check that the partitions are already
+	// part of the transaction instead of adding them.
+	VerifyOnly      bool
+	TopicPartitions map[string][]int32
 }
 
 func (a *AddPartitionsToTxnRequest) setVersion(v int16) {
@@ -14,28 +29,51 @@ func (a *AddPartitionsToTxnRequest) setVersion(v int16) {
 }
 
 func (a *AddPartitionsToTxnRequest) encode(pe packetEncoder) error {
+	if a.Version >= 4 {
+		if err := pe.putArrayLength(len(a.Transactions)); err != nil {
+			return err
+		}
+		for _, txn := range a.Transactions {
+			if err := txn.encode(pe); err != nil {
+				return err
+			}
+		}
+		pe.putEmptyTaggedFieldArray()
+		return nil
+	}
+
 	if err := pe.putString(a.TransactionalID); err != nil {
 		return err
 	}
 	pe.putInt64(a.ProducerID)
 	pe.putInt16(a.ProducerEpoch)
 
-	if err := pe.putArrayLength(len(a.TopicPartitions)); err != nil {
+	if err := encodeTxnTopicPartitions(pe, a.TopicPartitions); err != nil {
 		return err
 	}
-	for topic, partitions := range a.TopicPartitions {
-		if err := pe.putString(topic); err != nil {
-			return err
-		}
-		if err := pe.putInt32Array(partitions); err != nil {
-			return err
-		}
-	}
 
+	pe.putEmptyTaggedFieldArray()
 	return nil
 }
 
 func (a *AddPartitionsToTxnRequest) decode(pd packetDecoder, version int16) (err error) {
+	a.Version = version
+	if version >= 4 {
+		n, err := pd.getArrayLength()
+		if err != nil {
+			return err
+		}
+		a.Transactions = make([]*AddPartitionsToTxnTransaction, n)
+		for i := range a.Transactions {
+			a.Transactions[i] = new(AddPartitionsToTxnTransaction)
+			if err := a.Transactions[i].decode(pd); err != nil {
+				return err
+			}
+		}
+		_, err = pd.getEmptyTaggedFieldArray()
+		return err
+	}
+
 	if a.TransactionalID, err = pd.getString(); err != nil {
 		return err
 	}
@@ -45,28 +83,90 @@ func (a *AddPartitionsToTxnRequest) decode(pd packetDecoder, version int16) (err
 	if a.ProducerEpoch, err = pd.getInt16(); err != nil {
 		return err
 	}
+	if a.TopicPartitions, err = decodeTxnTopicPartitions(pd); err != nil {
+		return err
+	}
+
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (t *AddPartitionsToTxnTransaction) encode(pe packetEncoder) error {
+	if err := pe.putString(t.TransactionalID); err != nil {
+		return err
+	}
+	pe.putInt64(t.ProducerID)
+	pe.putInt16(t.ProducerEpoch)
+	pe.putBool(t.VerifyOnly)
+	if err := encodeTxnTopicPartitions(pe, t.TopicPartitions); err != nil {
+		return err
+	}
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
 
+func (t *AddPartitionsToTxnTransaction) decode(pd packetDecoder) (err error) {
+	if t.TransactionalID, err = pd.getString(); err != nil {
+		return err
+	}
+	if t.ProducerID, err = pd.getInt64(); err != nil {
+		return err
+	}
+	if t.ProducerEpoch, err = pd.getInt16(); err != nil {
+		return err
+	}
+	if t.VerifyOnly, err = pd.getBool(); err != nil {
+		return err
+	}
+	if t.TopicPartitions, err = decodeTxnTopicPartitions(pd); err != nil {
+		return err
+	}
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func encodeTxnTopicPartitions(pe packetEncoder, topicPartitions map[string][]int32) error {
+	if err := pe.putArrayLength(len(topicPartitions)); err != nil {
+		return err
+	}
+	for topic, partitions := range topicPartitions {
+		if err := pe.putString(topic); err != nil {
+			return err
+		}
+		if err := pe.putInt32Array(partitions); err != nil {
+			return err
+		}
+		pe.putEmptyTaggedFieldArray()
+	}
+	return nil
+}
+
+func decodeTxnTopicPartitions(pd packetDecoder) (map[string][]int32, error) {
 	n, err := pd.getArrayLength()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	a.TopicPartitions = make(map[string][]int32)
+	topicPartitions := make(map[string][]int32)
 	for i := 0; i < n; i++ {
 		topic, err := pd.getString()
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		partitions, err := pd.getInt32Array()
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		a.TopicPartitions[topic] = partitions
+		if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+			return nil, err
+		}
+
+		topicPartitions[topic] = partitions
 	}
 
-	return nil
+	return topicPartitions, nil
 }
 
 func (a *AddPartitionsToTxnRequest) key() int16 {
@@ -78,15 +178,30 @@ func (a *AddPartitionsToTxnRequest) version() int16 {
 }
 
 func (a *AddPartitionsToTxnRequest) headerVersion() int16 {
+	if a.Version >= 3 {
+		return 2
+	}
 	return 1
 }
 
 func (a *AddPartitionsToTxnRequest) isValidVersion() bool {
-	return a.Version >= 0 && a.Version <= 2
+	return a.Version >= 0 && a.Version <= 4
+}
+
+func (a *AddPartitionsToTxnRequest) isFlexible() bool {
+	return a.isFlexibleVersion(a.Version)
+}
+
+func (a *AddPartitionsToTxnRequest) isFlexibleVersion(version int16) bool {
+	return version >= 3
 }
 
 func (a *AddPartitionsToTxnRequest) requiredVersion() KafkaVersion {
 	switch a.Version {
+	case 4:
+		return V3_6_0_0
+	case 3:
+		return V2_8_0_0
 	case 2:
 		return V2_7_0_0
 	case 1:
@@ -25,3 +25,72 @@ func TestAddPartitionsToTxnRequest(t *testing.T) {
 
 	testRequest(t, "", req, addPartitionsToTxnRequest)
 }
+
+var addPartitionsToTxnRequestV3 = []byte{
+	4, 't', 'x', 'n',
+	0, 0, 0, 0, 0, 0, 31, 64, // ProducerID
+	0, 0, // ProducerEpoch
+	2, // 1 topic
+	6, 't', 'o', 'p', 'i', 'c',
+	2, 0, 0, 0, 1, // partition 1
+	0, // empty tagged fields
+	0, // empty tagged fields
+}
+
+var addPartitionsToTxnRequestV4 = []byte{
+	3, // 2 transactions
+	4, 't', 'x', 'n',
+	0, 0, 0, 0, 0, 0, 31, 64, // ProducerID
+	0, 1, // ProducerEpoch
+	1, // VerifyOnly
+	2, // 1 topic
+	6, 't', 'o', 'p', 'i', 'c',
+	2, 0, 0, 0, 1, // partition 1
+	0, // empty tagged fields
+	0, // empty tagged fields
+	5, 'o', 't', 'h', 'e',
+	0, 0, 0, 0, 0, 0, 31, 65, // ProducerID
+	0, 0, // ProducerEpoch
+	0, // VerifyOnly
+	2, // 1 topic
+	6, 't', 'o', 'p', 'i', 'c',
+	3, 0, 0, 0, 2, 0, 0, 0, 3, // partitions 2 and 3
+	0, // empty tagged fields
+	0, // empty tagged fields
+	0, // empty tagged fields
+}
+
+func TestAddPartitionsToTxnRequestFlexible(t *testing.T) {
+	req := &AddPartitionsToTxnRequest{
+		Version:         3,
+		TransactionalID: "txn",
+		ProducerID:      8000,
+		TopicPartitions: map[string][]int32{
+			"topic": {1},
+		},
+	}
+
+	testRequest(t, "v3", req, addPartitionsToTxnRequestV3)
+}
+
+func TestAddPartitionsToTxnRequestBatched(t *testing.T) {
+	req := &AddPartitionsToTxnRequest{
+		Version: 4,
+		Transactions: []*AddPartitionsToTxnTransaction{
+			{
+				TransactionalID: "txn",
+				ProducerID:      8000,
+				ProducerEpoch:   1,
+				VerifyOnly:      true,
+				TopicPartitions: map[string][]int32{"topic": {1}},
+			},
+			{
+				TransactionalID: "othe",
+				ProducerID:      8001,
+				TopicPartitions: map[string][]int32{"topic": {2, 3}},
+			},
+		},
+	}
+
+	testRequest(t, "v4", req, addPartitionsToTxnRequestV4)
+}
@@ -8,7 +8,12 @@ import (
 type AddPartitionsToTxnResponse struct {
 	Version      int16
 	ThrottleTime time.Duration
-	Errors       map[string][]*PartitionError
+	// ErrorCode is the top level error of a version 4+ response.
+	ErrorCode KError
+	Errors    map[string][]*PartitionError
+	// ResultsByTransaction replaces Errors from version 4, holding the
+	// partition errors of every transactional ID of the request.
+	ResultsByTransaction map[string]map[string][]*PartitionError
 }
 
 func (a *AddPartitionsToTxnResponse) setVersion(v int16) {
@@ -17,11 +22,70 @@ func (a *AddPartitionsToTxnResponse) setVersion(v int16) {
 
 func (a *AddPartitionsToTxnResponse) encode(pe packetEncoder) error {
 	pe.putDurationMs(a.ThrottleTime)
-	if err := pe.putArrayLength(len(a.Errors)); err != nil {
+
+	if a.Version >= 4 {
+		pe.putKError(a.ErrorCode)
+		if err := pe.putArrayLength(len(a.ResultsByTransaction)); err != nil {
+			return err
+		}
+		for txnID, errs := range a.ResultsByTransaction {
+			if err := pe.putString(txnID); err != nil {
+				return err
+			}
+			if err := encodeTxnPartitionErrors(pe, errs); err != nil {
+				return err
+			}
+			pe.putEmptyTaggedFieldArray()
+		}
+	} else if err := encodeTxnPartitionErrors(pe, a.Errors); err != nil {
+		return err
+	}
+
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (a *AddPartitionsToTxnResponse) decode(pd packetDecoder, version int16) (err error) {
+	a.Version = version
+	if a.ThrottleTime, err = pd.getDurationMs(); err != nil {
+		return err
+	}
+
+	if version >= 4 {
+		if a.ErrorCode, err = pd.getKError(); err != nil {
+			return err
+		}
+		n, err := pd.getArrayLength()
+		if err != nil {
+			return err
+		}
+		a.ResultsByTransaction = make(map[string]map[string][]*PartitionError, n)
+		for i := 0; i < n; i++ {
+			txnID, err := pd.getString()
+			if err != nil {
+				return err
+			}
+			if a.ResultsByTransaction[txnID], err = decodeTxnPartitionErrors(pd, version); err != nil {
+				return err
+			}
+			if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+				return err
+			}
+		}
+	} else if a.Errors, err = decodeTxnPartitionErrors(pd, version); err != nil {
+		return err
+	}
+
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func encodeTxnPartitionErrors(pe packetEncoder, errs map[string][]*PartitionError) error {
+	if err := pe.putArrayLength(len(errs)); err != nil {
 		return err
 	}
 
-	for topic, e := range a.Errors {
+	for topic, e := range errs {
 		if err := pe.putString(topic); err != nil {
 			return err
 		}
@@ -33,46 +97,46 @@ func (a *AddPartitionsToTxnResponse) encode(pe packetEncoder) error {
 				return err
 			}
 		}
+		pe.putEmptyTaggedFieldArray()
 	}
 
 	return nil
 }
 
-func (a *AddPartitionsToTxnResponse) decode(pd packetDecoder, version int16) (err error) {
-	a.Version = version
-	if a.ThrottleTime, err = pd.getDurationMs(); err != nil {
-		return err
-	}
-
+func decodeTxnPartitionErrors(pd packetDecoder, version int16) (map[string][]*PartitionError, error) {
 	n, err := pd.getArrayLength()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	a.Errors = make(map[string][]*PartitionError)
+	errs := make(map[string][]*PartitionError)
 
 	for i := 0; i < n; i++ {
 		topic, err := pd.getString()
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		m, err := pd.getArrayLength()
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		a.Errors[topic] = make([]*PartitionError, m)
+		errs[topic] = make([]*PartitionError, m)
 
 		for j := 0; j < m; j++ {
-			a.Errors[topic][j] = new(PartitionError)
-			if err := a.Errors[topic][j].decode(pd, version); err != nil {
-				return err
+			errs[topic][j] = new(PartitionError)
+			if err := errs[topic][j].decode(pd, version); err != nil {
+				return nil, err
 			}
 		}
+
+		if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+			return nil, err
+		}
 	}
 
-	return nil
+	return errs, nil
 }
 
 func (a *AddPartitionsToTxnResponse) key() int16 {
@@ -84,15 +148,30 @@ func (a *AddPartitionsToTxnResponse) version() int16 {
 }
 
 func (a *AddPartitionsToTxnResponse) headerVersion() int16 {
+	if a.Version >= 3 {
+		return 1
+	}
 	return 0
 }
 
 func (a *AddPartitionsToTxnResponse) isValidVersion() bool {
-	return a.Version >= 0 && a.Version <= 2
+	return a.Version >= 0 && a.Version <= 4
+}
+
+func (a *AddPartitionsToTxnResponse) isFlexible() bool {
+	return a.isFlexibleVersion(a.Version)
+}
+
+func (a *AddPartitionsToTxnResponse) isFlexibleVersion(version int16) bool {
+	return version >= 3
 }
 
 func (a *AddPartitionsToTxnResponse) requiredVersion() KafkaVersion {
 	switch a.Version {
+	case 4:
+		return V3_6_0_0
+	case 3:
+		return V2_8_0_0
 	case 2:
 		return V2_7_0_0
 	case 1:
@@ -115,6 +194,7 @@ type PartitionError struct {
 func (p *PartitionError) encode(pe packetEncoder) error {
 	pe.putInt32(p.Partition)
 	pe.putKError(p.Err)
+	pe.putEmptyTaggedFieldArray()
 	return nil
 }
 
@@ -128,5 +208,6 @@ func (p *PartitionError) decode(pd packetDecoder, version int16) (err error) {
 		return err
 	}
 
-	return nil
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
 }
@@ -29,3 +29,63 @@ func TestAddPartitionsToTxnResponse(t *testing.T) {
 
 	testResponse(t, "", resp, addPartitionsToTxnResponse)
 }
+
+var addPartitionsToTxnResponseV3 = []byte{
+	0, 0, 0, 100,
+	2, // 1 topic
+	6, 't', 'o', 'p', 'i', 'c',
+	2,          // 1 partition error
+	0, 0, 0, 2, // partition 2
+	0, 48, // error
+	0, // empty tagged fields
+	0, // empty tagged fields
+	0, // empty tagged fields
+}
+
+var addPartitionsToTxnResponseV4 = []byte{
+	0, 0, 0, 100,
+	0, 0, // ErrorCode
+	2, // 1 transaction
+	4, 't', 'x', 'n',
+	2, // 1 topic
+	6, 't', 'o', 'p', 'i', 'c',
+	2,          // 1 partition error
+	0, 0, 0, 2, // partition 2
+	0, 48, // error
+	0, // empty tagged fields
+	0, // empty tagged fields
+	0, // empty tagged fields
+	0, // empty tagged fields
+}
+
+func TestAddPartitionsToTxnResponseFlexible(t *testing.T) {
+	resp := &AddPartitionsToTxnResponse{
+		Version:      3,
+		ThrottleTime: 100 * time.Millisecond,
+		Errors: map[string][]*PartitionError{
+			"topic": {{
+				Err:       ErrInvalidTxnState,
+				Partition: 2,
+			}},
+		},
+	}
+
+	testResponse(t, "v3", resp, addPartitionsToTxnResponseV3)
+}
+
+func TestAddPartitionsToTxnResponseBatched(t *testing.T) {
+	resp := &AddPartitionsToTxnResponse{
+		Version:      4,
+		ThrottleTime: 100 * time.Millisecond,
+		ResultsByTransaction: map[string]map[string][]*PartitionError{
+			"txn": {
+				"topic": {{
+					Err:       ErrInvalidTxnState,
+					Partition: 2,
+				}},
+			},
+		},
+	}
+
+	testResponse(t, "v4", resp, addPartitionsToTxnResponseV4)
+}
@@ -9,6 +9,7 @@ import (
 	"net"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -23,6 +24,13 @@ type ClusterAdmin interface {
 	// may not return information about the new topic.The validateOnly option is supported from version 0.10.2.0.
 	CreateTopic(topic string, detail *TopicDetail, validateOnly bool) error
 
+	// Creates new topics in a single request, like CreateTopic. When some of
+	// the topics could not be created, the returned error is a
+	// TopicCreationErrors holding the error of each of them, the other topics
+	// are created. This operation is supported by brokers with version
+	// 0.10.1.0 or higher.
+	CreateTopics(topicDetails map[string]*TopicDetail, validateOnly bool) error
+
 	// List the topics available in the cluster with the default options.
 	ListTopics() (map[string]TopicDetail, error)
 
@@ -49,14 +57,56 @@ type ClusterAdmin interface {
 	// This operation is supported by brokers with version 2.4.0.0 or higher.
 	AlterPartitionReassignments(topic string, assignment [][]int32) error
 
+	// AlterPartitionReassignmentsByPartition reassigns the replicas of the given
+	// partitions of topic, leaving the other partitions untouched. Passing nil
+	// replicas for a partition cancels its in-flight reassignment.
+	// This operation is supported by brokers with version 2.4.0.0 or higher.
+	AlterPartitionReassignmentsByPartition(topic string, assignment map[int32][]int32) error
+
 	// Provides info on ongoing partitions replica reassignments.
 	// This operation is supported by brokers with version 2.4.0.0 or higher.
 	ListPartitionReassignments(topics string, partitions []int32) (topicStatus map[string]map[int32]*PartitionReplicaReassignmentsStatus, err error)
 
+	// ListPartitionReassignmentsByTopic provides info on the ongoing replica
+	// reassignments of the given partitions of several topics, including the
+	// replicas being added and removed. A nil topics map lists all ongoing
+	// reassignments in the cluster.
+	// This operation is supported by brokers with version 2.4.0.0 or higher.
+	ListPartitionReassignmentsByTopic(topics map[string][]int32) (topicStatus map[string]map[int32]*PartitionReplicaReassignmentsStatus, err error)
+
 	// Delete records whose offset is smaller than the given offset of the corresponding partition.
 	// This operation is supported by brokers with version 0.11.0.0 or higher.
 	DeleteRecords(topic string, partitionOffsets map[int32]int64) error
 
+	// DeleteRecordsWithResults behaves like DeleteRecords but reports the outcome
+	// of every partition: the new low watermark of the partition, or the error
+	// that prevented the deletion. An offset beyond the end of the log is
+	// reported as ErrOffsetOutOfRange. The request is sent to the leader of each
+	// partition, and the returned error is only set if the request is invalid.
+	// This operation is supported by brokers with version 0.11.0.0 or higher.
+	DeleteRecordsWithResults(topic string, partitionOffsets map[int32]int64) (map[int32]*DeleteRecordsResult, error)
+
+	// DescribeProducers returns the active producers on the given partition,
+	// including their epochs, sequence numbers and any ongoing transaction.
+	// This operation is supported by brokers with version 2.8.0.0 or higher.
+	DescribeProducers(topic string, partition int32) ([]*ProducerState, error)
+
+	// DescribeTransactions returns the state of the transactions of the given
+	// transactional IDs, keyed by transactional ID, asking the transaction
+	// coordinator of every ID. The IDs a coordinator failed to describe have
+	// the ErrorCode of their TransactionState set, e.g. to
+	// ErrTransactionalIDNotFound.
+	// This operation is supported by brokers with version 3.0.0.0 or higher.
+	DescribeTransactions(transactionalIDs []string) (map[string]*TransactionState, error)
+
+	// ListTransactions lists the transactional producers of the cluster,
+	// asking every broker as the transactions are spread across the
+	// transaction coordinators. Only the transactions in one of stateFilters,
+	// one of the TransactionState* constants, and of one of producerIDFilters
+	// are listed, empty filters match everything.
+	// This operation is supported by brokers with version 3.0.0.0 or higher.
+	ListTransactions(stateFilters []string, producerIDFilters []int64) ([]*TransactionListing, error)
+
 	// Get the configuration for the specified resources.
 	// The returned configuration includes default values and the Default is true
 	// can be used to distinguish them from user supplied values.
@@ -66,6 +116,14 @@ type ClusterAdmin interface {
 	// This operation is supported by brokers with version 0.11.0.0 or higher.
 	DescribeConfig(resource ConfigResource) ([]ConfigEntry, error)
 
+	// DescribeConfigs gets the configuration of several resources, possibly
+	// of mixed types, at once. Topic resources are described in a single
+	// request, broker resources in a request per broker in question. The
+	// results are keyed by resource type and name and hold the error of the
+	// resources which could not be described.
+	// This operation is supported by brokers with version 0.11.0.0 or higher.
+	DescribeConfigs(resources []ConfigResource) (map[ConfigResourceKey]*DescribeConfigsResult, error)
+
 	// Update the configuration for the specified resources with the default options.
 	// This operation is supported by brokers with version 0.11.0.0 or higher.
 	// The resources with their configs (topic is the only resource type with configs
@@ -75,8 +133,12 @@ type ClusterAdmin interface {
 
 	// IncrementalAlterConfig Incrementally Update the configuration for the specified resources with the default options.
 	// This operation is supported by brokers with version 2.3.0.0 or higher.
+	// Unlike AlterConfig, the configs that are not part of entries keep their value, each
+	// entry being set, deleted (reset to its default), appended to or subtracted from (for
+	// list configs) as per its IncrementalAlterConfigsOperation (KIP-339).
 	// Updates are not transactional so they may succeed for some resources while fail for others.
 	// The configs for a particular resource are updated automatically.
+	// Broker and broker logger configs are sent to the broker in question, others to the controller.
 	IncrementalAlterConfig(resourceType ConfigResourceType, name string, entries map[string]IncrementalAlterConfigsEntry, validateOnly bool) error
 
 	// Creates an access control list (ACL) which is bound to a specific resource.
@@ -98,13 +160,26 @@ type ClusterAdmin interface {
 	// This operation is supported by brokers with version 0.11.0.0 or higher.
 	ListAcls(filter AclFilter) ([]ResourceAcls, error)
 
+	// ListAclsFiltered lists the ACLs matching filter, which is best built
+	// with NewAclFilterBuilder. Unlike ListAcls, it takes care of the resource
+	// pattern types the version of the protocol in use supports: a filter
+	// without pattern type matches any, and the MATCH pattern type returns the
+	// literal, wildcard and prefixed ACLs applying to the resource, which is
+	// done by the client for Kafka versions older than 2.0. Prefixed filters
+	// require Kafka 2.0 or later.
+	// This operation is supported by brokers with version 0.11.0.0 or higher.
+	ListAclsFiltered(filter AclFilter) ([]ResourceAcls, error)
+
 	// Deletes access control lists (ACLs) according to the supplied filters.
 	// This operation is not transactional so it may succeed for some ACLs while fail for others.
 	// This operation is supported by brokers with version 0.11.0.0 or higher.
 	DeleteACL(filter AclFilter, validateOnly bool) ([]MatchingAcl, error)
 
-	// ElectLeaders allows to trigger the election of preferred leaders for a set of partitions.
-	ElectLeaders(ElectionType, map[string][]int32) (map[string]map[int32]*PartitionResult, error)
+	// ElectLeaders allows to trigger the election of preferred or unclean leaders
+	// for a set of partitions, or for all partitions when partitions is nil.
+	// The result holds the outcome per partition; partitions whose leader is
+	// already the preferred one report ErrElectionNotNeeded.
+	ElectLeaders(electionType ElectionType, partitions map[string][]int32) (map[string]map[int32]*PartitionResult, error)
 
 	// List the consumer groups available in the cluster.
 	ListConsumerGroups() (map[string]string, error)
@@ -119,8 +194,9 @@ type ClusterAdmin interface {
 	// in a single round trip per coordinator using OffsetFetch v8+ (KIP-709). A nil
 	// partitions map fetches offsets for all topics in that group.
 	//
-	// Returns ErrUnsupportedVersion if any coordinator advertises < v8. Requires
-	// Config.ApiVersionsRequest to be enabled for the version check to take effect.
+	// Coordinators that do not support v8, either because Config.Version is older
+	// than V3_0_0_0 or because they advertise an older version, are sent one
+	// request per group instead.
 	//
 	// On a retriable per-group error all coordinators are re-resolved and every group
 	// is re-batched. Non-retriable per-group errors are returned in the per-group Err.
@@ -140,7 +216,10 @@ type ClusterAdmin interface {
 	// This operation is not transactional so it may succeed for some partitions while fail for others.
 	AlterConsumerGroupOffsets(group string, offsets map[string]map[int32]OffsetAndMetadata, options *AlterConsumerGroupOffsetsOptions) (*OffsetCommitResponse, error)
 
-	// Deletes a consumer group offset
+	// Deletes a consumer group offset. The request targets the group's coordinator
+	// and is supported by brokers with version 2.4.0.0 or higher (KIP-496).
+	// ErrGroupSubscribedToTopic is returned if the group is still actively
+	// subscribed to the topic, in which case its consumers must be stopped first.
 	DeleteConsumerGroupOffset(group string, topic string, partition int32) error
 
 	// Delete a consumer group.
@@ -149,16 +228,34 @@ type ClusterAdmin interface {
 	// Get information about the nodes in the cluster
 	DescribeCluster() (brokers []*Broker, controllerID int32, err error)
 
-	// Get information about all log directories on the given set of brokers
+	// DescribeClusterInfo describes the cluster with the DescribeCluster API,
+	// which is supported by brokers with version 2.8.0.0 or higher and may be
+	// answered by any broker. Along with the cluster ID, controller and brokers
+	// it returns the bitfield of the operations the client is authorized to
+	// perform on the cluster (KIP-430), see AclOperationsFromBitfield.
+	DescribeClusterInfo() (clusterID string, controllerID int32, brokers []*Broker, clusterAuthorizedOperations int32, err error)
+
+	// Get information about all log directories on the given set of brokers, or
+	// on every broker in the cluster if brokers is empty. Each directory reports
+	// its own ErrorCode (e.g. ErrKafkaStorageError for an offline directory) and,
+	// from Kafka 3.3, its TotalBytes and UsableBytes.
 	DescribeLogDirs(brokers []int32) (map[int32][]DescribeLogDirsResponseDirMetadata, error)
 
-	// Get information about SCRAM users
+	// Get information about SCRAM users (KIP-554), or about all of them if
+	// users is empty. Each result carries its own ErrorCode, e.g.
+	// ErrResourceNotFound for a user without credentials. This operation is
+	// supported by brokers with version 2.7.0 or higher.
 	DescribeUserScramCredentials(users []string) ([]*DescribeUserScramCredentialsResult, error)
 
-	// Delete SCRAM users
+	// Delete SCRAM users (KIP-554). Each result carries its own ErrorCode.
+	// This operation is supported by brokers with version 2.7.0 or higher.
 	DeleteUserScramCredentials(delete []AlterUserScramCredentialsDelete) ([]*AlterUserScramCredentialsResult, error)
 
-	// Upsert SCRAM users
+	// Upsert SCRAM users (KIP-554). The salted password is derived client-side
+	// from the Password, Salt and Iterations of each upsert, so the password is
+	// never sent to the brokers. Each result carries its own ErrorCode, e.g.
+	// ErrUnacceptableCredential. This operation is supported by brokers with
+	// version 2.7.0 or higher.
 	UpsertUserScramCredentials(upsert []AlterUserScramCredentialsUpsert) ([]*AlterUserScramCredentialsResult, error)
 
 	// Get client quota configurations corresponding to the specified filter.
@@ -189,6 +286,46 @@ type ClusterAdmin interface {
 type clusterAdmin struct {
 	client Client
 	conf   *Config
+
+	// pools holds the connections per broker ID admin requests are spread
+	// over when Admin.ConnectionPoolSize is greater than 1, as of the
+	// poolsGeneration of the brokers of the client
+	pools           map[int32]*adminConnectionPool
+	poolsGeneration uint64
+	poolLock        sync.Mutex
+}
+
+// brokersGenerationer is implemented by the clients tracking the changes of
+// their brokers, see client.brokersGeneration.
+type brokersGenerationer interface {
+	brokersGeneration() uint64
+}
+
+// adminConnectionPool is a set of connections to a single broker: the one of
+// the client and Admin.ConnectionPoolSize-1 extra ones, each tracking its own
+// correlation IDs.
+type adminConnectionPool struct {
+	conns []*Broker
+	next  atomic.Uint32
+}
+
+func newAdminConnectionPool(broker *Broker, size int) *adminConnectionPool {
+	pool := &adminConnectionPool{conns: make([]*Broker, size)}
+	pool.conns[0] = broker
+	for i := 1; i < size; i++ {
+		conn := NewBroker(broker.Addr())
+		conn.id, conn.rack = broker.id, broker.rack
+		pool.conns[i] = conn
+	}
+	return pool
+}
+
+// close closes the extra connections of the pool, the one of the client is
+// left to the client.
+func (p *adminConnectionPool) close() {
+	for _, conn := range p.conns[1:] {
+		_ = conn.Close()
+	}
 }
 
 // NewClusterAdmin creates a new ClusterAdmin using the given broker addresses and configuration.
@@ -221,6 +358,13 @@ func NewClusterAdminFromClient(client Client) (ClusterAdmin, error) {
 }
 
 func (ca *clusterAdmin) Close() error {
+	ca.poolLock.Lock()
+	for _, pool := range ca.pools {
+		pool.close()
+	}
+	ca.pools = nil
+	ca.poolLock.Unlock()
+
 	return ca.client.Close()
 }
 
@@ -232,6 +376,88 @@ func (ca *clusterAdmin) Coordinator(group string) (*Broker, error) {
 	return ca.client.Coordinator(group)
 }
 
+// pooled returns the connection to broker the next admin request is to be sent
+// on, in turn among the ones of its pool, or broker itself if
+// Admin.ConnectionPoolSize is 1.
+func (ca *clusterAdmin) pooled(broker *Broker) *Broker {
+	size := ca.conf.Admin.ConnectionPoolSize
+	if size <= 1 {
+		return broker
+	}
+
+	ca.poolLock.Lock()
+	if c, ok := ca.client.(brokersGenerationer); ok {
+		if generation := c.brokersGeneration(); generation != ca.poolsGeneration {
+			ca.poolsGeneration = generation
+			ca.prunePools()
+		}
+	}
+	pool := ca.pools[broker.ID()]
+	if pool == nil || pool.conns[0] != broker {
+		// the client replaced the broker, e.g. as its address changed
+		if pool != nil {
+			pool.close()
+		}
+		if ca.pools == nil {
+			ca.pools = make(map[int32]*adminConnectionPool)
+		}
+		pool = newAdminConnectionPool(broker, size)
+		ca.pools[broker.ID()] = pool
+	}
+	ca.poolLock.Unlock()
+
+	conn := pool.conns[(pool.next.Add(1)-1)%uint32(len(pool.conns))]
+	_ = conn.Open(ca.conf)
+	return conn
+}
+
+// prunePools closes the pools of the brokers the client no longer knows, e.g.
+// as they left the cluster. It is called once the brokers of the client
+// changed, as they are not looked up on every request.
+// ca.poolLock must be held by caller
+func (ca *clusterAdmin) prunePools() {
+	if len(ca.pools) == 0 {
+		return
+	}
+	current := make(map[*Broker]bool)
+	for _, broker := range ca.client.Brokers() {
+		current[broker] = true
+	}
+	for id, pool := range ca.pools {
+		if !current[pool.conns[0]] {
+			pool.close()
+			delete(ca.pools, id)
+		}
+	}
+}
+
+// controller returns a pooled connection to the controller.
+func (ca *clusterAdmin) controller() (*Broker, error) {
+	broker, err := ca.client.Controller()
+	if err != nil {
+		return nil, err
+	}
+	return ca.pooled(broker), nil
+}
+
+// coordinator returns a pooled connection to the coordinator of the group.
+func (ca *clusterAdmin) coordinator(group string) (*Broker, error) {
+	broker, err := ca.client.Coordinator(group)
+	if err != nil {
+		return nil, err
+	}
+	return ca.pooled(broker), nil
+}
+
+// brokers returns a pooled connection to each broker of the cluster.
+func (ca *clusterAdmin) brokers() []*Broker {
+	brokers := ca.client.Brokers()
+	for i, broker := range brokers {
+		brokers[i] = ca.pooled(broker)
+	}
+	return brokers
+}
+
 func (ca *clusterAdmin) refreshController() (*Broker, error) {
 	return ca.client.RefreshController()
 }
@@ -249,6 +475,13 @@ func isRetriableGroupCoordinatorError(err error) bool {
 	return errors.Is(err, ErrNotCoordinatorForConsumer) || errors.Is(err, ErrConsumerCoordinatorNotAvailable) || errors.Is(err, io.EOF)
 }
 
+// isRetriableCoordinatorLoadError returns `true` if the given error type
+// unwraps to an `ErrOffsetsLoadInProgress` (COORDINATOR_LOAD_IN_PROGRESS)
+// response from Kafka, in which case the coordinator answers once loaded
+func isRetriableCoordinatorLoadError(err error) bool {
+	return errors.Is(err, ErrOffsetsLoadInProgress)
+}
+
 // isRetriableListTopicsError returns true for controller errors and transient
 // transport failures where reconnecting and retrying can succeed.
 func isRetriableListTopicsError(err error) bool {
@@ -259,6 +492,13 @@ func isRetriableListTopicsError(err error) bool {
 	return isTimeoutError(err)
 }
 
+// isRetriableLeaderError returns `true` if the given error type unwraps to an
+// `ErrNotLeaderForPartition`, `ErrLeaderNotAvailable` or `EOF`, in which case
+// refreshing metadata and retrying against the new leader can succeed
+func isRetriableLeaderError(err error) bool {
+	return errors.Is(err, ErrNotLeaderForPartition) || errors.Is(err, ErrLeaderNotAvailable) || errors.Is(err, io.EOF)
+}
+
 // isRetriableBrokerError returns `true` if the given error is a retryable
 // transport error or a timeout.
 func isRetriableBrokerError(err error) bool {
@@ -288,6 +528,23 @@ func (ca *clusterAdmin) retryOnError(retryable func(error) bool, fn func() error
 	}
 }
 
+// retryOnController calls fn with the controller, re-discovering the
+// controller from fresh metadata and retrying whenever fn fails with a
+// retriable controller error, up to Admin.Retry.Max times.
+func (ca *clusterAdmin) retryOnController(fn func(controller *Broker) error) error {
+	return ca.retryOnError(isRetriableControllerError, func() error {
+		b, err := ca.controller()
+		if err != nil {
+			return err
+		}
+		err = fn(b)
+		if isRetriableControllerError(err) {
+			_, _ = ca.refreshController()
+		}
+		return err
+	})
+}
+
 func (ca *clusterAdmin) CreateTopic(topic string, detail *TopicDetail, validateOnly bool) error {
 	if topic == "" {
 		return ErrInvalidTopic
@@ -309,7 +566,7 @@ func (ca *clusterAdmin) CreateTopic(topic string, detail *TopicDetail, validateO
 	)
 
 	return ca.retryOnError(isRetriableControllerError, func() error {
-		b, err := ca.Controller()
+		b, err := ca.controller()
 		if err != nil {
 			return err
 		}
@@ -335,10 +592,76 @@ func (ca *clusterAdmin) CreateTopic(topic string, detail *TopicDetail, validateO
 	})
 }
 
+func (ca *clusterAdmin) CreateTopics(topicDetails map[string]*TopicDetail, validateOnly bool) error {
+	for topic, detail := range topicDetails {
+		if topic == "" {
+			return ErrInvalidTopic
+		}
+		if detail == nil {
+			return fmt.Errorf("you must specify the details of topic %s", topic)
+		}
+	}
+
+	// the topics that failed with a retriable error are sent again
+	pending := maps.Clone(topicDetails)
+	failed := make(TopicCreationErrors)
+	err := ca.retryOnError(isRetriableControllerError, func() error {
+		b, err := ca.controller()
+		if err != nil {
+			return err
+		}
+
+		request := NewCreateTopicsRequest(
+			ca.conf.Version,
+			pending,
+			ca.conf.Admin.Timeout,
+			validateOnly,
+		)
+		rsp, err := b.CreateTopics(request)
+		if err != nil {
+			return err
+		}
+
+		var retryErr error
+		for topic := range pending {
+			topicErr, ok := rsp.TopicErrors[topic]
+			if !ok {
+				return ErrIncompleteResponse
+			}
+
+			switch {
+			case errors.Is(topicErr.Err, ErrNoError):
+				delete(failed, topic)
+				delete(pending, topic)
+			case isRetriableControllerError(topicErr.Err):
+				failed[topic] = topicErr
+				retryErr = topicErr
+			default:
+				failed[topic] = topicErr
+				delete(pending, topic)
+			}
+		}
+
+		if retryErr != nil {
+			_, _ = ca.refreshController()
+		}
+		return retryErr
+	})
+
+	var topicErr *TopicError
+	if err != nil && !errors.As(err, &topicErr) {
+		return err
+	}
+	if len(failed) > 0 {
+		return failed
+	}
+	return nil
+}
+
 func (ca *clusterAdmin) DescribeTopics(topics []string) (metadata []*TopicMetadata, err error) {
 	var response *MetadataResponse
 	err = ca.retryOnError(isRetriableControllerError, func() error {
-		controller, err := ca.Controller()
+		controller, err := ca.controller()
 		if err != nil {
 			return err
 		}
@@ -371,7 +694,7 @@ func (ca *clusterAdmin) DescribeCluster() (brokers []*Broker, controllerID int32
 func (ca *clusterAdmin) describeClusterUsingAPI() (brokers []*Broker, controllerID int32, err error) {
 	var response *DescribeClusterResponse
 	err = ca.retryOnError(isRetriableControllerError, func() error {
-		controller, err := ca.Controller()
+		controller, err := ca.controller()
 		if err != nil {
 			return err
 		}
@@ -385,10 +708,7 @@ func (ca *clusterAdmin) describeClusterUsingAPI() (brokers []*Broker, controller
 			if isRetriableControllerError(response.Err) {
 				_, _ = ca.refreshController()
 			}
-			if response.ErrorMessage != nil && *response.ErrorMessage != "" {
-				return fmt.Errorf("%w: %s", response.Err, *response.ErrorMessage)
-			}
-			return response.Err
+			return describeClusterError(response)
 		}
 		return nil
 	})
@@ -400,10 +720,52 @@ func (ca *clusterAdmin) describeClusterUsingAPI() (brokers []*Broker, controller
 	return brokers, response.ControllerID, nil
 }
 
+func (ca *clusterAdmin) DescribeClusterInfo() (clusterID string, controllerID int32, brokers []*Broker, clusterAuthorizedOperations int32, err error) {
+	if !ca.conf.Version.IsAtLeast(V2_8_0_0) {
+		return "", 0, nil, 0, ErrUnsupportedVersion
+	}
+
+	var response *DescribeClusterResponse
+	err = ca.retryOnError(isRetriableBrokerError, func() error {
+		b, err := ca.findAnyBroker()
+		if err != nil {
+			return err
+		}
+		_ = b.Open(ca.client.Config())
+
+		request := NewDescribeClusterRequest(ca.conf.Version)
+		request.IncludeClusterAuthorizedOperations = true
+		response, err = b.DescribeCluster(request)
+		if err != nil {
+			if isTimeoutError(err) {
+				_ = b.Close()
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return "", 0, nil, 0, err
+	}
+	if !errors.Is(response.Err, ErrNoError) {
+		return "", 0, nil, 0, describeClusterError(response)
+	}
+
+	brokers = convertDescribeClusterBrokers(response.Brokers)
+	return response.ClusterID, response.ControllerID, brokers, response.ClusterAuthorizedOperations, nil
+}
+
+func describeClusterError(response *DescribeClusterResponse) error {
+	if response.ErrorMessage != nil && *response.ErrorMessage != "" {
+		return fmt.Errorf("%w: %s", response.Err, *response.ErrorMessage)
+	}
+	return response.Err
+}
+
 func (ca *clusterAdmin) describeClusterUsingMetadata() (brokers []*Broker, controllerID int32, err error) {
 	var response *MetadataResponse
 	err = ca.retryOnError(isRetriableControllerError, func() error {
-		controller, err := ca.Controller()
+		controller, err := ca.controller()
 		if err != nil {
 			return err
 		}
@@ -423,10 +785,10 @@ func (ca *clusterAdmin) describeClusterUsingMetadata() (brokers []*Broker, contr
 }
 
 func convertDescribeClusterBrokers(entries []*DescribeClusterBroker) []*Broker {
-	// TODO: DescribeCluster brokers currently drop DescribeCluster-specific fields
-	// such as IsFenced (KIP-1073) and ClusterAuthorizedOperations because Broker
-	// has no equivalents yet. This keeps API parity with MetadataResponse for now,
-	// but the richer fields need to be surfaced in a future change.
+	// TODO: DescribeCluster brokers currently drop DescribeCluster-specific
+	// fields such as IsFenced (KIP-1073) because Broker has no equivalents
+	// yet. This keeps API parity with MetadataResponse for now, but the richer
+	// fields need to be surfaced in a future change.
 	if len(entries) == 0 {
 		return nil
 	}
@@ -445,7 +807,7 @@ func (ca *clusterAdmin) findBroker(id int32) (*Broker, error) {
 	brokers := ca.client.Brokers()
 	for _, b := range brokers {
 		if b.ID() == id {
-			return b, nil
+			return ca.pooled(b), nil
 		}
 	}
 	return nil, fmt.Errorf("could not find broker id %d", id)
@@ -455,7 +817,7 @@ func (ca *clusterAdmin) findAnyBroker() (*Broker, error) {
 	brokers := ca.client.Brokers()
 	if len(brokers) > 0 {
 		index := rand.Intn(len(brokers))
-		return brokers[index], nil
+		return ca.pooled(brokers[index]), nil
 	}
 	return nil, errors.New("no available broker")
 }
@@ -577,7 +939,7 @@ func (ca *clusterAdmin) DeleteTopic(topic string) error {
 	}
 
 	return ca.retryOnError(isRetriableControllerError, func() error {
-		b, err := ca.Controller()
+		b, err := ca.controller()
 		if err != nil {
 			return err
 		}
@@ -627,7 +989,7 @@ func (ca *clusterAdmin) CreatePartitions(topic string, count int32, assignment [
 	}
 
 	return ca.retryOnError(isRetriableControllerError, func() error {
-		b, err := ca.Controller()
+		b, err := ca.controller()
 		if err != nil {
 			return err
 		}
@@ -654,6 +1016,14 @@ func (ca *clusterAdmin) CreatePartitions(topic string, count int32, assignment [
 }
 
 func (ca *clusterAdmin) AlterPartitionReassignments(topic string, assignment [][]int32) error {
+	partitionAssignment := make(map[int32][]int32, len(assignment))
+	for i := 0; i < len(assignment); i++ {
+		partitionAssignment[int32(i)] = assignment[i]
+	}
+	return ca.AlterPartitionReassignmentsByPartition(topic, partitionAssignment)
+}
+
+func (ca *clusterAdmin) AlterPartitionReassignmentsByPartition(topic string, assignment map[int32][]int32) error {
 	if topic == "" {
 		return ErrInvalidTopic
 	}
@@ -663,16 +1033,11 @@ func (ca *clusterAdmin) AlterPartitionReassignments(topic string, assignment [][
 		Version:   int16(0),
 	}
 
-	for i := 0; i < len(assignment); i++ {
-		request.AddBlock(topic, int32(i), assignment[i])
+	for partition, replicas := range assignment {
+		request.AddBlock(topic, partition, replicas)
 	}
 
-	return ca.retryOnError(isRetriableControllerError, func() error {
-		b, err := ca.Controller()
-		if err != nil {
-			return err
-		}
-
+	return ca.retryOnController(func(b *Broker) error {
 		errs := make([]error, 0)
 
 		rsp, err := b.AlterPartitionReassignments(request)
@@ -705,17 +1070,25 @@ func (ca *clusterAdmin) ListPartitionReassignments(topic string, partitions []in
 	if topic == "" {
 		return nil, ErrInvalidTopic
 	}
+	return ca.ListPartitionReassignmentsByTopic(map[string][]int32{topic: partitions})
+}
 
+func (ca *clusterAdmin) ListPartitionReassignmentsByTopic(topics map[string][]int32) (topicStatus map[string]map[int32]*PartitionReplicaReassignmentsStatus, err error) {
 	request := &ListPartitionReassignmentsRequest{
 		TimeoutMs: int32(60000),
 		Version:   int16(0),
 	}
 
-	request.AddBlock(topic, partitions)
+	for topic, partitions := range topics {
+		if topic == "" {
+			return nil, ErrInvalidTopic
+		}
+		request.AddBlock(topic, partitions)
+	}
 
 	var rsp *ListPartitionReassignmentsResponse
 	err = ca.retryOnError(isRetriableControllerError, func() error {
-		b, err := ca.Controller()
+		b, err := ca.controller()
 		if err != nil {
 			return err
 		}
@@ -735,16 +1108,41 @@ func (ca *clusterAdmin) ListPartitionReassignments(topic string, partitions []in
 	}
 }
 
+// DeleteRecordsResult is the outcome of DeleteRecordsWithResults for a single partition.
+type DeleteRecordsResult struct {
+	// LowWatermark is the earliest offset of the partition after the deletion,
+	// or -1 if Err is set.
+	LowWatermark int64
+	Err          error
+}
+
 func (ca *clusterAdmin) DeleteRecords(topic string, partitionOffsets map[int32]int64) error {
-	if topic == "" {
-		return ErrInvalidTopic
+	results, err := ca.DeleteRecordsWithResults(topic, partitionOffsets)
+	if err != nil {
+		return err
 	}
 	errs := make([]error, 0)
+	for _, result := range results {
+		if result.Err != nil {
+			errs = append(errs, result.Err)
+		}
+	}
+	if len(errs) > 0 {
+		return Wrap(ErrDeleteRecords, errs...)
+	}
+	return nil
+}
+
+func (ca *clusterAdmin) DeleteRecordsWithResults(topic string, partitionOffsets map[int32]int64) (map[int32]*DeleteRecordsResult, error) {
+	if topic == "" {
+		return nil, ErrInvalidTopic
+	}
+	results := make(map[int32]*DeleteRecordsResult, len(partitionOffsets))
 	partitionPerBroker := make(map[*Broker][]int32)
 	for partition := range partitionOffsets {
 		broker, err := ca.client.Leader(topic, partition)
 		if err != nil {
-			errs = append(errs, err)
+			results[partition] = &DeleteRecordsResult{LowWatermark: -1, Err: err}
 			continue
 		}
 		partitionPerBroker[broker] = append(partitionPerBroker[broker], partition)
@@ -753,6 +1151,8 @@ func (ca *clusterAdmin) DeleteRecords(topic string, partitionOffsets map[int32]i
 		recordsToDelete := make(map[int32]int64, len(partitions))
 		for _, p := range partitions {
 			recordsToDelete[p] = partitionOffsets[p]
+			// overwritten below by the partition's result, if any
+			results[p] = &DeleteRecordsResult{LowWatermark: -1, Err: ErrIncompleteResponse}
 		}
 		topics := map[string]*DeleteRecordsRequestTopic{
 			topic: {
@@ -768,29 +1168,177 @@ func (ca *clusterAdmin) DeleteRecords(topic string, partitionOffsets map[int32]i
 		}
 		rsp, err := broker.DeleteRecords(request)
 		if err != nil {
-			errs = append(errs, err)
+			for _, p := range partitions {
+				results[p].Err = err
+			}
 			continue
 		}
 
 		deleteRecordsResponseTopic, ok := rsp.Topics[topic]
 		if !ok {
-			errs = append(errs, ErrIncompleteResponse)
 			continue
 		}
 
-		for _, deleteRecordsResponsePartition := range deleteRecordsResponseTopic.Partitions {
+		for _, p := range partitions {
+			deleteRecordsResponsePartition, ok := deleteRecordsResponseTopic.Partitions[p]
+			if !ok {
+				continue
+			}
+			results[p].LowWatermark = deleteRecordsResponsePartition.LowWatermark
+			results[p].Err = nil
 			if !errors.Is(deleteRecordsResponsePartition.Err, ErrNoError) {
-				errs = append(errs, deleteRecordsResponsePartition.Err)
+				results[p].Err = deleteRecordsResponsePartition.Err
+			}
+		}
+	}
+	return results, nil
+}
+
+func (ca *clusterAdmin) DescribeProducers(topic string, partition int32) ([]*ProducerState, error) {
+	if topic == "" {
+		return nil, ErrInvalidTopic
+	}
+
+	var producers []*ProducerState
+	err := ca.retryOnError(isRetriableLeaderError, func() error {
+		broker, err := ca.client.Leader(topic, partition)
+		if err != nil {
+			return err
+		}
+
+		request := &DescribeProducersRequest{
+			Topics: []*DescribeProducersRequestTopic{{
+				Name:             topic,
+				PartitionIndexes: []int32{partition},
+			}},
+		}
+		rsp, err := broker.DescribeProducers(request)
+		if err != nil {
+			return err
+		}
+
+		for _, t := range rsp.Topics {
+			if t.Name != topic {
 				continue
 			}
+			for _, p := range t.Partitions {
+				if p.PartitionIndex != partition {
+					continue
+				}
+				if !errors.Is(p.ErrorCode, ErrNoError) {
+					if isRetriableLeaderError(p.ErrorCode) {
+						_ = ca.client.RefreshMetadata(topic)
+					}
+					if p.ErrorMessage != nil && *p.ErrorMessage != "" {
+						return fmt.Errorf("%w: %s", p.ErrorCode, *p.ErrorMessage)
+					}
+					return p.ErrorCode
+				}
+				producers = p.ActiveProducers
+				return nil
+			}
 		}
+		return ErrIncompleteResponse
+	})
+	if err != nil {
+		return nil, err
 	}
-	if len(errs) > 0 {
-		return Wrap(ErrDeleteRecords, errs...)
+	return producers, nil
+}
+
+func (ca *clusterAdmin) DescribeTransactions(transactionalIDs []string) (map[string]*TransactionState, error) {
+	// batch the IDs per coordinator, the pooled connection is picked per batch
+	coordinators := make(map[int32]*Broker)
+	idsPerBroker := make(map[int32][]string)
+	for _, transactionalID := range transactionalIDs {
+		coordinator, err := ca.client.TransactionCoordinator(transactionalID)
+		if err != nil {
+			return nil, err
+		}
+		coordinators[coordinator.ID()] = coordinator
+		idsPerBroker[coordinator.ID()] = append(idsPerBroker[coordinator.ID()], transactionalID)
 	}
-	// todo since we are dealing with couple of partitions it would be good if we return slice of errors
-	// for each partition instead of one error
-	return nil
+
+	states := make(map[string]*TransactionState, len(transactionalIDs))
+	for id, ids := range idsPerBroker {
+		broker := ca.pooled(coordinators[id])
+		response, err := broker.DescribeTransactions(&DescribeTransactionsRequest{TransactionalIDs: ids})
+		if err != nil {
+			return nil, err
+		}
+		for _, state := range response.TransactionStates {
+			if errors.Is(state.ErrorCode, ErrNotCoordinatorForConsumer) {
+				// the coordinator moved, look it up again on the next call
+				_ = ca.client.RefreshTransactionCoordinator(state.TransactionalID)
+			}
+			states[state.TransactionalID] = state
+		}
+	}
+	return states, nil
+}
+
+func (ca *clusterAdmin) ListTransactions(stateFilters []string, producerIDFilters []int64) ([]*TransactionListing, error) {
+	// Query brokers in parallel, since we have to query *all* brokers
+	brokers := ca.brokers()
+	listings := make(chan []*TransactionListing, len(brokers))
+	errChan := make(chan error, len(brokers))
+	wg := sync.WaitGroup{}
+
+	for _, b := range brokers {
+		wg.Add(1)
+		go func(b *Broker) {
+			defer wg.Done()
+
+			request := &ListTransactionsRequest{
+				StateFilters:      stateFilters,
+				ProducerIDFilters: producerIDFilters,
+			}
+			// retry the broker while its transaction coordinator is loading
+			err := ca.retryOnError(isRetriableCoordinatorLoadError, func() error {
+				_ = b.Open(ca.conf) // Ensure that broker is opened
+
+				response, err := b.ListTransactions(request)
+				if err != nil {
+					return err
+				}
+				if !errors.Is(response.ErrorCode, ErrNoError) {
+					return response.ErrorCode
+				}
+				if len(response.UnknownStateFilters) > 0 {
+					Logger.Printf("admin/transactions broker %d does not know the state filters %v\n", b.ID(), response.UnknownStateFilters)
+				}
+				listings <- response.TransactionStates
+				return nil
+			})
+			if err != nil {
+				errChan <- err
+			}
+		}(b)
+	}
+
+	wg.Wait()
+	close(listings)
+	close(errChan)
+
+	// a transactional ID may be reported by several brokers while its
+	// coordinator moves
+	var result []*TransactionListing
+	seen := make(map[string]struct{})
+	for brokerListings := range listings {
+		for _, listing := range brokerListings {
+			if _, ok := seen[listing.TransactionalID]; ok {
+				continue
+			}
+			seen[listing.TransactionalID] = struct{}{}
+			result = append(result, listing)
+		}
+	}
+
+	// Intentionally return only the first error for simplicity
+	if err := <-errChan; err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
 // Returns a bool indicating whether the resource request needs to go to a
@@ -801,59 +1349,107 @@ func dependsOnSpecificNode(resource ConfigResource) bool {
 }
 
 func (ca *clusterAdmin) DescribeConfig(resource ConfigResource) ([]ConfigEntry, error) {
-	var entries []ConfigEntry
-	var resources []*ConfigResource
-	resources = append(resources, &resource)
-
-	request := &DescribeConfigsRequest{
-		Resources: resources,
+	results, err := ca.DescribeConfigs([]ConfigResource{resource})
+	if err != nil {
+		return nil, err
 	}
-
-	if ca.conf.Version.IsAtLeast(V1_1_0_0) {
-		request.Version = 1
+	result, ok := results[ConfigResourceKey{Type: resource.Type, Name: resource.Name}]
+	if !ok {
+		return nil, nil
 	}
-
-	if ca.conf.Version.IsAtLeast(V2_0_0_0) {
-		request.Version = 2
+	if result.Err != nil {
+		return nil, result.Err
 	}
+	return result.Entries, nil
+}
 
-	var (
-		b   *Broker
-		err error
-	)
+// ConfigResourceKey identifies a resource in the results of DescribeConfigs.
+type ConfigResourceKey struct {
+	Type ConfigResourceType
+	Name string
+}
 
-	// DescribeConfig of broker/broker logger must be sent to the broker in question
-	if dependsOnSpecificNode(resource) {
-		var id int64
-		id, err = strconv.ParseInt(resource.Name, 10, 32)
+// DescribeConfigsResult is the outcome of DescribeConfigs for a single resource.
+type DescribeConfigsResult struct {
+	Entries []ConfigEntry
+	// Err is a *DescribeConfigError if the resource could not be described.
+	Err error
+}
+
+func (ca *clusterAdmin) DescribeConfigs(resources []ConfigResource) (map[ConfigResourceKey]*DescribeConfigsResult, error) {
+	// broker and broker logger resources must be described by the broker in
+	// question, the others by any broker so they join the first batch
+	const anyBroker = -1
+	var nodes []int32
+	batches := make(map[int32][]*ConfigResource)
+	var others []*ConfigResource
+	for i := range resources {
+		resource := &resources[i]
+		if !dependsOnSpecificNode(*resource) {
+			others = append(others, resource)
+			continue
+		}
+		id, err := strconv.ParseInt(resource.Name, 10, 32)
 		if err != nil {
 			return nil, err
 		}
-		b, err = ca.findBroker(int32(id))
-	} else {
-		b, err = ca.findAnyBroker()
+		node := int32(id)
+		if _, ok := batches[node]; !ok {
+			nodes = append(nodes, node)
+		}
+		batches[node] = append(batches[node], resource)
 	}
-	if err != nil {
-		return nil, err
+	if len(others) > 0 {
+		if len(nodes) == 0 {
+			nodes = append(nodes, anyBroker)
+		}
+		batches[nodes[0]] = append(batches[nodes[0]], others...)
 	}
 
-	_ = b.Open(ca.client.Config())
-	rsp, err := b.DescribeConfigs(request)
-	if err != nil {
-		return nil, err
-	}
+	var version int16
+	if ca.conf.Version.IsAtLeast(V2_0_0_0) {
+		version = 2
+	} else if ca.conf.Version.IsAtLeast(V1_1_0_0) {
+		version = 1
+	}
+
+	results := make(map[ConfigResourceKey]*DescribeConfigsResult, len(resources))
+	for _, node := range nodes {
+		var (
+			b   *Broker
+			err error
+		)
+		if node == anyBroker {
+			b, err = ca.findAnyBroker()
+		} else {
+			b, err = ca.findBroker(node)
+		}
+		if err != nil {
+			return nil, err
+		}
 
-	for _, rspResource := range rsp.Resources {
-		if rspResource.Name == resource.Name {
+		_ = b.Open(ca.client.Config())
+		rsp, err := b.DescribeConfigs(&DescribeConfigsRequest{
+			Version:   version,
+			Resources: batches[node],
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, rspResource := range rsp.Resources {
+			result := &DescribeConfigsResult{}
 			if rspResource.ErrorCode != 0 {
-				return nil, &DescribeConfigError{Err: KError(rspResource.ErrorCode), ErrMsg: rspResource.ErrorMsg}
-			}
-			for _, cfgEntry := range rspResource.Configs {
-				entries = append(entries, *cfgEntry)
+				result.Err = &DescribeConfigError{Err: KError(rspResource.ErrorCode), ErrMsg: rspResource.ErrorMsg}
+			} else {
+				for _, cfgEntry := range rspResource.Configs {
+					result.Entries = append(result.Entries, *cfgEntry)
+				}
 			}
+			results[ConfigResourceKey{Type: rspResource.Type, Name: rspResource.Name}] = result
 		}
 	}
-	return entries, nil
+	return results, nil
 }
 
 func (ca *clusterAdmin) AlterConfig(resourceType ConfigResourceType, name string, entries map[string]*string, validateOnly bool) error {
@@ -925,27 +1521,35 @@ func (ca *clusterAdmin) IncrementalAlterConfig(resourceType ConfigResourceType,
 		request.Version = 1
 	}
 
-	var (
-		b   *Broker
-		err error
-	)
-
-	// AlterConfig of broker/broker logger must be sent to the broker in question
+	// IncrementalAlterConfig of broker/broker logger must be sent to the broker in question
 	if dependsOnSpecificNode(ConfigResource{Name: name, Type: resourceType}) {
-		var id int64
-		id, err = strconv.ParseInt(name, 10, 32)
+		id, err := strconv.ParseInt(name, 10, 32)
 		if err != nil {
 			return err
 		}
-		b, err = ca.findBroker(int32(id))
-	} else {
-		b, err = ca.findAnyBroker()
+		b, err := ca.findBroker(int32(id))
+		if err != nil {
+			return err
+		}
+		_ = b.Open(ca.client.Config())
+		return incrementalAlterConfig(b, request, name)
 	}
-	if err != nil {
+
+	return ca.retryOnError(isRetriableControllerError, func() error {
+		b, err := ca.controller()
+		if err != nil {
+			return err
+		}
+
+		err = incrementalAlterConfig(b, request, name)
+		if isRetriableControllerError(err) {
+			_, _ = ca.refreshController()
+		}
 		return err
-	}
+	})
+}
 
-	_ = b.Open(ca.client.Config())
+func incrementalAlterConfig(b *Broker, request *IncrementalAlterConfigsRequest, name string) error {
 	rsp, err := b.IncrementalAlterConfigs(request)
 	if err != nil {
 		return err
@@ -976,13 +1580,7 @@ func (ca *clusterAdmin) CreateACL(resource Resource, acl Acl) error {
 		request.Version = 1
 	}
 
-	b, err := ca.Controller()
-	if err != nil {
-		return err
-	}
-
-	_, err = b.CreateAcls(request)
-	return err
+	return ca.createAcls(request)
 }
 
 func (ca *clusterAdmin) CreateACLs(resourceACLs []*ResourceAcls) error {
@@ -998,41 +1596,98 @@ func (ca *clusterAdmin) CreateACLs(resourceACLs []*ResourceAcls) error {
 		request.Version = 1
 	}
 
-	b, err := ca.Controller()
-	if err != nil {
-		return err
-	}
+	return ca.createAcls(request)
+}
 
-	_, err = b.CreateAcls(request)
-	return err
+func (ca *clusterAdmin) createAcls(request *CreateAclsRequest) error {
+	return ca.retryOnController(func(b *Broker) error {
+		rsp, err := b.CreateAcls(request)
+		if err != nil {
+			return err
+		}
+		for _, res := range rsp.AclCreationResponses {
+			if errors.Is(res.Err, ErrNotController) {
+				return res.Err
+			}
+		}
+		return nil
+	})
 }
 
 func (ca *clusterAdmin) ListAcls(filter AclFilter) ([]ResourceAcls, error) {
-	request := &DescribeAclsRequest{AclFilter: filter}
+	rsp, err := ca.describeAcls(filter)
+	if err != nil {
+		return nil, err
+	}
 
-	if ca.conf.Version.IsAtLeast(V2_5_0_0) {
-		request.Version = 2
-	} else if ca.conf.Version.IsAtLeast(V2_0_0_0) {
-		request.Version = 1
+	var lAcls []ResourceAcls
+	for _, rAcl := range rsp.ResourceAcls {
+		lAcls = append(lAcls, *rAcl)
 	}
+	return lAcls, nil
+}
 
-	b, err := ca.Controller()
-	if err != nil {
-		return nil, err
+func (ca *clusterAdmin) ListAclsFiltered(filter AclFilter) ([]ResourceAcls, error) {
+	if filter.ResourcePatternTypeFilter == AclPatternUnknown {
+		filter.ResourcePatternTypeFilter = AclPatternAny
+	}
+
+	// before v1 brokers only know literal ACLs and match names exactly
+	legacy := !ca.conf.Version.IsAtLeast(V2_0_0_0)
+	var matchName *string
+	if legacy {
+		switch filter.ResourcePatternTypeFilter {
+		case AclPatternPrefixed:
+			return nil, ConfigurationError("prefixed ACLs require Version >= V2_0_0_0")
+		case AclPatternMatch:
+			// list the ACLs of every resource of the type, then keep the
+			// wildcard ones along with those of the resource
+			matchName, filter.ResourceName = filter.ResourceName, nil
+		}
 	}
 
-	rsp, err := b.DescribeAcls(request)
+	rsp, err := ca.describeAcls(filter)
 	if err != nil {
 		return nil, err
 	}
+	if !errors.Is(rsp.Err, ErrNoError) {
+		return nil, rsp.Err
+	}
 
 	var lAcls []ResourceAcls
 	for _, rAcl := range rsp.ResourceAcls {
+		if legacy {
+			rAcl.ResourcePatternType = AclPatternLiteral
+			if matchName != nil && rAcl.ResourceName != *matchName && rAcl.ResourceName != "*" {
+				continue
+			}
+		}
 		lAcls = append(lAcls, *rAcl)
 	}
 	return lAcls, nil
 }
 
+// describeAcls sends a DescribeAclsRequest for filter to the controller.
+func (ca *clusterAdmin) describeAcls(filter AclFilter) (*DescribeAclsResponse, error) {
+	request := &DescribeAclsRequest{AclFilter: filter}
+
+	if ca.conf.Version.IsAtLeast(V2_5_0_0) {
+		request.Version = 2
+	} else if ca.conf.Version.IsAtLeast(V2_0_0_0) {
+		request.Version = 1
+	}
+
+	var rsp *DescribeAclsResponse
+	err := ca.retryOnController(func(b *Broker) (err error) {
+		rsp, err = b.DescribeAcls(request)
+		if err == nil && errors.Is(rsp.Err, ErrNotController) {
+			err = rsp.Err
+		}
+		return err
+	})
+	return rsp, err
+}
+
 func (ca *clusterAdmin) DeleteACL(filter AclFilter, validateOnly bool) ([]MatchingAcl, error) {
 	var filters []*AclFilter
 	filters = append(filters, &filter)
@@ -1044,12 +1699,18 @@ func (ca *clusterAdmin) DeleteACL(filter AclFilter, validateOnly bool) ([]Matchi
 		request.Version = 1
 	}
 
-	b, err := ca.Controller()
-	if err != nil {
-		return nil, err
-	}
-
-	rsp, err := b.DeleteAcls(request)
+	var rsp *DeleteAclsResponse
+	err := ca.retryOnController(func(b *Broker) (err error) {
+		if rsp, err = b.DeleteAcls(request); err != nil {
+			return err
+		}
+		for _, fr := range rsp.FilterResponses {
+			if errors.Is(fr.Err, ErrNotController) {
+				return fr.Err
+			}
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -1074,11 +1735,13 @@ func (ca *clusterAdmin) ElectLeaders(electionType ElectionType, partitions map[s
 		request.Version = 2
 	} else if ca.conf.Version.IsAtLeast(V0_11_0_0) {
 		request.Version = 1
+	} else if electionType != PreferredElection {
+		return nil, ConfigurationError("unclean leader election requires Version >= 0.11.0.0")
 	}
 
 	var res *ElectLeadersResponse
 	if err := ca.retryOnError(isRetriableControllerError, func() error {
-		b, err := ca.Controller()
+		b, err := ca.controller()
 		if err != nil {
 			return err
 		}
@@ -1102,17 +1765,21 @@ func (ca *clusterAdmin) ElectLeaders(electionType ElectionType, partitions map[s
 }
 
 func (ca *clusterAdmin) DescribeConsumerGroups(groups []string) (result []*GroupDescription, err error) {
-	groupsPerBroker := make(map[*Broker][]string)
+	// batch the groups per coordinator, the pooled connection is picked per batch
+	coordinators := make(map[int32]*Broker)
+	groupsPerBroker := make(map[int32][]string)
 
 	for _, group := range groups {
 		coordinator, err := ca.client.Coordinator(group)
 		if err != nil {
 			return nil, err
 		}
-		groupsPerBroker[coordinator] = append(groupsPerBroker[coordinator], group)
+		coordinators[coordinator.ID()] = coordinator
+		groupsPerBroker[coordinator.ID()] = append(groupsPerBroker[coordinator.ID()], group)
 	}
 
-	for broker, brokerGroups := range groupsPerBroker {
+	for id, brokerGroups := range groupsPerBroker {
+		broker := ca.pooled(coordinators[id])
 		describeReq := &DescribeGroupsRequest{
 			Groups: brokerGroups,
 		}
@@ -1145,7 +1812,7 @@ func (ca *clusterAdmin) ListConsumerGroups() (allGroups map[string]string, err e
 	allGroups = make(map[string]string)
 
 	// Query brokers in parallel, since we have to query *all* brokers
-	brokers := ca.client.Brokers()
+	brokers := ca.brokers()
 	groupMaps := make(chan map[string]string, len(brokers))
 	errChan := make(chan error, len(brokers))
 	wg := sync.WaitGroup{}
@@ -1207,7 +1874,7 @@ func (ca *clusterAdmin) ListConsumerGroupOffsets(group string, topicPartitions m
 			}
 		}()
 
-		coordinator, err := ca.client.Coordinator(group)
+		coordinator, err := ca.coordinator(group)
 		if err != nil {
 			return err
 		}
@@ -1261,17 +1928,22 @@ func (ca *clusterAdmin) ListConsumerGroupOffsetsBatch(groupTopics map[string]map
 
 		clear(result)
 		for _, batch := range batches {
+			// the pooled connection is picked once per batch
+			batch.broker = ca.pooled(batch.broker)
 			req := NewOffsetFetchRequest(ca.conf.Version, "", nil)
 			req.Groups = batch.groups
-			if _, ok := batch.broker.negotiateApiVersion(req, 8); !ok {
-				return ErrUnsupportedVersion
-			}
-			resp, err := batch.broker.FetchOffset(req)
-			if err != nil {
+			var groups []OffsetFetchResponseGroup
+			if _, ok := batch.broker.negotiateApiVersion(req, 8); ok {
+				resp, err := batch.broker.FetchOffset(req)
+				if err != nil {
+					return err
+				}
+				groups = resp.Groups
+			} else if groups, err = ca.fetchGroupOffsets(batch.broker, batch.groups); err != nil {
 				return err
 			}
-			for i := range resp.Groups {
-				g := &resp.Groups[i]
+			for i := range groups {
+				g := &groups[i]
 				// retriable per-group error re-batches every group on retry; non-retriable
 				// errors are left on g.Err for the caller
 				if g.Err != ErrNoError && isRetriableGroupCoordinatorError(g.Err) {
@@ -1288,6 +1960,25 @@ func (ca *clusterAdmin) ListConsumerGroupOffsetsBatch(groupTopics map[string]map
 	return result, nil
 }
 
+// fetchGroupOffsets fetches the offsets of the groups with one OffsetFetch
+// request per group, for coordinators that do not support fetching several
+// groups at once.
+func (ca *clusterAdmin) fetchGroupOffsets(coordinator *Broker, groups []OffsetFetchRequestGroup) ([]OffsetFetchResponseGroup, error) {
+	result := make([]OffsetFetchResponseGroup, 0, len(groups))
+	for _, group := range groups {
+		resp, err := coordinator.FetchOffset(NewOffsetFetchRequest(ca.conf.Version, group.GroupId, group.Partitions))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, OffsetFetchResponseGroup{
+			GroupId: group.GroupId,
+			Blocks:  resp.Blocks,
+			Err:     resp.GroupError(),
+		})
+	}
+	return result, nil
+}
+
 func (ca *clusterAdmin) DeleteConsumerGroupOffset(group string, topic string, partition int32) error {
 	var response *DeleteOffsetsResponse
 	request := &DeleteOffsetsRequest{
@@ -1304,7 +1995,7 @@ func (ca *clusterAdmin) DeleteConsumerGroupOffset(group string, topic string, pa
 			}
 		}()
 
-		coordinator, err := ca.client.Coordinator(group)
+		coordinator, err := ca.coordinator(group)
 		if err != nil {
 			return err
 		}
@@ -1316,8 +2007,12 @@ func (ca *clusterAdmin) DeleteConsumerGroupOffset(group string, topic string, pa
 		if !errors.Is(response.ErrorCode, ErrNoError) {
 			return response.ErrorCode
 		}
-		if !errors.Is(response.Errors[topic][partition], ErrNoError) {
-			return response.Errors[topic][partition]
+		kerr, ok := response.Errors[topic][partition]
+		if !ok {
+			return ErrIncompleteResponse
+		}
+		if !errors.Is(kerr, ErrNoError) {
+			return kerr
 		}
 
 		return nil
@@ -1343,7 +2038,7 @@ func (ca *clusterAdmin) DeleteConsumerGroup(group string) error {
 			}
 		}()
 
-		coordinator, err := ca.client.Coordinator(group)
+		coordinator, err := ca.coordinator(group)
 		if err != nil {
 			return err
 		}
@@ -1371,17 +2066,26 @@ func (ca *clusterAdmin) DescribeLogDirs(brokerIds []int32) (allLogDirs map[int32
 		id      int32
 		logdirs []DescribeLogDirsResponseDirMetadata
 	}
+	var brokers []*Broker
+	if len(brokerIds) == 0 {
+		brokers = ca.brokers()
+	} else {
+		for _, b := range brokerIds {
+			broker, err := ca.findBroker(b)
+			if err != nil {
+				Logger.Printf("Unable to find broker with ID = %v\n", b)
+				continue
+			}
+			brokers = append(brokers, broker)
+		}
+	}
+
 	// Query brokers in parallel, since we may have to query multiple brokers
-	logDirsResults := make(chan result, len(brokerIds))
-	errChan := make(chan error, len(brokerIds))
+	logDirsResults := make(chan result, len(brokers))
+	errChan := make(chan error, len(brokers))
 	wg := sync.WaitGroup{}
 
-	for _, b := range brokerIds {
-		broker, err := ca.findBroker(b)
-		if err != nil {
-			Logger.Printf("Unable to find broker with ID = %v\n", b)
-			continue
-		}
+	for _, broker := range brokers {
 		wg.Add(1)
 		go func(b *Broker, conf *Config) {
 			defer wg.Done()
@@ -1414,7 +2118,7 @@ func (ca *clusterAdmin) DescribeLogDirs(brokerIds []int32) (allLogDirs map[int32
 	close(logDirsResults)
 	close(errChan)
 
-	allLogDirs = make(map[int32][]DescribeLogDirsResponseDirMetadata, len(brokerIds))
+	allLogDirs = make(map[int32][]DescribeLogDirsResponseDirMetadata, len(brokers))
 	for logDirsResult := range logDirsResults {
 		allLogDirs[logDirsResult.id] = logDirsResult.logdirs
 	}
@@ -1432,12 +2136,14 @@ func (ca *clusterAdmin) DescribeUserScramCredentials(users []string) ([]*Describ
 		})
 	}
 
-	b, err := ca.Controller()
-	if err != nil {
-		return nil, err
-	}
-
-	rsp, err := b.DescribeUserScramCredentials(req)
+	var rsp *DescribeUserScramCredentialsResponse
+	err := ca.retryOnController(func(b *Broker) (err error) {
+		rsp, err = b.DescribeUserScramCredentials(req)
+		if err == nil && errors.Is(rsp.ErrorCode, ErrNotController) {
+			err = rsp.ErrorCode
+		}
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -1470,14 +2176,16 @@ func (ca *clusterAdmin) AlterUserScramCredentials(u []AlterUserScramCredentialsU
 	}
 
 	var rsp *AlterUserScramCredentialsResponse
-	err := ca.retryOnError(isRetriableControllerError, func() error {
-		b, err := ca.Controller()
-		if err != nil {
+	err := ca.retryOnController(func(b *Broker) (err error) {
+		if rsp, err = b.AlterUserScramCredentials(req); err != nil {
 			return err
 		}
-
-		rsp, err = b.AlterUserScramCredentials(req)
-		return err
+		for _, res := range rsp.Results {
+			if errors.Is(res.ErrorCode, ErrNotController) {
+				return res.ErrorCode
+			}
+		}
+		return nil
 	})
 	if err != nil {
 		return nil, err
@@ -1496,12 +2204,14 @@ func (ca *clusterAdmin) DescribeClientQuotas(components []QuotaFilterComponent,
 		strict,
 	)
 
-	b, err := ca.Controller()
-	if err != nil {
-		return nil, err
-	}
-
-	rsp, err := b.DescribeClientQuotas(request)
+	var rsp *DescribeClientQuotasResponse
+	err := ca.retryOnController(func(b *Broker) (err error) {
+		rsp, err = b.DescribeClientQuotas(request)
+		if err == nil && errors.Is(rsp.ErrorCode, ErrNotController) {
+			err = rsp.ErrorCode
+		}
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -1522,17 +2232,24 @@ func (ca *clusterAdmin) AlterClientQuotas(entity []QuotaEntityComponent, op Clie
 		Ops:    []ClientQuotasOp{op},
 	}
 
-	request := &AlterClientQuotasRequest{
-		Entries:      []AlterClientQuotasEntry{entry},
-		ValidateOnly: validateOnly,
-	}
-
-	b, err := ca.Controller()
-	if err != nil {
-		return err
-	}
+	request := NewAlterClientQuotasRequest(
+		ca.conf.Version,
+		[]AlterClientQuotasEntry{entry},
+		validateOnly,
+	)
 
-	rsp, err := b.AlterClientQuotas(request)
+	var rsp *AlterClientQuotasResponse
+	err := ca.retryOnController(func(b *Broker) (err error) {
+		if rsp, err = b.AlterClientQuotas(request); err != nil {
+			return err
+		}
+		for _, entry := range rsp.Entries {
+			if errors.Is(entry.ErrorCode, ErrNotController) {
+				return entry.ErrorCode
+			}
+		}
+		return nil
+	})
 	if err != nil {
 		return err
 	}
@@ -1571,7 +2288,7 @@ func (ca *clusterAdmin) RemoveMemberFromConsumerGroup(group string, groupInstanc
 			}
 		}()
 
-		coordinator, err := ca.client.Coordinator(group)
+		coordinator, err := ca.coordinator(group)
 		if err != nil {
 			return err
 		}
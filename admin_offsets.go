@@ -186,7 +186,7 @@ func (ca *clusterAdmin) AlterConsumerGroupOffsets(group string, offsets map[stri
 			}
 		}()
 
-		coordinator, err := ca.client.Coordinator(group)
+		coordinator, err := ca.coordinator(group)
 		if err != nil {
 			return err
 		}
@@ -147,6 +147,177 @@ func TestClusterAdminCreateTopicWithoutAuthorization(t *testing.T) {
 	}
 }
 
+func TestClusterAdminCreateTopics(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"CreateTopicsRequest": NewMockCreateTopicsResponse(t).
+			SetError("existing_topic", ErrTopicAlreadyExists),
+	})
+
+	config := NewTestConfig()
+	config.Version = V0_11_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	detail := &TopicDetail{NumPartitions: 1, ReplicationFactor: 1}
+	err = admin.CreateTopics(map[string]*TopicDetail{"my_topic": detail, "existing_topic": detail}, false)
+	require.ErrorIs(t, err, ErrTopicAlreadyExists)
+	var creationErrs TopicCreationErrors
+	require.ErrorAs(t, err, &creationErrs)
+	require.Len(t, creationErrs, 1)
+	require.NoError(t, creationErrs.WithoutAlreadyExists())
+
+	err = admin.CreateTopics(map[string]*TopicDetail{"_internal_topic": detail, "existing_topic": detail}, false)
+	require.ErrorAs(t, err, &creationErrs)
+	require.Len(t, creationErrs, 2)
+	require.Equal(t, "kafka: failed to create 2 topics: "+
+		"_internal_topic: kafka server: The client is not authorized to access this topic - insufficient permissions to create topic with reserved prefix; "+
+		"existing_topic: kafka server: Topic with this name already exists", err.Error())
+	err = creationErrs.WithoutAlreadyExists()
+	require.ErrorIs(t, err, ErrTopicAuthorizationFailed)
+	require.NotErrorIs(t, err, ErrTopicAlreadyExists)
+
+	require.NoError(t, admin.CreateTopics(map[string]*TopicDetail{"my_topic": detail}, false))
+}
+
+func TestClusterAdminConnectionPool(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetLeader("my_topic", 0, seedBroker.BrokerID()),
+		"DescribeConfigsRequest": NewMockDescribeConfigsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_1_0_0
+	config.Admin.ConnectionPoolSize = 3
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := admin.(*clusterAdmin)
+
+	controller, err := ca.client.Controller()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conns []*Broker
+	for i := 0; i < 4; i++ {
+		conn, err := ca.controller()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if conn.ID() != controller.ID() {
+			t.Errorf("pooled connection %d has broker ID %d, expected %d", i, conn.ID(), controller.ID())
+		}
+		conns = append(conns, conn)
+	}
+	if conns[0] != controller {
+		t.Error("the connection of the client should be part of the pool")
+	}
+	if conns[1] == conns[0] || conns[2] == conns[0] || conns[2] == conns[1] {
+		t.Error("requests should be spread over the connections of the pool")
+	}
+	if conns[3] != conns[0] {
+		t.Error("the connections of the pool should be used in turn")
+	}
+
+	// each connection tracks its own correlation IDs
+	for i := 0; i < 6; i++ {
+		if _, err := admin.ListTopics(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := admin.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, conn := range conns[1:3] {
+		if connected, _ := conn.Connected(); connected {
+			t.Error("the extra connections should be closed with the admin")
+		}
+	}
+}
+
+func TestClusterAdminConnectionPoolBatches(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	broker2 := NewMockBroker(t, 2)
+	defer broker2.Close()
+
+	metadata := func(brokers ...*MockBroker) *MockMetadataResponse {
+		res := NewMockMetadataResponse(t).SetController(seedBroker.BrokerID())
+		for _, broker := range brokers {
+			res.SetBroker(broker.Addr(), broker.BrokerID())
+		}
+		return res
+	}
+	// the client may send its requests to either broker
+	handlers := map[string]MockResponse{
+		"MetadataRequest": metadata(seedBroker, broker2),
+		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).
+			SetCoordinator(CoordinatorGroup, "group-1", broker2).
+			SetCoordinator(CoordinatorGroup, "group-2", broker2),
+		"DescribeGroupsRequest": NewMockDescribeGroupsResponse(t).
+			AddGroupDescription("group-1", &GroupDescription{GroupId: "group-1"}).
+			AddGroupDescription("group-2", &GroupDescription{GroupId: "group-2"}),
+	}
+	seedBroker.SetHandlerByMap(handlers)
+	broker2.SetHandlerByMap(handlers)
+
+	config := NewTestConfig()
+	config.Version = V1_1_0_0
+	config.Admin.ConnectionPoolSize = 3
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	require.NoError(t, err)
+	defer safeClose(t, admin)
+	ca := admin.(*clusterAdmin)
+
+	// the groups of the same coordinator are described with a single request
+	groups, err := admin.DescribeConsumerGroups([]string{"group-1", "group-2"})
+	require.NoError(t, err)
+	require.Len(t, groups, 2)
+	requests := 0
+	for _, rr := range broker2.History() {
+		if _, ok := rr.Request.(*DescribeGroupsRequest); ok {
+			requests++
+		}
+	}
+	require.Equal(t, 1, requests)
+
+	// the pool of a broker leaving the cluster is closed
+	ca.poolLock.Lock()
+	pool := ca.pools[broker2.BrokerID()]
+	ca.poolLock.Unlock()
+	require.NotNil(t, pool)
+	handlers["MetadataRequest"] = metadata(seedBroker)
+	seedBroker.SetHandlerByMap(handlers)
+	broker2.SetHandlerByMap(handlers)
+	require.NoError(t, ca.client.RefreshMetadata())
+	_, err = ca.controller()
+	require.NoError(t, err)
+	ca.poolLock.Lock()
+	_, ok := ca.pools[broker2.BrokerID()]
+	ca.poolLock.Unlock()
+	require.False(t, ok, "expected the pool of the departed broker to be dropped")
+	for _, conn := range pool.conns[1:] {
+		connected, _ := conn.Connected()
+		require.False(t, connected, "expected the extra connections of the departed broker to be closed")
+	}
+}
+
 func TestClusterAdminListTopics(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
@@ -558,6 +729,41 @@ func TestClusterAdminAlterPartitionReassignments(t *testing.T) {
 	}
 }
 
+func TestClusterAdminAlterPartitionReassignmentsByPartition(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"AlterPartitionReassignmentsRequest": NewMockAlterPartitionReassignmentsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_4_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	require.NoError(t, err)
+	defer safeClose(t, admin)
+
+	err = admin.AlterPartitionReassignmentsByPartition("my_topic", map[int32][]int32{
+		2: {1, 2, 3},
+		5: nil, // cancel
+	})
+	require.NoError(t, err)
+
+	var request *AlterPartitionReassignmentsRequest
+	for _, rr := range seedBroker.History() {
+		if req, ok := rr.Request.(*AlterPartitionReassignmentsRequest); ok {
+			request = req
+		}
+	}
+	require.NotNil(t, request)
+	require.Len(t, request.blocks["my_topic"], 2, "expected only the given partitions to be reassigned")
+	require.Equal(t, []int32{1, 2, 3}, request.blocks["my_topic"][2].replicas)
+	require.Nil(t, request.blocks["my_topic"][5].replicas, "expected null replicas to cancel the reassignment")
+}
+
 func TestClusterAdminAlterPartitionReassignmentsWithDiffVersion(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
@@ -644,6 +850,40 @@ func TestClusterAdminListPartitionReassignments(t *testing.T) {
 	}
 }
 
+func TestClusterAdminListPartitionReassignmentsByTopic(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"ListPartitionReassignmentsRequest": NewMockListPartitionReassignmentsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_4_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	require.NoError(t, err)
+	defer safeClose(t, admin)
+
+	response, err := admin.ListPartitionReassignmentsByTopic(map[string][]int32{
+		"topic1": {0},
+		"topic2": {1, 2},
+	})
+	require.NoError(t, err)
+	require.Len(t, response, 2)
+	require.Len(t, response["topic1"], 1)
+	require.Len(t, response["topic2"], 2)
+	status := response["topic2"][2]
+	require.Equal(t, []int32{0}, status.Replicas)
+	require.Equal(t, []int32{1}, status.AddingReplicas)
+	require.Equal(t, []int32{2}, status.RemovingReplicas)
+
+	_, err = admin.ListPartitionReassignmentsByTopic(map[string][]int32{"": {0}})
+	require.ErrorIs(t, err, ErrInvalidTopic)
+}
+
 func TestClusterAdminListPartitionReassignmentsWithDiffVersion(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
@@ -728,6 +968,221 @@ func TestClusterAdminDeleteRecords(t *testing.T) {
 	}
 }
 
+func TestClusterAdminDeleteRecordsWithResults(t *testing.T) {
+	topicName := "my_topic"
+	seedBroker := NewMockBroker(t, 1)
+	secondBroker := NewMockBroker(t, 2)
+	defer seedBroker.Close()
+	defer secondBroker.Close()
+
+	metadata := NewMockMetadataResponse(t).
+		SetController(seedBroker.BrokerID()).
+		SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+		SetBroker(secondBroker.Addr(), secondBroker.BrokerID()).
+		SetLeader(topicName, 1, 1).
+		SetLeader(topicName, 2, 2).
+		SetLeader(topicName, 3, 2)
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest":      metadata,
+		"DeleteRecordsRequest": NewMockDeleteRecordsResponse(t),
+	})
+	secondBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": metadata,
+		"DeleteRecordsRequest": NewMockDeleteRecordsResponse(t).
+			SetError(topicName, 3, ErrOffsetOutOfRange),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	require.NoError(t, err)
+	defer safeClose(t, admin)
+
+	partitionOffset := map[int32]int64{1: 100, 2: 200, 3: 300}
+	results, err := admin.DeleteRecordsWithResults(topicName, partitionOffset)
+	require.NoError(t, err)
+	require.Equal(t, map[int32]*DeleteRecordsResult{
+		1: {LowWatermark: 100},
+		2: {LowWatermark: 200},
+		3: {LowWatermark: -1, Err: ErrOffsetOutOfRange},
+	}, results)
+
+	// each leader only receives its own partitions
+	for _, broker := range []*MockBroker{seedBroker, secondBroker} {
+		for _, rr := range broker.History() {
+			if req, ok := rr.Request.(*DeleteRecordsRequest); ok {
+				for partition := range req.Topics[topicName].PartitionOffsets {
+					leader, err := admin.(*clusterAdmin).client.Leader(topicName, partition)
+					require.NoError(t, err)
+					require.Equal(t, broker.BrokerID(), leader.ID())
+				}
+			}
+		}
+	}
+
+	err = admin.DeleteRecords(topicName, partitionOffset)
+	require.ErrorIs(t, err, ErrDeleteRecords)
+	require.ErrorIs(t, err, ErrOffsetOutOfRange)
+
+	_, err = admin.DeleteRecordsWithResults("", partitionOffset)
+	require.ErrorIs(t, err, ErrInvalidTopic)
+}
+
+func TestClusterAdminDescribeProducers(t *testing.T) {
+	topicName := "my_topic"
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	producer := &ProducerState{
+		ProducerID:            1000,
+		ProducerEpoch:         1,
+		LastSequence:          10,
+		LastTimestamp:         1234,
+		CoordinatorEpoch:      2,
+		CurrentTxnStartOffset: 50,
+	}
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetLeader(topicName, 0, seedBroker.BrokerID()).
+			SetLeader(topicName, 1, seedBroker.BrokerID()),
+		"DescribeProducersRequest": NewMockDescribeProducersResponse(t).
+			SetProducers(topicName, 0, producer),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_8_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	producers, err := admin.DescribeProducers(topicName, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(producers) != 1 || *producers[0] != *producer {
+		t.Fatalf("unexpected producers: %v", producers)
+	}
+
+	_, err = admin.DescribeProducers(topicName, 1)
+	if !errors.Is(err, ErrUnknownTopicOrPartition) {
+		t.Fatalf("expected ErrUnknownTopicOrPartition, got %v", err)
+	}
+}
+
+func TestClusterAdminDescribeTransactions(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	coordinator := NewMockBroker(t, 2)
+	defer coordinator.Close()
+
+	state := &TransactionState{
+		ErrorCode:              ErrNoError,
+		TransactionalID:        "tx1",
+		State:                  TransactionStateOngoing,
+		TransactionTimeoutMs:   60000,
+		TransactionStartTimeMs: 1000,
+		ProducerID:             7,
+		ProducerEpoch:          2,
+		Topics:                 map[string][]int32{"my_topic": {0, 1}},
+	}
+	findCoordinatorResponse := NewMockFindCoordinatorResponse(t).
+		SetCoordinator(CoordinatorTransaction, "tx1", coordinator).
+		SetCoordinator(CoordinatorTransaction, "tx2", coordinator)
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetBroker(coordinator.Addr(), coordinator.BrokerID()),
+		"FindCoordinatorRequest": findCoordinatorResponse,
+	})
+	coordinator.SetHandlerByMap(map[string]MockResponse{
+		"FindCoordinatorRequest": findCoordinatorResponse,
+		"DescribeTransactionsRequest": NewMockDescribeTransactionsResponse(t).
+			SetTransactionState(state),
+	})
+
+	config := NewTestConfig()
+	config.Version = V3_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	states, err := admin.DescribeTransactions([]string{"tx1", "tx2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 2 {
+		t.Fatalf("expected 2 transaction states, got %v", states)
+	}
+	assert.Equal(t, state, states["tx1"])
+	if !errors.Is(states["tx2"].ErrorCode, ErrTransactionalIDNotFound) {
+		t.Errorf("expected ErrTransactionalIDNotFound, got %v", states["tx2"].ErrorCode)
+	}
+
+	var requests int
+	for _, rr := range coordinator.History() {
+		if _, ok := rr.Request.(*DescribeTransactionsRequest); ok {
+			requests++
+		}
+	}
+	if requests != 1 {
+		t.Errorf("expected the transactional IDs to be batched in 1 request, got %d", requests)
+	}
+}
+
+func TestClusterAdminListTransactions(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	secondBroker := NewMockBroker(t, 2)
+	defer secondBroker.Close()
+
+	tx1 := &TransactionListing{TransactionalID: "tx1", ProducerID: 7, State: TransactionStateOngoing}
+	tx2 := &TransactionListing{TransactionalID: "tx2", ProducerID: 8, State: TransactionStateCompleteCommit}
+	metadataResponse := NewMockMetadataResponse(t).
+		SetController(seedBroker.BrokerID()).
+		SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+		SetBroker(secondBroker.Addr(), secondBroker.BrokerID())
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": metadataResponse,
+		"ListTransactionsRequest": NewMockListTransactionsResponse(t).
+			AddTransaction(tx1),
+	})
+	secondBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": metadataResponse,
+		"ListTransactionsRequest": NewMockSequence(
+			NewMockListTransactionsResponse(t).SetError(ErrOffsetsLoadInProgress),
+			NewMockListTransactionsResponse(t).AddTransaction(tx1).AddTransaction(tx2),
+		),
+	})
+
+	config := NewTestConfig()
+	config.Version = V3_0_0_0
+	config.Admin.Retry.Backoff = 0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	listings, err := admin.ListTransactions(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.ElementsMatch(t, []*TransactionListing{tx1, tx2}, listings)
+
+	listings, err = admin.ListTransactions([]string{TransactionStateCompleteCommit}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []*TransactionListing{tx2}, listings)
+}
+
 func TestClusterAdminDeleteRecordsWithInCorrectBroker(t *testing.T) {
 	topicName := "my_topic"
 	seedBroker := NewMockBroker(t, 1)
@@ -1027,6 +1482,95 @@ func TestClusterAdminDescribeBrokerConfig(t *testing.T) {
 	}
 }
 
+// TestClusterAdminDescribeConfigs ensures that topic and broker resources are
+// described in a single request to the broker in question and that the
+// results are demultiplexed with their own errors
+func TestClusterAdminDescribeConfigs(t *testing.T) {
+	controllerBroker := NewMockBroker(t, 1)
+	defer controllerBroker.Close()
+	configBroker := NewMockBroker(t, 2)
+	defer configBroker.Close()
+
+	metadataResponse := NewMockMetadataResponse(t).
+		SetController(controllerBroker.BrokerID()).
+		SetBroker(controllerBroker.Addr(), controllerBroker.BrokerID()).
+		SetBroker(configBroker.Addr(), configBroker.BrokerID())
+	controllerBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": metadataResponse,
+	})
+	configBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": metadataResponse,
+		"DescribeConfigsRequest": NewMockWrapper(&DescribeConfigsResponse{
+			Resources: []*ResourceResponse{
+				{
+					Type:    TopicResource,
+					Name:    "my_topic",
+					Configs: []*ConfigEntry{{Name: "retention.ms", Value: "5000"}},
+				},
+				{
+					Type:      TopicResource,
+					Name:      "missing_topic",
+					ErrorCode: int16(ErrUnknownTopicOrPartition),
+					ErrorMsg:  "no such topic",
+				},
+				{
+					Type:    BrokerResource,
+					Name:    "2",
+					Configs: []*ConfigEntry{{Name: "min.insync.replicas", Value: "2"}},
+				},
+			},
+		}),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+	admin, err := NewClusterAdmin([]string{controllerBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	results, err := admin.DescribeConfigs([]ConfigResource{
+		{Type: TopicResource, Name: "my_topic"},
+		{Type: TopicResource, Name: "missing_topic"},
+		{Type: BrokerResource, Name: "2"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var requests []*DescribeConfigsRequest
+	for _, rr := range configBroker.History() {
+		if req, ok := rr.Request.(*DescribeConfigsRequest); ok {
+			requests = append(requests, req)
+		}
+	}
+	if len(requests) != 1 || len(requests[0].Resources) != 3 {
+		t.Fatalf("Expected a single DescribeConfigsRequest with 3 resources, got %v", requests)
+	}
+	for _, rr := range controllerBroker.History() {
+		if _, ok := rr.Request.(*DescribeConfigsRequest); ok {
+			t.Error("Unexpected DescribeConfigsRequest sent to the controller")
+		}
+	}
+
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(results))
+	}
+	topic := results[ConfigResourceKey{Type: TopicResource, Name: "my_topic"}]
+	if topic == nil || topic.Err != nil || len(topic.Entries) != 1 || topic.Entries[0].Value != "5000" {
+		t.Errorf("Unexpected result for my_topic: %+v", topic)
+	}
+	broker := results[ConfigResourceKey{Type: BrokerResource, Name: "2"}]
+	if broker == nil || broker.Err != nil || len(broker.Entries) != 1 || broker.Entries[0].Value != "2" {
+		t.Errorf("Unexpected result for broker 2: %+v", broker)
+	}
+	missing := results[ConfigResourceKey{Type: TopicResource, Name: "missing_topic"}]
+	if missing == nil || !errors.Is(missing.Err, ErrUnknownTopicOrPartition) {
+		t.Errorf("Expected ErrUnknownTopicOrPartition for missing_topic, got %+v", missing)
+	}
+}
+
 func TestClusterAdminAlterConfig(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
@@ -1290,6 +1834,141 @@ func TestClusterAdminIncrementalAlterBrokerConfig(t *testing.T) {
 	}
 }
 
+func TestClusterAdminIncrementalAlterConfigRoutesToController(t *testing.T) {
+	seedBroker := newMockBroker(t, 1)
+	controllerBroker := newMockBroker(t, 2)
+
+	metadata := mockMetadataFor(t, controllerBroker, seedBroker)
+	seedBroker.SetHandlerByMap(map[string]MockResponse{"MetadataRequest": metadata})
+
+	var requests atomic.Int32
+	controllerBroker.SetHandlerFuncByMap(map[string]requestHandlerFunc{
+		"MetadataRequest": func(r *request) encoderWithHeader { return metadata.For(r.body) },
+		"IncrementalAlterConfigsRequest": func(r *request) encoderWithHeader {
+			req := r.body.(*IncrementalAlterConfigsRequest)
+			res := &IncrementalAlterConfigsResponse{Version: req.version()}
+			errorCode := int16(ErrNoError)
+			if requests.Add(1) == 1 {
+				// the first attempt lands on a broker that just lost the controllership
+				errorCode = int16(ErrNotController)
+			}
+			for _, resource := range req.Resources {
+				res.Resources = append(res.Resources, &AlterConfigsResourceResponse{
+					Name:      resource.Name,
+					Type:      resource.Type,
+					ErrorCode: errorCode,
+				})
+			}
+			return res
+		},
+	})
+
+	value := "60000"
+	err := newTestAdminAt(t, V2_3_0_0, seedBroker).IncrementalAlterConfig(TopicResource, "my_topic", map[string]IncrementalAlterConfigsEntry{
+		"retention.ms": {Operation: IncrementalAlterConfigsOperationSet, Value: &value},
+	}, false)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), requests.Load())
+	for _, rr := range seedBroker.History() {
+		if _, ok := rr.Request.(*IncrementalAlterConfigsRequest); ok {
+			t.Error("expected the request to be sent to the controller only")
+		}
+	}
+}
+
+func TestClusterAdminRediscoversMovedController(t *testing.T) {
+	seedBroker := newMockBroker(t, 1)
+	oldController := newMockBroker(t, 2)
+	newController := newMockBroker(t, 3)
+
+	before := mockMetadataFor(t, oldController, seedBroker, newController)
+	after := mockMetadataFor(t, newController, seedBroker, oldController)
+	var moved atomic.Bool
+	metadata := func(r *request) encoderWithHeader {
+		if moved.Load() {
+			return after.For(r.body)
+		}
+		return before.For(r.body)
+	}
+	notController := func(r *request) encoderWithHeader {
+		// the controllership moved away from this broker
+		moved.Store(true)
+		req := r.body.(*CreateAclsRequest)
+		res := &CreateAclsResponse{Version: req.version()}
+		for range req.AclCreations {
+			res.AclCreationResponses = append(res.AclCreationResponses, &AclCreationResponse{Err: ErrNotController})
+		}
+		return res
+	}
+	for _, b := range []*MockBroker{seedBroker, oldController} {
+		b.SetHandlerFuncByMap(map[string]requestHandlerFunc{
+			"MetadataRequest":   metadata,
+			"CreateAclsRequest": notController,
+		})
+	}
+	created := NewMockCreateAclsResponse(t)
+	newController.SetHandlerFuncByMap(map[string]requestHandlerFunc{
+		"MetadataRequest":   metadata,
+		"CreateAclsRequest": func(r *request) encoderWithHeader { return created.For(r.body) },
+	})
+
+	admin := newTestAdminAt(t, V2_0_0_0, seedBroker)
+	err := admin.CreateACL(
+		Resource{ResourceType: AclResourceTopic, ResourceName: "my_topic"},
+		Acl{Host: "localhost", Operation: AclOperationAlter, PermissionType: AclPermissionAllow},
+	)
+	require.NoError(t, err)
+
+	countCreateAcls := func(b *MockBroker) (n int) {
+		for _, rr := range b.History() {
+			if _, ok := rr.Request.(*CreateAclsRequest); ok {
+				n++
+			}
+		}
+		return n
+	}
+	assert.Equal(t, 1, countCreateAcls(oldController))
+	assert.Equal(t, 1, countCreateAcls(newController))
+	assert.Zero(t, countCreateAcls(seedBroker))
+}
+
+func TestClusterAdminControllerRetriesExhausted(t *testing.T) {
+	seedBroker := newMockBroker(t, 1)
+	metadata := mockMetadataFor(t, seedBroker)
+	var attempts atomic.Int32
+	seedBroker.SetHandlerFuncByMap(map[string]requestHandlerFunc{
+		"MetadataRequest": func(r *request) encoderWithHeader { return metadata.For(r.body) },
+		"AlterClientQuotasRequest": func(r *request) encoderWithHeader {
+			attempts.Add(1)
+			req := r.body.(*AlterClientQuotasRequest)
+			res := &AlterClientQuotasResponse{}
+			for _, entry := range req.Entries {
+				res.Entries = append(res.Entries, AlterClientQuotasEntryResponse{
+					ErrorCode: ErrNotController,
+					Entity:    entry.Entity,
+				})
+			}
+			return res
+		},
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_6_0_0
+	config.Admin.Retry.Max = 2
+	config.Admin.Retry.Backoff = 0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	require.NoError(t, err)
+	defer safeClose(t, admin)
+
+	err = admin.AlterClientQuotas(
+		[]QuotaEntityComponent{{EntityType: QuotaEntityUser, MatchType: QuotaMatchExact, Name: "alice"}},
+		ClientQuotasOp{Key: "producer_byte_rate", Value: 1024},
+		false,
+	)
+	require.ErrorIs(t, err, ErrNotController)
+	assert.Equal(t, int32(3), attempts.Load())
+}
+
 func TestClusterAdminCreateAcl(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
@@ -1387,7 +2066,239 @@ func TestClusterAdminCreateAcls(t *testing.T) {
 		},
 	}
 
-	err = admin.CreateACLs(rACLs)
+	err = admin.CreateACLs(rACLs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = admin.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClusterAdminListAcls(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"DescribeAclsRequest": NewMockListAclsResponse(t),
+		"CreateAclsRequest":   NewMockCreateAclsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := Resource{ResourceType: AclResourceTopic, ResourceName: "my_topic"}
+	a := Acl{Host: "localhost", Operation: AclOperationAlter, PermissionType: AclPermissionAny}
+
+	err = admin.CreateACL(r, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resourceName := "my_topic"
+	filter := AclFilter{
+		ResourceType: AclResourceTopic,
+		Operation:    AclOperationRead,
+		ResourceName: &resourceName,
+	}
+
+	rAcls, err := admin.ListAcls(filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rAcls) == 0 {
+		t.Fatal("no acls present")
+	}
+
+	err = admin.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// mockAclStore answers DescribeAclsRequests with its resources the way a
+// broker filters them, brokers only knowing literal ACLs before v1.
+type mockAclStore struct {
+	resources []Resource
+}
+
+func (m *mockAclStore) For(reqBody versionedDecoder) encoderWithHeader {
+	req := reqBody.(*DescribeAclsRequest)
+	res := &DescribeAclsResponse{Version: req.version()}
+	patternType := req.ResourcePatternTypeFilter
+	if req.Version == 0 {
+		patternType = AclPatternLiteral
+	}
+	for _, r := range m.resources {
+		if req.Version == 0 && r.ResourcePatternType != AclPatternLiteral {
+			continue
+		}
+		if req.ResourceType != AclResourceAny && req.ResourceType != r.ResourceType {
+			continue
+		}
+		match := req.ResourceName == nil || *req.ResourceName == r.ResourceName
+		switch patternType {
+		case AclPatternLiteral, AclPatternPrefixed:
+			match = match && patternType == r.ResourcePatternType
+		case AclPatternMatch:
+			if req.ResourceName != nil {
+				name := *req.ResourceName
+				match = r.ResourcePatternType == AclPatternLiteral && (r.ResourceName == name || r.ResourceName == "*") ||
+					r.ResourcePatternType == AclPatternPrefixed && strings.HasPrefix(name, r.ResourceName)
+			}
+		}
+		if match {
+			res.ResourceAcls = append(res.ResourceAcls, &ResourceAcls{
+				Resource: r,
+				Acls:     []*Acl{{Principal: "User:alice", Host: "*", Operation: AclOperationRead, PermissionType: AclPermissionAllow}},
+			})
+		}
+	}
+	return res
+}
+
+func TestClusterAdminListAclsFiltered(t *testing.T) {
+	topic := func(name string, patternType AclResourcePatternType) Resource {
+		return Resource{ResourceType: AclResourceTopic, ResourceName: name, ResourcePatternType: patternType}
+	}
+	store := &mockAclStore{resources: []Resource{
+		topic("orders-eu", AclPatternLiteral),
+		topic("*", AclPatternLiteral),
+		topic("orders-", AclPatternPrefixed),
+		topic("payments", AclPatternLiteral),
+	}}
+
+	tests := []struct {
+		name     string
+		version  KafkaVersion
+		filter   AclFilter
+		expected []Resource
+		err      error
+	}{
+		{
+			"match v0", V1_0_0_0,
+			NewAclFilterBuilder().ResourceType(AclResourceTopic).Match("orders-eu").Build(),
+			[]Resource{topic("orders-eu", AclPatternLiteral), topic("*", AclPatternLiteral)},
+			nil,
+		},
+		{
+			"match v1", V2_0_0_0,
+			NewAclFilterBuilder().ResourceType(AclResourceTopic).Match("orders-eu").Build(),
+			[]Resource{topic("orders-eu", AclPatternLiteral), topic("*", AclPatternLiteral), topic("orders-", AclPatternPrefixed)},
+			nil,
+		},
+		{
+			"match v2", V2_5_0_0,
+			NewAclFilterBuilder().ResourceType(AclResourceTopic).Match("orders-eu").Build(),
+			[]Resource{topic("orders-eu", AclPatternLiteral), topic("*", AclPatternLiteral), topic("orders-", AclPatternPrefixed)},
+			nil,
+		},
+		{
+			"literal v0", V1_0_0_0,
+			NewAclFilterBuilder().Literal("orders-eu").Build(),
+			[]Resource{topic("orders-eu", AclPatternLiteral)},
+			nil,
+		},
+		{
+			"literal v2", V2_5_0_0,
+			NewAclFilterBuilder().Literal("*").Build(),
+			[]Resource{topic("*", AclPatternLiteral)},
+			nil,
+		},
+		{
+			"prefixed v0", V1_0_0_0,
+			NewAclFilterBuilder().Prefixed("orders-").Build(),
+			nil,
+			ConfigurationError("prefixed ACLs require Version >= V2_0_0_0"),
+		},
+		{
+			"prefixed v1", V2_0_0_0,
+			NewAclFilterBuilder().Prefixed("orders-").Build(),
+			[]Resource{topic("orders-", AclPatternPrefixed)},
+			nil,
+		},
+		{
+			"any v0", V1_0_0_0,
+			NewAclFilterBuilder().Build(),
+			[]Resource{topic("orders-eu", AclPatternLiteral), topic("*", AclPatternLiteral), topic("payments", AclPatternLiteral)},
+			nil,
+		},
+		{
+			"no pattern type v2", V2_5_0_0,
+			AclFilter{ResourceType: AclResourceTopic, Operation: AclOperationAny, PermissionType: AclPermissionAny},
+			store.resources,
+			nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seedBroker := NewMockBroker(t, 1)
+			defer seedBroker.Close()
+
+			seedBroker.SetHandlerByMap(map[string]MockResponse{
+				"MetadataRequest": NewMockMetadataResponse(t).
+					SetController(seedBroker.BrokerID()).
+					SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+				"DescribeAclsRequest": store,
+			})
+
+			config := NewTestConfig()
+			config.Version = tt.version
+			admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+			require.NoError(t, err)
+			defer safeClose(t, admin)
+
+			rAcls, err := admin.ListAclsFiltered(tt.filter)
+			if tt.err != nil {
+				require.Equal(t, tt.err, err)
+				return
+			}
+			require.NoError(t, err)
+			var resources []Resource
+			for _, rAcl := range rAcls {
+				require.Len(t, rAcl.Acls, 1)
+				resources = append(resources, rAcl.Resource)
+			}
+			assert.Equal(t, tt.expected, resources)
+		})
+	}
+}
+
+func TestClusterAdminDeleteAcl(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"DeleteAclsRequest": NewMockDeleteAclsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V1_0_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resourceName := "my_topic"
+	filter := AclFilter{
+		ResourceType: AclResourceTopic,
+		Operation:    AclOperationAlter,
+		ResourceName: &resourceName,
+	}
+
+	_, err = admin.DeleteACL(filter, false)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -1398,7 +2309,7 @@ func TestClusterAdminCreateAcls(t *testing.T) {
 	}
 }
 
-func TestClusterAdminListAcls(t *testing.T) {
+func TestClusterAdminAlterClientQuotas(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
 
@@ -1406,46 +2317,45 @@ func TestClusterAdminListAcls(t *testing.T) {
 		"MetadataRequest": NewMockMetadataResponse(t).
 			SetController(seedBroker.BrokerID()).
 			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
-		"DescribeAclsRequest": NewMockListAclsResponse(t),
-		"CreateAclsRequest":   NewMockCreateAclsResponse(t),
+		"AlterClientQuotasRequest": NewMockAlterClientQuotasResponse(t),
 	})
 
 	config := NewTestConfig()
-	config.Version = V1_0_0_0
+	config.Version = V2_8_0_0
 	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
 	if err != nil {
 		t.Fatal(err)
 	}
+	defer safeClose(t, admin)
 
-	r := Resource{ResourceType: AclResourceTopic, ResourceName: "my_topic"}
-	a := Acl{Host: "localhost", Operation: AclOperationAlter, PermissionType: AclPermissionAny}
-
-	err = admin.CreateACL(r, a)
-	if err != nil {
+	entity := []QuotaEntityComponent{{
+		EntityType: QuotaEntityClientID,
+		MatchType:  QuotaMatchExact,
+		Name:       "my-client",
+	}}
+	op := ClientQuotasOp{Key: QuotaKeyConsumerByteRate, Value: 1024}
+	if err := admin.AlterClientQuotas(entity, op, true); err != nil {
 		t.Fatal(err)
 	}
-	resourceName := "my_topic"
-	filter := AclFilter{
-		ResourceType: AclResourceTopic,
-		Operation:    AclOperationRead,
-		ResourceName: &resourceName,
-	}
 
-	rAcls, err := admin.ListAcls(filter)
-	if err != nil {
-		t.Fatal(err)
+	var request *AlterClientQuotasRequest
+	for _, rr := range seedBroker.History() {
+		if r, ok := rr.Request.(*AlterClientQuotasRequest); ok {
+			request = r
+		}
 	}
-	if len(rAcls) == 0 {
-		t.Fatal("no acls present")
+	if request == nil {
+		t.Fatal("expected an AlterClientQuotasRequest")
 	}
-
-	err = admin.Close()
-	if err != nil {
-		t.Fatal(err)
+	if request.Version != 1 {
+		t.Errorf("expected version 1, got %d", request.Version)
+	}
+	if !request.ValidateOnly {
+		t.Error("expected ValidateOnly to be set")
 	}
 }
 
-func TestClusterAdminDeleteAcl(t *testing.T) {
+func TestClusterAdminAlterClientQuotasError(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
 
@@ -1453,31 +2363,25 @@ func TestClusterAdminDeleteAcl(t *testing.T) {
 		"MetadataRequest": NewMockMetadataResponse(t).
 			SetController(seedBroker.BrokerID()).
 			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
-		"DeleteAclsRequest": NewMockDeleteAclsResponse(t),
+		"AlterClientQuotasRequest": NewMockAlterClientQuotasResponse(t).
+			SetError(ErrInvalidRequest),
 	})
 
 	config := NewTestConfig()
-	config.Version = V1_0_0_0
+	config.Version = V2_6_0_0
 	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
 	if err != nil {
 		t.Fatal(err)
 	}
+	defer safeClose(t, admin)
 
-	resourceName := "my_topic"
-	filter := AclFilter{
-		ResourceType: AclResourceTopic,
-		Operation:    AclOperationAlter,
-		ResourceName: &resourceName,
-	}
-
-	_, err = admin.DeleteACL(filter, false)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	err = admin.Close()
-	if err != nil {
-		t.Fatal(err)
+	entity := []QuotaEntityComponent{{
+		EntityType: QuotaEntityUser,
+		MatchType:  QuotaMatchDefault,
+	}}
+	op := ClientQuotasOp{Key: QuotaKeyProducerByteRate, Value: 1024}
+	if err := admin.AlterClientQuotas(entity, op, false); !errors.Is(err, ErrInvalidRequest) {
+		t.Fatalf("expected ErrInvalidRequest, got %v", err)
 	}
 }
 
@@ -1510,7 +2414,7 @@ func TestElectLeaders(t *testing.T) {
 		t.Fatalf("topic missing in response")
 	}
 
-	if len(partitionResult) != 1 {
+	if len(partitionResult) != 2 {
 		t.Fatalf("partition missing in response")
 	}
 
@@ -1520,6 +2424,52 @@ func TestElectLeaders(t *testing.T) {
 	}
 }
 
+func TestElectLeadersAllPartitions(t *testing.T) {
+	broker := NewMockBroker(t, 1)
+	defer broker.Close()
+
+	broker.SetHandlerByMap(map[string]MockResponse{
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t),
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(broker.BrokerID()).
+			SetBroker(broker.Addr(), broker.BrokerID()),
+		"ElectLeadersRequest": NewMockElectLeadersResponse(t).
+			SetPartitionError("my_topic", 0, ErrNoError).
+			SetPartitionError("my_topic", 1, ErrElectionNotNeeded),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_4_0_0
+	admin, err := NewClusterAdmin([]string{broker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	response, err := admin.ElectLeaders(UncleanElection, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, rr := range broker.History() {
+		if req, ok := rr.Request.(*ElectLeadersRequest); ok {
+			if req.TopicPartitions != nil {
+				t.Errorf("expected a request for all partitions, got %v", req.TopicPartitions)
+			}
+			if req.Type != UncleanElection {
+				t.Errorf("expected unclean election, got %d", req.Type)
+			}
+		}
+	}
+
+	if got := response["my_topic"][0].ErrorCode; !errors.Is(got, ErrNoError) {
+		t.Errorf("expected partition 0 to be elected, got %v", got)
+	}
+	if got := response["my_topic"][1].ErrorCode; !errors.Is(got, ErrElectionNotNeeded) {
+		t.Errorf("expected partition 1 to report %v, got %v", ErrElectionNotNeeded, got)
+	}
+}
+
 func TestDescribeTopic(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
@@ -1849,7 +2799,19 @@ func TestListConsumerGroupOffsetsBatch(t *testing.T) {
 		assertGroupOffset(t, result, groupA, otherTopic, 0, expectedOffsetB)
 	})
 
-	t.Run("rejects broker downgrade below v8", func(t *testing.T) {
+	// offsetFetchVersions returns the versions of the OffsetFetch requests
+	// received by the broker.
+	offsetFetchVersions := func(broker *MockBroker) []int16 {
+		var versions []int16
+		for _, rr := range broker.History() {
+			if req, ok := rr.Request.(*OffsetFetchRequest); ok {
+				versions = append(versions, req.Version)
+			}
+		}
+		return versions
+	}
+
+	t.Run("falls back to a request per group on broker downgrade below v8", func(t *testing.T) {
 		broker := newMockBroker(t, 1)
 		broker.SetHandlerByMap(map[string]MockResponse{
 			"ApiVersionsRequest": NewMockApiVersionsResponse(t).SetApiKeys([]ApiVersionsResponseKey{
@@ -1857,6 +2819,10 @@ func TestListConsumerGroupOffsetsBatch(t *testing.T) {
 			}),
 			"MetadataRequest":        mockMetadataFor(t, broker),
 			"FindCoordinatorRequest": mockGroupCoordinators(t, broker, groupA, groupB),
+			"OffsetFetchRequest": NewMockOffsetFetchResponse(t).
+				SetOffset(groupA, topic, 0, expectedOffsetA, "", ErrNoError).
+				SetOffset(groupB, topic, 0, expectedOffsetB, "", ErrNoError).
+				SetError(ErrNoError),
 		})
 		config := NewTestConfig()
 		config.ApiVersionsRequest = true
@@ -1865,8 +2831,66 @@ func TestListConsumerGroupOffsetsBatch(t *testing.T) {
 		require.NoError(t, err)
 		t.Cleanup(func() { admin.Close() })
 
-		_, err = admin.ListConsumerGroupOffsetsBatch(bothGroups)
-		require.ErrorIs(t, err, ErrUnsupportedVersion)
+		result, err := admin.ListConsumerGroupOffsetsBatch(bothGroups)
+		require.NoError(t, err)
+		assertGroupOffset(t, result, groupA, topic, 0, expectedOffsetA)
+		assertGroupOffset(t, result, groupB, topic, 0, expectedOffsetB)
+		assert.Equal(t, []int16{7, 7}, offsetFetchVersions(broker))
+	})
+
+	t.Run("falls back to a request per group on older versions", func(t *testing.T) {
+		broker := newMockBroker(t, 1)
+		broker.SetHandlerByMap(map[string]MockResponse{
+			"OffsetFetchRequest": NewMockOffsetFetchResponse(t).
+				SetOffset(groupA, topic, 0, expectedOffsetA, "", ErrNoError).
+				SetOffset(groupB, topic, 0, expectedOffsetB, "", ErrNoError).
+				SetError(ErrNoError),
+			"MetadataRequest":        mockMetadataFor(t, broker),
+			"FindCoordinatorRequest": mockGroupCoordinators(t, broker, groupA, groupB),
+		})
+		admin := newTestAdminAt(t, V2_0_0_0, broker)
+
+		result, err := admin.ListConsumerGroupOffsetsBatch(bothGroups)
+		require.NoError(t, err)
+		assertGroupOffset(t, result, groupA, topic, 0, expectedOffsetA)
+		assertGroupOffset(t, result, groupB, topic, 0, expectedOffsetB)
+		assert.Equal(t, []int16{4, 4}, offsetFetchVersions(broker))
+	})
+
+	t.Run("retries on retriable group error on older versions", func(t *testing.T) {
+		broker := newMockBroker(t, 1)
+		notCoordinator := &OffsetFetchResponse{Version: 4, Err: ErrNotCoordinatorForConsumer}
+		success := NewMockOffsetFetchResponse(t).
+			SetOffset(groupA, topic, 0, expectedOffsetA, "", ErrNoError).
+			SetError(ErrNoError)
+		broker.SetHandlerByMap(map[string]MockResponse{
+			"OffsetFetchRequest":     NewMockSequence(notCoordinator, success),
+			"MetadataRequest":        mockMetadataFor(t, broker),
+			"FindCoordinatorRequest": mockGroupCoordinators(t, broker, groupA),
+		})
+		admin := newTestAdminAt(t, V2_0_0_0, broker)
+
+		result, err := admin.ListConsumerGroupOffsetsBatch(map[string]map[string][]int32{groupA: {topic: {0}}})
+		require.NoError(t, err)
+		assertGroupOffset(t, result, groupA, topic, 0, expectedOffsetA)
+		assert.Len(t, offsetFetchVersions(broker), 2)
+	})
+
+	t.Run("sends a single request per coordinator from v8", func(t *testing.T) {
+		broker := newMockBroker(t, 1)
+		broker.SetHandlerByMap(map[string]MockResponse{
+			"OffsetFetchRequest": NewMockOffsetFetchResponse(t).
+				SetOffset(groupA, topic, 0, expectedOffsetA, "", ErrNoError).
+				SetOffset(groupB, topic, 0, expectedOffsetB, "", ErrNoError).
+				SetError(ErrNoError),
+			"MetadataRequest":        mockMetadataFor(t, broker),
+			"FindCoordinatorRequest": mockGroupCoordinators(t, broker, groupA, groupB),
+		})
+		admin := newTestAdminAt(t, V3_0_0_0, broker)
+
+		_, err := admin.ListConsumerGroupOffsetsBatch(bothGroups)
+		require.NoError(t, err)
+		assert.Equal(t, []int16{8}, offsetFetchVersions(broker))
 	})
 
 	t.Run("limits broker's advertised max to client's supported max", func(t *testing.T) {
@@ -2270,6 +3294,14 @@ func TestDeleteOffset(t *testing.T) {
 	if !errors.Is(err, ErrGroupSubscribedToTopic) {
 		t.Fatalf("DeleteConsumerGroupOffset should have failed with error %v", ErrGroupSubscribedToTopic)
 	}
+
+	// Test missing partition in response
+	handlerMap["DeleteOffsetsRequest"] = NewMockDeleteOffsetRequest(t).SetDeletedOffset(ErrNoError, topic, partition+1, ErrNoError)
+	seedBroker.SetHandlerByMap(handlerMap)
+	err = admin.DeleteConsumerGroupOffset(group, topic, partition)
+	if !errors.Is(err, ErrIncompleteResponse) {
+		t.Fatalf("DeleteConsumerGroupOffset should have failed with error %v", ErrIncompleteResponse)
+	}
 }
 
 // TestRefreshMetaDataWithDifferentController ensures that the cached
@@ -2419,6 +3451,66 @@ func TestDescribeLogDirsUnknownBroker(t *testing.T) {
 	}
 }
 
+func TestDescribeLogDirsAllBrokers(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	secondBroker := NewMockBroker(t, 2)
+	defer secondBroker.Close()
+
+	metadata := NewMockMetadataResponse(t).
+		SetController(seedBroker.BrokerID()).
+		SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+		SetBroker(secondBroker.Addr(), secondBroker.BrokerID())
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": metadata,
+		"DescribeLogDirsRequest": NewMockDescribeLogDirsResponse(t).
+			SetLogDirs("/tmp/logs", map[string]int{"topic1": 2}).
+			SetLogDirCapacity(1000, 400),
+	})
+	secondBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": metadata,
+		"DescribeLogDirsRequest": NewMockDescribeLogDirsResponse(t).
+			SetLogDirs("/tmp/logs", map[string]int{"topic1": 2}).
+			AddLogDirError("/tmp/offline", ErrKafkaStorageError),
+	})
+
+	config := NewTestConfig()
+	config.Version = V3_3_0_0
+
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, admin)
+
+	logDirsPerBroker, err := admin.DescribeLogDirs(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logDirsPerBroker) != 2 {
+		t.Fatalf("Expected results for 2 brokers, got %v", len(logDirsPerBroker))
+	}
+
+	logDirs := logDirsPerBroker[seedBroker.BrokerID()]
+	if len(logDirs) != 1 {
+		t.Fatalf("Expected 1 log dir for broker %v, got %v", seedBroker.BrokerID(), len(logDirs))
+	}
+	if logDirs[0].TotalBytes != 1000 || logDirs[0].UsableBytes != 400 {
+		t.Errorf("Expected total/usable bytes 1000/400, got %v/%v", logDirs[0].TotalBytes, logDirs[0].UsableBytes)
+	}
+	if size := logDirs[0].Topics[0].Partitions[0].Size; size != 1234 {
+		t.Errorf("Expected partition size 1234, got %v", size)
+	}
+
+	logDirs = logDirsPerBroker[secondBroker.BrokerID()]
+	if len(logDirs) != 2 {
+		t.Fatalf("Expected 2 log dirs for broker %v, got %v", secondBroker.BrokerID(), len(logDirs))
+	}
+	if !errors.Is(logDirs[1].ErrorCode, ErrKafkaStorageError) || logDirs[1].Path != "/tmp/offline" {
+		t.Errorf("Expected /tmp/offline to report ErrKafkaStorageError, got %v %v", logDirs[1].Path, logDirs[1].ErrorCode)
+	}
+}
+
 func Test_retryOnError(t *testing.T) {
 	testBackoffTime := 100 * time.Millisecond
 	config := NewTestConfig()
@@ -2488,3 +3580,183 @@ func Test_retryOnError(t *testing.T) {
 		}
 	})
 }
+
+func TestDescribeClusterInfo(t *testing.T) {
+	t.Run("returns the cluster and its authorized operations", func(t *testing.T) {
+		broker := newMockBroker(t, 1)
+		rack := "rack-a"
+		var includeAuthorizedOperations atomic.Bool
+		broker.SetHandlerFuncByMap(map[string]requestHandlerFunc{
+			"MetadataRequest": func(r *request) encoderWithHeader { return mockMetadataFor(t, broker).For(r.body) },
+			"DescribeClusterRequest": func(r *request) encoderWithHeader {
+				req := r.body.(*DescribeClusterRequest)
+				includeAuthorizedOperations.Store(req.IncludeClusterAuthorizedOperations)
+				return &DescribeClusterResponse{
+					Version:      req.Version,
+					ClusterID:    "my-cluster",
+					ControllerID: 2,
+					Brokers: []*DescribeClusterBroker{
+						{BrokerID: 1, Host: "localhost", Port: 9092, Rack: &rack},
+						{BrokerID: 2, Host: "localhost", Port: 9093},
+					},
+					ClusterAuthorizedOperations: 1<<AclOperationDescribe | 1<<AclOperationAlterConfigs,
+				}
+			},
+		})
+
+		clusterID, controllerID, brokers, operations, err := newTestAdminAt(t, V2_8_0_0, broker).DescribeClusterInfo()
+		require.NoError(t, err)
+		assert.True(t, includeAuthorizedOperations.Load())
+		assert.Equal(t, "my-cluster", clusterID)
+		assert.Equal(t, int32(2), controllerID)
+		require.Len(t, brokers, 2)
+		assert.Equal(t, int32(1), brokers[0].ID())
+		assert.Equal(t, "localhost:9092", brokers[0].Addr())
+		assert.Equal(t, &rack, brokers[0].rack)
+		assert.Equal(t, "localhost:9093", brokers[1].Addr())
+		assert.Equal(t, []AclOperation{AclOperationDescribe, AclOperationAlterConfigs}, AclOperationsFromBitfield(operations))
+	})
+
+	t.Run("returns the error with its message", func(t *testing.T) {
+		broker := newMockBroker(t, 1)
+		message := "not authorized"
+		broker.SetHandlerFuncByMap(map[string]requestHandlerFunc{
+			"MetadataRequest": func(r *request) encoderWithHeader { return mockMetadataFor(t, broker).For(r.body) },
+			"DescribeClusterRequest": func(r *request) encoderWithHeader {
+				return &DescribeClusterResponse{
+					Version:      r.body.version(),
+					Err:          ErrClusterAuthorizationFailed,
+					ErrorMessage: &message,
+				}
+			},
+		})
+
+		_, _, _, _, err := newTestAdminAt(t, V2_8_0_0, broker).DescribeClusterInfo()
+		require.ErrorIs(t, err, ErrClusterAuthorizationFailed)
+		assert.ErrorContains(t, err, message)
+	})
+
+	t.Run("requires Kafka 2.8", func(t *testing.T) {
+		broker := newMockBroker(t, 1)
+		broker.SetHandlerByMap(map[string]MockResponse{"MetadataRequest": mockMetadataFor(t, broker)})
+
+		_, _, _, _, err := newTestAdminAt(t, V2_7_0_0, broker).DescribeClusterInfo()
+		require.ErrorIs(t, err, ErrUnsupportedVersion)
+	})
+}
+
+func TestClusterAdminUpsertUserScramCredentials(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	controllerBroker := NewMockBroker(t, 2)
+	defer controllerBroker.Close()
+
+	metadataResponse := NewMockMetadataResponse(t).
+		SetController(controllerBroker.BrokerID()).
+		SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+		SetBroker(controllerBroker.Addr(), controllerBroker.BrokerID())
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": metadataResponse,
+	})
+	controllerBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": metadataResponse,
+		"AlterUserScramCredentialsRequest": NewMockAlterUserScramCredentialsResponse(t).
+			SetError("bob", ErrUnacceptableCredential),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_7_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	require.NoError(t, err)
+	defer safeClose(t, admin)
+
+	upserts := []AlterUserScramCredentialsUpsert{
+		{Name: "alice", Mechanism: SCRAM_MECHANISM_SHA_256, Iterations: 4096, Salt: []byte("salt"), Password: []byte("alice-secret")},
+		{Name: "bob", Mechanism: SCRAM_MECHANISM_SHA_512, Iterations: 8192, Salt: []byte("pepper"), Password: []byte("bob-secret")},
+	}
+	results, err := admin.UpsertUserScramCredentials(upserts)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	require.Equal(t, "alice", results[0].User)
+	require.ErrorIs(t, results[0].ErrorCode, ErrNoError)
+	require.Equal(t, "bob", results[1].User)
+	require.ErrorIs(t, results[1].ErrorCode, ErrUnacceptableCredential)
+
+	var request *AlterUserScramCredentialsRequest
+	for _, rr := range controllerBroker.History() {
+		if r, ok := rr.Request.(*AlterUserScramCredentialsRequest); ok {
+			request = r
+		}
+	}
+	require.NotNil(t, request, "expected the request to be sent to the controller")
+	require.Len(t, request.Upsertions, 2)
+	for i, upsert := range upserts {
+		sent := request.Upsertions[i]
+		expected, err := scramFormatter{mechanism: upsert.Mechanism}.saltedPassword(upsert.Password, upsert.Salt, int(upsert.Iterations))
+		require.NoError(t, err)
+		require.Equal(t, expected, sent.saltedPassword, "salted password of %s", upsert.Name)
+		require.Nil(t, sent.Password, "the password must not be sent")
+	}
+	require.Len(t, request.Upsertions[0].saltedPassword, 32)
+	require.Len(t, request.Upsertions[1].saltedPassword, 64)
+}
+
+func TestClusterAdminDeleteUserScramCredentials(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"AlterUserScramCredentialsRequest": NewMockAlterUserScramCredentialsResponse(t).
+			SetError("unknown", ErrResourceNotFound),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_7_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	require.NoError(t, err)
+	defer safeClose(t, admin)
+
+	results, err := admin.DeleteUserScramCredentials([]AlterUserScramCredentialsDelete{
+		{Name: "alice", Mechanism: SCRAM_MECHANISM_SHA_256},
+		{Name: "unknown", Mechanism: SCRAM_MECHANISM_SHA_512},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	require.ErrorIs(t, results[0].ErrorCode, ErrNoError)
+	require.ErrorIs(t, results[1].ErrorCode, ErrResourceNotFound)
+}
+
+func TestClusterAdminDescribeUserScramCredentials(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(seedBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"DescribeUserScramCredentialsRequest": NewMockDescribeUserScramCredentialsResponse(t).
+			SetCredential("alice", SCRAM_MECHANISM_SHA_256, 4096).
+			SetCredential("alice", SCRAM_MECHANISM_SHA_512, 8192),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_7_0_0
+	admin, err := NewClusterAdmin([]string{seedBroker.Addr()}, config)
+	require.NoError(t, err)
+	defer safeClose(t, admin)
+
+	results, err := admin.DescribeUserScramCredentials([]string{"alice", "bob"})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	require.Equal(t, "alice", results[0].User)
+	require.ErrorIs(t, results[0].ErrorCode, ErrNoError)
+	require.Equal(t, []*UserScramCredentialsResponseInfo{
+		{Mechanism: SCRAM_MECHANISM_SHA_256, Iterations: 4096},
+		{Mechanism: SCRAM_MECHANISM_SHA_512, Iterations: 8192},
+	}, results[0].CredentialInfos)
+	require.Equal(t, "bob", results[1].User)
+	require.ErrorIs(t, results[1].ErrorCode, ErrResourceNotFound)
+}
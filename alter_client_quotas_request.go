@@ -10,6 +10,16 @@ package sarama
 //       value => FLOAT64
 //       remove => BOOLEAN
 //   validate_only => BOOLEAN
+// AlterClientQuotas Request (Version: 1) => [entries] validate_only _tagged_fields
+//   entries => [entity] [ops] _tagged_fields
+//     entity => entity_type entity_name _tagged_fields
+//       entity_type => COMPACT_STRING
+//       entity_name => COMPACT_NULLABLE_STRING
+//     ops => key value remove _tagged_fields
+//       key => COMPACT_STRING
+//       value => FLOAT64
+//       remove => BOOLEAN
+//   validate_only => BOOLEAN
 
 type AlterClientQuotasRequest struct {
 	Version      int16
@@ -17,6 +27,17 @@ type AlterClientQuotasRequest struct {
 	ValidateOnly bool                     // Whether the alteration should be validated, but not performed.
 }
 
+func NewAlterClientQuotasRequest(version KafkaVersion, entries []AlterClientQuotasEntry, validateOnly bool) *AlterClientQuotasRequest {
+	a := &AlterClientQuotasRequest{
+		Entries:      entries,
+		ValidateOnly: validateOnly,
+	}
+	if version.IsAtLeast(V2_8_0_0) {
+		a.Version = 1
+	}
+	return a
+}
+
 func (a *AlterClientQuotasRequest) setVersion(v int16) {
 	a.Version = v
 }
@@ -46,6 +67,8 @@ func (a *AlterClientQuotasRequest) encode(pe packetEncoder) error {
 	// ValidateOnly
 	pe.putBool(a.ValidateOnly)
 
+	pe.putEmptyTaggedFieldArray()
+
 	return nil
 }
 
@@ -75,7 +98,8 @@ func (a *AlterClientQuotasRequest) decode(pd packetDecoder, version int16) error
 	}
 	a.ValidateOnly = validateOnly
 
-	return nil
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
 }
 
 func (a *AlterClientQuotasEntry) encode(pe packetEncoder) error {
@@ -99,6 +123,8 @@ func (a *AlterClientQuotasEntry) encode(pe packetEncoder) error {
 		}
 	}
 
+	pe.putEmptyTaggedFieldArray()
+
 	return nil
 }
 
@@ -139,7 +165,8 @@ func (a *AlterClientQuotasEntry) decode(pd packetDecoder, version int16) error {
 		a.Ops = []ClientQuotasOp{}
 	}
 
-	return nil
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
 }
 
 func (c *ClientQuotasOp) encode(pe packetEncoder) error {
@@ -154,6 +181,8 @@ func (c *ClientQuotasOp) encode(pe packetEncoder) error {
 	// Remove
 	pe.putBool(c.Remove)
 
+	pe.putEmptyTaggedFieldArray()
+
 	return nil
 }
 
@@ -179,7 +208,8 @@ func (c *ClientQuotasOp) decode(pd packetDecoder, version int16) error {
 	}
 	c.Remove = remove
 
-	return nil
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
 }
 
 func (a *AlterClientQuotasRequest) key() int16 {
@@ -191,13 +221,32 @@ func (a *AlterClientQuotasRequest) version() int16 {
 }
 
 func (a *AlterClientQuotasRequest) headerVersion() int16 {
+	if a.Version >= 1 {
+		return 2
+	}
+
 	return 1
 }
 
 func (a *AlterClientQuotasRequest) isValidVersion() bool {
-	return a.Version == 0
+	return a.Version >= 0 && a.Version <= 1
+}
+
+func (a *AlterClientQuotasRequest) isFlexible() bool {
+	return a.isFlexibleVersion(a.Version)
+}
+
+func (a *AlterClientQuotasRequest) isFlexibleVersion(version int16) bool {
+	return version >= 1
 }
 
 func (a *AlterClientQuotasRequest) requiredVersion() KafkaVersion {
-	return V2_6_0_0
+	switch a.Version {
+	case 1:
+		return V2_8_0_0
+	case 0:
+		return V2_6_0_0
+	default:
+		return V2_8_0_0
+	}
 }
@@ -62,6 +62,22 @@ var (
 		0, // remove
 		0, // validate only
 	}
+
+	alterClientQuotasRequestV1IPOp = []byte{
+		2,           // entries len
+		2,           // entity len
+		3, 'i', 'p', // entity type
+		9, '1', '0', '.', '0', '.', '0', '.', '1', // entity value
+		0,                                                                                            // empty tagged fields
+		2,                                                                                            // ops len
+		19, 'r', 'e', 'q', 'u', 'e', 's', 't', '_', 'p', 'e', 'r', 'c', 'e', 'n', 't', 'a', 'g', 'e', // op key
+		64, 73, 0, 0, 0, 0, 0, 0, // op value (50)
+		0, // remove
+		0, // empty tagged fields
+		0, // empty tagged fields
+		1, // validate only
+		0, // empty tagged fields
+	}
 )
 
 func TestAlterClientQuotasRequest(t *testing.T) {
@@ -153,4 +169,17 @@ func TestAlterClientQuotasRequest(t *testing.T) {
 		ValidateOnly: false,
 	}
 	testRequest(t, "Add multiple Quotas Entries", req, alterClientQuotasRequestMultipleQuotasEntries)
+
+	// Add Quota to an ip, flexible version
+	ipComponent := QuotaEntityComponent{
+		EntityType: QuotaEntityIP,
+		MatchType:  QuotaMatchExact,
+		Name:       "10.0.0.1",
+	}
+	entry = AlterClientQuotasEntry{
+		Entity: []QuotaEntityComponent{ipComponent},
+		Ops:    []ClientQuotasOp{{Key: QuotaKeyRequestPercentage, Value: 50}},
+	}
+	req = NewAlterClientQuotasRequest(V2_8_0_0, []AlterClientQuotasEntry{entry}, true)
+	testRequest(t, "Add single Quota op v1", req, alterClientQuotasRequestV1IPOp)
 }
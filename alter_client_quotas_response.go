@@ -12,6 +12,14 @@ import (
 //     entity => entity_type entity_name
 //       entity_type => STRING
 //       entity_name => NULLABLE_STRING
+// AlterClientQuotas Response (Version: 1) => throttle_time_ms [entries] _tagged_fields
+//   throttle_time_ms => INT32
+//   entries => error_code error_message [entity] _tagged_fields
+//     error_code => INT16
+//     error_message => COMPACT_NULLABLE_STRING
+//     entity => entity_type entity_name _tagged_fields
+//       entity_type => COMPACT_STRING
+//       entity_name => COMPACT_NULLABLE_STRING
 
 type AlterClientQuotasResponse struct {
 	Version      int16
@@ -42,10 +50,13 @@ func (a *AlterClientQuotasResponse) encode(pe packetEncoder) error {
 		}
 	}
 
+	pe.putEmptyTaggedFieldArray()
+
 	return nil
 }
 
 func (a *AlterClientQuotasResponse) decode(pd packetDecoder, version int16) (err error) {
+	a.Version = version
 	if a.ThrottleTime, err = pd.getDurationMs(); err != nil {
 		return err
 	}
@@ -68,7 +79,8 @@ func (a *AlterClientQuotasResponse) decode(pd packetDecoder, version int16) (err
 		a.Entries = []AlterClientQuotasEntryResponse{}
 	}
 
-	return nil
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
 }
 
 func (a *AlterClientQuotasEntryResponse) encode(pe packetEncoder) error {
@@ -90,6 +102,8 @@ func (a *AlterClientQuotasEntryResponse) encode(pe packetEncoder) error {
 		}
 	}
 
+	pe.putEmptyTaggedFieldArray()
+
 	return nil
 }
 
@@ -125,7 +139,8 @@ func (a *AlterClientQuotasEntryResponse) decode(pd packetDecoder, version int16)
 		a.Entity = []QuotaEntityComponent{}
 	}
 
-	return nil
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
 }
 
 func (a *AlterClientQuotasResponse) key() int16 {
@@ -137,15 +152,34 @@ func (a *AlterClientQuotasResponse) version() int16 {
 }
 
 func (a *AlterClientQuotasResponse) headerVersion() int16 {
+	if a.Version >= 1 {
+		return 1
+	}
+
 	return 0
 }
 
 func (a *AlterClientQuotasResponse) isValidVersion() bool {
-	return a.Version == 0
+	return a.Version >= 0 && a.Version <= 1
+}
+
+func (a *AlterClientQuotasResponse) isFlexible() bool {
+	return a.isFlexibleVersion(a.Version)
+}
+
+func (a *AlterClientQuotasResponse) isFlexibleVersion(version int16) bool {
+	return version >= 1
 }
 
 func (a *AlterClientQuotasResponse) requiredVersion() KafkaVersion {
-	return V2_6_0_0
+	switch a.Version {
+	case 1:
+		return V2_8_0_0
+	case 0:
+		return V2_6_0_0
+	default:
+		return V2_8_0_0
+	}
 }
 
 func (r *AlterClientQuotasResponse) throttleTime() time.Duration {
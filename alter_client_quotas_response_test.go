@@ -39,6 +39,19 @@ var (
 		0, 9, 'c', 'l', 'i', 'e', 'n', 't', '-', 'i', 'd', // entityType
 		255, 255, // entityName
 	}
+
+	alterClientQuotasResponseV1SingleEntry = []byte{
+		0, 0, 0, 0, // ThrottleTime
+		2,    // Entries len
+		0, 0, // ErrorCode
+		0,           // ErrorMsg
+		2,           // Entity len
+		3, 'i', 'p', // entityType
+		9, '1', '0', '.', '0', '.', '0', '.', '1', // entityName
+		0, // empty tagged fields
+		0, // empty tagged fields
+		0, // empty tagged fields
+	}
 )
 
 func TestAlterClientQuotasResponse(t *testing.T) {
@@ -93,4 +106,18 @@ func TestAlterClientQuotasResponse(t *testing.T) {
 		Entries:      []AlterClientQuotasEntryResponse{entry1, entry2},
 	}
 	testResponse(t, "Altered multiple entries", res, alterClientQuotasResponseMultipleEntries)
+
+	// Response Altered single entry, flexible version
+	entry = AlterClientQuotasEntryResponse{
+		Entity: []QuotaEntityComponent{{
+			EntityType: QuotaEntityIP,
+			MatchType:  QuotaMatchExact,
+			Name:       "10.0.0.1",
+		}},
+	}
+	res = &AlterClientQuotasResponse{
+		Version: 1,
+		Entries: []AlterClientQuotasEntryResponse{entry},
+	}
+	testResponse(t, "Altered single entry v1", res, alterClientQuotasResponseV1SingleEntry)
 }
@@ -7,6 +7,15 @@ type apiVersionRange struct {
 
 type apiVersionMap map[int16]*apiVersionRange
 
+// ApiVersionRange is the range of versions of an API supported by a broker,
+// as advertised in its ApiVersionsResponse.
+type ApiVersionRange struct {
+	// MinVersion is the minimum supported version, inclusive.
+	MinVersion int16
+	// MaxVersion is the maximum supported version, inclusive.
+	MaxVersion int16
+}
+
 // restrictApiVersion selects the appropriate API version for a given protocol body according to
 // the client and broker version ranges. By default, it selects the maximum version supported by both
 // client and broker, capped by the maximum Kafka version from Config.
@@ -82,4 +91,8 @@ const (
 	apiKeyDescribeUserScramCredentials = 50
 	apiKeyAlterUserScramCredentials    = 51
 	apiKeyDescribeCluster              = 60
+	apiKeyDescribeProducers            = 61
+	apiKeyDescribeTransactions         = 65
+	apiKeyListTransactions             = 66
+	apiKeyConsumerGroupHeartbeat       = 68
 )
@@ -1,11 +1,15 @@
 package sarama
 
 import (
+	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"math"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/eapache/go-resiliency/breaker"
@@ -17,6 +21,28 @@ import (
 // ErrProducerRetryBufferOverflow is returned when the bridging retry buffer is full and OOM prevention needs to be applied.
 var ErrProducerRetryBufferOverflow = errors.New("retry buffer full: message discarded to prevent buffer overflow")
 
+// ErrDeliveryDeadlineExceeded is returned for a message whose ProducerMessage.Deadline
+// passed before it could be delivered, instead of retrying it.
+var ErrDeliveryDeadlineExceeded = errors.New("kafka: message delivery deadline exceeded")
+
+// RetryBufferOverflowPolicy selects which message the AsyncProducer discards when its
+// retry buffer reaches Producer.Retry.MaxBufferLength or Producer.Retry.MaxBufferBytes.
+// The discarded message is returned on Errors() with ErrProducerRetryBufferOverflow.
+type RetryBufferOverflowPolicy int8
+
+const (
+	// RetryBufferDropOldest discards the message that has been waiting the longest
+	// to be retried. This is the default.
+	RetryBufferDropOldest RetryBufferOverflowPolicy = iota
+	// RetryBufferDropNewest discards the message that would overflow the buffer,
+	// keeping the ones that have been waiting the longest.
+	RetryBufferDropNewest
+)
+
+// ErrProducerFlushTimeout is returned by AsyncProducer.Flush when the timeout elapses before
+// every message it waits for has been acknowledged.
+var ErrProducerFlushTimeout = errors.New("kafka: producer flush timed out with messages still in flight")
+
 const (
 	// minFunctionalRetryBufferLength defines the minimum number of messages the retry buffer must support.
 	// If Producer.Retry.MaxBufferLength is set to a non-zero value below this limit, it will be adjusted to this value.
@@ -54,8 +80,10 @@ type AsyncProducer interface {
 
 	// Successes is the success output channel back to the user when Return.Successes is
 	// enabled. If Return.Successes is true, you MUST read from this channel or the
-	// Producer will deadlock. It is suggested that you send and read messages
-	// together in a single select statement.
+	// Producer will deadlock once Return.SuccessesBufferSize successes are pending.
+	// It is suggested that you send and read messages together in a single select
+	// statement. The successes still buffered when the producer is closed
+	// remain readable until the channel is closed.
 	Successes() <-chan *ProducerMessage
 
 	// Errors is the error output channel back to the user. You MUST read from this
@@ -70,6 +98,11 @@ type AsyncProducer interface {
 	// TxnStatus return current producer transaction status.
 	TxnStatus() ProducerTxnStatusFlag
 
+	// ProducerID returns the current producer ID and epoch of an idempotent or
+	// transactional producer, or -1 and -1 otherwise. They change whenever the
+	// epoch is bumped, see ProducerError.EpochBumped.
+	ProducerID() (int64, int16)
+
 	// BeginTxn mark current transaction as ready.
 	BeginTxn() error
 
@@ -84,6 +117,28 @@ type AsyncProducer interface {
 
 	// AddMessageToTxn add message offsets to current transaction.
 	AddMessageToTxn(msg *ConsumerMessage, groupId string, metadata *string) error
+
+	// SendOffsetsAndCommit sends the offsets consumed by the given consumer group
+	// member to the current transaction straight away, rather than when the
+	// transaction is committed like AddOffsetsToTxn, by issuing AddOffsetsToTxn
+	// and TxnOffsetCommit requests. It must be called between BeginTxn and
+	// CommitTxn, and returns ErrTransactionNotReady otherwise. Once it returns
+	// nil the transaction can be committed. ErrProducerFenced and
+	// ErrInvalidProducerEpoch mean another producer with the same transactional
+	// ID has been started and this one must be closed, while ErrFencedInstancedId,
+	// ErrIllegalGeneration and ErrUnknownMemberId mean the consumer group member
+	// has been fenced and the transaction must be aborted.
+	SendOffsetsAndCommit(ctx context.Context, offsets map[string][]*PartitionOffsetMetadata, groupMetadata *ConsumerGroupMetadata) error
+
+	// Flush blocks until every message sent on Input before the call has been
+	// acknowledged or the timeout elapses, sending the buffered ones immediately
+	// regardless of the Producer.Flush thresholds. It returns ProducerErrors for
+	// those of these messages that failed, joined with ErrProducerFlushTimeout
+	// if some were still in flight when the timeout elapsed. Failed messages are
+	// also returned on the Errors channel, which must still be read. Messages
+	// sent concurrently with Flush are neither waited for nor reported. Flush may
+	// be called concurrently, but not after the producer has been closed.
+	Flush(timeout time.Duration) error
 }
 
 type asyncProducer struct {
@@ -105,9 +160,117 @@ type asyncProducer struct {
 	// mirroring Kafka's RecordAccumulator.
 	muter *partitionMuter
 
+	// zstdDict is the dictionary of Producer.ZstdDictionary, if any
+	zstdDict *zstdDictionary
+
+	// leaderStates tracks the partition leaders of an idempotent producer, to
+	// resync the batches rejected by a newly elected leader.
+	leaderStates     map[topicPartition]*partitionLeaderState
+	leaderStatesLock sync.Mutex
+	// resyncLock serializes the epoch bumps of the resyncs
+	resyncLock sync.Mutex
+
 	metricsRegistry metrics.Registry
 }
 
+// partitionLeaderState is the leader epoch state of a partition of an
+// idempotent producer.
+type partitionLeaderState struct {
+	// acked is the leader epoch under which the last batch was acknowledged
+	acked int32
+	// resynced is the leader epoch the partition was last resynced for
+	resynced int32
+	// pending holds the batches rejected by the new leader, in send order,
+	// while resyncing
+	pending   []*partitionSet
+	resyncing bool
+}
+
+// flushGeneration counts the user messages accepted by the dispatcher between
+// two Flush calls. Flush seals the current generation and waits for it, and for
+// the older generations still pending then, to be acknowledged. Messages only
+// cost a counter update until a generation is sealed.
+type flushGeneration struct {
+	pending atomic.Int64
+	sealed  atomic.Bool
+	closed  atomic.Bool
+	done    chan none
+	// prev is the previous generation when it was still pending at creation
+	prev *flushGeneration
+
+	errsLock sync.Mutex
+	errs     ProducerErrors
+}
+
+func newFlushGeneration(prev *flushGeneration) *flushGeneration {
+	if prev != nil && prev.closed.Load() {
+		prev = nil
+	}
+	return &flushGeneration{done: make(chan none), prev: prev}
+}
+
+// add counts msg in the generation, which must not be sealed.
+func (g *flushGeneration) add(msg *ProducerMessage) {
+	g.pending.Add(1)
+	msg.flushGen = g
+}
+
+// seal stops accepting messages in the generation and lets it complete once
+// its messages are acknowledged.
+func (g *flushGeneration) seal() {
+	g.sealed.Store(true)
+	if g.pending.Load() == 0 {
+		g.close()
+	}
+}
+
+func (g *flushGeneration) close() {
+	if g.closed.CompareAndSwap(false, true) {
+		close(g.done)
+	}
+}
+
+// acknowledge counts msg out of g, recording pErr for the Flush callers when
+// it failed after g was sealed.
+func (g *flushGeneration) acknowledge(pErr *ProducerError) {
+	if pErr != nil && g.sealed.Load() {
+		g.errsLock.Lock()
+		g.errs = append(g.errs, pErr)
+		g.errsLock.Unlock()
+	}
+	if g.pending.Add(-1) == 0 && g.sealed.Load() {
+		g.close()
+	}
+}
+
+// wait waits for g and the generations before it, and returns the errors they
+// collected.
+func (g *flushGeneration) wait(timeout <-chan time.Time) (ProducerErrors, error) {
+	var err error
+	var pErrs ProducerErrors
+	for gen := g; gen != nil; gen = gen.prev {
+		if err == nil {
+			select {
+			case <-gen.done:
+			case <-timeout:
+				err = ErrProducerFlushTimeout
+			}
+		}
+		gen.errsLock.Lock()
+		pErrs = append(pErrs, gen.errs...)
+		gen.errsLock.Unlock()
+	}
+	return pErrs, err
+}
+
+// flushed counts msg out of its flush generation, if any.
+func (m *ProducerMessage) flushed(pErr *ProducerError) {
+	if g := m.flushGen; g != nil {
+		m.flushGen = nil
+		g.acknowledge(pErr)
+	}
+}
+
 type partitionMuter struct {
 	mu             sync.Mutex
 	cond           *sync.Cond
@@ -302,12 +465,13 @@ func newAsyncProducer(client Client) (AsyncProducer, error) {
 		conf:            client.Config(),
 		errors:          make(chan *ProducerError),
 		input:           make(chan *ProducerMessage),
-		successes:       make(chan *ProducerMessage),
+		successes:       make(chan *ProducerMessage, client.Config().Producer.Return.SuccessesBufferSize),
 		retries:         make(chan *ProducerMessage),
 		brokers:         make(map[*Broker]*brokerProducer),
 		brokerRefs:      make(map[*brokerProducer]int),
 		txnmgr:          txnmgr,
 		muter:           newPartitionMuter(),
+		zstdDict:        newZstdDictionary(client.Config().Producer.ZstdDictionary),
 		metricsRegistry: newCleanupRegistry(client.Config().MetricRegistry),
 	}
 
@@ -327,6 +491,7 @@ const (
 	endtxn                        // endtxn
 	committxn                     // endtxn
 	aborttxn                      // endtxn
+	flush                         // start waiting for the messages accepted before a Flush call
 )
 
 // ProducerMessage is the collection of elements passed to the Producer in order to send a message.
@@ -336,13 +501,22 @@ type ProducerMessage struct {
 	// StringEncoder and ByteEncoder.
 	Key Encoder
 	// The actual message to store in Kafka. Pre-existing Encoders include
-	// StringEncoder and ByteEncoder.
+	// StringEncoder and ByteEncoder. A nil Value, or an Encoder returning a
+	// nil slice, produces a record with a null value, which is a tombstone
+	// on compacted topics, while an Encoder returning an empty slice such as
+	// ByteEncoder{} produces a record with an empty value.
 	Value Encoder
 
 	// The headers are key-value pairs that are transparently passed
 	// by Kafka between producers and consumers.
 	Headers []RecordHeader
 
+	// Deadline, when not zero, is the time after which the message is worthless.
+	// Once it has passed, the message is returned on Errors with
+	// ErrDeliveryDeadlineExceeded instead of being retried, or sent out of a
+	// retry buffer. A message already sent may still be delivered late.
+	Deadline time.Time
+
 	// This field is used to hold arbitrary data you wish to include so it
 	// will be available when receiving on the Successes and Errors channels.
 	// Sarama completely ignores this field and is only to be used for
@@ -377,6 +551,8 @@ type ProducerMessage struct {
 	sequenceNumber int32
 	producerEpoch  int16
 	hasSequence    bool
+	retryErr       error            // the error that caused the latest retry
+	flushGen       *flushGeneration // the generation Flush callers wait for the message in
 }
 
 const producerMessageOverhead = 26 // the metadata overhead of CRC, flags, etc.
@@ -400,12 +576,37 @@ func (m *ProducerMessage) ByteSize(version int) int {
 	return size
 }
 
+// SetHeader adds the header named key to the message. Kafka allows a header key
+// to be repeated, so headers already named key are kept and sent as well, but
+// Header, ConsumerMessage.Header and NewHeaderHashPartitioner use the value of
+// the last one. The key and value are not copied.
+func (m *ProducerMessage) SetHeader(key, value []byte) {
+	m.Headers = append(m.Headers, RecordHeader{Key: key, Value: value})
+}
+
+// Header returns the value of the last header named key, false if the message
+// has no such header. Keys are compared as raw bytes.
+func (m *ProducerMessage) Header(key []byte) ([]byte, bool) {
+	for i := len(m.Headers) - 1; i >= 0; i-- {
+		if bytes.Equal(m.Headers[i].Key, key) {
+			return m.Headers[i].Value, true
+		}
+	}
+	return nil, false
+}
+
+// expired reports whether the delivery deadline of the message has passed.
+func (m *ProducerMessage) expired() bool {
+	return !m.Deadline.IsZero() && !time.Now().Before(m.Deadline)
+}
+
 func (m *ProducerMessage) clear() {
 	m.flags = 0
 	m.retries = 0
 	m.sequenceNumber = 0
 	m.producerEpoch = 0
 	m.hasSequence = false
+	m.retryErr = nil
 }
 
 // ProducerError is the type of error generated when the producer fails to deliver a message.
@@ -413,6 +614,11 @@ func (m *ProducerMessage) clear() {
 type ProducerError struct {
 	Msg *ProducerMessage
 	Err error
+	// EpochBumped is true if this failure made an idempotent producer reset its
+	// sequence numbers, bumping its epoch or obtaining a new producer ID. The
+	// broker no longer deduplicates across such a reset, so messages retried by
+	// the application may be duplicated.
+	EpochBumped bool
 }
 
 func (pe ProducerError) Error() string {
@@ -436,6 +642,10 @@ func (p *asyncProducer) IsTransactional() bool {
 	return p.txnmgr.isTransactional()
 }
 
+func (p *asyncProducer) ProducerID() (int64, int16) {
+	return p.txnmgr.getProducerID()
+}
+
 func (p *asyncProducer) AddMessageToTxn(msg *ConsumerMessage, groupId string, metadata *string) error {
 	offsets := make(map[string][]*PartitionOffsetMetadata)
 	offsets[msg.Topic] = []*PartitionOffsetMetadata{
@@ -461,6 +671,23 @@ func (p *asyncProducer) AddOffsetsToTxn(offsets map[string][]*PartitionOffsetMet
 	return p.txnmgr.addOffsetsToTxn(offsets, groupId)
 }
 
+func (p *asyncProducer) SendOffsetsAndCommit(ctx context.Context, offsets map[string][]*PartitionOffsetMetadata, groupMetadata *ConsumerGroupMetadata) error {
+	p.txLock.Lock()
+	defer p.txLock.Unlock()
+
+	if !p.IsTransactional() {
+		DebugLogger.Printf("producer/txnmgr [%s] attempt to call SendOffsetsAndCommit on a non-transactional producer\n", p.txnmgr.transactionalID)
+		return ErrNonTransactedProducer
+	}
+
+	if groupMetadata == nil || groupMetadata.GroupID == "" {
+		return ErrInvalidGroupId
+	}
+
+	DebugLogger.Printf("producer/txnmgr [%s] send offsets of group %s to transaction\n", p.txnmgr.transactionalID, groupMetadata.GroupID)
+	return p.txnmgr.sendOffsetsToTxn(ctx, offsets, groupMetadata)
+}
+
 func (p *asyncProducer) TxnStatus() ProducerTxnStatusFlag {
 	return p.txnmgr.currentTxnStatus()
 }
@@ -538,8 +765,11 @@ func (p *asyncProducer) Input() chan<- *ProducerMessage {
 func (p *asyncProducer) Close() error {
 	p.AsyncClose()
 
+	var successesDrained chan none
 	if p.conf.Producer.Return.Successes {
+		successesDrained = make(chan none)
 		go withRecover(func() {
+			defer close(successesDrained)
 			for range p.successes {
 			}
 		})
@@ -554,12 +784,47 @@ func (p *asyncProducer) Close() error {
 		<-p.errors
 	}
 
+	// wait for the buffered successes to be drained too
+	if successesDrained != nil {
+		<-successesDrained
+	}
+
 	if len(pErrs) > 0 {
 		return pErrs
 	}
 	return nil
 }
 
+func (p *asyncProducer) Flush(timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	// route the request through the dispatcher so that messages sent on Input
+	// before calling Flush are accepted before their generation is sealed
+	sealed := make(chan *flushGeneration, 1)
+	var gen *flushGeneration
+	select {
+	case p.input <- &ProducerMessage{flags: flush, Metadata: sealed}:
+		select {
+		case gen = <-sealed:
+		case <-timer.C:
+			return ErrProducerFlushTimeout
+		}
+	case <-timer.C:
+		return ErrProducerFlushTimeout
+	}
+
+	pErrs, err := gen.wait(timer.C)
+	switch {
+	case len(pErrs) == 0:
+		return err
+	case err != nil:
+		return errors.Join(err, pErrs)
+	default:
+		return pErrs
+	}
+}
+
 func (p *asyncProducer) AsyncClose() {
 	go withRecover(p.shutdown)
 }
@@ -569,6 +834,7 @@ func (p *asyncProducer) AsyncClose() {
 func (p *asyncProducer) dispatcher() {
 	handlers := make(map[string]chan<- *ProducerMessage)
 	shuttingDown := false
+	gen := newFlushGeneration(nil)
 
 	for msg := range p.input {
 		if msg == nil {
@@ -590,6 +856,14 @@ func (p *asyncProducer) dispatcher() {
 			continue
 		}
 
+		if msg.flags&flush != 0 {
+			gen.seal()
+			msg.Metadata.(chan *flushGeneration) <- gen
+			gen = newFlushGeneration(gen)
+			p.signalFlush()
+			continue
+		}
+
 		if msg.flags&shutdown != 0 {
 			shuttingDown = true
 			p.inFlight.Done()
@@ -609,6 +883,7 @@ func (p *asyncProducer) dispatcher() {
 				continue
 			}
 			p.inFlight.Add(1)
+			gen.add(msg)
 			// Ignore retried msg, there are already in txn.
 			// Can't produce new record when transaction is not started.
 			if p.IsTransactional() && p.txnmgr.currentTxnStatus()&ProducerTxnFlagInTransaction == 0 {
@@ -622,27 +897,38 @@ func (p *asyncProducer) dispatcher() {
 			msg.safelyApplyInterceptor(interceptor)
 		}
 
-		version := 1
-		if p.conf.Version.IsAtLeast(V0_11_0_0) {
-			version = 2
-		} else if msg.Headers != nil {
-			p.returnError(msg, ConfigurationError("Producing headers requires Kafka at least v0.11"))
-			continue
+		msgs := []*ProducerMessage{msg}
+		if msg.retries == 0 && len(p.conf.Producer.InterceptorsV2) > 0 {
+			var err error
+			if msgs, err = p.applyInterceptorsV2(msg); err != nil {
+				p.returnError(msg, err)
+				continue
+			}
+			p.trackInterceptedMessages(msg, msgs)
 		}
 
-		size := msg.ByteSize(version)
-		if size > p.conf.Producer.MaxMessageBytes {
-			p.returnError(msg, ConfigurationError(fmt.Sprintf("Attempt to produce message larger than configured Producer.MaxMessageBytes: %d > %d", size, p.conf.Producer.MaxMessageBytes)))
-			continue
-		}
+		for _, msg := range msgs {
+			version := 1
+			if p.conf.Version.IsAtLeast(V0_11_0_0) {
+				version = 2
+			} else if msg.Headers != nil {
+				p.returnError(msg, ConfigurationError("Producing headers requires Kafka at least v0.11"))
+				continue
+			}
 
-		handler := handlers[msg.Topic]
-		if handler == nil {
-			handler = p.newTopicProducer(msg.Topic)
-			handlers[msg.Topic] = handler
-		}
+			if err := p.checkMessageSize(msg, version); err != nil {
+				p.returnError(msg, err)
+				continue
+			}
 
-		handler <- msg
+			handler := handlers[msg.Topic]
+			if handler == nil {
+				handler = p.newTopicProducer(msg.Topic)
+				handlers[msg.Topic] = handler
+			}
+
+			handler <- msg
+		}
 	}
 
 	for _, handler := range handlers {
@@ -650,6 +936,76 @@ func (p *asyncProducer) dispatcher() {
 	}
 }
 
+// applyInterceptorsV2 runs msg through the Producer.InterceptorsV2 chain, each
+// interceptor being called with every message returned by the previous one.
+// A message returned several times is only published once, as its state
+// cannot be shared by several deliveries.
+func (p *asyncProducer) applyInterceptorsV2(msg *ProducerMessage) ([]*ProducerMessage, error) {
+	msgs := []*ProducerMessage{msg}
+	for _, interceptor := range p.conf.Producer.InterceptorsV2 {
+		var next []*ProducerMessage
+		seen := make(map[*ProducerMessage]bool)
+		for _, m := range msgs {
+			out, err := m.safelyApplyInterceptorV2(interceptor)
+			if err != nil {
+				return nil, err
+			}
+			for _, o := range out {
+				if o != nil && !seen[o] {
+					seen[o] = true
+					next = append(next, o)
+				}
+			}
+		}
+		msgs = next
+	}
+	return msgs, nil
+}
+
+// trackInterceptedMessages accounts for the messages added and dropped by the
+// Producer.InterceptorsV2 chain in place of msg, which is already in flight.
+func (p *asyncProducer) trackInterceptedMessages(msg *ProducerMessage, msgs []*ProducerMessage) {
+	kept := false
+	for _, m := range msgs {
+		if m == msg {
+			kept = true
+			continue
+		}
+		// the message may be a copy of msg, it must not share its state
+		m.clear()
+		m.expectation = nil
+		p.inFlight.Add(1)
+		if msg.flushGen != nil {
+			msg.flushGen.add(m)
+		}
+	}
+	if kept {
+		return
+	}
+
+	msg.flushed(nil)
+	if msg.expectation != nil {
+		// unblock the SyncProducer waiting for the dropped message
+		msg.Partition, msg.Offset = -1, -1
+		msg.expectation <- nil
+	}
+	p.inFlight.Done()
+}
+
+// checkMessageSize rejects a message before it is batched if its uncompressed
+// size, including the record overhead, exceeds Producer.MaxMessageBytes. Messages
+// that could never fit in a request are rejected even if the check is skipped.
+func (p *asyncProducer) checkMessageSize(msg *ProducerMessage, version int) error {
+	size := msg.ByteSize(version)
+	if size >= int(MaxRequestSize-(10*1024)) {
+		return fmt.Errorf("%w: %d bytes exceeds MaxRequestSize %d", ErrMessageSizeTooLarge, size, MaxRequestSize)
+	}
+	if !p.conf.Producer.SkipMessageSizeCheck && size > p.conf.Producer.MaxMessageBytes {
+		return fmt.Errorf("%w: %d bytes exceeds Producer.MaxMessageBytes %d", ErrMessageSizeTooLarge, size, p.conf.Producer.MaxMessageBytes)
+	}
+	return nil
+}
+
 // one per topic
 // partitions messages, then dispatches them by partition
 type topicProducer struct {
@@ -781,16 +1137,19 @@ func (p *asyncProducer) newPartitionProducer(topic string, partition int32) chan
 	return input
 }
 
-func (pp *partitionProducer) backoff(retries int) {
-	pp.parent.backoff(retries)
+func (pp *partitionProducer) backoff(retries int, err error) {
+	pp.parent.backoff(retries, err)
 }
 
-func (p *asyncProducer) backoff(retries int) {
+func (p *asyncProducer) backoff(retries int, err error) {
 	var backoff time.Duration
-	if p.conf.Producer.Retry.BackoffFunc != nil {
-		maxRetries := p.conf.Producer.Retry.Max
+	maxRetries := p.conf.Producer.Retry.Max
+	switch {
+	case p.conf.Producer.Retry.BackoffFuncWithError != nil:
+		backoff = p.conf.Producer.Retry.BackoffFuncWithError(retries, maxRetries, err)
+	case p.conf.Producer.Retry.BackoffFunc != nil:
 		backoff = p.conf.Producer.Retry.BackoffFunc(retries, maxRetries)
-	} else {
+	default:
 		backoff = p.conf.Producer.Retry.Backoff
 	}
 	if backoff > 0 {
@@ -802,7 +1161,7 @@ func (pp *partitionProducer) updateLeaderIfBrokerProducerIsNil(msg *ProducerMess
 	if pp.brokerProducer == nil {
 		if err := pp.updateLeader(); err != nil {
 			pp.parent.returnError(msg, err)
-			pp.backoff(msg.retries)
+			pp.backoff(msg.retries, err)
 			return err
 		}
 		Logger.Printf("producer/leader/%s/%d selected broker %d\n", pp.topic, pp.partition, pp.leader.ID())
@@ -846,7 +1205,7 @@ func (pp *partitionProducer) dispatch() {
 			}
 			// a new, higher, retry level; handle it and then back off
 			pp.newHighWatermark(msg.retries)
-			pp.backoff(msg.retries)
+			pp.backoff(msg.retries, msg.retryErr)
 		} else if pp.highWatermark > 0 {
 			// we are retrying something (else highWatermark would be 0) but this message is not a *new* retry level
 			if msg.retries < pp.highWatermark {
@@ -883,6 +1242,12 @@ func (pp *partitionProducer) dispatch() {
 			msg.hasSequence = true
 		}
 
+		if msg.retries > 0 && msg.expired() {
+			// the retry backoff may have outlived the deadline
+			pp.parent.returnError(msg, ErrDeliveryDeadlineExceeded)
+			continue
+		}
+
 		if pp.parent.IsTransactional() {
 			pp.parent.txnmgr.maybeAddPartitionToCurrentTxn(pp.topic, pp.partition)
 		}
@@ -921,6 +1286,10 @@ func (pp *partitionProducer) flushRetryBuffers() {
 		}
 
 		for _, msg := range pp.retryState[pp.highWatermark].buf {
+			if msg.expired() {
+				pp.parent.returnError(msg, ErrDeliveryDeadlineExceeded)
+				continue
+			}
 			if pp.parent.conf.Producer.Idempotent && msg.retries == 0 && msg.flags == 0 && !msg.hasSequence {
 				msg.sequenceNumber, msg.producerEpoch = pp.parent.txnmgr.getAndIncrementSequenceNumber(msg.Topic, msg.Partition)
 				msg.hasSequence = true
@@ -973,6 +1342,7 @@ func (p *asyncProducer) newBrokerProducer(broker *Broker) *brokerProducer {
 		input:             input,
 		output:            bridge,
 		responses:         responses,
+		flush:             make(chan none, 1),
 		accumulatingBatch: newProduceSet(p),
 		currentRetries:    make(map[string]map[int32]error),
 	}
@@ -1087,6 +1457,8 @@ type brokerProducer struct {
 	responses <-chan *brokerProducerResponse
 	abandoned chan struct{}
 
+	flush chan none
+
 	accumulatingBatch *produceSet
 	flushingBatch     *produceSet // batch that has been muted and is ready to send
 	timer             *time.Timer
@@ -1180,12 +1552,19 @@ func (bp *brokerProducer) run() {
 				bp.parent.returnError(msg, err)
 				continue
 			}
+			if msg.flushGen != nil && msg.flushGen.sealed.Load() {
+				// a Flush call is waiting for the message
+				bp.timerFired = true
+			}
 
 			if bp.parent.conf.Producer.Flush.Frequency > 0 && bp.timer == nil {
 				bp.timer = time.NewTimer(bp.parent.conf.Producer.Flush.Frequency)
 			}
 		case <-timerChan:
 			bp.timerFired = true
+		case <-bp.flush:
+			// a Flush call is waiting for buffered messages, send them right away
+			bp.timerFired = !bp.accumulatingBatch.empty()
 		case output <- bp.flushingBatch:
 			bp.flushingBatch = nil
 		case response, ok := <-bp.responses:
@@ -1352,13 +1731,29 @@ func (bp *brokerProducer) handleSuccess(sent *produceSet, response *ProduceRespo
 
 		block := response.GetBlock(topic, partition)
 		if block == nil {
+			bp.partitionError(topic, partition, ErrIncompleteResponse)
 			bp.parent.returnErrors(pSet.msgs, ErrIncompleteResponse)
 			return
 		}
 
+		if block.Err != ErrNoError && block.Err != ErrDuplicateSequenceNumber {
+			bp.partitionError(topic, partition, block.Err)
+		}
+
+		if bp.parent.queueResync(topic, partition, pSet, block.Err) {
+			Logger.Printf("producer/broker/%d state change to [resyncing] on %s/%d because %v after a leader change\n",
+				bp.broker.ID(), topic, partition, block.Err)
+			if keepMuted[topic] == nil {
+				keepMuted[topic] = make(map[int32]struct{})
+			}
+			keepMuted[topic][partition] = struct{}{}
+			return
+		}
+
 		switch block.Err {
 		// Success
 		case ErrNoError:
+			bp.parent.ackLeaderEpoch(topic, partition)
 			if bp.parent.conf.Version.IsAtLeast(V0_10_0_0) && !block.Timestamp.IsZero() {
 				for _, msg := range pSet.msgs {
 					msg.Timestamp = block.Timestamp
@@ -1370,6 +1765,7 @@ func (bp *brokerProducer) handleSuccess(sent *produceSet, response *ProduceRespo
 			bp.parent.returnSuccesses(pSet.msgs)
 		// Duplicate
 		case ErrDuplicateSequenceNumber:
+			bp.parent.ackLeaderEpoch(topic, partition)
 			bp.parent.returnSuccesses(pSet.msgs)
 		// Retriable errors
 		case ErrInvalidMessage, ErrUnknownTopicOrPartition, ErrLeaderNotAvailable, ErrNotLeaderForPartition,
@@ -1455,14 +1851,31 @@ func (p *asyncProducer) retryBatch(topic string, partition int32, pSet *partitio
 			}
 			return
 		}
+	}
+	if slices.ContainsFunc(pSet.msgs, (*ProducerMessage).expired) {
+		// the batch is resent as a whole, so it fails as a whole
+		for _, msg := range pSet.msgs {
+			if msg.expired() {
+				p.returnError(msg, ErrDeliveryDeadlineExceeded)
+			} else {
+				p.returnError(msg, retryErr)
+			}
+		}
+		if alreadyMuted {
+			p.muter.unmute(produceSet)
+		}
+		return
+	}
+	for _, msg := range pSet.msgs {
 		msg.retries++
+		msg.retryErr = retryErr
 	}
 
 	// honor Producer.Retry.Backoff between retry attempts (#2469); the
 	// non-idempotent path gets this from partitionProducer.dispatch, but
 	// retryBatch dispatches the produceSet directly to the broker
 	if len(pSet.msgs) > 0 {
-		p.backoff(pSet.msgs[0].retries)
+		p.backoff(pSet.msgs[0].retries, retryErr)
 	}
 
 	// it's expected that a metadata refresh has been requested prior to calling retryBatch
@@ -1490,10 +1903,132 @@ func (p *asyncProducer) retryBatch(topic string, partition int32, pSet *partitio
 	p.unrefBrokerProducer(leader, bp)
 }
 
+// leaderState returns the leader epoch state of the partition.
+// Requires: p.leaderStatesLock held.
+func (p *asyncProducer) leaderState(topic string, partition int32) *partitionLeaderState {
+	if p.leaderStates == nil {
+		p.leaderStates = make(map[topicPartition]*partitionLeaderState)
+	}
+	tp := topicPartition{topic: topic, partition: partition}
+	state := p.leaderStates[tp]
+	if state == nil {
+		state = &partitionLeaderState{acked: invalidLeaderEpoch, resynced: invalidLeaderEpoch}
+		p.leaderStates[tp] = state
+	}
+	return state
+}
+
+// ackLeaderEpoch records the leader epoch under which a batch of an
+// idempotent producer was acknowledged on the partition.
+func (p *asyncProducer) ackLeaderEpoch(topic string, partition int32) {
+	if !p.conf.Producer.Idempotent {
+		return
+	}
+	_, leaderEpoch, err := p.client.LeaderAndEpoch(topic, partition)
+	if err != nil {
+		return
+	}
+	p.leaderStatesLock.Lock()
+	defer p.leaderStatesLock.Unlock()
+	p.leaderState(topic, partition).acked = leaderEpoch
+}
+
+// queueResync queues pSet to be resynced when it was rejected because of its
+// sequence numbers by a leader elected since the last acknowledged batch of
+// the partition. Such a leader, e.g. after an unclean election, may not know
+// the producer state yet, so that the batch can be resequenced rather than
+// failed. A partition is only resynced once per leader epoch, the batches
+// rejected meanwhile being resubmitted in order by the same resync.
+func (p *asyncProducer) queueResync(topic string, partition int32, pSet *partitionSet, err KError) bool {
+	if !p.conf.Producer.Idempotent || p.IsTransactional() || p.conf.Producer.Retry.Max <= 0 {
+		return false
+	}
+	if err != ErrUnknownProducerID && err != ErrOutOfOrderSequenceNumber {
+		return false
+	}
+	_, leaderEpoch, leaderErr := p.client.LeaderAndEpoch(topic, partition)
+	if leaderErr != nil {
+		return false
+	}
+
+	p.leaderStatesLock.Lock()
+	defer p.leaderStatesLock.Unlock()
+	state := p.leaderState(topic, partition)
+	switch {
+	case state.resyncing:
+		state.pending = append(state.pending, pSet)
+		return true
+	case state.acked < 0 || leaderEpoch <= state.acked || leaderEpoch == state.resynced:
+		return false
+	}
+	state.resynced = leaderEpoch
+	state.resyncing = true
+	state.pending = append(state.pending, pSet)
+	go withRecover(func() { p.resyncPartition(topic, partition, err) })
+	return true
+}
+
+// resyncPartition retries the batches rejected by a new partition leader
+// which lost the producer state: the epoch is bumped, so that the broker
+// starts tracking the producer again, and the batches are resequenced from
+// the new epoch.
+func (p *asyncProducer) resyncPartition(topic string, partition int32, retryErr error) {
+	p.resyncLock.Lock()
+	defer p.resyncLock.Unlock()
+
+	bumped := true
+	for {
+		p.leaderStatesLock.Lock()
+		state := p.leaderState(topic, partition)
+		if len(state.pending) == 0 {
+			state.resyncing = false
+			p.leaderStatesLock.Unlock()
+			return
+		}
+		pSet := state.pending[0]
+		state.pending = state.pending[1:]
+		p.leaderStatesLock.Unlock()
+
+		// the epoch is only bumped once, or not at all when the resync of
+		// another partition already bumped it since the batch was sent
+		if _, producerEpoch := p.txnmgr.getProducerID(); bumped && pSet.msgs[0].producerEpoch == producerEpoch {
+			bumped = p.bumpIdempotentProducerEpoch()
+		}
+		if !bumped {
+			p.failResync(topic, partition, pSet, retryErr)
+			continue
+		}
+		p.resyncBatch(topic, partition, pSet, retryErr)
+	}
+}
+
+// resyncBatch resequences pSet from the current epoch and retries it.
+func (p *asyncProducer) resyncBatch(topic string, partition int32, pSet *partitionSet, retryErr error) {
+	producerID, producerEpoch := p.txnmgr.getProducerID()
+	resynced := newProduceSetWithMeta(p, producerID, producerEpoch)
+	for _, msg := range pSet.msgs {
+		msg.sequenceNumber, msg.producerEpoch = p.txnmgr.getAndIncrementSequenceNumber(topic, partition)
+		if err := resynced.add(msg); err != nil {
+			p.failResync(topic, partition, pSet, err)
+			return
+		}
+	}
+	p.retryBatch(topic, partition, resynced.msgs[topic][partition], retryErr, true)
+}
+
+// failResync fails the muted batch pSet of a resync.
+func (p *asyncProducer) failResync(topic string, partition int32, pSet *partitionSet, err error) {
+	failed := newProduceSet(p)
+	failed.msgs[topic] = map[int32]*partitionSet{partition: pSet}
+	p.returnErrors(pSet.msgs, err)
+	p.muter.unmute(failed)
+}
+
 func (bp *brokerProducer) handleError(sent *produceSet, err error) {
 	var target PacketEncodingError
 	if errors.As(err, &target) {
 		sent.eachPartition(func(topic string, partition int32, pSet *partitionSet) {
+			bp.partitionError(topic, partition, err)
 			bp.parent.returnErrors(pSet.msgs, err)
 		})
 		bp.parent.muter.unmute(sent)
@@ -1519,6 +2054,7 @@ func (bp *brokerProducer) handleError(sent *produceSet, err error) {
 		}
 		keepMuted := make(map[string]map[int32]struct{})
 		sent.eachPartition(func(topic string, partition int32, pSet *partitionSet) {
+			bp.partitionError(topic, partition, err)
 			// keep partition marked as in-flight during retry (connection error)
 			if bp.currentRetries[topic] == nil {
 				bp.currentRetries[topic] = make(map[int32]error)
@@ -1550,6 +2086,14 @@ func (bp *brokerProducer) handleError(sent *produceSet, err error) {
 	}
 }
 
+// partitionError reports a failed batch of the partition to
+// Producer.OnPartitionError.
+func (bp *brokerProducer) partitionError(topic string, partition int32, err error) {
+	if onError := bp.parent.conf.Producer.OnPartitionError; onError != nil {
+		onError(topic, partition, err)
+	}
+}
+
 // singleton
 // effectively a "bridge" between the flushers and the dispatcher in order to avoid deadlock
 // based on https://godoc.org/github.com/eapache/channels#InfiniteChannel
@@ -1569,19 +2113,44 @@ func (p *asyncProducer) retryHandler() {
 		version = 2
 	}
 
+	bufferFull := func(length int, byteSize int64) bool {
+		return (maxBufferLength > 0 && length >= maxBufferLength) || (maxBufferBytes > 0 && byteSize >= maxBufferBytes)
+	}
+
+	var bufferBytes, bufferLength metrics.Gauge
+	if p.metricsRegistry != nil {
+		bufferBytes = metrics.GetOrRegisterGauge("producer-retry-buffer-bytes", p.metricsRegistry)
+		bufferLength = metrics.GetOrRegisterGauge("producer-retry-buffer-length", p.metricsRegistry)
+	}
+
 	var currentByteSize int64
 	var msg *ProducerMessage
 	var buf queue.Queue[*ProducerMessage]
 
+	removeHead := func() *ProducerMessage {
+		head := buf.Remove()
+		currentByteSize -= int64(head.ByteSize(version))
+		return head
+	}
+
 	for {
+		// fail the messages whose deadline passed while they were buffered
+		for buf.Length() > 0 && buf.Peek().expired() {
+			p.returnError(removeHead(), ErrDeliveryDeadlineExceeded)
+		}
+
+		if bufferBytes != nil {
+			bufferBytes.Update(currentByteSize)
+			bufferLength.Update(int64(buf.Length()))
+		}
+
 		if buf.Length() == 0 {
 			msg = <-p.retries
 		} else {
 			select {
 			case msg = <-p.retries:
 			case p.input <- buf.Peek():
-				msgToRemove := buf.Remove()
-				currentByteSize -= int64(msgToRemove.ByteSize(version))
+				removeHead()
 				continue
 			}
 		}
@@ -1590,10 +2159,27 @@ func (p *asyncProducer) retryHandler() {
 			return
 		}
 
+		msgSize := int64(msg.ByteSize(version))
+		if p.conf.Producer.Retry.BufferOverflowPolicy == RetryBufferDropNewest && msg.flags == 0 &&
+			bufferFull(buf.Length()+1, currentByteSize+msgSize) {
+			// make room by handing the oldest message over if the dispatcher is ready for it
+			if buf.Length() > 0 {
+				select {
+				case p.input <- buf.Peek():
+					removeHead()
+				default:
+				}
+			}
+			if bufferFull(buf.Length()+1, currentByteSize+msgSize) {
+				p.returnError(msg, ErrProducerRetryBufferOverflow)
+				continue
+			}
+		}
+
 		buf.Add(msg)
-		currentByteSize += int64(msg.ByteSize(version))
+		currentByteSize += msgSize
 
-		if (maxBufferLength <= 0 || buf.Length() < maxBufferLength) && (maxBufferBytes <= 0 || currentByteSize < maxBufferBytes) {
+		if !bufferFull(buf.Length(), currentByteSize) {
 			continue
 		}
 
@@ -1601,11 +2187,9 @@ func (p *asyncProducer) retryHandler() {
 		if msgToHandle.flags == 0 {
 			select {
 			case p.input <- msgToHandle:
-				buf.Remove()
-				currentByteSize -= int64(msgToHandle.ByteSize(version))
+				removeHead()
 			default:
-				buf.Remove()
-				currentByteSize -= int64(msgToHandle.ByteSize(version))
+				removeHead()
 				p.returnError(msgToHandle, ErrProducerRetryBufferOverflow)
 			}
 		}
@@ -1627,6 +2211,7 @@ func (p *asyncProducer) shutdown() {
 	}
 
 	p.muter.close()
+	p.zstdDict.close()
 
 	close(p.input)
 	close(p.retries)
@@ -1636,20 +2221,24 @@ func (p *asyncProducer) shutdown() {
 	p.metricsRegistry.UnregisterAll()
 }
 
-func (p *asyncProducer) bumpIdempotentProducerEpoch() {
+// bumpIdempotentProducerEpoch resets the sequence numbers, bumping the epoch
+// or requesting a new producer ID once the epoch is exhausted. It returns
+// false if no new producer ID could be obtained.
+func (p *asyncProducer) bumpIdempotentProducerEpoch() bool {
 	_, epoch := p.txnmgr.getProducerID()
 	if epoch == math.MaxInt16 {
 		Logger.Println("producer/txnmanager epoch exhausted, requesting new producer ID")
 		txnmgr, err := newTransactionManager(p.conf, p.client)
 		if err != nil {
 			Logger.Println(err)
-			return
+			return false
 		}
 
-		p.txnmgr = txnmgr
+		p.txnmgr.setProducerID(txnmgr.getProducerID())
 	} else {
 		p.txnmgr.bumpEpoch()
 	}
+	return true
 }
 
 func (p *asyncProducer) maybeTransitionToErrorState(err error) error {
@@ -1671,13 +2260,15 @@ func (p *asyncProducer) returnError(msg *ProducerMessage, err error) {
 	}
 	// We need to reset the producer ID epoch if we set a sequence number on it, because the broker
 	// will never see a message with this number, so we can never continue the sequence.
+	epochBumped := false
 	if !p.IsTransactional() && msg.hasSequence {
 		Logger.Printf("producer/txnmanager rolling over epoch due to publish failure on %s/%d", msg.Topic, msg.Partition)
-		p.bumpIdempotentProducerEpoch()
+		epochBumped = p.bumpIdempotentProducerEpoch()
 	}
 
 	msg.clear()
-	pErr := &ProducerError{Msg: msg, Err: err}
+	pErr := &ProducerError{Msg: msg, Err: err, EpochBumped: epochBumped}
+	msg.flushed(pErr)
 	if p.conf.Producer.Return.Errors {
 		p.errors <- pErr
 	} else {
@@ -1694,6 +2285,7 @@ func (p *asyncProducer) returnErrors(batch []*ProducerMessage, err error) {
 
 func (p *asyncProducer) returnSuccesses(batch []*ProducerMessage) {
 	for _, msg := range batch {
+		msg.flushed(nil)
 		if p.conf.Producer.Return.Successes {
 			msg.clear()
 			p.successes <- msg
@@ -1705,8 +2297,11 @@ func (p *asyncProducer) returnSuccesses(batch []*ProducerMessage) {
 func (p *asyncProducer) retryMessage(msg *ProducerMessage, err error) {
 	if msg.retries >= p.conf.Producer.Retry.Max {
 		p.returnError(msg, err)
+	} else if msg.expired() {
+		p.returnError(msg, ErrDeliveryDeadlineExceeded)
 	} else {
 		msg.retries++
+		msg.retryErr = err
 		p.retries <- msg
 	}
 }
@@ -1734,6 +2329,21 @@ func (p *asyncProducer) getBrokerProducer(broker *Broker) *brokerProducer {
 	return bp
 }
 
+// signalFlush asks every broker producer to send its buffered messages, which
+// a Flush call may be waiting for.
+func (p *asyncProducer) signalFlush() {
+	p.brokerLock.Lock()
+	defer p.brokerLock.Unlock()
+
+	for bp := range p.brokerRefs {
+		select {
+		case bp.flush <- none{}:
+		default:
+			// a flush signal is already pending for this broker
+		}
+	}
+}
+
 func (p *asyncProducer) unrefBrokerProducer(broker *Broker, bp *brokerProducer) {
 	p.brokerLock.Lock()
 	defer p.brokerLock.Unlock()
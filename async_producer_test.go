@@ -3,6 +3,7 @@
 package sarama
 
 import (
+	"context"
 	"errors"
 	"log"
 	"math"
@@ -15,6 +16,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/rcrowley/go-metrics"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/goleak"
@@ -219,6 +221,319 @@ func TestAsyncProducerMultipleFlushes(t *testing.T) {
 	seedBroker.Close()
 }
 
+func TestAsyncProducerFlush(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	leader := NewMockBroker(t, 2)
+	defer seedBroker.Close()
+	defer leader.Close()
+
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker(leader.Addr(), leader.BrokerID())
+	metadataResponse.AddTopicPartition("my_topic", 0, leader.BrokerID(), nil, nil, nil, ErrNoError)
+	seedBroker.Returns(metadataResponse)
+
+	prodSuccess := new(ProduceResponse)
+	prodSuccess.AddTopicPartition("my_topic", 0, ErrNoError)
+	leader.SetHandlerByMap(map[string]MockResponse{
+		"ProduceRequest": NewMockWrapper(prodSuccess),
+	})
+
+	config := NewTestConfig()
+	config.Producer.Flush.Messages = 100
+	config.Producer.Flush.Frequency = time.Hour
+	config.Producer.Return.Successes = true
+	producer, err := NewAsyncProducer([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		producer.Input() <- &ProducerMessage{Topic: "my_topic", Key: nil, Value: StringEncoder(TestMessage)}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := producer.Flush(5 * time.Second); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	expectResultsWithTimeout(t, producer, 5, 0, 5*time.Second)
+	wg.Wait()
+
+	closeProducer(t, producer)
+}
+
+func TestAsyncProducerSuccessesBufferSize(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	leader := NewMockBroker(t, 2)
+	defer seedBroker.Close()
+	defer leader.Close()
+
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker(leader.Addr(), leader.BrokerID())
+	metadataResponse.AddTopicPartition("my_topic", 0, leader.BrokerID(), nil, nil, nil, ErrNoError)
+	metadataResponse.AddTopicPartition("my_topic", 1, leader.BrokerID(), nil, nil, nil, ErrNoError)
+	seedBroker.Returns(metadataResponse)
+
+	prodSuccess := new(ProduceResponse)
+	prodSuccess.AddTopicPartition("my_topic", 0, ErrNoError)
+	prodSuccess.AddTopicPartition("my_topic", 1, ErrNoError)
+	leader.SetHandlerByMap(map[string]MockResponse{
+		"ProduceRequest": NewMockWrapper(prodSuccess),
+	})
+
+	config := NewTestConfig()
+	config.Producer.Flush.Messages = 5
+	config.Producer.Partitioner = NewManualPartitioner
+	config.Producer.Return.Successes = true
+	config.Producer.Return.SuccessesBufferSize = 10
+	producer, err := NewAsyncProducer([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the successes of both partitions are delivered without being read
+	for i := 0; i < 10; i++ {
+		producer.Input() <- &ProducerMessage{Topic: "my_topic", Partition: int32(i % 2), Value: StringEncoder(TestMessage)}
+	}
+	timeout := time.After(5 * time.Second)
+	for len(producer.Successes()) < 10 {
+		select {
+		case <-timeout:
+			t.Fatalf("Expected 10 buffered successes, found %d", len(producer.Successes()))
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	// the buffered successes remain readable until the channel is closed
+	producer.AsyncClose()
+	successes := 0
+	for range producer.Successes() {
+		successes++
+	}
+	if successes != 10 {
+		t.Errorf("Expected 10 successes, found %d", successes)
+	}
+	for err := range producer.Errors() {
+		t.Error(err)
+	}
+}
+
+func TestFlushGenerationWaitsForSealedMessagesOnly(t *testing.T) {
+	gen := newFlushGeneration(nil)
+	early, before, after := &ProducerMessage{}, &ProducerMessage{}, &ProducerMessage{}
+	gen.add(early)
+	gen.add(before)
+
+	// errors of the messages failing before the Flush call are not reported
+	early.flushed(&ProducerError{Msg: early, Err: ErrOutOfBrokers})
+
+	gen.seal()
+	sealed, gen := gen, newFlushGeneration(gen)
+	gen.add(after)
+	after.flushed(&ProducerError{Msg: after, Err: ErrOutOfBrokers})
+	select {
+	case <-sealed.done:
+		t.Fatal("Flush completed before the message it waits for")
+	default:
+	}
+
+	pErr := &ProducerError{Msg: before, Err: ErrMessageSizeTooLarge}
+	before.flushed(pErr)
+	errs, err := sealed.wait(time.After(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 1 || errs[0] != pErr {
+		t.Errorf("expected only the error of the flushed message, got %v", errs)
+	}
+	if before.flushGen != nil {
+		t.Error("acknowledged message still counted in its flush generation")
+	}
+}
+
+func TestFlushGenerationWaitsForPreviousGenerations(t *testing.T) {
+	first := newFlushGeneration(nil)
+	msg := &ProducerMessage{}
+	first.add(msg)
+	first.seal()
+
+	second := newFlushGeneration(first)
+	second.seal()
+	if _, err := second.wait(time.After(10 * time.Millisecond)); !errors.Is(err, ErrProducerFlushTimeout) {
+		t.Fatalf("expected ErrProducerFlushTimeout, got %v", err)
+	}
+
+	msg.flushed(nil)
+	if _, err := second.wait(time.After(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAsyncProducerFlushErrors(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	leader := NewMockBroker(t, 2)
+	defer seedBroker.Close()
+	defer leader.Close()
+
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker(leader.Addr(), leader.BrokerID())
+	metadataResponse.AddTopicPartition("my_topic", 0, leader.BrokerID(), nil, nil, nil, ErrNoError)
+	seedBroker.Returns(metadataResponse)
+
+	prodFailure := new(ProduceResponse)
+	prodFailure.AddTopicPartition("my_topic", 0, ErrMessageSizeTooLarge)
+	leader.SetHandlerByMap(map[string]MockResponse{
+		"ProduceRequest": NewMockWrapper(prodFailure),
+	})
+
+	config := NewTestConfig()
+	config.Producer.Flush.Messages = 100
+	config.Producer.Flush.Frequency = time.Hour
+	producer, err := NewAsyncProducer([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		producer.Input() <- &ProducerMessage{Topic: "my_topic", Key: nil, Value: StringEncoder(TestMessage)}
+	}
+
+	flushed := make(chan error, 1)
+	go func() { flushed <- producer.Flush(5 * time.Second) }()
+	expectResultsWithTimeout(t, producer, 0, 3, 5*time.Second)
+
+	var pErrs ProducerErrors
+	if err := <-flushed; !errors.As(err, &pErrs) || errors.Is(err, ErrProducerFlushTimeout) {
+		t.Fatalf("expected ProducerErrors, got %v", err)
+	}
+	if len(pErrs) != 3 {
+		t.Errorf("expected 3 errors, got %d", len(pErrs))
+	}
+	for _, pErr := range pErrs {
+		if !errors.Is(pErr, ErrMessageSizeTooLarge) {
+			t.Error(pErr)
+		}
+	}
+
+	closeProducer(t, producer)
+}
+
+func TestAsyncProducerFlushTimeout(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	leader := NewMockBroker(t, 2)
+	defer seedBroker.Close()
+	defer leader.Close()
+
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker(leader.Addr(), leader.BrokerID())
+	metadataResponse.AddTopicPartition("my_topic", 0, leader.BrokerID(), nil, nil, nil, ErrNoError)
+	seedBroker.Returns(metadataResponse)
+
+	prodSuccess := new(ProduceResponse)
+	prodSuccess.AddTopicPartition("my_topic", 0, ErrNoError)
+	leader.SetLatency(500 * time.Millisecond)
+	leader.Returns(prodSuccess)
+
+	config := NewTestConfig()
+	config.Producer.Flush.Messages = 100
+	config.Producer.Flush.Frequency = time.Hour
+	config.Producer.Return.Successes = true
+	producer, err := NewAsyncProducer([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	producer.Input() <- &ProducerMessage{Topic: "my_topic", Key: nil, Value: StringEncoder(TestMessage)}
+	if err := producer.Flush(50 * time.Millisecond); !errors.Is(err, ErrProducerFlushTimeout) {
+		t.Errorf("expected ErrProducerFlushTimeout, got %v", err)
+	}
+	expectResultsWithTimeout(t, producer, 1, 0, 5*time.Second)
+
+	closeProducer(t, producer)
+}
+
+func TestAsyncProducerRejectsOversizedMessage(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	leader := NewMockBroker(t, 2)
+	defer seedBroker.Close()
+	defer leader.Close()
+
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker(leader.Addr(), leader.BrokerID())
+	metadataResponse.AddTopicPartition("my_topic", 0, leader.BrokerID(), nil, nil, nil, ErrNoError)
+	seedBroker.Returns(metadataResponse)
+
+	prodSuccess := new(ProduceResponse)
+	prodSuccess.AddTopicPartition("my_topic", 0, ErrNoError)
+	leader.Returns(prodSuccess)
+
+	config := NewTestConfig()
+	config.Producer.MaxMessageBytes = 100
+	config.Producer.Flush.Messages = 2
+	config.Producer.Return.Successes = true
+	producer, err := NewAsyncProducer([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oversized := &ProducerMessage{Topic: "my_topic", Value: ByteEncoder(make([]byte, 200))}
+	producer.Input() <- oversized
+	select {
+	case pErr := <-producer.Errors():
+		if pErr.Msg != oversized || !errors.Is(pErr, ErrMessageSizeTooLarge) {
+			t.Errorf("expected ErrMessageSizeTooLarge for the oversized message, got %v", pErr)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the oversized message to be rejected")
+	}
+
+	// the rejected message must not have been added to the batch
+	for i := 0; i < 2; i++ {
+		producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}
+	}
+	expectResults(t, producer, 2, 0)
+
+	closeProducer(t, producer)
+}
+
+func TestAsyncProducerSkipMessageSizeCheck(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	leader := NewMockBroker(t, 2)
+	defer seedBroker.Close()
+	defer leader.Close()
+
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker(leader.Addr(), leader.BrokerID())
+	metadataResponse.AddTopicPartition("my_topic", 0, leader.BrokerID(), nil, nil, nil, ErrNoError)
+	seedBroker.Returns(metadataResponse)
+
+	prodFailure := new(ProduceResponse)
+	prodFailure.AddTopicPartition("my_topic", 0, ErrMessageSizeTooLarge)
+	leader.Returns(prodFailure)
+
+	config := NewTestConfig()
+	config.Producer.MaxMessageBytes = 100
+	config.Producer.SkipMessageSizeCheck = true
+	producer, err := NewAsyncProducer([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: ByteEncoder(make([]byte, 200))}
+	expectResults(t, producer, 0, 1)
+
+	if len(leader.History()) == 0 {
+		t.Error("expected the oversized message to be sent to the broker")
+	}
+
+	closeProducer(t, producer)
+}
+
 func TestAsyncProducerMultipleBrokers(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	leader0 := NewMockBroker(t, 2)
@@ -435,6 +750,123 @@ func TestAsyncProducerRecoveryWithRetriesDisabled(t *testing.T) {
 	})
 }
 
+// The broker assigned timestamp of topics configured with LogAppendTime is
+// returned with the successes, while CreateTime keeps the producer's one.
+func TestAsyncProducerLogAppendTime(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	logAppendTime := time.Unix(1700000000, 123*int64(time.Millisecond))
+	createTime := time.Unix(1600000000, 456*int64(time.Millisecond))
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetLeader("my_topic", 0, seedBroker.BrokerID()).
+			SetLeader("my_topic", 1, seedBroker.BrokerID()),
+		"ProduceRequest": NewMockProduceResponse(t).
+			SetLogAppendTime("my_topic", 0, logAppendTime),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_1_0_0
+	config.Producer.Flush.Messages = 2
+	config.Producer.Return.Successes = true
+	config.Producer.Partitioner = NewManualPartitioner
+	producer, err := NewAsyncProducer([]string{seedBroker.Addr()}, config)
+	require.NoError(t, err)
+	defer closeProducer(t, producer)
+
+	producer.Input() <- &ProducerMessage{Topic: "my_topic", Partition: 0, Value: StringEncoder(TestMessage), Timestamp: createTime}
+	producer.Input() <- &ProducerMessage{Topic: "my_topic", Partition: 1, Value: StringEncoder(TestMessage), Timestamp: createTime}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case msg := <-producer.Successes():
+			if msg.Partition == 0 {
+				require.True(t, logAppendTime.Equal(msg.Timestamp), "expected the log append time, got %v", msg.Timestamp)
+			} else {
+				require.True(t, createTime.Equal(msg.Timestamp), "expected the create time, got %v", msg.Timestamp)
+			}
+		case err := <-producer.Errors():
+			t.Fatal(err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a success")
+		}
+	}
+}
+
+// Messages spread thinly over many partitions are flushed as soon as one of
+// them holds Flush.MaxMessagesPerPartition messages.
+func TestAsyncProducerMaxMessagesPerPartition(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	metadataResponse := NewMockMetadataResponse(t).
+		SetBroker(seedBroker.Addr(), seedBroker.BrokerID())
+	for partition := int32(0); partition < 4; partition++ {
+		metadataResponse.SetLeader("my_topic", partition, seedBroker.BrokerID())
+	}
+	produceResponse := NewMockProduceResponse(t)
+	batches := make(chan map[int32]int, 10)
+	seedBroker.SetHandlerFuncByMap(map[string]requestHandlerFunc{
+		"MetadataRequest": func(req *request) encoderWithHeader { return metadataResponse.For(req.body) },
+		"ProduceRequest": func(req *request) encoderWithHeader {
+			batch := make(map[int32]int)
+			for partition, records := range req.body.(*ProduceRequest).records["my_topic"] {
+				n, err := records.numRecords()
+				require.NoError(t, err)
+				batch[partition] = n
+			}
+			batches <- batch
+			return produceResponse.For(req.body)
+		},
+	})
+
+	config := NewTestConfig()
+	config.Producer.Flush.Frequency = 500 * time.Millisecond
+	config.Producer.Flush.Messages = 100
+	config.Producer.Flush.MaxMessagesPerPartition = 3
+	config.Producer.Partitioner = NewManualPartitioner
+	producer, err := NewAsyncProducer([]string{seedBroker.Addr()}, config)
+	require.NoError(t, err)
+
+	// partition 0 is the only one to get a third message
+	for i := 0; i < 9; i++ {
+		producer.Input() <- &ProducerMessage{Topic: "my_topic", Partition: int32(i % 4), Value: StringEncoder(TestMessage)}
+	}
+
+	produced := make(map[int32]int)
+	select {
+	case batch := <-batches:
+		require.Equal(t, 3, batch[0])
+		for partition, n := range batch {
+			require.LessOrEqual(t, n, 3, "partition %d", partition)
+			produced[partition] += n
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a flush once partition 0 holds 3 messages")
+	}
+
+	// the messages of the other partitions wait for Flush.Frequency
+	select {
+	case batch := <-batches:
+		t.Fatalf("unexpected flush of %v", batch)
+	case <-time.After(100 * time.Millisecond):
+	}
+	for produced[1]+produced[2]+produced[3] < 6 {
+		select {
+		case batch := <-batches:
+			for partition, n := range batch {
+				produced[partition] += n
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected a flush once Flush.Frequency elapsed")
+		}
+	}
+	require.Equal(t, map[int32]int{0: 3, 1: 2, 2: 2, 3: 2}, produced)
+	closeProducer(t, producer)
+}
+
 func TestAsyncProducerEncoderFailures(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	leader := NewMockBroker(t, 2)
@@ -613,6 +1045,62 @@ func TestAsyncProducerMultipleRetries(t *testing.T) {
 	closeProducer(t, producer)
 }
 
+func TestAsyncProducerOnPartitionError(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	leader := NewMockBroker(t, 2)
+
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker(leader.Addr(), leader.BrokerID())
+	metadataResponse.AddTopicPartition("my_topic", 0, leader.BrokerID(), nil, nil, nil, ErrNoError)
+	metadataResponse.AddTopicPartition("my_topic", 1, leader.BrokerID(), nil, nil, nil, ErrNoError)
+	seedBroker.Returns(metadataResponse)
+
+	type partitionError struct {
+		topic     string
+		partition int32
+		err       error
+	}
+	partitionErrors := make(chan partitionError, 10)
+
+	config := NewTestConfig()
+	config.Producer.Flush.Messages = 10
+	config.Producer.Return.Successes = true
+	config.Producer.Retry.Max = 0
+	config.Producer.Partitioner = NewManualPartitioner
+	config.Producer.OnPartitionError = func(topic string, partition int32, err error) {
+		partitionErrors <- partitionError{topic, partition, err}
+	}
+	producer, err := NewAsyncProducer([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		producer.Input() <- &ProducerMessage{Topic: "my_topic", Partition: int32(i % 2), Value: StringEncoder(TestMessage)}
+	}
+	prodResponse := new(ProduceResponse)
+	prodResponse.AddTopicPartition("my_topic", 0, ErrNoError)
+	prodResponse.AddTopicPartition("my_topic", 1, ErrNotLeaderForPartition)
+	leader.Returns(prodResponse)
+	expectResults(t, producer, 5, 5)
+
+	select {
+	case got := <-partitionErrors:
+		if got != (partitionError{"my_topic", 1, ErrNotLeaderForPartition}) {
+			t.Errorf("unexpected partition error %+v", got)
+		}
+	default:
+		t.Error("expected OnPartitionError to be called")
+	}
+	if len(partitionErrors) > 0 {
+		t.Errorf("expected OnPartitionError to be called once per batch, got %d more calls", len(partitionErrors))
+	}
+
+	seedBroker.Close()
+	leader.Close()
+	closeProducer(t, producer)
+}
+
 func TestAsyncProducerMultipleRetriesWithBackoffFunc(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	leader1 := NewMockBroker(t, 2)
@@ -685,6 +1173,84 @@ func TestAsyncProducerMultipleRetriesWithBackoffFunc(t *testing.T) {
 	}
 }
 
+func TestAsyncProducerRetryWithBackoffFuncWithError(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	leader1 := NewMockBroker(t, 2)
+	leader2 := NewMockBroker(t, 3)
+
+	metadataLeader1 := new(MetadataResponse)
+	metadataLeader1.AddBroker(leader1.Addr(), leader1.BrokerID())
+	metadataLeader1.AddTopicPartition("my_topic", 0, leader1.BrokerID(), nil, nil, nil, ErrNoError)
+	seedBroker.Returns(metadataLeader1)
+
+	config := NewTestConfig()
+	config.Producer.Flush.Messages = 1
+	config.Producer.Return.Successes = true
+	config.Producer.Retry.Max = 2
+
+	var mu sync.Mutex
+	var backoffErrs []error
+	config.Producer.Retry.BackoffFuncWithError = func(retries, maxRetries int, err error) time.Duration {
+		mu.Lock()
+		defer mu.Unlock()
+		backoffErrs = append(backoffErrs, err)
+		return 0
+	}
+	config.Producer.Retry.BackoffFunc = func(retries, maxRetries int) time.Duration {
+		t.Error("BackoffFunc should not be called when BackoffFuncWithError is set")
+		return 0
+	}
+	producer, err := NewAsyncProducer([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prodNotLeader := new(ProduceResponse)
+	prodNotLeader.AddTopicPartition("my_topic", 0, ErrNotLeaderForPartition)
+	prodThrottled := new(ProduceResponse)
+	prodThrottled.AddTopicPartition("my_topic", 0, ErrNotEnoughReplicas)
+	prodSuccess := new(ProduceResponse)
+	prodSuccess.AddTopicPartition("my_topic", 0, ErrNoError)
+
+	metadataLeader2 := new(MetadataResponse)
+	metadataLeader2.AddBroker(leader2.Addr(), leader2.BrokerID())
+	metadataLeader2.AddTopicPartition("my_topic", 0, leader2.BrokerID(), nil, nil, nil, ErrNoError)
+
+	leader1.Returns(prodNotLeader)
+	leader1.Returns(metadataLeader2)
+	leader2.Returns(prodThrottled)
+	leader2.Returns(metadataLeader2)
+	leader2.Returns(prodSuccess)
+
+	producer.Input() <- &ProducerMessage{Topic: "my_topic", Key: nil, Value: StringEncoder(TestMessage)}
+	expectResults(t, producer, 1, 0)
+
+	seedBroker.Close()
+	leader1.Close()
+	leader2.Close()
+	closeProducer(t, producer)
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []error{ErrNotLeaderForPartition, ErrNotEnoughReplicas}
+	if len(backoffErrs) != len(expected) {
+		t.Fatalf("expected %d backoffs, got %v", len(expected), backoffErrs)
+	}
+	for i, err := range expected {
+		if !errors.Is(backoffErrs[i], err) {
+			t.Errorf("expected backoff #%d to receive %v, got %v", i+1, err, backoffErrs[i])
+		}
+	}
+}
+
+func TestProducerMessageClearResetsRetryState(t *testing.T) {
+	msg := &ProducerMessage{flags: fin, retries: 2, retryErr: ErrNotLeaderForPartition}
+	msg.clear()
+	if msg.flags != 0 || msg.retries != 0 || msg.retryErr != nil {
+		t.Errorf("expected retry state to be reset, got flags=%d retries=%d retryErr=%v", msg.flags, msg.retries, msg.retryErr)
+	}
+}
+
 func TestAsyncProducerWithExponentialBackoffDurations(t *testing.T) {
 	var backoffDurations []time.Duration
 	var mu sync.Mutex
@@ -796,6 +1362,138 @@ func TestAsyncProducerMultipleRetriesWithConcurrentRequests(t *testing.T) {
 	closeProducer(t, producer)
 }
 
+func TestAsyncProducerDeliveryDeadline(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	leader := NewMockBroker(t, 2)
+	defer seedBroker.Close()
+	defer leader.Close()
+
+	metadata := NewMockMetadataResponse(t).
+		SetBroker(leader.Addr(), leader.BrokerID()).
+		SetLeader("my_topic", 0, leader.BrokerID())
+	prodNotLeader := new(ProduceResponse)
+	prodNotLeader.AddTopicPartition("my_topic", 0, ErrNotLeaderForPartition)
+	prodSuccess := new(ProduceResponse)
+	prodSuccess.AddTopicPartition("my_topic", 0, ErrNoError)
+	seedBroker.SetHandlerByMap(map[string]MockResponse{"MetadataRequest": metadata})
+	leader.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": metadata,
+		"ProduceRequest":  NewMockSequence(prodNotLeader, prodSuccess),
+	})
+
+	config := NewTestConfig()
+	config.Producer.Flush.Messages = 2
+	config.Producer.Flush.Frequency = 10 * time.Millisecond
+	config.Producer.Return.Successes = true
+	config.Producer.Retry.Max = 5
+	config.Producer.Retry.Backoff = 200 * time.Millisecond
+	producer, err := NewAsyncProducer([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeProducer(t, producer)
+
+	// the first attempt fails, and the deadline of the first message passes
+	// during the backoff before the retry
+	late := &ProducerMessage{Topic: "my_topic", Value: StringEncoder("late"), Deadline: time.Now().Add(100 * time.Millisecond)}
+	producer.Input() <- late
+	producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder("on time")}
+
+	start := time.Now()
+	select {
+	case pErr := <-producer.Errors():
+		require.ErrorIs(t, pErr.Err, ErrDeliveryDeadlineExceeded)
+		require.Same(t, late, pErr.Msg)
+		require.Less(t, time.Since(start), time.Second)
+	case msg := <-producer.Successes():
+		t.Fatalf("unexpected success of %v", msg.Value)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the late message")
+	}
+	select {
+	case msg := <-producer.Successes():
+		require.Equal(t, StringEncoder("on time"), msg.Value)
+	case pErr := <-producer.Errors():
+		t.Fatal(pErr)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the message without deadline")
+	}
+}
+
+// TestAsyncProducerRetryKeepsPartitionOrder ensures that a batch being retried
+// is not overtaken by later batches for the same partition even though
+// Net.MaxOpenRequests allows several requests in flight.
+func TestAsyncProducerRetryKeepsPartitionOrder(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	leader := NewMockBroker(t, 2)
+	defer seedBroker.Close()
+	defer leader.Close()
+
+	metadataResponse := NewMockMetadataResponse(t).
+		SetBroker(leader.Addr(), leader.BrokerID()).
+		SetLeader("my_topic", 0, leader.BrokerID())
+	seedBroker.SetHandlerByMap(map[string]MockResponse{"MetadataRequest": metadataResponse})
+
+	var (
+		mu       sync.Mutex
+		requests int
+		written  []string
+	)
+	leader.setHandler(func(req *request) (res encoderWithHeader) {
+		produceReq, ok := req.body.(*ProduceRequest)
+		if !ok {
+			// the retry refreshes the metadata
+			return metadataResponse.For(req.body)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		res = &ProduceResponse{Version: produceReq.version()}
+		if requests == 1 {
+			res.(*ProduceResponse).AddTopicPartition("my_topic", 0, ErrNotEnoughReplicas)
+			return res
+		}
+		for _, record := range produceReq.records["my_topic"][0].RecordBatch.Records {
+			written = append(written, string(record.Value))
+		}
+		res.(*ProduceResponse).AddTopicPartition("my_topic", 0, ErrNoError)
+		return res
+	})
+	// give the following batches a chance to be sent while the first one is
+	// still in flight
+	leader.SetLatency(20 * time.Millisecond)
+
+	config := NewTestConfig()
+	config.Version = V2_0_0_0
+	config.Net.MaxOpenRequests = 5
+	config.Producer.Flush.MaxMessages = 1
+	config.Producer.Return.Successes = true
+	producer, err := NewAsyncProducer([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeProducer(t, producer)
+
+	var expected []string
+	for i := 0; i < 10; i++ {
+		expected = append(expected, strconv.Itoa(i))
+		producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(expected[i])}
+	}
+	for i := 0; i < 10; i++ {
+		select {
+		case msg := <-producer.Successes():
+			value, _ := msg.Value.Encode()
+			require.Equal(t, expected[i], string(value))
+		case pErr := <-producer.Errors():
+			t.Fatal(pErr)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, expected, written)
+}
+
 func TestAsyncProducerBrokerRestart(t *testing.T) {
 	// Logger = log.New(os.Stdout, "[sarama] ", log.LstdFlags)
 
@@ -1541,94 +2239,289 @@ func TestAsyncProducerIdempotentErrorOnOutOfSeq(t *testing.T) {
 	config.Net.MaxOpenRequests = 1
 	config.Version = V0_11_0_0
 
-	producer, err := NewAsyncProducer([]string{broker.Addr()}, config)
-	if err != nil {
-		t.Fatal(err)
+	producer, err := NewAsyncProducer([]string{broker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		producer.Input() <- &ProducerMessage{Topic: "my_topic", Key: nil, Value: StringEncoder(TestMessage)}
+	}
+
+	prodOutOfSeq := &ProduceResponse{
+		Version:      3,
+		ThrottleTime: 0,
+	}
+	prodOutOfSeq.AddTopicPartition("my_topic", 0, ErrOutOfOrderSequenceNumber)
+	broker.Returns(prodOutOfSeq)
+	expectResults(t, producer, 0, 10)
+
+	broker.Close()
+	closeProducer(t, producer)
+}
+
+func TestAsyncProducerIdempotentEpochRollover(t *testing.T) {
+	broker := NewMockBroker(t, 1)
+	defer broker.Close()
+
+	metadataResponse := &MetadataResponse{
+		Version:      4,
+		ControllerID: 1,
+	}
+	metadataResponse.AddBroker(broker.Addr(), broker.BrokerID())
+	metadataResponse.AddTopicPartition("my_topic", 0, broker.BrokerID(), nil, nil, nil, ErrNoError)
+	broker.Returns(metadataResponse)
+
+	initProducerID := &InitProducerIDResponse{
+		ThrottleTime:  0,
+		ProducerID:    1000,
+		ProducerEpoch: 1,
+	}
+	broker.Returns(initProducerID)
+
+	config := NewTestConfig()
+	config.Producer.Flush.Messages = 10
+	config.Producer.Flush.Frequency = 10 * time.Millisecond
+	config.Producer.Return.Successes = true
+	config.Producer.Retry.Max = 1 // This test needs to exercise what happens when retries exhaust
+	config.Producer.RequiredAcks = WaitForAll
+	config.Producer.Retry.Backoff = 0
+	config.Producer.Idempotent = true
+	config.Net.MaxOpenRequests = 1
+	config.Version = V0_11_0_0
+
+	producer, err := NewAsyncProducer([]string{broker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeProducer(t, producer)
+
+	producerID, epoch := producer.ProducerID()
+	require.Equal(t, int64(1000), producerID)
+	require.Equal(t, int16(1), epoch)
+
+	producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder("hello")}
+	prodError := &ProduceResponse{
+		Version:      3,
+		ThrottleTime: 0,
+	}
+	prodError.AddTopicPartition("my_topic", 0, ErrBrokerNotAvailable)
+	broker.Returns(prodError)
+	pErr := <-producer.Errors()
+	require.True(t, pErr.EpochBumped)
+	producerID, epoch = producer.ProducerID()
+	require.Equal(t, int64(1000), producerID)
+	require.Equal(t, int16(2), epoch)
+
+	lastReqRes := broker.history[len(broker.history)-1]
+	lastProduceBatch := lastReqRes.Request.(*ProduceRequest).records["my_topic"][0].RecordBatch
+	if lastProduceBatch.FirstSequence != 0 {
+		t.Error("first sequence not zero")
+	}
+	if lastProduceBatch.ProducerEpoch != 1 {
+		t.Error("first epoch was not one")
+	}
+
+	// Now if we produce again, the epoch should have rolled over.
+	producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder("hello")}
+	broker.Returns(prodError)
+	<-producer.Errors()
+
+	lastReqRes = broker.history[len(broker.history)-1]
+	lastProduceBatch = lastReqRes.Request.(*ProduceRequest).records["my_topic"][0].RecordBatch
+	if lastProduceBatch.FirstSequence != 0 {
+		t.Error("second sequence not zero")
+	}
+	if lastProduceBatch.ProducerEpoch <= 1 {
+		t.Error("second epoch was not > 1")
+	}
+}
+
+// A new leader elected while a batch is in flight may not know the producer
+// state yet: the batch must be resequenced rather than failed.
+func TestAsyncProducerIdempotentLeaderEpochChange(t *testing.T) {
+	broker := NewMockBroker(t, 1)
+	defer broker.Close()
+
+	metadataResponse := func(leaderEpoch int32) *MetadataResponse {
+		res := &MetadataResponse{Version: 7, ControllerID: 1}
+		res.AddBroker(broker.Addr(), broker.BrokerID())
+		res.AddTopicPartition("my_topic", 0, broker.BrokerID(), nil, nil, nil, ErrNoError)
+		res.Topics[0].Partitions[0].LeaderEpoch = leaderEpoch
+		return res
+	}
+	produceResponse := func(err KError) *ProduceResponse {
+		res := &ProduceResponse{Version: 7}
+		res.AddTopicPartition("my_topic", 0, err)
+		return res
+	}
+
+	var produces atomic.Int32
+	broker.setHandler(func(req *request) encoderWithHeader {
+		switch req.body.(type) {
+		case *MetadataRequest:
+			// the leader changes with the second produce request
+			if produces.Load() >= 2 {
+				return metadataResponse(2)
+			}
+			return metadataResponse(1)
+		case *InitProducerIDRequest:
+			return &InitProducerIDResponse{Version: 1, ProducerID: 1000, ProducerEpoch: 1}
+		case *ProduceRequest:
+			switch produces.Add(1) {
+			case 2:
+				return produceResponse(ErrNotLeaderForPartition)
+			case 3:
+				// the new leader lost the state of the producer
+				return produceResponse(ErrUnknownProducerID)
+			}
+			return produceResponse(ErrNoError)
+		}
+		return nil
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_1_0_0
+	config.Producer.Idempotent = true
+	config.Net.MaxOpenRequests = 1
+	config.Producer.Retry.Max = 3
+	config.Producer.Retry.Backoff = 0
+	config.Producer.RequiredAcks = WaitForAll
+	config.Producer.Return.Successes = true
+	producer, err := NewAsyncProducer([]string{broker.Addr()}, config)
+	require.NoError(t, err)
+
+	producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}
+	expectResults(t, producer, 1, 0)
+	producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}
+	expectResults(t, producer, 1, 0)
+	closeProducer(t, producer)
+
+	var batches []*RecordBatch
+	for _, rr := range broker.History() {
+		if req, ok := rr.Request.(*ProduceRequest); ok {
+			batches = append(batches, req.records["my_topic"][0].RecordBatch)
+		}
+	}
+	require.Len(t, batches, 4)
+	assert.Equal(t, int32(1), batches[2].FirstSequence, "the batch should be retried as is first")
+	assert.Equal(t, int16(1), batches[2].ProducerEpoch)
+	assert.Equal(t, int32(0), batches[3].FirstSequence, "the batch should then be resequenced")
+	assert.Equal(t, int16(2), batches[3].ProducerEpoch)
+}
+
+// The batches of several partitions rejected by their new leader are resynced
+// with a single epoch bump.
+func TestAsyncProducerIdempotentLeaderEpochChangeBumpsOnce(t *testing.T) {
+	broker := NewMockBroker(t, 1)
+	defer broker.Close()
+
+	var (
+		lock        sync.Mutex
+		leaderEpoch int32 = 1
+		rejected          = make(map[int32]int)
+	)
+	broker.setHandler(func(req *request) encoderWithHeader {
+		lock.Lock()
+		defer lock.Unlock()
+		switch body := req.body.(type) {
+		case *MetadataRequest:
+			res := &MetadataResponse{Version: 7, ControllerID: 1}
+			res.AddBroker(broker.Addr(), broker.BrokerID())
+			for partition := int32(0); partition < 2; partition++ {
+				res.AddTopicPartition("my_topic", partition, broker.BrokerID(), nil, nil, nil, ErrNoError)
+				res.Topics[0].Partitions[partition].LeaderEpoch = leaderEpoch
+			}
+			return res
+		case *InitProducerIDRequest:
+			return &InitProducerIDResponse{Version: 1, ProducerID: 1000, ProducerEpoch: 1}
+		case *ProduceRequest:
+			res := &ProduceResponse{Version: 7}
+			for partition, records := range body.records["my_topic"] {
+				kerr := ErrNoError
+				if batch := records.RecordBatch; batch.ProducerEpoch == 1 && batch.FirstSequence == 1 {
+					// the second batch of each partition is rejected by the
+					// old leader, then by the new one which lost the state
+					// of the producer
+					rejected[partition]++
+					if rejected[partition] == 1 {
+						leaderEpoch = 2
+						kerr = ErrNotLeaderForPartition
+					} else {
+						kerr = ErrUnknownProducerID
+					}
+				}
+				res.AddTopicPartition("my_topic", partition, kerr)
+			}
+			return res
+		}
+		return nil
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_1_0_0
+	config.Producer.Idempotent = true
+	config.Net.MaxOpenRequests = 1
+	config.Producer.Retry.Max = 3
+	config.Producer.Retry.Backoff = 0
+	config.Producer.RequiredAcks = WaitForAll
+	config.Producer.Return.Successes = true
+	config.Producer.Partitioner = NewManualPartitioner
+	producer, err := NewAsyncProducer([]string{broker.Addr()}, config)
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		for partition := int32(0); partition < 2; partition++ {
+			producer.Input() <- &ProducerMessage{Topic: "my_topic", Partition: partition, Value: StringEncoder(TestMessage)}
+		}
+		expectResults(t, producer, 2, 0)
 	}
+	closeProducer(t, producer)
 
-	for i := 0; i < 10; i++ {
-		producer.Input() <- &ProducerMessage{Topic: "my_topic", Key: nil, Value: StringEncoder(TestMessage)}
+	epochs := make(map[int32][]int16)
+	for _, rr := range broker.History() {
+		if req, ok := rr.Request.(*ProduceRequest); ok {
+			for partition, records := range req.records["my_topic"] {
+				epochs[partition] = append(epochs[partition], records.RecordBatch.ProducerEpoch)
+			}
+		}
 	}
-
-	prodOutOfSeq := &ProduceResponse{
-		Version:      3,
-		ThrottleTime: 0,
+	for partition := int32(0); partition < 2; partition++ {
+		require.NotEmpty(t, epochs[partition])
+		last := epochs[partition][len(epochs[partition])-1]
+		assert.Equal(t, int16(2), last, "the epoch of partition %d should be bumped once", partition)
 	}
-	prodOutOfSeq.AddTopicPartition("my_topic", 0, ErrOutOfOrderSequenceNumber)
-	broker.Returns(prodOutOfSeq)
-	expectResults(t, producer, 0, 10)
-
-	broker.Close()
-	closeProducer(t, producer)
 }
 
-func TestAsyncProducerIdempotentEpochRollover(t *testing.T) {
+func TestAsyncProducerNotIdempotentKeepsEpoch(t *testing.T) {
 	broker := NewMockBroker(t, 1)
 	defer broker.Close()
 
-	metadataResponse := &MetadataResponse{
-		Version:      4,
-		ControllerID: 1,
-	}
+	metadataResponse := new(MetadataResponse)
 	metadataResponse.AddBroker(broker.Addr(), broker.BrokerID())
 	metadataResponse.AddTopicPartition("my_topic", 0, broker.BrokerID(), nil, nil, nil, ErrNoError)
 	broker.Returns(metadataResponse)
 
-	initProducerID := &InitProducerIDResponse{
-		ThrottleTime:  0,
-		ProducerID:    1000,
-		ProducerEpoch: 1,
-	}
-	broker.Returns(initProducerID)
-
 	config := NewTestConfig()
-	config.Producer.Flush.Messages = 10
-	config.Producer.Flush.Frequency = 10 * time.Millisecond
-	config.Producer.Return.Successes = true
-	config.Producer.Retry.Max = 1 // This test needs to exercise what happens when retries exhaust
-	config.Producer.RequiredAcks = WaitForAll
-	config.Producer.Retry.Backoff = 0
-	config.Producer.Idempotent = true
-	config.Net.MaxOpenRequests = 1
-	config.Version = V0_11_0_0
-
+	config.Producer.Flush.Messages = 1
+	config.Producer.Retry.Max = 0
 	producer, err := NewAsyncProducer([]string{broker.Addr()}, config)
 	if err != nil {
 		t.Fatal(err)
 	}
 	defer closeProducer(t, producer)
 
-	producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder("hello")}
-	prodError := &ProduceResponse{
-		Version:      3,
-		ThrottleTime: 0,
-	}
-	prodError.AddTopicPartition("my_topic", 0, ErrBrokerNotAvailable)
-	broker.Returns(prodError)
-	<-producer.Errors()
-
-	lastReqRes := broker.history[len(broker.history)-1]
-	lastProduceBatch := lastReqRes.Request.(*ProduceRequest).records["my_topic"][0].RecordBatch
-	if lastProduceBatch.FirstSequence != 0 {
-		t.Error("first sequence not zero")
-	}
-	if lastProduceBatch.ProducerEpoch != 1 {
-		t.Error("first epoch was not one")
-	}
+	producerID, epoch := producer.ProducerID()
+	require.Equal(t, int64(-1), producerID)
+	require.Equal(t, int16(-1), epoch)
 
-	// Now if we produce again, the epoch should have rolled over.
-	producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder("hello")}
+	prodError := new(ProduceResponse)
+	prodError.AddTopicPartition("my_topic", 0, ErrMessageSizeTooLarge)
 	broker.Returns(prodError)
-	<-producer.Errors()
-
-	lastReqRes = broker.history[len(broker.history)-1]
-	lastProduceBatch = lastReqRes.Request.(*ProduceRequest).records["my_topic"][0].RecordBatch
-	if lastProduceBatch.FirstSequence != 0 {
-		t.Error("second sequence not zero")
-	}
-	if lastProduceBatch.ProducerEpoch <= 1 {
-		t.Error("second epoch was not > 1")
-	}
+	producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder("hello")}
+	pErr := <-producer.Errors()
+	require.ErrorIs(t, pErr, ErrMessageSizeTooLarge)
+	require.False(t, pErr.EpochBumped)
 }
 
 // TestAsyncProducerIdempotentEpochExhaustion ensures that producer requests
@@ -1685,7 +2578,11 @@ func TestAsyncProducerIdempotentEpochExhaustion(t *testing.T) {
 		ProducerID: newProducerID,
 	})
 
-	<-producer.Errors()
+	pErr := <-producer.Errors()
+	require.True(t, pErr.EpochBumped)
+	producerID, epoch := producer.ProducerID()
+	require.Equal(t, newProducerID, producerID)
+	require.Equal(t, int16(0), epoch)
 
 	lastProduceReqRes := broker.history[len(broker.history)-2] // last is InitProducerIDRequest
 	lastProduceBatch := lastProduceReqRes.Request.(*ProduceRequest).records["my_topic"][0].RecordBatch
@@ -2102,12 +2999,21 @@ func (c *stubLeaderClient) Leader(topic string, partitionID int32) (*Broker, err
 func (c *stubLeaderClient) LeaderAndEpoch(string, int32) (*Broker, int32, error) {
 	return c.leader, 0, nil
 }
-func (c *stubLeaderClient) Replicas(string, int32) ([]int32, error)          { return nil, nil }
-func (c *stubLeaderClient) InSyncReplicas(string, int32) ([]int32, error)    { return nil, nil }
-func (c *stubLeaderClient) OfflineReplicas(string, int32) ([]int32, error)   { return nil, nil }
-func (c *stubLeaderClient) RefreshBrokers([]string) error                    { return nil }
-func (c *stubLeaderClient) RefreshMetadata(...string) error                  { return nil }
-func (c *stubLeaderClient) GetOffset(string, int32, int64) (int64, error)    { return 0, nil }
+func (c *stubLeaderClient) Replicas(string, int32) ([]int32, error)        { return nil, nil }
+func (c *stubLeaderClient) InSyncReplicas(string, int32) ([]int32, error)  { return nil, nil }
+func (c *stubLeaderClient) OfflineReplicas(string, int32) ([]int32, error) { return nil, nil }
+func (c *stubLeaderClient) RefreshBrokers([]string) error                  { return nil }
+func (c *stubLeaderClient) RefreshMetadata(...string) error                { return nil }
+func (c *stubLeaderClient) GetOffset(string, int32, int64) (int64, error)  { return 0, nil }
+func (c *stubLeaderClient) GetOffsetWithEpoch(string, int32, int64) (int64, int32, error) {
+	return 0, -1, nil
+}
+func (c *stubLeaderClient) GetOffsetsByTime(string, time.Time) (map[int32]int64, error) {
+	return nil, nil
+}
+func (c *stubLeaderClient) ReadLastMessages(string, int32, int) ([]*ConsumerMessage, error) {
+	return nil, nil
+}
 func (c *stubLeaderClient) Coordinator(string) (*Broker, error)              { return nil, nil }
 func (c *stubLeaderClient) RefreshCoordinator(string) error                  { return nil }
 func (c *stubLeaderClient) TransactionCoordinator(string) (*Broker, error)   { return nil, nil }
@@ -2115,8 +3021,17 @@ func (c *stubLeaderClient) RefreshTransactionCoordinator(string) error       { r
 func (c *stubLeaderClient) InitProducerID() (*InitProducerIDResponse, error) { return nil, nil }
 func (c *stubLeaderClient) LeastLoadedBroker() *Broker                       { return c.leader }
 func (c *stubLeaderClient) PartitionNotReadable(string, int32) bool          { return false }
+func (c *stubLeaderClient) APIVersions(*Broker) (map[int16]ApiVersionRange, error) {
+	return nil, nil
+}
+func (c *stubLeaderClient) SetCachedApiVersions(int32, *ApiVersionsResponse) {}
+func (c *stubLeaderClient) TopicID(string) (Uuid, error)                     { return Uuid{}, nil }
+func (c *stubLeaderClient) Ping(context.Context) error                       { return nil }
 func (c *stubLeaderClient) Close() error                                     { return nil }
 func (c *stubLeaderClient) Closed() bool                                     { return false }
+func (c *stubLeaderClient) BrokerStateChanges() <-chan BrokerStateChange {
+	return nil
+}
 
 func testProducerInterceptor(
 	t *testing.T,
@@ -2219,6 +3134,162 @@ func TestAsyncProducerInterceptors(t *testing.T) {
 	}
 }
 
+type producerInterceptorV2Func func(*ProducerMessage) ([]*ProducerMessage, error)
+
+func (f producerInterceptorV2Func) OnSend(msg *ProducerMessage) ([]*ProducerMessage, error) {
+	return f(msg)
+}
+
+func TestAsyncProducerInterceptorsV2(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	leader := NewMockBroker(t, 2)
+	defer leader.Close()
+	metadataLeader := new(MetadataResponse)
+	metadataLeader.AddBroker(leader.Addr(), leader.BrokerID())
+	metadataLeader.AddTopicPartition("my_topic", 0, leader.BrokerID(), nil, nil, nil, ErrNoError)
+	seedBroker.Returns(metadataLeader)
+
+	errRejected := errors.New("rejected")
+	config := NewTestConfig()
+	config.Producer.Flush.Messages = 10
+	config.Producer.Return.Successes = true
+	config.Producer.InterceptorsV2 = []ProducerInterceptorV2{
+		AdaptProducerInterceptor(&appendInterceptor{i: 0}),
+		// drops the odd messages and rejects the last one
+		producerInterceptorV2Func(func(msg *ProducerMessage) ([]*ProducerMessage, error) {
+			v, _ := msg.Value.Encode()
+			i, _ := strconv.Atoi(strings.TrimPrefix(string(v), TestMessage))
+			switch {
+			case i == 10:
+				return nil, errRejected
+			case i%2 == 1:
+				return nil, nil
+			}
+			return []*ProducerMessage{msg}, nil
+		}),
+		// splits each message in two
+		producerInterceptorV2Func(func(msg *ProducerMessage) ([]*ProducerMessage, error) {
+			dup := *msg
+			dup.Value = StringEncoder(TestMessage + "dup")
+			return []*ProducerMessage{msg, &dup}, nil
+		}),
+	}
+	producer, err := NewAsyncProducer([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i <= 10; i++ {
+		producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}
+	}
+
+	pErr := <-producer.Errors()
+	if !errors.Is(pErr, errRejected) {
+		t.Errorf("expected the rejected message on the Errors channel, got %v", pErr.Err)
+	}
+
+	prodSuccess := new(ProduceResponse)
+	prodSuccess.AddTopicPartition("my_topic", 0, ErrNoError)
+	leader.Returns(prodSuccess)
+
+	values := make(map[string]int)
+	for i := 0; i < 10; i++ {
+		select {
+		case pErr := <-producer.Errors():
+			t.Error(pErr.Err)
+		case msg := <-producer.Successes():
+			v, _ := msg.Value.Encode()
+			values[string(v)]++
+		}
+	}
+	expected := map[string]int{TestMessage + "dup": 5}
+	for i := 0; i < 10; i += 2 {
+		expected[TestMessage+strconv.Itoa(i)] = 1
+	}
+	assert.Equal(t, expected, values)
+
+	if err := producer.Flush(time.Second); err != nil {
+		t.Error(err)
+	}
+	closeProducer(t, producer)
+}
+
+func TestAsyncProducerInterceptorsV2Duplicates(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker(seedBroker.Addr(), seedBroker.BrokerID())
+	metadataResponse.AddTopicPartition("my_topic", 0, seedBroker.BrokerID(), nil, nil, nil, ErrNoError)
+	seedBroker.Returns(metadataResponse)
+	prodSuccess := new(ProduceResponse)
+	prodSuccess.AddTopicPartition("my_topic", 0, ErrNoError)
+	seedBroker.Returns(prodSuccess)
+
+	config := NewTestConfig()
+	config.Producer.Flush.Messages = 2
+	config.Producer.Return.Successes = true
+	config.Producer.InterceptorsV2 = []ProducerInterceptorV2{
+		// returns the message twice, along with a new message twice
+		producerInterceptorV2Func(func(msg *ProducerMessage) ([]*ProducerMessage, error) {
+			added := &ProducerMessage{Topic: msg.Topic, Value: StringEncoder(TestMessage + "added")}
+			return []*ProducerMessage{msg, added, msg, added}, nil
+		}),
+	}
+	producer, err := NewAsyncProducer([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	producer.Input() <- &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}
+	values := make(map[string]int)
+	for i := 0; i < 2; i++ {
+		select {
+		case pErr := <-producer.Errors():
+			t.Error(pErr.Err)
+		case msg := <-producer.Successes():
+			v, _ := msg.Value.Encode()
+			values[string(v)]++
+		}
+	}
+	assert.Equal(t, map[string]int{TestMessage: 1, TestMessage + "added": 1}, values)
+
+	if err := producer.Flush(time.Second); err != nil {
+		t.Error(err)
+	}
+	closeProducer(t, producer)
+}
+
+func TestSyncProducerInterceptorsV2Drop(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker(seedBroker.Addr(), seedBroker.BrokerID())
+	metadataResponse.AddTopicPartition("my_topic", 0, seedBroker.BrokerID(), nil, nil, nil, ErrNoError)
+	seedBroker.Returns(metadataResponse)
+
+	config := NewTestConfig()
+	config.Producer.Return.Successes = true
+	config.Producer.InterceptorsV2 = []ProducerInterceptorV2{
+		producerInterceptorV2Func(func(*ProducerMessage) ([]*ProducerMessage, error) {
+			return nil, nil
+		}),
+	}
+	producer, err := NewSyncProducer([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, producer)
+
+	partition, offset, err := producer.SendMessage(&ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if partition != -1 || offset != -1 {
+		t.Errorf("expected a dropped message to be reported at -1/-1, got %d/%d", partition, offset)
+	}
+}
+
 func TestProducerError(t *testing.T) {
 	t.Parallel()
 	err := ProducerError{Err: ErrOutOfBrokers}
@@ -2624,6 +3695,124 @@ func TestTxnProduceRecordWithAbort(t *testing.T) {
 	require.Equal(t, ProducerTxnFlagReady, producer.txnmgr.status)
 }
 
+func newTxnSendOffsetsProducer(t *testing.T, txnOffsetCommitErr KError) (*MockBroker, *asyncProducer) {
+	t.Helper()
+	broker := NewMockBroker(t, 1)
+	t.Cleanup(broker.Close)
+
+	config := NewTestConfig()
+	config.Producer.Idempotent = true
+	config.Producer.Transaction.ID = "test"
+	config.Producer.Transaction.Retry.Backoff = 0
+	config.Version = V2_5_0_0
+	config.Producer.RequiredAcks = WaitForAll
+	config.Net.MaxOpenRequests = 1
+
+	broker.SetHandlerFuncByMap(map[string]requestHandlerFunc{
+		"MetadataRequest": func(req *request) encoderWithHeader {
+			return NewMockMetadataResponse(t).
+				SetController(broker.BrokerID()).
+				SetBroker(broker.Addr(), broker.BrokerID()).
+				For(req.body)
+		},
+		"FindCoordinatorRequest": func(req *request) encoderWithHeader {
+			return NewMockFindCoordinatorResponse(t).
+				SetCoordinator(CoordinatorTransaction, "test", broker).
+				SetCoordinator(CoordinatorGroup, "my-group", broker).
+				For(req.body)
+		},
+		"InitProducerIDRequest": func(req *request) encoderWithHeader {
+			return NewMockInitProducerIDResponse(t).SetProducerID(1).For(req.body)
+		},
+		"AddOffsetsToTxnRequest": func(req *request) encoderWithHeader {
+			return &AddOffsetsToTxnResponse{Version: req.body.version(), Err: ErrNoError}
+		},
+		"TxnOffsetCommitRequest": func(req *request) encoderWithHeader {
+			body := req.body.(*TxnOffsetCommitRequest)
+			resp := &TxnOffsetCommitResponse{Version: body.Version, Topics: map[string][]*PartitionError{}}
+			for topic, partitions := range body.Topics {
+				for _, partition := range partitions {
+					resp.Topics[topic] = append(resp.Topics[topic], &PartitionError{Partition: partition.Partition, Err: txnOffsetCommitErr})
+				}
+			}
+			return resp
+		},
+		"EndTxnRequest": func(req *request) encoderWithHeader {
+			return &EndTxnResponse{Version: req.body.version(), Err: ErrNoError}
+		},
+	})
+
+	client, err := NewClient([]string{broker.Addr()}, config)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+
+	ap, err := NewAsyncProducerFromClient(client)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ap.Close() })
+	return broker, ap.(*asyncProducer)
+}
+
+func TestTxnSendOffsetsAndCommit(t *testing.T) {
+	broker, producer := newTxnSendOffsetsProducer(t, ErrNoError)
+
+	offsets := map[string][]*PartitionOffsetMetadata{
+		"test-topic": {{Partition: 0, Offset: 10}},
+	}
+	groupMetadata := &ConsumerGroupMetadata{GroupID: "my-group", GenerationID: 3, MemberID: "member-1"}
+
+	err := producer.SendOffsetsAndCommit(context.Background(), offsets, groupMetadata)
+	require.ErrorIs(t, err, ErrTransactionNotReady, "expected calls outside a transaction to be rejected")
+
+	require.NoError(t, producer.BeginTxn())
+	require.NoError(t, producer.SendOffsetsAndCommit(context.Background(), offsets, groupMetadata))
+	require.Equal(t, ProducerTxnFlagInTransaction, producer.TxnStatus())
+	require.NoError(t, producer.CommitTxn())
+	require.Equal(t, ProducerTxnFlagReady, producer.TxnStatus())
+
+	var txnOffsetCommit *TxnOffsetCommitRequest
+	var endTxn *EndTxnRequest
+	for _, rr := range broker.History() {
+		switch req := rr.Request.(type) {
+		case *TxnOffsetCommitRequest:
+			txnOffsetCommit = req
+		case *EndTxnRequest:
+			endTxn = req
+		}
+	}
+	require.NotNil(t, txnOffsetCommit, "expected a TxnOffsetCommitRequest")
+	require.Equal(t, int16(3), txnOffsetCommit.Version)
+	require.Equal(t, "my-group", txnOffsetCommit.GroupID)
+	require.Equal(t, int32(3), txnOffsetCommit.GenerationID)
+	require.Equal(t, "member-1", txnOffsetCommit.MemberID)
+	require.Equal(t, int64(10), txnOffsetCommit.Topics["test-topic"][0].Offset)
+	require.NotNil(t, endTxn, "expected the transaction holding only offsets to be committed")
+	require.True(t, endTxn.TransactionResult)
+}
+
+func TestTxnSendOffsetsAndCommitFenced(t *testing.T) {
+	_, producer := newTxnSendOffsetsProducer(t, ErrFencedInstancedId)
+
+	require.NoError(t, producer.BeginTxn())
+	err := producer.SendOffsetsAndCommit(context.Background(), map[string][]*PartitionOffsetMetadata{
+		"test-topic": {{Partition: 0, Offset: 10}},
+	}, &ConsumerGroupMetadata{GroupID: "my-group", GenerationID: 3, MemberID: "member-1"})
+	require.ErrorIs(t, err, ErrFencedInstancedId)
+	require.Equal(t, ProducerTxnFlagInError|ProducerTxnFlagAbortableError, producer.TxnStatus())
+
+	require.ErrorIs(t, producer.CommitTxn(), ErrFencedInstancedId)
+	require.NoError(t, producer.AbortTxn())
+	require.Equal(t, ProducerTxnFlagReady, producer.TxnStatus())
+}
+
+func TestTxnSendOffsetsAndCommitInvalidArguments(t *testing.T) {
+	_, producer := newTxnSendOffsetsProducer(t, ErrNoError)
+
+	require.NoError(t, producer.BeginTxn())
+	err := producer.SendOffsetsAndCommit(context.Background(), nil, nil)
+	require.ErrorIs(t, err, ErrInvalidGroupId)
+	require.NoError(t, producer.AbortTxn())
+}
+
 func TestTxnCanAbort(t *testing.T) {
 	broker := NewMockBroker(t, 1)
 	defer broker.Close()
@@ -2839,6 +4028,68 @@ func TestProducerRetryBufferLimits(t *testing.T) {
 	}
 }
 
+func TestAsyncProducerRetryBufferOverflowPolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      RetryBufferOverflowPolicy
+		wantDropped int
+	}{
+		{"DropOldest", RetryBufferDropOldest, 0},
+		{"DropNewest", RetryBufferDropNewest, minFunctionalRetryBufferLength - 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewTestConfig()
+			config.Producer.Return.Errors = true
+			config.Producer.Retry.MaxBufferLength = minFunctionalRetryBufferLength
+			config.Producer.Retry.BufferOverflowPolicy = tt.policy
+			txnmgr, err := newTransactionManager(config, nil)
+			require.NoError(t, err)
+
+			registry := metrics.NewRegistry()
+			p := &asyncProducer{
+				conf:            config,
+				errors:          make(chan *ProducerError, 1),
+				input:           make(chan *ProducerMessage), // never read, so the buffer fills up
+				retries:         make(chan *ProducerMessage),
+				txnmgr:          txnmgr,
+				metricsRegistry: registry,
+			}
+			p.inFlight.Add(minFunctionalRetryBufferLength)
+			go withRecover(p.retryHandler)
+			defer func() { p.retries <- nil }()
+
+			for i := 0; i < minFunctionalRetryBufferLength; i++ {
+				p.retries <- &ProducerMessage{Topic: "my_topic", Metadata: i}
+			}
+
+			select {
+			case pErr := <-p.errors:
+				require.ErrorIs(t, pErr.Err, ErrProducerRetryBufferOverflow)
+				require.Equal(t, tt.wantDropped, pErr.Msg.Metadata)
+			case <-time.After(time.Second):
+				t.Fatal("expected a message to be dropped from the retry buffer")
+			}
+
+			require.Eventually(t, func() bool {
+				length := registry.Get("producer-retry-buffer-length")
+				return length != nil && length.(metrics.Gauge).Value() == minFunctionalRetryBufferLength-1
+			}, time.Second, time.Millisecond)
+			bytes := registry.Get("producer-retry-buffer-bytes").(metrics.Gauge).Value()
+			require.Positive(t, bytes)
+		})
+	}
+}
+
+func TestAsyncProducerRetryBufferOverflowPolicyValidation(t *testing.T) {
+	config := NewTestConfig()
+	config.Producer.Retry.BufferOverflowPolicy = RetryBufferDropNewest + 1
+	err := config.Validate()
+	var configErr ConfigurationError
+	require.ErrorAs(t, err, &configErr)
+}
+
 // This example shows how to use the producer while simultaneously
 // reading the Errors channel to know about any failures.
 func ExampleAsyncProducer_select() {
@@ -2930,6 +4181,58 @@ ProducerLoop:
 	log.Printf("Successfully produced: %d; errors: %d\n", successes, producerErrors)
 }
 
+// This example shows how to consume, transform and produce messages exactly
+// once: the offsets of the consumed messages are committed in the same
+// transaction as the produced messages, using the generation and member id of
+// the consumer group session so that a zombie member is fenced.
+func ExampleAsyncProducer_SendOffsetsAndCommit() {
+	config := NewTestConfig()
+	config.Version = V2_5_0_0
+	config.Producer.Idempotent = true
+	config.Producer.RequiredAcks = WaitForAll
+	config.Producer.Transaction.ID = "my-transactional-id"
+	config.Net.MaxOpenRequests = 1
+	producer, err := NewAsyncProducer([]string{"localhost:9092"}, config)
+	if err != nil {
+		panic(err)
+	}
+	defer func() { _ = producer.Close() }()
+
+	// transform is called from ConsumeClaim with each message of the claim.
+	transform := func(sess ConsumerGroupSession, msg *ConsumerMessage) {
+		if err := producer.BeginTxn(); err != nil {
+			log.Fatalln(err)
+		}
+
+		producer.Input() <- &ProducerMessage{Topic: "my_output_topic", Key: ByteEncoder(msg.Key), Value: ByteEncoder(msg.Value)}
+
+		offsets := map[string][]*PartitionOffsetMetadata{
+			msg.Topic: {{Partition: msg.Partition, Offset: msg.Offset + 1}},
+		}
+		groupMetadata := &ConsumerGroupMetadata{
+			GroupID:      "my-group",
+			GenerationID: sess.GenerationID(),
+			MemberID:     sess.MemberID(),
+		}
+		err := producer.SendOffsetsAndCommit(sess.Context(), offsets, groupMetadata)
+		if err == nil {
+			err = producer.CommitTxn()
+		}
+		if err != nil {
+			if producer.TxnStatus()&ProducerTxnFlagFatalError != 0 {
+				// The producer was fenced, it has to be closed.
+				log.Fatalln(err)
+			}
+			// Abort, the message will be consumed again from the last
+			// committed offset.
+			if err := producer.AbortTxn(); err != nil {
+				log.Fatalln(err)
+			}
+		}
+	}
+	_ = transform
+}
+
 // TestAsyncProducerRetryOrdering verifies that message ordering is preserved during retries,
 // both with and without request pipelining (MaxOpenRequests=1 vs >1).
 func TestAsyncProducerRetryOrdering(t *testing.T) {
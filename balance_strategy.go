@@ -1,6 +1,7 @@
 package sarama
 
 import (
+	"cmp"
 	"container/heap"
 	"errors"
 	"fmt"
@@ -21,6 +22,10 @@ const (
 	// StickyBalanceStrategyName identifies strategies that use the sticky-partition assignment strategy
 	StickyBalanceStrategyName = "sticky"
 
+	// CooperativeStickyBalanceStrategyName identifies strategies that use the sticky-partition assignment
+	// strategy with the cooperative rebalance protocol
+	CooperativeStickyBalanceStrategyName = "cooperative-sticky"
+
 	defaultGeneration = -1
 )
 
@@ -79,6 +84,90 @@ type SubscriptionUserDataBalanceStrategy interface {
 	SubscriptionUserData(topics []string) ([]byte, error)
 }
 
+// RebalanceProtocol is the protocol used by the members of a consumer group to
+// hand over partitions during a rebalance.
+type RebalanceProtocol int8
+
+const (
+	// RebalanceProtocolEager revokes all the partitions of every member before
+	// they rejoin the group.
+	RebalanceProtocolEager RebalanceProtocol = iota
+	// RebalanceProtocolCooperative (KIP-429) lets members keep consuming the
+	// partitions they own while they rejoin the group, and only revokes the
+	// partitions that move to another member.
+	RebalanceProtocolCooperative
+)
+
+// RebalanceProtocolBalanceStrategy is an optional extension of BalanceStrategy
+// that lets a strategy select the rebalance protocol used when it is the
+// strategy chosen by the group. Strategies that do not implement it use
+// RebalanceProtocolEager.
+//
+// With RebalanceProtocolCooperative, members report the partitions they own in
+// the OwnedPartitions of their ConsumerGroupMemberMetadata, and the Plan of the
+// strategy must not assign a partition to a member while another member still
+// owns it. The owner revokes the partitions missing from its new assignment and
+// immediately rejoins the group so that they can be assigned in a second
+// rebalance.
+type RebalanceProtocolBalanceStrategy interface {
+	BalanceStrategy
+
+	RebalanceProtocol() RebalanceProtocol
+}
+
+func rebalanceProtocol(strategy BalanceStrategy) RebalanceProtocol {
+	if s, ok := strategy.(RebalanceProtocolBalanceStrategy); ok {
+		return s.RebalanceProtocol()
+	}
+	return RebalanceProtocolEager
+}
+
+// RackAwareBalanceStrategy is an optional extension of BalanceStrategy that
+// lets a strategy prefer assigning partitions to members in the same rack as
+// one of their replicas (KIP-881). When Consumer.Group.Rebalance.RackAware is
+// enabled, the group leader calls PlanWithRacks instead of Plan with the racks
+// of the replicas of each partition in the form of a
+// `topic -> partition -> racks` map. The rack of a member is the RackID of its
+// ConsumerGroupMemberMetadata.
+type RackAwareBalanceStrategy interface {
+	BalanceStrategy
+
+	PlanWithRacks(members map[string]ConsumerGroupMemberMetadata, topics map[string][]int32, partitionRacks map[string]map[int32][]string) (BalanceStrategyPlan, error)
+}
+
+// rackAssignment holds the racks of the members and of the partition replicas
+// used by a rack-aware plan.
+type rackAssignment struct {
+	members    map[string]string
+	partitions map[string]map[int32][]string
+}
+
+func newRackAssignment(members map[string]ConsumerGroupMemberMetadata, partitionRacks map[string]map[int32][]string) *rackAssignment {
+	r := &rackAssignment{
+		members:    make(map[string]string, len(members)),
+		partitions: partitionRacks,
+	}
+	for memberID, meta := range members {
+		if meta.RackID != nil && *meta.RackID != "" {
+			r.members[memberID] = *meta.RackID
+		}
+	}
+	return r
+}
+
+// matches reports whether the member is in the same rack as one of the
+// replicas of the partition.
+func (r *rackAssignment) matches(memberID, topic string, partition int32) bool {
+	if r == nil {
+		return false
+	}
+	rack, ok := r.members[memberID]
+	if !ok {
+		return false
+	}
+	return slices.Contains(r.partitions[topic][partition], rack)
+}
+
 // --------------------------------------------------------------------
 
 // NewBalanceStrategyRange returns a range balance strategy,
@@ -93,12 +182,17 @@ type SubscriptionUserDataBalanceStrategy interface {
 func NewBalanceStrategyRange() BalanceStrategy {
 	return &balanceStrategy{
 		name: RangeBalanceStrategyName,
-		coreFn: func(plan BalanceStrategyPlan, memberIDs []string, topic string, partitions []int32) {
+		coreFn: func(plan BalanceStrategyPlan, memberIDs []string, topic string, partitions []int32, racks *rackAssignment) {
 			partitionsPerConsumer := len(partitions) / len(memberIDs)
 			consumersWithExtraPartition := len(partitions) % len(memberIDs)
 
 			sort.Strings(memberIDs)
 
+			if racks != nil {
+				assignRangeRackAware(plan, memberIDs, topic, partitions, racks)
+				return
+			}
+
 			for i, memberID := range memberIDs {
 				min := i*partitionsPerConsumer + int(math.Min(float64(consumersWithExtraPartition), float64(i)))
 				extra := 0
@@ -115,6 +209,50 @@ func NewBalanceStrategyRange() BalanceStrategy {
 // Deprecated: use NewBalanceStrategyRange to avoid data race issue
 var BalanceStrategyRange = NewBalanceStrategyRange()
 
+// assignRangeRackAware assigns each member the same number of partitions as
+// the range strategy, but first hands out the partitions with a replica in the
+// rack of the member before assigning the remaining ones in order. Without any
+// rack match this results in the same ranges as the range strategy.
+func assignRangeRackAware(plan BalanceStrategyPlan, memberIDs []string, topic string, partitions []int32, racks *rackAssignment) {
+	partitionsPerConsumer := len(partitions) / len(memberIDs)
+	consumersWithExtraPartition := len(partitions) % len(memberIDs)
+
+	assignments := make(map[string][]int32, len(memberIDs))
+	assigned := make(map[int32]bool, len(partitions))
+	fill := func(memberID string, quota int, sameRack bool) {
+		for _, partition := range partitions {
+			if len(assignments[memberID]) >= quota {
+				return
+			}
+			if assigned[partition] || (sameRack && !racks.matches(memberID, topic, partition)) {
+				continue
+			}
+			assignments[memberID] = append(assignments[memberID], partition)
+			assigned[partition] = true
+		}
+	}
+
+	for _, memberID := range memberIDs {
+		fill(memberID, partitionsPerConsumer, true)
+		if consumersWithExtraPartition > 0 && len(assignments[memberID]) == partitionsPerConsumer {
+			fill(memberID, partitionsPerConsumer+1, true)
+			if len(assignments[memberID]) > partitionsPerConsumer {
+				consumersWithExtraPartition--
+			}
+		}
+	}
+
+	for _, memberID := range memberIDs {
+		quota := partitionsPerConsumer
+		if len(assignments[memberID]) <= quota && consumersWithExtraPartition > 0 {
+			quota++
+			consumersWithExtraPartition--
+		}
+		fill(memberID, quota, false)
+		plan.Add(memberID, topic, assignments[memberID]...)
+	}
+}
+
 // NewBalanceStrategySticky returns a sticky balance strategy,
 // which assigns partitions to members with an attempt to preserve earlier assignments
 // while maintain a balanced partition distribution.
@@ -135,10 +273,39 @@ func NewBalanceStrategySticky() BalanceStrategy {
 // Deprecated: use NewBalanceStrategySticky to avoid data race issue
 var BalanceStrategySticky = NewBalanceStrategySticky()
 
+// NewBalanceStrategyCooperativeSticky returns a sticky balance strategy using the
+// cooperative rebalance protocol. This follows the same logic as
+// https://kafka.apache.org/31/javadoc/org/apache/kafka/clients/consumer/CooperativeStickyAssignor.html
+//
+// The assignments are the same as those of NewBalanceStrategySticky, but the
+// members keep consuming the partitions that stay assigned to them during a
+// rebalance instead of ending their session. A partition that moves to another
+// member is first revoked from its previous owner, and only assigned to its new
+// owner in the follow-up rebalance triggered by the previous owner.
+//
+// On reassignment with an additional consumer M3 of topic T with six partitions
+// (0..5) owned by M1 and M2, the first rebalance might result in:
+//
+//	M1: {T: [0, 2]}
+//	M2: {T: [1, 3]}
+//	M3: {}
+//
+// and the second one, once M1 and M2 have revoked partitions 4 and 5, in:
+//
+//	M1: {T: [0, 2]}
+//	M2: {T: [1, 3]}
+//	M3: {T: [4, 5]}
+//
+// All the members of the group have to support the cooperative-sticky strategy
+// for it to be chosen by the group.
+func NewBalanceStrategyCooperativeSticky() BalanceStrategy {
+	return &cooperativeStickyBalanceStrategy{}
+}
+
 // --------------------------------------------------------------------
 
 type balanceStrategy struct {
-	coreFn func(plan BalanceStrategyPlan, memberIDs []string, topic string, partitions []int32)
+	coreFn func(plan BalanceStrategyPlan, memberIDs []string, topic string, partitions []int32, racks *rackAssignment)
 	name   string
 }
 
@@ -147,6 +314,15 @@ func (s *balanceStrategy) Name() string { return s.name }
 
 // Plan implements BalanceStrategy.
 func (s *balanceStrategy) Plan(members map[string]ConsumerGroupMemberMetadata, topics map[string][]int32) (BalanceStrategyPlan, error) {
+	return s.plan(members, topics, nil)
+}
+
+// PlanWithRacks implements RackAwareBalanceStrategy.
+func (s *balanceStrategy) PlanWithRacks(members map[string]ConsumerGroupMemberMetadata, topics map[string][]int32, partitionRacks map[string]map[int32][]string) (BalanceStrategyPlan, error) {
+	return s.plan(members, topics, newRackAssignment(members, partitionRacks))
+}
+
+func (s *balanceStrategy) plan(members map[string]ConsumerGroupMemberMetadata, topics map[string][]int32, racks *rackAssignment) (BalanceStrategyPlan, error) {
 	// Build members by topic map
 	mbt := make(map[string][]string)
 	for memberID, meta := range members {
@@ -175,7 +351,7 @@ func (s *balanceStrategy) Plan(members map[string]ConsumerGroupMemberMetadata, t
 	// Assemble plan
 	plan := make(BalanceStrategyPlan, len(members))
 	for topic, memberIDs := range mbt {
-		s.coreFn(plan, uniq(memberIDs), topic, topics[topic])
+		s.coreFn(plan, uniq(memberIDs), topic, topics[topic], racks)
 	}
 	return plan, nil
 }
@@ -194,6 +370,18 @@ func (s *stickyBalanceStrategy) Name() string { return StickyBalanceStrategyName
 
 // Plan implements BalanceStrategy.
 func (s *stickyBalanceStrategy) Plan(members map[string]ConsumerGroupMemberMetadata, topics map[string][]int32) (BalanceStrategyPlan, error) {
+	return s.plan(members, topics, nil)
+}
+
+// PlanWithRacks implements RackAwareBalanceStrategy. Among the members with the
+// fewest partitions, unassigned partitions go to a member in the same rack as
+// one of their replicas if there is one.
+func (s *stickyBalanceStrategy) PlanWithRacks(members map[string]ConsumerGroupMemberMetadata, topics map[string][]int32, partitionRacks map[string]map[int32][]string) (BalanceStrategyPlan, error) {
+	return s.plan(members, topics, newRackAssignment(members, partitionRacks))
+}
+
+// plan computes the assignment, preferring racks when they are given.
+func (s *stickyBalanceStrategy) plan(members map[string]ConsumerGroupMemberMetadata, topics map[string][]int32, racks *rackAssignment) (BalanceStrategyPlan, error) {
 	// track partition movements during generation of the partition assignment plan
 	s.movements = partitionMovements{
 		Movements:                 make(map[topicPartitionAssignment]consumerPair),
@@ -278,7 +466,7 @@ func (s *stickyBalanceStrategy) Plan(members map[string]ConsumerGroupMemberMetad
 
 	// an ascending sorted set of consumers based on how many topic partitions are already assigned to them
 	sortedCurrentSubscriptions := sortMemberIDsByPartitionAssignments(currentAssignment)
-	s.balance(currentAssignment, prevAssignment, sortedPartitions, unassignedPartitions, sortedCurrentSubscriptions, consumer2AllPotentialPartitions, partition2AllPotentialConsumers, currentPartitionConsumers)
+	s.balance(currentAssignment, prevAssignment, sortedPartitions, unassignedPartitions, sortedCurrentSubscriptions, consumer2AllPotentialPartitions, partition2AllPotentialConsumers, currentPartitionConsumers, racks)
 
 	// Assemble plan
 	plan := make(BalanceStrategyPlan, len(currentAssignment))
@@ -303,17 +491,104 @@ func (s *stickyBalanceStrategy) AssignmentData(memberID string, topics map[strin
 	}, nil)
 }
 
+type cooperativeStickyBalanceStrategy struct {
+	stickyBalanceStrategy
+}
+
+// Name implements BalanceStrategy.
+func (s *cooperativeStickyBalanceStrategy) Name() string { return CooperativeStickyBalanceStrategyName }
+
+// RebalanceProtocol implements RebalanceProtocolBalanceStrategy.
+func (s *cooperativeStickyBalanceStrategy) RebalanceProtocol() RebalanceProtocol {
+	return RebalanceProtocolCooperative
+}
+
+// Plan implements BalanceStrategy.
+func (s *cooperativeStickyBalanceStrategy) Plan(members map[string]ConsumerGroupMemberMetadata, topics map[string][]int32) (BalanceStrategyPlan, error) {
+	return s.plan(members, topics, nil)
+}
+
+// PlanWithRacks implements RackAwareBalanceStrategy.
+func (s *cooperativeStickyBalanceStrategy) PlanWithRacks(members map[string]ConsumerGroupMemberMetadata, topics map[string][]int32, partitionRacks map[string]map[int32][]string) (BalanceStrategyPlan, error) {
+	return s.plan(members, topics, newRackAssignment(members, partitionRacks))
+}
+
+func (s *cooperativeStickyBalanceStrategy) plan(members map[string]ConsumerGroupMemberMetadata, topics map[string][]int32, racks *rackAssignment) (BalanceStrategyPlan, error) {
+	// the partitions owned by each member take the place of the assignment
+	// user data the sticky strategy relies on
+	stickyMembers := make(map[string]ConsumerGroupMemberMetadata, len(members))
+	owners := make(map[topicPartitionAssignment]string)
+	for memberID, meta := range members {
+		owned := make(map[string][]int32, len(meta.OwnedPartitions))
+		for _, op := range meta.OwnedPartitions {
+			owned[op.Topic] = append(owned[op.Topic], op.Partitions...)
+			for _, partition := range op.Partitions {
+				tp := topicPartitionAssignment{Topic: op.Topic, Partition: partition}
+				// on conflicting claims the member of the latest generation wins
+				if owner, ok := owners[tp]; !ok || members[owner].GenerationID < meta.GenerationID {
+					owners[tp] = memberID
+				}
+			}
+		}
+		userData, err := encode(&StickyAssignorUserDataV1{Topics: owned, Generation: meta.GenerationID}, nil)
+		if err != nil {
+			return nil, err
+		}
+		meta.UserData = userData
+		stickyMembers[memberID] = meta
+	}
+
+	plan, err := s.stickyBalanceStrategy.plan(stickyMembers, topics, racks)
+	if err != nil {
+		return nil, err
+	}
+
+	// withhold the partitions that move between members until their previous
+	// owner revoked them
+	for memberID, assignment := range plan {
+		for topic, partitions := range assignment {
+			kept := partitions[:0]
+			for _, partition := range partitions {
+				if owner, ok := owners[topicPartitionAssignment{Topic: topic, Partition: partition}]; ok && owner != memberID {
+					continue
+				}
+				kept = append(kept, partition)
+			}
+			if len(kept) == 0 {
+				delete(assignment, topic)
+			} else {
+				assignment[topic] = kept
+			}
+		}
+	}
+	return plan, nil
+}
+
+// AssignmentData implements BalanceStrategy. The cooperative sticky strategy
+// relies on the partitions owned by the members rather than on assignment data.
+func (s *cooperativeStickyBalanceStrategy) AssignmentData(memberID string, topics map[string][]int32, generationID int32) ([]byte, error) {
+	return nil, nil
+}
+
 // Balance assignments across consumers for maximum fairness and stickiness.
-func (s *stickyBalanceStrategy) balance(currentAssignment map[string][]topicPartitionAssignment, prevAssignment map[topicPartitionAssignment]consumerGenerationPair, sortedPartitions []topicPartitionAssignment, unassignedPartitions []topicPartitionAssignment, sortedCurrentSubscriptions []string, consumer2AllPotentialPartitions map[string][]topicPartitionAssignment, partition2AllPotentialConsumers map[topicPartitionAssignment][]string, currentPartitionConsumer map[topicPartitionAssignment]string) {
+func (s *stickyBalanceStrategy) balance(currentAssignment map[string][]topicPartitionAssignment, prevAssignment map[topicPartitionAssignment]consumerGenerationPair, sortedPartitions []topicPartitionAssignment, unassignedPartitions []topicPartitionAssignment, sortedCurrentSubscriptions []string, consumer2AllPotentialPartitions map[string][]topicPartitionAssignment, partition2AllPotentialConsumers map[topicPartitionAssignment][]string, currentPartitionConsumer map[topicPartitionAssignment]string, racks *rackAssignment) {
 	initializing := len(sortedCurrentSubscriptions) == 0 || len(currentAssignment[sortedCurrentSubscriptions[0]]) == 0
 
+	// assign the partitions in order when preferring racks, for the preference
+	// to only depend on the load of the consumers
+	if racks != nil {
+		slices.SortFunc(unassignedPartitions, func(a, b topicPartitionAssignment) int {
+			return cmp.Or(strings.Compare(a.Topic, b.Topic), cmp.Compare(a.Partition, b.Partition))
+		})
+	}
+
 	// assign all unassigned partitions
 	for _, partition := range unassignedPartitions {
 		// skip if there is no potential consumer for the partition
 		if len(partition2AllPotentialConsumers[partition]) == 0 {
 			continue
 		}
-		sortedCurrentSubscriptions = assignPartition(partition, sortedCurrentSubscriptions, currentAssignment, consumer2AllPotentialPartitions, currentPartitionConsumer)
+		sortedCurrentSubscriptions = assignPartition(partition, sortedCurrentSubscriptions, currentAssignment, consumer2AllPotentialPartitions, currentPartitionConsumer, racks)
 	}
 
 	// narrow down the reassignment scope to only those partitions that can actually be reassigned
@@ -615,13 +890,27 @@ func canTopicPartitionParticipateInReassignment(partition topicPartitionAssignme
 }
 
 // The assignment should improve the overall balance of the partition assignments to consumers.
-func assignPartition(partition topicPartitionAssignment, sortedCurrentSubscriptions []string, currentAssignment map[string][]topicPartitionAssignment, consumer2AllPotentialPartitions map[string][]topicPartitionAssignment, currentPartitionConsumer map[topicPartitionAssignment]string) []string {
+// Among the eligible consumers with the fewest partitions, one in the same rack as a replica
+// of the partition is preferred if racks are given.
+func assignPartition(partition topicPartitionAssignment, sortedCurrentSubscriptions []string, currentAssignment map[string][]topicPartitionAssignment, consumer2AllPotentialPartitions map[string][]topicPartitionAssignment, currentPartitionConsumer map[topicPartitionAssignment]string, racks *rackAssignment) []string {
+	var assignee string
 	for _, memberID := range sortedCurrentSubscriptions {
-		if memberAssignmentsIncludeTopicPartition(consumer2AllPotentialPartitions[memberID], partition) {
-			currentAssignment[memberID] = append(currentAssignment[memberID], partition)
-			currentPartitionConsumer[partition] = memberID
+		if !memberAssignmentsIncludeTopicPartition(consumer2AllPotentialPartitions[memberID], partition) {
+			continue
+		}
+		if assignee == "" {
+			assignee = memberID
+		} else if len(currentAssignment[memberID]) > len(currentAssignment[assignee]) {
 			break
 		}
+		if racks == nil || racks.matches(memberID, partition.Topic, partition.Partition) {
+			assignee = memberID
+			break
+		}
+	}
+	if assignee != "" {
+		currentAssignment[assignee] = append(currentAssignment[assignee], partition)
+		currentPartitionConsumer[partition] = assignee
 	}
 	return sortMemberIDsByPartitionAssignments(currentAssignment)
 }
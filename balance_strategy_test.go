@@ -5,6 +5,7 @@ package sarama
 import (
 	"bytes"
 	"fmt"
+	"maps"
 	"math"
 	"math/rand"
 	"reflect"
@@ -1105,7 +1106,7 @@ func Test_assignPartition(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := assignPartition(tt.args.partition, tt.args.sortedCurrentSubscriptions, tt.args.currentAssignment, tt.args.consumer2AllPotentialPartitions, tt.args.currentPartitionConsumer); !reflect.DeepEqual(got, tt.want) {
+			if got := assignPartition(tt.args.partition, tt.args.sortedCurrentSubscriptions, tt.args.currentAssignment, tt.args.consumer2AllPotentialPartitions, tt.args.currentPartitionConsumer, nil); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("assignPartition() = %v, want %v", got, tt.want)
 			}
 			if !reflect.DeepEqual(tt.args.currentAssignment, tt.wantCurrentAssignment) {
@@ -1504,6 +1505,62 @@ func Test_stickyBalanceStrategy_Plan_AddRemoveConsumerOneTopic(t *testing.T) {
 	verifyPlanIsBalancedAndSticky(t, s, members, plan3, err)
 }
 
+func Test_cooperativeStickyBalanceStrategy_Plan_AddConsumer(t *testing.T) {
+	s := NewBalanceStrategyCooperativeSticky()
+	if got := rebalanceProtocol(s); got != RebalanceProtocolCooperative {
+		t.Fatalf("expected the cooperative rebalance protocol, got %d", got)
+	}
+	topics := map[string][]int32{
+		"topic": {0, 1, 2, 3},
+	}
+
+	// PLAN 1: consumer1 owns every partition, consumer2 joins
+	members := map[string]ConsumerGroupMemberMetadata{
+		"consumer1": {
+			Version:         2,
+			Topics:          []string{"topic"},
+			OwnedPartitions: ownedPartitions(map[string][]int32{"topic": {0, 1, 2, 3}}),
+			GenerationID:    1,
+		},
+		"consumer2": {
+			Version:      2,
+			Topics:       []string{"topic"},
+			GenerationID: defaultGeneration,
+		},
+	}
+	plan1, err := s.Plan(members, topics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(plan1["consumer1"]["topic"]); got != 2 {
+		t.Errorf("expected consumer1 to keep 2 partitions, got %v", plan1["consumer1"])
+	}
+	if got := len(plan1["consumer2"]["topic"]); got != 0 {
+		t.Errorf("expected partitions owned by consumer1 to be withheld from consumer2, got %v", plan1["consumer2"])
+	}
+
+	// PLAN 2: consumer1 revoked the partitions missing from its assignment
+	members["consumer1"] = ConsumerGroupMemberMetadata{
+		Version:         2,
+		Topics:          []string{"topic"},
+		OwnedPartitions: ownedPartitions(plan1["consumer1"]),
+		GenerationID:    2,
+	}
+	plan2, err := s.Plan(members, topics)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyValidityAndBalance(t, members, plan2)
+	slices.Sort(plan1["consumer1"]["topic"])
+	slices.Sort(plan2["consumer1"]["topic"])
+	if !reflect.DeepEqual(plan1["consumer1"], plan2["consumer1"]) {
+		t.Errorf("expected consumer1 to keep %v, got %v", plan1["consumer1"], plan2["consumer1"])
+	}
+	if got := len(plan2["consumer2"]["topic"]); got != 2 {
+		t.Errorf("expected consumer2 to be assigned the revoked partitions, got %v", plan2["consumer2"])
+	}
+}
+
 func Test_stickyBalanceStrategy_Plan_PoorRoundRobinAssignmentScenario(t *testing.T) {
 	s := &stickyBalanceStrategy{}
 
@@ -2184,6 +2241,111 @@ func verifyPlanIsBalancedAndSticky(t *testing.T, s *stickyBalanceStrategy, membe
 	verifyValidityAndBalance(t, members, plan)
 }
 
+func TestBalanceStrategyRangeRackAware(t *testing.T) {
+	tests := []struct {
+		name     string
+		racks    map[string]string
+		replicas map[int32][]string
+		expected BalanceStrategyPlan
+	}{
+		{
+			name:     "partitions assigned to the member in the rack of their replicas",
+			racks:    map[string]string{"M1": "a", "M2": "b"},
+			replicas: map[int32][]string{0: {"b"}, 1: {"a"}, 2: {"b"}, 3: {"a"}},
+			expected: BalanceStrategyPlan{
+				"M1": map[string][]int32{"T1": {1, 3}},
+				"M2": map[string][]int32{"T1": {0, 2}},
+			},
+		},
+		{
+			name:     "no racks falls back to ranges",
+			replicas: map[int32][]string{0: {"b"}, 1: {"a"}, 2: {"b"}, 3: {"a"}},
+			expected: BalanceStrategyPlan{
+				"M1": map[string][]int32{"T1": {0, 1}},
+				"M2": map[string][]int32{"T1": {2, 3}},
+			},
+		},
+		{
+			name:     "balanced when all the replicas are in one rack",
+			racks:    map[string]string{"M1": "b", "M2": "a"},
+			replicas: map[int32][]string{0: {"a"}, 1: {"a"}, 2: {"a"}, 3: {"a"}},
+			expected: BalanceStrategyPlan{
+				"M1": map[string][]int32{"T1": {2, 3}},
+				"M2": map[string][]int32{"T1": {0, 1}},
+			},
+		},
+		{
+			name:     "extra partition goes to a member in the rack of its replicas",
+			racks:    map[string]string{"M1": "a", "M2": "b"},
+			replicas: map[int32][]string{0: {"b"}, 1: {"b"}, 2: {"b"}},
+			expected: BalanceStrategyPlan{
+				"M1": map[string][]int32{"T1": {2}},
+				"M2": map[string][]int32{"T1": {0, 1}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			members := make(map[string]ConsumerGroupMemberMetadata)
+			for _, memberID := range []string{"M1", "M2"} {
+				meta := ConsumerGroupMemberMetadata{Topics: []string{"T1"}}
+				if rack, ok := test.racks[memberID]; ok {
+					meta.RackID = &rack
+				}
+				members[memberID] = meta
+			}
+			partitions := slices.Sorted(maps.Keys(test.replicas))
+
+			strategy := NewBalanceStrategyRange().(RackAwareBalanceStrategy)
+			actual, err := strategy.PlanWithRacks(members, map[string][]int32{"T1": partitions}, map[string]map[int32][]string{"T1": test.replicas})
+			if err != nil {
+				t.Errorf("Unexpected error %v", err)
+			} else if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("Plan does not match expectation\nexpected: %#v\nactual: %#v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestBalanceStrategyStickyRackAware(t *testing.T) {
+	rackA, rackB := "a", "b"
+	members := map[string]ConsumerGroupMemberMetadata{
+		"M1": {Topics: []string{"T1"}, RackID: &rackA},
+		"M2": {Topics: []string{"T1"}, RackID: &rackB},
+	}
+	topics := map[string][]int32{"T1": {0, 1, 2, 3}}
+
+	for _, strategy := range []BalanceStrategy{NewBalanceStrategySticky(), NewBalanceStrategyCooperativeSticky()} {
+		t.Run(strategy.Name(), func(t *testing.T) {
+			s := strategy.(RackAwareBalanceStrategy)
+
+			plan, err := s.PlanWithRacks(members, topics, map[string]map[int32][]string{
+				"T1": {0: {"b"}, 1: {"a"}, 2: {"b"}, 3: {"a"}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			verifyValidityAndBalance(t, members, plan)
+			expected := BalanceStrategyPlan{
+				"M1": map[string][]int32{"T1": {1, 3}},
+				"M2": map[string][]int32{"T1": {0, 2}},
+			}
+			if !reflect.DeepEqual(plan, expected) {
+				t.Errorf("Plan does not match expectation\nexpected: %#v\nactual: %#v", expected, plan)
+			}
+
+			plan, err = s.PlanWithRacks(members, topics, map[string]map[int32][]string{
+				"T1": {0: {"a"}, 1: {"a"}, 2: {"a"}, 3: {"a"}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			verifyValidityAndBalance(t, members, plan)
+		})
+	}
+}
+
 func verifyValidityAndBalance(t *testing.T, consumers map[string]ConsumerGroupMemberMetadata, plan BalanceStrategyPlan) {
 	t.Helper()
 	size := len(consumers)
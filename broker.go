@@ -1,6 +1,7 @@
 package sarama
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/binary"
 	"errors"
@@ -34,6 +35,16 @@ type Broker struct {
 	responses     chan *responsePromise
 	done          chan bool
 
+	// reconnect backoff state, see Config.Net.ReconnectBackoff
+	dialFailures int
+	reconnectAt  time.Time
+
+	// idle connection state, see Config.Net.MaxIdleTime
+	inFlight     atomic.Int32
+	lastActivity atomic.Int64 // unix nanoseconds
+	idleTimer    *time.Timer
+	idleClosed   atomic.Bool
+
 	metricRegistry             metrics.Registry
 	incomingByteRate           metrics.Meter
 	requestRate                metrics.Meter
@@ -58,13 +69,62 @@ type Broker struct {
 	brokerProtocolRequestsRate map[int16]metrics.Meter
 	brokerAPIVersions          apiVersionMap
 
+	// cachedAPIVersions are stored when connecting instead of probing the
+	// broker with an ApiVersionsRequest, usingCachedAPIVersions tells whether
+	// the current connection relies on them. cachedAPIVersions is accessed
+	// atomically so that the client sets it without waiting for b.lock, which
+	// is held while connecting.
+	cachedAPIVersions      atomic.Pointer[ApiVersionsResponse]
+	usingCachedAPIVersions bool
+
 	kerberosAuthenticator               GSSAPIKerberosAuth
+	keytabs                             *keytabCache // set by the client, see GSSAPIConfig.KeyTabReloadInterval
 	clientSessionReauthenticationTimeMs int64
+	reauthenticationTimer               *time.Timer
 
 	throttleTimer     *time.Timer
 	throttleTimerLock sync.Mutex
+
+	// stateLock protects the connection state notifications, it is separate
+	// from lock which is held for the whole connection attempt
+	stateLock     sync.Mutex
+	state         BrokerConnectionState
+	stateChanges  chan BrokerConnectionState
+	stateListener func(*Broker, BrokerConnectionState)
+}
+
+// BrokerConnectionState is the state of the connection to a Broker, as
+// notified by Broker.StateChanges.
+type BrokerConnectionState int
+
+const (
+	// BrokerDisconnected means the broker is not connected, either because it
+	// was never opened, it was closed or the connection attempt failed.
+	BrokerDisconnected BrokerConnectionState = iota
+	// BrokerConnecting means a connection attempt is in progress.
+	BrokerConnecting
+	// BrokerConnected means the broker is connected (and authenticated if
+	// SASL is enabled).
+	BrokerConnected
+)
+
+func (s BrokerConnectionState) String() string {
+	switch s {
+	case BrokerDisconnected:
+		return "disconnected"
+	case BrokerConnecting:
+		return "connecting"
+	case BrokerConnected:
+		return "connected"
+	default:
+		return fmt.Sprintf("BrokerConnectionState(%d)", int(s))
+	}
 }
 
+// brokerStateChangesBuffer is the capacity of the channel returned by
+// Broker.StateChanges.
+const brokerStateChangesBuffer = 4
+
 // SASLMechanism specifies the SASL mechanism the client uses to authenticate with the broker
 type SASLMechanism string
 
@@ -78,6 +138,11 @@ const (
 	// SASLTypeSCRAMSHA512 represents the SCRAM-SHA-512 mechanism.
 	SASLTypeSCRAMSHA512 = "SCRAM-SHA-512"
 	SASLTypeGSSAPI      = "GSSAPI"
+	// SASLTypeCustom selects the user provided mechanism of
+	// Config.Net.SASL.CustomAuthenticator. It is never sent to the broker:
+	// the handshake advertises the name returned by the authenticator's
+	// Mechanism method instead.
+	SASLTypeCustom = "CUSTOM"
 	// SASLHandshakeV0 is v0 of the Kafka SASL handshake protocol. Client and
 	// server negotiate SASL auth using opaque packets.
 	SASLHandshakeV0 = int16(0)
@@ -114,6 +179,19 @@ type AccessTokenProvider interface {
 	Token() (*AccessToken, error)
 }
 
+// AccessTokenProviderContext is a variant of AccessTokenProvider whose Token
+// method receives a context, allowing implementations that fetch tokens from
+// an identity provider to honor cancellation and deadlines. It is configured
+// through Config.Net.SASL.TokenProviderContext, which takes precedence over
+// Config.Net.SASL.TokenProvider. The context passed by the broker expires
+// after Config.Net.DialTimeout.
+type AccessTokenProviderContext interface {
+	// Token returns an access token. The same reuse and refresh guidelines as
+	// for AccessTokenProvider apply, and the implementation should return
+	// promptly once ctx is done.
+	Token(ctx context.Context) (*AccessToken, error)
+}
+
 // SCRAMClient is a an interface to a SCRAM
 // client implementation.
 type SCRAMClient interface {
@@ -128,9 +206,25 @@ type SCRAMClient interface {
 	Done() bool
 }
 
+// SASLAuthenticator is an interface to a user provided implementation of a
+// SASL mechanism, such as AWS MSK IAM, used when Config.Net.SASL.Mechanism is
+// SASLTypeCustom. A new authenticator is created for every authentication, so
+// implementations can keep the state of the exchange.
+type SASLAuthenticator interface {
+	// Mechanism returns the name of the SASL mechanism, which is advertised to
+	// the broker in the SaslHandshakeRequest.
+	Mechanism() string
+	// Next steps the authenticator through the exchange. It is first called
+	// with a nil challenge to get the initial response, then with the bytes
+	// returned by the broker for each response sent, until it errors or
+	// returns done. The response returned along with done is not sent.
+	Next(challenge []byte) (response []byte, done bool, err error)
+}
+
 type responsePromise struct {
 	requestTime   time.Time
 	correlationID int32
+	intercepted   *RequestInfo // only set when Net.RequestInterceptor is
 	response      protocolBody
 	handler       func([]byte, error)
 	packets       chan []byte
@@ -186,10 +280,18 @@ func (b *Broker) getSockError() error {
 // block waiting for the connection to succeed or fail. To get the effect of a fully synchronous Open call,
 // follow it by a call to Connected(). The only errors Open will return directly are ConfigurationError or
 // AlreadyConnected. If conf is nil, the result of NewConfig() is used.
+// If the previous connection attempt failed and Net.ReconnectBackoff is set, no connection is attempted until
+// the backoff has elapsed, and Connected() reports ErrReconnectBackoff in the meantime.
 func (b *Broker) Open(conf *Config) error {
+	return b.open(conf, true)
+}
+
+// open is Open, optionally ignoring the reconnect backoff.
+func (b *Broker) open(conf *Config, withBackoff bool) error {
 	if !b.opened.CompareAndSwap(false, true) {
 		return ErrAlreadyConnected
 	}
+	b.idleClosed.Store(false)
 
 	if conf == nil {
 		conf = NewConfig()
@@ -206,19 +308,38 @@ func (b *Broker) Open(conf *Config) error {
 		b.metricRegistry = newCleanupRegistry(conf.MetricRegistry)
 	}
 
+	if wait := time.Until(b.reconnectAt); withBackoff && wait > 0 {
+		DebugLogger.Printf("Not reconnecting to broker %s for another %s\n", b.addr, wait)
+		b.connErr = ErrReconnectBackoff
+		b.opened.Store(false)
+		b.lock.Unlock()
+		return nil
+	}
+
+	b.setState(BrokerConnecting)
 	go withRecover(func() {
 		defer b.lock.Unlock()
 
+		addr := b.addr
+		if conf.Net.AddressRewriter != nil {
+			addr = conf.Net.AddressRewriter(b.addr)
+			DebugLogger.Printf("Rewrote broker address %s to %s\n", b.addr, addr)
+		}
+
 		dialer := conf.getDialer()
-		b.conn, b.connErr = dialer.Dial("tcp", b.addr)
+		b.conn, b.connErr = dialer.Dial("tcp", addr)
 		if b.connErr != nil {
 			Logger.Printf("Failed to connect to broker %s: %s\n", b.addr, b.connErr)
 			b.conn = nil
 			b.opened.Store(false)
+			b.backoffReconnectLocked(conf)
+			b.setState(BrokerDisconnected)
 			return
 		}
+		b.dialFailures = 0
+		b.reconnectAt = time.Time{}
 		if conf.Net.TLS.Enable {
-			b.conn = tls.Client(b.conn, validServerNameTLS(b.addr, conf.Net.TLS.Config))
+			b.conn = tls.Client(b.conn, validServerNameTLS(b.addr, conf.Net.TLS.Config, conf.Net.TLS.ServerNameFromBroker))
 		}
 
 		b.conn = newBufConn(b.conn)
@@ -245,7 +366,12 @@ func (b *Broker) Open(conf *Config) error {
 		// Store the response in the brokerAPIVersions map.
 		// It will be used to determine the supported API versions for each request.
 		// This should happen before SASL authentication: https://kafka.apache.org/42/design/protocol/#retrieving-supported-api-versions
-		if conf.ApiVersionsRequest {
+		// The probe is skipped when API versions were cached for the broker.
+		cached := b.cachedAPIVersions.Load()
+		b.usingCachedAPIVersions = cached != nil
+		if b.usingCachedAPIVersions {
+			b.storeAPIVersions(cached)
+		} else if conf.ApiVersionsRequest {
 			apiVersionsResponse, err := b.sendAndReceiveApiVersions(3)
 			if err != nil {
 				if b.maybeCloseLocked(err) {
@@ -273,13 +399,7 @@ func (b *Broker) Open(conf *Config) error {
 				}
 			}
 			if apiVersionsResponse != nil {
-				b.brokerAPIVersions = make(apiVersionMap, len(apiVersionsResponse.ApiKeys))
-				for _, key := range apiVersionsResponse.ApiKeys {
-					b.brokerAPIVersions[key.ApiKey] = &apiVersionRange{
-						minVersion: key.MinVersion,
-						maxVersion: key.MaxVersion,
-					}
-				}
+				b.storeAPIVersions(apiVersionsResponse)
 			}
 		}
 
@@ -287,7 +407,7 @@ func (b *Broker) Open(conf *Config) error {
 			conf.Net.SASL.Version = SASLHandshakeV1
 		}
 
-		useSaslV0 := conf.Net.SASL.Version == SASLHandshakeV0
+		useSaslV0 := conf.Net.SASL.Version == SASLHandshakeV0 || b.legacySASLHandshake(conf)
 		if conf.Net.SASL.Enable && useSaslV0 {
 			b.connErr = b.authenticateViaSASLv0()
 
@@ -300,6 +420,7 @@ func (b *Broker) Open(conf *Config) error {
 				}
 				b.conn = nil
 				b.opened.Store(false)
+				b.setState(BrokerDisconnected)
 				return
 			}
 		}
@@ -325,6 +446,9 @@ func (b *Broker) Open(conf *Config) error {
 		} else {
 			DebugLogger.Printf("Connected to broker at %s (unregistered)\n", b.addr)
 		}
+		b.lastActivity.Store(time.Now().UnixNano())
+		b.scheduleIdleCheck(b.conf.Net.MaxIdleTime)
+		b.setState(BrokerConnected)
 	})
 
 	return nil
@@ -346,6 +470,59 @@ func (b *Broker) Connected() (bool, error) {
 	return b.conn != nil, b.connErr
 }
 
+// StateChanges returns a channel receiving the connection state of the broker
+// every time it changes between BrokerConnecting, BrokerConnected and
+// BrokerDisconnected. Every call returns the same channel, which is never closed.
+//
+// The notifications are lossy: the channel has a small buffer and, rather than
+// blocking the connection of the broker, the oldest pending state is dropped
+// when it is full. The last state received is thus always the current one once
+// the channel is drained, but intermediate states may be missed.
+func (b *Broker) StateChanges() <-chan BrokerConnectionState {
+	b.stateLock.Lock()
+	defer b.stateLock.Unlock()
+
+	if b.stateChanges == nil {
+		b.stateChanges = make(chan BrokerConnectionState, brokerStateChangesBuffer)
+	}
+	return b.stateChanges
+}
+
+// setStateListener sets a function called with every connection state change
+// of the broker, while the broker notifies its own state changes.
+func (b *Broker) setStateListener(listener func(*Broker, BrokerConnectionState)) {
+	b.stateLock.Lock()
+	defer b.stateLock.Unlock()
+
+	b.stateListener = listener
+}
+
+// setState records a connection state change and notifies it without blocking.
+func (b *Broker) setState(state BrokerConnectionState) {
+	b.stateLock.Lock()
+	defer b.stateLock.Unlock()
+
+	if b.state == state {
+		return
+	}
+	b.state = state
+
+	if b.stateChanges != nil {
+		if len(b.stateChanges) == cap(b.stateChanges) {
+			// drop the oldest pending state to make room for the current one,
+			// the send cannot block as only senders hold stateLock
+			select {
+			case <-b.stateChanges:
+			default:
+			}
+		}
+		b.stateChanges <- state
+	}
+	if b.stateListener != nil {
+		b.stateListener(b, state)
+	}
+}
+
 // TLSConnectionState returns the client's TLS connection state. The second return value is false if this is not a tls connection or the connection has not yet been established.
 func (b *Broker) TLSConnectionState() (state tls.ConnectionState, ok bool) {
 	b.lock.Lock()
@@ -369,10 +546,27 @@ func (b *Broker) Close() error {
 	b.lock.Lock()
 	defer b.lock.Unlock()
 
+	b.idleClosed.Store(false)
 	b.connErr = nil
 	return b.closeLocked()
 }
 
+// backoffReconnectLocked delays the next connection attempt after a failed dial,
+// using an exponential backoff with full jitter.
+// NOTE: caller must hold b.lock.
+func (b *Broker) backoffReconnectLocked(conf *Config) {
+	if conf.Net.ReconnectBackoff <= 0 {
+		return
+	}
+	b.dialFailures++
+	backoff := conf.Net.ReconnectBackoff
+	for i := 1; i < b.dialFailures && backoff < conf.Net.ReconnectBackoffMax; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, conf.Net.ReconnectBackoffMax)
+	b.reconnectAt = time.Now().Add(time.Duration(rand.Int63n(int64(backoff) + 1)))
+}
+
 // maybeCloseLocked closes on transport errors and reports whether a close was performed.
 // NOTE: caller must hold b.lock.
 func (b *Broker) maybeCloseLocked(err error) bool {
@@ -405,6 +599,15 @@ func (b *Broker) closeLocked() error {
 	b.responses = nil
 	b.done = nil
 
+	if b.reauthenticationTimer != nil {
+		b.reauthenticationTimer.Stop()
+		b.reauthenticationTimer = nil
+	}
+	if b.idleTimer != nil {
+		b.idleTimer.Stop()
+		b.idleTimer = nil
+	}
+
 	b.metricRegistry.UnregisterAll()
 
 	if err == nil {
@@ -413,6 +616,7 @@ func (b *Broker) closeLocked() error {
 		Logger.Printf("Error while closing connection to broker %s: %s\n", b.addr, err)
 	}
 	b.opened.Store(false)
+	b.setState(BrokerDisconnected)
 
 	return err
 }
@@ -498,6 +702,19 @@ func (b *Broker) GetAvailableOffsets(request *OffsetRequest) (*OffsetResponse, e
 	return response, nil
 }
 
+// OffsetForLeaderEpoch sends a request to look up the end offsets of leader
+// epochs and returns the response or error
+func (b *Broker) OffsetForLeaderEpoch(request *OffsetForLeaderEpochRequest) (*OffsetForLeaderEpochResponse, error) {
+	response := new(OffsetForLeaderEpochResponse)
+	response.Version = request.Version
+
+	if err := b.sendAndReceive(request, response); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
 // ProduceCallback function is called once the produce response has been parsed
 // or could not be read.
 type ProduceCallback func(*ProduceResponse, error)
@@ -515,6 +732,8 @@ type ProduceCallback func(*ProduceResponse, error)
 //
 // Make sure not to Close the broker in the callback as it will lead to a deadlock.
 func (b *Broker) AsyncProduce(request *ProduceRequest, cb ProduceCallback) error {
+	b.reopenIfIdleClosed()
+
 	b.lock.Lock()
 	defer b.lock.Unlock()
 
@@ -553,13 +772,32 @@ func (b *Broker) AsyncProduce(request *ProduceRequest, cb ProduceCallback) error
 	return b.sendWithPromise(request, promise)
 }
 
-// Produce returns a produce response or error
+// Produce sends request directly to this broker, bypassing the partitioner and
+// leader discovery of the producers, and returns the produce response or error.
+// This is an advanced API meant for custom producers and tooling: the caller is
+// responsible for sending to the partition leader and for handling retriable
+// errors in the response.
+//
+// The request version is treated as the maximum the caller supports and is
+// lowered to the range advertised by the broker. A request built with record
+// batches (version 3 or above) fails with ErrUnsupportedVersion rather than
+// being downgraded to a version that can only carry legacy message sets.
+// Config.Net timeouts apply and the standard request metrics are recorded.
+//
+// When configured with RequiredAcks == NoResponse, a nil response is returned
+// once the request has been written.
 func (b *Broker) Produce(request *ProduceRequest) (*ProduceResponse, error) {
 	var (
 		response *ProduceResponse
 		err      error
 	)
 
+	if request.Version >= 3 {
+		if _, ok := b.negotiateApiVersion(request, 3); !ok {
+			return nil, ErrUnsupportedVersion
+		}
+	}
+
 	if request.RequiredAcks == NoResponse {
 		err = b.sendAndReceive(request, nil)
 	} else {
@@ -576,6 +814,11 @@ func (b *Broker) Produce(request *ProduceRequest) (*ProduceResponse, error) {
 
 // Fetch returns a FetchResponse or error
 func (b *Broker) Fetch(request *FetchRequest) (*FetchResponse, error) {
+	return b.fetch(request, new(FetchResponse))
+}
+
+// fetch sends request and decodes the reply into response.
+func (b *Broker) fetch(request *FetchRequest, response *FetchResponse) (*FetchResponse, error) {
 	defer func() {
 		// snapshot meters under the lock; Open may reassign them on reconnect
 		b.lock.Lock()
@@ -590,8 +833,6 @@ func (b *Broker) Fetch(request *FetchRequest) (*FetchResponse, error) {
 		}
 	}()
 
-	response := new(FetchResponse)
-
 	err := b.sendAndReceive(request, response)
 	if err != nil {
 		return nil, err
@@ -992,6 +1233,58 @@ func (b *Broker) DescribeUserScramCredentials(req *DescribeUserScramCredentialsR
 	return res, err
 }
 
+// DescribeProducers sends a request to describe the active producers of the
+// given partitions and returns the response or error
+func (b *Broker) DescribeProducers(request *DescribeProducersRequest) (*DescribeProducersResponse, error) {
+	response := new(DescribeProducersResponse)
+	response.Version = request.Version
+
+	if err := b.sendAndReceive(request, response); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// DescribeTransactions sends a request to describe the transactions of the
+// given transactional IDs and returns the response or error
+func (b *Broker) DescribeTransactions(request *DescribeTransactionsRequest) (*DescribeTransactionsResponse, error) {
+	response := new(DescribeTransactionsResponse)
+	response.Version = request.Version
+
+	if err := b.sendAndReceive(request, response); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// ListTransactions sends a request to list the transactions known to the
+// broker and returns the response or error
+func (b *Broker) ListTransactions(request *ListTransactionsRequest) (*ListTransactionsResponse, error) {
+	response := new(ListTransactionsResponse)
+	response.Version = request.Version
+
+	if err := b.sendAndReceive(request, response); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// ConsumerGroupHeartbeat sends a KIP-848 consumer group heartbeat request and
+// returns the response.
+func (b *Broker) ConsumerGroupHeartbeat(request *ConsumerGroupHeartbeatRequest) (*ConsumerGroupHeartbeatResponse, error) {
+	response := new(ConsumerGroupHeartbeatResponse)
+	response.Version = request.Version
+
+	if err := b.sendAndReceive(request, response); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
 func (b *Broker) AlterUserScramCredentials(req *AlterUserScramCredentialsRequest) (*AlterUserScramCredentialsResponse, error) {
 	res := new(AlterUserScramCredentialsResponse)
 
@@ -1102,6 +1395,33 @@ func (b *Broker) sendWithPromise(rb protocolBody, promise *responsePromise) erro
 	return b.sendInternal(rb, promise)
 }
 
+// clientID returns the client ID to send in the header of rb: the
+// Producer.ClientID or Consumer.ClientID override for produce and fetch
+// requests when set, the global ClientID otherwise.
+func (b *Broker) clientID(rb protocolBody) string {
+	switch rb.key() {
+	case apiKeyProduce:
+		if b.conf.Producer.ClientID != "" {
+			return b.conf.Producer.ClientID
+		}
+	case apiKeyFetch:
+		if b.conf.Consumer.ClientID != "" {
+			return b.conf.Consumer.ClientID
+		}
+	}
+	return b.conf.ClientID
+}
+
+// nextCorrelationID returns the correlation ID of the next request, obtained
+// from Net.CorrelationIDGenerator when set.
+// b.lock must be held by caller
+func (b *Broker) nextCorrelationID() int32 {
+	if generate := b.conf.Net.CorrelationIDGenerator; generate != nil {
+		return generate()
+	}
+	return b.correlationID
+}
+
 // b.lock must be held by caller
 func (b *Broker) sendInternal(rb protocolBody, promise *responsePromise) error {
 	// try restricting API version to ranges advertised by the broker
@@ -1118,7 +1438,7 @@ func (b *Broker) sendInternal(rb protocolBody, promise *responsePromise) error {
 		return ErrUnsupportedVersion
 	}
 
-	req := &request{correlationID: b.correlationID, clientID: b.conf.ClientID, body: rb}
+	req := &request{correlationID: b.nextCorrelationID(), clientID: b.clientID(rb), body: rb}
 	buf, err := encode(req, b.metricRegistry)
 	if err != nil {
 		return err
@@ -1127,6 +1447,12 @@ func (b *Broker) sendInternal(rb protocolBody, promise *responsePromise) error {
 	// check and wait if throttled
 	b.waitIfThrottled()
 
+	var intercepted *RequestInfo
+	if interceptor := b.conf.Net.RequestInterceptor; interceptor != nil {
+		intercepted = &RequestInfo{APIKey: rb.key(), APIVersion: rb.version(), CorrelationID: req.correlationID, Body: rb}
+		safelyInterceptSend(interceptor, b, *intercepted)
+	}
+
 	requestTime := time.Now()
 	// Will be decremented in responseReceiver (except error or request with NoResponse)
 	b.addRequestInFlightMetrics(1)
@@ -1135,30 +1461,48 @@ func (b *Broker) sendInternal(rb protocolBody, promise *responsePromise) error {
 	b.updateProtocolMetrics(rb)
 	if err != nil {
 		b.addRequestInFlightMetrics(-1)
+		b.interceptResponse(intercepted, time.Since(requestTime), err)
 		return err
 	}
 	b.correlationID++
+	b.lastActivity.Store(time.Now().UnixNano())
 
 	if promise == nil {
 		// Record request latency without the response
-		b.updateRequestLatencyAndInFlightMetrics(time.Since(requestTime))
+		requestLatency := time.Since(requestTime)
+		b.updateRequestLatencyAndInFlightMetrics(requestLatency)
+		b.interceptResponse(intercepted, requestLatency, nil)
 		return nil
 	}
 
 	promise.requestTime = requestTime
 	promise.correlationID = req.correlationID
+	promise.intercepted = intercepted
+	b.inFlight.Add(1)
 	b.responses <- promise
 
 	return nil
 }
 
+// interceptResponse notifies the Net.RequestInterceptor of the outcome of an
+// intercepted request, req being nil if there is no interceptor.
+func (b *Broker) interceptResponse(req *RequestInfo, latency time.Duration, err error) {
+	if req != nil {
+		safelyInterceptResponse(b.conf.Net.RequestInterceptor, b, *req, latency, err)
+	}
+}
+
 func (b *Broker) sendAndReceive(req protocolBody, res protocolBody) error {
+	b.reopenIfIdleClosed()
+
 	b.lock.Lock()
 	defer b.lock.Unlock()
 
 	promise, err := b.send(req, res)
 	if err != nil {
-		b.maybeCloseLocked(err)
+		if b.maybeCloseLocked(err) {
+			b.dropCachedAPIVersions(err)
+		}
 		return err
 	}
 
@@ -1168,7 +1512,9 @@ func (b *Broker) sendAndReceive(req protocolBody, res protocolBody) error {
 
 	err = handleResponsePromise(req, res, promise, b.metricRegistry)
 	if err != nil {
-		b.maybeCloseLocked(err)
+		if b.maybeCloseLocked(err) || errors.Is(err, ErrUnsupportedVersion) {
+			b.dropCachedAPIVersions(err)
+		}
 		return err
 	}
 	if res != nil {
@@ -1273,7 +1619,8 @@ func (b *Broker) responseReceiver() {
 			// This was previously incremented in send() and
 			// we are not calling updateIncomingCommunicationMetrics()
 			b.addRequestInFlightMetrics(-1)
-			promise.handle(nil, dead)
+			b.interceptResponse(promise.intercepted, time.Since(promise.requestTime), dead)
+			b.completePromise(promise, nil, dead)
 			continue
 		}
 
@@ -1285,7 +1632,17 @@ func (b *Broker) responseReceiver() {
 		if err != nil {
 			b.updateIncomingCommunicationMetrics(bytesReadHeader, requestLatency)
 			dead = err
-			promise.handle(nil, err)
+			b.interceptResponse(promise.intercepted, requestLatency, err)
+			b.completePromise(promise, nil, err)
+			continue
+		}
+
+		if length := int64(binary.BigEndian.Uint32(header)); length > b.maxResponseSize() {
+			// the rest of the response cannot be skipped safely
+			b.updateIncomingCommunicationMetrics(bytesReadHeader, requestLatency)
+			dead = fmt.Errorf("%w: %d bytes from broker %s", ErrResponseTooLarge, length, b.addr)
+			b.interceptResponse(promise.intercepted, requestLatency, dead)
+			b.completePromise(promise, nil, dead)
 			continue
 		}
 
@@ -1294,7 +1651,8 @@ func (b *Broker) responseReceiver() {
 		if err != nil {
 			b.updateIncomingCommunicationMetrics(bytesReadHeader, requestLatency)
 			dead = err
-			promise.handle(nil, err)
+			b.interceptResponse(promise.intercepted, requestLatency, err)
+			b.completePromise(promise, nil, err)
 			continue
 		}
 		if decodedHeader.correlationID != promise.correlationID {
@@ -1302,7 +1660,8 @@ func (b *Broker) responseReceiver() {
 			// TODO if decoded ID < cur ID, discard until we catch up
 			// TODO if decoded ID > cur ID, save it so when cur ID catches up we have a response
 			dead = PacketDecodingError{fmt.Sprintf("correlation ID didn't match, wanted %d, got %d", promise.correlationID, decodedHeader.correlationID)}
-			promise.handle(nil, dead)
+			b.interceptResponse(promise.intercepted, requestLatency, dead)
+			b.completePromise(promise, nil, dead)
 			continue
 		}
 
@@ -1311,15 +1670,25 @@ func (b *Broker) responseReceiver() {
 		b.updateIncomingCommunicationMetrics(bytesReadHeader+bytesReadBody, requestLatency)
 		if err != nil {
 			dead = err
-			promise.handle(nil, err)
+			b.interceptResponse(promise.intercepted, requestLatency, err)
+			b.completePromise(promise, nil, err)
 			continue
 		}
 
-		promise.handle(buf, nil)
+		b.interceptResponse(promise.intercepted, requestLatency, nil)
+		b.completePromise(promise, buf, nil)
 	}
 	close(b.done)
 }
 
+// completePromise hands the response or error to promise and records the
+// activity on the connection.
+func (b *Broker) completePromise(promise *responsePromise, packets []byte, err error) {
+	promise.handle(packets, err)
+	b.inFlight.Add(-1)
+	b.lastActivity.Store(time.Now().UnixNano())
+}
+
 func getHeaderLength(headerVersion int16) int8 {
 	if headerVersion < 1 {
 		return 8
@@ -1363,6 +1732,71 @@ func shouldCloseBrokerConn(err error) bool {
 	return false
 }
 
+// storeAPIVersions caches the API version ranges advertised in res, they are
+// used to restrict the version of the requests sent to the broker.
+// b.lock must be held by caller
+func (b *Broker) storeAPIVersions(res *ApiVersionsResponse) {
+	b.brokerAPIVersions = make(apiVersionMap, len(res.ApiKeys))
+	for _, key := range res.ApiKeys {
+		b.brokerAPIVersions[key.ApiKey] = &apiVersionRange{
+			minVersion: key.MinVersion,
+			maxVersion: key.MaxVersion,
+		}
+	}
+}
+
+// maxResponseSize returns the largest response accepted from the broker, the
+// smaller of Net.MaxResponseSize, when set, and the global MaxResponseSize.
+func (b *Broker) maxResponseSize() int64 {
+	limit := int64(MaxResponseSize)
+	if size := int64(b.conf.Net.MaxResponseSize); size > 0 && size < limit {
+		limit = size
+	}
+	return limit
+}
+
+// setCachedAPIVersions sets the API versions to store on the next connection
+// instead of probing the broker, nil restores the probe. It does not take
+// b.lock, so it may be called under the client lock.
+func (b *Broker) setCachedAPIVersions(res *ApiVersionsResponse) {
+	b.cachedAPIVersions.Store(res)
+}
+
+// dropCachedAPIVersions forgets the cached API versions when a request sent
+// with them failed with err, either ErrUnsupportedVersion or a transport error
+// as brokers close the connection on request versions they do not support.
+// The connection is closed so that the broker is probed when reopened.
+// b.lock must be held by caller
+func (b *Broker) dropCachedAPIVersions(err error) {
+	if !b.usingCachedAPIVersions {
+		return
+	}
+
+	Logger.Printf("Dropping the cached API versions of broker %s after error: %s\n", b.addr, err)
+	b.cachedAPIVersions.Store(nil)
+	b.usingCachedAPIVersions = false
+	b.brokerAPIVersions = nil
+	if b.conn != nil {
+		_ = b.closeLocked()
+	}
+}
+
+// apiVersions returns a copy of the cached API version ranges of the broker,
+// or nil if it has not advertised them yet.
+func (b *Broker) apiVersions() map[int16]ApiVersionRange {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.brokerAPIVersions == nil {
+		return nil
+	}
+	versions := make(map[int16]ApiVersionRange, len(b.brokerAPIVersions))
+	for key, r := range b.brokerAPIVersions {
+		versions[key] = ApiVersionRange{MinVersion: r.minVersion, MaxVersion: r.maxVersion}
+	}
+	return versions
+}
+
 func (b *Broker) sendAndReceiveApiVersions(v int16) (*ApiVersionsResponse, error) {
 	rb := &ApiVersionsRequest{
 		Version:               v,
@@ -1370,12 +1804,18 @@ func (b *Broker) sendAndReceiveApiVersions(v int16) (*ApiVersionsResponse, error
 		ClientSoftwareVersion: version(),
 	}
 
-	req := &request{correlationID: b.correlationID, clientID: b.conf.ClientID, body: rb}
+	req := &request{correlationID: b.nextCorrelationID(), clientID: b.conf.ClientID, body: rb}
 	buf, err := encode(req, b.metricRegistry)
 	if err != nil {
 		return nil, err
 	}
 
+	var intercepted *RequestInfo
+	if interceptor := b.conf.Net.RequestInterceptor; interceptor != nil {
+		intercepted = &RequestInfo{APIKey: rb.key(), APIVersion: rb.version(), CorrelationID: req.correlationID, Body: rb}
+		safelyInterceptSend(interceptor, b, *intercepted)
+	}
+
 	requestTime := time.Now()
 	// Will be decremented in updateIncomingCommunicationMetrics (except error)
 	b.addRequestInFlightMetrics(1)
@@ -1383,6 +1823,7 @@ func (b *Broker) sendAndReceiveApiVersions(v int16) (*ApiVersionsResponse, error
 	b.updateOutgoingCommunicationMetrics(bytes)
 	if err != nil {
 		b.addRequestInFlightMetrics(-1)
+		b.interceptResponse(intercepted, time.Since(requestTime), err)
 		Logger.Printf("Failed to send ApiVersionsRequest V%d to %s: %s\n", v, b.addr, err)
 		return nil, err
 	}
@@ -1395,6 +1836,7 @@ func (b *Broker) sendAndReceiveApiVersions(v int16) (*ApiVersionsResponse, error
 	_, err = b.readFull(header)
 	if err != nil {
 		b.addRequestInFlightMetrics(-1)
+		b.interceptResponse(intercepted, time.Since(requestTime), err)
 		Logger.Printf("Failed to read ApiVersionsResponse V%d header from %s: %s\n", v, b.addr, err)
 		return nil, err
 	}
@@ -1407,11 +1849,14 @@ func (b *Broker) sendAndReceiveApiVersions(v int16) (*ApiVersionsResponse, error
 	n, err := b.readFull(payload)
 	if err != nil {
 		b.addRequestInFlightMetrics(-1)
+		b.interceptResponse(intercepted, time.Since(requestTime), err)
 		Logger.Printf("Failed to read ApiVersionsResponse V%d payload from %s: %s\n", v, b.addr, err)
 		return nil, err
 	}
 
-	b.updateIncomingCommunicationMetrics(n+8, time.Since(requestTime))
+	requestLatency := time.Since(requestTime)
+	b.updateIncomingCommunicationMetrics(n+8, requestLatency)
+	b.interceptResponse(intercepted, requestLatency, nil)
 	res := &ApiVersionsResponse{Version: rb.version()}
 	err = versionedDecode(payload, res, rb.version(), b.metricRegistry)
 	if err != nil {
@@ -1428,6 +1873,24 @@ func (b *Broker) sendAndReceiveApiVersions(v int16) (*ApiVersionsResponse, error
 	return res, nil
 }
 
+// legacySASLHandshake reports whether SASL v1 is configured but the broker
+// only supports SaslHandshake v0, which predates SaslAuthenticate, in which
+// case the authentication bytes are sent directly on the connection. That is
+// the case when Config.Version is older than Kafka 1.0 or when the broker
+// advertises SaslHandshake v0 only. Mechanisms requiring v1 never fall back.
+// b.lock must be held by caller
+func (b *Broker) legacySASLHandshake(conf *Config) bool {
+	switch conf.Net.SASL.Mechanism {
+	case SASLTypeOAuth, SASLTypeCustom:
+		return false
+	}
+	if !conf.Version.IsAtLeast(V1_0_0_0) {
+		return true
+	}
+	handshakeVersions := b.brokerAPIVersions[apiKeySaslHandshake]
+	return handshakeVersions != nil && handshakeVersions.maxVersion < SASLHandshakeV1
+}
+
 func (b *Broker) authenticateViaSASLv0() error {
 	switch b.conf.Net.SASL.Mechanism {
 	case SASLTypeSCRAMSHA256, SASLTypeSCRAMSHA512:
@@ -1441,8 +1904,14 @@ func (b *Broker) authenticateViaSASLv0() error {
 
 func (b *Broker) authenticateViaSASLv1() error {
 	metricRegistry := b.metricRegistry
+	mechanism := string(b.conf.Net.SASL.Mechanism)
+	var customAuthenticator SASLAuthenticator
+	if b.conf.Net.SASL.Mechanism == SASLTypeCustom {
+		customAuthenticator = b.conf.Net.SASL.CustomAuthenticator()
+		mechanism = customAuthenticator.Mechanism()
+	}
 	if b.conf.Net.SASL.Handshake {
-		handshakeRequest := &SaslHandshakeRequest{Mechanism: string(b.conf.Net.SASL.Mechanism), Version: b.conf.Net.SASL.Version}
+		handshakeRequest := &SaslHandshakeRequest{Mechanism: mechanism, Version: b.conf.Net.SASL.Version}
 		handshakeResponse := new(SaslHandshakeResponse)
 		prom := makeResponsePromise(handshakeResponse)
 
@@ -1493,14 +1962,15 @@ func (b *Broker) authenticateViaSASLv1() error {
 	case SASLTypeGSSAPI:
 		b.kerberosAuthenticator.Config = &b.conf.Net.SASL.GSSAPI
 		if b.kerberosAuthenticator.NewKerberosClientFunc == nil {
-			b.kerberosAuthenticator.NewKerberosClientFunc = NewKerberosClient
+			b.kerberosAuthenticator.NewKerberosClientFunc = b.newKerberosClient
 		}
 		return b.kerberosAuthenticator.AuthorizeV2(b, authSendReceiver)
 	case SASLTypeOAuth:
-		provider := b.conf.Net.SASL.TokenProvider
-		return b.sendAndReceiveSASLOAuth(authSendReceiver, provider)
+		return b.sendAndReceiveSASLOAuth(authSendReceiver, b.conf.Net.SASL.TokenProvider)
 	case SASLTypeSCRAMSHA256, SASLTypeSCRAMSHA512:
 		return b.sendAndReceiveSASLSCRAMv1(authSendReceiver, b.conf.Net.SASL.SCRAMClientGeneratorFunc())
+	case SASLTypeCustom:
+		return b.sendAndReceiveSASLCustom(authSendReceiver, customAuthenticator)
 	default:
 		return b.sendAndReceiveSASLPlainAuthV1(authSendReceiver)
 	}
@@ -1509,15 +1979,21 @@ func (b *Broker) authenticateViaSASLv1() error {
 func (b *Broker) sendAndReceiveKerberos() error {
 	b.kerberosAuthenticator.Config = &b.conf.Net.SASL.GSSAPI
 	if b.kerberosAuthenticator.NewKerberosClientFunc == nil {
-		b.kerberosAuthenticator.NewKerberosClientFunc = NewKerberosClient
+		b.kerberosAuthenticator.NewKerberosClientFunc = b.newKerberosClient
 	}
 	return b.kerberosAuthenticator.Authorize(b)
 }
 
+// newKerberosClient is NewKerberosClient sharing the keytab cache of the
+// client the broker belongs to.
+func (b *Broker) newKerberosClient(config *GSSAPIConfig) (KerberosClient, error) {
+	return newKerberosClient(config, b.keytabs)
+}
+
 func (b *Broker) sendAndReceiveSASLHandshake(saslType SASLMechanism, version int16) error {
 	rb := &SaslHandshakeRequest{Mechanism: string(saslType), Version: version}
 
-	req := &request{correlationID: b.correlationID, clientID: b.conf.ClientID, body: rb}
+	req := &request{correlationID: b.nextCorrelationID(), clientID: b.conf.ClientID, body: rb}
 	buf, err := encode(req, b.metricRegistry)
 	if err != nil {
 		return err
@@ -1544,6 +2020,14 @@ func (b *Broker) sendAndReceiveSASLHandshake(saslType SASLMechanism, version int
 	}
 
 	length := binary.BigEndian.Uint32(header[:4])
+	if length < 4 {
+		b.addRequestInFlightMetrics(-1)
+		return PacketDecodingError{fmt.Sprintf("SASL handshake response of length %d too small", length)}
+	}
+	if int64(length) > b.maxResponseSize() {
+		b.addRequestInFlightMetrics(-1)
+		return fmt.Errorf("%w: SASL handshake response of %d bytes from broker %s", ErrResponseTooLarge, length, b.addr)
+	}
 	payload := make([]byte, length-4)
 	n, err := b.readFull(payload)
 	if err != nil {
@@ -1595,7 +2079,7 @@ func (b *Broker) sendAndReceiveSASLPlainAuthV0() error {
 	// default to V0 to allow for backward compatibility when SASL is enabled
 	// but not the handshake
 	if b.conf.Net.SASL.Handshake {
-		handshakeErr := b.sendAndReceiveSASLHandshake(SASLTypePlaintext, b.conf.Net.SASL.Version)
+		handshakeErr := b.sendAndReceiveSASLHandshake(SASLTypePlaintext, SASLHandshakeV0)
 		if handshakeErr != nil {
 			Logger.Printf("Error while performing SASL handshake %s: %s\n", b.addr, handshakeErr)
 			return handshakeErr
@@ -1641,14 +2125,15 @@ func (b *Broker) sendAndReceiveSASLPlainAuthV1(authSendReceiver func(authBytes [
 	return err
 }
 
-func currentUnixMilli() int64 {
+// currentUnixMilli is a variable so that tests can fake the clock.
+var currentUnixMilli = func() int64 {
 	return time.Now().UnixNano() / int64(time.Millisecond)
 }
 
 // sendAndReceiveSASLOAuth performs the authentication flow as described by KIP-255
 // https://cwiki.apache.org/confluence/pages/viewpage.action?pageId=75968876
 func (b *Broker) sendAndReceiveSASLOAuth(authSendReceiver func(authBytes []byte) (*SaslAuthenticateResponse, error), provider AccessTokenProvider) error {
-	token, err := provider.Token()
+	token, err := b.accessToken(provider)
 	if err != nil {
 		return err
 	}
@@ -1671,6 +2156,23 @@ func (b *Broker) sendAndReceiveSASLOAuth(authSendReceiver func(authBytes []byte)
 	return err
 }
 
+// accessToken fetches a token for SASL/OAUTHBEARER, preferring
+// Net.SASL.TokenProviderContext over the given provider when it is set.
+func (b *Broker) accessToken(provider AccessTokenProvider) (*AccessToken, error) {
+	ctxProvider := b.conf.Net.SASL.TokenProviderContext
+	if ctxProvider == nil {
+		return provider.Token()
+	}
+
+	ctx := context.Background()
+	if b.conf.Net.DialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.conf.Net.DialTimeout)
+		defer cancel()
+	}
+	return ctxProvider.Token(ctx)
+}
+
 func (b *Broker) sendAndReceiveSASLSCRAMv0() error {
 	if err := b.sendAndReceiveSASLHandshake(b.conf.Net.SASL.Mechanism, SASLHandshakeV0); err != nil {
 		return err
@@ -1710,8 +2212,9 @@ func (b *Broker) sendAndReceiveSASLSCRAMv0() error {
 			return err
 		}
 		payloadLength := binary.BigEndian.Uint32(header)
-		if int64(payloadLength) > int64(MaxResponseSize) {
-			return PacketDecodingError{fmt.Sprintf("SASL response of length %d too large", payloadLength)}
+		if int64(payloadLength) > b.maxResponseSize() {
+			b.addRequestInFlightMetrics(-1)
+			return fmt.Errorf("%w: SASL response of %d bytes from broker %s", ErrResponseTooLarge, payloadLength, b.addr)
 		}
 		payload := make([]byte, int(payloadLength))
 		n, err := b.readFull(payload)
@@ -1760,6 +2263,29 @@ func (b *Broker) sendAndReceiveSASLSCRAMv1(authSendReceiver func(authBytes []byt
 	return nil
 }
 
+func (b *Broker) sendAndReceiveSASLCustom(authSendReceiver func(authBytes []byte) (*SaslAuthenticateResponse, error), authenticator SASLAuthenticator) error {
+	msg, done, err := authenticator.Next(nil)
+	if err != nil {
+		return fmt.Errorf("failed to start the SASL %s exchange with the server: %w", authenticator.Mechanism(), err)
+	}
+
+	for !done {
+		res, err := authSendReceiver(msg)
+		if err != nil {
+			return err
+		}
+
+		msg, done, err = authenticator.Next(res.SaslAuthBytes)
+		if err != nil {
+			Logger.Println("SASL authentication failed", err)
+			return err
+		}
+	}
+
+	DebugLogger.Println("SASL authentication succeeded")
+	return nil
+}
+
 func (b *Broker) createSaslAuthenticateRequest(msg []byte) *SaslAuthenticateRequest {
 	authenticateRequest := SaslAuthenticateRequest{SaslAuthBytes: msg}
 	if b.conf.Version.IsAtLeast(V2_5_0_0) {
@@ -1821,6 +2347,97 @@ func (b *Broker) computeSaslSessionLifetime(res *SaslAuthenticateResponse) {
 	} else {
 		b.clientSessionReauthenticationTimeMs = 0
 	}
+	b.scheduleReauthentication()
+}
+
+// scheduleReauthentication arms a timer to re-authenticate the connection at
+// the session re-authentication time when Net.SASL.ReauthenticationEnabled is set.
+// Otherwise the session is only re-authenticated by the next request sent after it.
+// NOTE: caller must hold b.lock.
+func (b *Broker) scheduleReauthentication() {
+	if b.reauthenticationTimer != nil {
+		b.reauthenticationTimer.Stop()
+		b.reauthenticationTimer = nil
+	}
+	if !b.conf.Net.SASL.ReauthenticationEnabled || b.clientSessionReauthenticationTimeMs <= 0 {
+		return
+	}
+	delay := time.Duration(b.clientSessionReauthenticationTimeMs-currentUnixMilli()) * time.Millisecond
+	b.reauthenticationTimer = time.AfterFunc(max(delay, 0), b.reauthenticate)
+}
+
+// scheduleIdleCheck arms a timer to check after delay whether the connection
+// has been idle for Net.MaxIdleTime.
+// NOTE: caller must hold b.lock.
+func (b *Broker) scheduleIdleCheck(delay time.Duration) {
+	if b.idleTimer != nil {
+		b.idleTimer.Stop()
+		b.idleTimer = nil
+	}
+	if b.conf.Net.MaxIdleTime <= 0 {
+		return
+	}
+	b.idleTimer = time.AfterFunc(delay, b.closeIfIdle)
+}
+
+// closeIfIdle closes the connection if no request is in flight and nothing was
+// sent or received for Net.MaxIdleTime. The next request reopens it.
+func (b *Broker) closeIfIdle() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.conn == nil {
+		return
+	}
+	idle := time.Since(time.Unix(0, b.lastActivity.Load()))
+	if b.inFlight.Load() > 0 {
+		b.scheduleIdleCheck(b.conf.Net.MaxIdleTime)
+		return
+	}
+	if idle < b.conf.Net.MaxIdleTime {
+		b.scheduleIdleCheck(b.conf.Net.MaxIdleTime - idle)
+		return
+	}
+
+	DebugLogger.Printf("Closing connection to broker %s idle for %s\n", b.addr, idle)
+	_ = b.closeLocked()
+	b.idleClosed.Store(true)
+	metrics.GetOrRegisterMeter("reaped-connections", b.conf.MetricRegistry).Mark(1)
+}
+
+// reopenIfIdleClosed reopens the connection if it was closed for being idle,
+// the caller then waits for the connection to complete on b.lock.
+func (b *Broker) reopenIfIdleClosed() {
+	if !b.idleClosed.Load() {
+		return
+	}
+	b.lock.Lock()
+	conf := b.conf
+	b.lock.Unlock()
+
+	DebugLogger.Printf("Reopening idle connection to broker %s\n", b.addr)
+	_ = b.Open(conf)
+}
+
+// reauthenticate re-authenticates the session over the existing connection, as
+// described by KIP-368, without waiting for the next request to be sent.
+func (b *Broker) reauthenticate() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.conn == nil || b.clientSessionReauthenticationTimeMs <= 0 {
+		return
+	}
+	if currentUnixMilli() < b.clientSessionReauthenticationTimeMs {
+		b.scheduleReauthentication()
+		return
+	}
+
+	DebugLogger.Printf("Re-authenticating SASL session with broker %s\n", b.addr)
+	if err := b.authenticateViaSASLv1(); err != nil {
+		// the next request sent will retry the re-authentication
+		Logger.Printf("Error while re-authenticating SASL session with broker %s: %s\n", b.addr, err)
+	}
 }
 
 func (b *Broker) updateIncomingCommunicationMetrics(bytes int, requestLatency time.Duration) {
@@ -1974,21 +2591,27 @@ func (b *Broker) registerCounter(name string) metrics.Counter {
 	return metrics.GetOrRegisterCounter(nameForBroker, b.metricRegistry)
 }
 
-func validServerNameTLS(addr string, cfg *tls.Config) *tls.Config {
+// validServerNameTLS returns the TLS configuration to connect to the broker at
+// addr, taking the ServerName from its hostname if cfg has none or if
+// fromBroker is set.
+func validServerNameTLS(addr string, cfg *tls.Config, fromBroker bool) *tls.Config {
 	if cfg == nil {
 		cfg = &tls.Config{
 			MinVersion: tls.VersionTLS12,
 		}
 	}
-	if cfg.ServerName != "" {
+	if cfg.ServerName != "" && !fromBroker {
 		return cfg
 	}
 
-	c := cfg.Clone()
 	sn, _, err := net.SplitHostPort(addr)
 	if err != nil {
 		Logger.Println(fmt.Errorf("failed to get ServerName from addr %w", err))
+		if cfg.ServerName != "" {
+			return cfg
+		}
 	}
+	c := cfg.Clone()
 	c.ServerName = sn
 	return c
 }
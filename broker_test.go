@@ -4,11 +4,15 @@ package sarama
 
 import (
 	"bytes"
+	"context"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"reflect"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -244,6 +248,100 @@ func TestBrokerClose(t *testing.T) {
 	})
 }
 
+type interceptedResponse struct {
+	req     RequestInfo
+	latency time.Duration
+	err     error
+}
+
+type recordingRequestInterceptor struct {
+	lock      sync.Mutex
+	sent      []RequestInfo
+	responses []interceptedResponse
+	panics    bool
+}
+
+func (i *recordingRequestInterceptor) OnSend(broker *Broker, req RequestInfo) {
+	i.lock.Lock()
+	i.sent = append(i.sent, req)
+	i.lock.Unlock()
+	if i.panics {
+		panic("OnSend")
+	}
+}
+
+func (i *recordingRequestInterceptor) OnResponse(broker *Broker, req RequestInfo, latency time.Duration, err error) {
+	i.lock.Lock()
+	i.responses = append(i.responses, interceptedResponse{req, latency, err})
+	i.lock.Unlock()
+	if i.panics {
+		panic("OnResponse")
+	}
+}
+
+func TestBrokerRequestInterceptor(t *testing.T) {
+	for _, panics := range []bool{false, true} {
+		t.Run(fmt.Sprintf("panics=%t", panics), func(t *testing.T) {
+			mockBroker := NewMockBroker(t, 0)
+			defer mockBroker.Close()
+			mockBroker.SetHandlerByMap(map[string]MockResponse{
+				"MetadataRequest": NewMockMetadataResponse(t).
+					SetBroker(mockBroker.Addr(), mockBroker.BrokerID()),
+			})
+
+			interceptor := &recordingRequestInterceptor{panics: panics}
+			conf := NewTestConfig()
+			conf.ApiVersionsRequest = false
+			conf.Net.RequestInterceptor = interceptor
+			broker := NewBroker(mockBroker.Addr())
+			require.NoError(t, broker.Open(conf))
+			defer safeClose(t, broker)
+
+			metadataRequest := NewMetadataRequest(conf.Version, nil)
+			_, err := broker.GetMetadata(metadataRequest)
+			require.NoError(t, err)
+
+			// requests without response are reported once written
+			produceRequest := &ProduceRequest{RequiredAcks: NoResponse}
+			_, err = broker.Produce(produceRequest)
+			require.NoError(t, err)
+
+			interceptor.lock.Lock()
+			defer interceptor.lock.Unlock()
+			require.Equal(t, []RequestInfo{
+				{APIKey: apiKeyMetadata, APIVersion: metadataRequest.Version, CorrelationID: 0, Body: metadataRequest},
+				{APIKey: apiKeyProduce, APIVersion: produceRequest.Version, CorrelationID: 1, Body: produceRequest},
+			}, interceptor.sent)
+			require.Len(t, interceptor.responses, 2)
+			for i, response := range interceptor.responses {
+				require.Equal(t, interceptor.sent[i], response.req)
+				require.NoError(t, response.err)
+				require.GreaterOrEqual(t, response.latency, time.Duration(0))
+			}
+		})
+	}
+
+	t.Run("transport error", func(t *testing.T) {
+		interceptor := &recordingRequestInterceptor{}
+		conf := NewTestConfig()
+		conf.ApiVersionsRequest = true
+		conf.Net.Proxy.Enable = true
+		conf.Net.Proxy.Dialer = closeImmediatelyDialer{}
+		conf.Net.RequestInterceptor = interceptor
+		broker := NewBroker("127.0.0.1:9092")
+		require.NoError(t, broker.Open(conf))
+		_, connErr := broker.Connected()
+		require.Error(t, connErr)
+
+		interceptor.lock.Lock()
+		defer interceptor.lock.Unlock()
+		require.Len(t, interceptor.sent, 1)
+		require.Equal(t, int16(apiKeyApiVersions), interceptor.sent[0].APIKey)
+		require.Len(t, interceptor.responses, 1)
+		require.ErrorIs(t, interceptor.responses[0].err, connErr)
+	})
+}
+
 // closeImmediatelyDialer is a test dialer that returns a net.Conn whose peer is
 // already closed. This reliably triggers a transport-level failure (e.g. EOF)
 // during ApiVersions negotiation in Broker.Open.
@@ -255,6 +353,143 @@ func (closeImmediatelyDialer) Dial(_, _ string) (net.Conn, error) {
 	return client, nil
 }
 
+func TestBrokerCorrelationIDGenerator(t *testing.T) {
+	mockBroker := NewMockBroker(t, 0)
+	defer mockBroker.Close()
+	mockBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(mockBroker.Addr(), mockBroker.BrokerID()),
+	})
+
+	var next atomic.Int32
+	next.Store(1000)
+	interceptor := &recordingRequestInterceptor{}
+	conf := NewTestConfig()
+	conf.ApiVersionsRequest = false
+	conf.Net.RequestInterceptor = interceptor
+	conf.Net.CorrelationIDGenerator = func() int32 {
+		return next.Add(1)
+	}
+	broker := NewBroker(mockBroker.Addr())
+	require.NoError(t, broker.Open(conf))
+	defer safeClose(t, broker)
+
+	for range 2 {
+		_, err := broker.GetMetadata(NewMetadataRequest(conf.Version, nil))
+		require.NoError(t, err)
+	}
+
+	interceptor.lock.Lock()
+	defer interceptor.lock.Unlock()
+	require.Len(t, interceptor.sent, 2)
+	require.EqualValues(t, 1001, interceptor.sent[0].CorrelationID)
+	require.EqualValues(t, 1002, interceptor.sent[1].CorrelationID)
+}
+
+// newOversizedResponseListener returns a listener replying to the first
+// request with a response header declaring length bytes.
+func newOversizedResponseListener(t *testing.T, length uint32) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		size := make([]byte, 4)
+		if _, err := io.ReadFull(conn, size); err != nil {
+			return
+		}
+		req := make([]byte, binary.BigEndian.Uint32(size))
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return
+		}
+		header := make([]byte, 8)
+		binary.BigEndian.PutUint32(header, length)
+		copy(header[4:], req[4:8]) // correlation ID
+		if _, err := conn.Write(header); err != nil {
+			return
+		}
+		_, _ = io.Copy(io.Discard, conn)
+	}()
+	return ln
+}
+
+func TestBrokerResponseTooLarge(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name            string
+		length          uint32
+		maxResponseSize int32
+	}{
+		{"above Net.MaxResponseSize", 64 * 1024, 1024},
+		{"above the global limit", 1<<31 + 1024, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ln := newOversizedResponseListener(t, tc.length)
+			defer ln.Close()
+
+			conf := NewTestConfig()
+			conf.ApiVersionsRequest = false
+			conf.Net.MaxResponseSize = tc.maxResponseSize
+			broker := NewBroker(ln.Addr().String())
+			require.NoError(t, broker.Open(conf))
+			defer safeClose(t, broker)
+
+			_, err := broker.GetMetadata(&MetadataRequest{})
+			require.ErrorIs(t, err, ErrResponseTooLarge)
+
+			// the connection is unusable afterwards
+			_, err = broker.GetMetadata(&MetadataRequest{})
+			require.ErrorIs(t, err, ErrResponseTooLarge)
+		})
+	}
+}
+
+func TestBrokerSASLHandshakeResponseTooLarge(t *testing.T) {
+	t.Parallel()
+
+	ln := newOversizedResponseListener(t, 64*1024)
+	defer ln.Close()
+
+	conf := NewTestConfig()
+	conf.ApiVersionsRequest = false
+	conf.Net.MaxResponseSize = 1024
+	conf.Net.SASL.Enable = true
+	conf.Net.SASL.Version = SASLHandshakeV0
+	conf.Net.SASL.User = "token"
+	conf.Net.SASL.Password = "password"
+	broker := NewBroker(ln.Addr().String())
+	require.NoError(t, broker.Open(conf))
+
+	connected, err := broker.Connected()
+	require.False(t, connected)
+	require.ErrorIs(t, err, ErrResponseTooLarge)
+}
+
+func TestBrokerResponseTooLargeGlobalLimit(t *testing.T) {
+	// not parallel as the global limit is changed after the config is created
+	conf := NewTestConfig()
+	conf.ApiVersionsRequest = false
+
+	defer func(size int32) { MaxResponseSize = size }(MaxResponseSize)
+	MaxResponseSize = 1024
+	require.NoError(t, conf.Validate())
+
+	ln := newOversizedResponseListener(t, 64*1024)
+	defer ln.Close()
+	broker := NewBroker(ln.Addr().String())
+	require.NoError(t, broker.Open(conf))
+	defer safeClose(t, broker)
+
+	_, err := broker.GetMetadata(&MetadataRequest{})
+	require.ErrorIs(t, err, ErrResponseTooLarge)
+}
+
 func TestBrokerOpenApiVersionsTransportError(t *testing.T) {
 	t.Parallel()
 
@@ -335,6 +570,201 @@ func TestBrokerOpenSASLv1FailThenReopenTransportError(t *testing.T) {
 	_, _ = broker.Connected()
 }
 
+// failingDialer is a test dialer counting the connection attempts and failing
+// all of them.
+type failingDialer struct {
+	dials atomic.Int32
+}
+
+func (d *failingDialer) Dial(_, _ string) (net.Conn, error) {
+	d.dials.Add(1)
+	return nil, errors.New("connection refused")
+}
+
+func TestBrokerReconnectBackoff(t *testing.T) {
+	t.Parallel()
+
+	dialer := &failingDialer{}
+	conf := NewTestConfig()
+	conf.Net.Proxy.Enable = true
+	conf.Net.Proxy.Dialer = dialer
+	conf.Net.ReconnectBackoff = time.Hour
+	conf.Net.ReconnectBackoffMax = 2 * time.Hour
+
+	broker := NewBroker("127.0.0.1:9092")
+
+	for failures := 1; failures <= 3; failures++ {
+		// bypass the backoff to register another failure
+		require.NoError(t, broker.open(conf, false))
+		_, connErr := broker.Connected()
+		require.Error(t, connErr)
+		require.NotErrorIs(t, connErr, ErrReconnectBackoff)
+		require.Equal(t, int32(failures), dialer.dials.Load())
+
+		broker.lock.Lock()
+		require.Equal(t, failures, broker.dialFailures)
+		// the backoff doubles with each failure, up to the max
+		require.WithinRange(t, broker.reconnectAt, time.Now().Add(-time.Second), time.Now().Add(min(time.Hour<<(failures-1), 2*time.Hour)))
+		broker.lock.Unlock()
+	}
+
+	// force a backoff that can not have elapsed yet
+	broker.lock.Lock()
+	broker.reconnectAt = time.Now().Add(time.Hour)
+	broker.lock.Unlock()
+
+	require.NoError(t, broker.Open(conf))
+	connected, connErr := broker.Connected()
+	require.False(t, connected)
+	require.ErrorIs(t, connErr, ErrReconnectBackoff)
+	require.ErrorIs(t, connErr, ErrNotConnected)
+	require.Equal(t, int32(3), dialer.dials.Load(), "expected no dial during the backoff")
+
+	_, err := broker.GetMetadata(&MetadataRequest{})
+	require.ErrorIs(t, err, ErrReconnectBackoff)
+
+	// the backoff is reset once connected
+	mockBroker := NewMockBroker(t, 0)
+	defer mockBroker.Close()
+	broker.lock.Lock()
+	broker.addr = mockBroker.Addr()
+	broker.reconnectAt = time.Now().Add(-time.Millisecond)
+	broker.lock.Unlock()
+
+	conf.Net.Proxy.Enable = false
+	require.NoError(t, broker.Open(conf))
+	connected, connErr = broker.Connected()
+	require.True(t, connected)
+	require.NoError(t, connErr)
+	broker.lock.Lock()
+	require.Zero(t, broker.dialFailures)
+	require.True(t, broker.reconnectAt.IsZero())
+	broker.lock.Unlock()
+	safeClose(t, broker)
+}
+
+func TestBrokerReconnectBackoffDisabled(t *testing.T) {
+	t.Parallel()
+
+	dialer := &failingDialer{}
+	conf := NewTestConfig()
+	conf.Net.Proxy.Enable = true
+	conf.Net.Proxy.Dialer = dialer
+
+	broker := NewBroker("127.0.0.1:9092")
+	for i := 1; i <= 2; i++ {
+		require.NoError(t, broker.Open(conf))
+		_, connErr := broker.Connected()
+		require.Error(t, connErr)
+		require.NotErrorIs(t, connErr, ErrReconnectBackoff)
+		require.Equal(t, int32(i), dialer.dials.Load())
+	}
+}
+
+func TestBrokerMaxIdleTime(t *testing.T) {
+	t.Parallel()
+
+	mockBroker := NewMockBroker(t, 0)
+	defer mockBroker.Close()
+	mockBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t),
+		"ProduceRequest":  NewMockProduceResponse(t),
+	})
+
+	conf := NewTestConfig()
+	conf.Net.MaxIdleTime = 50 * time.Millisecond
+	reaped := metrics.GetOrRegisterMeter("reaped-connections", conf.MetricRegistry)
+
+	broker := NewBroker(mockBroker.Addr())
+	states := broker.StateChanges()
+	require.NoError(t, broker.Open(conf))
+	require.Equal(t, BrokerConnecting, <-states)
+	require.Equal(t, BrokerConnected, <-states)
+
+	// a request in flight for longer than the idle time keeps the connection
+	mockBroker.SetLatency(200 * time.Millisecond)
+	responseErrs := make(chan error, 1)
+	request := &ProduceRequest{RequiredAcks: WaitForLocal}
+	require.NoError(t, broker.AsyncProduce(request, func(_ *ProduceResponse, err error) {
+		responseErrs <- err
+	}))
+	require.NoError(t, <-responseErrs)
+	require.Zero(t, reaped.Count(), "expected no reaped connection with a request in flight")
+	mockBroker.SetLatency(0)
+
+	// the idle connection is closed
+	select {
+	case state := <-states:
+		require.Equal(t, BrokerDisconnected, state)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "expected the idle connection to be closed")
+	}
+	require.Equal(t, int64(1), reaped.Count())
+
+	// and reopened transparently by the next request
+	_, err := broker.GetMetadata(&MetadataRequest{})
+	require.NoError(t, err)
+	connected, err := broker.Connected()
+	require.NoError(t, err)
+	require.True(t, connected)
+
+	safeClose(t, broker)
+}
+
+func TestBrokerStateChanges(t *testing.T) {
+	t.Parallel()
+
+	mockBroker := NewMockBroker(t, 0)
+	defer mockBroker.Close()
+
+	broker := NewBroker(mockBroker.Addr())
+	states := broker.StateChanges()
+	require.Equal(t, states, broker.StateChanges(), "expected the same channel")
+
+	conf := NewTestConfig()
+	require.NoError(t, broker.Open(conf))
+	connected, err := broker.Connected()
+	require.NoError(t, err)
+	require.True(t, connected)
+	require.Equal(t, BrokerConnecting, <-states)
+	require.Equal(t, BrokerConnected, <-states)
+
+	safeClose(t, broker)
+	require.Equal(t, BrokerDisconnected, <-states)
+
+	// a failed connection attempt goes back to disconnected
+	conf.Net.Proxy.Enable = true
+	conf.Net.Proxy.Dialer = &failingDialer{}
+	require.NoError(t, broker.Open(conf))
+	_, err = broker.Connected()
+	require.Error(t, err)
+	require.Equal(t, BrokerConnecting, <-states)
+	require.Equal(t, BrokerDisconnected, <-states)
+	require.Empty(t, states)
+}
+
+func TestBrokerStateChangesDropsOldest(t *testing.T) {
+	t.Parallel()
+
+	broker := NewBroker("127.0.0.1:9092")
+	states := broker.StateChanges()
+
+	// nobody is reading, the notifications must not block
+	for i := 0; i < 3*brokerStateChangesBuffer; i++ {
+		broker.setState(BrokerConnecting)
+		broker.setState(BrokerConnected)
+	}
+	broker.setState(BrokerDisconnected)
+
+	require.Len(t, states, brokerStateChangesBuffer)
+	var last BrokerConnectionState
+	for len(states) > 0 {
+		last = <-states
+	}
+	require.Equal(t, BrokerDisconnected, last, "expected the current state to be kept")
+	require.Equal(t, "disconnected", last.String())
+}
+
 func TestBrokerFetch(t *testing.T) {
 	t.Run("metric mark does not race with concurrent reopen", func(t *testing.T) {
 		mb := NewMockBroker(t, 1)
@@ -367,6 +797,73 @@ func TestBrokerFetch(t *testing.T) {
 	})
 }
 
+func TestBrokerProduce(t *testing.T) {
+	newRequest := func() *ProduceRequest {
+		request := &ProduceRequest{RequiredAcks: WaitForLocal, Timeout: 1000, Version: 7}
+		request.AddBatch("my_topic", 0, &RecordBatch{
+			Version: 2,
+			Codec:   CompressionNone,
+			Records: []*Record{{Value: []byte("value")}},
+		})
+		return request
+	}
+
+	t.Run("version negotiated with the broker", func(t *testing.T) {
+		mb := NewMockBroker(t, 1)
+		defer mb.Close()
+		mb.SetHandlerByMap(map[string]MockResponse{
+			"ApiVersionsRequest": NewMockApiVersionsResponse(t).SetApiKeys([]ApiVersionsResponseKey{
+				{ApiKey: apiKeyProduce, MinVersion: 0, MaxVersion: 5},
+			}),
+			"ProduceRequest": NewMockProduceResponse(t),
+		})
+
+		conf := NewTestConfig()
+		conf.Version = V2_4_0_0
+		conf.ApiVersionsRequest = true
+		broker := NewBroker(mb.Addr())
+		require.NoError(t, broker.Open(conf))
+		defer safeClose(t, broker)
+
+		latency := getOrRegisterHistogram("request-latency-in-ms", conf.MetricRegistry)
+		before := latency.Count()
+
+		request := newRequest()
+		response, err := broker.Produce(request)
+		require.NoError(t, err)
+		require.EqualValues(t, 5, request.Version)
+		require.EqualValues(t, 5, response.Version)
+		require.NotNil(t, response.GetBlock("my_topic", 0))
+		require.Greater(t, latency.Count(), before)
+	})
+
+	t.Run("record batches are not downgraded to message sets", func(t *testing.T) {
+		mb := NewMockBroker(t, 1)
+		defer mb.Close()
+		mb.SetHandlerByMap(map[string]MockResponse{
+			"ApiVersionsRequest": NewMockApiVersionsResponse(t).SetApiKeys([]ApiVersionsResponseKey{
+				{ApiKey: apiKeyProduce, MinVersion: 0, MaxVersion: 2},
+			}),
+			"ProduceRequest": NewMockProduceResponse(t),
+		})
+
+		conf := NewTestConfig()
+		conf.Version = V2_4_0_0
+		conf.ApiVersionsRequest = true
+		broker := NewBroker(mb.Addr())
+		require.NoError(t, broker.Open(conf))
+		defer safeClose(t, broker)
+
+		_, err := broker.Produce(newRequest())
+		require.ErrorIs(t, err, ErrUnsupportedVersion)
+		for _, rr := range mb.History() {
+			if _, ok := rr.Request.(*ProduceRequest); ok {
+				t.Fatal("expected no produce request to be sent")
+			}
+		}
+	})
+}
+
 var ErrTokenFailure = errors.New("Failure generating token")
 
 type TokenProvider struct {
@@ -385,6 +882,53 @@ func newTokenProvider(token *AccessToken, err error) *TokenProvider {
 	}
 }
 
+type contextTokenProvider struct {
+	accessToken *AccessToken
+	deadline    time.Time
+}
+
+func (t *contextTokenProvider) Token(ctx context.Context) (*AccessToken, error) {
+	t.deadline, _ = ctx.Deadline()
+	return t.accessToken, ctx.Err()
+}
+
+func TestSASLOAuthBearerContextProvider(t *testing.T) {
+	mockBroker := NewMockBroker(t, 0)
+	mockBroker.SetHandlerByMap(map[string]MockResponse{
+		"SaslAuthenticateRequest": NewMockSaslAuthenticateResponse(t),
+		"SaslHandshakeRequest":    NewMockSaslHandshakeResponse(t).SetEnabledMechanisms([]string{SASLTypeOAuth}),
+	})
+	defer mockBroker.Close()
+
+	ctxProvider := &contextTokenProvider{accessToken: &AccessToken{Token: "access-token-123"}}
+
+	conf := NewTestConfig()
+	conf.Net.SASL.Enable = true
+	conf.Net.SASL.Mechanism = SASLTypeOAuth
+	// the legacy provider must not be used when a context-aware one is set
+	conf.Net.SASL.TokenProvider = newTokenProvider(nil, ErrTokenFailure)
+	conf.Net.SASL.TokenProviderContext = ctxProvider
+	conf.Net.DialTimeout = time.Minute
+	conf.Version = V1_0_0_0
+
+	start := time.Now()
+	broker := NewBroker(mockBroker.Addr())
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = broker.Close() })
+
+	if _, err := broker.Connected(); err != nil {
+		t.Fatal(err)
+	}
+	if ctxProvider.deadline.IsZero() {
+		t.Fatal("expected the token context to have a deadline")
+	}
+	if d := ctxProvider.deadline.Sub(start); d < time.Minute || ctxProvider.deadline.After(time.Now().Add(time.Minute)) {
+		t.Errorf("expected the token deadline to be bounded by Net.DialTimeout, got %s", d)
+	}
+}
+
 func TestSASLOAuthBearer(t *testing.T) {
 	testTable := []struct {
 		name                      string
@@ -635,6 +1179,106 @@ func TestSASLSCRAMSHAXXX(t *testing.T) {
 	}
 }
 
+// A mock custom SASL authenticator.
+type mockSASLAuthenticator struct {
+	challenges [][]byte
+}
+
+func (m *mockSASLAuthenticator) Mechanism() string {
+	return "AWS_MSK_IAM"
+}
+
+func (m *mockSASLAuthenticator) Next(challenge []byte) ([]byte, bool, error) {
+	m.challenges = append(m.challenges, challenge)
+	switch {
+	case challenge == nil:
+		return []byte("ping"), false, nil
+	case string(challenge) == "pong":
+		return nil, true, nil
+	default:
+		return nil, false, errors.New("failed to authenticate :(")
+	}
+}
+
+var _ SASLAuthenticator = &mockSASLAuthenticator{}
+
+func TestSASLCustomAuthenticator(t *testing.T) {
+	testTable := []struct {
+		name            string
+		mockSASLAuthErr KError
+		challengeResp   string
+		expectedErr     error
+	}{
+		{
+			name:          "SASL/CUSTOM successful authentication",
+			challengeResp: "pong",
+		},
+		{
+			name:          "SASL/CUSTOM authenticator error",
+			challengeResp: "gong",
+			expectedErr:   errors.New("failed to authenticate :("),
+		},
+		{
+			name:            "SASL/CUSTOM server authentication error",
+			mockSASLAuthErr: ErrSASLAuthenticationFailed,
+			challengeResp:   "pong",
+			expectedErr:     ErrSASLAuthenticationFailed,
+		},
+	}
+
+	for _, test := range testTable {
+		t.Run(test.name, func(t *testing.T) {
+			mockBroker := NewMockBroker(t, 0)
+			defer mockBroker.Close()
+
+			mockSASLAuthResponse := NewMockSaslAuthenticateResponse(t).SetAuthBytes([]byte(test.challengeResp))
+			if test.mockSASLAuthErr != ErrNoError {
+				mockSASLAuthResponse = mockSASLAuthResponse.SetError(test.mockSASLAuthErr)
+			}
+			mockBroker.SetHandlerByMap(map[string]MockResponse{
+				"SaslAuthenticateRequest": mockSASLAuthResponse,
+				"SaslHandshakeRequest":    NewMockSaslHandshakeResponse(t).SetEnabledMechanisms([]string{"AWS_MSK_IAM"}),
+			})
+
+			authenticator := &mockSASLAuthenticator{}
+			conf := NewTestConfig()
+			conf.Net.SASL.Enable = true
+			conf.Net.SASL.Mechanism = SASLTypeCustom
+			conf.Net.SASL.Version = SASLHandshakeV1
+			conf.Net.SASL.CustomAuthenticator = func() SASLAuthenticator { return authenticator }
+			conf.Version = V1_0_0_0
+
+			broker := NewBroker(mockBroker.Addr())
+			require.NoError(t, broker.Open(conf))
+			t.Cleanup(func() { _ = broker.Close() })
+
+			_, err := broker.Connected()
+			if test.expectedErr != nil {
+				require.Error(t, err)
+				require.ErrorContains(t, err, test.expectedErr.Error())
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, [][]byte{nil, []byte("pong")}, authenticator.challenges)
+			}
+
+			// the handshake advertises the name of the custom mechanism
+			var handshakes, authentications int
+			for _, rr := range mockBroker.History() {
+				switch req := rr.Request.(type) {
+				case *SaslHandshakeRequest:
+					handshakes++
+					require.Equal(t, "AWS_MSK_IAM", req.Mechanism)
+				case *SaslAuthenticateRequest:
+					authentications++
+					require.Equal(t, []byte("ping"), req.SaslAuthBytes)
+				}
+			}
+			require.Equal(t, 1, handshakes)
+			require.Equal(t, 1, authentications)
+		})
+	}
+}
+
 func TestSASLPlainAuth(t *testing.T) {
 	testTable := []struct {
 		name             string
@@ -755,6 +1399,97 @@ func TestSASLPlainAuth(t *testing.T) {
 	}
 }
 
+func TestSASLPlainAuthHandshakeVersion(t *testing.T) {
+	testTable := []struct {
+		name               string
+		version            KafkaVersion
+		handshakeVersions  *ApiVersionsResponseKey // advertised by the broker if set
+		expectHandshakeV0  bool
+		expectAuthenticate bool
+	}{
+		{
+			name:               "SaslHandshake v1 and SaslAuthenticate",
+			version:            V1_0_0_0,
+			expectAuthenticate: true,
+		},
+		{
+			name:               "SaslHandshake v1 advertised by the broker",
+			version:            V2_4_0_0,
+			handshakeVersions:  &ApiVersionsResponseKey{ApiKey: apiKeySaslHandshake, MinVersion: 0, MaxVersion: 1},
+			expectAuthenticate: true,
+		},
+		{
+			name:              "SaslHandshake v0 for versions before 1.0",
+			version:           V0_10_2_0,
+			expectHandshakeV0: true,
+		},
+		{
+			name:              "SaslHandshake v0 advertised by the broker",
+			version:           V2_4_0_0,
+			handshakeVersions: &ApiVersionsResponseKey{ApiKey: apiKeySaslHandshake, MinVersion: 0, MaxVersion: 0},
+			expectHandshakeV0: true,
+		},
+	}
+
+	for _, test := range testTable {
+		t.Run(test.name, func(t *testing.T) {
+			mockBroker := NewMockBroker(t, 0)
+			defer mockBroker.Close()
+
+			handlers := map[string]MockResponse{
+				"SaslAuthenticateRequest": NewMockSaslAuthenticateResponse(t),
+				"SaslHandshakeRequest": NewMockSaslHandshakeResponse(t).
+					SetEnabledMechanisms([]string{SASLTypePlaintext}),
+			}
+			if test.handshakeVersions != nil {
+				handlers["ApiVersionsRequest"] = NewMockApiVersionsResponse(t).
+					SetApiKeys([]ApiVersionsResponseKey{*test.handshakeVersions})
+			}
+			mockBroker.SetHandlerByMap(handlers)
+			var authBytes atomic.Value
+			mockBroker.SetSASLv0Handler(func(b []byte) []byte {
+				authBytes.Store(string(b))
+				return []byte{0, 0, 0, 0}
+			})
+
+			conf := NewTestConfig()
+			conf.Net.SASL.Mechanism = SASLTypePlaintext
+			conf.Net.SASL.Enable = true
+			conf.Net.SASL.User = "token"
+			conf.Net.SASL.Password = "password"
+			conf.Net.SASL.Version = SASLHandshakeV1
+			conf.Version = test.version
+			conf.ApiVersionsRequest = test.handshakeVersions != nil
+
+			broker := NewBroker(mockBroker.Addr())
+			require.NoError(t, broker.Open(conf))
+			t.Cleanup(func() { _ = broker.Close() })
+			connected, err := broker.Connected()
+			require.NoError(t, err)
+			require.True(t, connected)
+
+			var handshakes []int16
+			authenticated := false
+			for _, rr := range mockBroker.History() {
+				switch r := rr.Request.(type) {
+				case *SaslHandshakeRequest:
+					handshakes = append(handshakes, r.Version)
+				case *SaslAuthenticateRequest:
+					authenticated = true
+				}
+			}
+			if test.expectHandshakeV0 {
+				require.Equal(t, []int16{SASLHandshakeV0}, handshakes)
+				require.Equal(t, "\x00token\x00password", authBytes.Load())
+			} else {
+				require.Equal(t, []int16{SASLHandshakeV1}, handshakes)
+				require.Nil(t, authBytes.Load())
+			}
+			require.Equal(t, test.expectAuthenticate, authenticated)
+		})
+	}
+}
+
 // TestSASLReadTimeout ensures that the broker connection won't block forever
 // if the remote end never responds after the handshake
 func TestSASLReadTimeout(t *testing.T) {
@@ -1134,6 +1869,146 @@ loop:
 	mockBroker.Close()
 }
 
+func TestKip368ProactiveReAuthentication(t *testing.T) {
+	sessionLifetimeMs := int64(60 * 1000)
+
+	var now atomic.Int64
+	now.Store(time.Now().UnixMilli())
+	realCurrentUnixMilli := currentUnixMilli
+	currentUnixMilli = now.Load
+	t.Cleanup(func() { currentUnixMilli = realCurrentUnixMilli })
+
+	mockBroker := NewMockBroker(t, 0)
+	defer mockBroker.Close()
+
+	countSaslAuthRequests := func() (count int) {
+		for _, rr := range mockBroker.History() {
+			if _, ok := rr.Request.(*SaslAuthenticateRequest); ok {
+				count++
+			}
+		}
+		return
+	}
+
+	mockBroker.SetHandlerByMap(map[string]MockResponse{
+		"SaslAuthenticateRequest": NewMockSaslAuthenticateResponse(t).
+			SetAuthBytes([]byte("pong")).
+			SetSessionLifetimeMs(sessionLifetimeMs),
+		"SaslHandshakeRequest": NewMockSaslHandshakeResponse(t).
+			SetEnabledMechanisms([]string{SASLTypeSCRAMSHA512}),
+	})
+
+	var scramClients atomic.Int32
+	conf := NewTestConfig()
+	conf.Net.SASL.Enable = true
+	conf.Net.SASL.Mechanism = SASLTypeSCRAMSHA512
+	conf.Net.SASL.Version = SASLHandshakeV1
+	conf.Net.SASL.User = "user"
+	conf.Net.SASL.Password = "pass"
+	conf.Net.SASL.ReauthenticationEnabled = true
+	conf.Net.SASL.SCRAMClientGeneratorFunc = func() SCRAMClient {
+		scramClients.Add(1)
+		return &MockSCRAMClient{}
+	}
+	conf.Version = V2_2_0_0
+
+	broker := NewBroker(mockBroker.Addr())
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = broker.Close() })
+	if connected, err := broker.Connected(); err != nil || !connected {
+		t.Fatal(err)
+	}
+
+	broker.lock.Lock()
+	conn := broker.conn
+	scheduled := broker.reauthenticationTimer != nil
+	broker.lock.Unlock()
+	if !scheduled {
+		t.Fatal("expected a re-authentication to be scheduled")
+	}
+	if count := countSaslAuthRequests(); count != 1 {
+		t.Fatalf("expected 1 SaslAuthenticateRequest during initial authentication, got %d", count)
+	}
+
+	// firing before the session re-authentication time is a no-op
+	broker.reauthenticate()
+	if count := countSaslAuthRequests(); count != 1 {
+		t.Fatalf("expected no re-authentication before the session lifetime, got %d SaslAuthenticateRequests", count)
+	}
+
+	// advance the clock past the session lifetime and fire the timer
+	now.Add(sessionLifetimeMs)
+	broker.reauthenticate()
+
+	if count := countSaslAuthRequests(); count != 2 {
+		t.Fatalf("expected the session to be re-authenticated, got %d SaslAuthenticateRequests", count)
+	}
+	if n := scramClients.Load(); n != 2 {
+		t.Errorf("expected a new SCRAM client for the re-authentication, got %d clients", n)
+	}
+	broker.lock.Lock()
+	sameConn := broker.conn == conn
+	reauthAt := broker.clientSessionReauthenticationTimeMs
+	broker.lock.Unlock()
+	if !sameConn {
+		t.Error("expected the session to be re-authenticated over the existing connection")
+	}
+	if reauthAt <= now.Load() {
+		t.Error("expected the next re-authentication to be scheduled after the current time")
+	}
+}
+
+func TestKip368ProactiveReAuthenticationTimer(t *testing.T) {
+	mockBroker := NewMockBroker(t, 0)
+	defer mockBroker.Close()
+
+	mockBroker.SetHandlerByMap(map[string]MockResponse{
+		"SaslAuthenticateRequest": NewMockSaslAuthenticateResponse(t).
+			SetSessionLifetimeMs(100),
+		"SaslHandshakeRequest": NewMockSaslHandshakeResponse(t).
+			SetEnabledMechanisms([]string{SASLTypePlaintext}),
+	})
+
+	conf := NewTestConfig()
+	conf.Net.SASL.Enable = true
+	conf.Net.SASL.Mechanism = SASLTypePlaintext
+	conf.Net.SASL.Version = SASLHandshakeV1
+	conf.Net.SASL.User = "user"
+	conf.Net.SASL.Password = "pass"
+	conf.Net.SASL.ReauthenticationEnabled = true
+	conf.Version = V2_2_0_0
+
+	broker := NewBroker(mockBroker.Addr())
+	if err := broker.Open(conf); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = broker.Close() })
+	if connected, err := broker.Connected(); err != nil || !connected {
+		t.Fatal(err)
+	}
+
+	// no traffic is sent, the timer alone must re-authenticate the session
+	deadline := time.After(time.Second)
+	for {
+		count := 0
+		for _, rr := range mockBroker.History() {
+			if _, ok := rr.Request.(*SaslAuthenticateRequest); ok {
+				count++
+			}
+		}
+		if count >= 2 {
+			return
+		}
+		select {
+		case <-deadline:
+			t.Fatal("sasl reauth has not occurred within expected timeframe")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
 // We're not testing encoding/decoding here, so most of the requests/responses will be empty for simplicity's sake
 var brokerTestTable = []struct {
 	version  KafkaVersion
@@ -1700,3 +2575,28 @@ func Test_handleThrottledResponse(t *testing.T) {
 		}
 	})
 }
+
+func TestBrokerClientIDOverrides(t *testing.T) {
+	conf := NewTestConfig()
+	conf.ClientID = "global"
+	broker := NewBroker("127.0.0.1:9092")
+	broker.conf = conf
+
+	for _, rb := range []protocolBody{&ProduceRequest{}, &FetchRequest{}, &MetadataRequest{}} {
+		if got := broker.clientID(rb); got != "global" {
+			t.Errorf("expected global client ID for api key %d, got %q", rb.key(), got)
+		}
+	}
+
+	conf.Producer.ClientID = "producer"
+	conf.Consumer.ClientID = "consumer"
+	if got := broker.clientID(&ProduceRequest{}); got != "producer" {
+		t.Errorf("expected producer client ID for produce requests, got %q", got)
+	}
+	if got := broker.clientID(&FetchRequest{}); got != "consumer" {
+		t.Errorf("expected consumer client ID for fetch requests, got %q", got)
+	}
+	if got := broker.clientID(&MetadataRequest{}); got != "global" {
+		t.Errorf("expected global client ID for metadata requests, got %q", got)
+	}
+}
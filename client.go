@@ -57,9 +57,17 @@ type Client interface {
 	Leader(topic string, partitionID int32) (*Broker, error)
 
 	// LeaderAndEpoch returns the leader and its epoch for the current
-	// topic/partition, as determined by querying the cluster metadata.
+	// topic/partition, as determined by querying the cluster metadata. The
+	// epoch is the one the consumer sends as the current leader epoch in its
+	// fetch requests, or -1 if the brokers do not report it (Kafka < 2.1).
 	LeaderAndEpoch(topic string, partitionID int32) (*Broker, int32, error)
 
+	// TopicID returns the unique ID of the given topic (KIP-516), as cached
+	// from the cluster metadata. A topic recreated with the same name gets a
+	// different ID. It returns ErrTopicIDNotAvailable when the brokers do not
+	// report topic IDs, which requires Kafka 2.8 or higher.
+	TopicID(topic string) (Uuid, error)
+
 	// Replicas returns the set of all replica IDs for the given partition.
 	Replicas(topic string, partitionID int32) ([]int32, error)
 
@@ -88,6 +96,29 @@ type Client interface {
 	// OffsetNewest for the offset of the message that will be produced next, or a time.
 	GetOffset(topic string, partitionID int32, time int64) (int64, error)
 
+	// GetOffsetWithEpoch is GetOffset also returning the leader epoch of the
+	// partition for the offset, or -1 if the broker does not report it
+	// (Kafka < 2.1). Like GetOffset, it sends the leader epoch known from the
+	// cached metadata so that a stale leader rejects the request rather than
+	// answering from a diverged log, which lets consumers detect a truncation
+	// after an unclean leader election.
+	GetOffsetWithEpoch(topic string, partitionID int32, time int64) (offset int64, leaderEpoch int32, err error)
+
+	// GetOffsetsByTime queries the cluster to get, for every partition of the
+	// topic, the offset of the first message with a timestamp at or after the
+	// given time, sending a single request to the leader of each partition.
+	// The offset is -1 (OffsetNewest) for the partitions where the time is
+	// beyond the end of the log.
+	GetOffsetsByTime(topic string, t time.Time) (map[int32]int64, error)
+
+	// ReadLastMessages returns the last n messages of the partition, up to
+	// its high water mark at the time of the call, oldest first. They are read
+	// from the last n offsets, or from the log start offset if there are fewer,
+	// so that fewer than n messages are returned when some of these offsets
+	// hold no message, e.g. as the topic is compacted or they are transaction
+	// markers.
+	ReadLastMessages(topic string, partition int32, n int) ([]*ConsumerMessage, error)
+
 	// Coordinator returns the coordinating broker for a consumer group. It will
 	// return a locally cached value if it's available. You can call
 	// RefreshCoordinator to update the cached value. This function only works on
@@ -100,7 +131,7 @@ type Client interface {
 
 	// TransactionCoordinator returns the coordinating broker for a transaction id. It will
 	// return a locally cached value if it's available. You can call
-	// RefreshCoordinator to update the cached value. This function only works on
+	// RefreshTransactionCoordinator to update the cached value. This function only works on
 	// Kafka 0.11.0.0 and higher.
 	TransactionCoordinator(transactionID string) (*Broker, error)
 
@@ -117,6 +148,30 @@ type Client interface {
 	// PartitionNotReadable checks if partition is not readable
 	PartitionNotReadable(topic string, partition int32) bool
 
+	// APIVersions returns the range of versions the broker supports for each
+	// API key, as advertised in the ApiVersionsResponse cached when connecting
+	// to it. The broker is connected to first if needed, and probed with an
+	// ApiVersionsRequest if it has not advertised its versions yet. When
+	// Config.ApiVersionsRequest is disabled the broker is probed on every call,
+	// without caching the versions. Requires Kafka 0.10 or higher.
+	APIVersions(broker *Broker) (map[int16]ApiVersionRange, error)
+
+	// Ping checks that the cluster is reachable by sending an ApiVersionsRequest
+	// to a connected broker, or to a seed broker if none is connected yet. It
+	// returns ctx.Err() if ctx is done first. Unlike RefreshMetadata it neither
+	// updates the cached metadata nor creates topics, which makes it suitable
+	// for liveness and readiness probes. Requires Kafka 0.10 or higher.
+	Ping(ctx context.Context) error
+
+	// SetCachedApiVersions warm-starts the broker with the given ID with a
+	// pre-fetched ApiVersionsResponse, which is stored as its API versions
+	// when connecting to it instead of probing it with an ApiVersionsRequest,
+	// overriding Config.Admin.CachedApiVersions. It takes effect on the next
+	// connection to the broker and is trusted until a request sent with it
+	// fails with ErrUnsupportedVersion or loses the connection, at which point
+	// the broker is probed again. A nil response removes the override.
+	SetCachedApiVersions(brokerID int32, res *ApiVersionsResponse)
+
 	// Close shuts down all broker connections managed by this client. It is required
 	// to call this function before a client object passes out of scope, as it will
 	// otherwise leak memory. You must close any Producers or Consumers using a client
@@ -125,8 +180,27 @@ type Client interface {
 
 	// Closed returns true if the client has already had Close called on it
 	Closed() bool
+
+	// BrokerStateChanges returns a channel receiving the connection state
+	// changes of all the brokers managed by the client. Like
+	// Broker.StateChanges it is lossy: the oldest pending change is dropped
+	// rather than blocking a broker when the channel buffer is full. Every
+	// call returns the same channel, which is closed when the client is
+	// closed, so the disconnection of the brokers it closes may be missed.
+	BrokerStateChanges() <-chan BrokerStateChange
 }
 
+// BrokerStateChange is a connection state change of one of the brokers
+// managed by a Client.
+type BrokerStateChange struct {
+	Broker *Broker
+	State  BrokerConnectionState
+}
+
+// clientStateChangesBuffer is the capacity of the channel returned by
+// Client.BrokerStateChanges.
+const clientStateChangesBuffer = 16
+
 const (
 	// OffsetNewest stands for the log head offset, i.e. the offset that will be
 	// assigned to the next message that will be produced to the partition. You
@@ -159,8 +233,12 @@ type client struct {
 	brokers                 map[int32]*Broker                       // maps broker ids to brokers
 	metadata                map[string]map[int32]*PartitionMetadata // maps topics to partition ids to metadata
 	metadataTopics          map[string]none                         // topics that need to collect metadata
+	topicIDs                map[string]Uuid                         // maps topics to their non-zero topic ID
 	coordinators            map[string]int32                        // Maps consumer group names to coordinating broker IDs
 	transactionCoordinators map[string]int32                        // Maps transaction ids to coordinating broker IDs
+	cachedAPIVersions       map[int32]*ApiVersionsResponse          // Maps broker ids to the API versions set by SetCachedApiVersions
+	brokersVersion          atomic.Uint64                           // Incremented whenever the brokers map changes
+	keytabs                 keytabCache                             // Shared by the brokers to authenticate with Kerberos
 
 	// If the number of partitions is large, we can get some churn calling cachedPartitions,
 	// so the result is cached.  It is important to update this value whenever metadata is changed
@@ -169,6 +247,9 @@ type client struct {
 	lock sync.RWMutex // protects access to the maps that hold cluster state.
 
 	metadataRefresh metadataRefresh
+
+	stateLock    sync.Mutex // protects stateChanges, which is nil once closed
+	stateChanges chan BrokerStateChange
 }
 
 // NewClient creates a new Client. It connects to one of the given broker addresses
@@ -202,9 +283,12 @@ func NewClient(addrs []string, conf *Config) (Client, error) {
 		brokers:                 make(map[int32]*Broker),
 		metadata:                make(map[string]map[int32]*PartitionMetadata),
 		metadataTopics:          make(map[string]none),
+		topicIDs:                make(map[string]Uuid),
 		cachedPartitionsResults: make(map[string][maxPartitionIndex][]int32),
 		coordinators:            make(map[string]int32),
 		transactionCoordinators: make(map[string]int32),
+		cachedAPIVersions:       make(map[int32]*ApiVersionsResponse),
+		stateChanges:            make(chan BrokerStateChange, clientStateChangesBuffer),
 	}
 	refresh := func(topics []string) error {
 		deadline := time.Time{}
@@ -263,6 +347,12 @@ func (client *client) Brokers() []*Broker {
 	return brokers
 }
 
+// brokersGeneration returns a counter incremented whenever the registered
+// brokers change, e.g. on a metadata refresh, to detect changes cheaply.
+func (client *client) brokersGeneration() uint64 {
+	return client.brokersVersion.Load()
+}
+
 func (client *client) Broker(brokerID int32) (*Broker, error) {
 	client.lock.RLock()
 	defer client.lock.RUnlock()
@@ -334,12 +424,51 @@ func (client *client) Close() error {
 	}
 
 	client.brokers = nil
+	client.brokersVersion.Add(1)
 	client.metadata = nil
 	client.metadataTopics = nil
+	client.topicIDs = nil
+
+	client.stateLock.Lock()
+	close(client.stateChanges)
+	client.stateChanges = nil
+	client.stateLock.Unlock()
 
 	return nil
 }
 
+func (client *client) BrokerStateChanges() <-chan BrokerStateChange {
+	client.stateLock.Lock()
+	defer client.stateLock.Unlock()
+
+	if client.stateChanges == nil {
+		// closed client
+		ch := make(chan BrokerStateChange)
+		close(ch)
+		return ch
+	}
+	return client.stateChanges
+}
+
+// brokerStateChanged is the state listener of the brokers managed by the
+// client, it forwards their state changes to BrokerStateChanges.
+func (client *client) brokerStateChanged(broker *Broker, state BrokerConnectionState) {
+	client.stateLock.Lock()
+	defer client.stateLock.Unlock()
+
+	if client.stateChanges == nil {
+		return
+	}
+	if len(client.stateChanges) == cap(client.stateChanges) {
+		// drop the oldest pending change, see Broker.setState
+		select {
+		case <-client.stateChanges:
+		default:
+		}
+	}
+	client.stateChanges <- BrokerStateChange{Broker: broker, State: state}
+}
+
 func (client *client) Closed() bool {
 	client.lock.RLock()
 	defer client.lock.RUnlock()
@@ -415,6 +544,28 @@ func (client *client) getPartitions(topic string, pt partitionType) ([]int32, er
 	return partitions, nil
 }
 
+func (client *client) TopicID(topic string) (Uuid, error) {
+	if client.Closed() {
+		return Uuid{}, ErrClosedClient
+	}
+
+	id, known := client.cachedTopicID(topic)
+	if !known {
+		if err := client.RefreshMetadata(topic); err != nil {
+			return Uuid{}, err
+		}
+		id, known = client.cachedTopicID(topic)
+	}
+
+	if !known {
+		return Uuid{}, ErrUnknownTopicOrPartition
+	}
+	if id == (Uuid{}) {
+		return Uuid{}, ErrTopicIDNotAvailable
+	}
+	return id, nil
+}
+
 func (client *client) Replicas(topic string, partitionID int32) ([]int32, error) {
 	return client.getReplicas(topic, partitionID, func(metadata *PartitionMetadata) []int32 {
 		return metadata.Replicas
@@ -493,6 +644,7 @@ func (client *client) RefreshBrokers(addrs []string) error {
 		safeAsyncClose(broker)
 	}
 	client.brokers = make(map[int32]*Broker)
+	client.brokersVersion.Add(1)
 
 	for _, broker := range client.seedBrokers {
 		safeAsyncClose(broker)
@@ -525,19 +677,72 @@ func (client *client) RefreshMetadata(topics ...string) error {
 }
 
 func (client *client) GetOffset(topic string, partitionID int32, timestamp int64) (int64, error) {
+	offset, _, err := client.GetOffsetWithEpoch(topic, partitionID, timestamp)
+	return offset, err
+}
+
+func (client *client) GetOffsetWithEpoch(topic string, partitionID int32, timestamp int64) (int64, int32, error) {
 	if client.Closed() {
-		return -1, ErrClosedClient
+		return -1, -1, ErrClosedClient
 	}
 
-	offset, err := client.getOffset(topic, partitionID, timestamp)
+	offset, leaderEpoch, err := client.getOffset(topic, partitionID, timestamp)
 	if err != nil {
 		if err := client.RefreshMetadata(topic); err != nil {
-			return -1, err
+			return -1, -1, err
 		}
 		return client.getOffset(topic, partitionID, timestamp)
 	}
 
-	return offset, err
+	return offset, leaderEpoch, err
+}
+
+func (client *client) ReadLastMessages(topic string, partition int32, n int) ([]*ConsumerMessage, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	newestOffset, err := client.GetOffset(topic, partition, OffsetNewest)
+	if err != nil {
+		return nil, err
+	}
+	oldestOffset, err := client.GetOffset(topic, partition, OffsetOldest)
+	if err != nil {
+		return nil, err
+	}
+	startOffset := max(newestOffset-int64(n), oldestOffset)
+	if startOffset >= newestOffset {
+		return nil, nil
+	}
+
+	consumer, err := NewConsumerFromClient(client)
+	if err != nil {
+		return nil, err
+	}
+	defer consumer.Close()
+	reader, err := consumer.NewPartitionReader(topic, partition, startOffset)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return reader.(*partitionReader).readUntil(newestOffset)
+}
+
+func (client *client) GetOffsetsByTime(topic string, t time.Time) (map[int32]int64, error) {
+	if client.Closed() {
+		return nil, ErrClosedClient
+	}
+
+	offsets, err := client.getOffsetsByTime(topic, t.UnixMilli())
+	if err != nil {
+		if err := client.RefreshMetadata(topic); err != nil {
+			return nil, err
+		}
+		return client.getOffsetsByTime(topic, t.UnixMilli())
+	}
+
+	return offsets, nil
 }
 
 func (client *client) Controller() (*Broker, error) {
@@ -572,6 +777,7 @@ func (client *client) deregisterController() {
 	if controller, ok := client.brokers[client.controllerID]; ok {
 		_ = controller.Close()
 		delete(client.brokers, client.controllerID)
+		client.brokersVersion.Add(1)
 	}
 }
 
@@ -584,7 +790,12 @@ func (client *client) RefreshController() (*Broker, error) {
 
 	client.deregisterController()
 
-	if err := client.refreshMetadata(); err != nil {
+	err := client.refreshMetadata()
+	if errors.Is(err, ErrNoTopicsToUpdateMetadata) {
+		// the controller is part of every metadata response
+		err = client.RefreshMetadata()
+	}
+	if err != nil {
 		return nil, err
 	}
 
@@ -680,7 +891,11 @@ func (client *client) RefreshTransactionCoordinator(transactionID string) error
 func (client *client) randomizeSeedBrokers(addrs []string) {
 	random := rand.New(rand.NewSource(time.Now().UnixNano()))
 	for _, index := range random.Perm(len(addrs)) {
-		client.seedBrokers = append(client.seedBrokers, NewBroker(addrs[index]))
+		broker := NewBroker(addrs[index])
+		broker.setStateListener(client.brokerStateChanged)
+		broker.keytabs = &client.keytabs
+		broker.setCachedAPIVersions(client.conf.Admin.CachedApiVersions)
+		client.seedBrokers = append(client.seedBrokers, broker)
 	}
 }
 
@@ -703,6 +918,7 @@ func (client *client) checkBrokersHealth() {
 			Logger.Printf("client/brokers close broker #%d at %s due to socket error: %v", broker.ID(), broker.Addr(), err)
 			safeAsyncClose(broker)
 			delete(client.brokers, id)
+			client.brokersVersion.Add(1)
 		}
 	}
 
@@ -723,11 +939,19 @@ func (client *client) updateBroker(brokers []*Broker) {
 		}
 		currentBroker[broker.ID()] = broker
 		if client.brokers[broker.ID()] == nil { // add new broker
+			broker.setStateListener(client.brokerStateChanged)
+			broker.keytabs = &client.keytabs
+			broker.setCachedAPIVersions(client.cachedAPIVersionsOf(broker.ID()))
 			client.brokers[broker.ID()] = broker
+			client.brokersVersion.Add(1)
 			DebugLogger.Printf("client/brokers registered new broker #%d at %s", broker.ID(), broker.Addr())
 		} else if broker.Addr() != client.brokers[broker.ID()].Addr() { // replace broker with new address
 			safeAsyncClose(client.brokers[broker.ID()])
+			broker.setStateListener(client.brokerStateChanged)
+			broker.keytabs = &client.keytabs
+			broker.setCachedAPIVersions(client.cachedAPIVersionsOf(broker.ID()))
 			client.brokers[broker.ID()] = broker
+			client.brokersVersion.Add(1)
 			Logger.Printf("client/brokers replaced registered broker #%d with %s", broker.ID(), broker.Addr())
 		}
 	}
@@ -736,6 +960,7 @@ func (client *client) updateBroker(brokers []*Broker) {
 		if _, exist := currentBroker[id]; !exist { // remove old broker
 			safeAsyncClose(broker)
 			delete(client.brokers, id)
+			client.brokersVersion.Add(1)
 			Logger.Printf("client/broker remove invalid broker #%d with %s", broker.ID(), broker.Addr())
 		}
 	}
@@ -751,15 +976,33 @@ func (client *client) registerBroker(broker *Broker) {
 	}
 
 	if client.brokers[broker.ID()] == nil {
+		broker.setStateListener(client.brokerStateChanged)
+		broker.keytabs = &client.keytabs
+		broker.setCachedAPIVersions(client.cachedAPIVersionsOf(broker.ID()))
 		client.brokers[broker.ID()] = broker
+		client.brokersVersion.Add(1)
 		DebugLogger.Printf("client/brokers registered new broker #%d at %s", broker.ID(), broker.Addr())
 	} else if broker.Addr() != client.brokers[broker.ID()].Addr() {
 		safeAsyncClose(client.brokers[broker.ID()])
+		broker.setStateListener(client.brokerStateChanged)
+		broker.keytabs = &client.keytabs
+		broker.setCachedAPIVersions(client.cachedAPIVersionsOf(broker.ID()))
 		client.brokers[broker.ID()] = broker
+		client.brokersVersion.Add(1)
 		Logger.Printf("client/brokers replaced registered broker #%d with %s", broker.ID(), broker.Addr())
 	}
 }
 
+// cachedAPIVersionsOf returns the API versions to use instead of probing the
+// broker with the given ID, nil if it must be probed.
+// client.lock must be held by caller
+func (client *client) cachedAPIVersionsOf(brokerID int32) *ApiVersionsResponse {
+	if res := client.cachedAPIVersions[brokerID]; res != nil {
+		return res
+	}
+	return client.conf.Admin.CachedApiVersions
+}
+
 // deregisterBroker removes a broker from the broker list, and if it's
 // not in the broker list, removes it from seedBrokers.
 func (client *client) deregisterBroker(broker *Broker) {
@@ -770,6 +1013,7 @@ func (client *client) deregisterBroker(broker *Broker) {
 	if ok {
 		Logger.Printf("client/brokers deregistered broker #%d at %s", broker.ID(), broker.Addr())
 		delete(client.brokers, broker.ID())
+		client.brokersVersion.Add(1)
 		return
 	}
 	if len(client.seedBrokers) > 0 && broker == client.seedBrokers[0] {
@@ -790,6 +1034,12 @@ func (client *client) resurrectDeadBrokers() {
 // LeastLoadedBroker returns the broker with the least pending requests.
 // Firstly, choose the broker from cached broker list. If the broker list is empty, choose from seed brokers.
 func (client *client) LeastLoadedBroker() *Broker {
+	return client.openLeastLoadedBroker(true)
+}
+
+// openLeastLoadedBroker is LeastLoadedBroker, optionally ignoring the reconnect
+// backoff of the broker.
+func (client *client) openLeastLoadedBroker(withBackoff bool) *Broker {
 	client.lock.RLock()
 	defer client.lock.RUnlock()
 
@@ -802,12 +1052,12 @@ func (client *client) LeastLoadedBroker() *Broker {
 		}
 	}
 	if leastLoadedBroker != nil {
-		_ = leastLoadedBroker.Open(client.conf)
+		_ = leastLoadedBroker.open(client.conf, withBackoff)
 		return leastLoadedBroker
 	}
 
 	if len(client.seedBrokers) > 0 {
-		_ = client.seedBrokers[0].Open(client.conf)
+		_ = client.seedBrokers[0].open(client.conf, withBackoff)
 		return client.seedBrokers[0]
 	}
 
@@ -839,6 +1089,18 @@ func (client *client) cachedMetadata(topic string, partitionID int32) *Partition
 	return nil
 }
 
+// cachedTopicID returns the cached ID of topic, zero if the brokers did not
+// report it, and whether the topic is known at all.
+func (client *client) cachedTopicID(topic string) (Uuid, bool) {
+	client.lock.RLock()
+	defer client.lock.RUnlock()
+
+	if _, known := client.metadata[topic]; !known {
+		return Uuid{}, false
+	}
+	return client.topicIDs[topic], true
+}
+
 func (client *client) cachedPartitions(topic string, partitionSet partitionType) []int32 {
 	client.lock.RLock()
 	defer client.lock.RUnlock()
@@ -886,41 +1148,98 @@ func (client *client) cachedLeader(topic string, partitionID int32) (*Broker, in
 				return nil, -1, ErrLeaderNotAvailable
 			}
 			_ = b.Open(client.conf)
-			return b, metadata.LeaderEpoch, nil
+			leaderEpoch := metadata.LeaderEpoch
+			if metadata.Version < 7 {
+				// the leader epoch is only part of metadata responses v7+
+				leaderEpoch = invalidLeaderEpoch
+			}
+			return b, leaderEpoch, nil
 		}
 	}
 
 	return nil, -1, ErrUnknownTopicOrPartition
 }
 
-func (client *client) getOffset(topic string, partitionID int32, timestamp int64) (int64, error) {
-	broker, err := client.Leader(topic, partitionID)
+func (client *client) getOffsetsByTime(topic string, timestamp int64) (map[int32]int64, error) {
+	partitions, err := client.Partitions(topic)
 	if err != nil {
-		return -1, err
+		return nil, err
+	}
+
+	requests := make(map[*Broker]*OffsetRequest)
+	for _, partition := range partitions {
+		broker, leaderEpoch, err := client.LeaderAndEpoch(topic, partition)
+		if err != nil {
+			return nil, err
+		}
+		request, ok := requests[broker]
+		if !ok {
+			request = NewOffsetRequest(client.conf.Version)
+			requests[broker] = request
+		}
+		request.addBlock(topic, partition, timestamp, 1, leaderEpoch)
+	}
+
+	offsets := make(map[int32]int64, len(partitions))
+	for broker, request := range requests {
+		response, err := broker.GetAvailableOffsets(request)
+		if err != nil {
+			_ = broker.Close()
+			return nil, err
+		}
+
+		for partition := range request.blocks[topic] {
+			block := response.GetBlock(topic, partition)
+			if block == nil {
+				_ = broker.Close()
+				return nil, ErrIncompleteResponse
+			}
+			if !errors.Is(block.Err, ErrNoError) {
+				return nil, block.Err
+			}
+			offsets[partition] = OffsetNewest
+			if len(block.Offsets) == 1 {
+				offsets[partition] = block.Offsets[0]
+			}
+		}
+	}
+
+	return offsets, nil
+}
+
+func (client *client) getOffset(topic string, partitionID int32, timestamp int64) (int64, int32, error) {
+	broker, leaderEpoch, err := client.LeaderAndEpoch(topic, partitionID)
+	if err != nil {
+		return -1, -1, err
 	}
 
 	request := NewOffsetRequest(client.conf.Version)
-	request.AddBlock(topic, partitionID, timestamp, 1)
+	request.addBlock(topic, partitionID, timestamp, 1, leaderEpoch)
 
 	response, err := broker.GetAvailableOffsets(request)
 	if err != nil {
 		_ = broker.Close()
-		return -1, err
+		return -1, -1, err
 	}
 
 	block := response.GetBlock(topic, partitionID)
 	if block == nil {
 		_ = broker.Close()
-		return -1, ErrIncompleteResponse
+		return -1, -1, ErrIncompleteResponse
 	}
 	if !errors.Is(block.Err, ErrNoError) {
-		return -1, block.Err
+		return -1, -1, block.Err
 	}
 	if len(block.Offsets) != 1 {
-		return -1, ErrOffsetOutOfRange
+		return -1, -1, ErrOffsetOutOfRange
 	}
 
-	return block.Offsets[0], nil
+	// the leader epoch is only returned from version 4, which the request may
+	// have been downgraded from to the versions supported by the broker
+	if request.Version < 4 {
+		return block.Offsets[0], -1, nil
+	}
+	return block.Offsets[0], block.LeaderEpoch, nil
 }
 
 // core metadata update logic
@@ -1002,9 +1321,11 @@ func (client *client) tryRefreshMetadata(topics []string, attemptsRemaining int,
 		return err
 	}
 
-	broker := client.LeastLoadedBroker()
+	// metadata refreshes are not subject to the reconnect backoff, so that they
+	// can force an immediate reconnection
+	broker := client.openLeastLoadedBroker(false)
 	brokerErrors := make([]error, 0)
-	for ; broker != nil && !pastDeadline(0); broker = client.LeastLoadedBroker() {
+	for ; broker != nil && !pastDeadline(0); broker = client.openLeastLoadedBroker(false) {
 		allowAutoTopicCreation := client.conf.Metadata.AllowAutoTopicCreation
 		if len(topics) > 0 {
 			DebugLogger.Printf("client/metadata fetching metadata for %v from broker %s\n", topics, broker.addr)
@@ -1020,6 +1341,9 @@ func (client *client) tryRefreshMetadata(topics []string, attemptsRemaining int,
 		var kerror KError
 		var packetEncodingError PacketEncodingError
 		if err == nil {
+			if client.conf.Metadata.Interceptor != nil {
+				client.conf.Metadata.Interceptor(response)
+			}
 			// When talking to the startup phase of a broker, it is possible to receive an empty metadata set. We should remove that broker and try next broker (https://issues.apache.org/jira/browse/KAFKA-7924).
 			if len(response.Brokers) == 0 {
 				Logger.Printf("client/metadata receiving empty brokers from the metadata response when requesting the broker #%d at %s", broker.ID(), broker.addr)
@@ -1102,9 +1426,11 @@ func (client *client) updateMetadata(data *MetadataResponse, allKnownMetaData bo
 
 	client.controllerID = data.ControllerID
 
+	previousTopicIDs := client.topicIDs
 	if allKnownMetaData {
 		client.metadata = make(map[string]map[int32]*PartitionMetadata)
 		client.metadataTopics = make(map[string]none)
+		client.topicIDs = make(map[string]Uuid)
 		client.cachedPartitionsResults = make(map[string][maxPartitionIndex][]int32)
 	}
 	topicErrs := make(refreshError)
@@ -1116,7 +1442,9 @@ func (client *client) updateMetadata(data *MetadataResponse, allKnownMetaData bo
 		if _, exists := client.metadataTopics[topic.Name]; !exists {
 			client.metadataTopics[topic.Name] = none{}
 		}
+		previousID, hadID := previousTopicIDs[topic.Name]
 		delete(client.metadata, topic.Name)
+		delete(client.topicIDs, topic.Name)
 		delete(client.cachedPartitionsResults, topic.Name)
 
 		switch topic.Err {
@@ -1138,6 +1466,15 @@ func (client *client) updateMetadata(data *MetadataResponse, allKnownMetaData bo
 			continue
 		}
 
+		// brokers older than 2.8 and metadata versions below 10 leave the ID zeroed
+		if topic.Uuid != (Uuid{}) {
+			if hadID && previousID != topic.Uuid {
+				Logger.Printf("client/metadata topic %s was recreated, its ID changed from %s to %s\n",
+					topic.Name, previousID, topic.Uuid)
+			}
+			client.topicIDs[topic.Name] = topic.Uuid
+		}
+
 		client.metadata[topic.Name] = make(map[int32]*PartitionMetadata, len(topic.Partitions))
 		for _, partition := range topic.Partitions {
 			client.metadata[topic.Name][partition.ID] = partition
@@ -1337,6 +1674,139 @@ func (ncc *nopCloserClient) Close() error {
 	return nil
 }
 
+func (client *client) APIVersions(broker *Broker) (map[int16]ApiVersionRange, error) {
+	if client.Closed() {
+		return nil, ErrClosedClient
+	}
+	if broker == nil {
+		return nil, ErrBrokerNotFound
+	}
+
+	_ = broker.Open(client.conf)
+	if connected, err := broker.Connected(); !connected {
+		if err == nil {
+			err = ErrNotConnected
+		}
+		return nil, err
+	}
+	if versions := broker.apiVersions(); versions != nil {
+		return versions, nil
+	}
+
+	response, err := client.sendApiVersions(broker)
+	if err != nil {
+		return nil, err
+	}
+
+	if !client.conf.ApiVersionsRequest {
+		// cached versions restrict the requests sent to the broker, which
+		// must not happen when ApiVersionsRequest is disabled
+		versions := make(map[int16]ApiVersionRange, len(response.ApiKeys))
+		for _, key := range response.ApiKeys {
+			versions[key.ApiKey] = ApiVersionRange{MinVersion: key.MinVersion, MaxVersion: key.MaxVersion}
+		}
+		return versions, nil
+	}
+
+	broker.lock.Lock()
+	broker.storeAPIVersions(response)
+	broker.lock.Unlock()
+	return broker.apiVersions(), nil
+}
+
+// sendApiVersions sends an ApiVersionsRequest to the connected broker and
+// returns its response, or the error code of the response.
+func (client *client) sendApiVersions(broker *Broker) (*ApiVersionsResponse, error) {
+	request := &ApiVersionsRequest{
+		ClientSoftwareName:    defaultClientSoftwareName,
+		ClientSoftwareVersion: version(),
+	}
+	switch {
+	case client.conf.Version.IsAtLeast(V2_4_0_0):
+		request.Version = 3
+	case client.conf.Version.IsAtLeast(V2_0_0_0):
+		request.Version = 2
+	case client.conf.Version.IsAtLeast(V0_11_0_0):
+		request.Version = 1
+	}
+	response, err := broker.ApiVersions(request)
+	if err != nil {
+		return nil, err
+	}
+	if !errors.Is(KError(response.ErrorCode), ErrNoError) {
+		return nil, KError(response.ErrorCode)
+	}
+	return response, nil
+}
+
+func (client *client) Ping(ctx context.Context) error {
+	if client.Closed() {
+		return ErrClosedClient
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go withRecover(func() {
+		done <- client.ping()
+	})
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (client *client) ping() error {
+	broker := client.connectedBroker()
+	if broker == nil {
+		broker = client.LeastLoadedBroker()
+	}
+	if broker == nil {
+		return ErrOutOfBrokers
+	}
+
+	if connected, err := broker.Connected(); !connected {
+		if err == nil {
+			err = ErrNotConnected
+		}
+		return err
+	}
+	_, err := client.sendApiVersions(broker)
+	return err
+}
+
+// connectedBroker returns a registered broker with an open connection, or
+// nil if there is none.
+func (client *client) connectedBroker() *Broker {
+	client.lock.RLock()
+	defer client.lock.RUnlock()
+
+	for _, broker := range client.brokers {
+		if connected, _ := broker.Connected(); connected {
+			return broker
+		}
+	}
+	return nil
+}
+
+func (client *client) SetCachedApiVersions(brokerID int32, res *ApiVersionsResponse) {
+	client.lock.Lock()
+	defer client.lock.Unlock()
+
+	if res == nil {
+		delete(client.cachedAPIVersions, brokerID)
+	} else {
+		client.cachedAPIVersions[brokerID] = res
+	}
+	if broker := client.brokers[brokerID]; broker != nil {
+		broker.setCachedAPIVersions(client.cachedAPIVersionsOf(brokerID))
+	}
+}
+
 func (client *client) PartitionNotReadable(topic string, partition int32) bool {
 	client.lock.RLock()
 	defer client.lock.RUnlock()
@@ -4,6 +4,7 @@ package sarama
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -47,6 +48,63 @@ func TestSimpleClient(t *testing.T) {
 	safeClose(t, client)
 }
 
+func TestClientAddressRewriter(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	leader := NewMockBroker(t, 5)
+	defer leader.Close()
+
+	const (
+		seedAddr   = "seed.internal:9092"
+		leaderAddr = "leader.internal:9092"
+	)
+
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker(leaderAddr, leader.BrokerID())
+	metadataResponse.AddTopicPartition("my_topic", 0, leader.BrokerID(), nil, nil, nil, ErrNoError)
+	seedBroker.Returns(metadataResponse)
+
+	var mu sync.Mutex
+	rewritten := make(map[string]bool)
+	config := NewTestConfig()
+	config.Metadata.Retry.Max = 0
+	config.Net.AddressRewriter = func(broker string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		rewritten[broker] = true
+		switch broker {
+		case seedAddr:
+			return seedBroker.Addr()
+		case leaderAddr:
+			return leader.Addr()
+		}
+		return broker
+	}
+
+	client, err := NewClient([]string{seedAddr}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, client)
+
+	b, err := client.Leader("my_topic", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Addr() != leaderAddr {
+		t.Errorf("expected the advertised address %s, got %s", leaderAddr, b.Addr())
+	}
+	if connected, err := b.Connected(); !connected || err != nil {
+		t.Fatalf("expected the leader to be connected through the rewritten address, got %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !rewritten[seedAddr] || !rewritten[leaderAddr] {
+		t.Errorf("expected both bootstrap and discovered brokers to be rewritten, got %v", rewritten)
+	}
+}
+
 func TestCachedPartitions(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 
@@ -158,6 +216,62 @@ func TestClientSeedBrokers(t *testing.T) {
 	safeClose(t, client)
 }
 
+func TestClientBrokersShareKeytabCache(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker("localhost:12345", 2)
+	seedBroker.Returns(metadataResponse)
+
+	c, err := NewClient([]string{seedBroker.Addr()}, NewTestConfig())
+	require.NoError(t, err)
+	defer safeClose(t, c)
+
+	cl := c.(*client)
+	cl.lock.RLock()
+	defer cl.lock.RUnlock()
+	require.Same(t, &cl.keytabs, cl.seedBrokers[0].keytabs)
+	require.Same(t, &cl.keytabs, cl.brokers[2].keytabs)
+}
+
+func TestClientBrokerStateChanges(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker(seedBroker.Addr(), seedBroker.BrokerID())
+	seedBroker.Returns(metadataResponse)
+
+	client, err := NewClient([]string{seedBroker.Addr()}, NewTestConfig())
+	require.NoError(t, err)
+
+	changes := client.BrokerStateChanges()
+	change := <-changes
+	require.Equal(t, seedBroker.Addr(), change.Broker.Addr())
+	require.Equal(t, BrokerConnecting, change.State)
+	change = <-changes
+	require.Equal(t, BrokerConnected, change.State)
+
+	broker, err := client.Broker(seedBroker.BrokerID())
+	require.NoError(t, err)
+	_, err = broker.Connected()
+	require.NoError(t, err)
+	change = <-changes
+	require.Same(t, broker, change.Broker)
+	require.Equal(t, BrokerConnecting, change.State)
+	change = <-changes
+	require.Same(t, broker, change.Broker)
+	require.Equal(t, BrokerConnected, change.State)
+
+	safeClose(t, client)
+	for range changes {
+		// drain until closed
+	}
+	_, ok := <-client.BrokerStateChanges()
+	require.False(t, ok, "expected a closed channel once the client is closed")
+}
+
 func TestClientMetadata(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	leader := NewMockBroker(t, 5)
@@ -446,6 +560,56 @@ func TestClientReceivingUnknownTopic(t *testing.T) {
 	seedBroker.Close()
 }
 
+func TestClientMetadataAllowAutoTopicCreation(t *testing.T) {
+	if NewConfig().Metadata.AllowAutoTopicCreation {
+		t.Error("expected AllowAutoTopicCreation to default to false")
+	}
+
+	for _, allow := range []bool{false, true} {
+		t.Run(fmt.Sprintf("allow=%v", allow), func(t *testing.T) {
+			seedBroker := NewMockBroker(t, 1)
+			defer seedBroker.Close()
+
+			metadataResponse := new(MetadataResponse)
+			metadataResponse.Version = 5
+			metadataResponse.AddBroker(seedBroker.Addr(), seedBroker.BrokerID())
+			seedBroker.Returns(metadataResponse)
+
+			config := NewTestConfig()
+			config.Version = V1_0_0_0
+			config.Metadata.Retry.Max = 0
+			config.Metadata.AllowAutoTopicCreation = allow
+			client, err := NewClient([]string{seedBroker.Addr()}, config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer safeClose(t, client)
+
+			metadataUnknownTopic := new(MetadataResponse)
+			metadataUnknownTopic.Version = 5
+			metadataUnknownTopic.AddBroker(seedBroker.Addr(), seedBroker.BrokerID())
+			metadataUnknownTopic.AddTopic("new_topic", ErrUnknownTopicOrPartition)
+			seedBroker.Returns(metadataUnknownTopic)
+
+			if err := client.RefreshMetadata("new_topic"); !errors.Is(err, ErrUnknownTopicOrPartition) {
+				t.Error("ErrUnknownTopicOrPartition expected, got", err)
+			}
+
+			history := seedBroker.History()
+			req, ok := history[len(history)-1].Request.(*MetadataRequest)
+			if !ok {
+				t.Fatalf("expected a MetadataRequest, got %T", history[len(history)-1].Request)
+			}
+			if req.Version < 4 {
+				t.Fatalf("expected MetadataRequest v4+, got v%d", req.Version)
+			}
+			if req.AllowAutoTopicCreation != allow {
+				t.Errorf("expected AllowAutoTopicCreation %v, got %v", allow, req.AllowAutoTopicCreation)
+			}
+		})
+	}
+}
+
 func TestClientReceivingPartialMetadata(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	leader := NewMockBroker(t, 5)
@@ -656,6 +820,622 @@ func TestClientRefreshMetadataBrokerOffline(t *testing.T) {
 	}
 }
 
+func TestClientAPIVersions(t *testing.T) {
+	apiKeys := []ApiVersionsResponseKey{
+		{ApiKey: apiKeyMetadata, MinVersion: 0, MaxVersion: 12},
+		{ApiKey: apiKeyDescribeProducers, MinVersion: 0, MaxVersion: 0},
+	}
+	expected := map[int16]ApiVersionRange{
+		apiKeyMetadata:          {MinVersion: 0, MaxVersion: 12},
+		apiKeyDescribeProducers: {MinVersion: 0, MaxVersion: 0},
+	}
+	countApiVersionsRequests := func(broker *MockBroker) (n int) {
+		for _, rr := range broker.History() {
+			if _, ok := rr.Request.(*ApiVersionsRequest); ok {
+				n++
+			}
+		}
+		return n
+	}
+
+	for _, apiVersionsRequest := range []bool{true, false} {
+		t.Run(fmt.Sprintf("ApiVersionsRequest=%t", apiVersionsRequest), func(t *testing.T) {
+			seedBroker := NewMockBroker(t, 1)
+			defer seedBroker.Close()
+			seedBroker.SetHandlerByMap(map[string]MockResponse{
+				"MetadataRequest": NewMockMetadataResponse(t).
+					SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+				"ApiVersionsRequest": NewMockApiVersionsResponse(t).SetApiKeys(apiKeys),
+			})
+
+			config := NewTestConfig()
+			config.Version = V2_4_0_0
+			config.ApiVersionsRequest = apiVersionsRequest
+			client, err := NewClient([]string{seedBroker.Addr()}, config)
+			require.NoError(t, err)
+			defer safeClose(t, client)
+
+			broker, err := client.Broker(seedBroker.BrokerID())
+			require.NoError(t, err)
+
+			versions, err := client.APIVersions(broker)
+			require.NoError(t, err)
+			require.Equal(t, expected, versions)
+			probes := countApiVersionsRequests(seedBroker)
+
+			// the versions are cached for subsequent calls, unless probing
+			// is disabled
+			versions, err = client.APIVersions(broker)
+			require.NoError(t, err)
+			require.Equal(t, expected, versions)
+			if apiVersionsRequest {
+				require.Equal(t, probes, countApiVersionsRequests(seedBroker))
+			} else {
+				require.Equal(t, probes+1, countApiVersionsRequests(seedBroker))
+				broker.lock.Lock()
+				require.Nil(t, broker.brokerAPIVersions, "the request versions must not be restricted")
+				broker.lock.Unlock()
+			}
+		})
+	}
+
+	t.Run("nil broker", func(t *testing.T) {
+		seedBroker := NewMockBroker(t, 1)
+		defer seedBroker.Close()
+		seedBroker.SetHandlerByMap(map[string]MockResponse{
+			"MetadataRequest": NewMockMetadataResponse(t).
+				SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		})
+
+		client, err := NewClient([]string{seedBroker.Addr()}, NewTestConfig())
+		require.NoError(t, err)
+		defer safeClose(t, client)
+
+		_, err = client.APIVersions(nil)
+		require.ErrorIs(t, err, ErrBrokerNotFound)
+	})
+}
+
+func TestClientCachedApiVersions(t *testing.T) {
+	countApiVersionsRequests := func(broker *MockBroker) (n int) {
+		for _, rr := range broker.History() {
+			if _, ok := rr.Request.(*ApiVersionsRequest); ok {
+				n++
+			}
+		}
+		return n
+	}
+
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_4_0_0
+	config.ApiVersionsRequest = true
+	config.Admin.CachedApiVersions = &ApiVersionsResponse{ApiKeys: []ApiVersionsResponseKey{
+		{ApiKey: apiKeyMetadata, MinVersion: 0, MaxVersion: 9},
+	}}
+	client, err := NewClient([]string{seedBroker.Addr()}, config)
+	require.NoError(t, err)
+	defer safeClose(t, client)
+	require.Zero(t, countApiVersionsRequests(seedBroker), "the seed broker should not be probed")
+
+	client.SetCachedApiVersions(seedBroker.BrokerID(), &ApiVersionsResponse{ApiKeys: []ApiVersionsResponseKey{
+		{ApiKey: apiKeyMetadata, MinVersion: 0, MaxVersion: 12},
+	}})
+	broker, err := client.Broker(seedBroker.BrokerID())
+	require.NoError(t, err)
+	versions, err := client.APIVersions(broker)
+	require.NoError(t, err)
+	require.Equal(t, map[int16]ApiVersionRange{apiKeyMetadata: {MinVersion: 0, MaxVersion: 12}}, versions)
+	require.Zero(t, countApiVersionsRequests(seedBroker), "the broker should not be probed")
+
+	// the broker is probed again once the cached versions are found stale
+	broker.lock.Lock()
+	broker.dropCachedAPIVersions(ErrUnsupportedVersion)
+	broker.lock.Unlock()
+	_, err = client.APIVersions(broker)
+	require.NoError(t, err)
+	require.Equal(t, 1, countApiVersionsRequests(seedBroker))
+}
+
+func TestClientCachedApiVersionsOfDiscoveredBrokers(t *testing.T) {
+	countApiVersionsRequests := func(broker *MockBroker) (n int) {
+		for _, rr := range broker.History() {
+			if _, ok := rr.Request.(*ApiVersionsRequest); ok {
+				n++
+			}
+		}
+		return n
+	}
+
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	broker2 := NewMockBroker(t, 2)
+	defer broker2.Close()
+	broker3 := NewMockBroker(t, 3)
+	defer broker3.Close()
+	for _, b := range []*MockBroker{broker2, broker3} {
+		b.SetHandlerByMap(map[string]MockResponse{
+			"ApiVersionsRequest": NewMockApiVersionsResponse(t),
+		})
+	}
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_4_0_0
+	config.ApiVersionsRequest = true
+	config.Admin.CachedApiVersions = &ApiVersionsResponse{ApiKeys: []ApiVersionsResponseKey{
+		{ApiKey: apiKeyMetadata, MinVersion: 0, MaxVersion: 9},
+	}}
+	client, err := NewClient([]string{seedBroker.Addr()}, config)
+	require.NoError(t, err)
+	defer safeClose(t, client)
+
+	// set before the broker is known to the client
+	client.SetCachedApiVersions(broker2.BrokerID(), &ApiVersionsResponse{ApiKeys: []ApiVersionsResponseKey{
+		{ApiKey: apiKeyMetadata, MinVersion: 0, MaxVersion: 12},
+	}})
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetBroker(broker2.Addr(), broker2.BrokerID()).
+			SetBroker(broker3.Addr(), broker3.BrokerID()),
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t),
+	})
+	require.NoError(t, client.RefreshMetadata())
+
+	for _, tc := range []struct {
+		broker     *MockBroker
+		maxVersion int16
+	}{
+		{broker2, 12},
+		{broker3, 9},
+	} {
+		broker, err := client.Broker(tc.broker.BrokerID())
+		require.NoError(t, err)
+		versions, err := client.APIVersions(broker)
+		require.NoError(t, err)
+		require.Equal(t, map[int16]ApiVersionRange{apiKeyMetadata: {MinVersion: 0, MaxVersion: tc.maxVersion}}, versions)
+		require.Zero(t, countApiVersionsRequests(tc.broker), "broker #%d should not be probed", tc.broker.BrokerID())
+	}
+}
+
+func TestClientTopicID(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	firstID := Uuid{1, 2, 3}
+	metadataResponse := NewMockMetadataResponse(t).
+		SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+		SetLeader("my_topic", 0, seedBroker.BrokerID()).
+		SetLeader("no_id_topic", 0, seedBroker.BrokerID()).
+		SetTopicID("my_topic", firstID)
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": metadataResponse,
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_8_0_0
+	config.Metadata.Retry.Max = 0
+	client, err := NewClient([]string{seedBroker.Addr()}, config)
+	require.NoError(t, err)
+	defer safeClose(t, client)
+
+	id, err := client.TopicID("my_topic")
+	require.NoError(t, err)
+	require.Equal(t, firstID, id)
+
+	_, err = client.TopicID("no_id_topic")
+	require.ErrorIs(t, err, ErrTopicIDNotAvailable)
+
+	_, err = client.TopicID("unknown_topic")
+	require.ErrorIs(t, err, ErrUnknownTopicOrPartition)
+
+	// a recreated topic is reported with its new ID once refreshed
+	secondID := Uuid{4, 5, 6}
+	metadataResponse.SetTopicID("my_topic", secondID)
+	require.NoError(t, client.RefreshMetadata("my_topic"))
+	id, err = client.TopicID("my_topic")
+	require.NoError(t, err)
+	require.Equal(t, secondID, id)
+}
+
+func TestClientMetadataInterceptor(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	otherBroker := NewMockBroker(t, 2)
+	defer otherBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetBroker(otherBroker.Addr(), otherBroker.BrokerID()).
+			SetLeader("my_topic", 0, seedBroker.BrokerID()),
+	})
+
+	// once enabled, migrate the leadership to the other broker and shrink the ISR
+	var migrate atomic.Bool
+	var intercepted atomic.Int32
+	config := NewTestConfig()
+	config.Metadata.Retry.Max = 0
+	config.Metadata.Interceptor = func(response *MetadataResponse) {
+		intercepted.Add(1)
+		if !migrate.Load() {
+			return
+		}
+		for _, topic := range response.Topics {
+			for _, partition := range topic.Partitions {
+				partition.Leader = otherBroker.BrokerID()
+				partition.Isr = []int32{otherBroker.BrokerID()}
+			}
+		}
+	}
+	client, err := NewClient([]string{seedBroker.Addr()}, config)
+	require.NoError(t, err)
+	defer safeClose(t, client)
+	require.Positive(t, intercepted.Load())
+
+	leader, err := client.Leader("my_topic", 0)
+	require.NoError(t, err)
+	require.Equal(t, seedBroker.BrokerID(), leader.ID())
+
+	migrate.Store(true)
+	require.NoError(t, client.RefreshMetadata("my_topic"))
+
+	leader, err = client.Leader("my_topic", 0)
+	require.NoError(t, err)
+	require.Equal(t, otherBroker.BrokerID(), leader.ID())
+	isr, err := client.InSyncReplicas("my_topic", 0)
+	require.NoError(t, err)
+	require.Equal(t, []int32{otherBroker.BrokerID()}, isr)
+}
+
+func TestClientPing(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+		"ApiVersionsRequest": NewMockApiVersionsResponse(t),
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_4_0_0
+	client, err := NewClient([]string{seedBroker.Addr()}, config)
+	require.NoError(t, err)
+
+	requests := len(seedBroker.History())
+	require.NoError(t, client.Ping(context.Background()))
+	history := seedBroker.History()[requests:]
+	require.Len(t, history, 1, "ping should send a single request")
+	require.IsType(t, &ApiVersionsRequest{}, history[0].Request)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, client.Ping(ctx), context.Canceled)
+
+	seedBroker.SetLatency(time.Second)
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, client.Ping(ctx), context.DeadlineExceeded)
+
+	safeClose(t, client)
+	require.ErrorIs(t, client.Ping(context.Background()), ErrClosedClient)
+}
+
+func TestClientGetOffsetsByTime(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	leader := NewMockBroker(t, 2)
+	defer leader.Close()
+
+	timestamp := time.Unix(1700000000, 0)
+	metadataResponse := NewMockMetadataResponse(t).
+		SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+		SetBroker(leader.Addr(), leader.BrokerID()).
+		SetLeader("my_topic", 0, seedBroker.BrokerID()).
+		SetLeader("my_topic", 1, seedBroker.BrokerID()).
+		SetLeader("my_topic", 2, leader.BrokerID())
+	offsetResponse := NewMockOffsetResponse(t).
+		SetOffset("my_topic", 0, timestamp.UnixMilli(), 10).
+		SetOffset("my_topic", 1, timestamp.UnixMilli(), -1).
+		SetOffset("my_topic", 2, timestamp.UnixMilli(), 30)
+	for _, broker := range []*MockBroker{seedBroker, leader} {
+		broker.SetHandlerByMap(map[string]MockResponse{
+			"MetadataRequest": metadataResponse,
+			"OffsetRequest":   offsetResponse,
+		})
+	}
+
+	config := NewTestConfig()
+	config.Version = V2_1_0_0
+	config.Metadata.Retry.Max = 0
+	client, err := NewClient([]string{seedBroker.Addr()}, config)
+	require.NoError(t, err)
+	defer safeClose(t, client)
+
+	offsets, err := client.GetOffsetsByTime("my_topic", timestamp)
+	require.NoError(t, err)
+	require.Equal(t, map[int32]int64{0: 10, 1: OffsetNewest, 2: 30}, offsets)
+
+	// a single request is sent to the leader of each partition
+	for broker, partitions := range map[*MockBroker][]int32{seedBroker: {0, 1}, leader: {2}} {
+		var requests []*OffsetRequest
+		for _, rr := range broker.History() {
+			if request, ok := rr.Request.(*OffsetRequest); ok {
+				requests = append(requests, request)
+			}
+		}
+		require.Len(t, requests, 1)
+		require.Len(t, requests[0].blocks["my_topic"], len(partitions))
+		for _, partition := range partitions {
+			require.Equal(t, timestamp.UnixMilli(), requests[0].blocks["my_topic"][partition].timestamp)
+		}
+	}
+
+	_, err = client.GetOffsetsByTime("unknown_topic", timestamp)
+	require.ErrorIs(t, err, ErrUnknownTopicOrPartition)
+}
+
+func TestClientLeaderAndEpoch(t *testing.T) {
+	t.Run("metadata v7+", func(t *testing.T) {
+		seedBroker := NewMockBroker(t, 1)
+		defer seedBroker.Close()
+		metadataResponse := NewMockMetadataResponse(t).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetLeader("my_topic", 0, seedBroker.BrokerID()).
+			SetLeaderEpoch("my_topic", 0, 5)
+		fetchResponse := NewMockFetchResponse(t, 1).
+			SetMessage("my_topic", 0, 0, testMsg)
+		seedBroker.SetHandlerByMap(map[string]MockResponse{
+			"MetadataRequest": metadataResponse,
+			"OffsetRequest": NewMockOffsetResponse(t).
+				SetOffset("my_topic", 0, OffsetOldest, 0).
+				SetOffset("my_topic", 0, OffsetNewest, 1),
+			"FetchRequest": fetchResponse,
+		})
+
+		config := NewTestConfig()
+		config.Version = V2_1_0_0
+		client, err := NewClient([]string{seedBroker.Addr()}, config)
+		require.NoError(t, err)
+		defer safeClose(t, client)
+
+		leader, epoch, err := client.LeaderAndEpoch("my_topic", 0)
+		require.NoError(t, err)
+		require.Equal(t, seedBroker.BrokerID(), leader.ID())
+		require.Equal(t, int32(5), epoch)
+
+		// the consumer sends the same epoch in its fetch requests
+		consumer, err := NewConsumerFromClient(client)
+		require.NoError(t, err)
+		defer safeClose(t, consumer)
+		pc, err := consumer.ConsumePartition("my_topic", 0, 0)
+		require.NoError(t, err)
+		defer safeClose(t, pc)
+		select {
+		case <-pc.Messages():
+		case err := <-pc.Errors():
+			t.Fatal(err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a message")
+		}
+		var fetched bool
+		for _, rr := range seedBroker.History() {
+			if request, ok := rr.Request.(*FetchRequest); ok {
+				require.Equal(t, epoch, request.blocks["my_topic"][0].currentLeaderEpoch)
+				fetched = true
+			}
+		}
+		require.True(t, fetched, "expected a fetch request")
+
+		// a new epoch is picked up once the metadata is refreshed
+		metadataResponse.SetLeaderEpoch("my_topic", 0, 6)
+		require.NoError(t, client.RefreshMetadata("my_topic"))
+		_, epoch, err = client.LeaderAndEpoch("my_topic", 0)
+		require.NoError(t, err)
+		require.Equal(t, int32(6), epoch)
+	})
+
+	t.Run("metadata before v7", func(t *testing.T) {
+		seedBroker := NewMockBroker(t, 1)
+		defer seedBroker.Close()
+		seedBroker.SetHandlerByMap(map[string]MockResponse{
+			"MetadataRequest": NewMockMetadataResponse(t).
+				SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+				SetLeader("my_topic", 0, seedBroker.BrokerID()).
+				SetLeaderEpoch("my_topic", 0, 5),
+		})
+
+		config := NewTestConfig()
+		config.Version = V2_0_0_0
+		client, err := NewClient([]string{seedBroker.Addr()}, config)
+		require.NoError(t, err)
+		defer safeClose(t, client)
+
+		leader, epoch, err := client.LeaderAndEpoch("my_topic", 0)
+		require.NoError(t, err)
+		require.Equal(t, seedBroker.BrokerID(), leader.ID())
+		require.Equal(t, int32(invalidLeaderEpoch), epoch)
+	})
+
+	t.Run("unknown partition", func(t *testing.T) {
+		seedBroker := NewMockBroker(t, 1)
+		defer seedBroker.Close()
+		seedBroker.SetHandlerByMap(map[string]MockResponse{
+			"MetadataRequest": NewMockMetadataResponse(t).
+				SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+				SetLeader("my_topic", 0, seedBroker.BrokerID()),
+		})
+
+		config := NewTestConfig()
+		config.Metadata.Retry.Max = 0
+		client, err := NewClient([]string{seedBroker.Addr()}, config)
+		require.NoError(t, err)
+		defer safeClose(t, client)
+
+		_, epoch, err := client.LeaderAndEpoch("my_topic", 1)
+		require.ErrorIs(t, err, ErrUnknownTopicOrPartition)
+		require.Equal(t, int32(-1), epoch)
+	})
+}
+
+func TestClientReadLastMessages(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	// offsets 10 to 20 of a compacted topic, only some of them left
+	mockFetchResponse := NewMockFetchResponse(t, 1)
+	for _, offset := range []int64{12, 14, 17, 19} {
+		mockFetchResponse.SetMessage("my_topic", 0, offset, testMsg)
+	}
+	mockFetchResponse.SetHighWaterMark("my_topic", 0, 21)
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetLeader("my_topic", 0, seedBroker.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetOldest, 10).
+			SetOffset("my_topic", 0, OffsetNewest, 21),
+		"FetchRequest": mockFetchResponse,
+	})
+
+	client, err := NewClient([]string{seedBroker.Addr()}, NewTestConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, client)
+
+	for _, tc := range []struct {
+		n       int
+		offsets []int64
+	}{
+		{n: 0},
+		{n: 1},
+		{n: 5, offsets: []int64{17, 19}},
+		{n: 50, offsets: []int64{12, 14, 17, 19}},
+	} {
+		msgs, err := client.ReadLastMessages("my_topic", 0, tc.n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var offsets []int64
+		for _, msg := range msgs {
+			offsets = append(offsets, msg.Offset)
+		}
+		assert.Equal(t, tc.offsets, offsets, "last %d messages", tc.n)
+	}
+
+	if client.Closed() {
+		t.Error("ReadLastMessages should not close the client")
+	}
+}
+
+func TestClientGetOffsetWithEpoch(t *testing.T) {
+	newClient := func(t *testing.T, config *Config, offsetHandler func(*OffsetRequest) *OffsetResponse) (Client, *MockMetadataResponse) {
+		t.Helper()
+		seedBroker := NewMockBroker(t, 1)
+		t.Cleanup(seedBroker.Close)
+		metadataResponse := NewMockMetadataResponse(t).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetLeader("my_topic", 0, seedBroker.BrokerID()).
+			SetLeaderEpoch("my_topic", 0, 5)
+		seedBroker.SetHandlerFuncByMap(map[string]requestHandlerFunc{
+			"ApiVersionsRequest": func(req *request) encoderWithHeader {
+				return NewMockApiVersionsResponse(t).SetApiKeys([]ApiVersionsResponseKey{
+					{ApiKey: apiKeyListOffsets, MinVersion: 0, MaxVersion: 3},
+				}).For(req.body)
+			},
+			"MetadataRequest": func(req *request) encoderWithHeader {
+				return metadataResponse.For(req.body)
+			},
+			"OffsetRequest": func(req *request) encoderWithHeader {
+				return offsetHandler(req.body.(*OffsetRequest))
+			},
+		})
+
+		client, err := NewClient([]string{seedBroker.Addr()}, config)
+		require.NoError(t, err)
+		t.Cleanup(func() { safeClose(t, client) })
+		return client, metadataResponse
+	}
+	offsetResponse := func(request *OffsetRequest, kerr KError, leaderEpoch int32) *OffsetResponse {
+		response := &OffsetResponse{Version: request.Version}
+		response.AddTopicPartition("my_topic", 0, 42)
+		response.Blocks["my_topic"][0].Err = kerr
+		response.Blocks["my_topic"][0].LeaderEpoch = leaderEpoch
+		return response
+	}
+
+	t.Run("v7", func(t *testing.T) {
+		config := NewTestConfig()
+		config.Version = V3_0_0_0
+		client, _ := newClient(t, config, func(request *OffsetRequest) *OffsetResponse {
+			require.Equal(t, int16(7), request.Version)
+			require.Equal(t, int32(5), request.blocks["my_topic"][0].currentLeaderEpoch)
+			return offsetResponse(request, ErrNoError, 5)
+		})
+
+		offset, leaderEpoch, err := client.GetOffsetWithEpoch("my_topic", 0, OffsetNewest)
+		require.NoError(t, err)
+		require.Equal(t, int64(42), offset)
+		require.Equal(t, int32(5), leaderEpoch)
+	})
+
+	t.Run("fenced by a newer leader epoch", func(t *testing.T) {
+		config := NewTestConfig()
+		config.Version = V3_0_0_0
+		var metadataResponse *MockMetadataResponse
+		var requests atomic.Int32
+		client, metadataResponse := newClient(t, config, func(request *OffsetRequest) *OffsetResponse {
+			if requests.Add(1) == 1 {
+				// the leader has moved on, the metadata catches up on refresh
+				metadataResponse.SetLeaderEpoch("my_topic", 0, 6)
+				return offsetResponse(request, ErrFencedLeaderEpoch, -1)
+			}
+			require.Equal(t, int32(6), request.blocks["my_topic"][0].currentLeaderEpoch)
+			return offsetResponse(request, ErrNoError, 6)
+		})
+
+		offset, leaderEpoch, err := client.GetOffsetWithEpoch("my_topic", 0, OffsetNewest)
+		require.NoError(t, err)
+		require.Equal(t, int64(42), offset)
+		require.Equal(t, int32(6), leaderEpoch)
+		require.Equal(t, int32(2), requests.Load())
+	})
+
+	t.Run("falls back to the versions supported by the broker", func(t *testing.T) {
+		config := NewTestConfig()
+		config.Version = V3_0_0_0
+		config.ApiVersionsRequest = true
+		client, _ := newClient(t, config, func(request *OffsetRequest) *OffsetResponse {
+			require.Equal(t, int16(3), request.Version)
+			return offsetResponse(request, ErrNoError, 0)
+		})
+
+		offset, leaderEpoch, err := client.GetOffsetWithEpoch("my_topic", 0, OffsetNewest)
+		require.NoError(t, err)
+		require.Equal(t, int64(42), offset)
+		require.Equal(t, int32(-1), leaderEpoch)
+
+		offset, err = client.GetOffset("my_topic", 0, OffsetNewest)
+		require.NoError(t, err)
+		require.Equal(t, int64(42), offset)
+	})
+}
+
 func TestClientGetBroker(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	defer seedBroker.Close()
@@ -932,6 +1712,31 @@ func TestClientController(t *testing.T) {
 	})
 }
 
+func TestClientRefreshControllerWithoutTrackedTopics(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	controllerBroker := NewMockBroker(t, 2)
+	defer controllerBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetController(controllerBroker.BrokerID()).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetBroker(controllerBroker.Addr(), controllerBroker.BrokerID()),
+	})
+
+	cfg := NewTestConfig()
+	cfg.Version = V1_0_0_0
+	cfg.Metadata.Full = false
+	client, err := NewClient([]string{seedBroker.Addr()}, cfg)
+	require.NoError(t, err)
+	defer safeClose(t, client)
+
+	controller, err := client.RefreshController()
+	require.NoError(t, err)
+	require.Equal(t, controllerBroker.Addr(), controller.Addr())
+}
+
 func TestClientMetadataTimeout(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -1307,6 +2112,102 @@ func TestClientCoordinatorChangeWithConsumerOffsetsTopic(t *testing.T) {
 	safeClose(t, client)
 }
 
+func TestClientTransactionCoordinatorChange(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	staleCoordinator := NewMockBroker(t, 2)
+	defer staleCoordinator.Close()
+	freshCoordinator := NewMockBroker(t, 3)
+	defer freshCoordinator.Close()
+
+	metadataResponse := NewMockMetadataResponse(t).
+		SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+		SetBroker(staleCoordinator.Addr(), staleCoordinator.BrokerID()).
+		SetBroker(freshCoordinator.Addr(), freshCoordinator.BrokerID())
+	setCoordinator := func(coordinator *MockBroker) {
+		findCoordinatorResponse := NewMockFindCoordinatorResponse(t).
+			SetCoordinator(CoordinatorTransaction, "my_txn", coordinator)
+		for _, broker := range []*MockBroker{seedBroker, staleCoordinator, freshCoordinator} {
+			broker.SetHandlerByMap(map[string]MockResponse{
+				"MetadataRequest":        metadataResponse,
+				"FindCoordinatorRequest": findCoordinatorResponse,
+			})
+		}
+	}
+	setCoordinator(staleCoordinator)
+
+	config := NewTestConfig()
+	config.Version = V0_11_0_0
+	client, err := NewClient([]string{seedBroker.Addr()}, config)
+	require.NoError(t, err)
+	defer safeClose(t, client)
+
+	broker, err := client.TransactionCoordinator("my_txn")
+	require.NoError(t, err)
+	require.Equal(t, staleCoordinator.BrokerID(), broker.ID())
+
+	// the coordinator fails over, the cached value is kept until refreshed
+	setCoordinator(freshCoordinator)
+	broker, err = client.TransactionCoordinator("my_txn")
+	require.NoError(t, err)
+	require.Equal(t, staleCoordinator.BrokerID(), broker.ID())
+
+	require.NoError(t, client.RefreshTransactionCoordinator("my_txn"))
+	broker, err = client.TransactionCoordinator("my_txn")
+	require.NoError(t, err)
+	require.Equal(t, freshCoordinator.BrokerID(), broker.ID())
+
+	// the coordinator is looked up by transactional ID
+	var lookups int
+	for _, mockBroker := range []*MockBroker{seedBroker, staleCoordinator, freshCoordinator} {
+		for _, rr := range mockBroker.History() {
+			if request, ok := rr.Request.(*FindCoordinatorRequest); ok {
+				require.Equal(t, CoordinatorTransaction, request.CoordinatorType)
+				require.Equal(t, "my_txn", request.CoordinatorKey)
+				lookups++
+			}
+		}
+	}
+	require.Equal(t, 2, lookups)
+}
+
+func TestClientRefreshMetadataBypassesReconnectBackoff(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	defer seedBroker.Close()
+
+	seedBroker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()).
+			SetLeader("my_topic", 0, seedBroker.BrokerID()),
+	})
+
+	config := NewTestConfig()
+	config.Net.ReconnectBackoff = time.Second
+	c, err := NewClient([]string{seedBroker.Addr()}, config)
+	require.NoError(t, err)
+	defer safeClose(t, c)
+
+	// the connection to the leader is lost and its reconnection backed off
+	broker := c.(*client).brokers[seedBroker.BrokerID()]
+	_ = broker.Close()
+	broker.lock.Lock()
+	broker.reconnectAt = time.Now().Add(time.Hour)
+	broker.lock.Unlock()
+
+	leader, err := c.Leader("my_topic", 0)
+	require.NoError(t, err)
+	require.Same(t, broker, leader)
+	connected, connErr := leader.Connected()
+	require.False(t, connected)
+	require.ErrorIs(t, connErr, ErrReconnectBackoff)
+
+	// a metadata refresh reconnects right away
+	require.NoError(t, c.RefreshMetadata("my_topic"))
+	connected, connErr = broker.Connected()
+	require.True(t, connected)
+	require.NoError(t, connErr)
+}
+
 func TestClientCoordinatorWithoutConsumerOffsetsTopic(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	coordinator := NewMockBroker(t, 2)
@@ -1547,6 +2448,7 @@ func TestUpdateBroker(t *testing.T) {
 
 	t.Run("open client adds new broker entries", func(t *testing.T) {
 		c := &client{
+			conf:    NewTestConfig(),
 			brokers: make(map[int32]*Broker),
 		}
 		fn := func() {
@@ -1565,6 +2467,7 @@ func TestUpdateBroker(t *testing.T) {
 
 	t.Run("open client adds, updates and removes broker entries", func(t *testing.T) {
 		c := &client{
+			conf: NewTestConfig(),
 			brokers: map[int32]*Broker{
 				0: {
 					id:   0,
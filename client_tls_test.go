@@ -8,8 +8,10 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"fmt"
 	"math/big"
 	"net"
+	"strconv"
 	"testing"
 	"time"
 )
@@ -223,20 +225,149 @@ func doListenerTLSTest(t *testing.T, expectSuccess bool, serverConfig, clientCon
 }
 
 func TestSetServerName(t *testing.T) {
-	if validServerNameTLS("kafka-server.domain.com:9093", nil).ServerName != "kafka-server.domain.com" {
+	if validServerNameTLS("kafka-server.domain.com:9093", nil, false).ServerName != "kafka-server.domain.com" {
 		t.Fatal("Expected kafka-server.domain.com as tls.ServerName when tls config is nil")
 	}
 
-	if validServerNameTLS("kafka-server.domain.com:9093", &tls.Config{MinVersion: tls.VersionTLS12}).ServerName != "kafka-server.domain.com" {
+	if validServerNameTLS("kafka-server.domain.com:9093", &tls.Config{MinVersion: tls.VersionTLS12}, false).ServerName != "kafka-server.domain.com" {
 		t.Fatal("Expected kafka-server.domain.com as tls.ServerName when tls config ServerName is not provided")
 	}
 
 	c := &tls.Config{ServerName: "kafka-server-other.domain.com", MinVersion: tls.VersionTLS12}
-	if validServerNameTLS("", c).ServerName != "kafka-server-other.domain.com" {
+	if validServerNameTLS("", c, false).ServerName != "kafka-server-other.domain.com" {
 		t.Fatal("Expected kafka-server-other.domain.com as tls.ServerName when tls config ServerName is provided")
 	}
 
-	if validServerNameTLS("host-no-port", nil).ServerName != "" {
+	if validServerNameTLS("host-no-port", nil, false).ServerName != "" {
 		t.Fatal("Expected empty ServerName as the broker addr is missing the port")
 	}
+
+	if validServerNameTLS("kafka-server.domain.com:9093", c, false).ServerName != "kafka-server-other.domain.com" {
+		t.Fatal("Expected kafka-server-other.domain.com as tls.ServerName when not taken from the broker")
+	}
+
+	if validServerNameTLS("kafka-server.domain.com:9093", c, true).ServerName != "kafka-server.domain.com" {
+		t.Fatal("Expected kafka-server.domain.com as tls.ServerName when taken from the broker")
+	}
+	if c.ServerName != "kafka-server-other.domain.com" {
+		t.Fatal("Expected the shared tls config to be left untouched")
+	}
+
+	if validServerNameTLS("host-no-port", c, true).ServerName != "kafka-server-other.domain.com" {
+		t.Fatal("Expected kafka-server-other.domain.com as tls.ServerName as the broker addr is missing the port")
+	}
+}
+
+// TestTLSServerNameFromBroker connects to brokers presenting certificates for
+// their own hostname while the configured ServerName names the bootstrap host.
+func TestTLSServerNameFromBroker(t *testing.T) {
+	cakey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nvb := time.Now().Add(-1 * time.Hour)
+	nva := time.Now().Add(1 * time.Hour)
+	caTemplate := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "ca"},
+		SerialNumber:          big.NewInt(0),
+		NotAfter:              nva,
+		NotBefore:             nvb,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDer, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &cakey.PublicKey, cakey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, err := x509.ParseCertificate(caDer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(caCert)
+
+	// newBroker starts a mock broker presenting a certificate for host only
+	newBroker := func(id int32, host string) *MockBroker {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+			Subject:      pkix.Name{CommonName: host},
+			DNSNames:     []string{host},
+			SerialNumber: big.NewInt(int64(id)),
+			NotAfter:     nva,
+			NotBefore:    nvb,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}, caCert, &key.PublicKey, cakey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+			Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+			MinVersion:   tls.VersionTLS12,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// handshake failures are expected when the server name does not match
+		return NewMockBrokerListener(&testing.T{}, id, listener)
+	}
+	seed := newBroker(1, "broker1.kafka.test")
+	defer seed.Close()
+	other := newBroker(2, "broker2.kafka.test")
+	defer other.Close()
+
+	// the brokers advertise their hostname, which is rewritten to the actual
+	// listener address when dialing
+	addrs := map[string]string{
+		"broker1.kafka.test:" + strconv.Itoa(int(seed.Port())):  seed.Addr(),
+		"broker2.kafka.test:" + strconv.Itoa(int(other.Port())): other.Addr(),
+	}
+	metadata := NewMockMetadataResponse(t).SetController(other.BrokerID())
+	for addr, target := range addrs {
+		if target == seed.Addr() {
+			metadata.SetBroker(addr, seed.BrokerID())
+		} else {
+			metadata.SetBroker(addr, other.BrokerID())
+		}
+	}
+	for _, b := range []*MockBroker{seed, other} {
+		b.SetHandlerByMap(map[string]MockResponse{"MetadataRequest": metadata})
+	}
+
+	for _, fromBroker := range []bool{true, false} {
+		t.Run(fmt.Sprintf("ServerNameFromBroker=%v", fromBroker), func(t *testing.T) {
+			config := NewTestConfig()
+			config.Version = V1_0_0_0
+			config.Metadata.Retry.Max = 0
+			config.Net.AddressRewriter = func(broker string) string { return addrs[broker] }
+			config.Net.TLS.Enable = true
+			config.Net.TLS.ServerNameFromBroker = fromBroker
+			config.Net.TLS.Config = &tls.Config{
+				RootCAs:    pool,
+				ServerName: "broker1.kafka.test",
+				MinVersion: tls.VersionTLS12,
+			}
+
+			client, err := NewClient([]string{"broker1.kafka.test:" + strconv.Itoa(int(seed.Port()))}, config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer safeClose(t, client)
+
+			controller, err := client.Controller()
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = controller.GetMetadata(&MetadataRequest{Version: 5})
+			if fromBroker && err != nil {
+				t.Fatal("Expected the connection to broker2 to be verified against its hostname", err)
+			}
+			if !fromBroker && err == nil {
+				t.Fatal("Expected the connection to broker2 to be verified against the fixed ServerName")
+			}
+		})
+	}
 }
@@ -6,9 +6,11 @@ import (
 	"io"
 	"net"
 	"regexp"
+	"sort"
 	"time"
 
 	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
 	"github.com/rcrowley/go-metrics"
 	"golang.org/x/net/proxy"
 )
@@ -36,6 +38,24 @@ type Config struct {
 		// The maximum duration the administrative Kafka client will wait for ClusterAdmin operations,
 		// including topics, brokers, configurations and ACLs (defaults to 3 seconds).
 		Timeout time.Duration
+		// CachedApiVersions, when set, is stored as the API versions of every
+		// broker when connecting to it instead of probing the broker with an
+		// ApiVersionsRequest, e.g. a response fetched once and shared across
+		// short-lived admin clients to cut their startup latency. A broker is
+		// probed again once a request sent with the cached versions fails with
+		// ErrUnsupportedVersion or loses the connection. See also
+		// Client.SetCachedApiVersions.
+		CachedApiVersions *ApiVersionsResponse
+		// ConnectionPoolSize is the number of connections per broker the
+		// ClusterAdmin spreads its requests over, in turn (default 1). Raising
+		// it avoids head-of-line blocking between many concurrent admin
+		// operations, e.g. describing thousands of topics, at the cost of
+		// ConnectionPoolSize-1 extra connections per broker, which count
+		// against the broker-side connection limits such as
+		// max.connections.per.ip. The connection of the client is part of the
+		// pool; producer, consumer and client metadata requests keep using it
+		// only.
+		ConnectionPoolSize int
 	}
 
 	// Net is the namespace for network-level properties used by the Broker, and
@@ -43,11 +63,25 @@ type Config struct {
 	Net struct {
 		// How many outstanding requests a connection is allowed to have before
 		// sending on it blocks (default 5).
-		// Throughput can improve but message ordering is not guaranteed if Producer.Idempotent is disabled, see:
+		// The producer keeps at most one batch in flight per partition, and
+		// holds back the later batches of a partition while one is retried, so
+		// that raising it improves throughput across partitions without
+		// reordering the messages of a partition. The idempotent producer
+		// additionally relies on the broker enforcing the sequence numbers, and
+		// still requires it to be 1. See:
 		// https://kafka.apache.org/protocol#protocol_network
 		// https://kafka.apache.org/28/documentation.html#producerconfigs_max.in.flight.requests.per.connection
 		MaxOpenRequests int
 
+		// MaxResponseSize is the largest response, in bytes, accepted from a
+		// broker (defaults to 0, which uses `sarama.MaxResponseSize`; the
+		// global limit read when the response is received always applies as
+		// well). A response declaring a larger size is rejected with
+		// ErrResponseTooLarge before its buffer is allocated, and the
+		// connection is then unusable, so keep it above the largest expected
+		// fetch response, see Consumer.Fetch.MaxBytes.
+		MaxResponseSize int32
+
 		// All three of the below configurations are similar to the
 		// `socket.timeout.ms` setting in JVM kafka. All of them default
 		// to 30 seconds.
@@ -55,6 +89,28 @@ type Config struct {
 		ReadTimeout  time.Duration // How long to wait for a response.
 		WriteTimeout time.Duration // How long to wait for a transmit.
 
+		// ReconnectBackoff is the initial delay before reconnecting to a broker
+		// that could not be dialed, similar to `reconnect.backoff.ms` in
+		// librdkafka. Each consecutive failure doubles the delay up to
+		// ReconnectBackoffMax, and a random delay between zero and that value
+		// is used so that clients do not reconnect in lockstep. Opening the
+		// broker before the delay has elapsed fails with ErrReconnectBackoff.
+		// The delay is kept per broker and reset once a connection succeeds;
+		// metadata refreshes, such as Client.RefreshMetadata, are not subject
+		// to it. Defaults to 0, which disables the backoff.
+		ReconnectBackoff time.Duration
+		// ReconnectBackoffMax caps the reconnect backoff (defaults to 10s).
+		ReconnectBackoffMax time.Duration
+
+		// MaxIdleTime closes a broker connection with no request in flight
+		// and nothing sent or received for that long, similar to
+		// `connections.max.idle.ms` in the JVM client. The connection is
+		// reopened transparently by the next request sent to the broker. Useful
+		// against networks dropping or billing for idle connections. Every
+		// close marks the reaped-connections metric. Defaults to 0, which keeps
+		// connections open.
+		MaxIdleTime time.Duration
+
 		// ResolveCanonicalBootstrapServers turns each bootstrap broker address
 		// into a set of IPs, then does a reverse lookup on each one to get its
 		// canonical hostname. This list of hostnames then replaces the
@@ -64,13 +120,30 @@ type Config struct {
 		// hostnames. Defaults to false.
 		ResolveCanonicalBootstrapServers bool
 
+		// AddressRewriter, if set, is called with the address of every broker,
+		// bootstrap or discovered through metadata, right before dialing it and
+		// returns the address to actually connect to. The advertised address
+		// is still what Broker.Addr returns and what brokers are tracked by,
+		// and it is also used for TLS server name verification. Useful when
+		// brokers advertise hostnames that are not resolvable from the client.
+		// Defaults to nil.
+		AddressRewriter func(broker string) string
+
 		TLS struct {
 			// Whether or not to use TLS when connecting to the broker
 			// (defaults to false).
 			Enable bool
 			// The TLS configuration to use for secure connections if
-			// enabled (defaults to nil).
+			// enabled (defaults to nil). If its ServerName is empty, the
+			// hostname of each broker is used for SNI and verification.
 			Config *tls.Config
+			// ServerNameFromBroker makes every connection use the hostname
+			// of its broker as ServerName, even if Config.ServerName is set.
+			// This is needed when brokers present certificates for their own
+			// hostname while ServerName names the bootstrap host. Leave it
+			// disabled when all the brokers must be verified against a fixed
+			// name, such as a load balancer's (defaults to false).
+			ServerNameFromBroker bool
 		}
 
 		// SASL based authentication with broker. While there are multiple SASL authentication methods
@@ -83,7 +156,11 @@ type Config struct {
 			// Possible values: OAUTHBEARER, PLAIN (defaults to PLAIN).
 			Mechanism SASLMechanism
 			// Version is the SASL Protocol Version to use
-			// Kafka > 1.x should use V1, except on Azure EventHub which use V0
+			// Kafka > 1.x should use V1, except on Azure EventHub which use V0.
+			// V1 falls back to V0 for the brokers that only support SaslHandshake
+			// V0, i.e. when Config.Version is older than V1_0_0_0 or when the
+			// broker advertises so in its ApiVersionsResponse, except with the
+			// OAUTHBEARER and CUSTOM mechanisms which require V1.
 			Version int16
 			// Whether or not to send the Kafka SASL handshake first if enabled
 			// (defaults to true). You should only set this to false if you're using
@@ -109,6 +186,23 @@ type Config struct {
 			// AccessTokenProvider interface docs for proper implementation
 			// guidelines.
 			TokenProvider AccessTokenProvider
+			// TokenProviderContext is like TokenProvider but receives a
+			// context bounded by Net.DialTimeout. It takes precedence over
+			// TokenProvider when both are set.
+			TokenProviderContext AccessTokenProviderContext
+			// CustomAuthenticator is a generator of a user provided implementation
+			// of a SASL mechanism, used when Mechanism is SASLTypeCustom. It is
+			// called for every authentication, and the name returned by the
+			// authenticator's Mechanism method is the one advertised in the SASL
+			// handshake. Requires SASLHandshakeV1.
+			CustomAuthenticator func() SASLAuthenticator
+			// Whether to re-authenticate proactively over the existing connection
+			// shortly before the session lifetime returned by the broker
+			// (`connections.max.reauth.ms`, KIP-368) expires, picking up the
+			// current credentials. Otherwise the session is re-authenticated by the
+			// first request sent after that point. Only applies to SASLHandshakeV1.
+			// Defaults to false.
+			ReauthenticationEnabled bool
 
 			GSSAPI GSSAPIConfig
 		}
@@ -131,6 +225,21 @@ type Config struct {
 			// The proxy dialer to use enabled (defaults to nil).
 			Dialer proxy.Dialer
 		}
+
+		// RequestInterceptor, if set, is notified of every request sent to
+		// the brokers and of its response, e.g. to record the latency of each
+		// protocol request (defaults to nil).
+		RequestInterceptor RequestInterceptor
+
+		// CorrelationIDGenerator, if set, is called to obtain the correlation
+		// ID of every request sent to the brokers instead of incrementing a
+		// counter per connection, e.g. to correlate requests with external
+		// trace IDs in the broker logs. It is called concurrently for all the
+		// brokers. Responses are matched to requests by correlation ID, so the
+		// IDs must not collide between the requests in flight on a connection,
+		// otherwise responses are rejected as mismatched. This is an advanced
+		// diagnostic hook (defaults to nil).
+		CorrelationIDGenerator func() int32
 	}
 
 	// Metadata is the namespace for metadata management properties used by the
@@ -168,7 +277,9 @@ type Config struct {
 
 		// Whether to allow auto-create topics in metadata refresh. If set to true,
 		// the broker may auto-create topics that we requested which do not already exist,
-		// if it is configured to do so (`auto.create.topics.enable` is true). Defaults to true.
+		// if it is configured to do so (`auto.create.topics.enable` is true). If false,
+		// requesting a topic that does not exist fails with ErrUnknownTopicOrPartition.
+		// Only honored by MetadataRequest v4 (Kafka 0.11) and later. Defaults to false.
 		AllowAutoTopicCreation bool
 
 		// SingleFlight controls whether to send a single metadata refresh request at a given time
@@ -178,6 +289,16 @@ type Config struct {
 		// See https://github.com/IBM/sarama/issues/3224 for more details.
 		// SingleFlight defaults to true.
 		SingleFlight bool
+
+		// Interceptor, if set, is called with every metadata response
+		// received by the client, after it is decoded and before the client
+		// updates its cache with it (defaults to nil). It lets tests inject
+		// synthetic metadata, e.g. move partition leaders, shrink ISRs or add
+		// errors, to exercise leader migrations deterministically. It is meant
+		// for testing only: mutating the response makes the client's view of
+		// the cluster diverge from the actual one, which can route requests to
+		// the wrong brokers.
+		Interceptor func(*MetadataResponse)
 	}
 
 	// Producer is the namespace for configuration related to producing messages,
@@ -186,6 +307,10 @@ type Config struct {
 		// The maximum permitted size of a message (defaults to 1000000). Should be
 		// set equal to or smaller than the broker's `message.max.bytes`.
 		MaxMessageBytes int
+		// If enabled, messages larger than MaxMessageBytes are not rejected
+		// with ErrMessageSizeTooLarge before being batched, leaving the
+		// enforcement to the broker (defaults to false).
+		SkipMessageSizeCheck bool
 		// The level of acknowledgement reliability needed from the broker (defaults
 		// to WaitForLocal). Equivalent to the `request.required.acks` setting of the
 		// JVM producer.
@@ -201,8 +326,36 @@ type Config struct {
 		Compression CompressionCodec
 		// The level of compression to use on messages. The meaning depends
 		// on the actual compression type used and defaults to default compression
-		// level for the codec.
+		// level for the codec. For gzip it ranges from 1 (best speed) to 9 (best
+		// compression). For zstd it is a standard zstd level from 1 to 22, which
+		// is mapped to the encoder levels from zstd.SpeedFastest to
+		// zstd.SpeedBestCompression.
 		CompressionLevel int
+		// CompressionByTopic overrides Compression for the messages produced to
+		// the listed topics, so that e.g. a high-volume topic can be compressed
+		// with zstd while compression is disabled for another (defaults to nil,
+		// all topics use Compression). A topic overriding the codec uses its
+		// default compression level unless one is set in CompressionLevelByTopic.
+		CompressionByTopic map[string]CompressionCodec
+		// CompressionLevelByTopic overrides CompressionLevel for the messages
+		// produced to the listed topics (defaults to nil).
+		CompressionLevelByTopic map[string]int
+		// TimestampType is the timestamp type set in the attributes of the
+		// produced batches (defaults to CreateTime). Mirroring and replication
+		// tools can set LogAppendTime to preserve the timestamp type of the
+		// source records. The `message.timestamp.type` config of the topic
+		// still governs how the broker handles the timestamps. LogAppendTime
+		// requires Version >= V0_10_0_0.
+		TimestampType TimestampType
+		// ZstdDictionary is a zstd dictionary, as built by e.g. `zstd --train`,
+		// used to compress the messages compressed with zstd, which improves
+		// the compression ratio of small messages sharing a lot of structure
+		// (defaults to nil, no dictionary). It requires zstd compression. The
+		// consumer decompresses zstd records with it too. As dictionaries are not
+		// part of the Kafka protocol, producers and consumers must be configured
+		// with the same dictionary out-of-band, and consumers without it fail
+		// to decompress the messages.
+		ZstdDictionary []byte
 		// Generates partitioners for choosing the partition to send messages to
 		// (defaults to hashing the message key). Similar to the `partitioner.class`
 		// setting for the JVM producer.
@@ -218,6 +371,13 @@ type Config struct {
 			// Amount of time a transaction can remain unresolved (neither committed nor aborted)
 			// default is 1 min
 			Timeout time.Duration
+			// BatchedAddPartitions makes the producer add partitions to its
+			// transactions with the batched AddPartitionsToTxn version 4 of
+			// KIP-890 when the coordinator supports it, falling back to the single
+			// transaction versions otherwise. Kafka reserves these versions to
+			// brokers, so they require the ClusterAction permission on clusters
+			// with authorization enabled (default false).
+			BatchedAddPartitions bool
 
 			Retry struct {
 				// The total number of times to retry sending a message (default 50).
@@ -244,6 +404,15 @@ type Config struct {
 			// Successes channel (default disabled).
 			Successes bool
 
+			// SuccessesBufferSize is the number of successfully delivered
+			// messages buffered in the Successes channel (defaults to 0,
+			// unbuffered). Without a buffer, the producer hands every success
+			// over to the reader before processing the next responses, so a
+			// slow reader stalls the deliveries to all the partitions. A buffer
+			// absorbs bursts at the cost of keeping up to that many messages,
+			// with their keys and values, in memory until they are read.
+			SuccessesBufferSize int
+
 			// If enabled, messages that failed to deliver will be returned on the
 			// Errors channel, including error (default enabled).
 			Errors bool
@@ -260,6 +429,11 @@ type Config struct {
 			// The best-effort number of messages needed to trigger a flush. Use
 			// `MaxMessages` to set a hard upper limit.
 			Messages int
+			// The best-effort number of messages of a single partition needed to
+			// trigger a flush, even if `Messages` is not reached yet. This keeps
+			// the batches of a busy partition from waiting on many low-traffic
+			// partitions led by the same broker. Defaults to 0 for no limit.
+			MaxMessagesPerPartition int
 			// The best-effort frequency of flushes. Equivalent to
 			// `queue.buffering.max.ms` setting of JVM producer.
 			Frequency time.Duration
@@ -281,6 +455,12 @@ type Config struct {
 			// more sophisticated backoff strategies. This takes precedence over
 			// `Backoff` if set.
 			BackoffFunc func(retries, maxRetries int) time.Duration
+			// Like `BackoffFunc`, but also receives the error that caused the
+			// retry, e.g. to back off longer when throttled than when a
+			// partition leader is unavailable. The error may be nil when the
+			// retry was not caused by a specific error. This takes precedence
+			// over both `BackoffFunc` and `Backoff` if set.
+			BackoffFuncWithError func(retries, maxRetries int, err error) time.Duration
 			// The maximum length of the bridging buffer between `input` and `retries` channels
 			// in AsyncProducer#retryHandler.
 			// The limit is to prevent this buffer from overflowing or causing OOM.
@@ -295,8 +475,23 @@ type Config struct {
 			// Any value between 0 and 32 MB is pushed to 32 MB.
 			// A zero or negative value indicates unlimited.
 			MaxBufferBytes int64
+			// Which message to discard, and return on Errors() with
+			// ErrProducerRetryBufferOverflow, once the retry buffer reaches
+			// MaxBufferLength or MaxBufferBytes. Defaults to RetryBufferDropOldest.
+			// The current size of the buffer is exposed by the
+			// producer-retry-buffer-bytes and producer-retry-buffer-length metrics.
+			BufferOverflowPolicy RetryBufferOverflowPolicy
 		}
 
+		// OnPartitionError is called, when set, once per batch of a partition
+		// that failed to be produced, with the error returned by the broker
+		// for the partition or the error of the whole request, whether the
+		// messages are retried or returned on the Errors channel as usual.
+		// It lets applications e.g. circuit-break a single partition whose
+		// leader is unavailable. It is called by the goroutine handling the
+		// responses of the broker, so it must return quickly.
+		OnPartitionError func(topic string, partition int32, err error)
+
 		// Interceptors to be called when the producer dispatcher reads the
 		// message for the first time. Interceptors allows to intercept and
 		// possible mutate the message before they are published to Kafka
@@ -304,6 +499,24 @@ type Config struct {
 		// OnSend() is passed to the second interceptor OnSend(), and so on in
 		// the interceptor chain.
 		Interceptors []ProducerInterceptor
+
+		// InterceptorsV2 are called, in order, after Interceptors when the
+		// producer dispatcher reads a message for the first time. Each
+		// interceptor is called with every message returned by the previous
+		// one, so that messages can be dropped or fanned out along the chain.
+		// Existing ProducerInterceptors can be chained here with
+		// AdaptProducerInterceptor. Dropped messages are reported neither on
+		// the Successes nor on the Errors channel; a SyncProducer returns
+		// them as sent with a partition and offset of -1. The messages added
+		// by the chain are produced and reported like any other, but are not
+		// waited for by a SyncProducer.
+		InterceptorsV2 []ProducerInterceptorV2
+
+		// ClientID, when set, replaces the global ClientID in the header of
+		// produce requests, so that brokers apply their client-id quotas to
+		// produce traffic separately. Other requests, such as metadata and
+		// admin ones, keep using the global ClientID.
+		ClientID string
 	}
 
 	// Consumer is the namespace for configuration related to consuming messages,
@@ -311,6 +524,15 @@ type Config struct {
 	Consumer struct {
 		// Group is the namespace for configuring consumer group.
 		Group struct {
+			// Protocol selects the rebalance protocol used by the consumer group:
+			// GroupProtocolClassic performs partition assignment client-side using
+			// JoinGroup/SyncGroup, GroupProtocolConsumer lets the broker compute the
+			// assignment using the KIP-848 ConsumerGroupHeartbeat API (requires
+			// Version >= 4.0). It only selects the initial protocol, use
+			// ConsumerGroup.SetGroupProtocol to migrate a running member
+			// (default GroupProtocolClassic).
+			Protocol string
+
 			Session struct {
 				// The timeout used to detect consumer failures when using Kafka's group management facility.
 				// The consumer sends periodic heartbeats to indicate its liveness to the broker.
@@ -342,6 +564,13 @@ type Config struct {
 				// default: [ NewBalanceStrategyRange() ]
 				GroupStrategies []BalanceStrategy
 
+				// If enabled, members report Config.RackID in their subscription and the
+				// group leader passes the racks of the partition replicas to the strategies
+				// implementing RackAwareBalanceStrategy, such as the range and sticky ones,
+				// which then prefer assigning partitions to members in the same rack as one
+				// of their replicas to reduce cross-rack fetch traffic (defaults to false).
+				RackAware bool
+
 				// The maximum allowed time for each worker to join the group once a rebalance has begun.
 				// This is basically a limit on the amount of time needed for all tasks to flush any pending
 				// data and commit offsets. If the timeout is exceeded, then the worker will be removed from
@@ -364,6 +593,23 @@ type Config struct {
 				// coordinator for the group.
 				UserData []byte
 			}
+			Messages struct {
+				// How frequently the last message delivered by ConsumerGroup.Messages for
+				// each claimed partition is marked as consumed (default 1s).
+				MarkInterval time.Duration
+			}
+
+			// DeadLetter configures where ConsumerGroupSession.MarkError forwards
+			// the messages a handler failed to process. It is disabled unless
+			// Producer is set.
+			DeadLetter struct {
+				// Producer sends the failed messages to their dead letter topic.
+				// It is not closed by the consumer group.
+				Producer SyncProducer
+				// TopicFunc returns the dead letter topic of a failed message,
+				// it must be set along with Producer.
+				TopicFunc func(*ConsumerMessage) string
+			}
 
 			// support KIP-345
 			InstanceId string
@@ -375,6 +621,14 @@ type Config struct {
 			// dangerous to reset the offset automatically, particularly in the latter case. Defaults
 			// to true to maintain existing behavior.
 			ResetInvalidOffsets bool
+
+			// If true, a partition consumer detecting that the log of its partition
+			// was truncated after an unclean leader election (KIP-320, requires
+			// V2_7_0_0) resumes from the truncation offset and reports a
+			// *LogTruncationError. If false, the partition consumer is shut down
+			// with that error instead so the records lost can be handled. Defaults
+			// to true.
+			ResetOnTruncation bool
 		}
 
 		Retry struct {
@@ -422,6 +676,15 @@ type Config struct {
 			// record exceeds the limit, so keep this comfortably below the global
 			// `sarama.MaxResponseSize` safety net. Only used for Kafka >= 0.10.1.
 			MaxBytes int32
+			// DecompressionWorkers is the number of goroutines decompressing the
+			// record batches of fetch responses. When set, compressed batches are
+			// decompressed on these workers, concurrently across partitions,
+			// instead of inline while decoding the response of each broker, so
+			// that a few partitions with large batches do not hold up the
+			// others. Messages are still delivered in offset order per
+			// partition. Only record batches (Kafka >= 0.11) are concerned.
+			// Defaults to 0: decompress inline.
+			DecompressionWorkers int
 		}
 		// The maximum amount of time the broker will wait for Consumer.Fetch.Min
 		// bytes to become available before it returns fewer than that anyways. The
@@ -456,6 +719,12 @@ type Config struct {
 			// If enabled, any errors that occurred while consuming are returned on
 			// the Errors channel (default disabled).
 			Errors bool
+
+			// If enabled, the offset reached is returned on the EOFs channel of a
+			// PartitionConsumer each time it catches up with the end of its
+			// partition (default disabled). Unlike Errors, the channel does not
+			// need to be read.
+			EOF bool
 		}
 
 		// Offsets specifies configuration for how and when to commit consumed
@@ -481,6 +750,25 @@ type Config struct {
 			// Should be OffsetNewest or OffsetOldest. Defaults to OffsetNewest.
 			Initial int64
 
+			// OutOfRangeReset is the policy applied when a consumed or
+			// committed offset is out of range, e.g. after retention deleted
+			// the records it pointed to. OffsetResetEarliest and
+			// OffsetResetLatest reset to the oldest or newest offset, also
+			// while consuming, and OffsetResetError reports an
+			// *OffsetOutOfRangeError instead of skipping over the lost data.
+			// Defaults to OffsetResetInitial, which resets consumer group
+			// claims to Initial and stops partition consumers.
+			OutOfRangeReset OffsetResetPolicy
+
+			// RequireStable makes the offset manager fetch the committed
+			// offsets with the require_stable flag (KIP-447), so that the
+			// coordinator answers ErrUnstableOffsetCommit instead of offsets a
+			// pending transaction might still roll back. The fetch is then
+			// retried up to Metadata.Retry.Max times. Transactional
+			// consume-transform-produce applications need it for exactly-once
+			// processing (default disabled). Requires Version >= V2_5_0_0.
+			RequireStable bool
+
 			// The retention duration for committed offsets. If zero, disabled
 			// (in which case the `offsets.retention.minutes` option on the
 			// broker will be used).  Kafka only supports precision up to
@@ -491,8 +779,14 @@ type Config struct {
 
 			Retry struct {
 				// The total number of times to retry failing commit
-				// requests during OffsetManager shutdown (default 3).
+				// requests (default 3). Retriable errors, such as a
+				// request timeout or a coordinator change, are only
+				// reported once the retries are exhausted; commits
+				// fenced by a rebalance are never retried.
 				Max int
+				// How long to wait before retrying a failing commit
+				// (default 100ms).
+				Backoff time.Duration
 			}
 		}
 
@@ -501,6 +795,21 @@ type Config struct {
 		//	- use `ReadCommitted` to hide messages that are part of an aborted transaction
 		IsolationLevel IsolationLevel
 
+		// PreferClosestReplica determines whether the consumer follows the
+		// preferred read replica returned by the partition leader in fetch
+		// responses (KIP-392) and fetches from that follower until it fails,
+		// in which case it falls back to the leader. The leader only selects
+		// a follower when Config.RackID is set, the version is at least
+		// V2_3_0_0 and `replica.selector.class` is configured on the broker.
+		// Set it to false to always fetch from the leader (default true).
+		PreferClosestReplica bool
+
+		// OnAbortedMessage is called, when set, with the offset of every record
+		// discarded for being part of an aborted transaction while consuming
+		// with `ReadCommitted`. Control records are not reported. It is called
+		// by the goroutine parsing fetch responses, so it must return quickly.
+		OnAbortedMessage func(topic string, partition int32, offset int64)
+
 		// Interceptors to be called just before the record is sent to the
 		// messages channel. Interceptors allows to intercept and possible
 		// mutate the message before they are returned to the client.
@@ -508,6 +817,12 @@ type Config struct {
 		// passed to the second interceptor OnConsume(), and so on in the
 		// interceptor chain.
 		Interceptors []ConsumerInterceptor
+
+		// ClientID, when set, replaces the global ClientID in the header of
+		// fetch requests, so that brokers apply their client-id quotas to
+		// consume traffic separately. Other requests, such as metadata,
+		// offset and group coordination ones, keep using the global ClientID.
+		ClientID string
 	}
 
 	// A user-provided string sent with every request to the brokers for logging,
@@ -550,11 +865,13 @@ func NewConfig() *Config {
 	c.Admin.Retry.Max = 5
 	c.Admin.Retry.Backoff = 100 * time.Millisecond
 	c.Admin.Timeout = 3 * time.Second
+	c.Admin.ConnectionPoolSize = 1
 
 	c.Net.MaxOpenRequests = 5
 	c.Net.DialTimeout = 30 * time.Second
 	c.Net.ReadTimeout = 30 * time.Second
 	c.Net.WriteTimeout = 30 * time.Second
+	c.Net.ReconnectBackoffMax = 10 * time.Second
 	c.Net.SASL.Handshake = true
 	c.Net.SASL.Version = SASLHandshakeV1
 
@@ -562,7 +879,6 @@ func NewConfig() *Config {
 	c.Metadata.Retry.Backoff = 250 * time.Millisecond
 	c.Metadata.RefreshFrequency = defaultMetadataRefreshFrequency
 	c.Metadata.Full = true
-	c.Metadata.AllowAutoTopicCreation = true
 	c.Metadata.SingleFlight = true
 
 	c.Producer.MaxMessageBytes = 1024 * 1024
@@ -584,19 +900,24 @@ func NewConfig() *Config {
 	c.Consumer.Retry.Backoff = 2 * time.Second
 	c.Consumer.MaxWaitTime = 500 * time.Millisecond
 	c.Consumer.MaxProcessingTime = 100 * time.Millisecond
+	c.Consumer.PreferClosestReplica = true
 	c.Consumer.Return.Errors = false
 	c.Consumer.Offsets.AutoCommit.Enable = true
 	c.Consumer.Offsets.AutoCommit.Interval = 1 * time.Second
 	c.Consumer.Offsets.Initial = OffsetNewest
 	c.Consumer.Offsets.Retry.Max = 3
+	c.Consumer.Offsets.Retry.Backoff = 100 * time.Millisecond
 
+	c.Consumer.Group.Protocol = GroupProtocolClassic
 	c.Consumer.Group.Session.Timeout = 10 * time.Second
 	c.Consumer.Group.Heartbeat.Interval = 3 * time.Second
 	c.Consumer.Group.Rebalance.GroupStrategies = []BalanceStrategy{NewBalanceStrategyRange()}
 	c.Consumer.Group.Rebalance.Timeout = 60 * time.Second
 	c.Consumer.Group.Rebalance.Retry.Max = 4
 	c.Consumer.Group.Rebalance.Retry.Backoff = 2 * time.Second
+	c.Consumer.Group.Messages.MarkInterval = 1 * time.Second
 	c.Consumer.Group.ResetInvalidOffsets = true
+	c.Consumer.Group.ResetOnTruncation = true
 
 	c.ClientID = defaultClientID
 	c.ChannelBufferSize = 256
@@ -633,8 +954,8 @@ func (c *Config) Validate() error {
 	if c.Producer.Flush.Bytes >= int(MaxRequestSize) {
 		Logger.Println("Producer.Flush.Bytes must be smaller than MaxRequestSize; it will be ignored.")
 	}
-	if (c.Producer.Flush.Bytes > 0 || c.Producer.Flush.Messages > 0) && c.Producer.Flush.Frequency == 0 {
-		Logger.Println("Producer.Flush: Bytes or Messages are set, but Frequency is not; messages may not get flushed.")
+	if (c.Producer.Flush.Bytes > 0 || c.Producer.Flush.Messages > 0 || c.Producer.Flush.MaxMessagesPerPartition > 0) && c.Producer.Flush.Frequency == 0 {
+		Logger.Println("Producer.Flush: Bytes, Messages or MaxMessagesPerPartition are set, but Frequency is not; messages may not get flushed.")
 	}
 	if c.Producer.Timeout%time.Millisecond != 0 {
 		Logger.Println("Producer.Timeout only supports millisecond resolution; nanoseconds will be truncated.")
@@ -665,12 +986,20 @@ func (c *Config) Validate() error {
 	switch {
 	case c.Net.MaxOpenRequests <= 0:
 		return ConfigurationError("Net.MaxOpenRequests must be > 0")
+	case c.Net.MaxResponseSize < 0:
+		return ConfigurationError("Net.MaxResponseSize must be >= 0")
 	case c.Net.DialTimeout <= 0:
 		return ConfigurationError("Net.DialTimeout must be > 0")
 	case c.Net.ReadTimeout <= 0:
 		return ConfigurationError("Net.ReadTimeout must be > 0")
 	case c.Net.WriteTimeout <= 0:
 		return ConfigurationError("Net.WriteTimeout must be > 0")
+	case c.Net.ReconnectBackoff < 0:
+		return ConfigurationError("Net.ReconnectBackoff must be >= 0")
+	case c.Net.ReconnectBackoff > 0 && c.Net.ReconnectBackoffMax < c.Net.ReconnectBackoff:
+		return ConfigurationError("Net.ReconnectBackoffMax must be >= Net.ReconnectBackoff")
+	case c.Net.MaxIdleTime < 0:
+		return ConfigurationError("Net.MaxIdleTime must be >= 0")
 	case c.Net.SASL.Enable:
 		if c.Net.SASL.Mechanism == "" {
 			c.Net.SASL.Mechanism = SASLTypePlaintext
@@ -687,8 +1016,8 @@ func (c *Config) Validate() error {
 				return ConfigurationError("Net.SASL.Password must not be empty when SASL is enabled")
 			}
 		case SASLTypeOAuth:
-			if c.Net.SASL.TokenProvider == nil {
-				return ConfigurationError("An AccessTokenProvider instance must be provided to Net.SASL.TokenProvider")
+			if c.Net.SASL.TokenProvider == nil && c.Net.SASL.TokenProviderContext == nil {
+				return ConfigurationError("An AccessTokenProvider or AccessTokenProviderContext instance must be provided to Net.SASL.TokenProvider or Net.SASL.TokenProviderContext")
 			}
 		case SASLTypeSCRAMSHA256, SASLTypeSCRAMSHA512:
 			if c.Net.SASL.User == "" {
@@ -700,6 +1029,13 @@ func (c *Config) Validate() error {
 			if c.Net.SASL.SCRAMClientGeneratorFunc == nil {
 				return ConfigurationError("A SCRAMClientGeneratorFunc function must be provided to Net.SASL.SCRAMClientGeneratorFunc")
 			}
+		case SASLTypeCustom:
+			if c.Net.SASL.CustomAuthenticator == nil {
+				return ConfigurationError("A CustomAuthenticator function must be provided to Net.SASL.CustomAuthenticator")
+			}
+			if c.Net.SASL.Version != SASLHandshakeV1 {
+				return ConfigurationError("Net.SASL.Version must be SASLHandshakeV1 when the CUSTOM mechanism is used")
+			}
 		case SASLTypeGSSAPI:
 			if c.Net.SASL.GSSAPI.ServiceName == "" {
 				return ConfigurationError("Net.SASL.GSSAPI.ServiceName must not be empty when GSS-API mechanism is used")
@@ -716,6 +1052,9 @@ func (c *Config) Validate() error {
 					return ConfigurationError("Net.SASL.GSSAPI.KeyTabPath must not be empty when GSS-API mechanism is used" +
 						" and Net.SASL.GSSAPI.AuthType = KRB5_KEYTAB_AUTH")
 				}
+				if c.Net.SASL.GSSAPI.KeyTabReloadInterval < 0 {
+					return ConfigurationError("Net.SASL.GSSAPI.KeyTabReloadInterval must be >= 0")
+				}
 			case KRB5_CCACHE_AUTH:
 				if c.Net.SASL.GSSAPI.CCachePath == "" {
 					return ConfigurationError("Net.SASL.GSSAPI.CCachePath must not be empty when GSS-API mechanism is used" +
@@ -735,8 +1074,8 @@ func (c *Config) Validate() error {
 				return ConfigurationError("Net.SASL.GSSAPI.Realm must not be empty when GSS-API mechanism is used")
 			}
 		default:
-			msg := fmt.Sprintf("The SASL mechanism configuration is invalid. Possible values are `%s`, `%s`, `%s`, `%s` and `%s`",
-				SASLTypeOAuth, SASLTypePlaintext, SASLTypeSCRAMSHA256, SASLTypeSCRAMSHA512, SASLTypeGSSAPI)
+			msg := fmt.Sprintf("The SASL mechanism configuration is invalid. Possible values are `%s`, `%s`, `%s`, `%s`, `%s` and `%s`",
+				SASLTypeOAuth, SASLTypePlaintext, SASLTypeSCRAMSHA256, SASLTypeSCRAMSHA512, SASLTypeGSSAPI, SASLTypeCustom)
 			return ConfigurationError(msg)
 		}
 	}
@@ -745,6 +1084,8 @@ func (c *Config) Validate() error {
 	switch {
 	case c.Admin.Timeout <= 0:
 		return ConfigurationError("Admin.Timeout must be > 0")
+	case c.Admin.ConnectionPoolSize <= 0:
+		return ConfigurationError("Admin.ConnectionPoolSize must be > 0")
 	}
 
 	// validate the Metadata values
@@ -767,10 +1108,14 @@ func (c *Config) Validate() error {
 		return ConfigurationError("Producer.Timeout must be > 0")
 	case c.Producer.Partitioner == nil:
 		return ConfigurationError("Producer.Partitioner must not be nil")
+	case c.Producer.Return.SuccessesBufferSize < 0:
+		return ConfigurationError("Producer.Return.SuccessesBufferSize must be >= 0")
 	case c.Producer.Flush.Bytes < 0:
 		return ConfigurationError("Producer.Flush.Bytes must be >= 0")
 	case c.Producer.Flush.Messages < 0:
 		return ConfigurationError("Producer.Flush.Messages must be >= 0")
+	case c.Producer.Flush.MaxMessagesPerPartition < 0:
+		return ConfigurationError("Producer.Flush.MaxMessagesPerPartition must be >= 0")
 	case c.Producer.Flush.Frequency < 0:
 		return ConfigurationError("Producer.Flush.Frequency must be >= 0")
 	case c.Producer.Flush.MaxMessages < 0:
@@ -781,22 +1126,46 @@ func (c *Config) Validate() error {
 		return ConfigurationError("Producer.Retry.Max must be >= 0")
 	case c.Producer.Retry.Backoff < 0:
 		return ConfigurationError("Producer.Retry.Backoff must be >= 0")
+	case c.Producer.Retry.BufferOverflowPolicy != RetryBufferDropOldest && c.Producer.Retry.BufferOverflowPolicy != RetryBufferDropNewest:
+		return ConfigurationError("Producer.Retry.BufferOverflowPolicy must be RetryBufferDropOldest or RetryBufferDropNewest")
+	case c.Producer.TimestampType != CreateTime && c.Producer.TimestampType != LogAppendTime:
+		return ConfigurationError("Producer.TimestampType must be CreateTime or LogAppendTime")
+	case c.Producer.TimestampType == LogAppendTime && !c.Version.IsAtLeast(V0_10_0_0):
+		return ConfigurationError("Producer.TimestampType LogAppendTime requires Version >= V0_10_0_0")
 	}
 
-	if c.Producer.Compression == CompressionLZ4 && !c.Version.IsAtLeast(V0_10_0_0) {
-		return ConfigurationError("lz4 compression requires Version >= V0_10_0_0")
+	if err := c.validateCompression("", c.Producer.Compression, c.Producer.CompressionLevel); err != nil {
+		return err
 	}
 
-	if c.Producer.Compression == CompressionGZIP {
-		if c.Producer.CompressionLevel != CompressionLevelDefault {
-			if _, err := gzip.NewWriterLevel(io.Discard, c.Producer.CompressionLevel); err != nil {
-				return ConfigurationError(fmt.Sprintf("gzip compression does not work with level %d: %v", c.Producer.CompressionLevel, err))
-			}
+	topics := make([]string, 0, len(c.Producer.CompressionByTopic)+len(c.Producer.CompressionLevelByTopic))
+	for topic := range c.Producer.CompressionByTopic {
+		topics = append(topics, topic)
+	}
+	for topic := range c.Producer.CompressionLevelByTopic {
+		if _, ok := c.Producer.CompressionByTopic[topic]; !ok {
+			topics = append(topics, topic)
+		}
+	}
+	sort.Strings(topics)
+	for _, topic := range topics {
+		codec, level := c.producerCompression(topic)
+		if err := c.validateCompression(fmt.Sprintf("topic %q: ", topic), codec, level); err != nil {
+			return err
 		}
 	}
 
-	if c.Producer.Compression == CompressionZSTD && !c.Version.IsAtLeast(V2_1_0_0) {
-		return ConfigurationError("zstd compression requires Version >= V2_1_0_0")
+	if len(c.Producer.ZstdDictionary) > 0 {
+		usesZstd := c.Producer.Compression == CompressionZSTD
+		for _, codec := range c.Producer.CompressionByTopic {
+			usesZstd = usesZstd || codec == CompressionZSTD
+		}
+		if !usesZstd {
+			return ConfigurationError("Producer.ZstdDictionary requires zstd compression")
+		}
+		if _, err := zstd.InspectDictionary(c.Producer.ZstdDictionary); err != nil {
+			return ConfigurationError(fmt.Sprintf("Producer.ZstdDictionary is not a valid zstd dictionary: %v", err))
+		}
 	}
 
 	if c.Producer.Idempotent {
@@ -830,6 +1199,8 @@ func (c *Config) Validate() error {
 		return ConfigurationError("Consumer.Fetch.MaxBytes must be > 0")
 	case c.Consumer.MaxWaitTime < 1*time.Millisecond:
 		return ConfigurationError("Consumer.MaxWaitTime must be >= 1ms")
+	case c.Consumer.Fetch.DecompressionWorkers < 0:
+		return ConfigurationError("Consumer.Fetch.DecompressionWorkers must be >= 0")
 	case c.Consumer.MaxProcessingTime <= 0:
 		return ConfigurationError("Consumer.MaxProcessingTime must be > 0")
 	case c.Consumer.Retry.Backoff < 0:
@@ -840,10 +1211,16 @@ func (c *Config) Validate() error {
 		return ConfigurationError("Consumer.Offsets.AutoCommit.Interval must be > 0")
 	case c.Consumer.Offsets.Initial != OffsetOldest && c.Consumer.Offsets.Initial != OffsetNewest:
 		return ConfigurationError("Consumer.Offsets.Initial must be OffsetOldest or OffsetNewest")
+	case c.Consumer.Offsets.OutOfRangeReset < OffsetResetInitial || c.Consumer.Offsets.OutOfRangeReset > OffsetResetError:
+		return ConfigurationError("Consumer.Offsets.OutOfRangeReset must be a valid OffsetResetPolicy")
 	case c.Consumer.Offsets.Retry.Max < 0:
 		return ConfigurationError("Consumer.Offsets.Retry.Max must be >= 0")
+	case c.Consumer.Offsets.Retry.Backoff < 0:
+		return ConfigurationError("Consumer.Offsets.Retry.Backoff must be >= 0")
 	case c.Consumer.IsolationLevel != ReadUncommitted && c.Consumer.IsolationLevel != ReadCommitted:
 		return ConfigurationError("Consumer.IsolationLevel must be ReadUncommitted or ReadCommitted")
+	case c.Consumer.Offsets.RequireStable && !c.Version.IsAtLeast(V2_5_0_0):
+		return ConfigurationError("Consumer.Offsets.RequireStable requires Version >= V2_5_0_0")
 	}
 
 	if c.Consumer.Offsets.CommitInterval != 0 {
@@ -876,6 +1253,8 @@ func (c *Config) Validate() error {
 		return ConfigurationError("Consumer.Group.Rebalance.Retry.Max must be >= 0")
 	case c.Consumer.Group.Rebalance.Retry.Backoff < 0:
 		return ConfigurationError("Consumer.Group.Rebalance.Retry.Backoff must be >= 0")
+	case c.Consumer.Group.Messages.MarkInterval <= 0:
+		return ConfigurationError("Consumer.Group.Messages.MarkInterval must be > 0")
 	}
 
 	for _, strategy := range c.Consumer.Group.Rebalance.GroupStrategies {
@@ -884,6 +1263,14 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	if err := validateGroupProtocol(c); err != nil {
+		return err
+	}
+
+	if (c.Consumer.Group.DeadLetter.Producer == nil) != (c.Consumer.Group.DeadLetter.TopicFunc == nil) {
+		return ConfigurationError("Consumer.Group.DeadLetter.Producer and Consumer.Group.DeadLetter.TopicFunc must be set together")
+	}
+
 	if c.Consumer.Group.InstanceId != "" {
 		if !c.Version.IsAtLeast(V2_3_0_0) {
 			return ConfigurationError("Consumer.Group.InstanceId need Version >= 2.3")
@@ -903,10 +1290,58 @@ func (c *Config) Validate() error {
 	if !c.Version.IsAtLeast(V1_0_0_0) && !validClientID.MatchString(c.ClientID) {
 		return ConfigurationError(fmt.Sprintf("ClientID value %q is not valid for Kafka versions before 1.0.0", c.ClientID))
 	}
+	if !c.Version.IsAtLeast(V1_0_0_0) && c.Producer.ClientID != "" && !validClientID.MatchString(c.Producer.ClientID) {
+		return ConfigurationError(fmt.Sprintf("Producer.ClientID value %q is not valid for Kafka versions before 1.0.0", c.Producer.ClientID))
+	}
+	if !c.Version.IsAtLeast(V1_0_0_0) && c.Consumer.ClientID != "" && !validClientID.MatchString(c.Consumer.ClientID) {
+		return ConfigurationError(fmt.Sprintf("Consumer.ClientID value %q is not valid for Kafka versions before 1.0.0", c.Consumer.ClientID))
+	}
+
+	return nil
+}
+
+// validateCompression checks that codec is supported by the configured Version
+// and that level is valid for it, prefixing the error messages with prefix.
+func (c *Config) validateCompression(prefix string, codec CompressionCodec, level int) error {
+	if codec == CompressionLZ4 && !c.Version.IsAtLeast(V0_10_0_0) {
+		return ConfigurationError(prefix + "lz4 compression requires Version >= V0_10_0_0")
+	}
+
+	if codec == CompressionGZIP {
+		if level != CompressionLevelDefault {
+			if _, err := gzip.NewWriterLevel(io.Discard, level); err != nil {
+				return ConfigurationError(fmt.Sprintf("%sgzip compression does not work with level %d: %v", prefix, level, err))
+			}
+		}
+	}
+
+	if codec == CompressionZSTD {
+		if !c.Version.IsAtLeast(V2_1_0_0) {
+			return ConfigurationError(prefix + "zstd compression requires Version >= V2_1_0_0")
+		}
+		if level != CompressionLevelDefault &&
+			(level < zstdMinCompressionLevel || level > zstdMaxCompressionLevel) {
+			return ConfigurationError(fmt.Sprintf("%szstd compression does not work with level %d: must be between %d and %d",
+				prefix, level, zstdMinCompressionLevel, zstdMaxCompressionLevel))
+		}
+	}
 
 	return nil
 }
 
+// producerCompression returns the compression codec and level of the messages
+// produced to topic.
+func (c *Config) producerCompression(topic string) (CompressionCodec, int) {
+	codec, level := c.Producer.Compression, c.Producer.CompressionLevel
+	if topicCodec, ok := c.Producer.CompressionByTopic[topic]; ok && topicCodec != codec {
+		codec, level = topicCodec, CompressionLevelDefault
+	}
+	if topicLevel, ok := c.Producer.CompressionLevelByTopic[topic]; ok {
+		level = topicLevel
+	}
+	return codec, level
+}
+
 func (c *Config) getDialer() proxy.Dialer {
 	if c.Net.Proxy.Enable {
 		Logger.Println("using proxy")
@@ -939,3 +1374,20 @@ func validateGroupInstanceId(id string) error {
 	}
 	return nil
 }
+
+func validateGroupProtocol(c *Config) error {
+	return validateGroupProtocolName(c.Consumer.Group.Protocol, c.Version)
+}
+
+func validateGroupProtocolName(protocol string, version KafkaVersion) error {
+	switch protocol {
+	case "", GroupProtocolClassic:
+	case GroupProtocolConsumer:
+		if !version.IsAtLeast(V4_0_0_0) {
+			return ConfigurationError("Consumer.Group.Protocol consumer needs Version >= 4.0")
+		}
+	default:
+		return ConfigurationError(fmt.Sprintf("Consumer.Group.Protocol must be %q or %q", GroupProtocolClassic, GroupProtocolConsumer))
+	}
+	return nil
+}
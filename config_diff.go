@@ -0,0 +1,51 @@
+package sarama
+
+import "sort"
+
+// DiffConfigs computes the minimal set of changes turning the current config
+// entries of a resource, as returned by ClusterAdmin.DescribeConfig, into the
+// desired state. The result maps onto an incremental AlterConfigs request:
+// toSet holds the entries to set (IncrementalAlterConfigsOperationSet) and
+// toReset the sorted names of the entries to delete so that they fall back to
+// their default (IncrementalAlterConfigsOperationDelete).
+//
+// Read-only entries are never changed. Entries at their default value, or
+// inherited from the static broker configuration, are not reset as they are
+// not overridden for the resource. Sensitive entries can't be read back, so a
+// desired sensitive entry is always set and a sensitive entry missing from the
+// desired state is left untouched.
+func DiffConfigs(current []ConfigEntry, desired map[string]string) (toSet map[string]string, toReset []string) {
+	toSet = make(map[string]string)
+	entries := make(map[string]*ConfigEntry, len(current))
+	for i := range current {
+		entries[current[i].Name] = &current[i]
+	}
+
+	for name, value := range desired {
+		entry, ok := entries[name]
+		switch {
+		case !ok:
+			toSet[name] = value
+		case entry.ReadOnly:
+			continue
+		case entry.Sensitive || entry.Value != value:
+			toSet[name] = value
+		}
+	}
+
+	for _, entry := range current {
+		if _, ok := desired[entry.Name]; ok {
+			continue
+		}
+		if entry.ReadOnly || entry.Sensitive || entry.Default {
+			continue
+		}
+		switch entry.Source {
+		case SourceUnknown, SourceTopic, SourceDynamicBroker:
+			toReset = append(toReset, entry.Name)
+		}
+	}
+	sort.Strings(toReset)
+
+	return toSet, toReset
+}
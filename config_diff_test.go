@@ -0,0 +1,102 @@
+//go:build !functional
+
+package sarama
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffConfigs(t *testing.T) {
+	current := []ConfigEntry{
+		{Name: "retention.ms", Value: "604800000", Default: true, Source: SourceDefault},
+		{Name: "cleanup.policy", Value: "delete", Default: true, Source: SourceDefault},
+		{Name: "segment.bytes", Value: "1073741824", Source: SourceStaticBroker},
+		{Name: "max.message.bytes", Value: "2000000", Source: SourceTopic},
+		{Name: "min.insync.replicas", Value: "2", Source: SourceTopic},
+		{Name: "flush.ms", Value: "1000", Source: SourceTopic},
+		{Name: "sasl.jaas.config", Sensitive: true, Source: SourceTopic},
+		{Name: "ssl.key.password", Sensitive: true, Source: SourceTopic},
+		{Name: "broker.id", Value: "1", ReadOnly: true, Source: SourceStaticBroker},
+	}
+
+	testCases := []struct {
+		name    string
+		desired map[string]string
+		toSet   map[string]string
+		toReset []string
+	}{
+		{
+			name: "unchanged",
+			desired: map[string]string{
+				"max.message.bytes":   "2000000",
+				"min.insync.replicas": "2",
+				"flush.ms":            "1000",
+			},
+			toSet: map[string]string{},
+		},
+		{
+			name: "default valued entries are not touched",
+			desired: map[string]string{
+				"retention.ms":        "604800000",
+				"max.message.bytes":   "2000000",
+				"min.insync.replicas": "2",
+				"flush.ms":            "1000",
+			},
+			toSet: map[string]string{},
+		},
+		{
+			name: "changed and new entries are set",
+			desired: map[string]string{
+				"cleanup.policy":      "compact",
+				"max.message.bytes":   "3000000",
+				"min.insync.replicas": "2",
+				"flush.ms":            "1000",
+				"compression.type":    "zstd",
+			},
+			toSet: map[string]string{
+				"cleanup.policy":    "compact",
+				"max.message.bytes": "3000000",
+				"compression.type":  "zstd",
+			},
+		},
+		{
+			name:    "overridden entries missing from the desired state are reset",
+			desired: map[string]string{"min.insync.replicas": "2"},
+			toSet:   map[string]string{},
+			toReset: []string{"flush.ms", "max.message.bytes"},
+		},
+		{
+			name: "sensitive entries are always set and never reset",
+			desired: map[string]string{
+				"max.message.bytes":   "2000000",
+				"min.insync.replicas": "2",
+				"flush.ms":            "1000",
+				"ssl.key.password":    "secret",
+			},
+			toSet: map[string]string{"ssl.key.password": "secret"},
+		},
+		{
+			name: "read-only entries are never changed",
+			desired: map[string]string{
+				"max.message.bytes":   "2000000",
+				"min.insync.replicas": "2",
+				"flush.ms":            "1000",
+				"broker.id":           "2",
+			},
+			toSet: map[string]string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			toSet, toReset := DiffConfigs(current, tc.desired)
+			if !reflect.DeepEqual(toSet, tc.toSet) {
+				t.Errorf("Expected entries to set %v, found %v", tc.toSet, toSet)
+			}
+			if !reflect.DeepEqual(toReset, tc.toReset) {
+				t.Errorf("Expected entries to reset %v, found %v", tc.toReset, toReset)
+			}
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/rcrowley/go-metrics"
 	assert "github.com/stretchr/testify/require"
@@ -21,6 +22,7 @@ func NewTestConfig() *Config {
 	config.ApiVersionsRequest = false
 	config.Consumer.Retry.Backoff = 0
 	config.Producer.Retry.Backoff = 0
+	config.Consumer.Offsets.Retry.Backoff = 0
 	config.Version = MinVersion
 	return config
 }
@@ -74,6 +76,13 @@ func TestNetConfigValidates(t *testing.T) {
 			},
 			"Net.MaxOpenRequests must be > 0",
 		},
+		{
+			"MaxResponseSize",
+			func(cfg *Config) {
+				cfg.Net.MaxResponseSize = -1
+			},
+			"Net.MaxResponseSize must be >= 0",
+		},
 		{
 			"DialTimeout",
 			func(cfg *Config) {
@@ -95,6 +104,28 @@ func TestNetConfigValidates(t *testing.T) {
 			},
 			"Net.WriteTimeout must be > 0",
 		},
+		{
+			"ReconnectBackoff",
+			func(cfg *Config) {
+				cfg.Net.ReconnectBackoff = -1
+			},
+			"Net.ReconnectBackoff must be >= 0",
+		},
+		{
+			"ReconnectBackoffMax",
+			func(cfg *Config) {
+				cfg.Net.ReconnectBackoff = time.Second
+				cfg.Net.ReconnectBackoffMax = time.Millisecond
+			},
+			"Net.ReconnectBackoffMax must be >= Net.ReconnectBackoff",
+		},
+		{
+			"MaxIdleTime",
+			func(cfg *Config) {
+				cfg.Net.MaxIdleTime = -1
+			},
+			"Net.MaxIdleTime must be >= 0",
+		},
 		{
 			"SASL.User",
 			func(cfg *Config) {
@@ -119,7 +150,7 @@ func TestNetConfigValidates(t *testing.T) {
 				cfg.Net.SASL.Mechanism = "AnIncorrectSASLMechanism"
 				cfg.Net.SASL.TokenProvider = &DummyTokenProvider{}
 			},
-			"The SASL mechanism configuration is invalid. Possible values are `OAUTHBEARER`, `PLAIN`, `SCRAM-SHA-256`, `SCRAM-SHA-512` and `GSSAPI`",
+			"The SASL mechanism configuration is invalid. Possible values are `OAUTHBEARER`, `PLAIN`, `SCRAM-SHA-256`, `SCRAM-SHA-512`, `GSSAPI` and `CUSTOM`",
 		},
 		{
 			"SASL.Mechanism.OAUTHBEARER - Missing token provider",
@@ -128,7 +159,7 @@ func TestNetConfigValidates(t *testing.T) {
 				cfg.Net.SASL.Mechanism = SASLTypeOAuth
 				cfg.Net.SASL.TokenProvider = nil
 			},
-			"An AccessTokenProvider instance must be provided to Net.SASL.TokenProvider",
+			"An AccessTokenProvider or AccessTokenProviderContext instance must be provided to Net.SASL.TokenProvider or Net.SASL.TokenProviderContext",
 		},
 		{
 			"SASL.Mechanism SCRAM-SHA-256 - Missing SCRAM client",
@@ -152,6 +183,25 @@ func TestNetConfigValidates(t *testing.T) {
 			},
 			"A SCRAMClientGeneratorFunc function must be provided to Net.SASL.SCRAMClientGeneratorFunc",
 		},
+		{
+			"SASL.Mechanism CUSTOM - Missing authenticator",
+			func(cfg *Config) {
+				cfg.Net.SASL.Enable = true
+				cfg.Net.SASL.Mechanism = SASLTypeCustom
+			},
+			"A CustomAuthenticator function must be provided to Net.SASL.CustomAuthenticator",
+		},
+		{
+			"SASL.Mechanism CUSTOM - SASL v0",
+			func(cfg *Config) {
+				cfg.ApiVersionsRequest = false
+				cfg.Net.SASL.Enable = true
+				cfg.Net.SASL.Mechanism = SASLTypeCustom
+				cfg.Net.SASL.Version = SASLHandshakeV0
+				cfg.Net.SASL.CustomAuthenticator = func() SASLAuthenticator { return nil }
+			},
+			"Net.SASL.Version must be SASLHandshakeV1 when the CUSTOM mechanism is used",
+		},
 		{
 			"SASL.Mechanism GSSAPI (Kerberos) - Using User/Password, Missing password field",
 			func(cfg *Config) {
@@ -323,6 +373,13 @@ func TestAdminConfigValidates(t *testing.T) {
 			},
 			"Admin.Timeout must be > 0",
 		},
+		{
+			"ConnectionPoolSize",
+			func(cfg *Config) {
+				cfg.Admin.ConnectionPoolSize = 0
+			},
+			"Admin.ConnectionPoolSize must be > 0",
+		},
 	}
 
 	for i, test := range tests {
@@ -384,6 +441,13 @@ func TestProducerConfigValidates(t *testing.T) {
 			},
 			"Producer.Flush.Messages must be >= 0",
 		},
+		{
+			"Flush.MaxMessagesPerPartition",
+			func(cfg *Config) {
+				cfg.Producer.Flush.MaxMessagesPerPartition = -1
+			},
+			"Producer.Flush.MaxMessagesPerPartition must be >= 0",
+		},
 		{
 			"Flush.Frequency",
 			func(cfg *Config) {
@@ -420,6 +484,28 @@ func TestProducerConfigValidates(t *testing.T) {
 			},
 			"Producer.Retry.Backoff must be >= 0",
 		},
+		{
+			"Return.SuccessesBufferSize",
+			func(cfg *Config) {
+				cfg.Producer.Return.SuccessesBufferSize = -1
+			},
+			"Producer.Return.SuccessesBufferSize must be >= 0",
+		},
+		{
+			"TimestampType",
+			func(cfg *Config) {
+				cfg.Producer.TimestampType = 2
+			},
+			"Producer.TimestampType must be CreateTime or LogAppendTime",
+		},
+		{
+			"TimestampType Version",
+			func(cfg *Config) {
+				cfg.Producer.TimestampType = LogAppendTime
+				cfg.Version = V0_9_0_0
+			},
+			"Producer.TimestampType LogAppendTime requires Version >= V0_10_0_0",
+		},
 		{
 			"Idempotent Version",
 			func(cfg *Config) {
@@ -489,6 +575,28 @@ func TestConsumerConfigValidates(t *testing.T) {
 			},
 			"Consumer.IsolationLevel must be ReadUncommitted or ReadCommitted",
 		},
+		{
+			"Offsets.Retry.Backoff",
+			func(cfg *Config) {
+				cfg.Consumer.Offsets.Retry.Backoff = -1
+			},
+			"Consumer.Offsets.Retry.Backoff must be >= 0",
+		},
+		{
+			"Offsets.OutOfRangeReset",
+			func(cfg *Config) {
+				cfg.Consumer.Offsets.OutOfRangeReset = OffsetResetPolicy(42)
+			},
+			"Consumer.Offsets.OutOfRangeReset must be a valid OffsetResetPolicy",
+		},
+		{
+			"Offsets.RequireStable Version",
+			func(cfg *Config) {
+				cfg.Consumer.Offsets.RequireStable = true
+				cfg.Version = V2_4_0_0
+			},
+			"Consumer.Offsets.RequireStable requires Version >= V2_5_0_0",
+		},
 	}
 
 	for i, test := range tests {
@@ -528,6 +636,76 @@ func TestZstdConfigValidation(t *testing.T) {
 	if err := config.Validate(); err != nil {
 		t.Error("Expected zstd to work, got ", err)
 	}
+	config.Producer.CompressionLevel = 23
+	err = config.Validate()
+	if !errors.As(err, &target) || string(target) != "zstd compression does not work with level 23: must be between 1 and 22" {
+		t.Error("Expected invalid zstd level error, got ", err)
+	}
+	config.Producer.CompressionLevel = 1
+	if err := config.Validate(); err != nil {
+		t.Error("Expected zstd level 1 to work, got ", err)
+	}
+}
+
+func TestZstdDictionaryConfigValidation(t *testing.T) {
+	config := NewTestConfig()
+	config.Version = V2_1_0_0
+	config.Producer.ZstdDictionary = newTestZstdDictionary(t)
+	err := config.Validate()
+	var target ConfigurationError
+	if !errors.As(err, &target) || string(target) != "Producer.ZstdDictionary requires zstd compression" {
+		t.Error("Expected zstd dictionary without zstd compression error, got ", err)
+	}
+
+	config.Producer.CompressionByTopic = map[string]CompressionCodec{"json": CompressionZSTD}
+	if err := config.Validate(); err != nil {
+		t.Error("Expected zstd dictionary to work with a zstd topic, got ", err)
+	}
+
+	config.Producer.CompressionByTopic = nil
+	config.Producer.Compression = CompressionZSTD
+	if err := config.Validate(); err != nil {
+		t.Error("Expected zstd dictionary to work, got ", err)
+	}
+
+	config.Producer.ZstdDictionary = []byte("not a dictionary")
+	err = config.Validate()
+	if !errors.As(err, &target) || !strings.HasPrefix(string(target), "Producer.ZstdDictionary is not a valid zstd dictionary") {
+		t.Error("Expected invalid zstd dictionary error, got ", err)
+	}
+}
+
+func TestCompressionByTopicConfigValidation(t *testing.T) {
+	config := NewTestConfig()
+	config.Producer.CompressionByTopic = map[string]CompressionCodec{"json": CompressionZSTD}
+	err := config.Validate()
+	var target ConfigurationError
+	if !errors.As(err, &target) || string(target) != `topic "json": zstd compression requires Version >= V2_1_0_0` {
+		t.Error("Expected invalid zstd/kafka version error, got ", err)
+	}
+	config.Version = V2_1_0_0
+	if err := config.Validate(); err != nil {
+		t.Error("Expected zstd to work, got ", err)
+	}
+
+	// the global level does not apply to a topic overriding the codec
+	config.Producer.Compression = CompressionGZIP
+	config.Producer.CompressionLevel = 9
+	if err := config.Validate(); err != nil {
+		t.Error("Expected zstd to use its default level, got ", err)
+	}
+
+	config.Producer.CompressionLevelByTopic = map[string]int{"json": 23}
+	err = config.Validate()
+	if !errors.As(err, &target) || string(target) != `topic "json": zstd compression does not work with level 23: must be between 1 and 22` {
+		t.Error("Expected invalid zstd level error, got ", err)
+	}
+
+	config.Producer.CompressionLevelByTopic = map[string]int{"json": 1, "binary": 10}
+	err = config.Validate()
+	if !errors.As(err, &target) || !strings.HasPrefix(string(target), `topic "binary": gzip compression does not work with level 10`) {
+		t.Error("Expected invalid gzip level error, got ", err)
+	}
 }
 
 func TestValidGroupInstanceId(t *testing.T) {
@@ -574,6 +752,31 @@ func TestGroupInstanceIdAndVersionValidation(t *testing.T) {
 	}
 }
 
+func TestGroupMessagesMarkIntervalValidation(t *testing.T) {
+	config := NewTestConfig()
+	config.Consumer.Group.Messages.MarkInterval = 0
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "Consumer.Group.Messages.MarkInterval must be > 0") {
+		t.Error("Expected invalid mark interval error, got ", err)
+	}
+}
+
+func TestGroupProtocolAndVersionValidation(t *testing.T) {
+	config := NewTestConfig()
+	config.Consumer.Group.Protocol = "eager"
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "Consumer.Group.Protocol must be") {
+		t.Error("Expected invalid group protocol error, got ", err)
+	}
+	config.Consumer.Group.Protocol = GroupProtocolConsumer
+	config.Version = V3_7_0_0
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "Consumer.Group.Protocol consumer needs Version >= 4.0") {
+		t.Error("Expected invalid group protocol version error, got ", err)
+	}
+	config.Version = V4_0_0_0
+	if err := config.Validate(); err != nil {
+		t.Error("Expected consumer group protocol to work, got ", err)
+	}
+}
+
 func TestConsumerGroupStrategyCompatibility(t *testing.T) {
 	config := NewTestConfig()
 	config.Consumer.Group.Rebalance.Strategy = NewBalanceStrategySticky()
@@ -605,3 +808,11 @@ func ExampleConfig_metrics() {
 	// gauge sarama.m2
 	//   value:               2
 }
+
+func TestGroupDeadLetterValidation(t *testing.T) {
+	config := NewTestConfig()
+	config.Consumer.Group.DeadLetter.TopicFunc = func(msg *ConsumerMessage) string { return msg.Topic + ".dlq" }
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "must be set together") {
+		t.Error("Expected invalid dead letter error, got ", err)
+	}
+}
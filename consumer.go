@@ -1,9 +1,11 @@
 package sarama
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"math"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -11,6 +13,9 @@ import (
 	"github.com/rcrowley/go-metrics"
 )
 
+// ErrClosedConsumer is returned when a method is called on a consumer that has been closed.
+var ErrClosedConsumer = errors.New("kafka: tried to use a consumer that was closed")
+
 // ConsumerMessage encapsulates a Kafka message returned by the consumer.
 type ConsumerMessage struct {
 	Headers        []*RecordHeader // only set if kafka is version 0.11+
@@ -21,6 +26,33 @@ type ConsumerMessage struct {
 	Topic      string
 	Partition  int32
 	Offset     int64
+
+	// ProducerID, ProducerEpoch and IsTransactional are copied from the header
+	// of the record batch holding the message. They are only set if kafka is
+	// version 0.11+ (record batch format); legacy message sets leave them zero.
+	// Control records (transaction markers) are never surfaced to consumers.
+	ProducerID      int64
+	ProducerEpoch   int16
+	IsTransactional bool
+}
+
+// IsTombstone returns true when the value of the message is null, which marks
+// the deletion of its key on compacted topics, as opposed to an empty value.
+func (m *ConsumerMessage) IsTombstone() bool {
+	return m.Value == nil
+}
+
+// Header returns the value of the header named key, false if the message has
+// no such header. Kafka allows a header key to be repeated, in which case the
+// value of the last one is returned; all of them are kept in Headers. Keys are
+// compared as raw bytes, so they need not be valid UTF-8.
+func (m *ConsumerMessage) Header(key []byte) ([]byte, bool) {
+	for i := len(m.Headers) - 1; i >= 0; i-- {
+		if h := m.Headers[i]; h != nil && bytes.Equal(h.Key, key) {
+			return h.Value, true
+		}
+	}
+	return nil, false
 }
 
 // ConsumerError is what is provided to the user when an error occurs.
@@ -48,6 +80,78 @@ func (ce ConsumerErrors) Error() string {
 	return fmt.Sprintf("kafka: %d errors while consuming", len(ce))
 }
 
+// OffsetResetPolicy selects what a consumer does when its offset is out of
+// the range held by the broker, typically because the records it pointed to
+// were deleted by retention. See Config.Consumer.Offsets.OutOfRangeReset.
+type OffsetResetPolicy int8
+
+const (
+	// OffsetResetInitial resets a consumer group claim to
+	// Consumer.Offsets.Initial and stops a partition consumer with
+	// ErrOffsetOutOfRange. This is the default.
+	OffsetResetInitial OffsetResetPolicy = iota
+	// OffsetResetEarliest resets to the oldest available offset.
+	OffsetResetEarliest
+	// OffsetResetLatest resets to the newest offset.
+	OffsetResetLatest
+	// OffsetResetError never resets and reports an *OffsetOutOfRangeError
+	// instead, so that data loss is not skipped over silently.
+	OffsetResetError
+)
+
+// resetOffset returns the offset to reset to, initial being the configured
+// Consumer.Offsets.Initial, or false if the offset must not be reset.
+func (p OffsetResetPolicy) resetOffset(initial int64) (int64, bool) {
+	switch p {
+	case OffsetResetEarliest:
+		return OffsetOldest, true
+	case OffsetResetLatest:
+		return OffsetNewest, true
+	case OffsetResetError:
+		return 0, false
+	default:
+		return initial, true
+	}
+}
+
+// OffsetOutOfRangeError is returned when an offset is out of range and
+// Consumer.Offsets.OutOfRangeReset is OffsetResetError. It wraps
+// ErrOffsetOutOfRange.
+type OffsetOutOfRangeError struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+}
+
+func (e *OffsetOutOfRangeError) Error() string {
+	return fmt.Sprintf("kafka: offset %d of %s/%d is out of range", e.Offset, e.Topic, e.Partition)
+}
+
+func (e *OffsetOutOfRangeError) Unwrap() error {
+	return ErrOffsetOutOfRange
+}
+
+// LogTruncationError is returned when a partition consumer detects that the
+// log of its partition was truncated below the offset it consumed up to,
+// typically after an unclean leader election (KIP-320). The records between
+// TruncationOffset and Offset were consumed but are no longer in the log. It
+// wraps ErrLogTruncated.
+type LogTruncationError struct {
+	Topic            string
+	Partition        int32
+	Offset           int64
+	TruncationOffset int64
+}
+
+func (e *LogTruncationError) Error() string {
+	return fmt.Sprintf("kafka: log of %s/%d was truncated to offset %d below consumed offset %d",
+		e.Topic, e.Partition, e.TruncationOffset, e.Offset)
+}
+
+func (e *LogTruncationError) Unwrap() error {
+	return ErrLogTruncated
+}
+
 // Consumer manages PartitionConsumers which process Kafka messages from brokers. You MUST call Close()
 // on a consumer to avoid leaks, it will not be garbage-collected automatically when it passes out of
 // scope.
@@ -61,16 +165,64 @@ type Consumer interface {
 	// This method is the same as Client.Partitions(), and is provided for convenience.
 	Partitions(topic string) ([]int32, error)
 
+	// WatchPartitions returns a channel receiving the sorted list of all
+	// partition IDs for the given topic: the current list right away, then the
+	// full list again whenever partitions are added to the topic, as seen in
+	// the metadata the client refreshes every Metadata.RefreshFrequency. This
+	// lets applications consume new partitions with ConsumePartition as they
+	// appear. If Metadata.RefreshFrequency is zero only the current list is
+	// sent. The channel is closed when the consumer is closed, or by
+	// UnwatchPartitions.
+	WatchPartitions(topic string) (<-chan []int32, error)
+
+	// UnwatchPartitions stops watching the partitions sent to a channel
+	// returned by WatchPartitions, which is then closed. Unknown channels are
+	// ignored.
+	UnwatchPartitions(watch <-chan []int32)
+
 	// ConsumePartition creates a PartitionConsumer on the given topic/partition with
 	// the given offset. It will return an error if this Consumer is already consuming
 	// on the given topic/partition. Offset can be a literal offset, or OffsetNewest
 	// or OffsetOldest
 	ConsumePartition(topic string, partition int32, offset int64) (PartitionConsumer, error)
 
+	// ConsumePartitionWithOptions is ConsumePartition with options overriding
+	// the consumer configuration for this PartitionConsumer only, such as
+	// WithIsolationLevel.
+	ConsumePartitionWithOptions(topic string, partition int32, offset int64, opts ...ConsumeOption) (PartitionConsumer, error)
+
+	// NewPartitionReader creates a PartitionReader on the given
+	// topic/partition starting at the given offset, which can be a literal
+	// offset, OffsetNewest or OffsetOldest. Unlike ConsumePartition, several
+	// readers may read the same partition, also alongside a PartitionConsumer.
+	NewPartitionReader(topic string, partition int32, offset int64) (PartitionReader, error)
+
+	// ConsumeTopics creates a MultiConsumer on all the partitions of the given
+	// topics, including those added later on, starting at
+	// Consumer.Offsets.Initial. It returns an error if this Consumer is already
+	// consuming one of the partitions.
+	ConsumeTopics(topics []string) (MultiConsumer, error)
+
+	// ConsumePartitionFromTime creates a PartitionConsumer on the given
+	// topic/partition starting at the first message with a timestamp at or
+	// after the given time, as looked up with Client.GetOffset. It starts at
+	// OffsetNewest if the time is beyond the end of the log.
+	ConsumePartitionFromTime(topic string, partition int32, t time.Time) (PartitionConsumer, error)
+
 	// HighWaterMarks returns the current high water marks for each topic and partition.
 	// Consistency between partitions is not guaranteed since high water marks are updated separately.
 	HighWaterMarks() map[string]map[int32]int64
 
+	// TopicHighWaterMark returns the sum of the current high water marks of the
+	// partitions of the given topic, i.e. the total end offset of the topic.
+	// Partitions with no active PartitionConsumer are excluded from the sum.
+	TopicHighWaterMark(topic string) int64
+
+	// LagTotal returns the sum of the lags, as reported by PartitionConsumer.Lag,
+	// of the partitions of the given topic. Partitions with no active
+	// PartitionConsumer are excluded from the sum.
+	LagTotal(topic string) int64
+
 	// Close shuts down the consumer. It must be called after all child
 	// PartitionConsumers have already been closed.
 	Close() error
@@ -106,6 +258,15 @@ type consumer struct {
 	client          Client
 	metricRegistry  metrics.Registry
 	lock            sync.Mutex
+	// decompressor is nil unless Consumer.Fetch.DecompressionWorkers is set
+	decompressor *decompressionPool
+	// closing is closed by Close to stop the partition watchers, watchStops
+	// stop a single one for UnwatchPartitions
+	closing    chan none
+	watchers   sync.WaitGroup
+	watchStops map[<-chan []int32]chan none
+	// zstdDict is the dictionary of Producer.ZstdDictionary, if any
+	zstdDict *zstdDictionary
 }
 
 // NewConsumer creates a new consumer using the given broker addresses and configuration.
@@ -138,12 +299,31 @@ func newConsumer(client Client) (Consumer, error) {
 		children:        make(map[string]map[int32]*partitionConsumer),
 		brokerConsumers: make(map[*Broker]*brokerConsumer),
 		metricRegistry:  newCleanupRegistry(client.Config().MetricRegistry),
+		closing:         make(chan none),
+		watchStops:      make(map[<-chan []int32]chan none),
+		zstdDict:        newZstdDictionary(client.Config().Producer.ZstdDictionary),
+	}
+	if workers := c.conf.Consumer.Fetch.DecompressionWorkers; workers > 0 {
+		c.decompressor = newDecompressionPool(workers)
 	}
 
 	return c, nil
 }
 
 func (c *consumer) Close() error {
+	c.lock.Lock()
+	select {
+	case <-c.closing:
+	default:
+		close(c.closing)
+	}
+	c.lock.Unlock()
+	c.watchers.Wait()
+
+	if c.decompressor != nil {
+		c.decompressor.close()
+	}
+	c.zstdDict.close()
 	c.metricRegistry.UnregisterAll()
 	return c.client.Close()
 }
@@ -156,7 +336,117 @@ func (c *consumer) Partitions(topic string) ([]int32, error) {
 	return c.client.Partitions(topic)
 }
 
+func (c *consumer) WatchPartitions(topic string) (<-chan []int32, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	select {
+	case <-c.closing:
+		return nil, ErrClosedConsumer
+	default:
+	}
+
+	partitions, err := c.client.Partitions(topic)
+	if err != nil {
+		return nil, err
+	}
+
+	watch := make(chan []int32, 1)
+	watch <- partitions
+	stop := make(chan none)
+	c.watchStops[watch] = stop
+	c.watchers.Add(1)
+	go withRecover(func() {
+		defer c.watchers.Done()
+		defer close(watch)
+		c.watchPartitions(topic, len(partitions), watch, stop)
+	})
+	return watch, nil
+}
+
+func (c *consumer) UnwatchPartitions(watch <-chan []int32) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if stop, ok := c.watchStops[watch]; ok {
+		delete(c.watchStops, watch)
+		close(stop)
+	}
+}
+
+// watchPartitions sends the partitions of topic to watch every time their
+// count grows beyond count, until the consumer is closed or stop is.
+func (c *consumer) watchPartitions(topic string, count int, watch chan<- []int32, stop <-chan none) {
+	if c.conf.Metadata.RefreshFrequency == time.Duration(0) {
+		select {
+		case <-c.closing:
+		case <-stop:
+		}
+		return
+	}
+
+	ticker := time.NewTicker(c.conf.Metadata.RefreshFrequency)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-c.closing:
+			return
+		case <-stop:
+			return
+		}
+
+		// the client refreshes the metadata of the topic in the background
+		partitions, err := c.client.Partitions(topic)
+		if err != nil {
+			Logger.Printf("consumer/%s failed to get partitions to watch: %s\n", topic, err)
+			continue
+		}
+		if len(partitions) <= count {
+			continue
+		}
+
+		Logger.Printf("consumer/%s partition count changed from %d to %d\n", topic, count, len(partitions))
+		count = len(partitions)
+		select {
+		case watch <- partitions:
+		case <-c.closing:
+			return
+		case <-stop:
+			return
+		}
+	}
+}
+
+// ConsumeOption overrides the consumer configuration for a single
+// PartitionConsumer, see Consumer.ConsumePartitionWithOptions.
+type ConsumeOption func(*partitionConsumer)
+
+// WithIsolationLevel consumes the partition with the given isolation level
+// instead of Consumer.IsolationLevel. ReadCommitted requires Kafka 0.11 or
+// higher. A FetchRequest carries a single isolation level, so while a broker
+// leads partitions consumed with both levels it is sent a request per level.
+// Both are sent concurrently and share Consumer.Fetch.MaxBytes, which halves
+// the bytes fetched per request for these partitions.
+func WithIsolationLevel(level IsolationLevel) ConsumeOption {
+	return func(child *partitionConsumer) {
+		child.isolationLevel = level
+	}
+}
+
+// WithPaused creates the PartitionConsumer paused, as if Pause was called
+// before its first fetch, so that no message is fetched until Resume.
+func WithPaused() ConsumeOption {
+	return func(child *partitionConsumer) {
+		child.paused.Store(true)
+	}
+}
+
 func (c *consumer) ConsumePartition(topic string, partition int32, offset int64) (PartitionConsumer, error) {
+	return c.ConsumePartitionWithOptions(topic, partition, offset)
+}
+
+func (c *consumer) ConsumePartitionWithOptions(topic string, partition int32, offset int64, opts ...ConsumeOption) (PartitionConsumer, error) {
 	child := &partitionConsumer{
 		consumer:             c,
 		conf:                 c.conf,
@@ -164,13 +454,27 @@ func (c *consumer) ConsumePartition(topic string, partition int32, offset int64)
 		partition:            partition,
 		messages:             make(chan *ConsumerMessage, c.conf.ChannelBufferSize),
 		errors:               make(chan *ConsumerError, c.conf.ChannelBufferSize),
+		eofs:                 make(chan int64, c.conf.ChannelBufferSize),
 		feeder:               make(chan *partitionConsumerResponse, 1),
 		leaderEpoch:          invalidLeaderEpoch,
+		lastFetchedEpoch:     invalidLeaderEpoch,
 		preferredReadReplica: invalidPreferredReplicaID,
 		trigger:              make(chan none, 1),
 		dying:                make(chan none),
 		dispatcherStop:       make(chan none),
 		fetchSize:            c.conf.Consumer.Fetch.Default,
+		isolationLevel:       c.conf.Consumer.IsolationLevel,
+		eofOffset:            -1,
+	}
+	for _, opt := range opts {
+		opt(child)
+	}
+
+	switch {
+	case child.isolationLevel != ReadUncommitted && child.isolationLevel != ReadCommitted:
+		return nil, ConfigurationError("isolation level must be ReadUncommitted or ReadCommitted")
+	case child.isolationLevel == ReadCommitted && !c.conf.Version.IsAtLeast(V0_11_0_0):
+		return nil, ConfigurationError("ReadCommitted requires Version >= V0_11_0_0")
 	}
 
 	if err := child.chooseStartingOffset(offset); err != nil {
@@ -179,10 +483,12 @@ func (c *consumer) ConsumePartition(topic string, partition int32, offset int64)
 
 	leader, epoch, err := c.client.LeaderAndEpoch(child.topic, child.partition)
 	if err != nil {
+		child.unregisterLag()
 		return nil, err
 	}
 
 	if err := c.addChild(child); err != nil {
+		child.unregisterLag()
 		return nil, err
 	}
 
@@ -204,6 +510,18 @@ func (c *consumer) ConsumePartition(topic string, partition int32, offset int64)
 	return child, nil
 }
 
+func (c *consumer) ConsumePartitionFromTime(topic string, partition int32, t time.Time) (PartitionConsumer, error) {
+	offset, err := c.client.GetOffset(topic, partition, t.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	if offset < 0 {
+		// no message was written at or after the time yet
+		offset = OffsetNewest
+	}
+	return c.ConsumePartition(topic, partition, offset)
+}
+
 func (c *consumer) HighWaterMarks() map[string]map[int32]int64 {
 	c.lock.Lock()
 	defer c.lock.Unlock()
@@ -220,6 +538,28 @@ func (c *consumer) HighWaterMarks() map[string]map[int32]int64 {
 	return hwms
 }
 
+func (c *consumer) TopicHighWaterMark(topic string) int64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var hwm int64
+	for _, pc := range c.children[topic] {
+		hwm += pc.HighWaterMarkOffset()
+	}
+	return hwm
+}
+
+func (c *consumer) LagTotal(topic string) int64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var lag int64
+	for _, pc := range c.children[topic] {
+		lag += pc.Lag()
+	}
+	return lag
+}
+
 func (c *consumer) addChild(child *partitionConsumer) error {
 	c.lock.Lock()
 	defer c.lock.Unlock()
@@ -380,13 +720,35 @@ type PartitionConsumer interface {
 	// Consumer.Return.Errors setting to true, and read from this channel.
 	Errors() <-chan *ConsumerError
 
+	// EOFs returns a read channel of the offsets at which the PartitionConsumer
+	// caught up with the end of the partition, if enabled with
+	// Consumer.Return.EOF. An offset is sent when a fetch returns no new
+	// records and the next offset to consume equals the high water mark, once
+	// per catch-up: the signal is re-armed when new records arrive. Like
+	// librdkafka's `enable.partition.eof`, this lets applications tailing a
+	// partition know when they have consumed all its existing records. The
+	// channel does not need to be drained: when it is full, its oldest offset
+	// is dropped in favor of the latest.
+	EOFs() <-chan int64
+
 	// HighWaterMarkOffset returns the high water mark offset of the partition,
 	// i.e. the offset that will be used for the next message that will be produced.
 	// You can use this to determine how far behind the processing is.
 	HighWaterMarkOffset() int64
 
+	// Lag returns the number of messages between the next offset this
+	// PartitionConsumer will request and the high water mark offset, as observed
+	// in the most recent fetch response. On compacted topics, or when offsets are
+	// consumed by transaction markers, the value is approximate since not every
+	// offset in the range corresponds to a message. The same value is exposed as
+	// the consumer-lag gauge in the metric registry.
+	Lag() int64
+
 	// Pause suspends fetching from this partition. Future calls to the broker will not return
 	// any records from these partition until it have been resumed using Resume().
+	// The partition is left out of the fetch requests while paused, but the partition
+	// consumer and its offset are kept, so that it resumes where it stopped.
+	// Use Consumer.Pause to pause several partitions at once.
 	// Note that this method does not affect partition subscription.
 	// In particular, it does not cause a group rebalance when automatic assignment is used.
 	Pause()
@@ -427,6 +789,7 @@ func (s *brokerSubscription) release() {
 
 type partitionConsumer struct {
 	highWaterMarkOffset atomic.Int64 // must be at the top of the struct because https://golang.org/pkg/sync/atomic/#pkg-note-BUG
+	lag                 atomic.Int64
 
 	consumer           *consumer
 	conf               *Config
@@ -434,9 +797,11 @@ type partitionConsumer struct {
 	brokerSubscription *brokerSubscription
 	messages           chan *ConsumerMessage
 	errors             chan *ConsumerError
+	eofs               chan int64
 	feeder             chan *partitionConsumerResponse
 
 	leaderEpoch                int32
+	lastFetchedEpoch           int32 // leader epoch of the last consumed record batch
 	preferredReadReplica       int32
 	preferredReadReplicaExpiry time.Time
 
@@ -448,8 +813,11 @@ type partitionConsumer struct {
 	partition          int32
 	responseResult     error
 	fetchSize          int32
+	isolationLevel     IsolationLevel
 	offset             int64
+	eofOffset          int64 // offset of the last EOF sent, -1 if none
 	retries            atomic.Int32
+	resetOffset        atomic.Int64 // OffsetOldest or OffsetNewest to reset to on the next dispatch, 0 otherwise
 
 	paused atomic.Bool // accessed atomically, 0 = not paused, 1 = paused
 }
@@ -642,6 +1010,14 @@ func (child *partitionConsumer) dispatch() error {
 		return err
 	}
 
+	if offset := child.resetOffset.Swap(0); offset != 0 {
+		if err := child.chooseStartingOffset(offset); err != nil {
+			child.resetOffset.Store(offset)
+			return err
+		}
+		Logger.Printf("consumer/%s/%d reset out of range offset to %d\n", child.topic, child.partition, child.offset)
+	}
+
 	broker, epoch, err := child.preferredBroker()
 	if err != nil {
 		return err
@@ -682,6 +1058,10 @@ func (child *partitionConsumer) chooseStartingOffset(offset int64) error {
 	default:
 		return ErrOffsetOutOfRange
 	}
+	// the epoch of the records before the new offset is unknown
+	child.lastFetchedEpoch = invalidLeaderEpoch
+
+	child.updateLag(newestOffset)
 
 	return nil
 }
@@ -694,6 +1074,10 @@ func (child *partitionConsumer) Errors() <-chan *ConsumerError {
 	return child.errors
 }
 
+func (child *partitionConsumer) EOFs() <-chan int64 {
+	return child.eofs
+}
+
 func (child *partitionConsumer) AsyncClose() {
 	// this tells the current broker to abandon this child and lets the
 	// dispatcher shut itself down, which eventually closes messages and errors
@@ -720,6 +1104,40 @@ func (child *partitionConsumer) HighWaterMarkOffset() int64 {
 	return child.highWaterMarkOffset.Load()
 }
 
+func (child *partitionConsumer) Lag() int64 {
+	return child.lag.Load()
+}
+
+// updateLag records the distance between the given high water mark and the
+// next offset to be fetched, both of which must come from the same response.
+// Invalid (negative) high water marks, as sent alongside some errors, are ignored.
+func (child *partitionConsumer) updateLag(highWaterMarkOffset int64) {
+	if highWaterMarkOffset < 0 {
+		return
+	}
+	lag := highWaterMarkOffset - child.offset
+	if lag < 0 {
+		lag = 0
+	}
+	child.lag.Store(lag)
+
+	if child.consumer != nil && child.consumer.metricRegistry != nil {
+		metrics.GetOrRegisterGauge(child.lagMetricName(), child.consumer.metricRegistry).Update(lag)
+	}
+}
+
+// unregisterLag removes the consumer-lag gauge of the partition once it is no
+// longer being consumed.
+func (child *partitionConsumer) unregisterLag() {
+	if child.consumer != nil && child.consumer.metricRegistry != nil {
+		child.consumer.metricRegistry.Unregister(child.lagMetricName())
+	}
+}
+
+func (child *partitionConsumer) lagMetricName() string {
+	return getMetricNameForTopic("consumer-lag", child.topic) + "-partition-" + strconv.FormatInt(int64(child.partition), 10)
+}
+
 func (child *partitionConsumer) responseFeeder() {
 	var msgs []*ConsumerMessage
 	expiryTicker := time.NewTicker(child.conf.Consumer.MaxProcessingTime)
@@ -778,8 +1196,32 @@ feederLoop:
 	}
 
 	expiryTicker.Stop()
+	child.unregisterLag()
 	close(child.messages)
 	close(child.errors)
+	close(child.eofs)
+}
+
+// maybeSendEOF sends the current offset on the EOFs channel if it reached the
+// given high water mark and it was not sent already.
+func (child *partitionConsumer) maybeSendEOF(highWaterMarkOffset int64) {
+	if !child.conf.Consumer.Return.EOF || child.offset != highWaterMarkOffset || child.offset == child.eofOffset {
+		return
+	}
+	child.eofOffset = child.offset
+	for {
+		select {
+		case child.eofs <- child.offset:
+			return
+		default:
+		}
+		// the channel is full, make room for the latest offset so that
+		// consuming never waits for EOFs to be read
+		select {
+		case <-child.eofs:
+		default:
+		}
+	}
 }
 
 func (child *partitionConsumer) parseMessages(msgSet *MessageSet) ([]*ConsumerMessage, error) {
@@ -836,15 +1278,78 @@ func (child *partitionConsumer) parseRecords(batch *RecordBatch) ([]*ConsumerMes
 			Offset:    offset,
 			Timestamp: timestamp,
 			Headers:   rec.Headers,
+
+			ProducerID:      batch.ProducerID,
+			ProducerEpoch:   batch.ProducerEpoch,
+			IsTransactional: batch.IsTransactional,
 		})
 		child.offset = offset + 1
 	}
 	if len(messages) == 0 {
 		child.offset++
 	}
+	child.lastFetchedEpoch = batch.PartitionLeaderEpoch
 	return messages, nil
 }
 
+// updateFetchMetrics records the size, the number of records per batch and
+// the decompression ratio of a decoded fetch response block, both globally and
+// for the consumed topic.
+func (child *partitionConsumer) updateFetchMetrics(block *FetchResponseBlock) {
+	if child.consumer == nil || child.consumer.metricRegistry == nil {
+		return
+	}
+	registry := child.consumer.metricRegistry
+
+	responseBytes := int64(block.recordsSize)
+	getOrRegisterHistogram("fetch-response-bytes", registry).Update(responseBytes)
+	getOrRegisterTopicHistogram("fetch-response-bytes", child.topic, registry).Update(responseBytes)
+
+	recordsPerBatchMetric := getOrRegisterHistogram("fetch-records-per-batch", registry)
+	topicRecordsPerBatchMetric := getOrRegisterTopicHistogram("fetch-records-per-batch", child.topic, registry)
+	decompressionRatioMetric := getOrRegisterHistogram("decompression-ratio", registry)
+	topicDecompressionRatioMetric := getOrRegisterTopicHistogram("decompression-ratio", child.topic, registry)
+	updateRatio := func(uncompressed, compressed int) {
+		if compressed <= 0 {
+			return
+		}
+		ratio := int64(float64(uncompressed) / float64(compressed) * 100)
+		decompressionRatioMetric.Update(ratio)
+		topicDecompressionRatioMetric.Update(ratio)
+	}
+
+	for _, records := range block.RecordsSet {
+		switch records.recordsType {
+		case legacyRecords:
+			if records.MsgSet == nil {
+				continue
+			}
+			for _, msgBlock := range records.MsgSet.Messages {
+				if msgBlock.Msg == nil {
+					continue
+				}
+				n := int64(len(msgBlock.Messages()))
+				recordsPerBatchMetric.Update(n)
+				topicRecordsPerBatchMetric.Update(n)
+				if msgBlock.Msg.Codec != CompressionNone {
+					updateRatio(len(msgBlock.Msg.Value), msgBlock.Msg.compressedSize)
+				}
+			}
+		case defaultRecords:
+			batch := records.RecordBatch
+			if batch == nil {
+				continue
+			}
+			n := int64(len(batch.Records))
+			recordsPerBatchMetric.Update(n)
+			topicRecordsPerBatchMetric.Update(n)
+			if batch.Codec != CompressionNone {
+				updateRatio(batch.recordsLen, batch.compressedRecordsLen)
+			}
+		}
+	}
+}
+
 func (child *partitionConsumer) parseResponse(response *FetchResponse) ([]*ConsumerMessage, error) {
 	var consumerBatchSizeMetric metrics.Histogram
 	if child.consumer != nil && child.consumer.metricRegistry != nil {
@@ -864,10 +1369,23 @@ func (child *partitionConsumer) parseResponse(response *FetchResponse) ([]*Consu
 		return nil, ErrIncompleteResponse
 	}
 
+	// keep the lag current on every path, including errors and empty blocks
+	defer func() { child.updateLag(block.HighWaterMarkOffset) }()
+
 	if !errors.Is(block.Err, ErrNoError) {
 		return nil, block.Err
 	}
 
+	if block.DivergingEpoch != nil {
+		return nil, child.handleTruncation(block.DivergingEpoch)
+	}
+
+	if child.consumer != nil && child.consumer.decompressor != nil {
+		if err := child.consumer.decompressor.decodeRecords(block.RecordsSet); err != nil {
+			return nil, err
+		}
+	}
+
 	nRecs, err := block.numRecords()
 	if err != nil {
 		return nil, err
@@ -876,8 +1394,9 @@ func (child *partitionConsumer) parseResponse(response *FetchResponse) ([]*Consu
 	if consumerBatchSizeMetric != nil {
 		consumerBatchSizeMetric.Update(int64(nRecs))
 	}
+	child.updateFetchMetrics(block)
 
-	if block.PreferredReadReplica != invalidPreferredReplicaID {
+	if child.conf.Consumer.PreferClosestReplica && block.PreferredReadReplica != invalidPreferredReplicaID {
 		child.preferredReadReplica = block.PreferredReadReplica
 		child.preferredReadReplicaExpiry = time.Now().Add(child.preferredReadReplicaLease())
 	}
@@ -915,6 +1434,7 @@ func (child *partitionConsumer) parseResponse(response *FetchResponse) ([]*Consu
 			Logger.Printf("consumer/broker/%d received batch with zero records but high watermark was not reached, topic %s, partition %d, next offset %d\n", child.broker.broker.ID(), child.topic, child.partition, *block.recordsNextOffset)
 			child.offset = *block.recordsNextOffset
 		}
+		child.maybeSendEOF(block.HighWaterMarkOffset)
 
 		return nil, nil
 	}
@@ -965,7 +1485,7 @@ func (child *partitionConsumer) parseResponse(response *FetchResponse) ([]*Consu
 				// I don't know why there is this continue in case of error to begin with
 				// Safe bet is to ignore control messages if ReadUncommitted
 				// and block on them in case of error and ReadCommitted
-				if child.conf.Consumer.IsolationLevel == ReadCommitted {
+				if child.isolationLevel == ReadCommitted {
 					return nil, err
 				}
 				continue
@@ -983,9 +1503,14 @@ func (child *partitionConsumer) parseResponse(response *FetchResponse) ([]*Consu
 			}
 
 			// filter aborted transactions
-			if child.conf.Consumer.IsolationLevel == ReadCommitted {
+			if child.isolationLevel == ReadCommitted {
 				_, isAborted := abortedProducerIDs[records.RecordBatch.ProducerID]
 				if records.RecordBatch.IsTransactional && isAborted {
+					if onAborted := child.conf.Consumer.OnAbortedMessage; onAborted != nil {
+						for _, msg := range recordBatchMessages {
+							onAborted(child.topic, child.partition, msg.Offset)
+						}
+					}
 					continue
 				}
 			}
@@ -999,6 +1524,41 @@ func (child *partitionConsumer) parseResponse(response *FetchResponse) ([]*Consu
 	return messages, nil
 }
 
+// handleTruncation is called when the leader reports that the log diverged
+// from the records consumed so far. It queries the end offset of the last
+// fetched epoch to find the truncation point, falling back to the one reported
+// in the fetch response, and resumes from there if
+// Consumer.Group.ResetOnTruncation is set.
+func (child *partitionConsumer) handleTruncation(diverging *FetchEpochEndOffset) error {
+	truncationOffset := diverging.EndOffset
+	epoch := diverging.Epoch
+
+	request := NewOffsetForLeaderEpochRequest(child.conf.Version)
+	request.AddPartition(child.topic, child.partition, child.leaderEpoch, child.lastFetchedEpoch)
+	response, err := child.broker.broker.OffsetForLeaderEpoch(request)
+	if err != nil {
+		Logger.Printf("consumer/%s/%d failed to look up the end offset of leader epoch %d: %v\n",
+			child.topic, child.partition, child.lastFetchedEpoch, err)
+	} else if block := response.GetBlock(child.topic, child.partition); block != nil &&
+		errors.Is(block.Err, ErrNoError) && block.EndOffset >= 0 {
+		truncationOffset = block.EndOffset
+		epoch = block.LeaderEpoch
+	}
+	truncationOffset = min(truncationOffset, child.offset)
+
+	err = &LogTruncationError{
+		Topic:            child.topic,
+		Partition:        child.partition,
+		Offset:           child.offset,
+		TruncationOffset: truncationOffset,
+	}
+	if child.conf.Consumer.Group.ResetOnTruncation {
+		child.offset = truncationOffset
+		child.lastFetchedEpoch = epoch
+	}
+	return err
+}
+
 func (child *partitionConsumer) interceptors(msg *ConsumerMessage) {
 	for _, interceptor := range child.conf.Consumer.Interceptors {
 		msg.safelyApplyInterceptor(interceptor)
@@ -1120,7 +1680,7 @@ func (bc *brokerConsumer) subscriptionConsumer() {
 			continue
 		}
 
-		response, err := bc.fetchNewMessages()
+		responses, err := bc.fetchNewMessages()
 		if err != nil {
 			Logger.Printf("consumer/broker/%d disconnecting due to error processing FetchRequest: %s\n", bc.broker.ID(), err)
 			bc.abort(err)
@@ -1129,7 +1689,7 @@ func (bc *brokerConsumer) subscriptionConsumer() {
 
 		// if there isn't response, it means that not fetch was made
 		// so we don't need to handle any response
-		if response == nil {
+		if len(responses) == 0 {
 			time.Sleep(partitionConsumersBatchTimeout)
 			continue
 		}
@@ -1146,12 +1706,8 @@ func (bc *brokerConsumer) subscriptionConsumer() {
 			default:
 			}
 
-			if _, ok := response.Blocks[child.topic]; !ok {
-				bc.acks.Done()
-				continue
-			}
-
-			if _, ok := response.Blocks[child.topic][child.partition]; !ok {
+			response := fetchResponseFor(responses, child.topic, child.partition)
+			if response == nil {
 				bc.acks.Done()
 				continue
 			}
@@ -1226,6 +1782,19 @@ func (bc *brokerConsumer) handleResponses() {
 			// release it here
 			delete(bc.subscriptions, child)
 		} else if errors.Is(result, ErrOffsetOutOfRange) {
+			switch policy := child.conf.Consumer.Offsets.OutOfRangeReset; policy {
+			case OffsetResetEarliest, OffsetResetLatest:
+				// reset the offset when redispatching instead of shutting down
+				offset, _ := policy.resetOffset(child.conf.Consumer.Offsets.Initial)
+				Logger.Printf("consumer/broker/%d abandoned subscription to %s/%d because offset %d is out of range\n",
+					bc.broker.ID(), child.topic, child.partition, child.offset)
+				child.resetOffset.Store(offset)
+				child.triggerRedispatch()
+				bc.releaseSubscription(child)
+				continue
+			case OffsetResetError:
+				result = &OffsetOutOfRangeError{Topic: child.topic, Partition: child.partition, Offset: child.offset}
+			}
 			// there's no point in retrying this it will just fail the same way again
 			// shut it down and force the user to choose what to do
 			child.sendError(result)
@@ -1233,6 +1802,17 @@ func (bc *brokerConsumer) handleResponses() {
 			child.stopDispatcher()
 			child.AsyncClose()
 			bc.releaseSubscription(child)
+		} else if errors.Is(result, ErrLogTruncated) {
+			child.sendError(result)
+			if child.conf.Consumer.Group.ResetOnTruncation {
+				// the offset was already reset, keep consuming from there
+				Logger.Printf("consumer/%s/%d resetting because %s\n", child.topic, child.partition, result)
+				continue
+			}
+			Logger.Printf("consumer/%s/%d shutting down because %s\n", child.topic, child.partition, result)
+			child.stopDispatcher()
+			child.AsyncClose()
+			bc.releaseSubscription(child)
 		} else if errors.Is(result, ErrUnknownTopicOrPartition) ||
 			errors.Is(result, ErrNotLeaderForPartition) ||
 			errors.Is(result, ErrLeaderNotAvailable) ||
@@ -1284,43 +1864,127 @@ func (bc *brokerConsumer) abort(err error) {
 	}
 }
 
-// fetchNewMessages can be nil if no fetch is made, it can occur when
-// all partitions are paused
-func (bc *brokerConsumer) fetchNewMessages() (*FetchResponse, error) {
+// fetchResponseFor returns the response holding the block of the given
+// partition, or nil if none does.
+func fetchResponseFor(responses []*FetchResponse, topic string, partition int32) *FetchResponse {
+	for _, response := range responses {
+		if _, ok := response.Blocks[topic][partition]; ok {
+			return response
+		}
+	}
+	return nil
+}
+
+// fetchNewMessages sends a FetchRequest per isolation level used by the
+// subscriptions, as a request has a single isolation level. The responses can
+// be empty if no fetch is made, it can occur when all partitions are paused
+func (bc *brokerConsumer) fetchNewMessages() ([]*FetchResponse, error) {
+	var requests [ReadCommitted + 1]*FetchRequest
+	for child := range bc.subscriptions {
+		select {
+		case <-child.dying:
+			bc.releaseSubscription(child)
+			child.stopDispatcher()
+			continue
+		default:
+		}
+
+		if !child.IsPaused() {
+			if requests[child.isolationLevel] == nil {
+				requests[child.isolationLevel] = newFetchRequest(bc.consumer.conf, child.isolationLevel)
+			}
+			requests[child.isolationLevel].AddBlock(child.topic, child.partition, child.offset, child.fetchSize, child.leaderEpoch)
+			requests[child.isolationLevel].setLastFetchedEpoch(child.topic, child.partition, child.lastFetchedEpoch)
+		}
+	}
+
+	var pending []*FetchRequest
+	for _, request := range requests {
+		// avoid to fetch when there is no block
+		if request != nil {
+			pending = append(pending, request)
+		}
+	}
+	if len(pending) == 1 {
+		response, err := bc.fetch(pending[0])
+		if err != nil {
+			return nil, err
+		}
+		return []*FetchResponse{response}, nil
+	}
+
+	// the requests of each isolation level are sent concurrently, so that
+	// their MaxWaitTime overlap, and share Fetch.MaxBytes
+	responses := make([]*FetchResponse, len(pending))
+	errs := make([]error, len(pending))
+	var wg sync.WaitGroup
+	for i, request := range pending {
+		request.MaxBytes /= int32(len(pending))
+		wg.Add(1)
+		go withRecover(func() {
+			defer wg.Done()
+			responses[i], errs[i] = bc.fetch(request)
+		})
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return responses, nil
+}
+
+// fetch sends a single FetchRequest to the broker.
+func (bc *brokerConsumer) fetch(request *FetchRequest) (*FetchResponse, error) {
+	response := &FetchResponse{zstdDict: bc.consumer.zstdDict}
+	if bc.consumer.decompressor != nil {
+		// the partition consumers decompress the records on the pool
+		response.deferDecompression = true
+	}
+	if _, err := bc.broker.fetch(request, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// newFetchRequest returns an empty FetchRequest of the highest version
+// supported by conf.Version.
+func newFetchRequest(conf *Config, isolation IsolationLevel) *FetchRequest {
 	request := &FetchRequest{
-		MinBytes:    bc.consumer.conf.Consumer.Fetch.Min,
-		MaxWaitTime: int32(bc.consumer.conf.Consumer.MaxWaitTime / time.Millisecond),
+		MinBytes:    conf.Consumer.Fetch.Min,
+		MaxWaitTime: int32(conf.Consumer.MaxWaitTime / time.Millisecond),
 	}
 	// Version 1 is the same as version 0.
-	if bc.consumer.conf.Version.IsAtLeast(V0_9_0_0) {
+	if conf.Version.IsAtLeast(V0_9_0_0) {
 		request.Version = 1
 	}
 	// Starting in Version 2, the requestor must be able to handle Kafka Log
 	// Message format version 1.
-	if bc.consumer.conf.Version.IsAtLeast(V0_10_0_0) {
+	if conf.Version.IsAtLeast(V0_10_0_0) {
 		request.Version = 2
 	}
 	// Version 3 adds MaxBytes.  Starting in version 3, the partition ordering in
 	// the request is now relevant.  Partitions will be processed in the order
 	// they appear in the request.
-	if bc.consumer.conf.Version.IsAtLeast(V0_10_1_0) {
+	if conf.Version.IsAtLeast(V0_10_1_0) {
 		request.Version = 3
-		request.MaxBytes = bc.consumer.conf.Consumer.Fetch.MaxBytes
+		request.MaxBytes = conf.Consumer.Fetch.MaxBytes
 	}
 	// Version 4 adds IsolationLevel.  Starting in version 4, the reqestor must be
 	// able to handle Kafka log message format version 2.
 	// Version 5 adds LogStartOffset to indicate the earliest available offset of
 	// partition data that can be consumed.
-	if bc.consumer.conf.Version.IsAtLeast(V0_11_0_0) {
+	if conf.Version.IsAtLeast(V0_11_0_0) {
 		request.Version = 5
-		request.Isolation = bc.consumer.conf.Consumer.IsolationLevel
+		request.Isolation = isolation
 	}
 	// Version 6 is the same as version 5.
-	if bc.consumer.conf.Version.IsAtLeast(V1_0_0_0) {
+	if conf.Version.IsAtLeast(V1_0_0_0) {
 		request.Version = 6
 	}
 	// Version 7 adds incremental fetch request support.
-	if bc.consumer.conf.Version.IsAtLeast(V1_1_0_0) {
+	if conf.Version.IsAtLeast(V1_1_0_0) {
 		request.Version = 7
 		// We do not currently implement KIP-227 FetchSessions. Setting the id to 0
 		// and the epoch to -1 tells the broker not to generate as session ID we're going
@@ -1329,41 +1993,28 @@ func (bc *brokerConsumer) fetchNewMessages() (*FetchResponse, error) {
 		request.SessionEpoch = -1
 	}
 	// Version 8 is the same as version 7.
-	if bc.consumer.conf.Version.IsAtLeast(V2_0_0_0) {
+	if conf.Version.IsAtLeast(V2_0_0_0) {
 		request.Version = 8
 	}
 	// Version 9 adds CurrentLeaderEpoch, as described in KIP-320.
 	// Version 10 indicates that we can use the ZStd compression algorithm, as
 	// described in KIP-110.
-	if bc.consumer.conf.Version.IsAtLeast(V2_1_0_0) {
+	if conf.Version.IsAtLeast(V2_1_0_0) {
 		request.Version = 10
 	}
 	// Version 11 adds RackID for KIP-392 fetch from closest replica
-	if bc.consumer.conf.Version.IsAtLeast(V2_3_0_0) {
+	if conf.Version.IsAtLeast(V2_3_0_0) {
 		request.Version = 11
-		request.RackID = bc.consumer.conf.RackID
-	}
-
-	for child := range bc.subscriptions {
-		select {
-		case <-child.dying:
-			bc.releaseSubscription(child)
-			child.stopDispatcher()
-			continue
-		default:
-		}
-
-		if !child.IsPaused() {
-			request.AddBlock(child.topic, child.partition, child.offset, child.fetchSize, child.leaderEpoch)
+		if conf.Consumer.PreferClosestReplica {
+			request.RackID = conf.RackID
 		}
 	}
-
-	// avoid to fetch when there is no block
-	if len(request.blocks) == 0 {
-		return nil, nil
+	// Version 12 adds flexible versions and LastFetchedEpoch for KIP-320
+	// log truncation detection.
+	if conf.Version.IsAtLeast(V2_7_0_0) {
+		request.Version = 12
 	}
-
-	return bc.broker.Fetch(request)
+	return request
 }
 
 func (bc *brokerConsumer) stopConsuming() {
@@ -4,9 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
 	"slices"
 	"sort"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rcrowley/go-metrics"
@@ -29,6 +32,29 @@ var ErrSessionConsumeClaimExited = errors.New("kafka: ConsumeClaim goroutine exi
 // unreachable after retries).
 var ErrSessionHeartbeatFailed = errors.New("kafka: heartbeat loop failed")
 
+// ErrSessionAssignmentChanged is set as the cancellation cause of a consumer group session
+// context when the group coordinator sends a new assignment to a member using the
+// GroupProtocolConsumer rebalance protocol.
+var ErrSessionAssignmentChanged = errors.New("kafka: assignment changed by the group coordinator")
+
+// ErrNoDeadLetterProducer is returned by ConsumerGroupSession.MarkError when
+// Consumer.Group.DeadLetter.Producer is not set.
+var ErrNoDeadLetterProducer = errors.New("kafka: no dead letter producer configured in Consumer.Group.DeadLetter")
+
+// ErrClaimBatchStarted is returned by ConsumerGroupClaim.MessagesBatch when it
+// was already called on the claim.
+var ErrClaimBatchStarted = errors.New("kafka: MessagesBatch was already called on the claim")
+
+// Rebalance protocols supported by Config.Consumer.Group.Protocol.
+const (
+	// GroupProtocolClassic is the JoinGroup/SyncGroup protocol, where the group
+	// leader computes the assignment client-side.
+	GroupProtocolClassic = "classic"
+	// GroupProtocolConsumer is the KIP-848 protocol, where the group coordinator
+	// computes the assignment and members only heartbeat.
+	GroupProtocolConsumer = "consumer"
+)
+
 // ConsumerGroup is responsible for dividing up processing of topics and partitions
 // over a collection of processes (the members of the consumer group).
 type ConsumerGroup interface {
@@ -60,6 +86,22 @@ type ConsumerGroup interface {
 	// recreated to get the new claims.
 	Consume(ctx context.Context, topics []string, handler ConsumerGroupHandler) error
 
+	// Messages joins a cluster of consumers for a given list of topics like
+	// Consume, and returns a channel that the messages of all the claimed
+	// partitions are sent to. Consume is called in a loop in the background,
+	// until the context is canceled or the group is closed, which closes the
+	// channel. Errors returned by Consume are reported like the other errors
+	// of the group, see Errors.
+	//
+	// The order of the messages of a partition is preserved, but the messages
+	// of different partitions are interleaved. The last message received from
+	// the channel for each partition is marked as consumed every
+	// Config.Consumer.Group.Messages.MarkInterval and when the partition is
+	// released. A message that is not received yet when its partition is
+	// released by a rebalance is dropped rather than sent, and will be
+	// consumed again by the new owner of the partition.
+	Messages(ctx context.Context, topics []string) (<-chan *ConsumerMessage, error)
+
 	// Errors returns a read channel of errors that occurred during the consumer life-cycle.
 	// By default, errors are logged and not returned over this channel.
 	// If you want to implement any custom error handling, set your config's
@@ -74,21 +116,30 @@ type ConsumerGroup interface {
 	// records from these partitions until they have been resumed using Resume()/ResumeAll().
 	// Note that this method does not affect partition subscription.
 	// In particular, it does not cause a group rebalance when automatic assignment is used.
+	// The pause survives the rebalances assigning a partition to this member again. A partition
+	// assigned to another member is consumed by it regardless, and its pause is forgotten.
 	Pause(partitions map[string][]int32)
 
 	// Resume resumes specified partitions which have been paused with Pause()/PauseAll().
 	// New calls to the broker will return records from these partitions if there are any to be fetched.
 	Resume(partitions map[string][]int32)
 
-	// Pause suspends fetching from all partitions. Future calls to the broker will not return any
+	// PauseAll suspends fetching from all partitions. Future calls to the broker will not return any
 	// records from these partitions until they have been resumed using Resume()/ResumeAll().
 	// Note that this method does not affect partition subscription.
 	// In particular, it does not cause a group rebalance when automatic assignment is used.
+	// The partitions assigned to this member by later rebalances are paused as well.
 	PauseAll()
 
 	// Resume resumes all partitions which have been paused with Pause()/PauseAll().
 	// New calls to the broker will return records from these partitions if there are any to be fetched.
 	ResumeAll()
+
+	// SetGroupProtocol selects the rebalance protocol used from the next call
+	// to Consume on, either GroupProtocolClassic or GroupProtocolConsumer. A
+	// member that joined the group using the other protocol leaves it first, so
+	// that members can be migrated one at a time.
+	SetGroupProtocol(protocol string) error
 }
 
 type consumerGroup struct {
@@ -109,6 +160,33 @@ type consumerGroup struct {
 
 	userData []byte
 
+	// cooperative is set when the strategy chosen by the group uses
+	// RebalanceProtocolCooperative, in which case sessions rejoin the group
+	// for topics when it rebalances instead of ending
+	cooperative bool
+	// topics holds the subscription of the current session
+	topics []string
+
+	// nextProtocol holds the protocol requested through SetGroupProtocol, which
+	// becomes the active protocol at the start of the next Consume call
+	nextProtocol atomic.Value
+
+	// protocol is the rebalance protocol the member currently uses; the fields
+	// below it are only used by GroupProtocolConsumer
+	protocol          string
+	memberEpoch       int32
+	heartbeatInterval time.Duration
+	assignment        map[string][]int32
+	topicNames        map[Uuid]string
+
+	// pauseLock guards the pause state of the partitions, which outlives the
+	// claims so that it is applied again when a rebalance assigns a partition
+	// to the member anew. paused holds the partitions paused or resumed since
+	// the last PauseAll or ResumeAll, pausedAll applies to the others.
+	pauseLock sync.Mutex
+	pausedAll bool
+	paused    map[topicPartition]bool
+
 	metricRegistry metrics.Registry
 }
 
@@ -158,8 +236,15 @@ func newConsumerGroup(groupID string, client Client) (ConsumerGroup, error) {
 		errors:         make(chan error, config.ChannelBufferSize),
 		closed:         make(chan none),
 		userData:       config.Consumer.Group.Member.UserData,
+		protocol:       config.Consumer.Group.Protocol,
+		topicNames:     make(map[Uuid]string),
+		paused:         make(map[topicPartition]bool),
 		metricRegistry: newCleanupRegistry(config.MetricRegistry),
 	}
+	if cg.protocol == "" {
+		cg.protocol = GroupProtocolClassic
+	}
+	cg.nextProtocol.Store(cg.protocol)
 	if config.Consumer.Group.InstanceId != "" && config.Version.IsAtLeast(V2_3_0_0) {
 		cg.groupInstanceId = &config.Consumer.Group.InstanceId
 	}
@@ -216,6 +301,11 @@ func (c *consumerGroup) Consume(ctx context.Context, topics []string, handler Co
 		return fmt.Errorf("no topics provided")
 	}
 
+	// Switch protocols if another one was requested since the previous call
+	if err := c.switchProtocol(); err != nil {
+		return err
+	}
+
 	// Refresh metadata for requested topics
 	if err := c.client.RefreshMetadata(topics...); err != nil {
 		return err
@@ -249,26 +339,183 @@ func (c *consumerGroup) Consume(ctx context.Context, topics []string, handler Co
 	return err
 }
 
+// Messages implements ConsumerGroup.
+func (c *consumerGroup) Messages(ctx context.Context, topics []string) (<-chan *ConsumerMessage, error) {
+	select {
+	case <-c.closed:
+		return nil, ErrClosedConsumerGroup
+	default:
+	}
+
+	if len(topics) == 0 {
+		return nil, fmt.Errorf("no topics provided")
+	}
+
+	messages := make(chan *ConsumerMessage)
+	handler := &messagesHandler{
+		messages:     messages,
+		markInterval: c.config.Consumer.Group.Messages.MarkInterval,
+	}
+	go withRecover(func() {
+		defer close(messages)
+		for {
+			err := c.Consume(ctx, topics, handler)
+			if errors.Is(err, ErrClosedConsumerGroup) {
+				return
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			if err == nil {
+				continue
+			}
+			c.handleError(err, "", -1)
+			select {
+			case <-ctx.Done():
+				return
+			case <-c.closed:
+				return
+			case <-time.After(c.config.Consumer.Group.Rebalance.Retry.Backoff):
+			}
+		}
+	})
+	return messages, nil
+}
+
+// messagesHandler is the ConsumerGroupHandler behind ConsumerGroup.Messages
+// that sends the messages of every claim to a single channel.
+type messagesHandler struct {
+	messages     chan<- *ConsumerMessage
+	markInterval time.Duration
+}
+
+func (h *messagesHandler) Setup(ConsumerGroupSession) error   { return nil }
+func (h *messagesHandler) Cleanup(ConsumerGroupSession) error { return nil }
+
+func (h *messagesHandler) ConsumeClaim(sess ConsumerGroupSession, claim ConsumerGroupClaim) error {
+	ticker := time.NewTicker(h.markInterval)
+	defer ticker.Stop()
+
+	// the last message received from the channel and not marked yet
+	var last *ConsumerMessage
+	defer func() {
+		if last != nil {
+			sess.MarkMessage(last, "")
+		}
+	}()
+
+	for {
+		select {
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			select {
+			case h.messages <- msg:
+				last = msg
+			case <-sess.Context().Done():
+				return nil
+			}
+		case <-ticker.C:
+			if last != nil {
+				sess.MarkMessage(last, "")
+				last = nil
+			}
+		case <-sess.Context().Done():
+			return nil
+		}
+	}
+}
+
 // Pause implements ConsumerGroup.
 func (c *consumerGroup) Pause(partitions map[string][]int32) {
+	c.pauseLock.Lock()
+	defer c.pauseLock.Unlock()
+
+	c.setPaused(partitions, true)
 	c.consumer.Pause(partitions)
 }
 
 // Resume implements ConsumerGroup.
 func (c *consumerGroup) Resume(partitions map[string][]int32) {
+	c.pauseLock.Lock()
+	defer c.pauseLock.Unlock()
+
+	c.setPaused(partitions, false)
 	c.consumer.Resume(partitions)
 }
 
 // PauseAll implements ConsumerGroup.
 func (c *consumerGroup) PauseAll() {
+	c.pauseLock.Lock()
+	defer c.pauseLock.Unlock()
+
+	c.pausedAll = true
+	clear(c.paused)
 	c.consumer.PauseAll()
 }
 
 // ResumeAll implements ConsumerGroup.
 func (c *consumerGroup) ResumeAll() {
+	c.pauseLock.Lock()
+	defer c.pauseLock.Unlock()
+
+	c.pausedAll = false
+	clear(c.paused)
 	c.consumer.ResumeAll()
 }
 
+func (c *consumerGroup) setPaused(partitions map[string][]int32, paused bool) {
+	for topic, partitions := range partitions {
+		for _, partition := range partitions {
+			c.paused[topicPartition{topic, partition}] = paused
+		}
+	}
+}
+
+// isPaused tells whether the partition is paused.
+func (c *consumerGroup) isPaused(topic string, partition int32) bool {
+	c.pauseLock.Lock()
+	defer c.pauseLock.Unlock()
+
+	return c.isPausedLocked(topic, partition)
+}
+
+// c.pauseLock must be held by caller
+func (c *consumerGroup) isPausedLocked(topic string, partition int32) bool {
+	paused, ok := c.paused[topicPartition{topic, partition}]
+	if !ok {
+		paused = c.pausedAll
+	}
+	return paused
+}
+
+// pauseClaim applies the pause state of its partition to the partition
+// consumer of a new claim, in case it changed while the claim was created.
+func (c *consumerGroup) pauseClaim(topic string, partition int32, pc PartitionConsumer) {
+	c.pauseLock.Lock()
+	defer c.pauseLock.Unlock()
+
+	if c.isPausedLocked(topic, partition) {
+		pc.Pause()
+	} else {
+		pc.Resume()
+	}
+}
+
+// retainPaused forgets the pause state of the partitions that are no longer
+// claimed by the member after a rebalance.
+func (c *consumerGroup) retainPaused(claims map[string][]int32) {
+	c.pauseLock.Lock()
+	defer c.pauseLock.Unlock()
+
+	for tp := range c.paused {
+		if !slices.Contains(claims[tp.topic], tp.partition) {
+			delete(c.paused, tp)
+		}
+	}
+}
+
 func (c *consumerGroup) retryNewSession(ctx context.Context, topics []string, handler ConsumerGroupHandler, retries int, refreshCoordinator bool) (*consumerGroupSession, error) {
 	select {
 	case <-ctx.Done():
@@ -295,6 +542,9 @@ func (c *consumerGroup) newSession(ctx context.Context, topics []string, handler
 	if ctx.Err() != nil {
 		return nil, ctx.Err()
 	}
+	if c.protocol == GroupProtocolConsumer {
+		return c.newConsumerProtocolSession(ctx, topics, handler, retries)
+	}
 	coordinator, err := c.client.Coordinator(c.groupID)
 	if err != nil {
 		if retries <= 0 {
@@ -320,7 +570,7 @@ func (c *consumerGroup) newSession(ctx context.Context, topics []string, handler
 	}
 
 	// Join consumer group
-	join, err := c.joinGroupRequest(coordinator, topics)
+	join, err := c.joinGroupRequest(coordinator, topics, nil, defaultGeneration)
 	if consumerGroupJoinTotal != nil {
 		consumerGroupJoinTotal.Inc(1)
 	}
@@ -365,15 +615,9 @@ func (c *consumerGroup) newSession(ctx context.Context, topics []string, handler
 		return nil, join.Err
 	}
 
-	var strategy BalanceStrategy
-	var ok bool
-	if strategy = c.config.Consumer.Group.Rebalance.Strategy; strategy == nil {
-		strategy, ok = c.findStrategy(join.GroupProtocol, c.config.Consumer.Group.Rebalance.GroupStrategies)
-		if !ok {
-			// this case shouldn't happen in practice, since the leader will choose the protocol
-			// that all the members support
-			return nil, fmt.Errorf("unable to find selected strategy: %s", join.GroupProtocol)
-		}
+	strategy, err := c.groupStrategy(join.GroupProtocol)
+	if err != nil {
+		return nil, err
 	}
 
 	// Prepare distribution plan if we joined as the leader
@@ -433,28 +677,15 @@ func (c *consumerGroup) newSession(ctx context.Context, topics []string, handler
 	}
 
 	// Retrieve and sort claims
-	var claims map[string][]int32
-	if len(syncGroupResponse.MemberAssignment) > 0 {
-		members, err := syncGroupResponse.GetMemberAssignment()
-		if err != nil {
-			return nil, err
-		}
-		claims = members.Topics
-
-		// in the case of stateful balance strategies, hold on to the returned
-		// assignment metadata, otherwise, reset the statically defined consumer
-		// group metadata
-		if members.UserData != nil {
-			c.userData = members.UserData
-		} else {
-			c.userData = c.config.Consumer.Group.Member.UserData
-		}
-
-		for _, partitions := range claims {
-			sort.Sort(int32Slice(partitions))
-		}
+	claims, err := c.memberClaims(syncGroupResponse)
+	if err != nil {
+		return nil, err
 	}
 
+	// sessions of cooperative strategies survive rebalances
+	c.cooperative = rebalanceProtocol(strategy) == RebalanceProtocolCooperative
+	c.topics = topics
+
 	session, err := newConsumerGroupSession(ctx, c, claims, join.MemberId, join.GenerationId, handler)
 	if err != nil {
 		return nil, err
@@ -468,7 +699,99 @@ func (c *consumerGroup) newSession(ctx context.Context, topics []string, handler
 	return session, err
 }
 
-func (c *consumerGroup) joinGroupRequest(coordinator *Broker, topics []string) (*JoinGroupResponse, error) {
+// groupStrategy returns the BalanceStrategy of the protocol chosen by the group.
+func (c *consumerGroup) groupStrategy(protocol string) (BalanceStrategy, error) {
+	if strategy := c.config.Consumer.Group.Rebalance.Strategy; strategy != nil {
+		return strategy, nil
+	}
+	strategy, ok := c.findStrategy(protocol, c.config.Consumer.Group.Rebalance.GroupStrategies)
+	if !ok {
+		// this case shouldn't happen in practice, since the leader will choose the protocol
+		// that all the members support
+		return nil, fmt.Errorf("unable to find selected strategy: %s", protocol)
+	}
+	return strategy, nil
+}
+
+// memberClaims returns the sorted claims assigned to the member by a
+// SyncGroupResponse.
+func (c *consumerGroup) memberClaims(resp *SyncGroupResponse) (map[string][]int32, error) {
+	if len(resp.MemberAssignment) == 0 {
+		return nil, nil
+	}
+	members, err := resp.GetMemberAssignment()
+	if err != nil {
+		return nil, err
+	}
+	claims := members.Topics
+
+	// in the case of stateful balance strategies, hold on to the returned
+	// assignment metadata, otherwise, reset the statically defined consumer
+	// group metadata
+	if members.UserData != nil {
+		c.userData = members.UserData
+	} else {
+		c.userData = c.config.Consumer.Group.Member.UserData
+	}
+
+	for _, partitions := range claims {
+		sort.Sort(int32Slice(partitions))
+	}
+	return claims, nil
+}
+
+// rejoin takes part in a rebalance of the group without releasing the owned
+// claims, as done by the members of a group using a strategy with
+// RebalanceProtocolCooperative. It returns the new claims of the member and the
+// new generation of the group.
+func (c *consumerGroup) rejoin(coordinator *Broker, topics []string, owned map[string][]int32, generationID int32) (map[string][]int32, int32, error) {
+	join, err := c.joinGroupRequest(coordinator, topics, owned, generationID)
+	if err != nil {
+		_ = coordinator.Close()
+		return nil, 0, err
+	}
+	if !errors.Is(join.Err, ErrNoError) {
+		return nil, 0, join.Err
+	}
+	c.memberID = join.MemberId
+
+	strategy, err := c.groupStrategy(join.GroupProtocol)
+	if err != nil {
+		return nil, 0, err
+	}
+	if rebalanceProtocol(strategy) != RebalanceProtocolCooperative {
+		// the owned claims should have been revoked before joining the group
+		return nil, 0, fmt.Errorf("group switched to the eager %s strategy", strategy.Name())
+	}
+
+	var plan BalanceStrategyPlan
+	var members map[string]ConsumerGroupMemberMetadata
+	if join.LeaderId == join.MemberId {
+		if members, err = join.GetMembers(); err != nil {
+			return nil, 0, err
+		}
+		if _, _, plan, err = c.balance(strategy, members); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	sync, err := c.syncGroupRequest(coordinator, members, plan, join.GenerationId, strategy)
+	if err != nil {
+		_ = coordinator.Close()
+		return nil, 0, err
+	}
+	if !errors.Is(sync.Err, ErrNoError) {
+		return nil, 0, sync.Err
+	}
+
+	claims, err := c.memberClaims(sync)
+	return claims, join.GenerationId, err
+}
+
+// joinGroupRequest sends a JoinGroupRequest for topics. Strategies using
+// RebalanceProtocolCooperative also report the owned claims of the member,
+// which belong to the given generation.
+func (c *consumerGroup) joinGroupRequest(coordinator *Broker, topics []string, owned map[string][]int32, generationID int32) (*JoinGroupResponse, error) {
 	req := &JoinGroupRequest{
 		GroupId:        c.groupID,
 		MemberId:       c.memberID,
@@ -508,21 +831,42 @@ func (c *consumerGroup) joinGroupRequest(coordinator *Broker, topics []string) (
 		c.lastSessionCause = nil
 	}
 
+	strategies := c.config.Consumer.Group.Rebalance.GroupStrategies
 	if strategy := c.config.Consumer.Group.Rebalance.Strategy; strategy != nil {
-		if err := req.AddGroupProtocolMetadata(strategy.Name(), c.subscriptionMetadata(strategy, topics)); err != nil {
-			return nil, err
+		strategies = []BalanceStrategy{strategy}
+	}
+	for _, strategy := range strategies {
+		meta := c.subscriptionMetadata(strategy, topics)
+		if rebalanceProtocol(strategy) == RebalanceProtocolCooperative {
+			meta.Version = 2
+			meta.OwnedPartitions = ownedPartitions(owned)
+			meta.GenerationID = generationID
 		}
-	} else {
-		for _, strategy := range c.config.Consumer.Group.Rebalance.GroupStrategies {
-			if err := req.AddGroupProtocolMetadata(strategy.Name(), c.subscriptionMetadata(strategy, topics)); err != nil {
-				return nil, err
+		if c.config.Consumer.Group.Rebalance.RackAware && c.config.RackID != "" {
+			if meta.Version < 2 {
+				meta.GenerationID = defaultGeneration
 			}
+			meta.Version = 3
+			meta.RackID = &c.config.RackID
+		}
+		if err := req.AddGroupProtocolMetadata(strategy.Name(), meta); err != nil {
+			return nil, err
 		}
 	}
 
 	return coordinator.JoinGroup(req)
 }
 
+// ownedPartitions converts claims into the OwnedPartitions of a
+// ConsumerGroupMemberMetadata.
+func ownedPartitions(claims map[string][]int32) []*OwnedPartition {
+	owned := make([]*OwnedPartition, 0, len(claims))
+	for _, topic := range slices.Sorted(maps.Keys(claims)) {
+		owned = append(owned, &OwnedPartition{Topic: topic, Partitions: claims[topic]})
+	}
+	return owned
+}
+
 // subscriptionMetadata builds the ConsumerGroupMemberMetadata for a single
 // strategy in a JoinGroup request. If the strategy implements
 // SubscriptionUserDataBalanceStrategy, its SubscriptionUserData hook is invoked
@@ -673,14 +1017,46 @@ func (c *consumerGroup) balance(strategy BalanceStrategy, members map[string]Con
 		topicPartitions[topic] = partitions
 	}
 
+	if s, ok := strategy.(RackAwareBalanceStrategy); ok && c.config.Consumer.Group.Rebalance.RackAware {
+		plan, err := s.PlanWithRacks(members, topicPartitions, c.partitionRacks(topicPartitions))
+		return topicPartitions, allSubscribedTopics, plan, err
+	}
+
 	plan, err := strategy.Plan(members, topicPartitions)
 	return topicPartitions, allSubscribedTopics, plan, err
 }
 
+// partitionRacks returns the racks of the replicas of the given partitions
+// from the metadata of the client, skipping the brokers without a rack.
+func (c *consumerGroup) partitionRacks(topicPartitions map[string][]int32) map[string]map[int32][]string {
+	racks := make(map[string]map[int32][]string, len(topicPartitions))
+	for topic, partitions := range topicPartitions {
+		racks[topic] = make(map[int32][]string, len(partitions))
+		for _, partition := range partitions {
+			replicas, err := c.client.Replicas(topic, partition)
+			if err != nil {
+				continue
+			}
+			for _, replica := range replicas {
+				broker, err := c.client.Broker(replica)
+				if err != nil || broker.Rack() == "" {
+					continue
+				}
+				racks[topic][partition] = append(racks[topic][partition], broker.Rack())
+			}
+		}
+	}
+	return racks
+}
+
 // Leaves the cluster, called by Close.
 func (c *consumerGroup) leave() error {
 	c.lock.Lock()
 	defer c.lock.Unlock()
+	return c.leaveLocked()
+}
+
+func (c *consumerGroup) leaveLocked() error {
 	if c.memberID == "" {
 		return nil
 	}
@@ -690,6 +1066,16 @@ func (c *consumerGroup) leave() error {
 		return err
 	}
 
+	if c.protocol == GroupProtocolConsumer {
+		// as per KIP-848 a static member leaves temporarily, keeping its
+		// assignment until it rejoins or its session times out
+		epoch := ConsumerGroupMemberEpochLeave
+		if c.groupInstanceId != nil {
+			epoch = ConsumerGroupMemberEpochLeaveStatic
+		}
+		return c.leaveConsumerProtocol(coordinator, epoch)
+	}
+
 	// as per KIP-345 if groupInstanceId is set, i.e. static membership is in action, then do not leave group when consumer closed, just clear memberID
 	if c.groupInstanceId != nil {
 		c.memberID = ""
@@ -736,6 +1122,203 @@ func (c *consumerGroup) leave() error {
 	}
 }
 
+// SetGroupProtocol implements ConsumerGroup.
+func (c *consumerGroup) SetGroupProtocol(protocol string) error {
+	if err := validateGroupProtocolName(protocol, c.config.Version); err != nil {
+		return err
+	}
+	if protocol == "" {
+		protocol = GroupProtocolClassic
+	}
+	c.nextProtocol.Store(protocol)
+	return nil
+}
+
+// switchProtocol makes the member leave the group when another protocol was
+// requested since it joined, so that it rejoins using the new protocol.
+func (c *consumerGroup) switchProtocol() error {
+	protocol := c.nextProtocol.Load().(string)
+	if protocol == c.protocol {
+		return nil
+	}
+
+	Logger.Printf("consumergroup/%s switching from the %s to the %s protocol\n", c.groupID, c.protocol, protocol)
+	var err error
+	if c.protocol == GroupProtocolConsumer && c.memberID != "" {
+		// leave for good, even as a static member, since the member will not
+		// rejoin using this protocol
+		var coordinator *Broker
+		if coordinator, err = c.client.Coordinator(c.groupID); err == nil {
+			err = c.leaveConsumerProtocol(coordinator, ConsumerGroupMemberEpochLeave)
+		}
+	} else {
+		err = c.leaveLocked()
+	}
+	if err != nil {
+		return err
+	}
+	c.memberID = ""
+	c.protocol = protocol
+	return nil
+}
+
+func (c *consumerGroup) newConsumerProtocolSession(ctx context.Context, topics []string, handler ConsumerGroupHandler, retries int) (*consumerGroupSession, error) {
+	coordinator, err := c.client.Coordinator(c.groupID)
+	if err != nil {
+		if retries <= 0 {
+			return nil, err
+		}
+
+		return c.retryNewSession(ctx, topics, handler, retries, true)
+	}
+
+	c.topics = topics
+
+	// A new session starts without owning any partitions: reporting an empty
+	// set makes the coordinator send the full assignment of the member back.
+	resp, err := c.consumerGroupHeartbeatRequest(coordinator, c.memberEpoch, topics, []*ConsumerGroupHeartbeatTopicPartitions{})
+	if err != nil {
+		_ = coordinator.Close()
+		return nil, err
+	}
+
+	switch resp.Err {
+	case ErrNoError:
+	case ErrUnknownMemberId, ErrFencedMemberEpoch:
+		// reset member ID and epoch and rejoin immediately
+		c.memberID = ""
+		c.memberEpoch = ConsumerGroupMemberEpochJoin
+		c.assignment = nil
+		return c.newSession(ctx, topics, handler, retries)
+	case ErrNotCoordinatorForConsumer, ErrConsumerCoordinatorNotAvailable, ErrOffsetsLoadInProgress:
+		// retry after backoff
+		if retries <= 0 {
+			return nil, resp.Err
+		}
+		return c.retryNewSession(ctx, topics, handler, retries, true)
+	case ErrFencedInstancedId, ErrUnreleasedInstanceId:
+		if c.groupInstanceId != nil {
+			Logger.Printf("ConsumerGroupHeartbeat failed: group instance id %s is in use by another member\n", *c.groupInstanceId)
+		}
+		return nil, resp.Err
+	default:
+		if resp.ErrorMessage != nil {
+			return nil, fmt.Errorf("%w: %s", resp.Err, *resp.ErrorMessage)
+		}
+		return nil, resp.Err
+	}
+
+	if resp.MemberId != nil {
+		c.memberID = *resp.MemberId
+	}
+	c.memberEpoch = resp.MemberEpoch
+	c.heartbeatInterval = time.Duration(resp.HeartbeatIntervalMs) * time.Millisecond
+	if resp.Assignment != nil {
+		if c.assignment, err = c.resolveAssignment(coordinator, resp.Assignment); err != nil {
+			return nil, err
+		}
+	}
+
+	claims := make(map[string][]int32, len(c.assignment))
+	for topic, partitions := range c.assignment {
+		claims[topic] = slices.Clone(partitions)
+	}
+
+	return newConsumerGroupSession(ctx, c, claims, c.memberID, c.memberEpoch, handler)
+}
+
+// consumerGroupHeartbeatRequest sends a ConsumerGroupHeartbeat for the member.
+// The subscription is only sent when topics is not nil, and owned partitions
+// only when owned is not nil, as the coordinator remembers them otherwise.
+func (c *consumerGroup) consumerGroupHeartbeatRequest(coordinator *Broker, memberEpoch int32, topics []string, owned []*ConsumerGroupHeartbeatTopicPartitions) (*ConsumerGroupHeartbeatResponse, error) {
+	req := &ConsumerGroupHeartbeatRequest{
+		GroupId:            c.groupID,
+		MemberId:           c.memberID,
+		MemberEpoch:        memberEpoch,
+		InstanceId:         c.groupInstanceId,
+		RebalanceTimeoutMs: -1,
+		TopicPartitions:    owned,
+	}
+	if topics != nil {
+		req.RebalanceTimeoutMs = int32(c.config.Consumer.Group.Rebalance.Timeout / time.Millisecond)
+		req.SubscribedTopicNames = topics
+		if c.config.RackID != "" {
+			req.RackId = &c.config.RackID
+		}
+	}
+
+	return coordinator.ConsumerGroupHeartbeat(req)
+}
+
+// resolveAssignment converts an assignment keyed by topic IDs into claims keyed
+// by topic names, refreshing the IDs of the subscribed topics from the cluster
+// metadata when the assignment references a topic that was not seen before.
+func (c *consumerGroup) resolveAssignment(broker *Broker, assignment *ConsumerGroupHeartbeatAssignment) (map[string][]int32, error) {
+	for _, tp := range assignment.TopicPartitions {
+		if _, ok := c.topicNames[tp.TopicId]; ok {
+			continue
+		}
+
+		req := NewMetadataRequest(c.config.Version, c.topics)
+		req.AllowAutoTopicCreation = c.config.Metadata.AllowAutoTopicCreation
+		resp, err := broker.GetMetadata(req)
+		if err != nil {
+			return nil, err
+		}
+		for _, topic := range resp.Topics {
+			if errors.Is(topic.Err, ErrNoError) {
+				c.topicNames[topic.Uuid] = topic.Name
+			}
+		}
+		break
+	}
+
+	claims := make(map[string][]int32, len(assignment.TopicPartitions))
+	for _, tp := range assignment.TopicPartitions {
+		topic, ok := c.topicNames[tp.TopicId]
+		if !ok {
+			Logger.Printf("consumergroup/%s ignoring assignment of unknown topic id %s\n", c.groupID, tp.TopicId)
+			continue
+		}
+		partitions := slices.Clone(tp.Partitions)
+		sort.Sort(int32Slice(partitions))
+		claims[topic] = partitions
+	}
+	return claims, nil
+}
+
+// ownedTopicPartitions converts claims keyed by topic names into the owned
+// partitions reported to the coordinator, keyed by topic IDs.
+func (c *consumerGroup) ownedTopicPartitions(claims map[string][]int32) []*ConsumerGroupHeartbeatTopicPartitions {
+	owned := make([]*ConsumerGroupHeartbeatTopicPartitions, 0, len(claims))
+	for id, topic := range c.topicNames {
+		if partitions, ok := claims[topic]; ok {
+			owned = append(owned, &ConsumerGroupHeartbeatTopicPartitions{TopicId: id, Partitions: partitions})
+		}
+	}
+	return owned
+}
+
+func (c *consumerGroup) leaveConsumerProtocol(coordinator *Broker, epoch int32) error {
+	resp, err := c.consumerGroupHeartbeatRequest(coordinator, epoch, nil, nil)
+	if err != nil {
+		_ = coordinator.Close()
+		return err
+	}
+
+	// clear the member state
+	c.memberID = ""
+	c.memberEpoch = ConsumerGroupMemberEpochJoin
+	c.assignment = nil
+
+	switch resp.Err {
+	case ErrNoError, ErrUnknownMemberId, ErrFencedMemberEpoch:
+		return nil
+	default:
+		return resp.Err
+	}
+}
+
 func (c *consumerGroup) handleError(err error, topic string, partition int32) {
 	var consumerError *ConsumerError
 	if ok := errors.As(err, &consumerError); !ok && topic != "" && partition > -1 {
@@ -866,10 +1449,35 @@ type ConsumerGroupSession interface {
 	// MarkMessage marks a message as consumed.
 	MarkMessage(msg *ConsumerMessage, metadata string)
 
+	// MarkError forwards a message the handler failed to process to the dead
+	// letter topic of Config.Consumer.Group.DeadLetter, with the DeadLetterHeader
+	// headers describing err and where the message comes from added to its
+	// own, and marks it as consumed once the dead letter producer acknowledged
+	// it. The message is not marked if it could not be forwarded and the error
+	// is returned instead, so that it is never skipped: returning it from
+	// ConsumeClaim ends the session and the message is consumed again. It
+	// returns ErrNoDeadLetterProducer if no dead letter producer is configured.
+	MarkError(msg *ConsumerMessage, err error) error
+
 	// Context returns the session context.
 	Context() context.Context
 }
 
+// The headers ConsumerGroupSession.MarkError adds to the messages it forwards
+// to their dead letter topic.
+const (
+	// DeadLetterHeaderError is the error the message failed with.
+	DeadLetterHeaderError = "x-dead-letter-error"
+	// DeadLetterHeaderTopic is the topic the message was consumed from.
+	DeadLetterHeaderTopic = "x-dead-letter-topic"
+	// DeadLetterHeaderPartition is the partition the message was consumed from.
+	DeadLetterHeaderPartition = "x-dead-letter-partition"
+	// DeadLetterHeaderOffset is the offset of the message.
+	DeadLetterHeaderOffset = "x-dead-letter-offset"
+	// DeadLetterHeaderGroup is the consumer group that failed the message.
+	DeadLetterHeaderGroup = "x-dead-letter-group"
+)
+
 type consumerGroupSession struct {
 	parent       *consumerGroup
 	memberID     string
@@ -881,11 +1489,22 @@ type consumerGroupSession struct {
 	ctx     context.Context
 	cancel  context.CancelCauseFunc
 
+	// lock guards claims and generationID, which cooperative rebalances
+	// update, and the consumed partitions
+	lock      sync.RWMutex
+	consumers map[topicPartition]*claimConsumer
+
 	waitGroup       sync.WaitGroup
 	releaseOnce     sync.Once
 	hbDying, hbDead chan none
 }
 
+// claimConsumer tracks the goroutine consuming a claim of a session.
+type claimConsumer struct {
+	revoked chan none // closed when a cooperative rebalance revokes the claim
+	done    chan none // closed once the claim is no longer consumed
+}
+
 func newConsumerGroupSession(ctx context.Context, parent *consumerGroup, claims map[string][]int32, memberID string, generationID int32, handler ConsumerGroupHandler) (*consumerGroupSession, error) {
 	// init context
 	ctx, cancel := context.WithCancelCause(ctx)
@@ -906,28 +1525,27 @@ func newConsumerGroupSession(ctx context.Context, parent *consumerGroup, claims
 		claims:       claims,
 		ctx:          ctx,
 		cancel:       cancel,
+		consumers:    make(map[topicPartition]*claimConsumer),
 		hbDying:      make(chan none),
 		hbDead:       make(chan none),
 	}
 
+	parent.retainPaused(claims)
+
 	// start heartbeat loop
-	go sess.heartbeatLoop()
+	if parent.protocol == GroupProtocolConsumer {
+		go sess.consumerProtocolHeartbeatLoop()
+	} else {
+		go sess.heartbeatLoop()
+	}
 
 	// create a POM for each claim
 	for topic, partitions := range claims {
 		for _, partition := range partitions {
-			pom, err := offsets.ManagePartition(topic, partition)
-			if err != nil {
+			if err := sess.managePartition(topic, partition); err != nil {
 				_ = sess.release(false)
 				return nil, err
 			}
-
-			// handle POM errors
-			go func(topic string, partition int32) {
-				for err := range pom.Errors() {
-					sess.parent.handleError(err, topic, partition)
-				}
-			}(topic, partition)
 		}
 	}
 
@@ -936,44 +1554,102 @@ func newConsumerGroupSession(ctx context.Context, parent *consumerGroup, claims
 		_ = sess.release(true)
 		return nil, err
 	}
+	if h, ok := handler.(ConsumerGroupHandlerV2); ok && len(claims) > 0 {
+		if err := h.PartitionsAssigned(sess, claims); err != nil {
+			_ = sess.release(true)
+			return nil, err
+		}
+	}
 
 	// start consuming each topic partition in its own goroutine
 	for topic, partitions := range claims {
 		for _, partition := range partitions {
-			sess.waitGroup.Add(1) // increment wait group before spawning goroutine
-			go func(topic string, partition int32) {
-				defer sess.waitGroup.Done()
-				// cancel the group session as soon as any of the consume calls return
-				defer sess.cancel(ErrSessionConsumeClaimExited)
-
-				// if partition not currently readable, wait for it to become readable
-				if sess.parent.client.PartitionNotReadable(topic, partition) {
-					timer := time.NewTimer(5 * time.Second)
-					defer timer.Stop()
-
-					for sess.parent.client.PartitionNotReadable(topic, partition) {
-						select {
-						case <-ctx.Done():
-							return
-						case <-parent.closed:
-							return
-						case <-timer.C:
-							timer.Reset(5 * time.Second)
-						}
-					}
-				}
-
-				// consume a single topic/partition, blocking
-				sess.consume(topic, partition)
-			}(topic, partition)
+			sess.startConsuming(topic, partition)
 		}
 	}
 	return sess, nil
 }
 
-func (s *consumerGroupSession) Claims() map[string][]int32 { return s.claims }
-func (s *consumerGroupSession) MemberID() string           { return s.memberID }
-func (s *consumerGroupSession) GenerationID() int32        { return s.generationID }
+// managePartition creates the POM of a claim.
+func (s *consumerGroupSession) managePartition(topic string, partition int32) error {
+	pom, err := s.offsets.ManagePartition(topic, partition)
+	if err != nil {
+		return err
+	}
+
+	// handle POM errors
+	go func() {
+		for err := range pom.Errors() {
+			s.parent.handleError(err, topic, partition)
+		}
+	}()
+	return nil
+}
+
+// startConsuming consumes a claim in its own goroutine.
+func (s *consumerGroupSession) startConsuming(topic string, partition int32) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// the session is being released, see release
+	if s.ctx.Err() != nil {
+		return
+	}
+
+	c := &claimConsumer{revoked: make(chan none), done: make(chan none)}
+	s.consumers[topicPartition{topic: topic, partition: partition}] = c
+
+	s.waitGroup.Add(1) // increment wait group before spawning goroutine
+	go func() {
+		defer s.waitGroup.Done()
+		defer close(c.done)
+		// cancel the group session as soon as any of the consume calls return,
+		// unless the claim was revoked by a cooperative rebalance
+		defer func() {
+			select {
+			case <-c.revoked:
+			default:
+				s.cancel(ErrSessionConsumeClaimExited)
+			}
+		}()
+
+		// if partition not currently readable, wait for it to become readable
+		if s.parent.client.PartitionNotReadable(topic, partition) {
+			timer := time.NewTimer(5 * time.Second)
+			defer timer.Stop()
+
+			for s.parent.client.PartitionNotReadable(topic, partition) {
+				select {
+				case <-s.ctx.Done():
+					return
+				case <-s.parent.closed:
+					return
+				case <-c.revoked:
+					return
+				case <-timer.C:
+					timer.Reset(5 * time.Second)
+				}
+			}
+		}
+
+		// consume a single topic/partition, blocking
+		s.consume(topic, partition, c.revoked)
+	}()
+}
+
+func (s *consumerGroupSession) Claims() map[string][]int32 {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.claims
+}
+
+func (s *consumerGroupSession) MemberID() string { return s.memberID }
+
+func (s *consumerGroupSession) GenerationID() int32 {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.generationID
+}
 
 func (s *consumerGroupSession) MarkOffset(topic string, partition int32, offset int64, metadata string) {
 	if pom := s.offsets.findPOM(topic, partition); pom != nil {
@@ -995,6 +1671,50 @@ func (s *consumerGroupSession) MarkMessage(msg *ConsumerMessage, metadata string
 	s.MarkOffset(msg.Topic, msg.Partition, msg.Offset+1, metadata)
 }
 
+func (s *consumerGroupSession) MarkError(msg *ConsumerMessage, err error) error {
+	deadLetter := s.parent.config.Consumer.Group.DeadLetter
+	if deadLetter.Producer == nil {
+		return ErrNoDeadLetterProducer
+	}
+
+	if _, _, err := deadLetter.Producer.SendMessage(s.newDeadLetterMessage(deadLetter.TopicFunc(msg), msg, err)); err != nil {
+		return fmt.Errorf("kafka: failed to forward message %s/%d/%d to the dead letter topic: %w", msg.Topic, msg.Partition, msg.Offset, err)
+	}
+
+	s.MarkMessage(msg, "")
+	return nil
+}
+
+// newDeadLetterMessage copies a message failed with cause for the dead letter topic.
+func (s *consumerGroupSession) newDeadLetterMessage(topic string, msg *ConsumerMessage, cause error) *ProducerMessage {
+	var reason string
+	if cause != nil {
+		reason = cause.Error()
+	}
+
+	headers := make([]RecordHeader, 0, len(msg.Headers)+5)
+	for _, header := range msg.Headers {
+		headers = append(headers, *header)
+	}
+	headers = append(headers,
+		RecordHeader{Key: []byte(DeadLetterHeaderError), Value: []byte(reason)},
+		RecordHeader{Key: []byte(DeadLetterHeaderTopic), Value: []byte(msg.Topic)},
+		RecordHeader{Key: []byte(DeadLetterHeaderPartition), Value: []byte(strconv.FormatInt(int64(msg.Partition), 10))},
+		RecordHeader{Key: []byte(DeadLetterHeaderOffset), Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+		RecordHeader{Key: []byte(DeadLetterHeaderGroup), Value: []byte(s.parent.groupID)},
+	)
+
+	message := &ProducerMessage{Topic: topic, Headers: headers, Timestamp: msg.Timestamp}
+	// keep null keys and values (tombstones) as they are
+	if msg.Key != nil {
+		message.Key = ByteEncoder(msg.Key)
+	}
+	if msg.Value != nil {
+		message.Value = ByteEncoder(msg.Value)
+	}
+	return message
+}
+
 func (s *consumerGroupSession) Context() context.Context {
 	return s.ctx
 }
@@ -1043,13 +1763,15 @@ func isRetriableClaimError(err error) bool {
 		errors.Is(err, ErrReplicaNotAvailable)
 }
 
-func (s *consumerGroupSession) consume(topic string, partition int32) {
+func (s *consumerGroupSession) consume(topic string, partition int32, revoked <-chan none) {
 	// quick exit if rebalance is due
 	select {
 	case <-s.ctx.Done():
 		return
 	case <-s.parent.closed:
 		return
+	case <-revoked:
+		return
 	default:
 	}
 
@@ -1066,11 +1788,12 @@ func (s *consumerGroupSession) consume(topic string, partition int32) {
 		return
 	}
 
-	// trigger close when session is done
+	// trigger close when session is done or the claim is revoked
 	go func() {
 		select {
 		case <-s.ctx.Done():
 		case <-s.parent.closed:
+		case <-revoked:
 		}
 		claim.AsyncClose()
 	}()
@@ -1088,8 +1811,11 @@ func (s *consumerGroupSession) consume(topic string, partition int32) {
 }
 
 func (s *consumerGroupSession) release(withCleanup bool) (err error) {
-	// signal release, stop heartbeat
+	// signal release, stop heartbeat; holding the lock ensures no cooperative
+	// rebalance starts consuming a claim once we wait for the consumers
+	s.lock.Lock()
 	s.cancel(nil)
+	s.lock.Unlock()
 
 	// wait for consumers to exit
 	s.waitGroup.Wait()
@@ -1097,6 +1823,14 @@ func (s *consumerGroupSession) release(withCleanup bool) (err error) {
 	// perform release
 	s.releaseOnce.Do(func() {
 		if withCleanup {
+			if h, ok := s.handler.(ConsumerGroupHandlerV2); ok {
+				if claims := s.Claims(); len(claims) > 0 {
+					if e := h.PartitionsRevoked(s, claims); e != nil {
+						s.parent.handleError(e, "", -1)
+						err = e
+					}
+				}
+			}
 			if e := s.handler.Cleanup(s); e != nil {
 				s.parent.handleError(e, "", -1)
 				err = e
@@ -1171,7 +1905,14 @@ func (s *consumerGroupSession) heartbeatLoop() {
 			retries = s.parent.config.Metadata.Retry.Max
 		case ErrRebalanceInProgress:
 			retries = s.parent.config.Metadata.Retry.Max
-			s.cancel(err)
+			if !s.parent.cooperative || s.ctx.Err() != nil {
+				s.cancel(err)
+			} else if err := s.rebalance(coordinator); err != nil {
+				Logger.Printf(
+					"consumergroup/session/%s/%d cooperative rebalance failed: %v\n",
+					s.MemberID(), s.GenerationID(), err)
+				s.cancel(err)
+			}
 		case ErrUnknownMemberId, ErrIllegalGeneration:
 			s.cancel(err)
 			return
@@ -1196,6 +1937,256 @@ func (s *consumerGroupSession) heartbeatLoop() {
 	}
 }
 
+// rebalance takes part in a rebalance of a cooperative strategy without ending
+// the session: the claims that stay assigned to the member keep being consumed,
+// the revoked ones are released and the new ones start being consumed. When
+// claims were revoked, it rejoins the group right away so that they can be
+// assigned to their new owner.
+func (s *consumerGroupSession) rebalance(coordinator *Broker) error {
+	for {
+		prev := s.Claims()
+		claims, generationID, err := s.parent.rejoin(coordinator, s.parent.topics, prev, s.GenerationID())
+		if err != nil {
+			return err
+		}
+		if s.ctx.Err() != nil {
+			// the session is being released along with all its claims
+			return nil
+		}
+		revoked, assigned := diffClaims(prev, claims), diffClaims(claims, prev)
+
+		s.lock.Lock()
+		s.claims = claims
+		s.generationID = generationID
+		s.lock.Unlock()
+		s.parent.retainPaused(claims)
+		s.offsets.generation.Store(generationID)
+
+		Logger.Printf(
+			"consumergroup/session/%s/%d rebalanced, revoked %v, assigned %v\n",
+			s.MemberID(), generationID, revoked, assigned)
+
+		s.revoke(revoked)
+		for topic, partitions := range assigned {
+			for _, partition := range partitions {
+				if err := s.managePartition(topic, partition); err != nil {
+					return err
+				}
+			}
+		}
+		if h, ok := s.handler.(ConsumerGroupHandlerV2); ok && len(assigned) > 0 {
+			if err := h.PartitionsAssigned(s, assigned); err != nil {
+				return err
+			}
+		}
+		for topic, partitions := range assigned {
+			for _, partition := range partitions {
+				s.startConsuming(topic, partition)
+			}
+		}
+
+		if len(revoked) == 0 {
+			return nil
+		}
+	}
+}
+
+// revoke stops consuming claims and commits their offsets.
+func (s *consumerGroupSession) revoke(claims map[string][]int32) {
+	if len(claims) == 0 {
+		return
+	}
+
+	var consumers []*claimConsumer
+	s.lock.Lock()
+	for topic, partitions := range claims {
+		for _, partition := range partitions {
+			tp := topicPartition{topic: topic, partition: partition}
+			if c := s.consumers[tp]; c != nil {
+				close(c.revoked)
+				consumers = append(consumers, c)
+				delete(s.consumers, tp)
+			}
+		}
+	}
+	s.lock.Unlock()
+
+	for _, c := range consumers {
+		<-c.done
+	}
+
+	if h, ok := s.handler.(ConsumerGroupHandlerV2); ok {
+		if err := h.PartitionsRevoked(s, claims); err != nil {
+			s.parent.handleError(err, "", -1)
+		}
+	}
+
+	for topic, partitions := range claims {
+		for _, partition := range partitions {
+			if pom := s.offsets.findPOM(topic, partition); pom != nil {
+				pom.AsyncClose()
+			}
+		}
+	}
+	if s.parent.config.Consumer.Offsets.AutoCommit.Enable {
+		s.offsets.commit(time.Time{})
+	}
+	s.offsets.releasePOMs(true)
+}
+
+// diffClaims returns the claims of a that are not in b.
+func diffClaims(a, b map[string][]int32) map[string][]int32 {
+	diff := make(map[string][]int32)
+	for topic, partitions := range a {
+		for _, partition := range partitions {
+			if !slices.Contains(b[topic], partition) {
+				diff[topic] = append(diff[topic], partition)
+			}
+		}
+	}
+	return diff
+}
+
+// consumerProtocolHeartbeatLoop is the heartbeatLoop of sessions using the
+// GroupProtocolConsumer protocol. It acknowledges the claims of the session,
+// keeps the member epoch used for offset commits up to date and ends the
+// session when the coordinator changes the assignment of the member.
+func (s *consumerGroupSession) consumerProtocolHeartbeatLoop() {
+	defer close(s.hbDead)
+	defer s.cancel(ErrSessionHeartbeatFailed) // trigger the end of the session on exit
+	defer func() {
+		Logger.Printf(
+			"consumergroup/session/%s/%d heartbeat loop stopped\n",
+			s.MemberID(), s.GenerationID())
+	}()
+
+	interval := s.parent.heartbeatInterval
+	if interval <= 0 {
+		interval = s.parent.config.Consumer.Group.Heartbeat.Interval
+	}
+	pause := time.NewTicker(interval)
+	defer pause.Stop()
+
+	retryBackoff := time.NewTimer(s.parent.config.Metadata.Retry.Backoff)
+	defer retryBackoff.Stop()
+
+	// the first heartbeat acknowledges the partitions claimed by this session
+	owned := s.parent.ownedTopicPartitions(s.claims)
+
+	retries := s.parent.config.Metadata.Retry.Max
+	retry := false
+	for {
+		if !retry {
+			select {
+			case <-pause.C:
+			case <-s.hbDying:
+				return
+			}
+		}
+		retry = false
+
+		coordinator, err := s.parent.client.Coordinator(s.parent.groupID)
+		if err != nil {
+			if retries <= 0 {
+				s.parent.handleError(err, "", -1)
+				s.cancel(err)
+				return
+			}
+			retryBackoff.Reset(s.parent.config.Metadata.Retry.Backoff)
+			select {
+			case <-s.hbDying:
+				return
+			case <-retryBackoff.C:
+				retries--
+			}
+			continue
+		}
+
+		resp, err := s.parent.consumerGroupHeartbeatRequest(coordinator, s.parent.memberEpoch, nil, owned)
+		if err != nil {
+			_ = coordinator.Close()
+
+			if retries <= 0 {
+				s.parent.handleError(err, "", -1)
+				s.cancel(err)
+				return
+			}
+
+			retries--
+			continue
+		}
+
+		switch err := resp.Err; err {
+		case ErrNoError:
+			retries = s.parent.config.Metadata.Retry.Max
+			owned = nil
+			if resp.HeartbeatIntervalMs > 0 {
+				pause.Reset(time.Duration(resp.HeartbeatIntervalMs) * time.Millisecond)
+			}
+			s.parent.memberEpoch = resp.MemberEpoch
+			s.offsets.generation.Store(resp.MemberEpoch)
+			if resp.Assignment != nil {
+				claims, err := s.parent.resolveAssignment(coordinator, resp.Assignment)
+				if err != nil {
+					s.parent.handleError(err, "", -1)
+					s.cancel(err)
+					return
+				}
+				s.parent.assignment = claims
+				if !sameClaims(claims, s.claims) {
+					s.cancel(ErrSessionAssignmentChanged)
+					return
+				}
+			}
+		case ErrUnknownMemberId, ErrFencedMemberEpoch:
+			s.cancel(err)
+			return
+		case ErrNotCoordinatorForConsumer, ErrConsumerCoordinatorNotAvailable:
+			// the coordinator moved, find it and retry the heartbeat after backoff
+			if retries <= 0 {
+				s.parent.handleError(err, "", -1)
+				s.cancel(err)
+				return
+			}
+			if err := s.parent.client.RefreshCoordinator(s.parent.groupID); err != nil {
+				Logger.Printf("consumergroup/session/%s/%d failed to refresh the coordinator: %v\n",
+					s.MemberID(), s.GenerationID(), err)
+			}
+			retryBackoff.Reset(s.parent.config.Metadata.Retry.Backoff)
+			select {
+			case <-s.hbDying:
+				return
+			case <-retryBackoff.C:
+				retries--
+			}
+			retry = true
+		case ErrFencedInstancedId, ErrUnreleasedInstanceId:
+			if s.parent.groupInstanceId != nil {
+				Logger.Printf("ConsumerGroupHeartbeat failed: group instance id %s is in use by another member\n", *s.parent.groupInstanceId)
+			}
+			s.parent.handleError(err, "", -1)
+			s.cancel(err)
+			return
+		default:
+			s.parent.handleError(err, "", -1)
+			s.cancel(err)
+			return
+		}
+	}
+}
+
+func sameClaims(a, b map[string][]int32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for topic, partitions := range a {
+		if !slices.Equal(partitions, b[topic]) {
+			return false
+		}
+	}
+	return true
+}
+
 func sessionCauseToReason(cause error) string {
 	switch {
 	case errors.Is(cause, ErrRebalanceInProgress):
@@ -1212,6 +2203,8 @@ func sessionCauseToReason(cause error) string {
 		return "a ConsumeClaim handler has exited"
 	case errors.Is(cause, ErrSessionHeartbeatFailed):
 		return "the heartbeat goroutine has stopped"
+	case errors.Is(cause, ErrSessionAssignmentChanged):
+		return "the group coordinator changed the assignment"
 	default:
 		return cause.Error()
 	}
@@ -1241,6 +2234,30 @@ type ConsumerGroupHandler interface {
 	ConsumeClaim(ConsumerGroupSession, ConsumerGroupClaim) error
 }
 
+// ConsumerGroupHandlerV2 is an optional extension of ConsumerGroupHandler for
+// handlers that need to know which partitions they are gaining and losing, e.g.
+// to commit the offsets of the revoked partitions only. Handlers that do not
+// implement it keep the Setup/Cleanup/ConsumeClaim contract unchanged.
+//
+// When a session starts, PartitionsAssigned is run with all its claims after
+// Setup, and when it ends, PartitionsRevoked is run with all its claims before
+// Cleanup. During a rebalance of a strategy using RebalanceProtocolCooperative
+// the session survives, and the handler is only notified of the partitions
+// that moved.
+type ConsumerGroupHandlerV2 interface {
+	ConsumerGroupHandler
+
+	// PartitionsAssigned is run when partitions are assigned to the session,
+	// before ConsumeClaim is started for them. An error ends the session.
+	PartitionsAssigned(session ConsumerGroupSession, assigned map[string][]int32) error
+
+	// PartitionsRevoked is run when partitions are revoked from the session,
+	// once their ConsumeClaim goroutines have exited but before their offsets
+	// are committed for the very last time. Errors are reported on the Errors
+	// channel of the consumer group.
+	PartitionsRevoked(session ConsumerGroupSession, revoked map[string][]int32) error
+}
+
 // ConsumerGroupClaim processes Kafka messages from a given topic and partition within a consumer group.
 type ConsumerGroupClaim interface {
 	// Topic returns the consumed topic name.
@@ -1263,6 +2280,16 @@ type ConsumerGroupClaim interface {
 	// Config.Consumer.Group.Session.Timeout before the topic/partition is eventually
 	// re-assigned to another group member.
 	Messages() <-chan *ConsumerMessage
+
+	// MessagesBatch returns a read channel delivering the messages of the
+	// claim in batches of up to max messages, in order. A batch is delivered
+	// once it holds max messages or maxWait elapsed since its first message
+	// was received, like the max.poll.records setting of the JVM consumer.
+	// Marking the last message of a batch marks the whole batch as consumed.
+	// The channel is closed, after the remaining messages are delivered, when
+	// the Messages channel is. It reads the Messages channel, which must not
+	// be read by the handler, and can only be called once per claim.
+	MessagesBatch(max int, maxWait time.Duration) (<-chan []*ConsumerMessage, error)
 }
 
 type consumerGroupClaim struct {
@@ -1270,18 +2297,32 @@ type consumerGroupClaim struct {
 	partition int32
 	offset    int64
 	PartitionConsumer
+
+	batchLock sync.Mutex
+	batches   chan []*ConsumerMessage // nil unless MessagesBatch was called
 }
 
 func newConsumerGroupClaim(sess *consumerGroupSession, topic string, partition int32, offset int64) (*consumerGroupClaim, error) {
-	pcm, err := sess.parent.consumer.ConsumePartition(topic, partition, offset)
+	// a paused partition is created paused, so that it is never fetched
+	var opts []ConsumeOption
+	if sess.parent.isPaused(topic, partition) {
+		opts = append(opts, WithPaused())
+	}
+	pcm, err := sess.parent.consumer.ConsumePartitionWithOptions(topic, partition, offset, opts...)
 
 	if errors.Is(err, ErrOffsetOutOfRange) && sess.parent.config.Consumer.Group.ResetInvalidOffsets {
-		offset = sess.parent.config.Consumer.Offsets.Initial
-		pcm, err = sess.parent.consumer.ConsumePartition(topic, partition, offset)
+		offsets := sess.parent.config.Consumer.Offsets
+		reset, ok := offsets.OutOfRangeReset.resetOffset(offsets.Initial)
+		if !ok {
+			return nil, &OffsetOutOfRangeError{Topic: topic, Partition: partition, Offset: offset}
+		}
+		offset = reset
+		pcm, err = sess.parent.consumer.ConsumePartitionWithOptions(topic, partition, offset, opts...)
 	}
 	if err != nil {
 		return nil, err
 	}
+	sess.parent.pauseClaim(topic, partition, pcm)
 
 	go func() {
 		for err := range pcm.Errors() {
@@ -1301,6 +2342,64 @@ func (c *consumerGroupClaim) Topic() string        { return c.topic }
 func (c *consumerGroupClaim) Partition() int32     { return c.partition }
 func (c *consumerGroupClaim) InitialOffset() int64 { return c.offset }
 
+func (c *consumerGroupClaim) MessagesBatch(max int, maxWait time.Duration) (<-chan []*ConsumerMessage, error) {
+	if max <= 0 {
+		return nil, ConfigurationError("MessagesBatch max must be > 0")
+	}
+	if maxWait <= 0 {
+		return nil, ConfigurationError("MessagesBatch maxWait must be > 0")
+	}
+
+	c.batchLock.Lock()
+	defer c.batchLock.Unlock()
+
+	if c.batches != nil {
+		return nil, ErrClaimBatchStarted
+	}
+	c.batches = make(chan []*ConsumerMessage)
+	go withRecover(func() { c.batchMessages(c.batches, max, maxWait) })
+	return c.batches, nil
+}
+
+// batchMessages delivers the messages of the claim to batches, see
+// MessagesBatch.
+func (c *consumerGroupClaim) batchMessages(batches chan<- []*ConsumerMessage, max int, maxWait time.Duration) {
+	defer close(batches)
+
+	var batch []*ConsumerMessage
+	timer := time.NewTimer(maxWait)
+	timer.Stop()
+	flush := func() {
+		timer.Stop()
+		batches <- batch
+		batch = nil
+	}
+
+	messages := c.Messages()
+	for {
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				if len(batch) > 0 {
+					flush()
+				}
+				return
+			}
+			if len(batch) == 0 {
+				timer.Reset(maxWait)
+			}
+			batch = append(batch, msg)
+			if len(batch) >= max {
+				flush()
+			}
+		case <-timer.C:
+			if len(batch) > 0 {
+				flush()
+			}
+		}
+	}
+}
+
 // Drains messages and errors, ensures the claim is fully closed.
 func (c *consumerGroupClaim) waitClosed() (errs ConsumerErrors) {
 	go func() {
@@ -1308,6 +2407,16 @@ func (c *consumerGroupClaim) waitClosed() (errs ConsumerErrors) {
 		}
 	}()
 
+	c.batchLock.Lock()
+	if batches := c.batches; batches != nil {
+		// unblock the batching of the messages the handler did not read
+		go func() {
+			for range batches {
+			}
+		}()
+	}
+	c.batchLock.Unlock()
+
 	for err := range c.Errors() {
 		errs = append(errs, err)
 	}
@@ -0,0 +1,225 @@
+package sarama
+
+// Special values of the ConsumerGroupHeartbeatRequest member epoch.
+const (
+	// ConsumerGroupMemberEpochJoin is used by a member joining the group.
+	ConsumerGroupMemberEpochJoin int32 = 0
+	// ConsumerGroupMemberEpochLeave is used by a member leaving the group.
+	ConsumerGroupMemberEpochLeave int32 = -1
+	// ConsumerGroupMemberEpochLeaveStatic is used by a static member leaving
+	// the group temporarily, e.g. while it restarts.
+	ConsumerGroupMemberEpochLeaveStatic int32 = -2
+)
+
+// ConsumerGroupHeartbeatRequest (API key 68) is used by members of a consumer
+// group using the KIP-848 consumer rebalance protocol to join the group,
+// heartbeat, acknowledge their assignment and leave the group. Fields other
+// than GroupId, MemberId and MemberEpoch can be left nil when they did not
+// change since the previous heartbeat.
+type ConsumerGroupHeartbeatRequest struct {
+	// Version 0 is currently only supported
+	Version int16
+	// GroupId contains the group identifier.
+	GroupId string
+	// MemberId contains the member id, empty when joining for the first time.
+	MemberId string
+	// MemberEpoch contains the current member epoch, or one of the special
+	// ConsumerGroupMemberEpoch values.
+	MemberEpoch int32
+	// InstanceId contains the instance id of a static member.
+	InstanceId *string
+	// RackId contains the rack id of the member.
+	RackId *string
+	// RebalanceTimeoutMs contains the maximum time the member may take to
+	// revoke its partitions, or -1 if it did not change.
+	RebalanceTimeoutMs int32
+	// SubscribedTopicNames contains the subscribed topics.
+	SubscribedTopicNames []string
+	// ServerAssignor contains the name of the server side assignor to use.
+	ServerAssignor *string
+	// TopicPartitions contains the partitions currently owned by the member.
+	TopicPartitions []*ConsumerGroupHeartbeatTopicPartitions
+}
+
+// ConsumerGroupHeartbeatTopicPartitions identifies a set of partitions of a
+// topic, by topic id.
+type ConsumerGroupHeartbeatTopicPartitions struct {
+	TopicId    Uuid
+	Partitions []int32
+}
+
+func (t *ConsumerGroupHeartbeatTopicPartitions) encode(pe packetEncoder) error {
+	if err := pe.putRawBytes(t.TopicId[:]); err != nil {
+		return err
+	}
+	if err := pe.putInt32Array(t.Partitions); err != nil {
+		return err
+	}
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (t *ConsumerGroupHeartbeatTopicPartitions) decode(pd packetDecoder) error {
+	topicId, err := pd.getRawBytes(16)
+	if err != nil {
+		return err
+	}
+	copy(t.TopicId[:], topicId)
+	if t.Partitions, err = pd.getInt32Array(); err != nil {
+		return err
+	}
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func encodeConsumerGroupHeartbeatTopicPartitions(pe packetEncoder, topics []*ConsumerGroupHeartbeatTopicPartitions) error {
+	if topics == nil {
+		return pe.putArrayLength(-1)
+	}
+	if err := pe.putArrayLength(len(topics)); err != nil {
+		return err
+	}
+	for _, topic := range topics {
+		if err := topic.encode(pe); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeConsumerGroupHeartbeatTopicPartitions(pd packetDecoder) ([]*ConsumerGroupHeartbeatTopicPartitions, error) {
+	n, err := getNullableCompactArrayLength(pd)
+	if err != nil || n < 0 {
+		return nil, err
+	}
+	topics := make([]*ConsumerGroupHeartbeatTopicPartitions, n)
+	for i := range topics {
+		topics[i] = new(ConsumerGroupHeartbeatTopicPartitions)
+		if err := topics[i].decode(pd); err != nil {
+			return nil, err
+		}
+	}
+	return topics, nil
+}
+
+// getNullableCompactArrayLength reads a compact array length, returning -1
+// for a null array rather than folding it into an empty one.
+func getNullableCompactArrayLength(pd packetDecoder) (int, error) {
+	n, err := pd.getUVarint()
+	if err != nil {
+		return 0, err
+	}
+	if n > uint64(pd.remaining())+1 {
+		return 0, ErrInsufficientData
+	}
+	return int(n) - 1, nil
+}
+
+func (r *ConsumerGroupHeartbeatRequest) setVersion(v int16) {
+	r.Version = v
+}
+
+func (r *ConsumerGroupHeartbeatRequest) encode(pe packetEncoder) error {
+	if err := pe.putString(r.GroupId); err != nil {
+		return err
+	}
+	if err := pe.putString(r.MemberId); err != nil {
+		return err
+	}
+	pe.putInt32(r.MemberEpoch)
+	if err := pe.putNullableString(r.InstanceId); err != nil {
+		return err
+	}
+	if err := pe.putNullableString(r.RackId); err != nil {
+		return err
+	}
+	pe.putInt32(r.RebalanceTimeoutMs)
+	if r.SubscribedTopicNames == nil {
+		if err := pe.putArrayLength(-1); err != nil {
+			return err
+		}
+	} else if err := pe.putStringArray(r.SubscribedTopicNames); err != nil {
+		return err
+	}
+	if err := pe.putNullableString(r.ServerAssignor); err != nil {
+		return err
+	}
+	if err := encodeConsumerGroupHeartbeatTopicPartitions(pe, r.TopicPartitions); err != nil {
+		return err
+	}
+
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *ConsumerGroupHeartbeatRequest) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
+	if r.GroupId, err = pd.getString(); err != nil {
+		return err
+	}
+	if r.MemberId, err = pd.getString(); err != nil {
+		return err
+	}
+	if r.MemberEpoch, err = pd.getInt32(); err != nil {
+		return err
+	}
+	if r.InstanceId, err = pd.getNullableString(); err != nil {
+		return err
+	}
+	if r.RackId, err = pd.getNullableString(); err != nil {
+		return err
+	}
+	if r.RebalanceTimeoutMs, err = pd.getInt32(); err != nil {
+		return err
+	}
+	n, err := getNullableCompactArrayLength(pd)
+	if err != nil {
+		return err
+	}
+	r.SubscribedTopicNames = nil
+	if n >= 0 {
+		r.SubscribedTopicNames = make([]string, n)
+		for i := range r.SubscribedTopicNames {
+			if r.SubscribedTopicNames[i], err = pd.getString(); err != nil {
+				return err
+			}
+		}
+	}
+	if r.ServerAssignor, err = pd.getNullableString(); err != nil {
+		return err
+	}
+	if r.TopicPartitions, err = decodeConsumerGroupHeartbeatTopicPartitions(pd); err != nil {
+		return err
+	}
+
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *ConsumerGroupHeartbeatRequest) key() int16 {
+	return apiKeyConsumerGroupHeartbeat
+}
+
+func (r *ConsumerGroupHeartbeatRequest) version() int16 {
+	return r.Version
+}
+
+func (r *ConsumerGroupHeartbeatRequest) headerVersion() int16 {
+	return 2
+}
+
+func (r *ConsumerGroupHeartbeatRequest) isValidVersion() bool {
+	return r.Version == 0
+}
+
+func (r *ConsumerGroupHeartbeatRequest) isFlexible() bool {
+	return r.isFlexibleVersion(r.Version)
+}
+
+func (r *ConsumerGroupHeartbeatRequest) isFlexibleVersion(version int16) bool {
+	return version >= 0
+}
+
+func (r *ConsumerGroupHeartbeatRequest) requiredVersion() KafkaVersion {
+	return V4_0_0_0
+}
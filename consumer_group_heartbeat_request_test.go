@@ -0,0 +1,65 @@
+//go:build !functional
+
+package sarama
+
+import "testing"
+
+var (
+	consumerGroupHeartbeatJoinRequest = []byte{
+		2, 'g', // GroupId
+		1,          // MemberId ""
+		0, 0, 0, 0, // MemberEpoch 0
+		0,                // InstanceId null
+		0,                // RackId null
+		0, 0, 0xea, 0x60, // RebalanceTimeoutMs 60000
+		2, 2, 't', // SubscribedTopicNames ["t"]
+		0, // ServerAssignor null
+		1, // TopicPartitions []
+		0, // empty tagged fields
+	}
+	consumerGroupHeartbeatRequest = []byte{
+		2, 'g', // GroupId
+		2, 'm', // MemberId
+		0, 0, 0, 3, // MemberEpoch 3
+		2, 'i', // InstanceId
+		2, 'r', // RackId
+		0xff, 0xff, 0xff, 0xff, // RebalanceTimeoutMs -1
+		0,                                                    // SubscribedTopicNames null
+		0,                                                    // ServerAssignor null
+		2,                                                    // TopicPartitions array length 1
+		0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, // TopicId
+		3,          // Partitions array length 2
+		0, 0, 0, 0, // Partition 0
+		0, 0, 0, 1, // Partition 1
+		0, // empty topic tagged fields
+		0, // empty tagged fields
+	}
+)
+
+func TestConsumerGroupHeartbeatRequest(t *testing.T) {
+	request := &ConsumerGroupHeartbeatRequest{
+		GroupId:              "g",
+		MemberEpoch:          ConsumerGroupMemberEpochJoin,
+		RebalanceTimeoutMs:   60000,
+		SubscribedTopicNames: []string{"t"},
+		TopicPartitions:      []*ConsumerGroupHeartbeatTopicPartitions{},
+	}
+	testRequest(t, "join", request, consumerGroupHeartbeatJoinRequest)
+
+	instanceID, rackID := "i", "r"
+	request = &ConsumerGroupHeartbeatRequest{
+		GroupId:            "g",
+		MemberId:           "m",
+		MemberEpoch:        3,
+		InstanceId:         &instanceID,
+		RackId:             &rackID,
+		RebalanceTimeoutMs: -1,
+		TopicPartitions: []*ConsumerGroupHeartbeatTopicPartitions{
+			{
+				TopicId:    Uuid{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+				Partitions: []int32{0, 1},
+			},
+		},
+	}
+	testRequest(t, "heartbeat", request, consumerGroupHeartbeatRequest)
+}
@@ -0,0 +1,158 @@
+package sarama
+
+import "time"
+
+// ConsumerGroupHeartbeatResponse is the response to a ConsumerGroupHeartbeatRequest.
+type ConsumerGroupHeartbeatResponse struct {
+	// Version 0 is currently only supported
+	Version int16
+
+	ThrottleTime time.Duration
+	Err          KError
+	ErrorMessage *string
+	// MemberId contains the member id generated by the coordinator. It is only
+	// provided when the member joins with an empty member id.
+	MemberId *string
+	// MemberEpoch contains the member epoch.
+	MemberEpoch int32
+	// HeartbeatIntervalMs contains the heartbeat interval expected by the
+	// coordinator.
+	HeartbeatIntervalMs int32
+	// Assignment contains the partitions assigned to the member, or nil if it
+	// did not change since the previous heartbeat.
+	Assignment *ConsumerGroupHeartbeatAssignment
+}
+
+// ConsumerGroupHeartbeatAssignment contains the partitions assigned to a member
+// of a consumer group.
+type ConsumerGroupHeartbeatAssignment struct {
+	TopicPartitions []*ConsumerGroupHeartbeatTopicPartitions
+}
+
+func (a *ConsumerGroupHeartbeatAssignment) encode(pe packetEncoder) error {
+	if err := pe.putArrayLength(len(a.TopicPartitions)); err != nil {
+		return err
+	}
+	for _, topic := range a.TopicPartitions {
+		if err := topic.encode(pe); err != nil {
+			return err
+		}
+	}
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (a *ConsumerGroupHeartbeatAssignment) decode(pd packetDecoder) error {
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	a.TopicPartitions = make([]*ConsumerGroupHeartbeatTopicPartitions, n)
+	for i := range a.TopicPartitions {
+		a.TopicPartitions[i] = new(ConsumerGroupHeartbeatTopicPartitions)
+		if err := a.TopicPartitions[i].decode(pd); err != nil {
+			return err
+		}
+	}
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *ConsumerGroupHeartbeatResponse) setVersion(v int16) {
+	r.Version = v
+}
+
+func (r *ConsumerGroupHeartbeatResponse) encode(pe packetEncoder) error {
+	pe.putDurationMs(r.ThrottleTime)
+	pe.putKError(r.Err)
+	if err := pe.putNullableString(r.ErrorMessage); err != nil {
+		return err
+	}
+	if err := pe.putNullableString(r.MemberId); err != nil {
+		return err
+	}
+	pe.putInt32(r.MemberEpoch)
+	pe.putInt32(r.HeartbeatIntervalMs)
+
+	// nullable structs are prefixed with -1 when absent and 1 when present
+	if r.Assignment == nil {
+		pe.putInt8(-1)
+	} else {
+		pe.putInt8(1)
+		if err := r.Assignment.encode(pe); err != nil {
+			return err
+		}
+	}
+
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *ConsumerGroupHeartbeatResponse) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
+	if r.ThrottleTime, err = pd.getDurationMs(); err != nil {
+		return err
+	}
+	if r.Err, err = pd.getKError(); err != nil {
+		return err
+	}
+	if r.ErrorMessage, err = pd.getNullableString(); err != nil {
+		return err
+	}
+	if r.MemberId, err = pd.getNullableString(); err != nil {
+		return err
+	}
+	if r.MemberEpoch, err = pd.getInt32(); err != nil {
+		return err
+	}
+	if r.HeartbeatIntervalMs, err = pd.getInt32(); err != nil {
+		return err
+	}
+
+	present, err := pd.getInt8()
+	if err != nil {
+		return err
+	}
+	r.Assignment = nil
+	if present >= 0 {
+		r.Assignment = new(ConsumerGroupHeartbeatAssignment)
+		if err := r.Assignment.decode(pd); err != nil {
+			return err
+		}
+	}
+
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *ConsumerGroupHeartbeatResponse) key() int16 {
+	return apiKeyConsumerGroupHeartbeat
+}
+
+func (r *ConsumerGroupHeartbeatResponse) version() int16 {
+	return r.Version
+}
+
+func (r *ConsumerGroupHeartbeatResponse) headerVersion() int16 {
+	return 1
+}
+
+func (r *ConsumerGroupHeartbeatResponse) isValidVersion() bool {
+	return r.Version == 0
+}
+
+func (r *ConsumerGroupHeartbeatResponse) isFlexible() bool {
+	return r.isFlexibleVersion(r.Version)
+}
+
+func (r *ConsumerGroupHeartbeatResponse) isFlexibleVersion(version int16) bool {
+	return version >= 0
+}
+
+func (r *ConsumerGroupHeartbeatResponse) requiredVersion() KafkaVersion {
+	return V4_0_0_0
+}
+
+func (r *ConsumerGroupHeartbeatResponse) throttleTime() time.Duration {
+	return r.ThrottleTime
+}
@@ -0,0 +1,67 @@
+//go:build !functional
+
+package sarama
+
+import "testing"
+
+var (
+	consumerGroupHeartbeatResponseNoAssignment = []byte{
+		0, 0, 0, 0, // ThrottleTimeMs
+		0, 0, // ErrorCode
+		0,          // ErrorMessage null
+		0,          // MemberId null
+		0, 0, 0, 2, // MemberEpoch 2
+		0, 0, 0x13, 0x88, // HeartbeatIntervalMs 5000
+		0xff, // Assignment null
+		0,    // empty tagged fields
+	}
+	consumerGroupHeartbeatResponseWithAssignment = []byte{
+		0, 0, 0, 0, // ThrottleTimeMs
+		0, 0, // ErrorCode
+		0,      // ErrorMessage null
+		2, 'm', // MemberId
+		0, 0, 0, 1, // MemberEpoch 1
+		0, 0, 0x13, 0x88, // HeartbeatIntervalMs 5000
+		1,                                                    // Assignment present
+		2,                                                    // TopicPartitions array length 1
+		0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, // TopicId
+		3,          // Partitions array length 2
+		0, 0, 0, 0, // Partition 0
+		0, 0, 0, 1, // Partition 1
+		0, // empty topic tagged fields
+		0, // empty assignment tagged fields
+		0, // empty tagged fields
+	}
+)
+
+func TestConsumerGroupHeartbeatResponse(t *testing.T) {
+	response := &ConsumerGroupHeartbeatResponse{
+		MemberEpoch:         2,
+		HeartbeatIntervalMs: 5000,
+	}
+	testResponse(t, "no assignment", response, consumerGroupHeartbeatResponseNoAssignment)
+
+	memberID := "m"
+	response = &ConsumerGroupHeartbeatResponse{
+		MemberId:            &memberID,
+		MemberEpoch:         1,
+		HeartbeatIntervalMs: 5000,
+		Assignment: &ConsumerGroupHeartbeatAssignment{
+			TopicPartitions: []*ConsumerGroupHeartbeatTopicPartitions{
+				{
+					TopicId:    Uuid{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+					Partitions: []int32{0, 1},
+				},
+			},
+		},
+	}
+	testResponse(t, "with assignment", response, consumerGroupHeartbeatResponseWithAssignment)
+
+	errorMessage := "fenced"
+	response = &ConsumerGroupHeartbeatResponse{
+		Err:          ErrFencedMemberEpoch,
+		ErrorMessage: &errorMessage,
+		MemberEpoch:  -1,
+	}
+	testResponse(t, "error", response, nil)
+}
@@ -2,6 +2,13 @@ package sarama
 
 import "errors"
 
+// The newest versions of the consumer protocol schemas known to sarama, the
+// fields added by later versions are ignored when decoding.
+const (
+	consumerProtocolSubscriptionMaxVersion = 3
+	consumerProtocolAssignmentMaxVersion   = 3
+)
+
 // ConsumerGroupMemberMetadata holds the metadata for consumer group
 // https://github.com/apache/kafka/blob/trunk/clients/src/main/resources/common/message/ConsumerProtocolSubscription.json
 type ConsumerGroupMemberMetadata struct {
@@ -94,6 +101,10 @@ func (m *ConsumerGroupMemberMetadata) decode(pd packetDecoder) (err error) {
 		}
 	}
 
+	if m.Version > consumerProtocolSubscriptionMaxVersion {
+		return skipUnknownFields(pd)
+	}
+
 	return nil
 }
 
@@ -125,6 +136,9 @@ func (m *OwnedPartition) decode(pd packetDecoder) (err error) {
 
 // ConsumerGroupMemberAssignment holds the member assignment for a consume group
 // https://github.com/apache/kafka/blob/trunk/clients/src/main/resources/common/message/ConsumerProtocolAssignment.json
+//
+// Versions 0 to 3 share the same fields, Version tells which one the assignor
+// of the group used.
 type ConsumerGroupMemberAssignment struct {
 	Version  int16
 	Topics   map[string][]int32
@@ -179,5 +193,17 @@ func (m *ConsumerGroupMemberAssignment) decode(pd packetDecoder) (err error) {
 		return
 	}
 
+	if m.Version > consumerProtocolAssignmentMaxVersion {
+		return skipUnknownFields(pd)
+	}
+
 	return nil
 }
+
+// skipUnknownFields discards the fields appended by a consumer protocol
+// version newer than the ones sarama knows about, as newer versions keep the
+// older fields unchanged and only add fields at the end.
+func skipUnknownFields(pd packetDecoder) error {
+	_, err := pd.getRawBytes(pd.remaining())
+	return err
+}
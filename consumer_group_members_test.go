@@ -120,3 +120,80 @@ func TestConsumerGroupMemberAssignment(t *testing.T) {
 		t.Errorf("Encoded data does not match expectation\nexpected: %v\nactual: %v", amt, amt2)
 	}
 }
+
+func TestConsumerGroupMemberAssignmentVersions(t *testing.T) {
+	for _, version := range []int16{0, 1, 2, 3} {
+		buf := append([]byte{0, byte(version)}, groupMemberAssignmentV0[2:]...)
+		gmd := &GroupMemberDescription{MemberAssignment: buf}
+		amt, err := gmd.GetMemberAssignment()
+		if err != nil {
+			t.Fatalf("Failed to decode V%d data: %v", version, err)
+		}
+		expected := &ConsumerGroupMemberAssignment{
+			Version:  version,
+			Topics:   map[string][]int32{"one": {0, 2, 4}},
+			UserData: []byte{0x01, 0x02, 0x03},
+		}
+		if !reflect.DeepEqual(expected, amt) {
+			t.Errorf("Decoded V%d data does not match expectation\nexpected: %v\nactual: %v", version, expected, amt)
+		}
+	}
+
+	// the fields of unknown later versions are ignored
+	buf := append([]byte{0, 4}, groupMemberAssignmentV0[2:]...)
+	buf = append(buf, 0, 0, 0, 42)
+	amt := new(ConsumerGroupMemberAssignment)
+	if err := decode(buf, amt, nil); err != nil {
+		t.Fatal("Failed to decode V4 data", err)
+	}
+	if amt.Version != 4 || !reflect.DeepEqual(map[string][]int32{"one": {0, 2, 4}}, amt.Topics) {
+		t.Errorf("Unexpected V4 decoding %v", amt)
+	}
+
+	// but trailing data is still rejected for known versions
+	buf = append(append([]byte{}, groupMemberAssignmentV0...), 0, 0, 0, 42)
+	if err := decode(buf, new(ConsumerGroupMemberAssignment), nil); err == nil {
+		t.Error("Expected an error decoding V0 data with trailing bytes")
+	}
+}
+
+func TestConsumerGroupMemberMetadataOwnedPartitions(t *testing.T) {
+	rack := "rack"
+	meta := &ConsumerGroupMemberMetadata{
+		Version:         3,
+		Topics:          []string{"one"},
+		UserData:        []byte{0x01},
+		OwnedPartitions: []*OwnedPartition{{Topic: "one", Partitions: []int32{1, 3}}},
+		GenerationID:    7,
+		RackID:          &rack,
+	}
+	buf, err := encode(meta, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gmd := &GroupMemberDescription{MemberMetadata: buf}
+	decoded, err := gmd.GetMemberMetadata()
+	if err != nil {
+		t.Fatal("Failed to decode V3 data", err)
+	}
+	if !reflect.DeepEqual(meta, decoded) {
+		t.Errorf("Decoded data does not match expectation\nexpected: %v\nactual: %v", meta, decoded)
+	}
+
+	// the fields of unknown later versions are ignored
+	buf[1] = 4
+	buf = append(buf, 0, 0, 0, 42)
+	gmd = &GroupMemberDescription{MemberMetadata: buf}
+	if decoded, err = gmd.GetMemberMetadata(); err != nil {
+		t.Fatal("Failed to decode V4 data", err)
+	}
+	meta.Version = 4
+	if !reflect.DeepEqual(meta, decoded) {
+		t.Errorf("Decoded data does not match expectation\nexpected: %v\nactual: %v", meta, decoded)
+	}
+
+	if decoded, err = new(GroupMemberDescription).GetMemberMetadata(); decoded != nil || err != nil {
+		t.Errorf("Expected no metadata, got %v, %v", decoded, err)
+	}
+}
@@ -5,8 +5,10 @@ package sarama
 import (
 	"context"
 	"errors"
+	"fmt"
 	"slices"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -339,6 +341,51 @@ func TestSubscriptionMetadata(t *testing.T) {
 	})
 }
 
+func TestConsumerGroupBalanceRackAware(t *testing.T) {
+	broker := NewMockBroker(t, 1)
+	defer broker.Close()
+
+	rackA, rackB := "a", "b"
+	broker.SetHandlerFuncByMap(map[string]requestHandlerFunc{
+		"MetadataRequest": func(req *request) encoderWithHeader {
+			res := &MetadataResponse{Version: req.body.version(), ControllerID: broker.BrokerID()}
+			res.Brokers = []*Broker{
+				{id: broker.BrokerID(), addr: broker.Addr(), rack: &rackA},
+				{id: 2, addr: "localhost:0", rack: &rackB},
+			}
+			for partition, replica := range []int32{2, 1, 2, 1} {
+				res.AddTopicPartition("my-topic", int32(partition), replica, []int32{replica}, []int32{replica}, nil, ErrNoError)
+			}
+			return res
+		},
+	})
+
+	members := map[string]ConsumerGroupMemberMetadata{
+		"M1": {Version: 3, Topics: []string{"my-topic"}, RackID: &rackA},
+		"M2": {Version: 3, Topics: []string{"my-topic"}, RackID: &rackB},
+	}
+
+	for _, test := range []struct {
+		rackAware bool
+		expected  BalanceStrategyPlan
+	}{
+		{true, BalanceStrategyPlan{"M1": {"my-topic": {1, 3}}, "M2": {"my-topic": {0, 2}}}},
+		{false, BalanceStrategyPlan{"M1": {"my-topic": {0, 1}}, "M2": {"my-topic": {2, 3}}}},
+	} {
+		config := NewTestConfig()
+		config.Version = V2_8_0_0
+		config.Consumer.Group.Rebalance.RackAware = test.rackAware
+		client, err := NewClient([]string{broker.Addr()}, config)
+		assert.NoError(t, err)
+
+		c := &consumerGroup{client: client, config: config}
+		_, _, plan, err := c.balance(NewBalanceStrategyRange(), members)
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, plan)
+		safeClose(t, client)
+	}
+}
+
 // drainHandler is a ConsumerGroupHandler that drains messages without blocking.
 type drainHandler struct{}
 
@@ -606,3 +653,828 @@ func TestConsumerGroupReason(t *testing.T) {
 		assert.Equal(t, "the consumer is being closed", *leaveCapture.reasons[0])
 	})
 }
+
+func TestConsumerGroupMessages(t *testing.T) {
+	config := NewTestConfig()
+	config.ClientID = t.Name()
+	config.Version = V3_2_0_0
+	config.Consumer.Return.Errors = true
+	config.Consumer.Offsets.Initial = OffsetOldest
+	config.Consumer.Offsets.AutoCommit.Interval = time.Hour
+	config.Consumer.Group.Messages.MarkInterval = 10 * time.Millisecond
+
+	broker0 := NewMockBroker(t, 0)
+	defer broker0.Close()
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my-topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my-topic", 0, OffsetOldest, 0).
+			SetOffset("my-topic", 0, OffsetNewest, 2),
+		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).
+			SetCoordinator(CoordinatorGroup, "my-group", broker0),
+		"HeartbeatRequest": NewMockHeartbeatResponse(t),
+		"JoinGroupRequest": NewMockJoinGroupResponse(t).
+			SetGroupProtocol(RangeBalanceStrategyName).
+			SetMemberId("test-member"),
+		"SyncGroupRequest": NewMockSyncGroupResponse(t).SetMemberAssignment(
+			&ConsumerGroupMemberAssignment{
+				Version: 0,
+				Topics:  map[string][]int32{"my-topic": {0}},
+			}),
+		"LeaveGroupRequest": NewMockLeaveGroupResponse(t),
+		"OffsetFetchRequest": NewMockOffsetFetchResponse(t).SetOffset(
+			"my-group", "my-topic", 0, -1, "", ErrNoError,
+		).SetError(ErrNoError),
+		"OffsetCommitRequest": NewMockOffsetCommitResponse(t),
+		"FetchRequest": NewMockFetchResponse(t, 1).
+			SetMessage("my-topic", 0, 0, StringEncoder("foo")).
+			SetMessage("my-topic", 0, 1, StringEncoder("bar")),
+	})
+
+	group, err := NewConsumerGroup([]string{broker0.Addr()}, "my-group", config)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	messages, err := group.Messages(ctx, []string{"my-topic"})
+	assert.NoError(t, err)
+
+	for _, expected := range []string{"foo", "bar"} {
+		select {
+		case msg := <-messages:
+			assert.Equal(t, expected, string(msg.Value))
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for message %q", expected)
+		}
+	}
+
+	cancel()
+	select {
+	case _, ok := <-messages:
+		assert.False(t, ok, "expected the channel to be closed")
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the channel to be closed")
+	}
+	assert.NoError(t, group.Close())
+
+	var committed int64 = -1
+	for _, rr := range broker0.History() {
+		if req, ok := rr.Request.(*OffsetCommitRequest); ok {
+			if block := req.blocks["my-topic"][0]; block != nil {
+				committed = block.offset
+			}
+		}
+	}
+	assert.Equal(t, int64(2), committed, "expected the last received message to be committed")
+}
+
+func TestConsumerGroupMessagesErrors(t *testing.T) {
+	broker0 := NewMockBroker(t, 0)
+	defer broker0.Close()
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()),
+	})
+
+	config := NewTestConfig()
+	config.Version = V3_2_0_0
+	group, err := NewConsumerGroup([]string{broker0.Addr()}, "my-group", config)
+	assert.NoError(t, err)
+
+	_, err = group.Messages(t.Context(), nil)
+	assert.Error(t, err)
+
+	assert.NoError(t, group.Close())
+	_, err = group.Messages(t.Context(), []string{"my-topic"})
+	assert.ErrorIs(t, err, ErrClosedConsumerGroup)
+}
+
+// claimHandler is a ConsumerGroupHandler that reports the start and the end of
+// each ConsumeClaim call.
+// pausedClaimHandler reports the claims of each session and holds them until
+// the session ends.
+type pausedClaimHandler struct {
+	claims chan *consumerGroupClaim
+}
+
+func (h *pausedClaimHandler) Setup(_ ConsumerGroupSession) error   { return nil }
+func (h *pausedClaimHandler) Cleanup(_ ConsumerGroupSession) error { return nil }
+func (h *pausedClaimHandler) ConsumeClaim(sess ConsumerGroupSession, claim ConsumerGroupClaim) error {
+	h.claims <- claim.(*consumerGroupClaim)
+	<-sess.Context().Done()
+	return nil
+}
+
+// mockHeartbeatTrigger returns ErrRebalanceInProgress once to the next
+// heartbeat after each call to rebalance.
+type mockHeartbeatTrigger struct {
+	pending atomic.Bool
+}
+
+func (m *mockHeartbeatTrigger) rebalance() { m.pending.Store(true) }
+
+func (m *mockHeartbeatTrigger) For(reqBody versionedDecoder) encoderWithHeader {
+	resp := &HeartbeatResponse{Version: reqBody.(*HeartbeatRequest).version()}
+	if m.pending.CompareAndSwap(true, false) {
+		resp.Err = ErrRebalanceInProgress
+	}
+	return resp
+}
+
+func TestConsumerGroupPauseSurvivesRebalance(t *testing.T) {
+	config := NewTestConfig()
+	config.ClientID = t.Name()
+	config.Version = V2_0_0_0
+	config.Consumer.Return.Errors = true
+	config.Consumer.Offsets.AutoCommit.Enable = false
+	config.Consumer.Group.Heartbeat.Interval = 50 * time.Millisecond
+
+	broker0 := NewMockBroker(t, 0)
+	defer broker0.Close()
+
+	metadata := NewMockMetadataResponse(t).SetBroker(broker0.Addr(), broker0.BrokerID())
+	offsets := NewMockOffsetResponse(t)
+	offsetFetch := NewMockOffsetFetchResponse(t).SetError(ErrNoError)
+	for partition := range int32(2) {
+		metadata.SetLeader("my-topic", partition, broker0.BrokerID())
+		offsets.SetOffset("my-topic", partition, OffsetOldest, 0).SetOffset("my-topic", partition, OffsetNewest, 0)
+		offsetFetch.SetOffset("my-group", "my-topic", partition, 0, "", ErrNoError)
+	}
+	sync := func(partitions ...int32) *MockSyncGroupResponse {
+		return NewMockSyncGroupResponse(t).SetMemberAssignment(&ConsumerGroupMemberAssignment{
+			Topics: map[string][]int32{"my-topic": partitions},
+		})
+	}
+	heartbeat := &mockHeartbeatTrigger{}
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest":    metadata,
+		"OffsetRequest":      offsets,
+		"OffsetFetchRequest": offsetFetch,
+		"FetchRequest":       NewMockFetchResponse(t, 1),
+		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).
+			SetCoordinator(CoordinatorGroup, "my-group", broker0),
+		"JoinGroupRequest": NewMockJoinGroupResponse(t).SetGroupProtocol(RangeBalanceStrategyName),
+		// partition 0 stays with the member, then moves away and comes back
+		"SyncGroupRequest":  NewMockSequence(sync(0, 1), sync(0, 1), sync(1), sync(0, 1), sync(0, 1)),
+		"HeartbeatRequest":  heartbeat,
+		"LeaveGroupRequest": NewMockLeaveGroupResponse(t),
+	})
+
+	group, err := NewConsumerGroup([]string{broker0.Addr()}, "my-group", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, group)
+
+	h := &pausedClaimHandler{claims: make(chan *consumerGroupClaim, 2)}
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	go func() {
+		for ctx.Err() == nil {
+			_ = group.Consume(ctx, []string{"my-topic"}, h)
+		}
+	}()
+
+	session := func(n int) map[int32]*consumerGroupClaim {
+		t.Helper()
+		claims := make(map[int32]*consumerGroupClaim, n)
+		for len(claims) < n {
+			select {
+			case claim := <-h.claims:
+				claims[claim.Partition()] = claim
+			case <-time.After(5 * time.Second):
+				t.Fatalf("timed out waiting for the claims, got %d of %d", len(claims), n)
+			}
+		}
+		return claims
+	}
+
+	claims := session(2)
+	group.Pause(map[string][]int32{"my-topic": {0}})
+	assert.True(t, claims[0].IsPaused())
+	assert.False(t, claims[1].IsPaused())
+
+	heartbeat.rebalance()
+	claims = session(2)
+	assert.True(t, claims[0].IsPaused(), "pause lost when the partition was assigned to the member again")
+	assert.False(t, claims[1].IsPaused())
+
+	// the pause is forgotten once the partition is assigned elsewhere
+	heartbeat.rebalance()
+	claims = session(1)
+	assert.False(t, claims[1].IsPaused())
+	heartbeat.rebalance()
+	claims = session(2)
+	assert.False(t, claims[0].IsPaused())
+
+	group.PauseAll()
+	heartbeat.rebalance()
+	claims = session(2)
+	assert.True(t, claims[0].IsPaused())
+	assert.True(t, claims[1].IsPaused())
+	group.ResumeAll()
+	assert.False(t, claims[0].IsPaused())
+	assert.False(t, claims[1].IsPaused())
+}
+
+type claimHandler struct {
+	sessions chan ConsumerGroupSession
+	started  chan int32
+	exited   chan int32
+}
+
+func (h *claimHandler) Setup(sess ConsumerGroupSession) error {
+	h.sessions <- sess
+	return nil
+}
+func (h *claimHandler) Cleanup(_ ConsumerGroupSession) error { return nil }
+func (h *claimHandler) ConsumeClaim(_ ConsumerGroupSession, claim ConsumerGroupClaim) error {
+	h.started <- claim.Partition()
+	for range claim.Messages() {
+	}
+	h.exited <- claim.Partition()
+	return nil
+}
+
+// newCooperativeRebalanceTest returns a config and a broker taking a consumer
+// group through a cooperative rebalance: member-1 is assigned partitions 0 to 3
+// of my-topic, then partitions 2 and 3 move to member-2.
+func newCooperativeRebalanceTest(t *testing.T) (*Config, *MockBroker) {
+	t.Helper()
+
+	config := NewTestConfig()
+	config.ClientID = t.Name()
+	config.Version = V2_4_0_0
+	config.Consumer.Return.Errors = true
+	config.Consumer.Offsets.AutoCommit.Enable = false
+	config.Consumer.Group.Heartbeat.Interval = 50 * time.Millisecond
+	config.Consumer.Group.Rebalance.GroupStrategies = []BalanceStrategy{NewBalanceStrategyCooperativeSticky()}
+
+	broker0 := NewMockBroker(t, 0)
+
+	metadata := NewMockMetadataResponse(t).SetBroker(broker0.Addr(), broker0.BrokerID())
+	offsets := NewMockOffsetResponse(t)
+	offsetFetch := NewMockOffsetFetchResponse(t).SetError(ErrNoError)
+	for partition := range int32(4) {
+		metadata.SetLeader("my-topic", partition, broker0.BrokerID())
+		offsets.SetOffset("my-topic", partition, OffsetOldest, 0).SetOffset("my-topic", partition, OffsetNewest, 0)
+		offsetFetch.SetOffset("my-group", "my-topic", partition, 0, "", ErrNoError)
+	}
+	join := func(generation int32) *MockJoinGroupResponse {
+		// another member leads the group and computes the assignments
+		return NewMockJoinGroupResponse(t).
+			SetGroupProtocol(CooperativeStickyBalanceStrategyName).
+			SetGenerationId(generation).
+			SetMemberId("member-1").
+			SetLeaderId("member-2")
+	}
+	sync := func(partitions ...int32) *MockSyncGroupResponse {
+		return NewMockSyncGroupResponse(t).SetMemberAssignment(&ConsumerGroupMemberAssignment{
+			Topics: map[string][]int32{"my-topic": partitions},
+		})
+	}
+
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest":    metadata,
+		"OffsetRequest":      offsets,
+		"OffsetFetchRequest": offsetFetch,
+		"FetchRequest":       NewMockFetchResponse(t, 1),
+		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).
+			SetCoordinator(CoordinatorGroup, "my-group", broker0),
+		// member-2 joins the group, then member-1 rejoins after revoking the
+		// partitions moving to member-2
+		"JoinGroupRequest": NewMockSequence(join(1), join(2), join(3)),
+		"SyncGroupRequest": NewMockSequence(sync(0, 1, 2, 3), sync(0, 1), sync(0, 1)),
+		"HeartbeatRequest": NewMockSequence(
+			NewMockHeartbeatResponse(t),
+			NewMockHeartbeatResponse(t).SetError(ErrRebalanceInProgress),
+			NewMockHeartbeatResponse(t),
+		),
+		"LeaveGroupRequest": NewMockLeaveGroupResponse(t),
+	})
+	return config, broker0
+}
+
+func TestConsumerGroupCooperativeRebalance(t *testing.T) {
+	config, broker0 := newCooperativeRebalanceTest(t)
+	defer broker0.Close()
+
+	group, err := NewConsumerGroup([]string{broker0.Addr()}, "my-group", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, group)
+
+	h := &claimHandler{
+		sessions: make(chan ConsumerGroupSession, 2),
+		started:  make(chan int32, 8),
+		exited:   make(chan int32, 8),
+	}
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- group.Consume(ctx, []string{"my-topic"}, h) }()
+
+	var sess ConsumerGroupSession
+	select {
+	case sess = <-h.sessions:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the session")
+	}
+
+	receive := func(ch chan int32, n int) []int32 {
+		t.Helper()
+		var partitions []int32
+		for range n {
+			select {
+			case p := <-ch:
+				partitions = append(partitions, p)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("timed out after receiving %v", partitions)
+			}
+		}
+		slices.Sort(partitions)
+		return partitions
+	}
+	assert.Equal(t, []int32{0, 1, 2, 3}, receive(h.started, 4))
+	assert.Equal(t, []int32{2, 3}, receive(h.exited, 2), "expected only the moved partitions to be revoked")
+
+	assert.Eventually(t, func() bool { return sess.GenerationID() == 3 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, map[string][]int32{"my-topic": {0, 1}}, sess.Claims())
+	assert.NoError(t, sess.Context().Err(), "expected the session to survive the rebalance")
+
+	// the rebalance neither restarted the kept claims nor started a new session
+	select {
+	case p := <-h.started:
+		t.Errorf("unexpected restart of partition %d", p)
+	case p := <-h.exited:
+		t.Errorf("unexpected exit of partition %d", p)
+	case <-h.sessions:
+		t.Error("unexpected new session")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// the owned partitions were reported when rejoining
+	var owned [][]*OwnedPartition
+	for _, rr := range broker0.History() {
+		req, ok := rr.Request.(*JoinGroupRequest)
+		if !ok {
+			continue
+		}
+		meta := new(ConsumerGroupMemberMetadata)
+		assert.NoError(t, decode(req.OrderedGroupProtocols[0].Metadata, meta, nil))
+		owned = append(owned, meta.OwnedPartitions)
+	}
+	assert.Len(t, owned, 3)
+	assert.Empty(t, owned[0])
+	assert.Equal(t, []*OwnedPartition{{Topic: "my-topic", Partitions: []int32{0, 1, 2, 3}}}, owned[1])
+	assert.Equal(t, []*OwnedPartition{{Topic: "my-topic", Partitions: []int32{0, 1}}}, owned[2])
+
+	cancel()
+	assert.NoError(t, <-done)
+	assert.Equal(t, []int32{0, 1}, receive(h.exited, 2))
+}
+
+// mockConsumerGroupHeartbeatCapture wraps a MockConsumerGroupHeartbeatResponse
+// and records the member epoch of each incoming ConsumerGroupHeartbeatRequest.
+type mockConsumerGroupHeartbeatCapture struct {
+	inner  MockResponse
+	mu     sync.Mutex
+	epochs []int32
+}
+
+func (m *mockConsumerGroupHeartbeatCapture) For(reqBody versionedDecoder) encoderWithHeader {
+	req := reqBody.(*ConsumerGroupHeartbeatRequest)
+	m.mu.Lock()
+	m.epochs = append(m.epochs, req.MemberEpoch)
+	m.mu.Unlock()
+	return m.inner.For(reqBody)
+}
+
+// sessionHandler is a ConsumerGroupHandler that reports each session on setup.
+type sessionHandler struct {
+	sessions chan ConsumerGroupSession
+}
+
+func (h *sessionHandler) Setup(sess ConsumerGroupSession) error {
+	h.sessions <- sess
+	return nil
+}
+func (h *sessionHandler) Cleanup(_ ConsumerGroupSession) error { return nil }
+func (h *sessionHandler) ConsumeClaim(sess ConsumerGroupSession, _ ConsumerGroupClaim) error {
+	<-sess.Context().Done()
+	return nil
+}
+
+func TestConsumerGroupConsumerProtocol(t *testing.T) {
+	config := NewTestConfig()
+	config.ClientID = t.Name()
+	config.Version = V4_0_0_0
+	config.Consumer.Group.Protocol = GroupProtocolConsumer
+	config.Consumer.Group.Rebalance.Retry.Max = 0
+	config.Consumer.Offsets.AutoCommit.Enable = false
+
+	topicID := Uuid{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	heartbeats := &mockConsumerGroupHeartbeatCapture{
+		inner: NewMockConsumerGroupHeartbeatResponse(t).
+			SetMember("test-member", 1).
+			SetAssignment(topicID, 0),
+	}
+
+	broker0 := NewMockBroker(t, 0)
+	defer broker0.Close()
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my-topic", 0, broker0.BrokerID()).
+			SetTopicID("my-topic", topicID),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my-topic", 0, OffsetOldest, 0).
+			SetOffset("my-topic", 0, OffsetNewest, 1),
+		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).
+			SetCoordinator(CoordinatorGroup, "my-group", broker0),
+		"ConsumerGroupHeartbeatRequest": heartbeats,
+		"JoinGroupRequest": NewMockJoinGroupResponse(t).
+			SetGroupProtocol(RangeBalanceStrategyName).
+			SetMemberId("classic-member"),
+		"SyncGroupRequest": NewMockSyncGroupResponse(t).SetMemberAssignment(
+			&ConsumerGroupMemberAssignment{
+				Version: 0,
+				Topics:  map[string][]int32{"my-topic": {0}},
+			}),
+		"HeartbeatRequest":  NewMockHeartbeatResponse(t),
+		"LeaveGroupRequest": NewMockLeaveGroupResponse(t),
+		"OffsetFetchRequest": NewMockOffsetFetchResponse(t).SetOffset(
+			"my-group", "my-topic", 0, 0, "", ErrNoError,
+		).SetError(ErrNoError),
+		"FetchRequest": NewMockFetchResponse(t, 1),
+	})
+
+	group, err := NewConsumerGroup([]string{broker0.Addr()}, "my-group", config)
+	assert.NoError(t, err)
+	defer func() { _ = group.Close() }()
+
+	consume := func() ConsumerGroupSession {
+		t.Helper()
+		h := &sessionHandler{sessions: make(chan ConsumerGroupSession, 1)}
+		ctx, cancel := context.WithCancel(t.Context())
+		done := make(chan error, 1)
+		go func() { done <- group.Consume(ctx, []string{"my-topic"}, h) }()
+
+		var sess ConsumerGroupSession
+		select {
+		case sess = <-h.sessions:
+		case err := <-done:
+			t.Fatalf("Consume returned before the session was set up: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the session")
+		}
+		cancel()
+		assert.NoError(t, <-done)
+		return sess
+	}
+
+	// the assignment computed by the coordinator is claimed by the session
+	sess := consume()
+	assert.Equal(t, "test-member", sess.MemberID())
+	assert.Equal(t, int32(1), sess.GenerationID())
+	assert.Equal(t, map[string][]int32{"my-topic": {0}}, sess.Claims())
+
+	// switching back to the classic protocol leaves the group first
+	assert.Error(t, group.SetGroupProtocol("unknown"))
+	assert.NoError(t, group.SetGroupProtocol(GroupProtocolClassic))
+	sess = consume()
+	assert.Equal(t, "classic-member", sess.MemberID())
+	assert.Equal(t, map[string][]int32{"my-topic": {0}}, sess.Claims())
+
+	heartbeats.mu.Lock()
+	defer heartbeats.mu.Unlock()
+	assert.Equal(t, ConsumerGroupMemberEpochJoin, heartbeats.epochs[0])
+	assert.Equal(t, ConsumerGroupMemberEpochLeave, heartbeats.epochs[len(heartbeats.epochs)-1])
+}
+
+// mockNotCoordinatorHeartbeat wraps a MockConsumerGroupHeartbeatResponse and
+// answers the second heartbeat with ErrNotCoordinatorForConsumer.
+type mockNotCoordinatorHeartbeat struct {
+	inner MockResponse
+	mu    sync.Mutex
+	count int
+}
+
+func (m *mockNotCoordinatorHeartbeat) For(reqBody versionedDecoder) encoderWithHeader {
+	m.mu.Lock()
+	m.count++
+	count := m.count
+	m.mu.Unlock()
+	if count == 2 {
+		req := reqBody.(*ConsumerGroupHeartbeatRequest)
+		return &ConsumerGroupHeartbeatResponse{Version: req.version(), Err: ErrNotCoordinatorForConsumer}
+	}
+	return m.inner.For(reqBody)
+}
+
+func TestConsumerGroupConsumerProtocolHeartbeatNotCoordinator(t *testing.T) {
+	config := NewTestConfig()
+	config.ClientID = t.Name()
+	config.Version = V4_0_0_0
+	config.Consumer.Group.Protocol = GroupProtocolConsumer
+	config.Consumer.Group.Rebalance.Retry.Max = 0
+	config.Consumer.Offsets.AutoCommit.Enable = false
+	config.Metadata.Retry.Backoff = 10 * time.Millisecond
+
+	topicID := Uuid{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	heartbeats := &mockNotCoordinatorHeartbeat{
+		inner: NewMockConsumerGroupHeartbeatResponse(t).
+			SetMember("test-member", 1).
+			SetAssignment(topicID, 0),
+	}
+
+	broker0 := NewMockBroker(t, 0)
+	defer broker0.Close()
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my-topic", 0, broker0.BrokerID()).
+			SetTopicID("my-topic", topicID),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my-topic", 0, OffsetOldest, 0).
+			SetOffset("my-topic", 0, OffsetNewest, 1),
+		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).
+			SetCoordinator(CoordinatorGroup, "my-group", broker0),
+		"ConsumerGroupHeartbeatRequest": heartbeats,
+		"OffsetFetchRequest": NewMockOffsetFetchResponse(t).SetOffset(
+			"my-group", "my-topic", 0, 0, "", ErrNoError,
+		).SetError(ErrNoError),
+		"FetchRequest": NewMockFetchResponse(t, 1),
+	})
+
+	group, err := NewConsumerGroup([]string{broker0.Addr()}, "my-group", config)
+	assert.NoError(t, err)
+	defer safeClose(t, group)
+
+	h := &sessionHandler{sessions: make(chan ConsumerGroupSession, 2)}
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() { done <- group.Consume(ctx, []string{"my-topic"}, h) }()
+
+	var sess ConsumerGroupSession
+	select {
+	case sess = <-h.sessions:
+	case err := <-done:
+		t.Fatalf("Consume returned before the session was set up: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the session")
+	}
+
+	// the heartbeat is retried against the refreshed coordinator and the
+	// session goes on
+	deadline := time.After(5 * time.Second)
+	for {
+		heartbeats.mu.Lock()
+		count := heartbeats.count
+		heartbeats.mu.Unlock()
+		if count >= 4 {
+			break
+		}
+		select {
+		case <-sess.Context().Done():
+			t.Fatalf("session ended by the heartbeat error: %v", context.Cause(sess.Context()))
+		case <-deadline:
+			t.Fatal("timed out waiting for the heartbeats")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	assert.NoError(t, sess.Context().Err())
+
+	findCoordinator := 0
+	for _, rr := range broker0.History() {
+		if _, ok := rr.Request.(*FindCoordinatorRequest); ok {
+			findCoordinator++
+		}
+	}
+	assert.GreaterOrEqual(t, findCoordinator, 2, "expected the coordinator to be refreshed")
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+// rebalanceHandler is a claimHandler reporting the partitions assigned and
+// revoked through ConsumerGroupHandlerV2.
+type rebalanceHandler struct {
+	*claimHandler
+	events chan string
+}
+
+func (h *rebalanceHandler) PartitionsAssigned(_ ConsumerGroupSession, assigned map[string][]int32) error {
+	h.events <- fmt.Sprintf("assigned %v", slices.Sorted(slices.Values(assigned["my-topic"])))
+	return nil
+}
+
+func (h *rebalanceHandler) PartitionsRevoked(_ ConsumerGroupSession, revoked map[string][]int32) error {
+	// the ConsumeClaim goroutines of the revoked partitions have exited
+	h.events <- fmt.Sprintf("revoked %v after %d exits", slices.Sorted(slices.Values(revoked["my-topic"])), len(h.exited))
+	return nil
+}
+
+func TestConsumerGroupHandlerV2(t *testing.T) {
+	config, broker0 := newCooperativeRebalanceTest(t)
+	defer broker0.Close()
+
+	group, err := NewConsumerGroup([]string{broker0.Addr()}, "my-group", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, group)
+
+	h := &rebalanceHandler{
+		claimHandler: &claimHandler{
+			sessions: make(chan ConsumerGroupSession, 2),
+			started:  make(chan int32, 8),
+			exited:   make(chan int32, 8),
+		},
+		events: make(chan string, 8),
+	}
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- group.Consume(ctx, []string{"my-topic"}, h) }()
+
+	receive := func() string {
+		t.Helper()
+		select {
+		case event := <-h.events:
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a rebalance event")
+			return ""
+		}
+	}
+	assert.Equal(t, "assigned [0 1 2 3]", receive())
+	assert.Equal(t, "revoked [2 3] after 2 exits", receive())
+
+	// drain the claims revoked by the rebalance
+	for range 2 {
+		<-h.exited
+	}
+	cancel()
+	assert.NoError(t, <-done)
+	assert.Equal(t, "revoked [0 1] after 2 exits", receive())
+	select {
+	case event := <-h.events:
+		t.Errorf("unexpected event %q", event)
+	default:
+	}
+}
+
+type deadLetterSyncProducer struct {
+	SyncProducer
+	err      error
+	messages []*ProducerMessage
+	// onSend, if set, is called before the message is acknowledged
+	onSend func(*ProducerMessage)
+}
+
+func (p *deadLetterSyncProducer) SendMessage(msg *ProducerMessage) (int32, int64, error) {
+	p.messages = append(p.messages, msg)
+	if p.onSend != nil {
+		p.onSend(msg)
+	}
+	return 0, 0, p.err
+}
+
+func TestConsumerGroupSessionMarkError(t *testing.T) {
+	config := NewTestConfig()
+	session := &consumerGroupSession{parent: &consumerGroup{config: config, groupID: "my-group"}}
+	msg := &ConsumerMessage{
+		Topic:     "my-topic",
+		Partition: 3,
+		Offset:    42,
+		Key:       []byte("key"),
+		Headers:   []*RecordHeader{{Key: []byte("trace"), Value: []byte("abc")}},
+	}
+
+	assert.ErrorIs(t, session.MarkError(msg, errors.New("boom")), ErrNoDeadLetterProducer)
+
+	producer := &deadLetterSyncProducer{err: ErrOutOfBrokers}
+	config.Consumer.Group.DeadLetter.Producer = producer
+	config.Consumer.Group.DeadLetter.TopicFunc = func(msg *ConsumerMessage) string { return msg.Topic + ".dlq" }
+	assert.ErrorIs(t, session.MarkError(msg, errors.New("boom")), ErrOutOfBrokers)
+
+	assert.Len(t, producer.messages, 1)
+	forwarded := producer.messages[0]
+	assert.Equal(t, "my-topic.dlq", forwarded.Topic)
+	assert.Equal(t, ByteEncoder("key"), forwarded.Key)
+	assert.Nil(t, forwarded.Value, "null values must be kept")
+	headers := make(map[string]string, len(forwarded.Headers))
+	for _, header := range forwarded.Headers {
+		headers[string(header.Key)] = string(header.Value)
+	}
+	assert.Equal(t, map[string]string{
+		"trace":                   "abc",
+		DeadLetterHeaderError:     "boom",
+		DeadLetterHeaderTopic:     "my-topic",
+		DeadLetterHeaderPartition: "3",
+		DeadLetterHeaderOffset:    "42",
+		DeadLetterHeaderGroup:     "my-group",
+	}, headers)
+}
+
+func TestConsumerGroupSessionMarkErrorMarksOffset(t *testing.T) {
+	config := NewTestConfig()
+	config.Consumer.Offsets.AutoCommit.Enable = false
+	om, testClient, broker, coordinator := initOffsetManagerWithBackoffFunc(t, 0, nil, config)
+	defer broker.Close()
+	defer coordinator.Close()
+	pom := initPartitionOffsetManager(t, om, coordinator, 10, "")
+
+	producer := &deadLetterSyncProducer{}
+	config.Consumer.Group.DeadLetter.Producer = producer
+	config.Consumer.Group.DeadLetter.TopicFunc = func(msg *ConsumerMessage) string { return msg.Topic + ".dlq" }
+	session := &consumerGroupSession{
+		parent:  &consumerGroup{config: config, groupID: "group"},
+		offsets: om.(*offsetManager),
+	}
+	msg := &ConsumerMessage{Topic: "my_topic", Partition: 0, Offset: 42, Value: []byte("value")}
+
+	// a failed send leaves the offset unmarked
+	producer.err = ErrOutOfBrokers
+	assert.ErrorIs(t, session.MarkError(msg, errors.New("boom")), ErrOutOfBrokers)
+	offset, _ := pom.NextOffset()
+	assert.Equal(t, int64(10), offset)
+
+	// the offset is marked once the record is forwarded
+	producer.err = nil
+	producer.onSend = func(*ProducerMessage) {
+		offset, _ := pom.NextOffset()
+		assert.Equal(t, int64(10), offset, "the offset must not be marked before the send succeeds")
+	}
+	assert.NoError(t, session.MarkError(msg, errors.New("boom")))
+	offset, _ = pom.NextOffset()
+	assert.Equal(t, int64(43), offset)
+
+	assert.Len(t, producer.messages, 2)
+	forwarded := producer.messages[1]
+	assert.Equal(t, "my_topic.dlq", forwarded.Topic)
+	assert.Equal(t, ByteEncoder("value"), forwarded.Value)
+	for key, expected := range map[string]string{
+		DeadLetterHeaderError:  "boom",
+		DeadLetterHeaderOffset: "42",
+		DeadLetterHeaderGroup:  "group",
+	} {
+		value, ok := forwarded.Header([]byte(key))
+		assert.True(t, ok, "missing header %s", key)
+		assert.Equal(t, expected, string(value))
+	}
+
+	// om must be closed before the pom so pom.release() is called before pom.Close()
+	safeClose(t, om)
+	safeClose(t, pom)
+	safeClose(t, testClient)
+}
+
+type batchPartitionConsumer struct {
+	PartitionConsumer
+	messages chan *ConsumerMessage
+}
+
+func (pc *batchPartitionConsumer) Messages() <-chan *ConsumerMessage { return pc.messages }
+
+func TestConsumerGroupClaimMessagesBatch(t *testing.T) {
+	pc := &batchPartitionConsumer{messages: make(chan *ConsumerMessage, 10)}
+	claim := &consumerGroupClaim{topic: "my-topic", partition: 0, PartitionConsumer: pc}
+
+	_, err := claim.MessagesBatch(0, time.Second)
+	assert.Error(t, err)
+
+	batches, err := claim.MessagesBatch(3, 50*time.Millisecond)
+	assert.NoError(t, err)
+	_, err = claim.MessagesBatch(3, 50*time.Millisecond)
+	assert.ErrorIs(t, err, ErrClaimBatchStarted)
+
+	offsets := func(batch []*ConsumerMessage) (offsets []int64) {
+		for _, msg := range batch {
+			offsets = append(offsets, msg.Offset)
+		}
+		return offsets
+	}
+
+	// full batches are delivered right away
+	for offset := int64(0); offset < 4; offset++ {
+		pc.messages <- &ConsumerMessage{Topic: "my-topic", Offset: offset}
+	}
+	assert.Equal(t, []int64{0, 1, 2}, offsets(<-batches))
+
+	// partial batches are delivered once maxWait elapsed
+	start := time.Now()
+	assert.Equal(t, []int64{3}, offsets(<-batches))
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+
+	// the remaining messages are delivered when the claim is closed
+	pc.messages <- &ConsumerMessage{Topic: "my-topic", Offset: 4}
+	close(pc.messages)
+	assert.Equal(t, []int64{4}, offsets(<-batches))
+	_, ok := <-batches
+	assert.False(t, ok, "expected the batches channel to be closed")
+}
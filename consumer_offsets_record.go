@@ -0,0 +1,333 @@
+package sarama
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrUnsupportedOffsetsRecord is returned by DecodeConsumerOffsetsRecord for
+// records whose key or value schema version is not supported, such as the
+// records written by the KIP-848 group coordinator. These can be skipped.
+var ErrUnsupportedOffsetsRecord = errors.New("kafka: unsupported __consumer_offsets record version")
+
+// OffsetsRecord is a record of the internal __consumer_offsets topic as
+// returned by DecodeConsumerOffsetsRecord. It is either an *OffsetCommitRecord
+// or a *GroupMetadataRecord.
+type OffsetsRecord interface {
+	// GroupID returns the consumer group the record belongs to.
+	GroupID() string
+	isOffsetsRecord()
+}
+
+// OffsetCommitRecord is an offset committed by a consumer group for a partition.
+type OffsetCommitRecord struct {
+	Group     string
+	Topic     string
+	Partition int32
+	// Value is nil if the record is a tombstone, i.e. the offset was deleted.
+	Value *OffsetCommitValue
+}
+
+// OffsetCommitValue (Version: 0) => offset metadata commit_timestamp
+// OffsetCommitValue (Version: 1) => offset metadata commit_timestamp expire_timestamp
+// OffsetCommitValue (Version: 2) => offset metadata commit_timestamp
+// OffsetCommitValue (Version: 3) => offset leader_epoch metadata commit_timestamp
+// OffsetCommitValue (Version: 4) => offset leader_epoch metadata commit_timestamp _tagged_fields
+
+// OffsetCommitValue is the value of an OffsetCommitRecord.
+type OffsetCommitValue struct {
+	Version     int16
+	Offset      int64
+	LeaderEpoch int32 // -1 before version 3
+	Metadata    string
+	CommitTime  time.Time
+	ExpireTime  time.Time // only set in version 1
+}
+
+// GroupMetadataRecord is a snapshot of the state of a group.
+type GroupMetadataRecord struct {
+	Group string
+	// Value is nil if the record is a tombstone, i.e. the group was deleted.
+	Value *GroupMetadataValue
+}
+
+// GroupMetadataValue (Version: 0-4) => protocol_type generation protocol leader current_state_timestamp [members]
+//   current_state_timestamp => INT64 (versions 2+)
+//   members => member_id group_instance_id client_id client_host rebalance_timeout session_timeout subscription assignment
+//     group_instance_id => NULLABLE_STRING (versions 3+)
+//     rebalance_timeout => INT32 (versions 1+)
+
+// GroupMetadataValue is the value of a GroupMetadataRecord.
+type GroupMetadataValue struct {
+	Version          int16
+	ProtocolType     string
+	Generation       int32
+	Protocol         string
+	Leader           string
+	CurrentStateTime time.Time // only set from version 2
+	Members          []GroupMetadataMember
+}
+
+// GroupMetadataMember is a member of a group in a GroupMetadataValue.
+type GroupMetadataMember struct {
+	MemberID         string
+	GroupInstanceID  *string
+	ClientID         string
+	ClientHost       string
+	RebalanceTimeout int32 // -1 before version 1
+	SessionTimeout   int32
+	MemberMetadata   []byte
+	MemberAssignment []byte
+}
+
+func (r *OffsetCommitRecord) GroupID() string  { return r.Group }
+func (r *OffsetCommitRecord) isOffsetsRecord() {}
+
+func (r *GroupMetadataRecord) GroupID() string  { return r.Group }
+func (r *GroupMetadataRecord) isOffsetsRecord() {}
+
+// DecodeConsumerOffsetsRecord decodes the key and value of a record consumed
+// from the internal __consumer_offsets topic. A nil value is a tombstone and
+// yields a record with a nil Value.
+func DecodeConsumerOffsetsRecord(key, value []byte) (OffsetsRecord, error) {
+	keyVersion, err := offsetsRecordVersion(key)
+	if err != nil {
+		return nil, err
+	}
+
+	switch keyVersion {
+	case 0, 1:
+		record := &OffsetCommitRecord{}
+		if err := decode(key[2:], (*offsetCommitKey)(record), nil); err != nil {
+			return nil, err
+		}
+		if value == nil {
+			return record, nil
+		}
+		valueVersion, err := offsetsRecordVersion(value)
+		if err != nil {
+			return nil, err
+		}
+		record.Value = &OffsetCommitValue{}
+		if err := versionedDecode(value[2:], record.Value, valueVersion, nil); err != nil {
+			return nil, err
+		}
+		return record, nil
+	case 2:
+		record := &GroupMetadataRecord{}
+		if err := decode(key[2:], (*groupMetadataKey)(record), nil); err != nil {
+			return nil, err
+		}
+		if value == nil {
+			return record, nil
+		}
+		valueVersion, err := offsetsRecordVersion(value)
+		if err != nil {
+			return nil, err
+		}
+		record.Value = &GroupMetadataValue{}
+		if err := versionedDecode(value[2:], record.Value, valueVersion, nil); err != nil {
+			return nil, err
+		}
+		return record, nil
+	default:
+		return nil, fmt.Errorf("%w: key version %d", ErrUnsupportedOffsetsRecord, keyVersion)
+	}
+}
+
+func offsetsRecordVersion(buf []byte) (int16, error) {
+	if len(buf) < 2 {
+		return -1, ErrInsufficientData
+	}
+	return int16(binary.BigEndian.Uint16(buf)), nil
+}
+
+// offsetCommitKey (Version: 0-1) => group topic partition
+type offsetCommitKey OffsetCommitRecord
+
+func (k *offsetCommitKey) decode(pd packetDecoder) (err error) {
+	if k.Group, err = pd.getString(); err != nil {
+		return err
+	}
+	if k.Topic, err = pd.getString(); err != nil {
+		return err
+	}
+	k.Partition, err = pd.getInt32()
+	return err
+}
+
+// groupMetadataKey (Version: 2) => group
+type groupMetadataKey GroupMetadataRecord
+
+func (k *groupMetadataKey) decode(pd packetDecoder) (err error) {
+	k.Group, err = pd.getString()
+	return err
+}
+
+func (v *OffsetCommitValue) decode(pd packetDecoder, version int16) (err error) {
+	if version < 0 || version > 4 {
+		return fmt.Errorf("%w: offset commit value version %d", ErrUnsupportedOffsetsRecord, version)
+	}
+	v.Version = version
+
+	if v.Offset, err = pd.getInt64(); err != nil {
+		return err
+	}
+
+	v.LeaderEpoch = -1
+	if version >= 3 {
+		if v.LeaderEpoch, err = pd.getInt32(); err != nil {
+			return err
+		}
+	}
+
+	if v.Metadata, err = pd.getString(); err != nil {
+		return err
+	}
+
+	if err = (Timestamp{&v.CommitTime}).decode(pd); err != nil {
+		return err
+	}
+
+	if version == 1 {
+		if err = (Timestamp{&v.ExpireTime}).decode(pd); err != nil {
+			return err
+		}
+	}
+
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (v *OffsetCommitValue) isFlexible() bool {
+	return v.isFlexibleVersion(v.Version)
+}
+
+func (v *OffsetCommitValue) isFlexibleVersion(version int16) bool {
+	return version >= 4
+}
+
+func (v *GroupMetadataValue) decode(pd packetDecoder, version int16) (err error) {
+	if version < 0 || version > 4 {
+		return fmt.Errorf("%w: group metadata value version %d", ErrUnsupportedOffsetsRecord, version)
+	}
+	v.Version = version
+
+	if v.ProtocolType, err = pd.getString(); err != nil {
+		return err
+	}
+
+	if v.Generation, err = pd.getInt32(); err != nil {
+		return err
+	}
+
+	protocol, err := pd.getNullableString()
+	if err != nil {
+		return err
+	}
+	if protocol != nil {
+		v.Protocol = *protocol
+	}
+
+	leader, err := pd.getNullableString()
+	if err != nil {
+		return err
+	}
+	if leader != nil {
+		v.Leader = *leader
+	}
+
+	if version >= 2 {
+		if err = (Timestamp{&v.CurrentStateTime}).decode(pd); err != nil {
+			return err
+		}
+	}
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		v.Members = make([]GroupMetadataMember, n)
+		for i := range v.Members {
+			if err = v.Members[i].decode(pd, version); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (v *GroupMetadataValue) isFlexible() bool {
+	return v.isFlexibleVersion(v.Version)
+}
+
+func (v *GroupMetadataValue) isFlexibleVersion(version int16) bool {
+	return version >= 4
+}
+
+func (m *GroupMetadataMember) decode(pd packetDecoder, version int16) (err error) {
+	if m.MemberID, err = pd.getString(); err != nil {
+		return err
+	}
+
+	if version >= 3 {
+		if m.GroupInstanceID, err = pd.getNullableString(); err != nil {
+			return err
+		}
+	}
+
+	if m.ClientID, err = pd.getString(); err != nil {
+		return err
+	}
+
+	if m.ClientHost, err = pd.getString(); err != nil {
+		return err
+	}
+
+	m.RebalanceTimeout = -1
+	if version >= 1 {
+		if m.RebalanceTimeout, err = pd.getInt32(); err != nil {
+			return err
+		}
+	}
+
+	if m.SessionTimeout, err = pd.getInt32(); err != nil {
+		return err
+	}
+
+	if m.MemberMetadata, err = pd.getBytes(); err != nil {
+		return err
+	}
+
+	if m.MemberAssignment, err = pd.getBytes(); err != nil {
+		return err
+	}
+
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+// GetMemberMetadata decodes the subscription of a member of a "consumer" group.
+func (m *GroupMetadataMember) GetMemberMetadata() (*ConsumerGroupMemberMetadata, error) {
+	if len(m.MemberMetadata) == 0 {
+		return nil, nil
+	}
+	metadata := new(ConsumerGroupMemberMetadata)
+	err := decode(m.MemberMetadata, metadata, nil)
+	return metadata, err
+}
+
+// GetMemberAssignment decodes the assignment of a member of a "consumer" group.
+func (m *GroupMetadataMember) GetMemberAssignment() (*ConsumerGroupMemberAssignment, error) {
+	if len(m.MemberAssignment) == 0 {
+		return nil, nil
+	}
+	assignment := new(ConsumerGroupMemberAssignment)
+	err := decode(m.MemberAssignment, assignment, nil)
+	return assignment, err
+}
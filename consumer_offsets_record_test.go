@@ -0,0 +1,202 @@
+//go:build !functional
+
+package sarama
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var (
+	offsetCommitKeyV1 = []byte{
+		0, 1, // version
+		0, 2, 'g', '1', // group
+		0, 2, 't', '1', // topic
+		0, 0, 0, 3, // partition
+	}
+
+	offsetCommitValueV1 = []byte{
+		0, 1, // version
+		0, 0, 0, 0, 0, 0, 0, 42, // offset
+		0, 4, 'm', 'e', 't', 'a', // metadata
+		0, 0, 0, 0, 0, 0, 3, 232, // commit timestamp
+		0, 0, 0, 0, 0, 0, 7, 208, // expire timestamp
+	}
+
+	offsetCommitValueV3 = []byte{
+		0, 3, // version
+		0, 0, 0, 0, 0, 0, 0, 42, // offset
+		0, 0, 0, 7, // leader epoch
+		0, 0, // metadata
+		0, 0, 0, 0, 0, 0, 3, 232, // commit timestamp
+	}
+
+	offsetCommitValueV4 = []byte{
+		0, 4, // version
+		0, 0, 0, 0, 0, 0, 0, 42, // offset
+		0, 0, 0, 7, // leader epoch
+		1,                        // metadata
+		0, 0, 0, 0, 0, 0, 3, 232, // commit timestamp
+		1,     // tagged fields
+		0, 16, // topic id tag and length
+		0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, // topic id
+	}
+
+	groupMetadataKeyV2 = []byte{
+		0, 2, // version
+		0, 2, 'g', '1', // group
+	}
+
+	groupMetadataValueV3 = []byte{
+		0, 3, // version
+		0, 8, 'c', 'o', 'n', 's', 'u', 'm', 'e', 'r', // protocol type
+		0, 0, 0, 5, // generation
+		0, 5, 'r', 'a', 'n', 'g', 'e', // protocol
+		0, 2, 'm', '1', // leader
+		0, 0, 0, 0, 0, 0, 3, 232, // current state timestamp
+		0, 0, 0, 1, // members
+		0, 2, 'm', '1', // member id
+		255, 255, // group instance id
+		0, 2, 'c', '1', // client id
+		0, 2, 'h', '1', // client host
+		0, 0, 234, 96, // rebalance timeout
+		0, 0, 39, 16, // session timeout
+		0, 0, 0, 13, 0, 0, 0, 0, 0, 1, 0, 1, 't', 255, 255, 255, 255, // subscription
+		0, 0, 0, 0, // assignment
+	}
+
+	groupMetadataValueV0 = []byte{
+		0, 0, // version
+		0, 0, // protocol type
+		0, 0, 0, 1, // generation
+		255, 255, // protocol
+		255, 255, // leader
+		0, 0, 0, 0, // members
+	}
+)
+
+func TestDecodeConsumerOffsetsRecordOffsetCommit(t *testing.T) {
+	tests := []struct {
+		name  string
+		value []byte
+		want  *OffsetCommitValue
+	}{
+		{"v1", offsetCommitValueV1, &OffsetCommitValue{
+			Version:     1,
+			Offset:      42,
+			LeaderEpoch: -1,
+			Metadata:    "meta",
+			CommitTime:  time.Unix(1, 0),
+			ExpireTime:  time.Unix(2, 0),
+		}},
+		{"v3", offsetCommitValueV3, &OffsetCommitValue{
+			Version:     3,
+			Offset:      42,
+			LeaderEpoch: 7,
+			CommitTime:  time.Unix(1, 0),
+		}},
+		{"v4 with tagged fields", offsetCommitValueV4, &OffsetCommitValue{
+			Version:     4,
+			Offset:      42,
+			LeaderEpoch: 7,
+			CommitTime:  time.Unix(1, 0),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record, err := DecodeConsumerOffsetsRecord(offsetCommitKeyV1, tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			commit, ok := record.(*OffsetCommitRecord)
+			if !ok {
+				t.Fatalf("expected an *OffsetCommitRecord, got %T", record)
+			}
+			if commit.GroupID() != "g1" || commit.Topic != "t1" || commit.Partition != 3 {
+				t.Errorf("unexpected key %+v", commit)
+			}
+			if !reflect.DeepEqual(commit.Value, tt.want) {
+				t.Errorf("expected %+v, got %+v", tt.want, commit.Value)
+			}
+		})
+	}
+}
+
+func TestDecodeConsumerOffsetsRecordGroupMetadata(t *testing.T) {
+	record, err := DecodeConsumerOffsetsRecord(groupMetadataKeyV2, groupMetadataValueV3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	group, ok := record.(*GroupMetadataRecord)
+	if !ok {
+		t.Fatalf("expected a *GroupMetadataRecord, got %T", record)
+	}
+	if group.GroupID() != "g1" {
+		t.Errorf("expected group g1, got %s", group.GroupID())
+	}
+
+	value := group.Value
+	if value.ProtocolType != "consumer" || value.Generation != 5 || value.Protocol != "range" ||
+		value.Leader != "m1" || !value.CurrentStateTime.Equal(time.Unix(1, 0)) {
+		t.Errorf("unexpected group metadata %+v", value)
+	}
+	if len(value.Members) != 1 {
+		t.Fatalf("expected 1 member, got %d", len(value.Members))
+	}
+	member := value.Members[0]
+	if member.MemberID != "m1" || member.GroupInstanceID != nil || member.ClientID != "c1" ||
+		member.ClientHost != "h1" || member.RebalanceTimeout != 60000 || member.SessionTimeout != 10000 {
+		t.Errorf("unexpected member %+v", member)
+	}
+	metadata, err := member.GetMemberMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(metadata.Topics, []string{"t"}) {
+		t.Errorf("expected subscription to [t], got %v", metadata.Topics)
+	}
+
+	record, err = DecodeConsumerOffsetsRecord(groupMetadataKeyV2, groupMetadataValueV0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value = record.(*GroupMetadataRecord).Value
+	if value.Generation != 1 || value.Protocol != "" || value.Leader != "" || len(value.Members) != 0 {
+		t.Errorf("unexpected empty group metadata %+v", value)
+	}
+}
+
+func TestDecodeConsumerOffsetsRecordTombstone(t *testing.T) {
+	record, err := DecodeConsumerOffsetsRecord(offsetCommitKeyV1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commit := record.(*OffsetCommitRecord); commit.Value != nil || commit.Partition != 3 {
+		t.Errorf("expected an offset commit tombstone, got %+v", commit)
+	}
+
+	record, err = DecodeConsumerOffsetsRecord(groupMetadataKeyV2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if group := record.(*GroupMetadataRecord); group.Value != nil || group.Group != "g1" {
+		t.Errorf("expected a group metadata tombstone, got %+v", group)
+	}
+}
+
+func TestDecodeConsumerOffsetsRecordErrors(t *testing.T) {
+	if _, err := DecodeConsumerOffsetsRecord([]byte{0, 3, 0, 2, 'g', '1'}, nil); !errors.Is(err, ErrUnsupportedOffsetsRecord) {
+		t.Errorf("expected ErrUnsupportedOffsetsRecord for an unknown key version, got %v", err)
+	}
+	if _, err := DecodeConsumerOffsetsRecord(offsetCommitKeyV1, []byte{0, 5}); !errors.Is(err, ErrUnsupportedOffsetsRecord) {
+		t.Errorf("expected ErrUnsupportedOffsetsRecord for an unknown value version, got %v", err)
+	}
+	if _, err := DecodeConsumerOffsetsRecord([]byte{0}, nil); !errors.Is(err, ErrInsufficientData) {
+		t.Errorf("expected ErrInsufficientData for a truncated key, got %v", err)
+	}
+	if _, err := DecodeConsumerOffsetsRecord(offsetCommitKeyV1, offsetCommitValueV3[:10]); !errors.Is(err, ErrInsufficientData) {
+		t.Errorf("expected ErrInsufficientData for a truncated value, got %v", err)
+	}
+}
@@ -5,12 +5,16 @@ package sarama
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"reflect"
 	"runtime"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -89,6 +93,470 @@ func TestConsumerOffsetManual(t *testing.T) {
 	broker0.Close()
 }
 
+// A partition consumer started from a time begins at the offset the broker
+// returns for it, or at the newest offset if the time is beyond the log end.
+func TestConsumePartitionFromTime(t *testing.T) {
+	// Given
+	broker0 := NewMockBroker(t, 0)
+	defer broker0.Close()
+
+	startTime := time.Unix(1700000000, 0)
+	timestamp := startTime.UnixMilli()
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()).
+			SetLeader("my_topic", 1, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetOldest, 0).
+			SetOffset("my_topic", 0, OffsetNewest, 2345).
+			SetOffset("my_topic", 0, timestamp, 1234).
+			SetOffset("my_topic", 1, OffsetOldest, 0).
+			SetOffset("my_topic", 1, OffsetNewest, 500).
+			SetOffset("my_topic", 1, timestamp, -1),
+		"FetchRequest": NewMockFetchResponse(t, 1).
+			SetMessage("my_topic", 0, 1234, testMsg).
+			SetMessage("my_topic", 1, 500, testMsg),
+	})
+
+	master, err := NewConsumer([]string{broker0.Addr()}, NewTestConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, master)
+
+	for partition, expectedOffset := range map[int32]int64{0: 1234, 1: 500} {
+		// When
+		consumer, err := master.ConsumePartitionFromTime("my_topic", partition, startTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Then
+		select {
+		case message := <-consumer.Messages():
+			assertMessageOffset(t, message, expectedOffset)
+		case err := <-consumer.Errors():
+			t.Error(err)
+		}
+		safeClose(t, consumer)
+	}
+}
+
+// The lag reported by a partition consumer tracks the distance between the
+// next offset to be fetched and the high water mark of the last fetch response.
+func TestConsumerLag(t *testing.T) {
+	// Given
+	broker0 := NewMockBroker(t, 0)
+
+	manualOffset := int64(1234)
+	offsetNewest := int64(2345)
+	offsetNewestAfterFetchRequest := int64(3456)
+
+	mockFetchResponse := NewMockFetchResponse(t, 1)
+	for i := int64(0); i < 10; i++ {
+		mockFetchResponse.SetMessage("my_topic", 0, i+manualOffset, testMsg)
+	}
+	mockFetchResponse.SetHighWaterMark("my_topic", 0, offsetNewestAfterFetchRequest)
+
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetOldest, 0).
+			SetOffset("my_topic", 0, OffsetNewest, offsetNewest),
+		"FetchRequest": mockFetchResponse,
+	})
+
+	config := NewTestConfig()
+	config.MetricRegistry = metrics.NewRegistry()
+
+	// When
+	master, err := NewConsumer([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	consumer, err := master.ConsumePartition("my_topic", 0, manualOffset)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	if lag := consumer.Lag(); lag != offsetNewest-manualOffset {
+		t.Errorf("Expected lag %d, found %d", offsetNewest-manualOffset, lag)
+	}
+	for i := int64(0); i < 10; i++ {
+		select {
+		case message := <-consumer.Messages():
+			assertMessageOffset(t, message, i+manualOffset)
+		case err := <-consumer.Errors():
+			t.Error(err)
+		}
+	}
+
+	expectedLag := offsetNewestAfterFetchRequest - (manualOffset + 10)
+	if lag := consumer.Lag(); lag != expectedLag {
+		t.Errorf("Expected lag %d, found %d", expectedLag, lag)
+	}
+	gauge, ok := config.MetricRegistry.Get("consumer-lag-for-topic-my_topic-partition-0").(metrics.Gauge)
+	if !ok {
+		t.Fatal("Expected consumer-lag gauge to be registered")
+	}
+	if value := gauge.Value(); value != expectedLag {
+		t.Errorf("Expected consumer-lag gauge %d, found %d", expectedLag, value)
+	}
+
+	safeClose(t, consumer)
+	if config.MetricRegistry.Get("consumer-lag-for-topic-my_topic-partition-0") != nil {
+		t.Error("Expected consumer-lag gauge to be unregistered on close")
+	}
+	safeClose(t, master)
+	broker0.Close()
+}
+
+// The fetch metrics are recorded per topic from the decoded fetch responses
+// and unregistered when the consumer is closed.
+func TestConsumerFetchMetrics(t *testing.T) {
+	// Given
+	value := StringEncoder(strings.Repeat("compressible ", 64))
+	fetchResponse := &FetchResponse{Version: 5}
+	for i := int64(0); i < 3; i++ {
+		fetchResponse.AddRecord("my_topic", 0, nil, value, i)
+	}
+	fetchResponse.SetLastOffsetDelta("my_topic", 0, 2)
+	fetchResponse.SetLastStableOffset("my_topic", 0, 3)
+	batch := fetchResponse.GetBlock("my_topic", 0).RecordsSet[0].RecordBatch
+	batch.Codec = CompressionGZIP
+	batch.CompressionLevel = CompressionLevelDefault
+
+	broker0 := NewMockBroker(t, 0)
+	defer broker0.Close()
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetNewest, 1234).
+			SetOffset("my_topic", 0, OffsetOldest, 0),
+		"FetchRequest": NewMockSequence(fetchResponse, &FetchResponse{Version: 5}),
+	})
+
+	config := NewTestConfig()
+	config.Version = V0_11_0_0
+	config.MetricRegistry = metrics.NewRegistry()
+	master, err := NewConsumer([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When
+	consumer, err := master.ConsumePartition("my_topic", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := int64(0); i < 3; i++ {
+		select {
+		case message := <-consumer.Messages():
+			assertMessageOffset(t, message, i)
+		case err := <-consumer.Errors():
+			t.Fatal(err)
+		}
+	}
+
+	// Then
+	histogram := func(name string) metrics.Histogram {
+		t.Helper()
+		h, ok := config.MetricRegistry.Get(name).(metrics.Histogram)
+		if !ok {
+			t.Fatalf("Expected %s histogram to be registered", name)
+		}
+		return h
+	}
+	for _, name := range []string{"fetch-response-bytes", "fetch-response-bytes-for-topic-my_topic"} {
+		if h := histogram(name); h.Count() == 0 || h.Max() <= 0 {
+			t.Errorf("Expected %s to record the response size, found count %d max %d", name, h.Count(), h.Max())
+		}
+	}
+	for _, name := range []string{"fetch-records-per-batch", "fetch-records-per-batch-for-topic-my_topic"} {
+		if h := histogram(name); h.Count() != 1 || h.Max() != 3 {
+			t.Errorf("Expected %s to record one batch of 3 records, found count %d max %d", name, h.Count(), h.Max())
+		}
+	}
+	for _, name := range []string{"decompression-ratio", "decompression-ratio-for-topic-my_topic"} {
+		if h := histogram(name); h.Count() != 1 || h.Max() <= 100 {
+			t.Errorf("Expected %s to record a ratio above 100, found count %d max %d", name, h.Count(), h.Max())
+		}
+	}
+
+	safeClose(t, consumer)
+	safeClose(t, master)
+	for _, name := range []string{
+		"fetch-response-bytes", "fetch-response-bytes-for-topic-my_topic",
+		"fetch-records-per-batch", "fetch-records-per-batch-for-topic-my_topic",
+		"decompression-ratio", "decompression-ratio-for-topic-my_topic",
+	} {
+		if config.MetricRegistry.Get(name) != nil {
+			t.Errorf("Expected %s to be unregistered on close", name)
+		}
+	}
+}
+
+// The lag is also refreshed from fetch responses that carry a partition error.
+func TestConsumerLagOnPartitionError(t *testing.T) {
+	child := &partitionConsumer{
+		topic:     "my_topic",
+		partition: 0,
+		conf:      NewTestConfig(),
+		offset:    10,
+	}
+
+	response := new(FetchResponse)
+	response.AddError("my_topic", 0, ErrNotLeaderForPartition)
+	response.GetBlock("my_topic", 0).HighWaterMarkOffset = 50
+	if _, err := child.parseResponse(response); !errors.Is(err, ErrNotLeaderForPartition) {
+		t.Fatalf("Expected ErrNotLeaderForPartition, found %v", err)
+	}
+	if lag := child.Lag(); lag != 40 {
+		t.Errorf("Expected lag 40, found %d", lag)
+	}
+
+	// an invalid high water mark leaves the previous lag in place
+	response.GetBlock("my_topic", 0).HighWaterMarkOffset = -1
+	if _, err := child.parseResponse(response); !errors.Is(err, ErrNotLeaderForPartition) {
+		t.Fatalf("Expected ErrNotLeaderForPartition, found %v", err)
+	}
+	if lag := child.Lag(); lag != 40 {
+		t.Errorf("Expected lag 40, found %d", lag)
+	}
+}
+
+// With Consumer.Return.EOF, the partition consumer signals once each time it
+// catches up with the high water mark.
+func TestConsumerPartitionEOF(t *testing.T) {
+	// Given
+	broker0 := NewMockBroker(t, 0)
+	defer broker0.Close()
+
+	fetchResponse := NewMockFetchResponse(t, 1).
+		SetMessage("my_topic", 0, 0, testMsg).
+		SetMessage("my_topic", 0, 1, testMsg).
+		SetHighWaterMark("my_topic", 0, 2)
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetOldest, 0).
+			SetOffset("my_topic", 0, OffsetNewest, 2),
+		"FetchRequest": fetchResponse,
+	})
+
+	config := NewTestConfig()
+	config.Consumer.Return.EOF = true
+	master, err := NewConsumer([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, master)
+
+	// When
+	consumer, err := master.ConsumePartition("my_topic", 0, OffsetOldest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectEOF := func(offset int64) {
+		t.Helper()
+		select {
+		case eof := <-consumer.EOFs():
+			if eof != offset {
+				t.Errorf("Expected EOF at offset %d, got %d", offset, eof)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected EOF at offset %d", offset)
+		}
+	}
+
+	// Then
+	for i := int64(0); i < 2; i++ {
+		assertMessageOffset(t, <-consumer.Messages(), i)
+	}
+	expectEOF(2)
+
+	// the following empty fetches do not signal again
+	wait := time.After(100 * time.Millisecond)
+	select {
+	case eof := <-consumer.EOFs():
+		t.Errorf("Unexpected EOF at offset %d", eof)
+	case <-wait:
+	}
+
+	// new records re-arm the signal
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"FetchRequest": NewMockFetchResponse(t, 1).
+			SetMessage("my_topic", 0, 2, testMsg).
+			SetHighWaterMark("my_topic", 0, 3),
+	})
+	assertMessageOffset(t, <-consumer.Messages(), 2)
+	expectEOF(3)
+
+	safeClose(t, consumer)
+}
+
+func TestConsumerPartitionEOFDoesNotBlock(t *testing.T) {
+	config := NewTestConfig()
+	config.Consumer.Return.EOF = true
+	child := &partitionConsumer{conf: config, eofs: make(chan int64, 1), eofOffset: -1}
+
+	// EOFs is never read, only the latest offset is kept
+	for _, offset := range []int64{1, 2, 3} {
+		child.offset = offset
+		child.maybeSendEOF(offset)
+	}
+	if eof := <-child.eofs; eof != 3 {
+		t.Errorf("Expected EOF at offset 3, got %d", eof)
+	}
+}
+
+// A fetch response reporting a diverging epoch makes the partition consumer
+// look up the truncation offset and, by default, resume from there.
+func TestConsumerLogTruncation(t *testing.T) {
+	for _, reset := range []bool{true, false} {
+		t.Run(fmt.Sprintf("reset=%v", reset), func(t *testing.T) {
+			// Given
+			broker0 := NewMockBroker(t, 0)
+			defer broker0.Close()
+
+			fetched := &FetchResponse{Version: 12}
+			for i := int64(0); i < 4; i++ {
+				fetched.AddRecord("my_topic", 0, nil, testMsg, i)
+			}
+			fetched.GetBlock("my_topic", 0).HighWaterMarkOffset = 4
+			for _, records := range fetched.GetBlock("my_topic", 0).RecordsSet {
+				records.RecordBatch.PartitionLeaderEpoch = 1
+			}
+
+			diverged := &FetchResponse{Version: 12}
+			diverged.AddError("my_topic", 0, ErrNoError)
+			diverged.GetBlock("my_topic", 0).HighWaterMarkOffset = 4
+			diverged.GetBlock("my_topic", 0).DivergingEpoch = &FetchEpochEndOffset{Epoch: 2, EndOffset: 3}
+
+			broker0.SetHandlerByMap(map[string]MockResponse{
+				"MetadataRequest": NewMockMetadataResponse(t).
+					SetBroker(broker0.Addr(), broker0.BrokerID()).
+					SetLeader("my_topic", 0, broker0.BrokerID()),
+				"OffsetRequest": NewMockOffsetResponse(t).
+					SetOffset("my_topic", 0, OffsetOldest, 0).
+					SetOffset("my_topic", 0, OffsetNewest, 4),
+				"FetchRequest": NewMockSequence(
+					fetched,
+					diverged,
+					NewMockFetchResponse(t, 4).
+						SetMessage("my_topic", 0, 2, testMsg).
+						SetMessage("my_topic", 0, 3, testMsg).
+						SetHighWaterMark("my_topic", 0, 4),
+				),
+				"OffsetForLeaderEpochRequest": NewMockOffsetForLeaderEpochResponse(t).
+					SetEndOffset("my_topic", 0, 1, 2),
+			})
+
+			config := NewTestConfig()
+			config.Version = V2_7_0_0
+			config.Consumer.Return.Errors = true
+			config.Consumer.Group.ResetOnTruncation = reset
+			master, err := NewConsumer([]string{broker0.Addr()}, config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer safeClose(t, master)
+
+			// When
+			consumer, err := master.ConsumePartition("my_topic", 0, OffsetOldest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := int64(0); i < 4; i++ {
+				assertMessageOffset(t, <-consumer.Messages(), i)
+			}
+
+			// Then
+			var truncationErr *LogTruncationError
+			select {
+			case consErr := <-consumer.Errors():
+				if !errors.As(consErr, &truncationErr) {
+					t.Fatalf("Expected a LogTruncationError, got %v", consErr)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Expected a LogTruncationError")
+			}
+			if truncationErr.Offset != 4 || truncationErr.TruncationOffset != 2 {
+				t.Errorf("Expected truncation from offset 4 to 2, got %+v", truncationErr)
+			}
+
+			var epochRequest *OffsetForLeaderEpochRequest
+			for _, rr := range broker0.History() {
+				if req, ok := rr.Request.(*OffsetForLeaderEpochRequest); ok {
+					epochRequest = req
+				}
+			}
+			if epochRequest == nil {
+				t.Fatal("Expected an OffsetForLeaderEpochRequest")
+			}
+			if epoch := epochRequest.Topics[0].Partitions[0].LeaderEpoch; epoch != 1 {
+				t.Errorf("Expected the last fetched epoch 1 to be looked up, got %d", epoch)
+			}
+
+			if reset {
+				for i := int64(2); i < 4; i++ {
+					assertMessageOffset(t, <-consumer.Messages(), i)
+				}
+				safeClose(t, consumer)
+				return
+			}
+			select {
+			case msg, ok := <-consumer.Messages():
+				if ok {
+					t.Errorf("Unexpected message at offset %d after the truncation", msg.Offset)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Expected the partition consumer to shut down")
+			}
+		})
+	}
+}
+
+// The topic high water mark and lag sum those of the consumed partitions.
+func TestConsumerTopicHighWaterMarkAndLagTotal(t *testing.T) {
+	newChild := func(partition int32, hwm, lag int64) *partitionConsumer {
+		child := &partitionConsumer{topic: "my_topic", partition: partition}
+		child.highWaterMarkOffset.Store(hwm)
+		child.lag.Store(lag)
+		return child
+	}
+	c := &consumer{children: map[string]map[int32]*partitionConsumer{
+		"my_topic": {
+			0: newChild(0, 100, 10),
+			1: newChild(1, 250, 0),
+			3: newChild(3, 40, 5),
+		},
+		"other_topic": {
+			0: newChild(0, 1000, 1000),
+		},
+	}}
+
+	if hwm := c.TopicHighWaterMark("my_topic"); hwm != 390 {
+		t.Errorf("Expected high water mark 390, found %d", hwm)
+	}
+	if lag := c.LagTotal("my_topic"); lag != 15 {
+		t.Errorf("Expected lag 15, found %d", lag)
+	}
+	if hwm, lag := c.TopicHighWaterMark("unknown"), c.LagTotal("unknown"); hwm != 0 || lag != 0 {
+		t.Errorf("Expected no high water mark and lag for an unconsumed topic, found %d and %d", hwm, lag)
+	}
+}
+
 // If a message is given a key, it can be correctly collected while consuming.
 func TestConsumerMessageWithKey(t *testing.T) {
 	// Given
@@ -235,6 +703,99 @@ func TestPauseResumeConsumption(t *testing.T) {
 	broker0.Close()
 }
 
+// Paused partitions are left out of the fetch requests, while the partition
+// consumer keeps its offset and resumes where it stopped.
+func TestConsumerPauseExcludesPartitionFromFetch(t *testing.T) {
+	broker0 := NewMockBroker(t, 0)
+	defer broker0.Close()
+
+	handlers := map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()).
+			SetLeader("my_topic", 1, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetOldest, 0).
+			SetOffset("my_topic", 0, OffsetNewest, 10).
+			SetOffset("my_topic", 1, OffsetOldest, 0).
+			SetOffset("my_topic", 1, OffsetNewest, 10),
+		"FetchRequest": NewMockFetchResponse(t, 1).
+			SetMessage("my_topic", 0, 0, testMsg).
+			SetMessage("my_topic", 1, 0, testMsg),
+	}
+	broker0.SetHandlerByMap(handlers)
+
+	master, err := NewConsumer([]string{broker0.Addr()}, NewTestConfig())
+	require.NoError(t, err)
+	defer safeClose(t, master)
+
+	consumer0, err := master.ConsumePartition("my_topic", 0, 0)
+	require.NoError(t, err)
+	defer safeClose(t, consumer0)
+	consumer1, err := master.ConsumePartition("my_topic", 1, 0)
+	require.NoError(t, err)
+	defer safeClose(t, consumer1)
+
+	for _, consumer := range []PartitionConsumer{consumer0, consumer1} {
+		select {
+		case msg := <-consumer.Messages():
+			assertMessageOffset(t, msg, 0)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the first message")
+		}
+	}
+
+	master.Pause(map[string][]int32{"my_topic": {1}})
+	require.True(t, consumer1.IsPaused())
+	require.False(t, consumer0.IsPaused())
+
+	// a fetch without the paused partition means that any fetch issued before
+	// pausing has been handled, as a broker consumer fetches sequentially
+	pauseRequested := len(broker0.History())
+	require.Eventually(t, func() bool {
+		for _, rr := range broker0.History()[pauseRequested:] {
+			if req, ok := rr.Request.(*FetchRequest); ok && req.blocks["my_topic"][1] == nil {
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+	pausedAt := len(broker0.History())
+
+	handlers["FetchRequest"] = NewMockFetchResponse(t, 1).
+		SetMessage("my_topic", 0, 0, testMsg).
+		SetMessage("my_topic", 1, 0, testMsg).
+		SetMessage("my_topic", 1, 1, testMsg)
+	broker0.SetHandlerByMap(handlers)
+
+	select {
+	case msg := <-consumer1.Messages():
+		t.Fatalf("unexpected message at offset %d from a paused partition", msg.Offset)
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	var fetches int
+	for _, rr := range broker0.History()[pausedAt:] {
+		if req, ok := rr.Request.(*FetchRequest); ok {
+			fetches++
+			require.Contains(t, req.blocks["my_topic"], int32(0))
+			require.NotContains(t, req.blocks["my_topic"], int32(1))
+		}
+	}
+	require.NotZero(t, fetches, "expected the unpaused partition to keep being fetched")
+
+	master.Resume(map[string][]int32{"my_topic": {1}})
+	require.False(t, consumer1.IsPaused())
+	select {
+	case msg := <-consumer1.Messages():
+		assertMessageOffset(t, msg, 1)
+	case err := <-consumer1.Errors():
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the resumed partition")
+	}
+}
+
 // If `OffsetNewest` is passed as the initial offset then the first consumed
 // message indeed corresponds to the offset that broker claims to be the
 // newest in its metadata response.
@@ -507,96 +1068,323 @@ func TestConsumerLeaderRefreshErrorWithBackoffFunc(t *testing.T) {
 	config.Consumer.Return.Errors = true
 	config.Metadata.Retry.Max = 0
 
-	runConsumerLeaderRefreshErrorTestWithConfig(t, config)
+	runConsumerLeaderRefreshErrorTestWithConfig(t, config)
+
+	// we expect at least one call to our backoff function
+	if calls.Load() == 0 {
+		t.Fail()
+	}
+}
+
+func TestConsumerInvalidTopic(t *testing.T) {
+	// Given
+	broker0 := NewMockBroker(t, 100)
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()),
+	})
+
+	c, err := NewConsumer([]string{broker0.Addr()}, NewTestConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When
+	pc, err := c.ConsumePartition("my_topic", 0, OffsetOldest)
+
+	// Then
+	if pc != nil || !errors.Is(err, ErrUnknownTopicOrPartition) {
+		t.Errorf("Should fail with, err=%v", err)
+	}
+
+	safeClose(t, c)
+	broker0.Close()
+}
+
+// Nothing bad happens if a partition consumer that has no leader assigned at
+// the moment is closed.
+func TestConsumerClosePartitionWithoutLeader(t *testing.T) {
+	// Given
+	broker0 := NewMockBroker(t, 100)
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetOldest, 123).
+			SetOffset("my_topic", 0, OffsetNewest, 1000),
+		"FetchRequest": NewMockFetchResponse(t, 1).
+			SetMessage("my_topic", 0, 123, testMsg),
+	})
+
+	config := NewTestConfig()
+	config.Net.ReadTimeout = 100 * time.Millisecond
+	config.Consumer.Retry.Backoff = 100 * time.Millisecond
+	config.Consumer.Return.Errors = true
+	config.Metadata.Retry.Max = 0
+	c, err := NewConsumer([]string{broker0.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pc, err := c.ConsumePartition("my_topic", 0, OffsetOldest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertMessageOffset(t, <-pc.Messages(), 123)
+
+	// broker0 says that it is no longer the leader for my_topic/0, but the
+	// requests to retrieve metadata fail with network timeout.
+	fetchResponse2 := &FetchResponse{}
+	fetchResponse2.AddError("my_topic", 0, ErrNotLeaderForPartition)
+
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"FetchRequest": NewMockWrapper(fetchResponse2),
+	})
+
+	// When
+	if consErr := <-pc.Errors(); !errors.Is(consErr.Err, ErrOutOfBrokers) {
+		t.Errorf("Unexpected error: %v", consErr.Err)
+	}
+
+	// Then: the partition consumer can be closed without any problem.
+	safeClose(t, pc)
+	safeClose(t, c)
+	broker0.Close()
+}
+
+// If the initial offset passed on partition consumer creation is out of the
+// actual offset range for the partition, then the partition consumer stops
+// immediately closing its output channels.
+func TestConsumerShutsDownOutOfRange(t *testing.T) {
+	// Given
+	broker0 := NewMockBroker(t, 0)
+	fetchResponse := new(FetchResponse)
+	fetchResponse.AddError("my_topic", 0, ErrOffsetOutOfRange)
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetNewest, 1234).
+			SetOffset("my_topic", 0, OffsetOldest, 7),
+		"FetchRequest": NewMockWrapper(fetchResponse),
+	})
+
+	master, err := NewConsumer([]string{broker0.Addr()}, NewTestConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When
+	consumer, err := master.ConsumePartition("my_topic", 0, 101)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then: consumer should shut down closing its messages and errors channels.
+	if _, ok := <-consumer.Messages(); ok {
+		t.Error("Expected the consumer to shut down")
+	}
+	safeClose(t, consumer)
+
+	safeClose(t, master)
+	broker0.Close()
+}
+
+// Partitions consumed with different isolation levels from the same broker
+// are fetched with a FetchRequest per isolation level, sharing MaxBytes.
+func TestConsumerPartitionIsolationLevel(t *testing.T) {
+	// Given
+	broker0 := NewMockBroker(t, 0)
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()).
+			SetLeader("my_topic", 1, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetOldest, 0).
+			SetOffset("my_topic", 0, OffsetNewest, 1).
+			SetOffset("my_topic", 1, OffsetOldest, 0).
+			SetOffset("my_topic", 1, OffsetNewest, 1),
+		"FetchRequest": NewMockFetchResponse(t, 1).
+			SetMessage("my_topic", 0, 0, testMsg).
+			SetMessage("my_topic", 1, 0, testMsg),
+	})
+
+	cfg := NewTestConfig()
+	cfg.Version = V0_11_0_0
+	master, err := NewConsumer([]string{broker0.Addr()}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// When
+	uncommitted, err := master.ConsumePartition("my_topic", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	committed, err := master.ConsumePartitionWithOptions("my_topic", 1, 0, WithIsolationLevel(ReadCommitted))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Then
+	assertMessageOffset(t, <-uncommitted.Messages(), 0)
+	assertMessageOffset(t, <-committed.Messages(), 0)
+	require.Eventually(t, func() bool {
+		for _, rr := range broker0.History() {
+			if request, ok := rr.Request.(*FetchRequest); ok && request.MaxBytes == cfg.Consumer.Fetch.MaxBytes/2 {
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond, "expected the requests of both isolation levels to share MaxBytes")
+	safeClose(t, uncommitted)
+	safeClose(t, committed)
+	safeClose(t, master)
+
+	for _, rr := range broker0.History() {
+		request, ok := rr.Request.(*FetchRequest)
+		if !ok {
+			continue
+		}
+		for partition := range request.blocks["my_topic"] {
+			expected := ReadUncommitted
+			if partition == 1 {
+				expected = ReadCommitted
+			}
+			if request.Isolation != expected {
+				t.Errorf("Expected partition %d to be fetched with isolation level %d, got %d", partition, expected, request.Isolation)
+			}
+		}
+	}
+	broker0.Close()
+}
+
+func TestConsumePartitionWithPaused(t *testing.T) {
+	broker0 := NewMockBroker(t, 0)
+	defer broker0.Close()
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetOldest, 0).
+			SetOffset("my_topic", 0, OffsetNewest, 1),
+		"FetchRequest": NewMockFetchResponse(t, 1).
+			SetMessage("my_topic", 0, 0, testMsg),
+	})
+
+	master, err := NewConsumer([]string{broker0.Addr()}, NewTestConfig())
+	require.NoError(t, err)
+	defer safeClose(t, master)
+
+	consumer, err := master.ConsumePartitionWithOptions("my_topic", 0, 0, WithPaused())
+	require.NoError(t, err)
+	defer safeClose(t, consumer)
+	require.True(t, consumer.IsPaused())
 
-	// we expect at least one call to our backoff function
-	if calls.Load() == 0 {
-		t.Fail()
+	select {
+	case msg := <-consumer.Messages():
+		t.Fatalf("unexpected message from a paused partition: %v", msg)
+	case <-time.After(100 * time.Millisecond):
+	}
+	for _, rr := range broker0.History() {
+		if _, ok := rr.Request.(*FetchRequest); ok {
+			t.Fatal("expected no FetchRequest for a paused partition")
+		}
+	}
+
+	consumer.Resume()
+	select {
+	case msg := <-consumer.Messages():
+		assertMessageOffset(t, msg, 0)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the message once resumed")
 	}
 }
 
-func TestConsumerInvalidTopic(t *testing.T) {
-	// Given
-	broker0 := NewMockBroker(t, 100)
+func TestConsumePartitionWithOptionsValidation(t *testing.T) {
+	broker0 := NewMockBroker(t, 0)
+	defer broker0.Close()
 	broker0.SetHandlerByMap(map[string]MockResponse{
 		"MetadataRequest": NewMockMetadataResponse(t).
-			SetBroker(broker0.Addr(), broker0.BrokerID()),
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
 	})
 
-	c, err := NewConsumer([]string{broker0.Addr()}, NewTestConfig())
+	cfg := NewTestConfig()
+	cfg.Version = V0_10_2_0
+	master, err := NewConsumer([]string{broker0.Addr()}, cfg)
 	if err != nil {
 		t.Fatal(err)
 	}
+	defer safeClose(t, master)
 
-	// When
-	pc, err := c.ConsumePartition("my_topic", 0, OffsetOldest)
-
-	// Then
-	if pc != nil || !errors.Is(err, ErrUnknownTopicOrPartition) {
-		t.Errorf("Should fail with, err=%v", err)
+	for _, level := range []IsolationLevel{ReadCommitted, IsolationLevel(42)} {
+		_, err := master.ConsumePartitionWithOptions("my_topic", 0, 0, WithIsolationLevel(level))
+		var target ConfigurationError
+		if !errors.As(err, &target) {
+			t.Errorf("Expected a ConfigurationError for isolation level %d, got %v", level, err)
+		}
 	}
-
-	safeClose(t, c)
-	broker0.Close()
 }
 
-// Nothing bad happens if a partition consumer that has no leader assigned at
-// the moment is closed.
-func TestConsumerClosePartitionWithoutLeader(t *testing.T) {
+// If the offset gets out of range while consuming and OutOfRangeReset is
+// OffsetResetEarliest, then the partition consumer resumes from the oldest
+// offset instead of shutting down.
+func TestConsumerResetsOutOfRange(t *testing.T) {
 	// Given
-	broker0 := NewMockBroker(t, 100)
+	broker0 := NewMockBroker(t, 0)
+	outOfRange := new(FetchResponse)
+	outOfRange.AddError("my_topic", 0, ErrOffsetOutOfRange)
+	oldest := new(FetchResponse)
+	oldest.AddMessage("my_topic", 0, nil, testMsg, 7)
 	broker0.SetHandlerByMap(map[string]MockResponse{
 		"MetadataRequest": NewMockMetadataResponse(t).
 			SetBroker(broker0.Addr(), broker0.BrokerID()).
 			SetLeader("my_topic", 0, broker0.BrokerID()),
 		"OffsetRequest": NewMockOffsetResponse(t).
-			SetOffset("my_topic", 0, OffsetOldest, 123).
-			SetOffset("my_topic", 0, OffsetNewest, 1000),
-		"FetchRequest": NewMockFetchResponse(t, 1).
-			SetMessage("my_topic", 0, 123, testMsg),
+			SetOffset("my_topic", 0, OffsetNewest, 1234).
+			SetOffset("my_topic", 0, OffsetOldest, 7),
+		"FetchRequest": NewMockSequence(outOfRange, oldest),
 	})
 
 	config := NewTestConfig()
-	config.Net.ReadTimeout = 100 * time.Millisecond
-	config.Consumer.Retry.Backoff = 100 * time.Millisecond
-	config.Consumer.Return.Errors = true
-	config.Metadata.Retry.Max = 0
-	c, err := NewConsumer([]string{broker0.Addr()}, config)
+	config.Consumer.Offsets.OutOfRangeReset = OffsetResetEarliest
+	master, err := NewConsumer([]string{broker0.Addr()}, config)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	pc, err := c.ConsumePartition("my_topic", 0, OffsetOldest)
+	// When
+	consumer, err := master.ConsumePartition("my_topic", 0, 101)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	assertMessageOffset(t, <-pc.Messages(), 123)
-
-	// broker0 says that it is no longer the leader for my_topic/0, but the
-	// requests to retrieve metadata fail with network timeout.
-	fetchResponse2 := &FetchResponse{}
-	fetchResponse2.AddError("my_topic", 0, ErrNotLeaderForPartition)
-
-	broker0.SetHandlerByMap(map[string]MockResponse{
-		"FetchRequest": NewMockWrapper(fetchResponse2),
-	})
-
-	// When
-	if consErr := <-pc.Errors(); !errors.Is(consErr.Err, ErrOutOfBrokers) {
-		t.Errorf("Unexpected error: %v", consErr.Err)
+	// Then
+	select {
+	case msg, ok := <-consumer.Messages():
+		if !ok {
+			t.Fatal("Expected the consumer to reset its offset instead of shutting down")
+		}
+		assertMessageOffset(t, msg, 7)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the consumer to reset its offset")
 	}
 
-	// Then: the partition consumer can be closed without any problem.
-	safeClose(t, pc)
-	safeClose(t, c)
+	safeClose(t, consumer)
+	safeClose(t, master)
 	broker0.Close()
 }
 
-// If the initial offset passed on partition consumer creation is out of the
-// actual offset range for the partition, then the partition consumer stops
-// immediately closing its output channels.
-func TestConsumerShutsDownOutOfRange(t *testing.T) {
+// If the offset gets out of range while consuming and OutOfRangeReset is
+// OffsetResetError, then the partition consumer reports an
+// OffsetOutOfRangeError and shuts down.
+func TestConsumerOutOfRangeResetError(t *testing.T) {
 	// Given
 	broker0 := NewMockBroker(t, 0)
 	fetchResponse := new(FetchResponse)
@@ -611,7 +1399,10 @@ func TestConsumerShutsDownOutOfRange(t *testing.T) {
 		"FetchRequest": NewMockWrapper(fetchResponse),
 	})
 
-	master, err := NewConsumer([]string{broker0.Addr()}, NewTestConfig())
+	config := NewTestConfig()
+	config.Consumer.Return.Errors = true
+	config.Consumer.Offsets.OutOfRangeReset = OffsetResetError
+	master, err := NewConsumer([]string{broker0.Addr()}, config)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -622,11 +1413,22 @@ func TestConsumerShutsDownOutOfRange(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	// Then: consumer should shut down closing its messages and errors channels.
+	// Then
+	consumerErr := <-consumer.Errors()
+	var outOfRangeErr *OffsetOutOfRangeError
+	if !errors.As(consumerErr, &outOfRangeErr) {
+		t.Fatalf("Expected an OffsetOutOfRangeError, got %v", consumerErr)
+	}
+	if outOfRangeErr.Topic != "my_topic" || outOfRangeErr.Partition != 0 || outOfRangeErr.Offset != 101 {
+		t.Errorf("Unexpected OffsetOutOfRangeError: %+v", outOfRangeErr)
+	}
+	if !errors.Is(consumerErr, ErrOffsetOutOfRange) {
+		t.Error("Expected the error to wrap ErrOffsetOutOfRange")
+	}
 	if _, ok := <-consumer.Messages(); ok {
 		t.Error("Expected the consumer to shut down")
 	}
-	safeClose(t, consumer)
+	_ = consumer.Close()
 
 	safeClose(t, master)
 	broker0.Close()
@@ -702,6 +1504,68 @@ func TestConsumerExtraOffsets(t *testing.T) {
 	}
 }
 
+// Records with a null value are consumed as tombstones while records with an
+// empty value are not.
+func TestConsumerTombstones(t *testing.T) {
+	// Given
+	legacyFetchResponse := &FetchResponse{}
+	legacyFetchResponse.AddMessage("my_topic", 0, nil, nil, 1)
+	legacyFetchResponse.AddMessage("my_topic", 0, nil, ByteEncoder{}, 2)
+	newFetchResponse := &FetchResponse{Version: 5}
+	newFetchResponse.AddRecord("my_topic", 0, nil, nil, 1)
+	newFetchResponse.AddRecord("my_topic", 0, nil, ByteEncoder{}, 2)
+	newFetchResponse.SetLastOffsetDelta("my_topic", 0, 2)
+	newFetchResponse.SetLastStableOffset("my_topic", 0, 2)
+	for _, fetchResponse := range []*FetchResponse{legacyFetchResponse, newFetchResponse} {
+		cfg := NewTestConfig()
+		cfg.Consumer.Return.Errors = true
+		if fetchResponse.Version >= 5 {
+			cfg.Version = V0_11_0_0
+		}
+
+		broker0 := NewMockBroker(t, 0)
+		broker0.SetHandlerByMap(map[string]MockResponse{
+			"MetadataRequest": NewMockMetadataResponse(t).
+				SetBroker(broker0.Addr(), broker0.BrokerID()).
+				SetLeader("my_topic", 0, broker0.BrokerID()),
+			"OffsetRequest": NewMockOffsetResponse(t).
+				SetOffset("my_topic", 0, OffsetNewest, 1234).
+				SetOffset("my_topic", 0, OffsetOldest, 0),
+			"FetchRequest": NewMockSequence(fetchResponse, &FetchResponse{Version: fetchResponse.Version}),
+		})
+
+		master, err := NewConsumer([]string{broker0.Addr()}, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// When
+		consumer, err := master.ConsumePartition("my_topic", 0, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Then
+		for _, tombstone := range []bool{true, false} {
+			select {
+			case msg := <-consumer.Messages():
+				if msg.IsTombstone() != tombstone {
+					t.Errorf("Expected IsTombstone() to be %t for the message at offset %d (version %d)", tombstone, msg.Offset, fetchResponse.Version)
+				}
+				if len(msg.Value) != 0 {
+					t.Errorf("Expected no value, got %q", msg.Value)
+				}
+			case err := <-consumer.Errors():
+				t.Fatal(err)
+			}
+		}
+
+		safeClose(t, consumer)
+		safeClose(t, master)
+		broker0.Close()
+	}
+}
+
 // In some situations broker may return a block containing only
 // messages older then requested, even though there would be
 // more messages if higher offset was requested.
@@ -842,6 +1706,145 @@ func TestConsumeMessageWithSessionIDs(t *testing.T) {
 	}
 }
 
+func TestConsumerDecompressionWorkers(t *testing.T) {
+	batchSizes := map[int32][]int{
+		0: {200, 1, 100},
+		1: {2, 3},
+		2: {1},
+	}
+	compressed := newCompressedFetchResponse(CompressionZSTD, batchSizes)
+
+	broker0 := NewMockBroker(t, 0)
+	defer broker0.Close()
+	metadata := NewMockMetadataResponse(t).SetBroker(broker0.Addr(), broker0.BrokerID())
+	offsets := NewMockOffsetResponse(t)
+	for partition := range batchSizes {
+		metadata.SetLeader("my_topic", partition, broker0.BrokerID())
+		offsets.SetOffset("my_topic", partition, OffsetOldest, 0).SetOffset("my_topic", partition, OffsetNewest, 1000)
+	}
+	broker0.SetHandlerFuncByMap(map[string]requestHandlerFunc{
+		"MetadataRequest": func(req *request) encoderWithHeader { return metadata.For(req.body) },
+		"OffsetRequest":   func(req *request) encoderWithHeader { return offsets.For(req.body) },
+		"FetchRequest": func(req *request) encoderWithHeader {
+			// serve all the batches of a partition when fetching from offset 0
+			fetch := req.body.(*FetchRequest)
+			res := &FetchResponse{Version: fetch.Version}
+			for partition, block := range fetch.blocks["my_topic"] {
+				resBlock := res.getOrCreateBlock("my_topic", partition)
+				resBlock.HighWaterMarkOffset = compressed.GetBlock("my_topic", partition).HighWaterMarkOffset
+				if block.fetchOffset == 0 {
+					resBlock.RecordsSet = compressed.GetBlock("my_topic", partition).RecordsSet
+				}
+			}
+			return res
+		},
+	})
+
+	config := NewTestConfig()
+	config.Version = V2_1_0_0
+	config.Consumer.Fetch.DecompressionWorkers = 2
+	master, err := NewConsumer([]string{broker0.Addr()}, config)
+	require.NoError(t, err)
+	defer safeClose(t, master)
+
+	for partition, sizes := range batchSizes {
+		consumer, err := master.ConsumePartition("my_topic", partition, 0)
+		require.NoError(t, err)
+		defer safeClose(t, consumer)
+
+		var total int
+		for _, size := range sizes {
+			total += size
+		}
+		for offset := int64(0); offset < int64(total); offset++ {
+			select {
+			case msg := <-consumer.Messages():
+				require.Equal(t, offset, msg.Offset, "expected messages in offset order")
+				expected := fmt.Sprintf("partition %d offset %d ", partition, offset)
+				require.True(t, strings.HasPrefix(string(msg.Value), expected), "unexpected value %q", msg.Value)
+			case err := <-consumer.Errors():
+				t.Fatal(err)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("timed out waiting for offset %d of partition %d", offset, partition)
+			}
+		}
+	}
+}
+
+func TestConsumerZstdDictionary(t *testing.T) {
+	dict := newTestZstdDictionary(t)
+	batchSizes := map[int32][]int{0: {10, 5}}
+	compressed := newCompressedFetchResponse(CompressionZSTD, batchSizes)
+	for _, records := range compressed.GetBlock("my_topic", 0).RecordsSet {
+		records.RecordBatch.zstdDict = newZstdDictionary(dict)
+	}
+
+	for _, workers := range []int{0, 2} {
+		t.Run(fmt.Sprintf("%d decompression workers", workers), func(t *testing.T) {
+			broker0 := NewMockBroker(t, 0)
+			defer broker0.Close()
+			broker0.SetHandlerFuncByMap(map[string]requestHandlerFunc{
+				"MetadataRequest": func(req *request) encoderWithHeader {
+					return NewMockMetadataResponse(t).
+						SetBroker(broker0.Addr(), broker0.BrokerID()).
+						SetLeader("my_topic", 0, broker0.BrokerID()).
+						For(req.body)
+				},
+				"OffsetRequest": func(req *request) encoderWithHeader {
+					return NewMockOffsetResponse(t).
+						SetOffset("my_topic", 0, OffsetOldest, 0).
+						SetOffset("my_topic", 0, OffsetNewest, 15).
+						For(req.body)
+				},
+				"FetchRequest": func(req *request) encoderWithHeader {
+					fetch := req.body.(*FetchRequest)
+					res := &FetchResponse{Version: fetch.Version}
+					resBlock := res.getOrCreateBlock("my_topic", 0)
+					resBlock.HighWaterMarkOffset = 15
+					if fetch.blocks["my_topic"][0].fetchOffset == 0 {
+						resBlock.RecordsSet = compressed.GetBlock("my_topic", 0).RecordsSet
+					}
+					return res
+				},
+			})
+
+			config := NewTestConfig()
+			config.Version = V2_1_0_0
+			config.Producer.Compression = CompressionZSTD
+			config.Producer.ZstdDictionary = dict
+			config.Consumer.Fetch.DecompressionWorkers = workers
+			master, err := NewConsumer([]string{broker0.Addr()}, config)
+			require.NoError(t, err)
+			defer safeClose(t, master)
+
+			consumer, err := master.ConsumePartition("my_topic", 0, 0)
+			require.NoError(t, err)
+			defer safeClose(t, consumer)
+
+			for offset := int64(0); offset < 15; offset++ {
+				select {
+				case msg := <-consumer.Messages():
+					require.Equal(t, offset, msg.Offset)
+					expected := fmt.Sprintf("partition 0 offset %d ", offset)
+					require.True(t, strings.HasPrefix(string(msg.Value), expected), "unexpected value %q", msg.Value)
+				case err := <-consumer.Errors():
+					t.Fatal(err)
+				case <-time.After(5 * time.Second):
+					t.Fatalf("timed out waiting for offset %d", offset)
+				}
+			}
+		})
+	}
+}
+
+func TestConsumerDecompressionWorkersValidation(t *testing.T) {
+	config := NewTestConfig()
+	config.Consumer.Fetch.DecompressionWorkers = -1
+	var target ConfigurationError
+	require.ErrorAs(t, config.Validate(), &target)
+	require.Equal(t, "Consumer.Fetch.DecompressionWorkers must be >= 0", string(target))
+}
+
 func TestConsumeMessagesFromReadReplica(t *testing.T) {
 	withRefreshFrequency := func(frequency time.Duration) func(*Config) {
 		return func(cfg *Config) {
@@ -903,6 +1906,38 @@ func TestConsumeMessagesFromReadReplica(t *testing.T) {
 		assertOffsets(t, c, 1, 2, 3, 4)
 	})
 
+	t.Run("falls back to leader on ErrNotLeaderForPartition", func(t *testing.T) {
+		c, cleanup := newReadReplicaTest(t, readReplicaTestConfig{
+			leaderFetches: []readReplicaFetch{
+				{preferredReadReplica: preferredReplica(1)},
+				{records: []int64{3, 4}},
+			},
+			followerFetches: []readReplicaFetch{
+				{records: []int64{1, 2}},
+				{err: ErrNotLeaderForPartition},
+			},
+		})
+		defer cleanup()
+		assertOffsets(t, c, 1, 2, 3, 4)
+	})
+
+	t.Run("stays on leader when PreferClosestReplica is disabled", func(t *testing.T) {
+		c, cleanup := newReadReplicaTest(t, readReplicaTestConfig{
+			configure: func(cfg *Config) {
+				cfg.Consumer.PreferClosestReplica = false
+			},
+			leaderFetches: []readReplicaFetch{
+				{records: []int64{1, 2}, preferredReadReplica: preferredReplica(1)},
+				{records: []int64{3, 4}},
+			},
+			followerFetches: []readReplicaFetch{
+				{records: []int64{5, 6}},
+			},
+		})
+		defer cleanup()
+		assertOffsets(t, c, 1, 2, 3, 4)
+	})
+
 	t.Run("falls back to leader on unknown error", func(t *testing.T) {
 		c, cleanup := newReadReplicaTest(t, readReplicaTestConfig{
 			leaderFetches: []readReplicaFetch{
@@ -1165,6 +2200,75 @@ func TestConsumeMessagesTrackLeader(t *testing.T) {
 
 // It is fine if offsets of fetched messages are not sequential (although
 // strictly increasing!).
+func TestConsumerWatchPartitions(t *testing.T) {
+	broker0 := NewMockBroker(t, 0)
+	defer broker0.Close()
+
+	var partitionCount atomic.Int32
+	partitionCount.Store(1)
+	broker0.SetHandlerFuncByMap(map[string]requestHandlerFunc{
+		"MetadataRequest": func(req *request) encoderWithHeader {
+			metadata := NewMockMetadataResponse(t).SetBroker(broker0.Addr(), broker0.BrokerID())
+			for p := int32(0); p < partitionCount.Load(); p++ {
+				metadata.SetLeader("my_topic", p, broker0.BrokerID())
+			}
+			return metadata.For(req.body)
+		},
+	})
+
+	config := NewTestConfig()
+	config.Metadata.RefreshFrequency = 10 * time.Millisecond
+	master, err := NewConsumer([]string{broker0.Addr()}, config)
+	require.NoError(t, err)
+
+	watch, err := master.WatchPartitions("my_topic")
+	require.NoError(t, err)
+	require.Equal(t, []int32{0}, <-watch)
+
+	partitionCount.Store(3)
+	select {
+	case partitions := <-watch:
+		require.Equal(t, []int32{0, 1, 2}, partitions)
+	case <-time.After(5 * time.Second):
+		t.Fatal("new partitions were not sent")
+	}
+
+	require.NoError(t, master.Close())
+	select {
+	case _, ok := <-watch:
+		require.False(t, ok, "expected the watch channel to be closed")
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch channel was not closed by Close")
+	}
+
+	_, err = master.WatchPartitions("my_topic")
+	require.ErrorIs(t, err, ErrClosedConsumer)
+}
+
+func TestConsumerWatchPartitionsWithoutRefresh(t *testing.T) {
+	broker0 := NewMockBroker(t, 0)
+	defer broker0.Close()
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()).
+			SetLeader("my_topic", 1, broker0.BrokerID()),
+	})
+
+	config := NewTestConfig()
+	config.Metadata.RefreshFrequency = 0
+	master, err := NewConsumer([]string{broker0.Addr()}, config)
+	require.NoError(t, err)
+
+	watch, err := master.WatchPartitions("my_topic")
+	require.NoError(t, err)
+	require.Equal(t, []int32{0, 1}, <-watch)
+
+	require.NoError(t, master.Close())
+	_, ok := <-watch
+	require.False(t, ok, "expected the watch channel to be closed")
+}
+
 func TestConsumerNonSequentialOffsets(t *testing.T) {
 	// Given
 	legacyFetchResponse := &FetchResponse{}
@@ -1665,6 +2769,7 @@ func TestPartitionConsumerBrokerRace(t *testing.T) {
 		broker:         broker,
 		messages:       make(chan *ConsumerMessage, 1),
 		errors:         make(chan *ConsumerError, 1),
+		eofs:           make(chan int64),
 		feeder:         make(chan *partitionConsumerResponse, 1),
 		trigger:        make(chan none, 1),
 		dying:          make(chan none),
@@ -1799,6 +2904,7 @@ func TestPartitionConsumerRetryMax(t *testing.T) {
 		topic:          "my_topic",
 		partition:      7,
 		errors:         make(chan *ConsumerError, 1),
+		eofs:           make(chan int64),
 		feeder:         make(chan *partitionConsumerResponse, 1),
 		trigger:        make(chan none, 1),
 		dying:          make(chan none),
@@ -2022,6 +3128,16 @@ func TestExcludeUncommitted(t *testing.T) {
 	cfg.Consumer.Return.Errors = true
 	cfg.Version = V0_11_0_0
 	cfg.Consumer.IsolationLevel = ReadCommitted
+	var abortedLock sync.Mutex
+	var aborted []int64
+	cfg.Consumer.OnAbortedMessage = func(topic string, partition int32, offset int64) {
+		if topic != "my_topic" || partition != 0 {
+			t.Errorf("unexpected aborted message from %s/%d", topic, partition)
+		}
+		abortedLock.Lock()
+		defer abortedLock.Unlock()
+		aborted = append(aborted, offset)
+	}
 
 	// When
 	master, err := NewConsumer([]string{broker0.Addr()}, cfg)
@@ -2048,11 +3164,93 @@ func TestExcludeUncommitted(t *testing.T) {
 		t.Error(err)
 	}
 
+	// and the 2 aborted messages are reported, but not the control record
+	abortedLock.Lock()
+	if !slices.Equal(aborted, []int64{1235, 1236}) {
+		t.Errorf("expected aborted messages at offsets 1235 and 1236, got %v", aborted)
+	}
+	abortedLock.Unlock()
+
 	safeClose(t, consumer)
 	safeClose(t, master)
 	broker0.Close()
 }
 
+// Record batch producer metadata is carried onto the consumed messages, while
+// legacy message sets leave it unset.
+func TestConsumerMessageProducerMetadata(t *testing.T) {
+	for _, d := range []struct {
+		name     string
+		kversion KafkaVersion
+		fetch    func(*FetchResponse)
+		expected []*ConsumerMessage
+	}{{
+		name:     "record batches",
+		kversion: V0_11_0_0,
+		fetch: func(r *FetchResponse) {
+			r.Version = 5
+			r.AddRecordBatch("my_topic", 0, nil, testMsg, 1, 7, true)
+			r.GetBlock("my_topic", 0).RecordsSet[0].RecordBatch.ProducerEpoch = 3
+			r.AddControlRecord("my_topic", 0, 2, 7, ControlRecordCommit)
+			r.AddRecordBatch("my_topic", 0, nil, testMsg, 3, 9, false)
+		},
+		expected: []*ConsumerMessage{
+			{Offset: 1, ProducerID: 7, ProducerEpoch: 3, IsTransactional: true},
+			{Offset: 3, ProducerID: 9},
+		},
+	}, {
+		name:     "legacy message sets",
+		kversion: V0_10_2_0,
+		fetch: func(r *FetchResponse) {
+			r.Version = 3
+			r.AddMessage("my_topic", 0, nil, testMsg, 1)
+			r.AddMessage("my_topic", 0, nil, testMsg, 2)
+		},
+		expected: []*ConsumerMessage{{Offset: 1}, {Offset: 2}},
+	}} {
+		t.Run(d.name, func(t *testing.T) {
+			broker0 := NewMockBroker(t, 0)
+			defer broker0.Close()
+
+			fetchResponse := &FetchResponse{}
+			d.fetch(fetchResponse)
+			broker0.SetHandlerByMap(map[string]MockResponse{
+				"MetadataRequest": NewMockMetadataResponse(t).
+					SetBroker(broker0.Addr(), broker0.BrokerID()).
+					SetLeader("my_topic", 0, broker0.BrokerID()),
+				"OffsetRequest": NewMockOffsetResponse(t).
+					SetOffset("my_topic", 0, OffsetOldest, 0).
+					SetOffset("my_topic", 0, OffsetNewest, 4),
+				"FetchRequest": NewMockSequence(fetchResponse, NewMockFetchResponse(t, 1)),
+			})
+
+			cfg := NewTestConfig()
+			cfg.Version = d.kversion
+			master, err := NewConsumer([]string{broker0.Addr()}, cfg)
+			require.NoError(t, err)
+			defer safeClose(t, master)
+
+			consumer, err := master.ConsumePartition("my_topic", 0, 1)
+			require.NoError(t, err)
+			defer safeClose(t, consumer)
+
+			for _, want := range d.expected {
+				select {
+				case msg := <-consumer.Messages():
+					assertMessageOffset(t, msg, want.Offset)
+					require.Equal(t, want.ProducerID, msg.ProducerID)
+					require.Equal(t, want.ProducerEpoch, msg.ProducerEpoch)
+					require.Equal(t, want.IsTransactional, msg.IsTransactional)
+				case err := <-consumer.Errors():
+					t.Fatal(err)
+				case <-time.After(5 * time.Second):
+					t.Fatalf("timed out waiting for the message at offset %d", want.Offset)
+				}
+			}
+		})
+	}
+}
+
 func assertMessageKey(t *testing.T, msg *ConsumerMessage, expectedKey Encoder) {
 	t.Helper()
 
@@ -2362,6 +3560,7 @@ func TestConsumerAbortNoGoroutineLeak(t *testing.T) {
 			dispatcherStop: make(chan none),
 			messages:       make(chan *ConsumerMessage, config.ChannelBufferSize),
 			errors:         make(chan *ConsumerError, errorsBuffer),
+			eofs:           make(chan int64),
 			feeder:         make(chan *partitionConsumerResponse, 1),
 		}
 	}
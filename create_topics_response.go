@@ -1,7 +1,11 @@
 package sarama
 
 import (
+	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -159,6 +163,45 @@ func (t *TopicError) Unwrap() error {
 	return t.Err
 }
 
+// TopicCreationErrors is returned by ClusterAdmin.CreateTopics when some of
+// the topics could not be created, it maps their names to their error.
+type TopicCreationErrors map[string]*TopicError
+
+func (e TopicCreationErrors) Error() string {
+	topics := slices.Sorted(maps.Keys(e))
+	failures := make([]string, len(topics))
+	for i, topic := range topics {
+		failures[i] = fmt.Sprintf("%s: %s", topic, e[topic])
+	}
+	return fmt.Sprintf("kafka: failed to create %d topics: %s", len(e), strings.Join(failures, "; "))
+}
+
+// Unwrap returns the errors of the topics, so that errors.Is reports whether
+// any of them failed with a given KError.
+func (e TopicCreationErrors) Unwrap() []error {
+	errs := make([]error, 0, len(e))
+	for _, topicErr := range e {
+		errs = append(errs, topicErr)
+	}
+	return errs
+}
+
+// WithoutAlreadyExists returns the errors of the topics that failed for
+// another reason than ErrTopicAlreadyExists, or nil if there are none, for
+// idempotent creations to ignore the topics that already exist.
+func (e TopicCreationErrors) WithoutAlreadyExists() error {
+	remaining := make(TopicCreationErrors, len(e))
+	for topic, topicErr := range e {
+		if !errors.Is(topicErr, ErrTopicAlreadyExists) {
+			remaining[topic] = topicErr
+		}
+	}
+	if len(remaining) == 0 {
+		return nil
+	}
+	return remaining
+}
+
 func (t *TopicError) encode(pe packetEncoder, version int16) error {
 	pe.putKError(t.Err)
 
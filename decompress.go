@@ -33,7 +33,7 @@ var (
 	}
 )
 
-func decompress(cc CompressionCodec, data []byte) ([]byte, error) {
+func decompress(cc CompressionCodec, zstdDict *zstdDictionary, data []byte) ([]byte, error) {
 	switch cc {
 	case CompressionNone:
 		return data, nil
@@ -84,7 +84,7 @@ func decompress(cc CompressionCodec, data []byte) ([]byte, error) {
 	case CompressionZSTD:
 		buffer := *bytesPool.Get().(*[]byte)
 		var err error
-		buffer, err = zstdDecompress(ZstdDecoderParams{}, buffer, data)
+		buffer, err = zstdDecompress(ZstdDecoderParams{dict: zstdDict}, buffer, data)
 		// copy the buffer to a new slice with the correct length and reuse buffer
 		res := make([]byte, len(buffer))
 		copy(res, buffer)
@@ -96,3 +96,69 @@ func decompress(cc CompressionCodec, data []byte) ([]byte, error) {
 		return nil, PacketDecodingError{fmt.Sprintf("invalid compression specified (%d)", cc)}
 	}
 }
+
+// decompressionPool decompresses the record batches of fetch responses on a
+// fixed number of goroutines, see Config.Consumer.Fetch.DecompressionWorkers.
+type decompressionPool struct {
+	jobs      chan func()
+	closing   chan none
+	closeOnce sync.Once
+}
+
+func newDecompressionPool(workers int) *decompressionPool {
+	p := &decompressionPool{
+		jobs:    make(chan func()),
+		closing: make(chan none),
+	}
+	for i := 0; i < workers; i++ {
+		go withRecover(p.run)
+	}
+	return p
+}
+
+func (p *decompressionPool) run() {
+	for {
+		select {
+		case job := <-p.jobs:
+			job()
+		case <-p.closing:
+			return
+		}
+	}
+}
+
+// decodeRecords decodes the records of the batches whose decompression was
+// deferred on the workers of the pool, or inline once the pool is closed. It
+// returns the error of the first batch that failed to decode, if any.
+func (p *decompressionPool) decodeRecords(recordsSet []*Records) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(recordsSet))
+	for i, records := range recordsSet {
+		batch := records.RecordBatch
+		if batch == nil || batch.pendingRecords == nil {
+			continue
+		}
+		wg.Add(1)
+		job := func() {
+			defer wg.Done()
+			errs[i] = batch.decodeRecords()
+		}
+		select {
+		case p.jobs <- job:
+		case <-p.closing:
+			job()
+		}
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *decompressionPool) close() {
+	p.closeOnce.Do(func() { close(p.closing) })
+}
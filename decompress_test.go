@@ -0,0 +1,152 @@
+//go:build !functional
+
+package sarama
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newCompressedFetchResponse returns a fetch response holding, for each
+// partition of my_topic, record batches of the given sizes compressed with
+// codec.
+func newCompressedFetchResponse(codec CompressionCodec, batchSizes map[int32][]int) *FetchResponse {
+	now := time.Now().Truncate(time.Millisecond)
+	response := &FetchResponse{Version: 4}
+	for partition, sizes := range batchSizes {
+		block := response.getOrCreateBlock("my_topic", partition)
+		var offset int64
+		for _, size := range sizes {
+			batch := &RecordBatch{
+				Version:         2,
+				Codec:           codec,
+				FirstOffset:     offset,
+				LastOffsetDelta: int32(size - 1),
+				FirstTimestamp:  now,
+				MaxTimestamp:    now,
+			}
+			for i := 0; i < size; i++ {
+				value := fmt.Sprintf("partition %d offset %d ", partition, offset+int64(i))
+				batch.addRecord(&Record{OffsetDelta: int64(i), Value: []byte(strings.Repeat(value, 32))})
+			}
+			records := newDefaultRecords(batch)
+			block.RecordsSet = append(block.RecordsSet, &records)
+			offset += int64(size)
+		}
+		block.HighWaterMarkOffset = offset
+		block.LastStableOffset = offset
+	}
+	return response
+}
+
+func decodeFetchResponse(tb testing.TB, raw []byte, deferDecompression bool) *FetchResponse {
+	tb.Helper()
+	response := &FetchResponse{deferDecompression: deferDecompression}
+	if err := versionedDecode(raw, response, 4, nil); err != nil {
+		tb.Fatal(err)
+	}
+	return response
+}
+
+func TestDecompressionPoolDecodeRecords(t *testing.T) {
+	raw, err := encode(newCompressedFetchResponse(CompressionZSTD, map[int32][]int{
+		0: {100, 1, 50},
+		1: {3},
+	}), nil)
+	require.NoError(t, err)
+
+	expected := decodeFetchResponse(t, raw, false)
+	deferred := decodeFetchResponse(t, raw, true)
+
+	pool := newDecompressionPool(2)
+	for _, partition := range []int32{0, 1} {
+		block := deferred.GetBlock("my_topic", partition)
+		for _, records := range block.RecordsSet {
+			require.NotNil(t, records.RecordBatch.pendingRecords, "expected the decompression to be deferred")
+		}
+		if partition == 1 {
+			// once the pool is closed the records are decoded inline
+			pool.close()
+		}
+		require.NoError(t, pool.decodeRecords(block.RecordsSet))
+
+		expectedBlock := expected.GetBlock("my_topic", partition)
+		require.Len(t, block.RecordsSet, len(expectedBlock.RecordsSet))
+		for i, records := range block.RecordsSet {
+			require.Nil(t, records.RecordBatch.pendingRecords)
+			require.Equal(t, expectedBlock.RecordsSet[i].RecordBatch.Records, records.RecordBatch.Records)
+		}
+	}
+}
+
+func TestDecompressionPoolDecodeRecordsError(t *testing.T) {
+	raw, err := encode(newCompressedFetchResponse(CompressionZSTD, map[int32][]int{0: {10, 10}}), nil)
+	require.NoError(t, err)
+
+	response := decodeFetchResponse(t, raw, true)
+	block := response.GetBlock("my_topic", 0)
+	// corrupt the compressed records of the second batch
+	block.RecordsSet[1].RecordBatch.pendingRecords = []byte{0, 1, 2, 3}
+
+	pool := newDecompressionPool(1)
+	defer pool.close()
+	require.Error(t, pool.decodeRecords(block.RecordsSet))
+	require.Len(t, block.RecordsSet[0].RecordBatch.Records, 10)
+	require.NotNil(t, block.RecordsSet[0].RecordBatch.Records[9], "expected the first batch to be decoded")
+}
+
+// BenchmarkFetchResponseDecompression measures the time from the reception of a
+// fetch response to the records of all its partitions being decoded, for a
+// topic with a few partitions of large batches and many of small ones.
+func BenchmarkFetchResponseDecompression(b *testing.B) {
+	batchSizes := make(map[int32][]int)
+	for partition := int32(0); partition < 16; partition++ {
+		if partition < 2 {
+			batchSizes[partition] = []int{2000, 2000, 2000}
+		} else {
+			batchSizes[partition] = []int{5, 1, 20}
+		}
+	}
+
+	for _, codec := range []CompressionCodec{CompressionLZ4, CompressionZSTD} {
+		raw, err := encode(newCompressedFetchResponse(codec, batchSizes), nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		for _, workers := range []int{0, 1, 4, 8} {
+			b.Run(fmt.Sprintf("%s/workers=%d", codec, workers), func(b *testing.B) {
+				var pool *decompressionPool
+				if workers > 0 {
+					pool = newDecompressionPool(workers)
+					defer pool.close()
+				}
+				b.SetBytes(int64(len(raw)))
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					response := decodeFetchResponse(b, raw, pool != nil)
+					if pool == nil {
+						continue
+					}
+					// each partition consumer decodes its own block
+					var wg sync.WaitGroup
+					for partition := range batchSizes {
+						wg.Add(1)
+						go func(block *FetchResponseBlock) {
+							defer wg.Done()
+							if err := pool.decodeRecords(block.RecordsSet); err != nil {
+								b.Error(err)
+							}
+						}(response.GetBlock("my_topic", partition))
+					}
+					wg.Wait()
+				}
+			})
+		}
+	}
+}
@@ -287,6 +287,9 @@ func (gmd *GroupMemberDescription) decode(pd packetDecoder, version int16) (err
 	return err
 }
 
+// GetMemberAssignment decodes the partitions assigned to a member of a group
+// using the consumer protocol, by topic. It returns nil if the member has no
+// assignment yet, such as during a rebalance.
 func (gmd *GroupMemberDescription) GetMemberAssignment() (*ConsumerGroupMemberAssignment, error) {
 	if len(gmd.MemberAssignment) == 0 {
 		return nil, nil
@@ -296,6 +299,9 @@ func (gmd *GroupMemberDescription) GetMemberAssignment() (*ConsumerGroupMemberAs
 	return assignment, err
 }
 
+// GetMemberMetadata decodes the subscription of a member of a group using the
+// consumer protocol, including the partitions it owns when the group
+// rebalances cooperatively. It returns nil if the member has no metadata.
 func (gmd *GroupMemberDescription) GetMemberMetadata() (*ConsumerGroupMemberMetadata, error) {
 	if len(gmd.MemberMetadata) == 0 {
 		return nil, nil
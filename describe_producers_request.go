@@ -0,0 +1,98 @@
+package sarama
+
+// DescribeProducersRequest (API key 61) describes the active producers on a
+// set of partitions (KIP-664). The request must be sent to the partition
+// leader.
+type DescribeProducersRequest struct {
+	// Version 0 is currently only supported
+	Version int16
+	// Topics contains the topics and partitions to describe.
+	Topics []*DescribeProducersRequestTopic
+}
+
+// DescribeProducersRequestTopic contains the partitions of a topic to describe.
+type DescribeProducersRequestTopic struct {
+	// Name contains the topic name.
+	Name string
+	// PartitionIndexes contains the indexes of the partitions to describe.
+	PartitionIndexes []int32
+}
+
+func (r *DescribeProducersRequest) setVersion(v int16) {
+	r.Version = v
+}
+
+func (r *DescribeProducersRequest) encode(pe packetEncoder) error {
+	if err := pe.putArrayLength(len(r.Topics)); err != nil {
+		return err
+	}
+	for _, topic := range r.Topics {
+		if err := pe.putString(topic.Name); err != nil {
+			return err
+		}
+		if err := pe.putInt32Array(topic.PartitionIndexes); err != nil {
+			return err
+		}
+		pe.putEmptyTaggedFieldArray()
+	}
+
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *DescribeProducersRequest) decode(pd packetDecoder, version int16) error {
+	r.Version = version
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	if n == -1 {
+		n = 0
+	}
+
+	r.Topics = make([]*DescribeProducersRequestTopic, n)
+	for i := 0; i < n; i++ {
+		topic := &DescribeProducersRequestTopic{}
+		if topic.Name, err = pd.getString(); err != nil {
+			return err
+		}
+		if topic.PartitionIndexes, err = pd.getInt32Array(); err != nil {
+			return err
+		}
+		if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+		r.Topics[i] = topic
+	}
+
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *DescribeProducersRequest) key() int16 {
+	return apiKeyDescribeProducers
+}
+
+func (r *DescribeProducersRequest) version() int16 {
+	return r.Version
+}
+
+func (r *DescribeProducersRequest) headerVersion() int16 {
+	return 2
+}
+
+func (r *DescribeProducersRequest) isValidVersion() bool {
+	return r.Version == 0
+}
+
+func (r *DescribeProducersRequest) isFlexible() bool {
+	return r.isFlexibleVersion(r.Version)
+}
+
+func (r *DescribeProducersRequest) isFlexibleVersion(version int16) bool {
+	return version >= 0
+}
+
+func (r *DescribeProducersRequest) requiredVersion() KafkaVersion {
+	return V2_8_0_0
+}
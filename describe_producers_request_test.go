@@ -0,0 +1,37 @@
+//go:build !functional
+
+package sarama
+
+import "testing"
+
+var (
+	emptyDescribeProducersRequest = []byte{
+		1, // empty topics array
+		0, // empty tagged fields
+	}
+	singleTopicDescribeProducersRequest = []byte{
+		2,                // Topics array length 1
+		4, 'f', 'o', 'o', // Topic name
+		3,          // PartitionIndexes array length 2
+		0, 0, 0, 1, // Partition 1
+		0, 0, 0, 2, // Partition 2
+		0, // empty topic tagged fields
+		0, // empty tagged fields
+	}
+)
+
+func TestDescribeProducersRequest(t *testing.T) {
+	request := &DescribeProducersRequest{
+		Version: 0,
+		Topics:  []*DescribeProducersRequestTopic{},
+	}
+	testRequest(t, "no topics", request, emptyDescribeProducersRequest)
+
+	request.Topics = []*DescribeProducersRequestTopic{
+		{
+			Name:             "foo",
+			PartitionIndexes: []int32{1, 2},
+		},
+	}
+	testRequest(t, "single topic", request, singleTopicDescribeProducersRequest)
+}
@@ -0,0 +1,214 @@
+package sarama
+
+import "time"
+
+// DescribeProducersResponse is the response to a DescribeProducersRequest.
+type DescribeProducersResponse struct {
+	// Version 0 is currently only supported
+	Version int16
+
+	ThrottleTime time.Duration
+
+	Topics []*DescribeProducersResponseTopic
+}
+
+// DescribeProducersResponseTopic contains the results for a single topic.
+type DescribeProducersResponseTopic struct {
+	Name       string
+	Partitions []*DescribeProducersResponsePartition
+}
+
+// DescribeProducersResponsePartition contains the active producers of a
+// single partition, or the error that prevented describing them.
+type DescribeProducersResponsePartition struct {
+	PartitionIndex  int32
+	ErrorCode       KError
+	ErrorMessage    *string
+	ActiveProducers []*ProducerState
+}
+
+// ProducerState describes an active producer on a partition.
+type ProducerState struct {
+	ProducerID       int64
+	ProducerEpoch    int32
+	LastSequence     int32
+	LastTimestamp    int64
+	CoordinatorEpoch int32
+	// CurrentTxnStartOffset contains the first offset of the ongoing
+	// transaction of this producer, or -1 if there is none.
+	CurrentTxnStartOffset int64
+}
+
+func (r *DescribeProducersResponse) setVersion(v int16) {
+	r.Version = v
+}
+
+func (r *DescribeProducersResponse) encode(pe packetEncoder) error {
+	pe.putDurationMs(r.ThrottleTime)
+
+	if err := pe.putArrayLength(len(r.Topics)); err != nil {
+		return err
+	}
+	for _, topic := range r.Topics {
+		if err := pe.putString(topic.Name); err != nil {
+			return err
+		}
+		if err := pe.putArrayLength(len(topic.Partitions)); err != nil {
+			return err
+		}
+		for _, partition := range topic.Partitions {
+			if err := partition.encode(pe); err != nil {
+				return err
+			}
+		}
+		pe.putEmptyTaggedFieldArray()
+	}
+
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *DescribeProducersResponse) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
+	if r.ThrottleTime, err = pd.getDurationMs(); err != nil {
+		return err
+	}
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	if n == -1 {
+		n = 0
+	}
+	r.Topics = make([]*DescribeProducersResponseTopic, n)
+	for i := 0; i < n; i++ {
+		topic := &DescribeProducersResponseTopic{}
+		if topic.Name, err = pd.getString(); err != nil {
+			return err
+		}
+		m, err := pd.getArrayLength()
+		if err != nil {
+			return err
+		}
+		if m == -1 {
+			m = 0
+		}
+		topic.Partitions = make([]*DescribeProducersResponsePartition, m)
+		for j := 0; j < m; j++ {
+			partition := &DescribeProducersResponsePartition{}
+			if err := partition.decode(pd); err != nil {
+				return err
+			}
+			topic.Partitions[j] = partition
+		}
+		if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+		r.Topics[i] = topic
+	}
+
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (p *DescribeProducersResponsePartition) encode(pe packetEncoder) error {
+	pe.putInt32(p.PartitionIndex)
+	pe.putKError(p.ErrorCode)
+	if err := pe.putNullableString(p.ErrorMessage); err != nil {
+		return err
+	}
+	if err := pe.putArrayLength(len(p.ActiveProducers)); err != nil {
+		return err
+	}
+	for _, producer := range p.ActiveProducers {
+		pe.putInt64(producer.ProducerID)
+		pe.putInt32(producer.ProducerEpoch)
+		pe.putInt32(producer.LastSequence)
+		pe.putInt64(producer.LastTimestamp)
+		pe.putInt32(producer.CoordinatorEpoch)
+		pe.putInt64(producer.CurrentTxnStartOffset)
+		pe.putEmptyTaggedFieldArray()
+	}
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (p *DescribeProducersResponsePartition) decode(pd packetDecoder) (err error) {
+	if p.PartitionIndex, err = pd.getInt32(); err != nil {
+		return err
+	}
+	if p.ErrorCode, err = pd.getKError(); err != nil {
+		return err
+	}
+	if p.ErrorMessage, err = pd.getNullableString(); err != nil {
+		return err
+	}
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	if n == -1 {
+		n = 0
+	}
+	p.ActiveProducers = make([]*ProducerState, n)
+	for i := 0; i < n; i++ {
+		producer := &ProducerState{}
+		if producer.ProducerID, err = pd.getInt64(); err != nil {
+			return err
+		}
+		if producer.ProducerEpoch, err = pd.getInt32(); err != nil {
+			return err
+		}
+		if producer.LastSequence, err = pd.getInt32(); err != nil {
+			return err
+		}
+		if producer.LastTimestamp, err = pd.getInt64(); err != nil {
+			return err
+		}
+		if producer.CoordinatorEpoch, err = pd.getInt32(); err != nil {
+			return err
+		}
+		if producer.CurrentTxnStartOffset, err = pd.getInt64(); err != nil {
+			return err
+		}
+		if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+		p.ActiveProducers[i] = producer
+	}
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *DescribeProducersResponse) key() int16 {
+	return apiKeyDescribeProducers
+}
+
+func (r *DescribeProducersResponse) version() int16 {
+	return r.Version
+}
+
+func (r *DescribeProducersResponse) headerVersion() int16 {
+	return 1
+}
+
+func (r *DescribeProducersResponse) isValidVersion() bool {
+	return r.Version == 0
+}
+
+func (r *DescribeProducersResponse) isFlexible() bool {
+	return r.isFlexibleVersion(r.Version)
+}
+
+func (r *DescribeProducersResponse) isFlexibleVersion(version int16) bool {
+	return version >= 0
+}
+
+func (r *DescribeProducersResponse) requiredVersion() KafkaVersion {
+	return V2_8_0_0
+}
+
+func (r *DescribeProducersResponse) throttleTime() time.Duration {
+	return r.ThrottleTime
+}
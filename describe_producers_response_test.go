@@ -0,0 +1,81 @@
+//go:build !functional
+
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	emptyDescribeProducersResponse = []byte{
+		0, 0, 0, 100, // throttle time (100 ms)
+		1, // empty topics array
+		0, // empty tagged fields
+	}
+
+	producersDescribeProducersResponse = []byte{
+		0, 0, 0, 100, // throttle time (100 ms)
+		2,                // Topics array length 1
+		4, 'f', 'o', 'o', // Topic name
+		3,          // Partitions array length 2
+		0, 0, 0, 1, // PartitionIndex
+		0, 0, // ErrorCode
+		0,                      // ErrorMessage
+		2,                      // ActiveProducers array length 1
+		0, 0, 0, 0, 0, 0, 0, 7, // ProducerID
+		0, 0, 0, 2, // ProducerEpoch
+		0, 0, 0, 9, // LastSequence
+		0, 0, 0, 0, 0, 0, 3, 232, // LastTimestamp
+		0, 0, 0, 4, // CoordinatorEpoch
+		0, 0, 0, 0, 0, 0, 0, 42, // CurrentTxnStartOffset
+		0,          // empty producer tagged fields
+		0,          // empty partition tagged fields
+		0, 0, 0, 2, // PartitionIndex
+		0, 6, // ErrorCode
+		6, 'e', 'r', 'r', 'o', 'r', // ErrorMessage
+		1, // empty ActiveProducers array
+		0, // empty partition tagged fields
+		0, // empty topic tagged fields
+		0, // empty tagged fields
+	}
+)
+
+func TestDescribeProducersResponse(t *testing.T) {
+	response := &DescribeProducersResponse{
+		Version:      0,
+		ThrottleTime: 100 * time.Millisecond,
+		Topics:       []*DescribeProducersResponseTopic{},
+	}
+	testResponse(t, "empty", response, emptyDescribeProducersResponse)
+
+	errMsg := "error"
+	response.Topics = []*DescribeProducersResponseTopic{
+		{
+			Name: "foo",
+			Partitions: []*DescribeProducersResponsePartition{
+				{
+					PartitionIndex: 1,
+					ErrorCode:      ErrNoError,
+					ActiveProducers: []*ProducerState{
+						{
+							ProducerID:            7,
+							ProducerEpoch:         2,
+							LastSequence:          9,
+							LastTimestamp:         1000,
+							CoordinatorEpoch:      4,
+							CurrentTxnStartOffset: 42,
+						},
+					},
+				},
+				{
+					PartitionIndex:  2,
+					ErrorCode:       ErrNotLeaderForPartition,
+					ErrorMessage:    &errMsg,
+					ActiveProducers: []*ProducerState{},
+				},
+			},
+		},
+	}
+	testResponse(t, "producers", response, producersDescribeProducersResponse)
+}
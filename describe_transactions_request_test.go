@@ -0,0 +1,28 @@
+//go:build !functional
+
+package sarama
+
+import "testing"
+
+var (
+	emptyDescribeTransactionsRequest = []byte{
+		1, // empty TransactionalIDs array
+		0, // empty tagged fields
+	}
+	idsDescribeTransactionsRequest = []byte{
+		3,                // TransactionalIDs array length 2
+		4, 't', 'x', '1', // TransactionalID
+		4, 't', 'x', '2', // TransactionalID
+		0, // empty tagged fields
+	}
+)
+
+func TestDescribeTransactionsRequest(t *testing.T) {
+	request := &DescribeTransactionsRequest{
+		Version: 0,
+	}
+	testRequest(t, "no transactional IDs", request, emptyDescribeTransactionsRequest)
+
+	request.TransactionalIDs = []string{"tx1", "tx2"}
+	testRequest(t, "transactional IDs", request, idsDescribeTransactionsRequest)
+}
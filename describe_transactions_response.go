@@ -0,0 +1,197 @@
+package sarama
+
+import "time"
+
+// The states of a transaction reported in TransactionState.State.
+const (
+	TransactionStateEmpty             = "Empty"
+	TransactionStateOngoing           = "Ongoing"
+	TransactionStatePrepareCommit     = "PrepareCommit"
+	TransactionStatePrepareAbort      = "PrepareAbort"
+	TransactionStateCompleteCommit    = "CompleteCommit"
+	TransactionStateCompleteAbort     = "CompleteAbort"
+	TransactionStateDead              = "Dead"
+	TransactionStatePrepareEpochFence = "PrepareEpochFence"
+)
+
+// DescribeTransactionsResponse is the response to a DescribeTransactionsRequest.
+type DescribeTransactionsResponse struct {
+	// Version 0 is currently only supported
+	Version int16
+
+	ThrottleTime time.Duration
+
+	TransactionStates []*TransactionState
+}
+
+// TransactionState describes the transaction of a transactional ID, or the
+// error that prevented describing it.
+type TransactionState struct {
+	ErrorCode       KError
+	TransactionalID string
+	// State contains the state of the transaction, one of the
+	// TransactionState* constants.
+	State                  string
+	TransactionTimeoutMs   int32
+	TransactionStartTimeMs int64
+	ProducerID             int64
+	ProducerEpoch          int16
+	// Topics contains the partitions in the transaction, by topic.
+	Topics map[string][]int32
+}
+
+func (r *DescribeTransactionsResponse) setVersion(v int16) {
+	r.Version = v
+}
+
+func (r *DescribeTransactionsResponse) encode(pe packetEncoder) error {
+	pe.putDurationMs(r.ThrottleTime)
+
+	if err := pe.putArrayLength(len(r.TransactionStates)); err != nil {
+		return err
+	}
+	for _, state := range r.TransactionStates {
+		if err := state.encode(pe); err != nil {
+			return err
+		}
+	}
+
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *DescribeTransactionsResponse) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
+	if r.ThrottleTime, err = pd.getDurationMs(); err != nil {
+		return err
+	}
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	if n == -1 {
+		n = 0
+	}
+	r.TransactionStates = make([]*TransactionState, n)
+	for i := 0; i < n; i++ {
+		state := &TransactionState{}
+		if err := state.decode(pd); err != nil {
+			return err
+		}
+		r.TransactionStates[i] = state
+	}
+
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (s *TransactionState) encode(pe packetEncoder) error {
+	pe.putKError(s.ErrorCode)
+	if err := pe.putString(s.TransactionalID); err != nil {
+		return err
+	}
+	if err := pe.putString(s.State); err != nil {
+		return err
+	}
+	pe.putInt32(s.TransactionTimeoutMs)
+	pe.putInt64(s.TransactionStartTimeMs)
+	pe.putInt64(s.ProducerID)
+	pe.putInt16(s.ProducerEpoch)
+
+	if err := pe.putArrayLength(len(s.Topics)); err != nil {
+		return err
+	}
+	for topic, partitions := range s.Topics {
+		if err := pe.putString(topic); err != nil {
+			return err
+		}
+		if err := pe.putInt32Array(partitions); err != nil {
+			return err
+		}
+		pe.putEmptyTaggedFieldArray()
+	}
+
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (s *TransactionState) decode(pd packetDecoder) (err error) {
+	if s.ErrorCode, err = pd.getKError(); err != nil {
+		return err
+	}
+	if s.TransactionalID, err = pd.getString(); err != nil {
+		return err
+	}
+	if s.State, err = pd.getString(); err != nil {
+		return err
+	}
+	if s.TransactionTimeoutMs, err = pd.getInt32(); err != nil {
+		return err
+	}
+	if s.TransactionStartTimeMs, err = pd.getInt64(); err != nil {
+		return err
+	}
+	if s.ProducerID, err = pd.getInt64(); err != nil {
+		return err
+	}
+	if s.ProducerEpoch, err = pd.getInt16(); err != nil {
+		return err
+	}
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	if n == -1 {
+		n = 0
+	}
+	s.Topics = make(map[string][]int32, n)
+	for i := 0; i < n; i++ {
+		topic, err := pd.getString()
+		if err != nil {
+			return err
+		}
+		if s.Topics[topic], err = pd.getInt32Array(); err != nil {
+			return err
+		}
+		if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+	}
+
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *DescribeTransactionsResponse) key() int16 {
+	return apiKeyDescribeTransactions
+}
+
+func (r *DescribeTransactionsResponse) version() int16 {
+	return r.Version
+}
+
+func (r *DescribeTransactionsResponse) headerVersion() int16 {
+	return 1
+}
+
+func (r *DescribeTransactionsResponse) isValidVersion() bool {
+	return r.Version == 0
+}
+
+func (r *DescribeTransactionsResponse) isFlexible() bool {
+	return r.isFlexibleVersion(r.Version)
+}
+
+func (r *DescribeTransactionsResponse) isFlexibleVersion(version int16) bool {
+	return version >= 0
+}
+
+func (r *DescribeTransactionsResponse) requiredVersion() KafkaVersion {
+	return V3_0_0_0
+}
+
+func (r *DescribeTransactionsResponse) throttleTime() time.Duration {
+	return r.ThrottleTime
+}
@@ -0,0 +1,75 @@
+//go:build !functional
+
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	emptyDescribeTransactionsResponse = []byte{
+		0, 0, 0, 100, // throttle time (100 ms)
+		1, // empty TransactionStates array
+		0, // empty tagged fields
+	}
+
+	statesDescribeTransactionsResponse = []byte{
+		0, 0, 0, 100, // throttle time (100 ms)
+		3,    // TransactionStates array length 2
+		0, 0, // ErrorCode
+		4, 't', 'x', '1', // TransactionalID
+		8, 'O', 'n', 'g', 'o', 'i', 'n', 'g', // State
+		0, 0, 234, 96, // TransactionTimeoutMs
+		0, 0, 0, 0, 0, 0, 3, 232, // TransactionStartTimeMs
+		0, 0, 0, 0, 0, 0, 0, 7, // ProducerID
+		0, 2, // ProducerEpoch
+		2,                // Topics array length 1
+		4, 'f', 'o', 'o', // Topic
+		3,          // Partitions array length 2
+		0, 0, 0, 1, // Partition 1
+		0, 0, 0, 2, // Partition 2
+		0,      // empty topic tagged fields
+		0,      // empty state tagged fields
+		0, 105, // ErrorCode
+		4, 't', 'x', '2', // TransactionalID
+		1,          // empty State
+		0, 0, 0, 0, // TransactionTimeoutMs
+		0, 0, 0, 0, 0, 0, 0, 0, // TransactionStartTimeMs
+		255, 255, 255, 255, 255, 255, 255, 255, // ProducerID
+		255, 255, // ProducerEpoch
+		1, // empty Topics array
+		0, // empty state tagged fields
+		0, // empty tagged fields
+	}
+)
+
+func TestDescribeTransactionsResponse(t *testing.T) {
+	response := &DescribeTransactionsResponse{
+		Version:           0,
+		ThrottleTime:      100 * time.Millisecond,
+		TransactionStates: []*TransactionState{},
+	}
+	testResponse(t, "empty", response, emptyDescribeTransactionsResponse)
+
+	response.TransactionStates = []*TransactionState{
+		{
+			ErrorCode:              ErrNoError,
+			TransactionalID:        "tx1",
+			State:                  TransactionStateOngoing,
+			TransactionTimeoutMs:   60000,
+			TransactionStartTimeMs: 1000,
+			ProducerID:             7,
+			ProducerEpoch:          2,
+			Topics:                 map[string][]int32{"foo": {1, 2}},
+		},
+		{
+			ErrorCode:       ErrTransactionalIDNotFound,
+			TransactionalID: "tx2",
+			ProducerID:      -1,
+			ProducerEpoch:   -1,
+			Topics:          map[string][]int32{},
+		},
+	}
+	testResponse(t, "states", response, statesDescribeTransactionsResponse)
+}
@@ -1,8 +1,10 @@
 package sarama
 
 type ElectLeadersRequest struct {
-	Version         int16
-	Type            ElectionType
+	Version int16
+	Type    ElectionType
+	// TopicPartitions contains the partitions to elect leaders for, or nil to
+	// elect leaders for all partitions.
 	TopicPartitions map[string][]int32
 	TimeoutMs       int32
 }
@@ -16,7 +18,11 @@ func (r *ElectLeadersRequest) encode(pe packetEncoder) error {
 		pe.putInt8(int8(r.Type))
 	}
 
-	if err := pe.putArrayLength(len(r.TopicPartitions)); err != nil {
+	if r.TopicPartitions == nil {
+		if err := pe.putArrayLength(-1); err != nil {
+			return err
+		}
+	} else if err := pe.putArrayLength(len(r.TopicPartitions)); err != nil {
 		return err
 	}
 
@@ -48,12 +54,18 @@ func (r *ElectLeadersRequest) decode(pd packetDecoder, version int16) (err error
 		r.Type = ElectionType(t)
 	}
 
-	topicCount, err := pd.getArrayLength()
+	var topicCount int
+	if r.isFlexible() {
+		topicCount, err = getNullableCompactArrayLength(pd)
+	} else {
+		topicCount, err = pd.getArrayLength()
+	}
 	if err != nil {
 		return err
 	}
-	if topicCount > 0 {
-		r.TopicPartitions = make(map[string][]int32)
+	r.TopicPartitions = nil
+	if topicCount >= 0 {
+		r.TopicPartitions = make(map[string][]int32, topicCount)
 		for i := 0; i < topicCount; i++ {
 			topic, err := pd.getString()
 			if err != nil {
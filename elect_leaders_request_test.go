@@ -26,6 +26,17 @@ var (
 		0, 0, 39, 16, // timeout 10000
 		0, // empty tagged fields
 	}
+	electLeadersRequestAllTopicsV1 = []byte{
+		1,                  // unclean election type
+		255, 255, 255, 255, // null topics
+		0, 0, 39, 16, // timeout 10000
+	}
+	electLeadersRequestAllTopicsV2 = []byte{
+		1,            // unclean election type
+		0,            // null topics
+		0, 0, 39, 16, // timeout 10000
+		0, // empty tagged fields
+	}
 )
 
 func TestElectLeadersRequest(t *testing.T) {
@@ -42,6 +53,16 @@ func TestElectLeadersRequest(t *testing.T) {
 
 	request.Version = 2
 	testRequest(t, "one topic V2", request, electLeadersRequestOneTopicV2)
+
+	request = &ElectLeadersRequest{
+		TimeoutMs: int32(10000),
+		Version:   int16(1),
+		Type:      UncleanElection,
+	}
+	testRequest(t, "all topics V1", request, electLeadersRequestAllTopicsV1)
+
+	request.Version = 2
+	testRequest(t, "all topics V2", request, electLeadersRequestAllTopicsV2)
 }
 
 func TestElectLeadersRequestHeaderVersion(t *testing.T) {
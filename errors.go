@@ -30,6 +30,10 @@ var ErrAlreadyConnected = errors.New("kafka: broker connection already initiated
 // ErrNotConnected is the error returned when trying to send or call Close() on a Broker that is not connected.
 var ErrNotConnected = errors.New("kafka: broker not connected")
 
+// ErrReconnectBackoff is the error returned when trying to use a Broker whose last connection attempt
+// failed, before its reconnect backoff (see Config.Net.ReconnectBackoff) has elapsed. It wraps ErrNotConnected.
+var ErrReconnectBackoff = fmt.Errorf("%w: waiting for the reconnect backoff", ErrNotConnected)
+
 // ErrInsufficientData is returned when decoding and the packet is truncated. This can be expected
 // when requesting messages, since as an optimization the server is allowed to return a partial message at the end
 // of the message set.
@@ -41,6 +45,10 @@ var ErrShuttingDown = errors.New("kafka: message received by producer in process
 // ErrMessageTooLarge is returned when the next message to consume is larger than the configured Consumer.Fetch.Max
 var ErrMessageTooLarge = errors.New("kafka: message is larger than Consumer.Fetch.Max")
 
+// ErrResponseTooLarge is returned when a broker response declares a size larger
+// than Net.MaxResponseSize. The connection to the broker is unusable afterwards.
+var ErrResponseTooLarge = errors.New("kafka: response is larger than Net.MaxResponseSize")
+
 // ErrConsumerOffsetNotAdvanced is returned when a partition consumer didn't advance its offset after parsing
 // a RecordBatch.
 var ErrConsumerOffsetNotAdvanced = errors.New("kafka: consumer offset was not advanced after a RecordBatch")
@@ -51,6 +59,10 @@ var ErrConsumerOffsetNotAdvanced = errors.New("kafka: consumer offset was not ad
 // ends the session and triggers a fresh rejoin.
 var ErrConsumerRetriesExhausted = errors.New("kafka: partition consumer giving up after consecutive failures")
 
+// ErrLogTruncated is returned when a partition consumer detects that the log of its partition was truncated
+// below the offset it consumed up to, typically after an unclean leader election. See LogTruncationError.
+var ErrLogTruncated = errors.New("kafka: log truncation detected")
+
 // ErrControllerNotAvailable is returned when server didn't give correct controller id. May be kafka server's version
 // is lower than 0.10.0.0.
 var ErrControllerNotAvailable = errors.New("kafka: controller is not available")
@@ -59,6 +71,10 @@ var ErrControllerNotAvailable = errors.New("kafka: controller is not available")
 // the metadata.
 var ErrNoTopicsToUpdateMetadata = errors.New("kafka: no specific topics to update metadata")
 
+// ErrTopicIDNotAvailable is returned by Client.TopicID when the brokers do not report topic IDs,
+// which requires Kafka 2.8 or later.
+var ErrTopicIDNotAvailable = errors.New("kafka: topic ID is not available from the cluster metadata")
+
 // ErrUnknownScramMechanism is returned when user tries to AlterUserScramCredentials with unknown SCRAM mechanism
 var ErrUnknownScramMechanism = errors.New("kafka: unknown SCRAM mechanism provided")
 
@@ -264,6 +280,20 @@ const (
 	ErrUnstableOffsetCommit               KError = 88 // Errors.UNSTABLE_OFFSET_COMMIT
 	ErrThrottlingQuotaExceeded            KError = 89 // Errors.THROTTLING_QUOTA_EXCEEDED
 	ErrProducerFenced                     KError = 90 // Errors.PRODUCER_FENCED
+
+	// KIP-554 SCRAM credential errors
+	ErrResourceNotFound       KError = 91 // Errors.RESOURCE_NOT_FOUND
+	ErrDuplicateResource      KError = 92 // Errors.DUPLICATE_RESOURCE
+	ErrUnacceptableCredential KError = 93 // Errors.UNACCEPTABLE_CREDENTIAL
+
+	// KIP-664 transaction admin errors
+	ErrTransactionalIDNotFound KError = 105 // Errors.TRANSACTIONAL_ID_NOT_FOUND
+
+	// KIP-848 consumer group protocol errors
+	ErrFencedMemberEpoch    KError = 110 // Errors.FENCED_MEMBER_EPOCH
+	ErrUnreleasedInstanceId KError = 111 // Errors.UNRELEASED_INSTANCE_ID
+	ErrUnsupportedAssignor  KError = 112 // Errors.UNSUPPORTED_ASSIGNOR
+	ErrStaleMemberEpoch     KError = 113 // Errors.STALE_MEMBER_EPOCH
 )
 
 func (err KError) Error() string {
@@ -450,6 +480,22 @@ func (err KError) Error() string {
 		return "kafka server: This record has failed the validation on broker and hence will be rejected"
 	case ErrUnstableOffsetCommit:
 		return "kafka server: There are unstable offsets that need to be cleared"
+	case ErrResourceNotFound:
+		return "kafka server: A request illegally referred to a resource that does not exist"
+	case ErrDuplicateResource:
+		return "kafka server: A request illegally referred to the same resource twice"
+	case ErrUnacceptableCredential:
+		return "kafka server: Requested credential would not meet criteria for acceptability"
+	case ErrTransactionalIDNotFound:
+		return "kafka server: The transactionalId could not be found"
+	case ErrFencedMemberEpoch:
+		return "kafka server: The member epoch is fenced by the group coordinator. The member must abandon all its partitions and rejoin"
+	case ErrUnreleasedInstanceId:
+		return "kafka server: The instance ID is still used by another member in the consumer group. That member must leave first"
+	case ErrUnsupportedAssignor:
+		return "kafka server: The assignor or its version range is not supported by the consumer group"
+	case ErrStaleMemberEpoch:
+		return "kafka server: The member epoch is stale. The member must retry after receiving its updated member epoch via the ConsumerGroupHeartbeat API"
 	}
 
 	return fmt.Sprintf("Unknown error, how did this happen? Error code = %d", err)
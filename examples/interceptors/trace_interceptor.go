@@ -42,20 +42,9 @@ const (
 
 func shouldIgnoreMsg(msg *sarama.ProducerMessage) bool {
 	// check message hasn't been here before (retries)
-	var traceFound, spanFound, msgIDFound bool
-	for _, h := range msg.Headers {
-		if string(h.Key) == TraceHeaderName {
-			traceFound = true
-			continue
-		}
-		if string(h.Key) == SpanHeaderName {
-			spanFound = true
-			continue
-		}
-		if string(h.Key) == MessageIDHeaderName {
-			msgIDFound = true
-		}
-	}
+	_, traceFound := msg.Header([]byte(TraceHeaderName))
+	_, spanFound := msg.Header([]byte(SpanHeaderName))
+	_, msgIDFound := msg.Header([]byte(MessageIDHeaderName))
 	return traceFound && spanFound && msgIDFound
 }
 
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/IBM/sarama"
+)
+
+// Sarama configuration options
+var (
+	brokers          = ""
+	version          = ""
+	group            = ""
+	topics           = ""
+	destinationTopic = ""
+	transactionalID  = ""
+	oldest           = true
+	verbose          = false
+)
+
+func init() {
+	flag.StringVar(&brokers, "brokers", "", "Kafka bootstrap brokers to connect to, as a comma separated list")
+	flag.StringVar(&group, "group", "", "Kafka consumer group definition")
+	flag.StringVar(&version, "version", sarama.V2_5_0_0.String(), "Kafka cluster version, at least 2.5.0")
+	flag.StringVar(&topics, "topics", "", "Kafka topics to be consumed, as a comma separated list")
+	flag.StringVar(&destinationTopic, "destination-topic", "", "Kafka topic where records will be copied from topics.")
+	flag.StringVar(&transactionalID, "transactional-id", "sarama", "Transactional ID of the producer, unique per running instance")
+	flag.BoolVar(&oldest, "oldest", true, "Kafka consumer consume initial offset from oldest")
+	flag.BoolVar(&verbose, "verbose", false, "Sarama logging")
+	flag.Parse()
+
+	if len(brokers) == 0 {
+		panic("no Kafka bootstrap brokers defined, please set the -brokers flag")
+	}
+
+	if len(topics) == 0 {
+		panic("no topics given to be consumed, please set the -topics flag")
+	}
+
+	if len(destinationTopic) == 0 {
+		panic("no destination topics given to be consumed, please set the -destination-topics flag")
+	}
+
+	if len(group) == 0 {
+		panic("no Kafka consumer group defined, please set the -group flag")
+	}
+}
+
+func main() {
+	log.Println("Starting a new Sarama consumer")
+
+	if verbose {
+		sarama.Logger = log.New(os.Stdout, "[sarama] ", log.LstdFlags)
+	}
+
+	version, err := sarama.ParseKafkaVersion(version)
+	if err != nil {
+		log.Panicf("Error parsing Kafka version: %v", err)
+	}
+	if !version.IsAtLeast(sarama.V2_5_0_0) {
+		log.Panicf("SendOffsetsAndCommit requires Kafka 2.5.0 or later, got %s", version)
+	}
+
+	config := sarama.NewConfig()
+	config.Version = version
+	if oldest {
+		config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	}
+	config.Consumer.IsolationLevel = sarama.ReadCommitted
+	config.Consumer.Offsets.AutoCommit.Enable = false
+
+	/**
+	 * A single transactional producer is enough: the group coordinator fences
+	 * the offsets sent by a member that is no longer part of the group, so the
+	 * transactional ID does not need to be derived from the claimed partitions.
+	 */
+	producerConfig := sarama.NewConfig()
+	producerConfig.Version = version
+	producerConfig.Net.MaxOpenRequests = 1
+	producerConfig.Producer.RequiredAcks = sarama.WaitForAll
+	producerConfig.Producer.Idempotent = true
+	producerConfig.Producer.Transaction.ID = transactionalID
+	producer, err := sarama.NewAsyncProducer(strings.Split(brokers, ","), producerConfig)
+	if err != nil {
+		log.Panicf("Error creating transactional producer: %v", err)
+	}
+
+	consumer := Consumer{
+		groupID:  group,
+		producer: producer,
+		ready:    make(chan bool),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client, err := sarama.NewConsumerGroup(strings.Split(brokers, ","), group, config)
+	if err != nil {
+		log.Panicf("Error creating consumer group client: %v", err)
+	}
+
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			if err := client.Consume(ctx, strings.Split(topics, ","), &consumer); err != nil {
+				if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+					return
+				}
+				log.Panicf("Error from consumer: %v", err)
+			}
+			// check if context was cancelled, signaling that the consumer should stop
+			if ctx.Err() != nil {
+				return
+			}
+			consumer.ready = make(chan bool)
+		}
+	}()
+
+	<-consumer.ready // Await till the consumer has been set up
+	log.Println("Sarama consumer up and running!...")
+
+	sigterm := make(chan os.Signal, 1)
+	signal.Notify(sigterm, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case <-ctx.Done():
+		log.Println("terminating: context cancelled")
+	case <-sigterm:
+		log.Println("terminating: via signal")
+	}
+	cancel()
+	wg.Wait()
+
+	if err = client.Close(); err != nil {
+		log.Panicf("Error closing client: %v", err)
+	}
+	if err = producer.Close(); err != nil {
+		log.Panicf("Error closing producer: %v", err)
+	}
+}
+
+// Consumer represents a Sarama consumer group consumer
+type Consumer struct {
+	ready   chan bool
+	groupID string
+
+	// producerLock serializes the transactions of the claims, which share the
+	// producer
+	producerLock sync.Mutex
+	producer     sarama.AsyncProducer
+}
+
+// Setup is run at the beginning of a new session, before ConsumeClaim
+func (consumer *Consumer) Setup(sarama.ConsumerGroupSession) error {
+	// Mark the consumer as ready
+	close(consumer.ready)
+	return nil
+}
+
+// Cleanup is run at the end of a session, once all ConsumeClaim goroutines have exited
+func (consumer *Consumer) Cleanup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+// ConsumeClaim must start a consumer loop of ConsumerGroupClaim's Messages().
+// Once the Messages() channel is closed, the Handler must finish its processing
+// loop and exit.
+func (consumer *Consumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for {
+		select {
+		case message, ok := <-claim.Messages():
+			if !ok {
+				log.Printf("message channel was closed")
+				return nil
+			}
+			if err := consumer.copyMessage(session, message); err != nil {
+				// the transaction was aborted, consume the message again
+				log.Printf("Message consumer: unable to copy message: %v", err)
+				session.ResetOffset(message.Topic, message.Partition, message.Offset, "")
+				return err
+			}
+			log.Printf("Message copied: value = %s, timestamp = %v, topic = %s, partition = %d", string(message.Value), message.Timestamp, message.Topic, message.Partition)
+		// Should return when `session.Context()` is done.
+		case <-session.Context().Done():
+			return nil
+		}
+	}
+}
+
+// copyMessage produces message to the destination topic and commits its
+// offset in the same transaction.
+func (consumer *Consumer) copyMessage(session sarama.ConsumerGroupSession, message *sarama.ConsumerMessage) error {
+	consumer.producerLock.Lock()
+	defer consumer.producerLock.Unlock()
+
+	if err := consumer.producer.BeginTxn(); err != nil {
+		return err
+	}
+	consumer.producer.Input() <- &sarama.ProducerMessage{
+		Topic: destinationTopic,
+		Key:   sarama.ByteEncoder(message.Key),
+		Value: sarama.ByteEncoder(message.Value),
+	}
+
+	offsets := map[string][]*sarama.PartitionOffsetMetadata{
+		message.Topic: {{Partition: message.Partition, Offset: message.Offset + 1, LeaderEpoch: -1}},
+	}
+	groupMetadata := &sarama.ConsumerGroupMetadata{
+		GroupID:      consumer.groupID,
+		GenerationID: session.GenerationID(),
+		MemberID:     session.MemberID(),
+	}
+	err := consumer.producer.SendOffsetsAndCommit(session.Context(), offsets, groupMetadata)
+	if err == nil {
+		err = consumer.producer.CommitTxn()
+	}
+	if err == nil {
+		return nil
+	}
+
+	switch {
+	case errors.Is(err, sarama.ErrProducerFenced), errors.Is(err, sarama.ErrInvalidProducerEpoch):
+		// another instance uses the same transactional ID, this one must stop
+		log.Panicf("Transactional producer fenced: %v", err)
+	case consumer.producer.TxnStatus()&sarama.ProducerTxnFlagFatalError != 0:
+		log.Panicf("Transactional producer in a fatal state: %v", err)
+	}
+	// the member was fenced by a rebalance or the transaction failed, either
+	// way the message is copied again by the member claiming its partition
+	if abortErr := consumer.producer.AbortTxn(); abortErr != nil {
+		return errors.Join(err, abortErr)
+	}
+	return err
+}
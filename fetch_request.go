@@ -1,6 +1,9 @@
 package sarama
 
-import "fmt"
+import (
+	"encoding/binary"
+	"fmt"
+)
 
 type fetchRequestBlock struct {
 	Version int16
@@ -8,6 +11,9 @@ type fetchRequestBlock struct {
 	currentLeaderEpoch int32
 	// fetchOffset contains the message offset.
 	fetchOffset int64
+	// lastFetchedEpoch contains the epoch of the last fetched record or -1 if
+	// there is none.
+	lastFetchedEpoch int32
 	// logStartOffset contains the earliest available offset of the follower
 	// replica.  The field is only used when the request is sent by the
 	// follower.
@@ -23,10 +29,14 @@ func (b *fetchRequestBlock) encode(pe packetEncoder, version int16) error {
 		pe.putInt32(b.currentLeaderEpoch)
 	}
 	pe.putInt64(b.fetchOffset)
+	if b.Version >= 12 {
+		pe.putInt32(b.lastFetchedEpoch)
+	}
 	if b.Version >= 5 {
 		pe.putInt64(b.logStartOffset)
 	}
 	pe.putInt32(b.maxBytes)
+	pe.putEmptyTaggedFieldArray()
 	return nil
 }
 
@@ -40,6 +50,11 @@ func (b *fetchRequestBlock) decode(pd packetDecoder, version int16) (err error)
 	if b.fetchOffset, err = pd.getInt64(); err != nil {
 		return err
 	}
+	if b.Version >= 12 {
+		if b.lastFetchedEpoch, err = pd.getInt32(); err != nil {
+			return err
+		}
+	}
 	if b.Version >= 5 {
 		if b.logStartOffset, err = pd.getInt64(); err != nil {
 			return err
@@ -48,9 +63,39 @@ func (b *fetchRequestBlock) decode(pd packetDecoder, version int16) (err error)
 	if b.maxBytes, err = pd.getInt32(); err != nil {
 		return err
 	}
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+// FetchReplicaState contains the state of the replica in the follower. It is
+// only sent from version 15 onwards and replaces the top-level ReplicaID.
+type FetchReplicaState struct {
+	// ReplicaID contains the replica ID of the follower, or -1 if this request
+	// is from a consumer.
+	ReplicaID int32
+	// ReplicaEpoch contains the epoch of this follower, or -1 if not
+	// available.
+	ReplicaEpoch int64
+}
+
+func (s *FetchReplicaState) encode(pe packetEncoder) error {
+	pe.putInt32(s.ReplicaID)
+	pe.putInt64(s.ReplicaEpoch)
+	pe.putEmptyTaggedFieldArray()
 	return nil
 }
 
+func (s *FetchReplicaState) decode(pd packetDecoder) (err error) {
+	if s.ReplicaID, err = pd.getInt32(); err != nil {
+		return err
+	}
+	if s.ReplicaEpoch, err = pd.getInt64(); err != nil {
+		return err
+	}
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
 // FetchRequest (API key 1) will fetch Kafka messages. Version 3 introduced the MaxBytes field. See
 // https://issues.apache.org/jira/browse/KAFKA-2063 for a discussion of the issues leading up to that.  The KIP is at
 // https://cwiki.apache.org/confluence/display/KAFKA/KIP-74%3A+Add+Fetch+Response+Size+Limit+in+Bytes
@@ -83,10 +128,22 @@ type FetchRequest struct {
 	SessionEpoch int32
 	// blocks contains the topics to fetch.
 	blocks map[string]map[int32]*fetchRequestBlock
+	// topicIDBlocks contains the topics to fetch, identified by topic ID
+	// (version 13+).
+	topicIDBlocks map[Uuid]map[int32]*fetchRequestBlock
 	// forgotten contains in an incremental fetch request, the partitions to remove.
 	forgotten map[string][]int32
+	// forgottenTopicIDs contains in an incremental fetch request, the
+	// partitions to remove, identified by topic ID (version 13+).
+	forgottenTopicIDs map[Uuid][]int32
 	// RackID contains a Rack ID of the consumer making this request
 	RackID string
+	// ClusterID contains the clusterId if known. This is used to validate
+	// metadata fetches prior to broker registration (version 12+, tagged).
+	ClusterID *string
+	// ReplicaState contains the state of the replica in the follower
+	// (version 15+, tagged). It is left nil for consumers.
+	ReplicaState *FetchReplicaState
 }
 
 func (r *FetchRequest) setVersion(v int16) {
@@ -103,7 +160,9 @@ const (
 func (r *FetchRequest) encode(pe packetEncoder) (err error) {
 	metricRegistry := pe.metricRegistry()
 
-	pe.putInt32(-1) // ReplicaID is always -1 for clients
+	if r.Version < 15 {
+		pe.putInt32(-1) // ReplicaID is always -1 for clients
+	}
 	pe.putInt32(r.MaxWaitTime)
 	pe.putInt32(r.MinBytes)
 	if r.Version >= 3 {
@@ -116,29 +175,56 @@ func (r *FetchRequest) encode(pe packetEncoder) (err error) {
 		pe.putInt32(r.SessionID)
 		pe.putInt32(r.SessionEpoch)
 	}
-	err = pe.putArrayLength(len(r.blocks))
-	if err != nil {
-		return err
-	}
-	for topic, blocks := range r.blocks {
-		err = pe.putString(topic)
+	if r.Version >= 13 {
+		err = pe.putArrayLength(len(r.topicIDBlocks))
 		if err != nil {
 			return err
 		}
-		err = pe.putArrayLength(len(blocks))
+		for topicID, blocks := range r.topicIDBlocks {
+			err = pe.putRawBytes(topicID[:])
+			if err != nil {
+				return err
+			}
+			err = r.encodeBlocks(pe, blocks)
+			if err != nil {
+				return err
+			}
+			// the topic name is not known, so there is no per-topic meter to mark
+		}
+	} else {
+		err = pe.putArrayLength(len(r.blocks))
 		if err != nil {
 			return err
 		}
-		for partition, block := range blocks {
-			pe.putInt32(partition)
-			err = block.encode(pe, r.Version)
+		for topic, blocks := range r.blocks {
+			err = pe.putString(topic)
+			if err != nil {
+				return err
+			}
+			err = r.encodeBlocks(pe, blocks)
 			if err != nil {
 				return err
 			}
+			getOrRegisterTopicMeter("consumer-fetch-rate", topic, metricRegistry).Mark(1)
 		}
-		getOrRegisterTopicMeter("consumer-fetch-rate", topic, metricRegistry).Mark(1)
 	}
-	if r.Version >= 7 {
+	if r.Version >= 13 {
+		err = pe.putArrayLength(len(r.forgottenTopicIDs))
+		if err != nil {
+			return err
+		}
+		for topicID, partitions := range r.forgottenTopicIDs {
+			err = pe.putRawBytes(topicID[:])
+			if err != nil {
+				return err
+			}
+			err = pe.putInt32Array(partitions)
+			if err != nil {
+				return err
+			}
+			pe.putEmptyTaggedFieldArray()
+		}
+	} else if r.Version >= 7 {
 		err = pe.putArrayLength(len(r.forgotten))
 		if err != nil {
 			return err
@@ -155,6 +241,7 @@ func (r *FetchRequest) encode(pe packetEncoder) (err error) {
 			for _, partition := range partitions {
 				pe.putInt32(partition)
 			}
+			pe.putEmptyTaggedFieldArray()
 		}
 	}
 	if r.Version >= 11 {
@@ -164,14 +251,67 @@ func (r *FetchRequest) encode(pe packetEncoder) (err error) {
 		}
 	}
 
+	if r.Version >= 12 {
+		return r.encodeTaggedFields(pe)
+	}
+
+	return nil
+}
+
+func (r *FetchRequest) encodeBlocks(pe packetEncoder, blocks map[int32]*fetchRequestBlock) error {
+	err := pe.putArrayLength(len(blocks))
+	if err != nil {
+		return err
+	}
+	for partition, block := range blocks {
+		pe.putInt32(partition)
+		err = block.encode(pe, r.Version)
+		if err != nil {
+			return err
+		}
+	}
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *FetchRequest) encodeTaggedFields(pe packetEncoder) error {
+	var numTaggedFields uint64
+	if r.ClusterID != nil {
+		numTaggedFields++
+	}
+	if r.Version >= 15 && r.ReplicaState != nil {
+		numTaggedFields++
+	}
+	pe.putUVarint(numTaggedFields)
+
+	if r.ClusterID != nil {
+		var tmp [binary.MaxVarintLen64]byte
+		length := binary.PutUvarint(tmp[:], uint64(len(*r.ClusterID)+1)) + len(*r.ClusterID)
+		pe.putUVarint(0) // tag
+		pe.putUVarint(uint64(length))
+		if err := pe.putNullableString(r.ClusterID); err != nil {
+			return err
+		}
+	}
+
+	if r.Version >= 15 && r.ReplicaState != nil {
+		pe.putUVarint(1)  // tag
+		pe.putUVarint(13) // replicaID (4) + replicaEpoch (8) + empty tagged fields (1)
+		if err := r.ReplicaState.encode(pe); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
 func (r *FetchRequest) decode(pd packetDecoder, version int16) (err error) {
 	r.Version = version
 
-	if _, err = pd.getInt32(); err != nil {
-		return err
+	if r.Version < 15 {
+		if _, err = pd.getInt32(); err != nil {
+			return err
+		}
 	}
 	if r.MaxWaitTime, err = pd.getInt32(); err != nil {
 		return err
@@ -205,34 +345,61 @@ func (r *FetchRequest) decode(pd packetDecoder, version int16) (err error) {
 	if err != nil {
 		return err
 	}
-	if topicCount == 0 {
+	if topicCount == 0 && r.Version < 12 {
 		return nil
 	}
-	r.blocks = make(map[string]map[int32]*fetchRequestBlock)
+	if topicCount > 0 {
+		if r.Version >= 13 {
+			r.topicIDBlocks = make(map[Uuid]map[int32]*fetchRequestBlock)
+		} else {
+			r.blocks = make(map[string]map[int32]*fetchRequestBlock)
+		}
+	}
 	for i := 0; i < topicCount; i++ {
-		topic, err := pd.getString()
-		if err != nil {
-			return err
+		if r.Version >= 13 {
+			topicID, err := pd.getRawBytes(16)
+			if err != nil {
+				return err
+			}
+			blocks, err := r.decodeBlocks(pd)
+			if err != nil {
+				return err
+			}
+			r.topicIDBlocks[Uuid(topicID)] = blocks
+		} else {
+			topic, err := pd.getString()
+			if err != nil {
+				return err
+			}
+			blocks, err := r.decodeBlocks(pd)
+			if err != nil {
+				return err
+			}
+			r.blocks[topic] = blocks
 		}
-		partitionCount, err := pd.getArrayLength()
+	}
+
+	if r.Version >= 13 {
+		forgottenCount, err := pd.getArrayLength()
 		if err != nil {
 			return err
 		}
-		r.blocks[topic] = make(map[int32]*fetchRequestBlock)
-		for j := 0; j < partitionCount; j++ {
-			partition, err := pd.getInt32()
+		r.forgottenTopicIDs = make(map[Uuid][]int32)
+		for i := 0; i < forgottenCount; i++ {
+			topicID, err := pd.getRawBytes(16)
 			if err != nil {
 				return err
 			}
-			fetchBlock := &fetchRequestBlock{}
-			if err = fetchBlock.decode(pd, r.Version); err != nil {
+			partitions, err := pd.getInt32Array()
+			if err != nil {
+				return err
+			}
+			r.forgottenTopicIDs[Uuid(topicID)] = partitions
+			if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
 				return err
 			}
-			r.blocks[topic][partition] = fetchBlock
 		}
-	}
-
-	if r.Version >= 7 {
+	} else if r.Version >= 7 {
 		forgottenCount, err := pd.getArrayLength()
 		if err != nil {
 			return err
@@ -259,6 +426,9 @@ func (r *FetchRequest) decode(pd packetDecoder, version int16) (err error) {
 				}
 				r.forgotten[topic][j] = partition
 			}
+			if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -269,9 +439,45 @@ func (r *FetchRequest) decode(pd packetDecoder, version int16) (err error) {
 		}
 	}
 
+	if r.Version >= 12 {
+		return pd.getTaggedFieldArray(taggedFieldDecoders{
+			0: func(pd packetDecoder) (err error) {
+				r.ClusterID, err = pd.getNullableString()
+				return err
+			},
+			1: func(pd packetDecoder) error {
+				r.ReplicaState = &FetchReplicaState{}
+				return r.ReplicaState.decode(pd)
+			},
+		})
+	}
+
 	return nil
 }
 
+func (r *FetchRequest) decodeBlocks(pd packetDecoder) (map[int32]*fetchRequestBlock, error) {
+	partitionCount, err := pd.getArrayLength()
+	if err != nil {
+		return nil, err
+	}
+	blocks := make(map[int32]*fetchRequestBlock)
+	for j := 0; j < partitionCount; j++ {
+		partition, err := pd.getInt32()
+		if err != nil {
+			return nil, err
+		}
+		fetchBlock := &fetchRequestBlock{}
+		if err = fetchBlock.decode(pd, r.Version); err != nil {
+			return nil, err
+		}
+		blocks[partition] = fetchBlock
+	}
+	if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
 func (r *FetchRequest) key() int16 {
 	return apiKeyFetch
 }
@@ -281,15 +487,34 @@ func (r *FetchRequest) version() int16 {
 }
 
 func (r *FetchRequest) headerVersion() int16 {
+	if r.Version >= 12 {
+		return 2
+	}
 	return 1
 }
 
 func (r *FetchRequest) isValidVersion() bool {
-	return r.Version >= 0 && r.Version <= 11
+	return r.Version >= 0 && r.Version <= 16
+}
+
+func (r *FetchRequest) isFlexible() bool {
+	return r.isFlexibleVersion(r.Version)
+}
+
+func (r *FetchRequest) isFlexibleVersion(version int16) bool {
+	return version >= 12
 }
 
 func (r *FetchRequest) requiredVersion() KafkaVersion {
 	switch r.Version {
+	case 16:
+		return V3_7_0_0
+	case 14, 15:
+		return V3_5_0_0
+	case 13:
+		return V3_1_0_0
+	case 12:
+		return V2_7_0_0
 	case 11:
 		return V2_3_0_0
 	case 9, 10:
@@ -311,7 +536,7 @@ func (r *FetchRequest) requiredVersion() KafkaVersion {
 	case 0:
 		return V0_8_2_0
 	default:
-		return V2_3_0_0
+		return V3_7_0_0
 	}
 }
 
@@ -328,6 +553,41 @@ func (r *FetchRequest) AddBlock(topic string, partitionID int32, fetchOffset int
 		r.blocks[topic] = make(map[int32]*fetchRequestBlock)
 	}
 
+	r.blocks[topic][partitionID] = r.newBlock(fetchOffset, maxBytes, leaderEpoch)
+}
+
+// AddBlockWithTopicID adds a partition to fetch for the topic identified by
+// topicID. Topic IDs are only used on the wire from version 13 onwards, where
+// they replace the topic names added with AddBlock.
+func (r *FetchRequest) AddBlockWithTopicID(topicID Uuid, partitionID int32, fetchOffset int64, maxBytes int32, leaderEpoch int32) {
+	if r.topicIDBlocks == nil {
+		r.topicIDBlocks = make(map[Uuid]map[int32]*fetchRequestBlock)
+	}
+
+	if r.forgottenTopicIDs == nil {
+		r.forgottenTopicIDs = make(map[Uuid][]int32)
+	}
+
+	if r.topicIDBlocks[topicID] == nil {
+		r.topicIDBlocks[topicID] = make(map[int32]*fetchRequestBlock)
+	}
+
+	r.topicIDBlocks[topicID][partitionID] = r.newBlock(fetchOffset, maxBytes, leaderEpoch)
+}
+
+// setLastFetchedEpoch sets the epoch of the last record batch fetched from a
+// partition added with AddBlock, letting the leader detect log truncation
+// from version 12 onwards (KIP-320).
+func (r *FetchRequest) setLastFetchedEpoch(topic string, partitionID int32, epoch int32) {
+	if r.Version < 12 {
+		return
+	}
+	if block := r.blocks[topic][partitionID]; block != nil {
+		block.lastFetchedEpoch = epoch
+	}
+}
+
+func (r *FetchRequest) newBlock(fetchOffset int64, maxBytes int32, leaderEpoch int32) *fetchRequestBlock {
 	tmp := new(fetchRequestBlock)
 	tmp.Version = r.Version
 	tmp.maxBytes = maxBytes
@@ -335,6 +595,8 @@ func (r *FetchRequest) AddBlock(topic string, partitionID int32, fetchOffset int
 	if r.Version >= 9 {
 		tmp.currentLeaderEpoch = leaderEpoch
 	}
-
-	r.blocks[topic][partitionID] = tmp
+	if r.Version >= 12 {
+		tmp.lastFetchedEpoch = invalidLeaderEpoch
+	}
+	return tmp
 }
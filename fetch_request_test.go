@@ -2,7 +2,12 @@
 
 package sarama
 
-import "testing"
+import (
+	"fmt"
+	"testing"
+
+	"github.com/rcrowley/go-metrics"
+)
 
 var (
 	fetchRequestNoBlocks = []byte{
@@ -50,6 +55,79 @@ var (
 		0x00, 0x00, 0x00, 0x00,
 		0x00, 0x06, 'r', 'a', 'c', 'k', '0', '1', // rackID
 	}
+
+	fetchRequestOneBlockV12 = []byte{
+		0xFF, 0xFF, 0xFF, 0xFF, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0xFF,
+		0x01,
+		0x00, 0x00, 0x00, 0xAA, // sessionID
+		0x00, 0x00, 0x00, 0xEE, // sessionEpoch
+		0x02,
+		0x06, 't', 'o', 'p', 'i', 'c',
+		0x02,
+		0x00, 0x00, 0x00, 0x12, // partitionID
+		0x00, 0x00, 0x00, 0x66, // currentLeaderEpoch
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x34, // fetchOffset
+		0xFF, 0xFF, 0xFF, 0xFF, // lastFetchedEpoch
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // logStartOffset
+		0x00, 0x00, 0x00, 0x56, // maxBytes
+		0x00,                               // partition tagged fields
+		0x00,                               // topic tagged fields
+		0x01,                               // forgotten topics
+		0x07, 'r', 'a', 'c', 'k', '0', '1', // rackID
+		0x00, // tagged fields
+	}
+
+	fetchRequestOneBlockV13 = []byte{
+		0xFF, 0xFF, 0xFF, 0xFF, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0xFF,
+		0x01,
+		0x00, 0x00, 0x00, 0xAA, // sessionID
+		0x00, 0x00, 0x00, 0xEE, // sessionEpoch
+		0x02,
+		0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
+		0x09, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E, 0x0F, 0x10, // topicID
+		0x02,
+		0x00, 0x00, 0x00, 0x12, // partitionID
+		0x00, 0x00, 0x00, 0x66, // currentLeaderEpoch
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x34, // fetchOffset
+		0xFF, 0xFF, 0xFF, 0xFF, // lastFetchedEpoch
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // logStartOffset
+		0x00, 0x00, 0x00, 0x56, // maxBytes
+		0x00,                               // partition tagged fields
+		0x00,                               // topic tagged fields
+		0x01,                               // forgotten topics
+		0x07, 'r', 'a', 'c', 'k', '0', '1', // rackID
+		0x01,                       // tagged fields
+		0x00, 0x03, 0x03, 'c', '1', // clusterID
+	}
+
+	fetchRequestOneBlockV15 = []byte{
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0xFF,
+		0x01,
+		0x00, 0x00, 0x00, 0xAA, // sessionID
+		0x00, 0x00, 0x00, 0xEE, // sessionEpoch
+		0x02,
+		0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
+		0x09, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E, 0x0F, 0x10, // topicID
+		0x02,
+		0x00, 0x00, 0x00, 0x12, // partitionID
+		0x00, 0x00, 0x00, 0x66, // currentLeaderEpoch
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x34, // fetchOffset
+		0xFF, 0xFF, 0xFF, 0xFF, // lastFetchedEpoch
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // logStartOffset
+		0x00, 0x00, 0x00, 0x56, // maxBytes
+		0x00,                               // partition tagged fields
+		0x00,                               // topic tagged fields
+		0x01,                               // forgotten topics
+		0x07, 'r', 'a', 'c', 'k', '0', '1', // rackID
+		0x01,       // tagged fields
+		0x01, 0x0D, // replicaState
+		0x00, 0x00, 0x00, 0x01, // replicaID
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05, // replicaEpoch
+		0x00,
+	}
 )
 
 func TestFetchRequest(t *testing.T) {
@@ -93,4 +171,69 @@ func TestFetchRequest(t *testing.T) {
 		request.RackID = "rack01"
 		testRequest(t, "one block v11 rackid", request, fetchRequestOneBlockV11)
 	})
+
+	t.Run("one block v12 last fetched epoch", func(t *testing.T) {
+		request := new(FetchRequest)
+		request.Version = 12
+		request.MaxBytes = 0xFF
+		request.Isolation = ReadCommitted
+		request.SessionID = 0xAA
+		request.SessionEpoch = 0xEE
+		request.AddBlock("topic", 0x12, 0x34, 0x56, 0x66)
+		request.RackID = "rack01"
+		testRequest(t, "one block v12", request, fetchRequestOneBlockV12)
+	})
+
+	topicID := Uuid{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E, 0x0F, 0x10}
+
+	t.Run("one block v13 topic id and cluster id", func(t *testing.T) {
+		request := new(FetchRequest)
+		request.Version = 13
+		request.MaxBytes = 0xFF
+		request.Isolation = ReadCommitted
+		request.SessionID = 0xAA
+		request.SessionEpoch = 0xEE
+		request.AddBlockWithTopicID(topicID, 0x12, 0x34, 0x56, 0x66)
+		request.RackID = "rack01"
+		clusterID := "c1"
+		request.ClusterID = &clusterID
+		testRequest(t, "one block v13", request, fetchRequestOneBlockV13)
+	})
+
+	t.Run("one block v15 replica state", func(t *testing.T) {
+		request := new(FetchRequest)
+		request.Version = 15
+		request.MaxBytes = 0xFF
+		request.Isolation = ReadCommitted
+		request.SessionID = 0xAA
+		request.SessionEpoch = 0xEE
+		request.AddBlockWithTopicID(topicID, 0x12, 0x34, 0x56, 0x66)
+		request.RackID = "rack01"
+		request.ReplicaState = &FetchReplicaState{ReplicaID: 1, ReplicaEpoch: 5}
+		testRequest(t, "one block v15", request, fetchRequestOneBlockV15)
+	})
+
+	t.Run("versions 12 to 16 round trip", func(t *testing.T) {
+		for version := int16(12); version <= 16; version++ {
+			request := &FetchRequest{Version: version, MaxBytes: 0xFF, SessionID: 0xAA}
+			if version >= 13 {
+				request.AddBlockWithTopicID(topicID, 0x12, 0x34, 0x56, 0x66)
+			} else {
+				request.AddBlock("topic", 0x12, 0x34, 0x56, 0x66)
+			}
+			testRequestWithoutByteComparison(t, fmt.Sprintf("v%d", version), request)
+		}
+	})
+	t.Run("v13 topic id meters", func(t *testing.T) {
+		request := &FetchRequest{Version: 13}
+		request.AddBlockWithTopicID(topicID, 0x12, 0x34, 0x56, 0x66)
+		registry := metrics.NewRegistry()
+		if _, err := encode(request, registry); err != nil {
+			t.Fatal(err)
+		}
+		// the per-topic meter is not named after the topic ID
+		if meter := registry.Get(getMetricNameForTopic("consumer-fetch-rate", topicID.String())); meter != nil {
+			t.Errorf("unexpected meter for topic ID %s", topicID)
+		}
+	})
 }
@@ -29,13 +29,65 @@ func (t *AbortedTransaction) decode(pd packetDecoder) (err error) {
 		return err
 	}
 
-	return nil
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
 }
 
 func (t *AbortedTransaction) encode(pe packetEncoder) (err error) {
 	pe.putInt64(t.ProducerID)
 	pe.putInt64(t.FirstOffset)
+	pe.putEmptyTaggedFieldArray()
+
+	return nil
+}
+
+// FetchEpochEndOffset is returned by the leader (version 12+) when the fetch
+// offset and last fetched epoch of the request diverge from its log.
+type FetchEpochEndOffset struct {
+	Epoch     int32
+	EndOffset int64
+}
+
+func (e *FetchEpochEndOffset) decode(pd packetDecoder) (err error) {
+	if e.Epoch, err = pd.getInt32(); err != nil {
+		return err
+	}
+	if e.EndOffset, err = pd.getInt64(); err != nil {
+		return err
+	}
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (e *FetchEpochEndOffset) encode(pe packetEncoder) error {
+	pe.putInt32(e.Epoch)
+	pe.putInt64(e.EndOffset)
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+// FetchLeaderIDAndEpoch is returned (version 12+) when the partition leader
+// known to the broker differs from the one the request was sent to.
+type FetchLeaderIDAndEpoch struct {
+	LeaderID    int32
+	LeaderEpoch int32
+}
+
+func (l *FetchLeaderIDAndEpoch) decode(pd packetDecoder) (err error) {
+	if l.LeaderID, err = pd.getInt32(); err != nil {
+		return err
+	}
+	if l.LeaderEpoch, err = pd.getInt32(); err != nil {
+		return err
+	}
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
 
+func (l *FetchLeaderIDAndEpoch) encode(pe packetEncoder) error {
+	pe.putInt32(l.LeaderID)
+	pe.putInt32(l.LeaderEpoch)
+	pe.putEmptyTaggedFieldArray()
 	return nil
 }
 
@@ -56,6 +108,12 @@ type FetchResponseBlock struct {
 	// PreferredReadReplica contains the preferred read replica for the
 	// consumer to use on its next fetch request
 	PreferredReadReplica int32
+	// DivergingEpoch contains the largest epoch and its end offset known to
+	// the leader when the log of the fetcher has diverged (version 12+).
+	DivergingEpoch *FetchEpochEndOffset
+	// CurrentLeader contains the current leader of the partition when the
+	// fetch was sent to a broker which isn't the leader (version 12+).
+	CurrentLeader *FetchLeaderIDAndEpoch
 	// RecordsSet contains the record data.
 	RecordsSet []*Records
 
@@ -70,6 +128,13 @@ type FetchResponseBlock struct {
 	// partial batch fully, when one is present. Zero if no partial trailing
 	// batch was detected or the size is unknown (e.g. legacy MessageSet).
 	partialBatchSize int32
+
+	// recordsSize is the on-wire size of the records, for the fetch metrics.
+	recordsSize int32
+
+	// deferDecompression and zstdDict are passed on to the decoded Records
+	deferDecompression bool
+	zstdDict           *zstdDictionary
 }
 
 func (b *FetchResponseBlock) decode(pd packetDecoder, version int16) (err error) {
@@ -129,13 +194,26 @@ func (b *FetchResponseBlock) decode(pd packetDecoder, version int16) (err error)
 		b.PreferredReadReplica = -1
 	}
 
-	recordsSize, err := pd.getInt32()
-	if err != nil {
-		return err
+	var recordsSize int32
+	if version >= 12 {
+		// compact records are prefixed by their length + 1, 0 being null
+		n, err := pd.getUVarint()
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			recordsSize = int32(n - 1)
+		}
+	} else {
+		recordsSize, err = pd.getInt32()
+		if err != nil {
+			return err
+		}
 	}
 	if sizeMetric != nil {
 		sizeMetric.Update(int64(recordsSize))
 	}
+	b.recordsSize = recordsSize
 
 	recordsDecoder, err := pd.getSubset(int(recordsSize))
 	if err != nil {
@@ -145,7 +223,7 @@ func (b *FetchResponseBlock) decode(pd packetDecoder, version int16) (err error)
 	b.RecordsSet = []*Records{}
 
 	for recordsDecoder.remaining() > 0 {
-		records := &Records{}
+		records := &Records{deferDecompression: b.deferDecompression, zstdDict: b.zstdDict}
 		if err := records.decode(recordsDecoder); err != nil {
 			// If we have at least one decoded records, this is not an error
 			if errors.Is(err, ErrInsufficientData) {
@@ -197,6 +275,19 @@ func (b *FetchResponseBlock) decode(pd packetDecoder, version int16) (err error)
 		}
 	}
 
+	if version >= 12 {
+		return pd.getTaggedFieldArray(taggedFieldDecoders{
+			0: func(pd packetDecoder) error {
+				b.DivergingEpoch = &FetchEpochEndOffset{}
+				return b.DivergingEpoch.decode(pd)
+			},
+			1: func(pd packetDecoder) error {
+				b.CurrentLeader = &FetchLeaderIDAndEpoch{}
+				return b.CurrentLeader.decode(pd)
+			},
+		})
+	}
+
 	return nil
 }
 
@@ -253,6 +344,19 @@ func (b *FetchResponseBlock) encode(pe packetEncoder, version int16) (err error)
 		pe.putInt32(b.PreferredReadReplica)
 	}
 
+	if version >= 12 {
+		// compact records are prefixed with a varint length so they are
+		// encoded separately (and without the flexible encoder) first
+		raw, err := encode(&fetchResponseRecords{b.RecordsSet}, nil)
+		if err != nil {
+			return err
+		}
+		if err = pe.putBytes(raw); err != nil {
+			return err
+		}
+		return b.encodeTaggedFields(pe)
+	}
+
 	pe.push(&lengthField{})
 	for _, records := range b.RecordsSet {
 		err = records.encode(pe)
@@ -263,6 +367,48 @@ func (b *FetchResponseBlock) encode(pe packetEncoder, version int16) (err error)
 	return pe.pop()
 }
 
+func (b *FetchResponseBlock) encodeTaggedFields(pe packetEncoder) error {
+	var numTaggedFields uint64
+	if b.DivergingEpoch != nil {
+		numTaggedFields++
+	}
+	if b.CurrentLeader != nil {
+		numTaggedFields++
+	}
+	pe.putUVarint(numTaggedFields)
+
+	if b.DivergingEpoch != nil {
+		pe.putUVarint(0)  // tag
+		pe.putUVarint(13) // epoch (4) + endOffset (8) + empty tagged fields (1)
+		if err := b.DivergingEpoch.encode(pe); err != nil {
+			return err
+		}
+	}
+	if b.CurrentLeader != nil {
+		pe.putUVarint(1) // tag
+		pe.putUVarint(9) // leaderID (4) + leaderEpoch (4) + empty tagged fields (1)
+		if err := b.CurrentLeader.encode(pe); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetchResponseRecords encodes the records of a FetchResponseBlock without
+// any length prefix.
+type fetchResponseRecords struct {
+	recordsSet []*Records
+}
+
+func (r *fetchResponseRecords) encode(pe packetEncoder) error {
+	for _, records := range r.recordsSet {
+		if err := records.encode(pe); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (b *FetchResponseBlock) getAbortedTransactions() []*AbortedTransaction {
 	// I can't find any doc that guarantee the field `fetchResponse.AbortedTransactions` is ordered
 	// plus Java implementation use a PriorityQueue based on `FirstOffset`. I guess we have to order it ourself
@@ -287,9 +433,19 @@ type FetchResponse struct {
 	SessionID int32
 	// Blocks contains the response topics.
 	Blocks map[string]map[int32]*FetchResponseBlock
+	// TopicIDBlocks contains the response topics identified by topic ID
+	// (version 13+).
+	TopicIDBlocks map[Uuid]map[int32]*FetchResponseBlock
 
 	LogAppendTime bool
 	Timestamp     time.Time
+
+	// deferDecompression leaves the records of compressed record batches
+	// undecoded until RecordBatch.decodeRecords is called, so that consumers can
+	// decompress them off the goroutine decoding the response.
+	deferDecompression bool
+	// zstdDict is the dictionary used to decompress zstd compressed records
+	zstdDict *zstdDictionary
 }
 
 func (r *FetchResponse) setVersion(v int16) {
@@ -321,36 +477,65 @@ func (r *FetchResponse) decode(pd packetDecoder, version int16) (err error) {
 		return err
 	}
 
-	r.Blocks = make(map[string]map[int32]*FetchResponseBlock, numTopics)
+	if r.Version >= 13 {
+		r.TopicIDBlocks = make(map[Uuid]map[int32]*FetchResponseBlock, numTopics)
+	} else {
+		r.Blocks = make(map[string]map[int32]*FetchResponseBlock, numTopics)
+	}
 	for i := 0; i < numTopics; i++ {
-		name, err := pd.getString()
-		if err != nil {
-			return err
-		}
-
-		numBlocks, err := pd.getArrayLength()
-		if err != nil {
-			return err
-		}
-
-		r.Blocks[name] = make(map[int32]*FetchResponseBlock, numBlocks)
-
-		for j := 0; j < numBlocks; j++ {
-			id, err := pd.getInt32()
+		if r.Version >= 13 {
+			topicID, err := pd.getRawBytes(16)
 			if err != nil {
 				return err
 			}
-
-			block := new(FetchResponseBlock)
-			err = block.decode(pd, version)
+			blocks, err := r.decodeBlocks(pd)
+			if err != nil {
+				return err
+			}
+			r.TopicIDBlocks[Uuid(topicID)] = blocks
+		} else {
+			name, err := pd.getString()
 			if err != nil {
 				return err
 			}
-			r.Blocks[name][id] = block
+			blocks, err := r.decodeBlocks(pd)
+			if err != nil {
+				return err
+			}
+			r.Blocks[name] = blocks
 		}
 	}
 
-	return nil
+	// the NodeEndpoints tagged field (version 16+) is not used
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *FetchResponse) decodeBlocks(pd packetDecoder) (map[int32]*FetchResponseBlock, error) {
+	numBlocks, err := pd.getArrayLength()
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := make(map[int32]*FetchResponseBlock, numBlocks)
+	for j := 0; j < numBlocks; j++ {
+		id, err := pd.getInt32()
+		if err != nil {
+			return nil, err
+		}
+
+		block := &FetchResponseBlock{deferDecompression: r.deferDecompression, zstdDict: r.zstdDict}
+		err = block.decode(pd, r.Version)
+		if err != nil {
+			return nil, err
+		}
+		blocks[id] = block
+	}
+
+	if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+		return nil, err
+	}
+	return blocks, nil
 }
 
 func (r *FetchResponse) encode(pe packetEncoder) (err error) {
@@ -363,30 +548,61 @@ func (r *FetchResponse) encode(pe packetEncoder) (err error) {
 		pe.putInt32(r.SessionID)
 	}
 
-	err = pe.putArrayLength(len(r.Blocks))
-	if err != nil {
-		return err
-	}
-
-	for topic, partitions := range r.Blocks {
-		err = pe.putString(topic)
+	if r.Version >= 13 {
+		err = pe.putArrayLength(len(r.TopicIDBlocks))
 		if err != nil {
 			return err
 		}
 
-		err = pe.putArrayLength(len(partitions))
+		for topicID, partitions := range r.TopicIDBlocks {
+			err = pe.putRawBytes(topicID[:])
+			if err != nil {
+				return err
+			}
+
+			err = r.encodeBlocks(pe, partitions)
+			if err != nil {
+				return err
+			}
+		}
+	} else {
+		err = pe.putArrayLength(len(r.Blocks))
 		if err != nil {
 			return err
 		}
 
-		for id, block := range partitions {
-			pe.putInt32(id)
-			err = block.encode(pe, r.Version)
+		for topic, partitions := range r.Blocks {
+			err = pe.putString(topic)
+			if err != nil {
+				return err
+			}
+
+			err = r.encodeBlocks(pe, partitions)
 			if err != nil {
 				return err
 			}
 		}
 	}
+
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *FetchResponse) encodeBlocks(pe packetEncoder, partitions map[int32]*FetchResponseBlock) error {
+	err := pe.putArrayLength(len(partitions))
+	if err != nil {
+		return err
+	}
+
+	for id, block := range partitions {
+		pe.putInt32(id)
+		err = block.encode(pe, r.Version)
+		if err != nil {
+			return err
+		}
+	}
+
+	pe.putEmptyTaggedFieldArray()
 	return nil
 }
 
@@ -399,15 +615,34 @@ func (r *FetchResponse) version() int16 {
 }
 
 func (r *FetchResponse) headerVersion() int16 {
+	if r.Version >= 12 {
+		return 1
+	}
 	return 0
 }
 
 func (r *FetchResponse) isValidVersion() bool {
-	return r.Version >= 0 && r.Version <= 11
+	return r.Version >= 0 && r.Version <= 16
+}
+
+func (r *FetchResponse) isFlexible() bool {
+	return r.isFlexibleVersion(r.Version)
+}
+
+func (r *FetchResponse) isFlexibleVersion(version int16) bool {
+	return version >= 12
 }
 
 func (r *FetchResponse) requiredVersion() KafkaVersion {
 	switch r.Version {
+	case 16:
+		return V3_7_0_0
+	case 14, 15:
+		return V3_5_0_0
+	case 13:
+		return V3_1_0_0
+	case 12:
+		return V2_7_0_0
 	case 11:
 		return V2_3_0_0
 	case 9, 10:
@@ -429,7 +664,7 @@ func (r *FetchResponse) requiredVersion() KafkaVersion {
 	case 0:
 		return V0_8_2_0
 	default:
-		return V2_3_0_0
+		return V3_7_0_0
 	}
 }
 
@@ -449,6 +684,20 @@ func (r *FetchResponse) GetBlock(topic string, partition int32) *FetchResponseBl
 	return r.Blocks[topic][partition]
 }
 
+// GetBlockByTopicID returns the block for the given partition of the topic
+// identified by topicID (version 13+).
+func (r *FetchResponse) GetBlockByTopicID(topicID Uuid, partition int32) *FetchResponseBlock {
+	if r.TopicIDBlocks == nil {
+		return nil
+	}
+
+	if r.TopicIDBlocks[topicID] == nil {
+		return nil
+	}
+
+	return r.TopicIDBlocks[topicID][partition]
+}
+
 func (r *FetchResponse) AddError(topic string, partition int32, err KError) {
 	if r.Blocks == nil {
 		r.Blocks = make(map[string]map[int32]*FetchResponseBlock)
@@ -202,6 +202,56 @@ var (
 		0xFF, 0xFF, 0xFF, 0xFF,
 		0x00, 0x00, 0x00, 0x02, 0x00, 0xEE,
 	}
+
+	oneRecordFetchResponseV13 = []byte{
+		0x00, 0x00, 0x00, 0x00, // ThrottleTime
+		0x00, 0x00, // ErrorCode
+		0x00, 0x00, 0x00, 0x00, // SessionID
+		0x02, // Number of Topics
+		0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08,
+		0x09, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E, 0x0F, 0x10, // Topic ID
+		0x02,                   // Number of Partitions
+		0x00, 0x00, 0x00, 0x05, // Partition
+		0x00, 0x01, // Error
+		0x00, 0x00, 0x00, 0x00, 0x10, 0x10, 0x10, 0x10, // High Watermark Offset
+		0x00, 0x00, 0x00, 0x00, 0x10, 0x10, 0x10, 0x10, // Last Stable Offset
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // Log Start Offset
+		0x01,                   // Number of Aborted Transactions
+		0xFF, 0xFF, 0xFF, 0xFF, // Preferred Read Replica
+		0x53, // Records length
+		// recordBatch
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x46,
+		0x00, 0x00, 0x00, 0x00,
+		0x02,
+		0xDB, 0x47, 0x14, 0xC9,
+		0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0A,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x01,
+		// record
+		0x28,
+		0x00,
+		0x0A,
+		0x00,
+		0x08, 0x01, 0x02, 0x03, 0x04,
+		0x06, 0x05, 0x06, 0x07,
+		0x02,
+		0x06, 0x08, 0x09, 0x0A,
+		0x04, 0x0B, 0x0C,
+		// partition tagged fields
+		0x01,
+		0x00, 0x0D, // DivergingEpoch
+		0x00, 0x00, 0x00, 0x02,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0A,
+		0x00,
+		0x00, // topic tagged fields
+		0x00, // tagged fields
+	}
 )
 
 func TestEmptyFetchResponse(t *testing.T) {
@@ -598,3 +648,77 @@ func TestPreferredReplicaFetchResponseV11(t *testing.T) {
 		t.Error("Decoding produced incorrect message value.")
 	}
 }
+
+func TestOneRecordFetchResponseV13(t *testing.T) {
+	response := FetchResponse{}
+	testVersionDecodable(t, "one record v13", &response, oneRecordFetchResponseV13, 13)
+
+	topicID := Uuid{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E, 0x0F, 0x10}
+	if len(response.TopicIDBlocks) != 1 {
+		t.Fatal("Decoding produced incorrect number of topic blocks.")
+	}
+
+	block := response.GetBlockByTopicID(topicID, 5)
+	if block == nil {
+		t.Fatal("GetBlockByTopicID didn't return block.")
+	}
+	if !errors.Is(block.Err, ErrOffsetOutOfRange) {
+		t.Error("Decoding didn't produce correct error code.")
+	}
+	if block.HighWaterMarkOffset != 0x10101010 {
+		t.Error("Decoding didn't produce correct high water mark offset.")
+	}
+	if block.DivergingEpoch == nil || block.DivergingEpoch.Epoch != 2 || block.DivergingEpoch.EndOffset != 0x0A {
+		t.Errorf("Decoding didn't produce correct diverging epoch: %+v", block.DivergingEpoch)
+	}
+	if block.CurrentLeader != nil {
+		t.Error("Decoding produced a current leader where there was none.")
+	}
+
+	n, err := block.numRecords()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if n != 1 {
+		t.Fatal("Decoding produced incorrect number of records.")
+	}
+	rec := block.RecordsSet[0].RecordBatch.Records[0]
+	if !bytes.Equal(rec.Key, []byte{0x01, 0x02, 0x03, 0x04}) {
+		t.Error("Decoding produced incorrect record key.")
+	}
+	if !bytes.Equal(rec.Value, []byte{0x05, 0x06, 0x07}) {
+		t.Error("Decoding produced incorrect record value.")
+	}
+
+	packet, err := encode(&response, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(packet, oneRecordFetchResponseV13) {
+		t.Errorf("Encoding failed\ngot  %v\nwant %v", packet, oneRecordFetchResponseV13)
+	}
+}
+
+func TestFetchResponseV12TaggedFields(t *testing.T) {
+	response := &FetchResponse{
+		Version:   12,
+		ErrorCode: 0,
+		SessionID: 0xAC,
+		Blocks: map[string]map[int32]*FetchResponseBlock{
+			"topic": {
+				5: {
+					Err:                  ErrFencedLeaderEpoch,
+					HighWaterMarkOffset:  0x10,
+					LastStableOffset:     0x10,
+					LogStartOffset:       0x01,
+					AbortedTransactions:  []*AbortedTransaction{{ProducerID: 7, FirstOffset: 3}},
+					PreferredReadReplica: -1,
+					RecordsSet:           []*Records{},
+					DivergingEpoch:       &FetchEpochEndOffset{Epoch: 4, EndOffset: 0x0F},
+					CurrentLeader:        &FetchLeaderIDAndEpoch{LeaderID: 2, LeaderEpoch: 5},
+				},
+			},
+		},
+	}
+	testResponse(t, "v12 tagged fields", response, nil)
+}
@@ -41,6 +41,14 @@ type GSSAPIConfig struct {
 	Realm              string
 	DisablePAFXFAST    bool
 	BuildSpn           BuildSpnFunc
+
+	// KeyTabReloadInterval, when > 0, makes each Client cache the keytab read
+	// from KeyTabPath for its brokers and check the modification time of the
+	// file at most once per interval, reading it again when it changed, e.g.
+	// after a rotation, so that new connections authenticate with the new
+	// keytab. By default, and for brokers used without a Client, the keytab
+	// is read again on every authentication.
+	KeyTabReloadInterval time.Duration
 }
 
 type GSSAPIKerberosAuth struct {
@@ -88,6 +96,9 @@ func (krbAuth *GSSAPIKerberosAuth) readPackage(broker *Broker) ([]byte, int, err
 	}
 	bytesRead += bytes
 	payloadLength := binary.BigEndian.Uint32(lengthInBytes)
+	if int64(payloadLength) > broker.maxResponseSize() {
+		return nil, bytesRead, fmt.Errorf("%w: GSSAPI response of %d bytes from broker %s", ErrResponseTooLarge, payloadLength, broker.addr)
+	}
 	payloadBytes := make([]byte, payloadLength)         // buffer for read..
 	bytes, err = io.ReadFull(broker.conn, payloadBytes) // read bytes
 	if err != nil {
@@ -1,5 +1,7 @@
 package sarama
 
+import "time"
+
 // ProducerInterceptor allows you to intercept (and possibly mutate) the records
 // received by the producer before they are published to the Kafka cluster.
 // https://cwiki.apache.org/confluence/display/KAFKA/KIP-42%3A+Add+Producer+and+Consumer+Interceptors#KIP42:AddProducerandConsumerInterceptors-Motivation
@@ -11,6 +13,39 @@ type ProducerInterceptor interface {
 	OnSend(*ProducerMessage)
 }
 
+// ProducerInterceptorV2 allows you to transform the records received by the
+// producer before they are published to the Kafka cluster: unlike a
+// ProducerInterceptor, it can drop a message, e.g. to sample or filter the
+// traffic, or split it into several messages.
+type ProducerInterceptorV2 interface {
+
+	// OnSend is called with a message sent by the application, or returned by
+	// the previous interceptor of the chain, and returns the messages to
+	// publish in its place: none drops the message and several fan it out.
+	// The returned messages may include the given one, and a message
+	// returned more than once is only published once. An error fails the
+	// message sent by the application, which is then returned on the Errors
+	// channel.
+	OnSend(*ProducerMessage) ([]*ProducerMessage, error)
+}
+
+// AdaptProducerInterceptor wraps a ProducerInterceptor into a
+// ProducerInterceptorV2 which always publishes the message it was given, so
+// that existing interceptors can be chained with new ones in
+// Producer.InterceptorsV2.
+func AdaptProducerInterceptor(interceptor ProducerInterceptor) ProducerInterceptorV2 {
+	return producerInterceptorAdapter{interceptor: interceptor}
+}
+
+type producerInterceptorAdapter struct {
+	interceptor ProducerInterceptor
+}
+
+func (a producerInterceptorAdapter) OnSend(msg *ProducerMessage) ([]*ProducerMessage, error) {
+	a.interceptor.OnSend(msg)
+	return []*ProducerMessage{msg}, nil
+}
+
 // ConsumerInterceptor allows you to intercept (and possibly mutate) the records
 // received by the consumer before they are sent to the messages channel.
 // https://cwiki.apache.org/confluence/display/KAFKA/KIP-42%3A+Add+Producer+and+Consumer+Interceptors#KIP42:AddProducerandConsumerInterceptors-Motivation
@@ -22,6 +57,36 @@ type ConsumerInterceptor interface {
 	OnConsume(*ConsumerMessage)
 }
 
+// RequestInterceptor allows you to observe the requests sent to the brokers and
+// the latency of their responses, for instance to trace them. It is called
+// from the goroutines handling the broker connections, so it must be safe for
+// concurrent use and should not block.
+type RequestInterceptor interface {
+
+	// OnSend is called right before the request is written to the broker. The
+	// request has already been encoded at this point, so modifying req.Body
+	// does not alter what is sent.
+	OnSend(broker *Broker, req RequestInfo)
+
+	// OnResponse is called once the response to the request has been read,
+	// with the time elapsed since it was sent, or with the error that
+	// prevented the request from being written or its response from being
+	// read. It is called as soon as the request is written for requests that
+	// have no response, such as produce requests with NoResponse acks.
+	OnResponse(broker *Broker, req RequestInfo, latency time.Duration, err error)
+}
+
+// RequestInfo describes a request observed by a RequestInterceptor.
+type RequestInfo struct {
+	// APIKey and APIVersion identify the protocol API of the request.
+	APIKey     int16
+	APIVersion int16
+	// CorrelationID matches the request with its response.
+	CorrelationID int32
+	// Body is the request itself, such as a *ProduceRequest or a *FetchRequest.
+	Body interface{}
+}
+
 func (msg *ProducerMessage) safelyApplyInterceptor(interceptor ProducerInterceptor) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -32,6 +97,17 @@ func (msg *ProducerMessage) safelyApplyInterceptor(interceptor ProducerIntercept
 	interceptor.OnSend(msg)
 }
 
+func (msg *ProducerMessage) safelyApplyInterceptorV2(interceptor ProducerInterceptorV2) (msgs []*ProducerMessage, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			Logger.Printf("Error when calling producer interceptor: %v, %v", interceptor, r)
+			msgs, err = []*ProducerMessage{msg}, nil
+		}
+	}()
+
+	return interceptor.OnSend(msg)
+}
+
 func (msg *ConsumerMessage) safelyApplyInterceptor(interceptor ConsumerInterceptor) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -41,3 +117,23 @@ func (msg *ConsumerMessage) safelyApplyInterceptor(interceptor ConsumerIntercept
 
 	interceptor.OnConsume(msg)
 }
+
+func safelyInterceptSend(interceptor RequestInterceptor, broker *Broker, req RequestInfo) {
+	defer func() {
+		if r := recover(); r != nil {
+			Logger.Printf("Error when calling request interceptor: %v, %v", interceptor, r)
+		}
+	}()
+
+	interceptor.OnSend(broker, req)
+}
+
+func safelyInterceptResponse(interceptor RequestInterceptor, broker *Broker, req RequestInfo, latency time.Duration, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			Logger.Printf("Error when calling request interceptor: %v, %v", interceptor, r)
+		}
+	}()
+
+	interceptor.OnResponse(broker, req, latency, err)
+}
@@ -1,6 +1,10 @@
 package sarama
 
 import (
+	"os"
+	"sync"
+	"time"
+
 	krb5client "github.com/jcmturner/gokrb5/v8/client"
 	krb5config "github.com/jcmturner/gokrb5/v8/config"
 	"github.com/jcmturner/gokrb5/v8/credentials"
@@ -24,18 +28,24 @@ func (c *KerberosGoKrb5Client) CName() types.PrincipalName {
 // It uses pure go Kerberos 5 solution (RFC-4121 and RFC-4120).
 // uses gokrb5 library underlying which is a pure go kerberos client with some GSS-API capabilities.
 func NewKerberosClient(config *GSSAPIConfig) (KerberosClient, error) {
+	return newKerberosClient(config, nil)
+}
+
+// newKerberosClient is NewKerberosClient reading the keytab through keytabs,
+// which may be nil.
+func newKerberosClient(config *GSSAPIConfig, keytabs *keytabCache) (KerberosClient, error) {
 	cfg, err := krb5config.Load(config.KerberosConfigPath)
 	if err != nil {
 		return nil, err
 	}
-	return createClient(config, cfg)
+	return createClient(config, cfg, keytabs)
 }
 
-func createClient(config *GSSAPIConfig, cfg *krb5config.Config) (KerberosClient, error) {
+func createClient(config *GSSAPIConfig, cfg *krb5config.Config, keytabs *keytabCache) (KerberosClient, error) {
 	var client *krb5client.Client
 	switch config.AuthType {
 	case KRB5_KEYTAB_AUTH:
-		kt, err := keytab.Load(config.KeyTabPath)
+		kt, err := loadKeytab(config, keytabs)
 		if err != nil {
 			return nil, err
 		}
@@ -55,3 +65,59 @@ func createClient(config *GSSAPIConfig, cfg *krb5config.Config) (KerberosClient,
 	}
 	return &KerberosGoKrb5Client{*client}, nil
 }
+
+// keytabCache caches the keytabs loaded with a
+// GSSAPIConfig.KeyTabReloadInterval, by path. Each client has its own, shared
+// by its brokers. The zero value is ready to use.
+type keytabCache struct {
+	lock    sync.Mutex
+	entries map[string]*cachedKeytab
+}
+
+type cachedKeytab struct {
+	keytab    *keytab.Keytab
+	modTime   time.Time // modification time of the file the keytab was read from
+	checkedAt time.Time // last time modTime was checked
+}
+
+// loadKeytab reads the keytab of config, or returns the one cached in keytabs
+// when KeyTabReloadInterval is set and the file did not change.
+func loadKeytab(config *GSSAPIConfig, keytabs *keytabCache) (*keytab.Keytab, error) {
+	if keytabs == nil || config.KeyTabReloadInterval <= 0 {
+		return keytab.Load(config.KeyTabPath)
+	}
+	return keytabs.load(config.KeyTabPath, config.KeyTabReloadInterval)
+}
+
+func (c *keytabCache) load(path string, interval time.Duration) (*keytab.Keytab, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := time.Now()
+	entry := c.entries[path]
+	if entry != nil && now.Sub(entry.checkedAt) < interval {
+		return entry.keytab, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if entry != nil && info.ModTime().Equal(entry.modTime) {
+		entry.checkedAt = now
+		return entry.keytab, nil
+	}
+
+	kt, err := keytab.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if entry != nil {
+		Logger.Printf("Reloaded the Kerberos keytab %s after it changed\n", path)
+	}
+	if c.entries == nil {
+		c.entries = make(map[string]*cachedKeytab)
+	}
+	c.entries[path] = &cachedKeytab{keytab: kt, modTime: info.ModTime(), checkedAt: now}
+	return kt, nil
+}
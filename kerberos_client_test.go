@@ -4,9 +4,14 @@ package sarama
 
 import (
 	"errors"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	krbcfg "github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/iana/etypeID"
+	"github.com/jcmturner/gokrb5/v8/keytab"
 )
 
 /*
@@ -94,7 +99,7 @@ func TestCreateWithPassword(t *testing.T) {
 	clientConfig.Net.SASL.GSSAPI.AuthType = KRB5_USER_AUTH
 	clientConfig.Net.SASL.GSSAPI.Password = "qwerty"
 	clientConfig.Net.SASL.GSSAPI.KerberosConfigPath = "/etc/krb5.conf"
-	client, _ := createClient(&clientConfig.Net.SASL.GSSAPI, kerberosConfig)
+	client, _ := createClient(&clientConfig.Net.SASL.GSSAPI, kerberosConfig, nil)
 	// Expect to create client with password
 	if client == nil {
 		t.Errorf("Expected client not nil")
@@ -123,12 +128,70 @@ func TestCreateWithKeyTab(t *testing.T) {
 	clientConfig.Net.SASL.GSSAPI.AuthType = KRB5_KEYTAB_AUTH
 	clientConfig.Net.SASL.GSSAPI.KeyTabPath = "nonexist.keytab"
 	clientConfig.Net.SASL.GSSAPI.KerberosConfigPath = "/etc/krb5.conf"
-	_, err = createClient(&clientConfig.Net.SASL.GSSAPI, kerberosConfig)
+	_, err = createClient(&clientConfig.Net.SASL.GSSAPI, kerberosConfig, nil)
 	if err.Error() != expectedErr.Error() {
 		t.Errorf("Expected error:%s, got:%s.", err, expectedErr)
 	}
 }
 
+func TestKeytabReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "client.keytab")
+	writeKeytab := func(password string, modTime time.Time) {
+		kt := keytab.New()
+		if err := kt.AddEntry("client", "EXAMPLE.COM", password, modTime, 1, etypeID.AES256_CTS_HMAC_SHA1_96); err != nil {
+			t.Fatal(err)
+		}
+		b, err := kt.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, b, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	writeKeytab("first", modTime)
+
+	cache := &keytabCache{}
+	first, err := cache.load(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the file is not checked again before the interval elapsed
+	writeKeytab("second", modTime.Add(time.Minute))
+	cached, err := cache.load(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached != first {
+		t.Error("Expected the cached keytab to be returned before the reload interval elapsed")
+	}
+
+	// it is read again once the interval elapsed and the file changed
+	cache.entries[path].checkedAt = time.Time{}
+	reloaded, err := cache.load(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded == first {
+		t.Error("Expected the keytab to be reloaded after it changed")
+	}
+
+	// but not when the file did not change
+	cache.entries[path].checkedAt = time.Time{}
+	cached, err = cache.load(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached != reloaded {
+		t.Error("Expected the cached keytab to be returned when the file did not change")
+	}
+}
+
 func TestCreateWithCredentialsCache(t *testing.T) {
 	kerberosConfig, err := krbcfg.NewFromString(krb5cfg)
 	if err != nil {
@@ -143,7 +206,7 @@ func TestCreateWithCredentialsCache(t *testing.T) {
 	clientConfig.Net.SASL.GSSAPI.AuthType = KRB5_CCACHE_AUTH
 	clientConfig.Net.SASL.GSSAPI.CCachePath = "nonexist.ccache"
 	clientConfig.Net.SASL.GSSAPI.KerberosConfigPath = "/etc/krb5.conf"
-	_, err = createClient(&clientConfig.Net.SASL.GSSAPI, kerberosConfig)
+	_, err = createClient(&clientConfig.Net.SASL.GSSAPI, kerberosConfig, nil)
 	if err.Error() != expectedErr.Error() {
 		t.Errorf("Expected error:%s, got:%s.", err, expectedErr)
 	}
@@ -167,7 +230,7 @@ func TestCreateWithDisablePAFXFAST(t *testing.T) {
 	clientConfig.Net.SASL.GSSAPI.KerberosConfigPath = "/etc/krb5.conf"
 	clientConfig.Net.SASL.GSSAPI.DisablePAFXFAST = true
 
-	_, err = createClient(&clientConfig.Net.SASL.GSSAPI, kerberosConfig)
+	_, err = createClient(&clientConfig.Net.SASL.GSSAPI, kerberosConfig, nil)
 	if err.Error() != expectedErr.Error() {
 		t.Errorf("Expected error:%s, got:%s.", err, expectedErr)
 	}
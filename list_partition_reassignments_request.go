@@ -2,8 +2,10 @@ package sarama
 
 type ListPartitionReassignmentsRequest struct {
 	TimeoutMs int32
-	blocks    map[string][]int32
-	Version   int16
+	// blocks are the partitions to list the reassignments of, nil to list all
+	// ongoing reassignments.
+	blocks  map[string][]int32
+	Version int16
 }
 
 func (r *ListPartitionReassignmentsRequest) setVersion(v int16) {
@@ -13,7 +15,12 @@ func (r *ListPartitionReassignmentsRequest) setVersion(v int16) {
 func (r *ListPartitionReassignmentsRequest) encode(pe packetEncoder) error {
 	pe.putInt32(r.TimeoutMs)
 
-	if err := pe.putArrayLength(len(r.blocks)); err != nil {
+	if r.blocks == nil {
+		// a null array lists all ongoing reassignments
+		if err := pe.putArrayLength(-1); err != nil {
+			return err
+		}
+	} else if err := pe.putArrayLength(len(r.blocks)); err != nil {
 		return err
 	}
 
@@ -13,6 +13,12 @@ var listPartitionReassignmentsRequestOneBlock = []byte{
 	0, 0, // empty tagged fields
 }
 
+var listPartitionReassignmentsRequestAllTopics = []byte{
+	0, 0, 39, 16, // timeout 10000
+	0, // null topics: list all reassignments
+	0, // empty tagged fields
+}
+
 func TestListPartitionReassignmentRequest(t *testing.T) {
 	var request *ListPartitionReassignmentsRequest = &ListPartitionReassignmentsRequest{
 		TimeoutMs: int32(10000),
@@ -26,4 +32,9 @@ func TestListPartitionReassignmentRequest(t *testing.T) {
 	request.AddBlock("topic2", []int32{1, 2})
 
 	testRequestWithoutByteComparison(t, "two blocks", request)
+
+	testRequest(t, "all topics", &ListPartitionReassignmentsRequest{
+		TimeoutMs: int32(10000),
+		Version:   int16(0),
+	}, listPartitionReassignmentsRequestAllTopics)
 }
@@ -0,0 +1,72 @@
+package sarama
+
+// ListTransactionsRequest (API key 66) lists the transactions known to the
+// transaction coordinator it is sent to (KIP-664), so it must be sent to every
+// broker to list all the transactions of the cluster.
+type ListTransactionsRequest struct {
+	// Version 0 is currently only supported
+	Version int16
+	// StateFilters contains the transaction states to list, all the states
+	// are listed if empty.
+	StateFilters []string
+	// ProducerIDFilters contains the producer IDs to list, all the producers
+	// are listed if empty.
+	ProducerIDFilters []int64
+}
+
+func (r *ListTransactionsRequest) setVersion(v int16) {
+	r.Version = v
+}
+
+func (r *ListTransactionsRequest) encode(pe packetEncoder) error {
+	if err := pe.putStringArray(r.StateFilters); err != nil {
+		return err
+	}
+	if err := pe.putInt64Array(r.ProducerIDFilters); err != nil {
+		return err
+	}
+
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *ListTransactionsRequest) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
+	if r.StateFilters, err = pd.getStringArray(); err != nil {
+		return err
+	}
+	if r.ProducerIDFilters, err = pd.getInt64Array(); err != nil {
+		return err
+	}
+
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *ListTransactionsRequest) key() int16 {
+	return apiKeyListTransactions
+}
+
+func (r *ListTransactionsRequest) version() int16 {
+	return r.Version
+}
+
+func (r *ListTransactionsRequest) headerVersion() int16 {
+	return 2
+}
+
+func (r *ListTransactionsRequest) isValidVersion() bool {
+	return r.Version == 0
+}
+
+func (r *ListTransactionsRequest) isFlexible() bool {
+	return r.isFlexibleVersion(r.Version)
+}
+
+func (r *ListTransactionsRequest) isFlexibleVersion(version int16) bool {
+	return version >= 0
+}
+
+func (r *ListTransactionsRequest) requiredVersion() KafkaVersion {
+	return V3_0_0_0
+}
@@ -0,0 +1,29 @@
+//go:build !functional
+
+package sarama
+
+import "testing"
+
+var (
+	emptyListTransactionsRequest = []byte{
+		1, // empty StateFilters array
+		1, // empty ProducerIDFilters array
+		0, // empty tagged fields
+	}
+	filtersListTransactionsRequest = []byte{
+		2,                                    // StateFilters array length 1
+		8, 'O', 'n', 'g', 'o', 'i', 'n', 'g', // StateFilter
+		2,                      // ProducerIDFilters array length 1
+		0, 0, 0, 0, 0, 0, 0, 7, // ProducerIDFilter
+		0, // empty tagged fields
+	}
+)
+
+func TestListTransactionsRequest(t *testing.T) {
+	request := &ListTransactionsRequest{Version: 0}
+	testRequest(t, "no filters", request, emptyListTransactionsRequest)
+
+	request.StateFilters = []string{TransactionStateOngoing}
+	request.ProducerIDFilters = []int64{7}
+	testRequest(t, "filters", request, filtersListTransactionsRequest)
+}
@@ -0,0 +1,128 @@
+package sarama
+
+import "time"
+
+// ListTransactionsResponse is the response to a ListTransactionsRequest.
+type ListTransactionsResponse struct {
+	// Version 0 is currently only supported
+	Version int16
+
+	ThrottleTime time.Duration
+	ErrorCode    KError
+	// UnknownStateFilters contains the state filters of the request the
+	// broker does not know.
+	UnknownStateFilters []string
+	TransactionStates   []*TransactionListing
+}
+
+// TransactionListing describes a transactional producer known to a
+// transaction coordinator.
+type TransactionListing struct {
+	TransactionalID string
+	ProducerID      int64
+	// State contains the state of the transaction, one of the
+	// TransactionState* constants.
+	State string
+}
+
+func (r *ListTransactionsResponse) setVersion(v int16) {
+	r.Version = v
+}
+
+func (r *ListTransactionsResponse) encode(pe packetEncoder) error {
+	pe.putDurationMs(r.ThrottleTime)
+	pe.putKError(r.ErrorCode)
+	if err := pe.putStringArray(r.UnknownStateFilters); err != nil {
+		return err
+	}
+
+	if err := pe.putArrayLength(len(r.TransactionStates)); err != nil {
+		return err
+	}
+	for _, listing := range r.TransactionStates {
+		if err := pe.putString(listing.TransactionalID); err != nil {
+			return err
+		}
+		pe.putInt64(listing.ProducerID)
+		if err := pe.putString(listing.State); err != nil {
+			return err
+		}
+		pe.putEmptyTaggedFieldArray()
+	}
+
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *ListTransactionsResponse) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
+	if r.ThrottleTime, err = pd.getDurationMs(); err != nil {
+		return err
+	}
+	if r.ErrorCode, err = pd.getKError(); err != nil {
+		return err
+	}
+	if r.UnknownStateFilters, err = pd.getStringArray(); err != nil {
+		return err
+	}
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	if n == -1 {
+		n = 0
+	}
+	r.TransactionStates = make([]*TransactionListing, n)
+	for i := 0; i < n; i++ {
+		listing := &TransactionListing{}
+		if listing.TransactionalID, err = pd.getString(); err != nil {
+			return err
+		}
+		if listing.ProducerID, err = pd.getInt64(); err != nil {
+			return err
+		}
+		if listing.State, err = pd.getString(); err != nil {
+			return err
+		}
+		if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+		r.TransactionStates[i] = listing
+	}
+
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *ListTransactionsResponse) key() int16 {
+	return apiKeyListTransactions
+}
+
+func (r *ListTransactionsResponse) version() int16 {
+	return r.Version
+}
+
+func (r *ListTransactionsResponse) headerVersion() int16 {
+	return 1
+}
+
+func (r *ListTransactionsResponse) isValidVersion() bool {
+	return r.Version == 0
+}
+
+func (r *ListTransactionsResponse) isFlexible() bool {
+	return r.isFlexibleVersion(r.Version)
+}
+
+func (r *ListTransactionsResponse) isFlexibleVersion(version int16) bool {
+	return version >= 0
+}
+
+func (r *ListTransactionsResponse) requiredVersion() KafkaVersion {
+	return V3_0_0_0
+}
+
+func (r *ListTransactionsResponse) throttleTime() time.Duration {
+	return r.ThrottleTime
+}
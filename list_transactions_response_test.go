@@ -0,0 +1,50 @@
+//go:build !functional
+
+package sarama
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	emptyListTransactionsResponse = []byte{
+		0, 0, 0, 100, // throttle time (100 ms)
+		0, 0, // ErrorCode
+		1, // empty UnknownStateFilters array
+		1, // empty TransactionStates array
+		0, // empty tagged fields
+	}
+
+	listingsListTransactionsResponse = []byte{
+		0, 0, 0, 100, // throttle time (100 ms)
+		0, 0, // ErrorCode
+		2,                // UnknownStateFilters array length 1
+		4, 'F', 'o', 'o', // UnknownStateFilter
+		2,                // TransactionStates array length 1
+		4, 't', 'x', '1', // TransactionalID
+		0, 0, 0, 0, 0, 0, 0, 7, // ProducerID
+		8, 'O', 'n', 'g', 'o', 'i', 'n', 'g', // State
+		0, // empty listing tagged fields
+		0, // empty tagged fields
+	}
+)
+
+func TestListTransactionsResponse(t *testing.T) {
+	response := &ListTransactionsResponse{
+		Version:           0,
+		ThrottleTime:      100 * time.Millisecond,
+		TransactionStates: []*TransactionListing{},
+	}
+	testResponse(t, "empty", response, emptyListTransactionsResponse)
+
+	response.UnknownStateFilters = []string{"Foo"}
+	response.TransactionStates = []*TransactionListing{
+		{
+			TransactionalID: "tx1",
+			ProducerID:      7,
+			State:           TransactionStateOngoing,
+		},
+	}
+	testResponse(t, "listings", response, listingsListTransactionsResponse)
+}
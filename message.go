@@ -64,6 +64,29 @@ func (cc CompressionCodec) MarshalText() ([]byte, error) {
 	return []byte(cc.String()), nil
 }
 
+// TimestampType is the type of the timestamps of a record batch, encoded in
+// its attributes.
+type TimestampType int8
+
+const (
+	// CreateTime timestamps are set by the producer when creating the records.
+	CreateTime TimestampType = iota
+	// LogAppendTime timestamps are set by the broker when appending the
+	// records to the log.
+	LogAppendTime
+)
+
+func (t TimestampType) String() string {
+	switch t {
+	case CreateTime:
+		return "CreateTime"
+	case LogAppendTime:
+		return "LogAppendTime"
+	default:
+		return fmt.Sprintf("TimestampType(%d)", int8(t))
+	}
+}
+
 // Message is a kafka message type
 type Message struct {
 	Codec            CompressionCodec // codec used to compress the message contents
@@ -107,7 +130,7 @@ func (m *Message) encode(pe packetEncoder) error {
 		payload = m.compressedCache
 		m.compressedCache = nil
 	} else if m.Value != nil {
-		payload, err = compress(m.Codec, m.CompressionLevel, m.Value)
+		payload, err = compress(m.Codec, m.CompressionLevel, nil, m.Value)
 		if err != nil {
 			return err
 		}
@@ -169,7 +192,7 @@ func (m *Message) decode(pd packetDecoder) (err error) {
 	m.compressedSize = len(m.Value)
 
 	if m.Value != nil && m.Codec != CompressionNone {
-		m.Value, err = decompress(m.Codec, m.Value)
+		m.Value, err = decompress(m.Codec, nil, m.Value)
 		if err != nil {
 			return err
 		}
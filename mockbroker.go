@@ -23,6 +23,10 @@ const (
 
 type GSSApiHandlerFunc func([]byte) []byte
 
+// SASLv0HandlerFunc is invoked with the raw authentication bytes a client
+// sends after a SaslHandshake v0, and returns the raw bytes to reply with.
+type SASLv0HandlerFunc func(authBytes []byte) []byte
+
 type requestHandlerFunc func(req *request) (res encoderWithHeader)
 
 // RequestNotifierFunc is invoked when a mock broker processes a request successfully
@@ -67,6 +71,7 @@ type MockBroker struct {
 	history       []RequestResponse
 	lock          sync.Mutex
 	gssApiHandler GSSApiHandlerFunc
+	saslV0Handler SASLv0HandlerFunc
 }
 
 // RequestResponse represents a Request/Response pair processed by MockBroker.
@@ -76,9 +81,14 @@ type RequestResponse struct {
 }
 
 // SetLatency makes broker pause for the specified period every time before
-// replying.
+// replying. The pause happens once the response is built and before any of
+// its bytes are written, so that it can be used to exercise client-side
+// timeouts such as Config.Net.ReadTimeout. It is cut short when the broker is
+// closed.
 func (b *MockBroker) SetLatency(latency time.Duration) {
+	b.lock.Lock()
 	b.latency = latency
+	b.lock.Unlock()
 }
 
 // SetHandlerByMap defines mapping of Request types to MockResponses. When a
@@ -197,6 +207,16 @@ func (b *MockBroker) SetGSSAPIHandler(handler GSSApiHandlerFunc) {
 	b.gssApiHandler = handler
 }
 
+// SetSASLv0Handler sets the handler of the raw authentication bytes that
+// follow a SaslHandshake v0 on a connection, as sent by the SASL v0 PLAIN
+// flow. Without a handler the broker accepts them, replying with an empty
+// frame like Kafka does.
+func (b *MockBroker) SetSASLv0Handler(handler SASLv0HandlerFunc) {
+	b.lock.Lock()
+	b.saslV0Handler = handler
+	b.lock.Unlock()
+}
+
 func (b *MockBroker) readToBytes(r io.Reader) ([]byte, error) {
 	var (
 		bytesRead   int
@@ -252,6 +272,7 @@ func (b *MockBroker) handleRequests(conn io.ReadWriteCloser, idx int, wg *sync.W
 
 	var bytesWritten int
 	var bytesRead int
+	var saslV0 bool // the next packet holds raw authentication bytes
 	for {
 		buffer, err := b.readToBytes(conn)
 		if err != nil {
@@ -263,7 +284,26 @@ func (b *MockBroker) handleRequests(conn io.ReadWriteCloser, idx int, wg *sync.W
 		}
 
 		bytesWritten = 0
-		if !b.isGSSAPI(buffer) {
+		if saslV0 && !b.isGSSAPI(buffer) {
+			// SASL v0 authentication bytes are not part of kafka protocol,
+			// they are not recorded in the history either
+			saslV0 = false
+			res := []byte{0, 0, 0, 0}
+			b.lock.Lock()
+			if b.saslV0Handler != nil {
+				res = b.saslV0Handler(buffer[4:])
+			}
+			b.lock.Unlock()
+			if res == nil {
+				Logger.Printf("*** mockbroker/%d/%d: ignored SASL v0 authentication", b.brokerID, idx)
+				continue
+			}
+			if _, err = conn.Write(res); err != nil {
+				b.serverError(err)
+				break
+			}
+			bytesWritten = len(res)
+		} else if !b.isGSSAPI(buffer) {
 			req, br, err := decodeRequest(bytes.NewReader(buffer))
 			bytesRead = br
 			if err != nil {
@@ -274,15 +314,16 @@ func (b *MockBroker) handleRequests(conn io.ReadWriteCloser, idx int, wg *sync.W
 				break
 			}
 
-			if b.latency > 0 {
-				time.Sleep(b.latency)
-			}
-
 			b.lock.Lock()
 			res := b.handler(req)
 			b.history = append(b.history, RequestResponse{req.body, res})
+			latency := b.latency
 			b.lock.Unlock()
 
+			if handshake, ok := req.body.(*SaslHandshakeRequest); ok && handshake.Version == SASLHandshakeV0 {
+				saslV0 = true
+			}
+
 			if res == nil {
 				Logger.Printf("*** mockbroker/%d/%d: ignored %v", b.brokerID, idx, spew.Sdump(req))
 				continue
@@ -308,6 +349,10 @@ func (b *MockBroker) handleRequests(conn io.ReadWriteCloser, idx int, wg *sync.W
 				continue
 			}
 
+			if !b.delay(latency) {
+				break
+			}
+
 			resHeader := b.encodeHeader(res.headerVersion(), req.correlationID, uint32(len(encodedRes)))
 			if _, err = conn.Write(resHeader); err != nil {
 				b.serverError(err)
@@ -344,6 +389,22 @@ func (b *MockBroker) handleRequests(conn io.ReadWriteCloser, idx int, wg *sync.W
 	Logger.Printf("*** mockbroker/%d/%d: connection closed, err=%v", b.BrokerID(), idx, err)
 }
 
+// delay pauses for the given latency, it returns false if the broker was
+// closed in the meantime.
+func (b *MockBroker) delay(latency time.Duration) bool {
+	if latency <= 0 {
+		return true
+	}
+	timer := time.NewTimer(latency)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-b.closing:
+		return false
+	}
+}
+
 func (b *MockBroker) encodeHeader(headerVersion int16, correlationId int32, payloadLength uint32) []byte {
 	headerLength := uint32(8)
 
@@ -0,0 +1,104 @@
+//go:build !functional
+
+package sarama
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockBrokerLatencyTriggersReadTimeout(t *testing.T) {
+	mb := NewMockBroker(t, 0)
+	defer mb.Close()
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t),
+	})
+	mb.SetLatency(500 * time.Millisecond)
+
+	conf := NewTestConfig()
+	conf.Net.ReadTimeout = 50 * time.Millisecond
+	broker := NewBroker(mb.Addr())
+	require.NoError(t, broker.Open(conf))
+	defer safeClose(t, broker)
+
+	_, err := broker.GetMetadata(&MetadataRequest{})
+	var nerr net.Error
+	require.True(t, errors.As(err, &nerr) && nerr.Timeout(), "expected a timeout, got %v", err)
+}
+
+func TestMockBrokerLatencyCancelledOnClose(t *testing.T) {
+	mb := NewMockBroker(t, 0)
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t),
+	})
+	mb.SetLatency(time.Hour)
+
+	broker := NewBroker(mb.Addr())
+	require.NoError(t, broker.Open(NewTestConfig()))
+	defer func() { _ = broker.Close() }()
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := broker.GetMetadata(&MetadataRequest{})
+		errs <- err
+	}()
+	// wait for the request to be received before closing
+	require.Eventually(t, func() bool { return len(mb.History()) == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	closed := make(chan none)
+	go func() {
+		mb.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("closing the mock broker did not cut the latency short")
+	}
+	require.Error(t, <-errs)
+}
+
+func TestMockThrottledResponse(t *testing.T) {
+	throttle := 200 * time.Millisecond
+	mb := NewMockBroker(t, 0)
+	defer mb.Close()
+	mb.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockThrottledResponse(t, NewMockMetadataResponse(t), throttle),
+	})
+
+	conf := NewTestConfig()
+	conf.Version = V1_0_0_0
+	broker := NewBroker(mb.Addr())
+	require.NoError(t, broker.Open(conf))
+	defer safeClose(t, broker)
+
+	res, err := broker.GetMetadata(NewMetadataRequest(conf.Version, nil))
+	require.NoError(t, err)
+	require.Equal(t, int32(throttle/time.Millisecond), res.ThrottleTimeMs)
+
+	// the next request must wait for the throttle time reported by the broker
+	start := time.Now()
+	_, err = broker.GetMetadata(NewMetadataRequest(conf.Version, nil))
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, time.Since(start), throttle/2)
+}
+
+func TestSetThrottleTime(t *testing.T) {
+	produce := &ProduceResponse{}
+	require.True(t, setThrottleTime(produce, 1500*time.Millisecond))
+	require.Equal(t, 1500*time.Millisecond, produce.ThrottleTime)
+
+	heartbeat := &HeartbeatResponse{}
+	require.True(t, setThrottleTime(heartbeat, 1500*time.Millisecond))
+	require.Equal(t, int32(1500), heartbeat.ThrottleTime)
+
+	metadata := &MetadataResponse{}
+	require.True(t, setThrottleTime(metadata, 1500*time.Millisecond))
+	require.Equal(t, int32(1500), metadata.ThrottleTimeMs)
+
+	require.False(t, setThrottleTime(&SaslHandshakeResponse{}, time.Second))
+}
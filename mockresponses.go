@@ -2,8 +2,11 @@ package sarama
 
 import (
 	"fmt"
+	"reflect"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 )
 
 // TestReporter has methods matching go's testing.T to avoid importing
@@ -69,6 +72,52 @@ func (mc *MockSequence) For(reqBody versionedDecoder) (res encoderWithHeader) {
 	return res
 }
 
+// MockThrottledResponse is a mock response builder that sets the throttle time
+// of the responses built by another MockResponse, as a broker enforcing a quota
+// would. It allows testing that clients back off for the throttle time
+// reported by the broker.
+type MockThrottledResponse struct {
+	t            TestReporter
+	res          MockResponse
+	throttleTime time.Duration
+}
+
+func NewMockThrottledResponse(t TestReporter, res MockResponse, throttleTime time.Duration) *MockThrottledResponse {
+	return &MockThrottledResponse{t: t, res: res, throttleTime: throttleTime}
+}
+
+func (mr *MockThrottledResponse) For(reqBody versionedDecoder) encoderWithHeader {
+	res := mr.res.For(reqBody)
+	if res != nil && !setThrottleTime(res, mr.throttleTime) {
+		mr.t.Errorf("%T does not carry a throttle time", res)
+	}
+	return res
+}
+
+// setThrottleTime sets the throttle time of a response, whichever way the
+// response represents it. It returns false if the response has none.
+func setThrottleTime(res encoderWithHeader, throttleTime time.Duration) bool {
+	v := reflect.ValueOf(res)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return false
+	}
+	for _, name := range []string{"ThrottleTime", "ThrottleTimeMs"} {
+		field := v.Elem().FieldByName(name)
+		if !field.IsValid() || !field.CanSet() {
+			continue
+		}
+		switch {
+		case field.Type() == reflect.TypeOf(time.Duration(0)):
+			field.SetInt(int64(throttleTime))
+			return true
+		case field.Kind() == reflect.Int32:
+			field.SetInt(int64(throttleTime / time.Millisecond))
+			return true
+		}
+	}
+	return false
+}
+
 type MockListGroupsResponse struct {
 	groups map[string]string
 	t      TestReporter
@@ -137,19 +186,29 @@ type MockMetadataResponse struct {
 	controllerID int32
 	errors       map[string]KError
 	leaders      map[string]map[int32]int32
+	leaderEpochs map[string]map[int32]int32
 	brokers      map[string]int32
+	topicIDs     map[string]Uuid
 	t            TestReporter
 }
 
 func NewMockMetadataResponse(t TestReporter) *MockMetadataResponse {
 	return &MockMetadataResponse{
-		errors:  make(map[string]KError),
-		leaders: make(map[string]map[int32]int32),
-		brokers: make(map[string]int32),
-		t:       t,
+		errors:       make(map[string]KError),
+		leaders:      make(map[string]map[int32]int32),
+		leaderEpochs: make(map[string]map[int32]int32),
+		brokers:      make(map[string]int32),
+		topicIDs:     make(map[string]Uuid),
+		t:            t,
 	}
 }
 
+// SetTopicID sets the topic ID returned for a topic by metadata requests v10+.
+func (mmr *MockMetadataResponse) SetTopicID(topic string, topicID Uuid) *MockMetadataResponse {
+	mmr.topicIDs[topic] = topicID
+	return mmr
+}
+
 func (mmr *MockMetadataResponse) SetError(topic string, kerror KError) *MockMetadataResponse {
 	mmr.errors[topic] = kerror
 	return mmr
@@ -165,6 +224,18 @@ func (mmr *MockMetadataResponse) SetLeader(topic string, partition, brokerID int
 	return mmr
 }
 
+// SetLeaderEpoch sets the leader epoch returned for a partition by metadata
+// requests v7+.
+func (mmr *MockMetadataResponse) SetLeaderEpoch(topic string, partition, leaderEpoch int32) *MockMetadataResponse {
+	partitions := mmr.leaderEpochs[topic]
+	if partitions == nil {
+		partitions = make(map[int32]int32)
+		mmr.leaderEpochs[topic] = partitions
+	}
+	partitions[partition] = leaderEpoch
+	return mmr
+}
+
 func (mmr *MockMetadataResponse) SetBroker(addr string, brokerID int32) *MockMetadataResponse {
 	mmr.brokers[addr] = brokerID
 	return mmr
@@ -201,6 +272,8 @@ func (mmr *MockMetadataResponse) For(reqBody versionedDecoder) encoderWithHeader
 		for topic, err := range mmr.errors {
 			metadataResponse.AddTopic(topic, err)
 		}
+		mmr.setTopicIDs(metadataResponse)
+		mmr.setLeaderEpochs(metadataResponse)
 		return metadataResponse
 	}
 	for _, topic := range metadataRequest.Topics {
@@ -217,9 +290,29 @@ func (mmr *MockMetadataResponse) For(reqBody versionedDecoder) encoderWithHeader
 			metadataResponse.AddTopicPartition(topic, partition, brokerID, replicas, replicas, offlineReplicas, ErrNoError)
 		}
 	}
+	mmr.setTopicIDs(metadataResponse)
+	mmr.setLeaderEpochs(metadataResponse)
 	return metadataResponse
 }
 
+func (mmr *MockMetadataResponse) setLeaderEpochs(metadataResponse *MetadataResponse) {
+	for _, topic := range metadataResponse.Topics {
+		for _, partition := range topic.Partitions {
+			if leaderEpoch, ok := mmr.leaderEpochs[topic.Name][partition.ID]; ok {
+				partition.LeaderEpoch = leaderEpoch
+			}
+		}
+	}
+}
+
+func (mmr *MockMetadataResponse) setTopicIDs(metadataResponse *MetadataResponse) {
+	for _, topic := range metadataResponse.Topics {
+		if topicID, ok := mmr.topicIDs[topic.Name]; ok {
+			topic.Uuid = topicID
+		}
+	}
+}
+
 // MockOffsetResponse is an `OffsetResponse` builder.
 type MockOffsetResponse struct {
 	offsets map[string]map[int32]map[int64]int64
@@ -276,6 +369,51 @@ func (mor *MockOffsetResponse) getOffset(topic string, partition int32, time int
 	return offset
 }
 
+// MockOffsetForLeaderEpochResponse is an `OffsetForLeaderEpochResponse` builder.
+type MockOffsetForLeaderEpochResponse struct {
+	endOffsets map[string]map[int32]*OffsetForLeaderEpochResponsePartition
+	t          TestReporter
+}
+
+func NewMockOffsetForLeaderEpochResponse(t TestReporter) *MockOffsetForLeaderEpochResponse {
+	return &MockOffsetForLeaderEpochResponse{
+		endOffsets: make(map[string]map[int32]*OffsetForLeaderEpochResponsePartition),
+		t:          t,
+	}
+}
+
+// SetEndOffset sets the leader epoch and end offset returned for a partition,
+// whatever the requested epoch is.
+func (m *MockOffsetForLeaderEpochResponse) SetEndOffset(topic string, partition int32, leaderEpoch int32, endOffset int64) *MockOffsetForLeaderEpochResponse {
+	partitions := m.endOffsets[topic]
+	if partitions == nil {
+		partitions = make(map[int32]*OffsetForLeaderEpochResponsePartition)
+		m.endOffsets[topic] = partitions
+	}
+	partitions[partition] = &OffsetForLeaderEpochResponsePartition{
+		Err:         ErrNoError,
+		Partition:   partition,
+		LeaderEpoch: leaderEpoch,
+		EndOffset:   endOffset,
+	}
+	return m
+}
+
+func (m *MockOffsetForLeaderEpochResponse) For(reqBody versionedDecoder) encoderWithHeader {
+	req := reqBody.(*OffsetForLeaderEpochRequest)
+	res := &OffsetForLeaderEpochResponse{Version: req.Version}
+	for _, topic := range req.Topics {
+		for _, partition := range topic.Partitions {
+			if block := m.endOffsets[topic.Name][partition.Partition]; block != nil {
+				res.AddBlock(topic.Name, partition.Partition, block.Err, block.LeaderEpoch, block.EndOffset)
+			} else {
+				res.AddBlock(topic.Name, partition.Partition, ErrUnknownTopicOrPartition, -1, -1)
+			}
+		}
+	}
+	return res
+}
+
 // mockMessage is a message that used to be mocked for `FetchResponse`
 type mockMessage struct {
 	key Encoder
@@ -553,9 +691,10 @@ func (mr *MockOffsetCommitResponse) getError(group, topic string, partition int3
 
 // MockProduceResponse is a `ProduceResponse` builder.
 type MockProduceResponse struct {
-	version int16
-	errors  map[string]map[int32]KError
-	t       TestReporter
+	version        int16
+	errors         map[string]map[int32]KError
+	logAppendTimes map[string]map[int32]time.Time
+	t              TestReporter
 }
 
 func NewMockProduceResponse(t TestReporter) *MockProduceResponse {
@@ -580,6 +719,23 @@ func (mr *MockProduceResponse) SetError(topic string, partition int32, kerror KE
 	return mr
 }
 
+// SetLogAppendTime sets the timestamp assigned by the broker to the records
+// produced to a partition, as returned by produce requests v2+ for topics
+// configured with `LogAppendTime`. Partitions without a log append time
+// behave as if configured with `CreateTime`.
+func (mr *MockProduceResponse) SetLogAppendTime(topic string, partition int32, logAppendTime time.Time) *MockProduceResponse {
+	if mr.logAppendTimes == nil {
+		mr.logAppendTimes = make(map[string]map[int32]time.Time)
+	}
+	partitions := mr.logAppendTimes[topic]
+	if partitions == nil {
+		partitions = make(map[int32]time.Time)
+		mr.logAppendTimes[topic] = partitions
+	}
+	partitions[partition] = logAppendTime
+	return mr
+}
+
 func (mr *MockProduceResponse) For(reqBody versionedDecoder) encoderWithHeader {
 	req := reqBody.(*ProduceRequest)
 	res := &ProduceResponse{
@@ -591,6 +747,7 @@ func (mr *MockProduceResponse) For(reqBody versionedDecoder) encoderWithHeader {
 	for topic, partitions := range req.records {
 		for partition := range partitions {
 			res.AddTopicPartition(topic, partition, mr.getError(topic, partition))
+			res.GetBlock(topic, partition).Timestamp = mr.logAppendTimes[topic][partition]
 		}
 	}
 	return res
@@ -674,11 +831,17 @@ func (mr *MockOffsetFetchResponse) For(reqBody versionedDecoder) encoderWithHead
 }
 
 type MockCreateTopicsResponse struct {
-	t TestReporter
+	t      TestReporter
+	errors map[string]KError
 }
 
 func NewMockCreateTopicsResponse(t TestReporter) *MockCreateTopicsResponse {
-	return &MockCreateTopicsResponse{t: t}
+	return &MockCreateTopicsResponse{t: t, errors: make(map[string]KError)}
+}
+
+func (mr *MockCreateTopicsResponse) SetError(topic string, kerror KError) *MockCreateTopicsResponse {
+	mr.errors[topic] = kerror
+	return mr
 }
 
 func (mr *MockCreateTopicsResponse) For(reqBody versionedDecoder) encoderWithHeader {
@@ -697,6 +860,10 @@ func (mr *MockCreateTopicsResponse) For(reqBody versionedDecoder) encoderWithHea
 			}
 			continue
 		}
+		if kerror, ok := mr.errors[topic]; ok {
+			res.TopicErrors[topic] = &TopicError{Err: kerror}
+			continue
+		}
 		res.TopicErrors[topic] = &TopicError{Err: ErrNoError}
 	}
 	return res
@@ -793,33 +960,71 @@ func (mr *MockListPartitionReassignmentsResponse) For(reqBody versionedDecoder)
 }
 
 type MockElectLeadersResponse struct {
-	t TestReporter
+	t      TestReporter
+	errors map[string]map[int32]KError
 }
 
 func NewMockElectLeadersResponse(t TestReporter) *MockElectLeadersResponse {
 	return &MockElectLeadersResponse{t: t}
 }
 
+// SetPartitionError sets the error returned for a partition. Partitions
+// without an error set are reported as elected successfully. When the request
+// targets all partitions, only the partitions with an error set are returned.
+func (mr *MockElectLeadersResponse) SetPartitionError(topic string, partition int32, kerror KError) *MockElectLeadersResponse {
+	if mr.errors == nil {
+		mr.errors = make(map[string]map[int32]KError)
+	}
+	if mr.errors[topic] == nil {
+		mr.errors[topic] = make(map[int32]KError)
+	}
+	mr.errors[topic][partition] = kerror
+	return mr
+}
+
 func (mr *MockElectLeadersResponse) For(reqBody versionedDecoder) encoderWithHeader {
 	req := reqBody.(*ElectLeadersRequest)
 	res := &ElectLeadersResponse{Version: req.version(), ReplicaElectionResults: map[string]map[int32]*PartitionResult{}}
 
+	addResult := func(topic string, partition int32) {
+		if res.ReplicaElectionResults[topic] == nil {
+			res.ReplicaElectionResults[topic] = map[int32]*PartitionResult{}
+		}
+		res.ReplicaElectionResults[topic][partition] = &PartitionResult{ErrorCode: mr.errors[topic][partition]}
+	}
+
+	if req.TopicPartitions == nil {
+		for topic, partitions := range mr.errors {
+			for partition := range partitions {
+				addResult(topic, partition)
+			}
+		}
+		return res
+	}
 	for topic, partitions := range req.TopicPartitions {
 		for _, partition := range partitions {
-			res.ReplicaElectionResults[topic] = map[int32]*PartitionResult{
-				partition: {ErrorCode: ErrNoError},
-			}
+			addResult(topic, partition)
 		}
 	}
 	return res
 }
 
 type MockDeleteRecordsResponse struct {
-	t TestReporter
+	t      TestReporter
+	errors map[string]map[int32]KError
 }
 
 func NewMockDeleteRecordsResponse(t TestReporter) *MockDeleteRecordsResponse {
-	return &MockDeleteRecordsResponse{t: t}
+	return &MockDeleteRecordsResponse{t: t, errors: make(map[string]map[int32]KError)}
+}
+
+// SetError makes the deletion of the records of the given partition fail with kerror.
+func (mr *MockDeleteRecordsResponse) SetError(topic string, partition int32, kerror KError) *MockDeleteRecordsResponse {
+	if mr.errors[topic] == nil {
+		mr.errors[topic] = make(map[int32]KError)
+	}
+	mr.errors[topic][partition] = kerror
+	return mr
 }
 
 func (mr *MockDeleteRecordsResponse) For(reqBody versionedDecoder) encoderWithHeader {
@@ -829,14 +1034,116 @@ func (mr *MockDeleteRecordsResponse) For(reqBody versionedDecoder) encoderWithHe
 
 	for topic, deleteRecordRequestTopic := range req.Topics {
 		partitions := make(map[int32]*DeleteRecordsResponsePartition)
-		for partition := range deleteRecordRequestTopic.PartitionOffsets {
-			partitions[partition] = &DeleteRecordsResponsePartition{Err: ErrNoError}
+		for partition, offset := range deleteRecordRequestTopic.PartitionOffsets {
+			if kerror, ok := mr.errors[topic][partition]; ok {
+				partitions[partition] = &DeleteRecordsResponsePartition{LowWatermark: -1, Err: kerror}
+				continue
+			}
+			partitions[partition] = &DeleteRecordsResponsePartition{LowWatermark: offset, Err: ErrNoError}
 		}
 		res.Topics[topic] = &DeleteRecordsResponseTopic{Partitions: partitions}
 	}
 	return res
 }
 
+type MockDescribeProducersResponse struct {
+	t         TestReporter
+	producers map[string]map[int32][]*ProducerState
+}
+
+func NewMockDescribeProducersResponse(t TestReporter) *MockDescribeProducersResponse {
+	return &MockDescribeProducersResponse{t: t, producers: make(map[string]map[int32][]*ProducerState)}
+}
+
+func (mr *MockDescribeProducersResponse) SetProducers(topic string, partition int32, producers ...*ProducerState) *MockDescribeProducersResponse {
+	if mr.producers[topic] == nil {
+		mr.producers[topic] = make(map[int32][]*ProducerState)
+	}
+	mr.producers[topic][partition] = producers
+	return mr
+}
+
+func (mr *MockDescribeProducersResponse) For(reqBody versionedDecoder) encoderWithHeader {
+	req := reqBody.(*DescribeProducersRequest)
+	res := &DescribeProducersResponse{Version: req.Version}
+	for _, topic := range req.Topics {
+		resTopic := &DescribeProducersResponseTopic{Name: topic.Name}
+		for _, partition := range topic.PartitionIndexes {
+			resPartition := &DescribeProducersResponsePartition{PartitionIndex: partition}
+			if producers, ok := mr.producers[topic.Name][partition]; ok {
+				resPartition.ActiveProducers = producers
+			} else {
+				resPartition.ErrorCode = ErrUnknownTopicOrPartition
+			}
+			resTopic.Partitions = append(resTopic.Partitions, resPartition)
+		}
+		res.Topics = append(res.Topics, resTopic)
+	}
+	return res
+}
+
+type MockDescribeTransactionsResponse struct {
+	t      TestReporter
+	states map[string]*TransactionState
+}
+
+func NewMockDescribeTransactionsResponse(t TestReporter) *MockDescribeTransactionsResponse {
+	return &MockDescribeTransactionsResponse{t: t, states: make(map[string]*TransactionState)}
+}
+
+func (mr *MockDescribeTransactionsResponse) SetTransactionState(state *TransactionState) *MockDescribeTransactionsResponse {
+	mr.states[state.TransactionalID] = state
+	return mr
+}
+
+func (mr *MockDescribeTransactionsResponse) For(reqBody versionedDecoder) encoderWithHeader {
+	req := reqBody.(*DescribeTransactionsRequest)
+	res := &DescribeTransactionsResponse{Version: req.Version}
+	for _, transactionalID := range req.TransactionalIDs {
+		state, ok := mr.states[transactionalID]
+		if !ok {
+			state = &TransactionState{TransactionalID: transactionalID, ErrorCode: ErrTransactionalIDNotFound}
+		}
+		res.TransactionStates = append(res.TransactionStates, state)
+	}
+	return res
+}
+
+type MockListTransactionsResponse struct {
+	t        TestReporter
+	err      KError
+	listings []*TransactionListing
+}
+
+func NewMockListTransactionsResponse(t TestReporter) *MockListTransactionsResponse {
+	return &MockListTransactionsResponse{t: t}
+}
+
+func (mr *MockListTransactionsResponse) AddTransaction(listing *TransactionListing) *MockListTransactionsResponse {
+	mr.listings = append(mr.listings, listing)
+	return mr
+}
+
+func (mr *MockListTransactionsResponse) SetError(kerror KError) *MockListTransactionsResponse {
+	mr.err = kerror
+	return mr
+}
+
+func (mr *MockListTransactionsResponse) For(reqBody versionedDecoder) encoderWithHeader {
+	req := reqBody.(*ListTransactionsRequest)
+	res := &ListTransactionsResponse{Version: req.Version, ErrorCode: mr.err}
+	for _, listing := range mr.listings {
+		if len(req.StateFilters) > 0 && !slices.Contains(req.StateFilters, listing.State) {
+			continue
+		}
+		if len(req.ProducerIDFilters) > 0 && !slices.Contains(req.ProducerIDFilters, listing.ProducerID) {
+			continue
+		}
+		res.TransactionStates = append(res.TransactionStates, listing)
+	}
+	return res
+}
+
 type MockDescribeConfigsResponse struct {
 	t TestReporter
 }
@@ -868,6 +1175,7 @@ func (mr *MockDescribeConfigsResponse) For(reqBody versionedDecoder) encoderWith
 			)
 			res.Resources = append(res.Resources, &ResourceResponse{
 				Name:    r.Name,
+				Type:    r.Type,
 				Configs: configEntries,
 			})
 		case BrokerLoggerResource:
@@ -881,6 +1189,7 @@ func (mr *MockDescribeConfigsResponse) For(reqBody versionedDecoder) encoderWith
 			)
 			res.Resources = append(res.Resources, &ResourceResponse{
 				Name:    r.Name,
+				Type:    r.Type,
 				Configs: configEntries,
 			})
 		case TopicResource:
@@ -928,6 +1237,7 @@ func (mr *MockDescribeConfigsResponse) For(reqBody versionedDecoder) encoderWith
 				configEntries, maxMessageBytes, retentionMs, password)
 			res.Resources = append(res.Resources, &ResourceResponse{
 				Name:    r.Name,
+				Type:    r.Type,
 				Configs: configEntries,
 			})
 		}
@@ -1424,6 +1734,69 @@ func (m *MockHeartbeatResponse) SetError(kerr KError) *MockHeartbeatResponse {
 	return m
 }
 
+// MockConsumerGroupHeartbeatResponse is a `ConsumerGroupHeartbeatResponse` builder.
+// It returns the configured assignment on every heartbeat which reports owned
+// partitions, like the coordinator does.
+type MockConsumerGroupHeartbeatResponse struct {
+	t TestReporter
+
+	Err                 KError
+	MemberId            string
+	MemberEpoch         int32
+	HeartbeatIntervalMs int32
+	Assignment          map[Uuid][]int32
+}
+
+func NewMockConsumerGroupHeartbeatResponse(t TestReporter) *MockConsumerGroupHeartbeatResponse {
+	return &MockConsumerGroupHeartbeatResponse{
+		t:                   t,
+		MemberEpoch:         1,
+		HeartbeatIntervalMs: 50,
+		Assignment:          make(map[Uuid][]int32),
+	}
+}
+
+func (m *MockConsumerGroupHeartbeatResponse) For(reqBody versionedDecoder) encoderWithHeader {
+	req := reqBody.(*ConsumerGroupHeartbeatRequest)
+	resp := &ConsumerGroupHeartbeatResponse{
+		Version:             req.version(),
+		Err:                 m.Err,
+		MemberEpoch:         m.MemberEpoch,
+		HeartbeatIntervalMs: m.HeartbeatIntervalMs,
+	}
+	if req.MemberEpoch < 0 {
+		resp.MemberEpoch = req.MemberEpoch
+		return resp
+	}
+	if req.MemberId == "" {
+		resp.MemberId = &m.MemberId
+	}
+	if req.TopicPartitions != nil {
+		resp.Assignment = &ConsumerGroupHeartbeatAssignment{}
+		for topicID, partitions := range m.Assignment {
+			resp.Assignment.TopicPartitions = append(resp.Assignment.TopicPartitions,
+				&ConsumerGroupHeartbeatTopicPartitions{TopicId: topicID, Partitions: partitions})
+		}
+	}
+	return resp
+}
+
+func (m *MockConsumerGroupHeartbeatResponse) SetError(kerr KError) *MockConsumerGroupHeartbeatResponse {
+	m.Err = kerr
+	return m
+}
+
+func (m *MockConsumerGroupHeartbeatResponse) SetMember(memberID string, memberEpoch int32) *MockConsumerGroupHeartbeatResponse {
+	m.MemberId = memberID
+	m.MemberEpoch = memberEpoch
+	return m
+}
+
+func (m *MockConsumerGroupHeartbeatResponse) SetAssignment(topicID Uuid, partitions ...int32) *MockConsumerGroupHeartbeatResponse {
+	m.Assignment[topicID] = partitions
+	return m
+}
+
 type MockDescribeLogDirsResponse struct {
 	t       TestReporter
 	logDirs []DescribeLogDirsResponseDirMetadata
@@ -1459,6 +1832,25 @@ func (m *MockDescribeLogDirsResponse) SetLogDirs(logDirPath string, topicPartiti
 	return m
 }
 
+// SetLogDirCapacity sets the total and usable bytes of every log dir, which are
+// only encoded from version 4.
+func (m *MockDescribeLogDirsResponse) SetLogDirCapacity(totalBytes, usableBytes int64) *MockDescribeLogDirsResponse {
+	for i := range m.logDirs {
+		m.logDirs[i].TotalBytes = totalBytes
+		m.logDirs[i].UsableBytes = usableBytes
+	}
+	return m
+}
+
+// AddLogDirError adds a log dir that failed with the given error.
+func (m *MockDescribeLogDirsResponse) AddLogDirError(logDirPath string, kerror KError) *MockDescribeLogDirsResponse {
+	m.logDirs = append(m.logDirs, DescribeLogDirsResponseDirMetadata{
+		ErrorCode: kerror,
+		Path:      logDirPath,
+	})
+	return m
+}
+
 func (m *MockDescribeLogDirsResponse) For(reqBody versionedDecoder) encoderWithHeader {
 	req := reqBody.(*DescribeLogDirsRequest)
 	resp := &DescribeLogDirsResponse{
@@ -1544,3 +1936,102 @@ func (m *MockInitProducerIDResponse) For(reqBody versionedDecoder) encoderWithHe
 	}
 	return res
 }
+
+type MockAlterClientQuotasResponse struct {
+	t   TestReporter
+	err KError
+}
+
+func NewMockAlterClientQuotasResponse(t TestReporter) *MockAlterClientQuotasResponse {
+	return &MockAlterClientQuotasResponse{t: t}
+}
+
+func (m *MockAlterClientQuotasResponse) SetError(err KError) *MockAlterClientQuotasResponse {
+	m.err = err
+	return m
+}
+
+func (m *MockAlterClientQuotasResponse) For(reqBody versionedDecoder) encoderWithHeader {
+	req := reqBody.(*AlterClientQuotasRequest)
+	res := &AlterClientQuotasResponse{Version: req.Version}
+	for _, entry := range req.Entries {
+		res.Entries = append(res.Entries, AlterClientQuotasEntryResponse{
+			ErrorCode: m.err,
+			Entity:    entry.Entity,
+		})
+	}
+	return res
+}
+
+// MockAlterUserScramCredentialsResponse is an AlterUserScramCredentialsResponse
+// builder answering every deleted and upserted user, with the errors set by
+// SetError.
+type MockAlterUserScramCredentialsResponse struct {
+	t      TestReporter
+	errors map[string]KError
+}
+
+func NewMockAlterUserScramCredentialsResponse(t TestReporter) *MockAlterUserScramCredentialsResponse {
+	return &MockAlterUserScramCredentialsResponse{t: t, errors: make(map[string]KError)}
+}
+
+// SetError sets the error returned for the given user.
+func (m *MockAlterUserScramCredentialsResponse) SetError(user string, err KError) *MockAlterUserScramCredentialsResponse {
+	m.errors[user] = err
+	return m
+}
+
+func (m *MockAlterUserScramCredentialsResponse) For(reqBody versionedDecoder) encoderWithHeader {
+	req := reqBody.(*AlterUserScramCredentialsRequest)
+	res := &AlterUserScramCredentialsResponse{Version: req.Version}
+	users := make([]string, 0, len(req.Deletions)+len(req.Upsertions))
+	for _, d := range req.Deletions {
+		users = append(users, d.Name)
+	}
+	for _, u := range req.Upsertions {
+		users = append(users, u.Name)
+	}
+	for _, user := range users {
+		res.Results = append(res.Results, &AlterUserScramCredentialsResult{
+			User:      user,
+			ErrorCode: m.errors[user],
+		})
+	}
+	return res
+}
+
+// MockDescribeUserScramCredentialsResponse is a
+// DescribeUserScramCredentialsResponse builder answering the described users
+// with the credentials set by SetCredential, or ErrResourceNotFound.
+type MockDescribeUserScramCredentialsResponse struct {
+	t           TestReporter
+	credentials map[string][]*UserScramCredentialsResponseInfo
+}
+
+func NewMockDescribeUserScramCredentialsResponse(t TestReporter) *MockDescribeUserScramCredentialsResponse {
+	return &MockDescribeUserScramCredentialsResponse{t: t, credentials: make(map[string][]*UserScramCredentialsResponseInfo)}
+}
+
+// SetCredential adds a credential of the given mechanism to the user.
+func (m *MockDescribeUserScramCredentialsResponse) SetCredential(user string, mechanism ScramMechanismType, iterations int32) *MockDescribeUserScramCredentialsResponse {
+	m.credentials[user] = append(m.credentials[user], &UserScramCredentialsResponseInfo{
+		Mechanism:  mechanism,
+		Iterations: iterations,
+	})
+	return m
+}
+
+func (m *MockDescribeUserScramCredentialsResponse) For(reqBody versionedDecoder) encoderWithHeader {
+	req := reqBody.(*DescribeUserScramCredentialsRequest)
+	res := &DescribeUserScramCredentialsResponse{Version: req.Version}
+	for _, u := range req.DescribeUsers {
+		result := &DescribeUserScramCredentialsResult{User: u.Name}
+		if infos, ok := m.credentials[u.Name]; ok {
+			result.CredentialInfos = infos
+		} else {
+			result.ErrorCode = ErrResourceNotFound
+		}
+		res.Results = append(res.Results, result)
+	}
+	return res
+}
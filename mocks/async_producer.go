@@ -1,8 +1,10 @@
 package mocks
 
 import (
+	"context"
 	"errors"
 	"sync"
+	"time"
 
 	"github.com/IBM/sarama"
 )
@@ -25,6 +27,13 @@ type AsyncProducer struct {
 	txnLock         sync.Mutex
 	txnStatus       sarama.ProducerTxnStatusFlag
 	lastOffset      int64
+
+	// outstanding counts the expectations whose message has not been handled
+	// yet, drained is closed for the Flush callers once it drops to zero
+	flushLock   sync.Mutex
+	outstanding int
+	drained     chan struct{}
+
 	*TopicConfig
 }
 
@@ -73,6 +82,7 @@ func NewAsyncProducer(t ErrorReporter, config *sarama.Config) *AsyncProducer {
 				partitioner = config.Producer.Partitioner(msg.Topic)
 				partitioners[msg.Topic] = partitioner
 			}
+			consumed := false
 			mp.l.Lock()
 			if len(mp.expectations) == 0 {
 				mp.expectations = nil
@@ -80,6 +90,7 @@ func NewAsyncProducer(t ErrorReporter, config *sarama.Config) *AsyncProducer {
 			} else {
 				expectation := mp.expectations[0]
 				mp.expectations = mp.expectations[1:]
+				consumed = true
 
 				partition, err := partitioner.Partition(msg, mp.partitions(msg.Topic))
 				if err != nil {
@@ -106,13 +117,19 @@ func NewAsyncProducer(t ErrorReporter, config *sarama.Config) *AsyncProducer {
 				}
 			}
 			mp.l.Unlock()
+			if consumed {
+				mp.handled(1)
+			}
 		}
 
 		mp.l.Lock()
-		if len(mp.expectations) > 0 {
-			mp.t.Errorf("Expected to exhaust all expectations, but %d are left.", len(mp.expectations))
+		left := len(mp.expectations)
+		if left > 0 {
+			mp.t.Errorf("Expected to exhaust all expectations, but %d are left.", left)
 		}
 		mp.l.Unlock()
+		// no more messages will be handled, release the Flush callers
+		mp.handled(left)
 	}()
 
 	return mp
@@ -192,6 +209,12 @@ func (mp *AsyncProducer) TxnStatus() sarama.ProducerTxnStatusFlag {
 	return mp.txnStatus
 }
 
+// ProducerID corresponds with the ProducerID method of sarama's Producer
+// implementation. The mock has no producer ID, so it always returns -1 and -1.
+func (mp *AsyncProducer) ProducerID() (int64, int16) {
+	return -1, -1
+}
+
 func (mp *AsyncProducer) AddOffsetsToTxn(offsets map[string][]*sarama.PartitionOffsetMetadata, groupId string) error {
 	return nil
 }
@@ -200,6 +223,52 @@ func (mp *AsyncProducer) AddMessageToTxn(msg *sarama.ConsumerMessage, groupId st
 	return nil
 }
 
+func (mp *AsyncProducer) SendOffsetsAndCommit(ctx context.Context, offsets map[string][]*sarama.PartitionOffsetMetadata, groupMetadata *sarama.ConsumerGroupMetadata) error {
+	return nil
+}
+
+// Flush corresponds with the Flush method of sarama's Producer implementation.
+// The mock producer has no buffers of its own, so Flush waits until the messages
+// of every expectation set so far have been handled, returning
+// sarama.ErrProducerFlushTimeout if that takes longer than the timeout.
+func (mp *AsyncProducer) Flush(timeout time.Duration) error {
+	mp.flushLock.Lock()
+	if mp.outstanding == 0 {
+		mp.flushLock.Unlock()
+		return nil
+	}
+	if mp.drained == nil {
+		mp.drained = make(chan struct{})
+	}
+	drained := mp.drained
+	mp.flushLock.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-drained:
+		return nil
+	case <-timer.C:
+		return sarama.ErrProducerFlushTimeout
+	}
+}
+
+func (mp *AsyncProducer) expected() {
+	mp.flushLock.Lock()
+	defer mp.flushLock.Unlock()
+	mp.outstanding++
+}
+
+func (mp *AsyncProducer) handled(n int) {
+	mp.flushLock.Lock()
+	defer mp.flushLock.Unlock()
+	mp.outstanding -= n
+	if mp.outstanding <= 0 && mp.drained != nil {
+		close(mp.drained)
+		mp.drained = nil
+	}
+}
+
 ////////////////////////////////////////////////
 // Setting expectations
 ////////////////////////////////////////////////
@@ -213,6 +282,7 @@ func (mp *AsyncProducer) ExpectInputWithMessageCheckerFunctionAndSucceed(cf Mess
 	mp.l.Lock()
 	defer mp.l.Unlock()
 	mp.expectations = append(mp.expectations, &producerExpectation{Result: errProduceSuccess, CheckFunction: cf})
+	mp.expected()
 
 	return mp
 }
@@ -226,6 +296,7 @@ func (mp *AsyncProducer) ExpectInputWithMessageCheckerFunctionAndFail(cf Message
 	mp.l.Lock()
 	defer mp.l.Unlock()
 	mp.expectations = append(mp.expectations, &producerExpectation{Result: err, CheckFunction: cf})
+	mp.expected()
 
 	return mp
 }
@@ -8,6 +8,7 @@ import (
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/IBM/sarama"
 )
@@ -77,6 +78,30 @@ func TestProducerReturnsExpectationsToChannels(t *testing.T) {
 	}
 }
 
+func TestProducerFlushWaitsForExpectations(t *testing.T) {
+	config := NewTestConfig()
+	config.Producer.Return.Successes = true
+	mp := NewAsyncProducer(t, config).
+		ExpectInputAndSucceed().
+		ExpectInputAndFail(sarama.ErrOutOfBrokers)
+
+	if err := mp.Flush(10 * time.Millisecond); !errors.Is(err, sarama.ErrProducerFlushTimeout) {
+		t.Errorf("Expected ErrProducerFlushTimeout before any input, got %v", err)
+	}
+
+	mp.Input() <- &sarama.ProducerMessage{Topic: "test"}
+	mp.Input() <- &sarama.ProducerMessage{Topic: "test"}
+	if err := mp.Flush(time.Second); err != nil {
+		t.Error(err)
+	}
+
+	<-mp.Successes()
+	<-mp.Errors()
+	if err := mp.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestProducerWithTooFewExpectations(t *testing.T) {
 	trm := newTestReporterMock()
 	mp := NewAsyncProducer(trm, nil)
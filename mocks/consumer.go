@@ -1,8 +1,10 @@
 package mocks
 
 import (
+	"context"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/IBM/sarama"
 )
@@ -16,6 +18,7 @@ type Consumer struct {
 	config             *sarama.Config
 	partitionConsumers map[string]map[int32]*PartitionConsumer
 	metadata           map[string][]int32
+	watchers           map[string][]chan []int32
 }
 
 // NewConsumer returns a new mock Consumer instance. The t argument should
@@ -46,6 +49,60 @@ func NewConsumer(t ErrorReporter, config *sarama.Config) *Consumer {
 // Before you can start consuming a partition, you have to set expectations on it using
 // ExpectConsumePartition. You can only consume a partition once per consumer.
 func (c *Consumer) ConsumePartition(topic string, partition int32, offset int64) (sarama.PartitionConsumer, error) {
+	return c.consumePartition(topic, partition, offset)
+}
+
+// ConsumePartitionWithOptions implements the ConsumePartitionWithOptions
+// method from the sarama.Consumer interface. The options are ignored.
+func (c *Consumer) ConsumePartitionWithOptions(topic string, partition int32, offset int64, opts ...sarama.ConsumeOption) (sarama.PartitionConsumer, error) {
+	return c.consumePartition(topic, partition, offset)
+}
+
+// ConsumePartitionFromTime implements the ConsumePartitionFromTime method from the
+// sarama.Consumer interface. As the mock has no log to look the time up in, it
+// accepts any offset set on the ExpectConsumePartition expectation.
+func (c *Consumer) ConsumePartitionFromTime(topic string, partition int32, t time.Time) (sarama.PartitionConsumer, error) {
+	return c.consumePartition(topic, partition, AnyOffset)
+}
+
+// NewPartitionReader implements the NewPartitionReader method from the
+// sarama.Consumer interface. The reader returns the messages and the errors
+// yielded on the expectation set with ExpectConsumePartition, which is
+// consumed like with ConsumePartition.
+func (c *Consumer) NewPartitionReader(topic string, partition int32, offset int64) (sarama.PartitionReader, error) {
+	pc, err := c.consumePartition(topic, partition, offset)
+	if err != nil {
+		return nil, err
+	}
+	return &partitionReader{pc: pc.(*PartitionConsumer)}, nil
+}
+
+// UnwatchPartitions implements the UnwatchPartitions method from the
+// sarama.Consumer interface, closing a channel returned by WatchPartitions.
+func (c *Consumer) UnwatchPartitions(watch <-chan []int32) {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	for topic, watches := range c.watchers {
+		for i, w := range watches {
+			if w == watch {
+				c.watchers[topic] = append(watches[:i:i], watches[i+1:]...)
+				close(w)
+				return
+			}
+		}
+	}
+}
+
+// ConsumeTopics implements the ConsumeTopics method from the sarama.Consumer
+// interface. The partitions of the topics are looked up with WatchPartitions,
+// so they must be registered with SetTopicMetadata, and each of them is
+// consumed like with ConsumePartition, from Consumer.Offsets.Initial.
+func (c *Consumer) ConsumeTopics(topics []string) (sarama.MultiConsumer, error) {
+	return sarama.NewMultiConsumer(c, topics, c.config.Consumer.Offsets.Initial)
+}
+
+func (c *Consumer) consumePartition(topic string, partition int32, offset int64) (sarama.PartitionConsumer, error) {
 	c.l.Lock()
 	defer c.l.Unlock()
 
@@ -59,7 +116,7 @@ func (c *Consumer) ConsumePartition(topic string, partition int32, offset int64)
 		return nil, sarama.ConfigurationError("The topic/partition is already being consumed")
 	}
 
-	if pc.offset != AnyOffset && pc.offset != offset {
+	if pc.offset != AnyOffset && offset != AnyOffset && pc.offset != offset {
 		c.t.Errorf("Unexpected offset when calling ConsumePartition for %s/%d. Expected %d, got %d.", topic, partition, pc.offset, offset)
 	}
 
@@ -100,6 +157,30 @@ func (c *Consumer) Partitions(topic string) ([]int32, error) {
 	return c.metadata[topic], nil
 }
 
+// WatchPartitions implements the WatchPartitions method from the sarama.Consumer interface.
+// The partitions registered with SetTopicMetadata are sent right away, and again
+// whenever SetTopicMetadata adds partitions to the topic. The channel is closed by Close.
+func (c *Consumer) WatchPartitions(topic string) (<-chan []int32, error) {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	if c.metadata == nil {
+		c.t.Errorf("Unexpected call to WatchPartitions. Initialize the mock's topic metadata with SetTopicMetadata.")
+		return nil, sarama.ErrOutOfBrokers
+	}
+	if c.metadata[topic] == nil {
+		return nil, sarama.ErrUnknownTopicOrPartition
+	}
+
+	watch := make(chan []int32, 1)
+	watch <- c.metadata[topic]
+	if c.watchers == nil {
+		c.watchers = make(map[string][]chan []int32)
+	}
+	c.watchers[topic] = append(c.watchers[topic], watch)
+	return watch, nil
+}
+
 func (c *Consumer) HighWaterMarks() map[string]map[int32]int64 {
 	c.l.Lock()
 	defer c.l.Unlock()
@@ -116,6 +197,37 @@ func (c *Consumer) HighWaterMarks() map[string]map[int32]int64 {
 	return hwms
 }
 
+// TopicHighWaterMark implements the TopicHighWaterMark method from the
+// sarama.Consumer interface. Only the partitions consumed with ConsumePartition
+// are included in the sum.
+func (c *Consumer) TopicHighWaterMark(topic string) int64 {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	var hwm int64
+	for _, pc := range c.partitionConsumers[topic] {
+		if pc.consumed {
+			hwm += pc.HighWaterMarkOffset()
+		}
+	}
+	return hwm
+}
+
+// LagTotal implements the LagTotal method from the sarama.Consumer interface.
+// Only the partitions consumed with ConsumePartition are included in the sum.
+func (c *Consumer) LagTotal(topic string) int64 {
+	c.l.Lock()
+	defer c.l.Unlock()
+
+	var lag int64
+	for _, pc := range c.partitionConsumers[topic] {
+		if pc.consumed {
+			lag += pc.Lag()
+		}
+	}
+	return lag
+}
+
 // Close implements the Close method from the sarama.Consumer interface. It will close
 // all registered PartitionConsumer instances.
 func (c *Consumer) Close() error {
@@ -128,6 +240,13 @@ func (c *Consumer) Close() error {
 		}
 	}
 
+	for _, watches := range c.watchers {
+		for _, watch := range watches {
+			close(watch)
+		}
+	}
+	c.watchers = nil
+
 	return nil
 }
 
@@ -192,11 +311,25 @@ func (c *Consumer) ResumeAll() {
 ///////////////////////////////////////////////////
 
 // SetTopicMetadata sets the clusters topic/partition metadata,
-// which will be returned by Topics() and Partitions().
+// which will be returned by Topics() and Partitions(). Topics gaining
+// partitions are sent to their WatchPartitions channels.
 func (c *Consumer) SetTopicMetadata(metadata map[string][]int32) {
 	c.l.Lock()
 	defer c.l.Unlock()
 
+	for topic, watches := range c.watchers {
+		if len(metadata[topic]) <= len(c.metadata[topic]) {
+			continue
+		}
+		for _, watch := range watches {
+			// keep only the latest list if the previous one was not received
+			select {
+			case <-watch:
+			default:
+			}
+			watch <- metadata[topic]
+		}
+	}
 	c.metadata = metadata
 }
 
@@ -230,6 +363,7 @@ func (c *Consumer) ExpectConsumePartition(topic string, partition int32, offset
 			messages:           make(chan *sarama.ConsumerMessage, c.config.ChannelBufferSize),
 			suppressedMessages: make(chan *sarama.ConsumerMessage, c.config.ChannelBufferSize),
 			errors:             make(chan *sarama.ConsumerError, c.config.ChannelBufferSize),
+			eofs:               make(chan int64, c.config.ChannelBufferSize),
 		}
 		consumer.highWaterMarkOffset.Store(highWatermarkOffset)
 		c.partitionConsumers[topic][partition] = consumer
@@ -258,6 +392,7 @@ type PartitionConsumer struct {
 	messages                      chan *sarama.ConsumerMessage
 	suppressedMessages            chan *sarama.ConsumerMessage
 	errors                        chan *sarama.ConsumerError
+	eofs                          chan int64
 	singleClose                   sync.Once
 	consumed                      bool
 	errorsShouldBeDrained         bool
@@ -275,6 +410,7 @@ func (pc *PartitionConsumer) AsyncClose() {
 		close(pc.suppressedMessages)
 		close(pc.messages)
 		close(pc.errors)
+		close(pc.eofs)
 	})
 }
 
@@ -340,6 +476,12 @@ func (pc *PartitionConsumer) Errors() <-chan *sarama.ConsumerError {
 	return pc.errors
 }
 
+// EOFs implements the EOFs method from the sarama.PartitionConsumer interface.
+// Offsets are only sent on it by YieldEOF.
+func (pc *PartitionConsumer) EOFs() <-chan int64 {
+	return pc.eofs
+}
+
 // Messages implements the Messages method from the sarama.PartitionConsumer interface.
 func (pc *PartitionConsumer) Messages() <-chan *sarama.ConsumerMessage {
 	return pc.messages
@@ -349,6 +491,18 @@ func (pc *PartitionConsumer) HighWaterMarkOffset() int64 {
 	return pc.highWaterMarkOffset.Load()
 }
 
+// Lag implements the Lag method from the sarama.PartitionConsumer interface. The mock
+// considers every yielded message to be consumed, so it only reports lag while paused.
+func (pc *PartitionConsumer) Lag() int64 {
+	pc.l.Lock()
+	defer pc.l.Unlock()
+
+	if pc.paused {
+		return pc.suppressedHighWaterMarkOffset - pc.highWaterMarkOffset.Load()
+	}
+	return 0
+}
+
 // Pause implements the Pause method from the sarama.PartitionConsumer interface.
 func (pc *PartitionConsumer) Pause() {
 	pc.l.Lock()
@@ -424,6 +578,14 @@ func (pc *PartitionConsumer) YieldError(err error) *PartitionConsumer {
 	return pc
 }
 
+// YieldEOF will yield an end of partition signal at the given offset on the
+// EOFs channel, as the partition consumer sends when it caught up with the
+// high water mark.
+func (pc *PartitionConsumer) YieldEOF(offset int64) *PartitionConsumer {
+	pc.eofs <- offset
+	return pc
+}
+
 // ExpectMessagesDrainedOnClose sets an expectation on the partition consumer
 // that the messages channel will be fully drained when Close is called. If this
 // expectation is not met, an error is reported to the error reporter.
@@ -441,3 +603,34 @@ func (pc *PartitionConsumer) ExpectErrorsDrainedOnClose() *PartitionConsumer {
 
 	return pc
 }
+
+// partitionReader implements sarama's PartitionReader interface on top of a
+// mock PartitionConsumer.
+type partitionReader struct {
+	pc *PartitionConsumer
+}
+
+func (r *partitionReader) ReadMessage(ctx context.Context) (*sarama.ConsumerMessage, error) {
+	select {
+	case msg, ok := <-r.pc.Messages():
+		if !ok {
+			return nil, sarama.ErrClosedConsumer
+		}
+		return msg, nil
+	case cErr, ok := <-r.pc.Errors():
+		if !ok {
+			return nil, sarama.ErrClosedConsumer
+		}
+		return nil, cErr
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (r *partitionReader) HighWaterMarkOffset() int64 {
+	return r.pc.HighWaterMarkOffset()
+}
+
+func (r *partitionReader) Close() error {
+	return r.pc.Close()
+}
@@ -3,10 +3,12 @@
 package mocks
 
 import (
+	"context"
 	"errors"
 	"sort"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/IBM/sarama"
 )
@@ -226,6 +228,57 @@ func TestConsumerWithWrongOffsetExpectation(t *testing.T) {
 	}
 }
 
+func TestConsumerPartitionFromTime(t *testing.T) {
+	trm := newTestReporterMock()
+	consumer := NewConsumer(trm, NewTestConfig())
+	consumer.ExpectConsumePartition("test", 0, 1234).YieldMessage(&sarama.ConsumerMessage{Value: []byte("hello world")})
+
+	pc, err := consumer.ConsumePartitionFromTime("test", 0, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := <-pc.Messages(); string(msg.Value) != "hello world" {
+		t.Error("Message was not as expected:", msg)
+	}
+
+	if err := consumer.Close(); err != nil {
+		t.Error(err)
+	}
+	if len(trm.errors) != 0 {
+		t.Errorf("Expected no expectation failures to be set on the error reporter, found %v", trm.errors)
+	}
+}
+
+func TestConsumerPartitionReader(t *testing.T) {
+	trm := newTestReporterMock()
+	consumer := NewConsumer(trm, NewTestConfig())
+	pc := consumer.ExpectConsumePartition("test", 0, sarama.OffsetOldest).
+		YieldMessage(&sarama.ConsumerMessage{Value: []byte("hello world")})
+
+	reader, err := consumer.NewPartitionReader("test", 0, sarama.OffsetOldest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := reader.ReadMessage(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(msg.Value) != "hello world" {
+		t.Error("Message was not as expected:", msg)
+	}
+	pc.YieldError(sarama.ErrOutOfBrokers)
+	if _, err := reader.ReadMessage(context.Background()); !errors.Is(err, sarama.ErrOutOfBrokers) {
+		t.Error("Expected ErrOutOfBrokers, found:", err)
+	}
+
+	if err := reader.Close(); err != nil {
+		t.Error(err)
+	}
+	if len(trm.errors) != 0 {
+		t.Errorf("Expected no expectation failures to be set on the error reporter, found %v", trm.errors)
+	}
+}
+
 func TestConsumerViolatesMessagesDrainedExpectation(t *testing.T) {
 	trm := newTestReporterMock()
 	consumer := NewConsumer(trm, NewTestConfig())
@@ -334,6 +387,83 @@ func TestConsumerUnexpectedTopicMetadata(t *testing.T) {
 	}
 }
 
+func TestConsumerWatchPartitions(t *testing.T) {
+	trm := newTestReporterMock()
+	consumer := NewConsumer(trm, NewTestConfig())
+	consumer.SetTopicMetadata(map[string][]int32{"test": {0, 1}})
+
+	watch, err := consumer.WatchPartitions("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if partitions := <-watch; len(partitions) != 2 {
+		t.Error("Unexpected initial partitions:", partitions)
+	}
+
+	consumer.SetTopicMetadata(map[string][]int32{"test": {0, 1, 2}})
+	if partitions := <-watch; len(partitions) != 3 {
+		t.Error("Unexpected partitions after growth:", partitions)
+	}
+
+	if _, err := consumer.WatchPartitions("unknown"); !errors.Is(err, sarama.ErrUnknownTopicOrPartition) {
+		t.Error("Expected sarama.ErrUnknownTopicOrPartition, found", err)
+	}
+
+	unwatched, err := consumer.WatchPartitions("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-unwatched
+	consumer.UnwatchPartitions(unwatched)
+	if _, ok := <-unwatched; ok {
+		t.Error("Expected the watch channel to be closed by UnwatchPartitions")
+	}
+
+	if err := consumer.Close(); err != nil {
+		t.Error(err)
+	}
+	if _, ok := <-watch; ok {
+		t.Error("Expected the watch channel to be closed by Close")
+	}
+	if len(trm.errors) != 0 {
+		t.Errorf("Expected no expectation failures to be set on the error reporter.")
+	}
+}
+
+func TestConsumerConsumeTopics(t *testing.T) {
+	trm := newTestReporterMock()
+	consumer := NewConsumer(trm, NewTestConfig())
+	consumer.SetTopicMetadata(map[string][]int32{"test": {0}})
+	consumer.ExpectConsumePartition("test", 0, AnyOffset).
+		YieldMessage(&sarama.ConsumerMessage{Value: []byte("hello")})
+	pc1 := consumer.ExpectConsumePartition("test", 1, AnyOffset)
+
+	mc, err := consumer.ConsumeTopics([]string{"test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := <-mc.Messages(); msg.Partition != 0 || string(msg.Value) != "hello" {
+		t.Error("Unexpected message:", msg)
+	}
+
+	// the partition added to the topic is consumed as well
+	consumer.SetTopicMetadata(map[string][]int32{"test": {0, 1}})
+	pc1.YieldMessage(&sarama.ConsumerMessage{Value: []byte("world")})
+	if msg := <-mc.Messages(); msg.Partition != 1 || string(msg.Value) != "world" {
+		t.Error("Unexpected message:", msg)
+	}
+
+	if err := mc.Close(); err != nil {
+		t.Error(err)
+	}
+	if err := consumer.Close(); err != nil {
+		t.Error(err)
+	}
+	if len(trm.errors) != 0 {
+		t.Errorf("Expected no expectation failures to be set on the error reporter, found %v", trm.errors)
+	}
+}
+
 func TestConsumerOffsetsAreManagedCorrectlyWithOffsetOldest(t *testing.T) {
 	trm := newTestReporterMock()
 	consumer := NewConsumer(trm, NewTestConfig())
@@ -404,6 +534,67 @@ func TestConsumerOffsetsAreManagedCorrectlyWithSpecifiedOffset(t *testing.T) {
 	}
 }
 
+func TestConsumerTopicHighWaterMarkAndLagTotal(t *testing.T) {
+	trm := newTestReporterMock()
+	consumer := NewConsumer(trm, NewTestConfig())
+	consumer.ExpectConsumePartition("test", 0, 10).YieldMessage(&sarama.ConsumerMessage{Value: []byte("hello")})
+	consumer.ExpectConsumePartition("test", 1, 20)
+	consumer.ExpectConsumePartition("test", 2, 1000)
+
+	pc0, err := consumer.ConsumePartition("test", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := consumer.ConsumePartition("test", 1, 20); err != nil {
+		t.Fatal(err)
+	}
+	<-pc0.Messages()
+
+	// partition 2 is not consumed and is excluded
+	if hwm := consumer.TopicHighWaterMark("test"); hwm != 31 {
+		t.Errorf("Expected high water mark 31, found %d", hwm)
+	}
+
+	pc0.Pause()
+	pc0.(*PartitionConsumer).YieldMessage(&sarama.ConsumerMessage{Value: []byte("hello")})
+	if lag := consumer.LagTotal("test"); lag != 1 {
+		t.Errorf("Expected lag 1, found %d", lag)
+	}
+	pc0.Resume()
+	<-pc0.Messages()
+
+	if err := consumer.Close(); err != nil {
+		t.Error(err)
+	}
+	if len(trm.errors) != 1 {
+		t.Errorf("Expected an expectation failure for the unconsumed partition, found: %v", trm.errors)
+	}
+}
+
+func TestConsumerYieldEOF(t *testing.T) {
+	trm := newTestReporterMock()
+	consumer := NewConsumer(trm, NewTestConfig())
+	consumer.ExpectConsumePartition("test", 0, sarama.OffsetOldest).YieldEOF(42)
+
+	pc, err := consumer.ConsumePartition("test", 0, sarama.OffsetOldest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eof := <-pc.EOFs(); eof != 42 {
+		t.Errorf("Expected EOF at offset 42, got %d", eof)
+	}
+
+	if err := consumer.Close(); err != nil {
+		t.Error(err)
+	}
+	if _, ok := <-pc.EOFs(); ok {
+		t.Error("Expected the EOFs channel to be closed")
+	}
+	if len(trm.errors) != 0 {
+		t.Errorf("Expected to not report any errors, found: %v", trm.errors)
+	}
+}
+
 func TestConsumerInvalidConfiguration(t *testing.T) {
 	trm := newTestReporterMock()
 	config := NewTestConfig()
@@ -3,6 +3,7 @@
 package mocks
 
 import (
+	"context"
 	"errors"
 	"strings"
 	"testing"
@@ -57,6 +58,28 @@ func TestSyncProducerReturnsExpectationsToSendMessage(t *testing.T) {
 	}
 }
 
+func TestSyncProducerSendMessageContextCancelled(t *testing.T) {
+	sp := NewSyncProducer(t, nil)
+	sp.ExpectSendMessageAndSucceed()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	msg := &sarama.ProducerMessage{Topic: "test", Value: sarama.StringEncoder("test")}
+	if _, _, err := sp.SendMessageContext(ctx, msg); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	// the expectation is still available to an uncancelled call
+	if _, _, err := sp.SendMessageContext(context.Background(), msg); err != nil {
+		t.Errorf("The message should have been produced successfully, but got %s", err)
+	}
+
+	if err := sp.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
 func TestSyncProducerFailTxn(t *testing.T) {
 	config := NewTestConfig()
 	config.Producer.Transaction.ID = "test"
@@ -0,0 +1,243 @@
+package sarama
+
+import "sync"
+
+// MultiConsumer consumes all the partitions of a set of topics, with a
+// PartitionConsumer each, and merges their messages and errors into a single
+// pair of channels. Partitions added to the topics are consumed as they are
+// discovered, see Consumer.WatchPartitions. Unlike a ConsumerGroup, it is not
+// coordinated with other consumers and does not commit offsets, but it keeps
+// track of the offsets consumed so that they can be stored by the application.
+//
+// As with the PartitionConsumers it is made of, you MUST call Close or
+// AsyncClose on a MultiConsumer, before closing the underlying Consumer.
+type MultiConsumer interface {
+	// Messages returns the read channel for the messages of all the
+	// partitions. The messages of a partition are delivered in order, those of
+	// different partitions are interleaved. The channel is closed once the
+	// MultiConsumer is closed.
+	Messages() <-chan *ConsumerMessage
+
+	// Errors returns the read channel for the errors of all the partitions,
+	// which are only sent if Consumer.Return.Errors is set, along with the
+	// failures to consume the partitions added to the topics. The channel is
+	// closed once the MultiConsumer is closed.
+	Errors() <-chan *ConsumerError
+
+	// Offsets returns, for each topic and partition, the offset following the
+	// last message received from Messages, i.e. the offset to resume consuming
+	// from. Partitions of which no message was received yet are left out. The
+	// offsets are recorded once the messages are handed over, so they may lag
+	// behind a message just received, but never run ahead of the messages.
+	Offsets() map[string]map[int32]int64
+
+	// AsyncClose initiates a shutdown of the MultiConsumer and of all its
+	// PartitionConsumers. It returns immediately, and the Messages and Errors
+	// channels are closed once the shutdown is complete.
+	AsyncClose()
+
+	// Close stops the MultiConsumer and all its PartitionConsumers, and waits
+	// for the shutdown to complete. The messages and errors not read yet are
+	// discarded.
+	Close() error
+}
+
+type multiConsumer struct {
+	consumer Consumer
+	offset   int64
+	messages chan *ConsumerMessage
+	errors   chan *ConsumerError
+
+	// lock guards children, offsets and watches
+	lock     sync.Mutex
+	children map[topicPartition]PartitionConsumer
+	offsets  map[string]map[int32]int64
+	watches  []<-chan []int32
+
+	dying     chan none
+	workers   sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// ConsumeTopics creates a MultiConsumer consuming all the partitions of the
+// given topics from Consumer.Offsets.Initial on.
+func (c *consumer) ConsumeTopics(topics []string) (MultiConsumer, error) {
+	return NewMultiConsumer(c, topics, c.conf.Consumer.Offsets.Initial)
+}
+
+// NewMultiConsumer creates a MultiConsumer consuming all the partitions of the
+// given topics with consumer, starting at offset, which can be OffsetNewest or
+// OffsetOldest. Consumer.ConsumeTopics is the usual way to create one; this
+// function also accepts other Consumer implementations, such as mocks.
+func NewMultiConsumer(consumer Consumer, topics []string, offset int64) (MultiConsumer, error) {
+	m := &multiConsumer{
+		consumer: consumer,
+		offset:   offset,
+		messages: make(chan *ConsumerMessage),
+		errors:   make(chan *ConsumerError),
+		children: make(map[topicPartition]PartitionConsumer),
+		offsets:  make(map[string]map[int32]int64),
+		dying:    make(chan none),
+	}
+
+	for _, topic := range topics {
+		watch, err := consumer.WatchPartitions(topic)
+		if err != nil {
+			_ = m.Close()
+			return nil, err
+		}
+		m.lock.Lock()
+		m.watches = append(m.watches, watch)
+		m.lock.Unlock()
+		// the current partitions are sent right away
+		for _, partition := range <-watch {
+			if err := m.consumePartition(topic, partition); err != nil {
+				_ = m.Close()
+				return nil, err
+			}
+		}
+		m.workers.Add(1)
+		go withRecover(func() {
+			defer m.workers.Done()
+			m.watch(topic, watch)
+		})
+	}
+	return m, nil
+}
+
+// watch consumes the partitions added to topic, as sent on watch, until the
+// MultiConsumer is closed.
+func (m *multiConsumer) watch(topic string, watch <-chan []int32) {
+	for {
+		var partitions []int32
+		select {
+		case p, ok := <-watch:
+			if !ok {
+				// the consumer was closed
+				return
+			}
+			partitions = p
+		case <-m.dying:
+			return
+		}
+
+		for _, partition := range partitions {
+			err := m.consumePartition(topic, partition)
+			if err == nil {
+				continue
+			}
+			Logger.Printf("consumer/%s/%d failed to consume new partition: %s\n", topic, partition, err)
+			select {
+			case m.errors <- &ConsumerError{Topic: topic, Partition: partition, Err: err}:
+			case <-m.dying:
+				return
+			}
+		}
+	}
+}
+
+// consumePartition starts consuming partition unless it is already consumed,
+// and forwards its messages and errors.
+func (m *multiConsumer) consumePartition(topic string, partition int32) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	select {
+	case <-m.dying:
+		return ErrClosedConsumer
+	default:
+	}
+	tp := topicPartition{topic, partition}
+	if _, ok := m.children[tp]; ok {
+		return nil
+	}
+
+	child, err := m.consumer.ConsumePartition(topic, partition, m.offset)
+	if err != nil {
+		return err
+	}
+	m.children[tp] = child
+
+	m.workers.Add(2)
+	go withRecover(func() {
+		defer m.workers.Done()
+		for msg := range child.Messages() {
+			select {
+			case m.messages <- msg:
+				m.markConsumed(msg)
+			case <-m.dying:
+				// drain the messages until the child is closed
+			}
+		}
+	})
+	go withRecover(func() {
+		defer m.workers.Done()
+		for err := range child.Errors() {
+			select {
+			case m.errors <- err:
+			case <-m.dying:
+			}
+		}
+	})
+	return nil
+}
+
+func (m *multiConsumer) markConsumed(msg *ConsumerMessage) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	partitions := m.offsets[msg.Topic]
+	if partitions == nil {
+		partitions = make(map[int32]int64)
+		m.offsets[msg.Topic] = partitions
+	}
+	partitions[msg.Partition] = msg.Offset + 1
+}
+
+func (m *multiConsumer) Messages() <-chan *ConsumerMessage {
+	return m.messages
+}
+
+func (m *multiConsumer) Errors() <-chan *ConsumerError {
+	return m.errors
+}
+
+func (m *multiConsumer) Offsets() map[string]map[int32]int64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	offsets := make(map[string]map[int32]int64, len(m.offsets))
+	for topic, partitions := range m.offsets {
+		offsets[topic] = make(map[int32]int64, len(partitions))
+		for partition, offset := range partitions {
+			offsets[topic][partition] = offset
+		}
+	}
+	return offsets
+}
+
+func (m *multiConsumer) AsyncClose() {
+	go withRecover(func() {
+		_ = m.Close()
+	})
+}
+
+func (m *multiConsumer) Close() error {
+	m.closeOnce.Do(func() {
+		close(m.dying)
+
+		m.lock.Lock()
+		for _, watch := range m.watches {
+			m.consumer.UnwatchPartitions(watch)
+		}
+		for _, child := range m.children {
+			child.AsyncClose()
+		}
+		m.lock.Unlock()
+
+		m.workers.Wait()
+		close(m.messages)
+		close(m.errors)
+	})
+	return nil
+}
@@ -0,0 +1,120 @@
+//go:build !functional
+
+package sarama
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiConsumer(t *testing.T) {
+	broker0 := NewMockBroker(t, 0)
+	defer broker0.Close()
+
+	var partitionCount atomic.Int32
+	partitionCount.Store(2)
+	offsets := NewMockOffsetResponse(t)
+	fetch := NewMockFetchResponse(t, 1)
+	for p := int32(0); p < 3; p++ {
+		offsets.SetOffset("my_topic", p, OffsetOldest, 0).SetOffset("my_topic", p, OffsetNewest, 1)
+		fetch.SetMessage("my_topic", p, 0, StringEncoder(strconv.Itoa(int(p))))
+	}
+	broker0.SetHandlerFuncByMap(map[string]requestHandlerFunc{
+		"MetadataRequest": func(req *request) encoderWithHeader {
+			metadata := NewMockMetadataResponse(t).SetBroker(broker0.Addr(), broker0.BrokerID())
+			for p := int32(0); p < partitionCount.Load(); p++ {
+				metadata.SetLeader("my_topic", p, broker0.BrokerID())
+			}
+			return metadata.For(req.body)
+		},
+		"OffsetRequest": func(req *request) encoderWithHeader { return offsets.For(req.body) },
+		"FetchRequest":  func(req *request) encoderWithHeader { return fetch.For(req.body) },
+	})
+
+	config := NewTestConfig()
+	config.Consumer.Offsets.Initial = OffsetOldest
+	config.Metadata.RefreshFrequency = 10 * time.Millisecond
+	master, err := NewConsumer([]string{broker0.Addr()}, config)
+	require.NoError(t, err)
+	defer safeClose(t, master)
+
+	consumer, err := master.ConsumeTopics([]string{"my_topic"})
+	require.NoError(t, err)
+
+	receive := func() *ConsumerMessage {
+		t.Helper()
+		select {
+		case msg := <-consumer.Messages():
+			require.Equal(t, StringEncoder(strconv.Itoa(int(msg.Partition))), StringEncoder(msg.Value))
+			return msg
+		case err := <-consumer.Errors():
+			t.Fatal(err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a message")
+		}
+		return nil
+	}
+
+	received := map[int32]bool{receive().Partition: true, receive().Partition: true}
+	require.Equal(t, map[int32]bool{0: true, 1: true}, received)
+	// the offsets are recorded right after the messages are received
+	require.Eventually(t, func() bool { return len(consumer.Offsets()["my_topic"]) == 2 }, 5*time.Second, time.Millisecond)
+	require.Equal(t, map[string]map[int32]int64{"my_topic": {0: 1, 1: 1}}, consumer.Offsets())
+
+	// the partition added to the topic is consumed as well
+	partitionCount.Store(3)
+	require.Equal(t, int32(2), receive().Partition)
+	require.Eventually(t, func() bool { return len(consumer.Offsets()["my_topic"]) == 3 }, 5*time.Second, time.Millisecond)
+	require.Equal(t, map[string]map[int32]int64{"my_topic": {0: 1, 1: 1, 2: 1}}, consumer.Offsets())
+
+	_, err = master.ConsumePartition("my_topic", 2, OffsetOldest)
+	require.Error(t, err, "expected the partition to be consumed already")
+
+	require.NoError(t, consumer.Close())
+	_, ok := <-consumer.Messages()
+	require.False(t, ok, "expected the messages channel to be closed")
+	_, ok = <-consumer.Errors()
+	require.False(t, ok, "expected the errors channel to be closed")
+}
+
+func TestMultiConsumerCloseStopsWatchers(t *testing.T) {
+	broker0 := NewMockBroker(t, 0)
+	defer broker0.Close()
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetNewest, 0).
+			SetOffset("my_topic", 0, OffsetOldest, 0),
+		"FetchRequest": NewMockFetchResponse(t, 1),
+	})
+
+	config := NewTestConfig()
+	config.Metadata.RefreshFrequency = 10 * time.Millisecond
+	master, err := NewConsumer([]string{broker0.Addr()}, config)
+	require.NoError(t, err)
+	defer safeClose(t, master)
+
+	for i := 0; i < 3; i++ {
+		consumer, err := master.ConsumeTopics([]string{"my_topic"})
+		require.NoError(t, err)
+		require.NoError(t, consumer.Close())
+	}
+
+	// the partition watchers stopped although the consumer is still open
+	stopped := make(chan none)
+	go func() {
+		master.(*consumer).watchers.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the partition watchers to stop")
+	}
+}
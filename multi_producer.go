@@ -0,0 +1,187 @@
+package sarama
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// QuorumPolicy defines how many of the clusters of a MultiProducer must
+// acknowledge a message for the send to be considered successful.
+type QuorumPolicy int8
+
+const (
+	// QuorumAll requires every cluster to acknowledge the message.
+	QuorumAll QuorumPolicy = iota
+	// QuorumMajority requires more than half of the clusters to acknowledge the message.
+	QuorumMajority
+	// QuorumAny requires at least one cluster to acknowledge the message.
+	QuorumAny
+)
+
+func (q QuorumPolicy) String() string {
+	switch q {
+	case QuorumAll:
+		return "All"
+	case QuorumMajority:
+		return "Majority"
+	case QuorumAny:
+		return "Any"
+	default:
+		return fmt.Sprintf("QuorumPolicy(%d)", int8(q))
+	}
+}
+
+// required returns the number of acknowledgements needed out of n clusters.
+func (q QuorumPolicy) required(n int) int {
+	switch q {
+	case QuorumMajority:
+		return n/2 + 1
+	case QuorumAny:
+		return 1
+	default:
+		return n
+	}
+}
+
+// ClusterResult is the outcome of a MultiProducer send on one cluster. As each
+// cluster assigns its own partition and offset to the message, they are
+// reported per cluster and may differ from one cluster to another.
+type ClusterResult struct {
+	Cluster   int // index of the cluster's producer as passed to NewMultiProducer
+	Partition int32
+	Offset    int64
+	// Err is nil if the cluster acknowledged the message. It is the context
+	// error if the send was abandoned because of the timeout or because the
+	// outcome was already decided; such a message may still be delivered.
+	Err error
+}
+
+// MultiProducerError is returned by a MultiProducer when the quorum was not
+// reached. It holds the result of every cluster.
+type MultiProducerError struct {
+	Policy  QuorumPolicy
+	Results []ClusterResult
+}
+
+func (e *MultiProducerError) Error() string {
+	var failures []string
+	for _, res := range e.Results {
+		if res.Err != nil {
+			failures = append(failures, fmt.Sprintf("cluster %d: %s", res.Cluster, res.Err))
+		}
+	}
+	return fmt.Sprintf("kafka: quorum %s not reached, %d/%d clusters failed (%s)",
+		e.Policy, len(failures), len(e.Results), strings.Join(failures, ", "))
+}
+
+// Unwrap returns the errors of the clusters that failed.
+func (e *MultiProducerError) Unwrap() []error {
+	var errs []error
+	for _, res := range e.Results {
+		if res.Err != nil {
+			errs = append(errs, res.Err)
+		}
+	}
+	return errs
+}
+
+// MultiProducer publishes every message to several clusters through one
+// SyncProducer per cluster, and considers a send successful once its
+// QuorumPolicy is satisfied.
+type MultiProducer interface {
+	// SendMessage produces a copy of the message on every cluster and returns
+	// once the quorum is reached, can no longer be reached, or the timeout
+	// expires. The message itself is left untouched: the partition and offset
+	// assigned by each cluster are in the returned results, indexed by cluster.
+	// If the quorum is not reached the error is a *MultiProducerError.
+	SendMessage(msg *ProducerMessage) ([]ClusterResult, error)
+
+	// SendMessageContext behaves like SendMessage, but also stops waiting once
+	// ctx is done.
+	SendMessageContext(ctx context.Context, msg *ProducerMessage) ([]ClusterResult, error)
+
+	// Close shuts down the producers of every cluster.
+	Close() error
+}
+
+type multiProducer struct {
+	producers []SyncProducer
+	policy    QuorumPolicy
+	timeout   time.Duration
+}
+
+// NewMultiProducer creates a new MultiProducer sending to the given producers,
+// one per cluster, according to policy. A non-zero timeout bounds how long a
+// send waits for the clusters, so that a slow cluster can not block it
+// indefinitely. The MultiProducer takes ownership of the producers and closes
+// them on Close.
+func NewMultiProducer(policy QuorumPolicy, timeout time.Duration, producers ...SyncProducer) (MultiProducer, error) {
+	switch {
+	case len(producers) == 0:
+		return nil, ConfigurationError("MultiProducer requires at least one producer")
+	case policy < QuorumAll || policy > QuorumAny:
+		return nil, ConfigurationError(fmt.Sprintf("invalid QuorumPolicy %d", policy))
+	case timeout < 0:
+		return nil, ConfigurationError("MultiProducer timeout must be >= 0")
+	}
+	return &multiProducer{producers: producers, policy: policy, timeout: timeout}, nil
+}
+
+func (mp *multiProducer) SendMessage(msg *ProducerMessage) ([]ClusterResult, error) {
+	return mp.SendMessageContext(context.Background(), msg)
+}
+
+func (mp *multiProducer) SendMessageContext(ctx context.Context, msg *ProducerMessage) ([]ClusterResult, error) {
+	var cancel context.CancelFunc
+	if mp.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, mp.timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	done := make(chan ClusterResult, len(mp.producers))
+	for i, producer := range mp.producers {
+		// each cluster gets its own copy as the producer writes to the message
+		m := *msg
+		go func() {
+			partition, offset, err := producer.SendMessageContext(ctx, &m)
+			done <- ClusterResult{Cluster: i, Partition: partition, Offset: offset, Err: err}
+		}()
+	}
+
+	required := mp.policy.required(len(mp.producers))
+	results := make([]ClusterResult, len(mp.producers))
+	successes, failures := 0, 0
+	for range mp.producers {
+		res := <-done
+		results[res.Cluster] = res
+		if res.Err == nil {
+			successes++
+		} else {
+			failures++
+		}
+		if successes >= required || failures > len(mp.producers)-required {
+			// the outcome is decided, stop waiting for the remaining clusters
+			cancel()
+		}
+	}
+
+	if successes < required {
+		return results, &MultiProducerError{Policy: mp.policy, Results: results}
+	}
+	return results, nil
+}
+
+func (mp *multiProducer) Close() error {
+	var errs []error
+	for _, producer := range mp.producers {
+		if err := producer.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
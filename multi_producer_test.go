@@ -0,0 +1,180 @@
+//go:build !functional
+
+package sarama
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// stubClusterProducer is a SyncProducer failing right away with err, or
+// acknowledging messages at a fixed partition and offset after delay.
+type stubClusterProducer struct {
+	SyncProducer
+	partition int32
+	offset    int64
+	delay     time.Duration
+	err       error
+	closeErr  error
+	sent      chan *ProducerMessage
+	closed    bool
+}
+
+func (p *stubClusterProducer) SendMessageContext(ctx context.Context, msg *ProducerMessage) (int32, int64, error) {
+	msg.Partition, msg.Offset = p.partition, p.offset
+	if p.sent != nil {
+		p.sent <- msg
+	}
+	if p.err != nil {
+		return -1, -1, p.err
+	}
+	select {
+	case <-time.After(p.delay):
+	case <-ctx.Done():
+		return -1, -1, ctx.Err()
+	}
+	return p.partition, p.offset, nil
+}
+
+func (p *stubClusterProducer) Close() error {
+	p.closed = true
+	return p.closeErr
+}
+
+func TestNewMultiProducerValidation(t *testing.T) {
+	producer := &stubClusterProducer{}
+
+	_, err := NewMultiProducer(QuorumAll, 0)
+	require.ErrorAs(t, err, new(ConfigurationError))
+	_, err = NewMultiProducer(QuorumPolicy(42), 0, producer)
+	require.ErrorAs(t, err, new(ConfigurationError))
+	_, err = NewMultiProducer(QuorumAll, -time.Second, producer)
+	require.ErrorAs(t, err, new(ConfigurationError))
+	_, err = NewMultiProducer(QuorumMajority, time.Second, producer)
+	require.NoError(t, err)
+}
+
+func TestMultiProducerQuorum(t *testing.T) {
+	failure := errors.New("cluster unavailable")
+	ok := func(partition int32, offset int64) *stubClusterProducer {
+		return &stubClusterProducer{partition: partition, offset: offset}
+	}
+	failed := func() *stubClusterProducer { return &stubClusterProducer{err: failure} }
+
+	for _, d := range []struct {
+		name      string
+		policy    QuorumPolicy
+		producers []*stubClusterProducer
+		success   bool
+	}{
+		{"all succeeded", QuorumAll, []*stubClusterProducer{ok(0, 10), ok(2, 20), ok(1, 30)}, true},
+		{"all with one failure", QuorumAll, []*stubClusterProducer{ok(0, 10), failed(), ok(1, 30)}, false},
+		{"majority with one failure", QuorumMajority, []*stubClusterProducer{ok(0, 10), failed(), ok(1, 30)}, true},
+		{"majority with two failures", QuorumMajority, []*stubClusterProducer{ok(0, 10), failed(), failed()}, false},
+		{"majority of two with one failure", QuorumMajority, []*stubClusterProducer{ok(0, 10), failed()}, false},
+		{"any with one success", QuorumAny, []*stubClusterProducer{failed(), failed(), ok(1, 30)}, true},
+		{"any without success", QuorumAny, []*stubClusterProducer{failed(), failed()}, false},
+	} {
+		t.Run(d.name, func(t *testing.T) {
+			producers := make([]SyncProducer, len(d.producers))
+			for i, p := range d.producers {
+				producers[i] = p
+			}
+			mp, err := NewMultiProducer(d.policy, 0, producers...)
+			require.NoError(t, err)
+
+			msg := &ProducerMessage{Topic: "my_topic", Value: StringEncoder("hello"), Partition: -1, Offset: -1}
+			results, err := mp.SendMessage(msg)
+			require.Len(t, results, len(d.producers))
+			for i, res := range results {
+				require.Equal(t, i, res.Cluster)
+				if d.producers[i].err != nil {
+					require.ErrorIs(t, res.Err, failure)
+					continue
+				}
+				if res.Err == nil {
+					require.Equal(t, d.producers[i].partition, res.Partition)
+					require.Equal(t, d.producers[i].offset, res.Offset)
+				}
+			}
+			// each cluster writes to its own copy of the message
+			require.Equal(t, int32(-1), msg.Partition)
+			require.Equal(t, int64(-1), msg.Offset)
+
+			if d.success {
+				require.NoError(t, err)
+				return
+			}
+			var mpErr *MultiProducerError
+			require.ErrorAs(t, err, &mpErr)
+			require.Equal(t, d.policy, mpErr.Policy)
+			require.Equal(t, results, mpErr.Results)
+			require.ErrorIs(t, err, failure)
+		})
+	}
+}
+
+func TestMultiProducerTimeout(t *testing.T) {
+	fast := &stubClusterProducer{partition: 1, offset: 5}
+	slow := &stubClusterProducer{delay: time.Hour}
+
+	mp, err := NewMultiProducer(QuorumAll, 50*time.Millisecond, fast, slow)
+	require.NoError(t, err)
+
+	start := time.Now()
+	results, err := mp.SendMessage(&ProducerMessage{Topic: "my_topic"})
+	require.Less(t, time.Since(start), 10*time.Second)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.NoError(t, results[0].Err)
+	require.ErrorIs(t, results[1].Err, context.DeadlineExceeded)
+}
+
+func TestMultiProducerStopsWaitingOnceDecided(t *testing.T) {
+	fast := &stubClusterProducer{partition: 1, offset: 5}
+	slow := &stubClusterProducer{delay: time.Hour}
+
+	mp, err := NewMultiProducer(QuorumAny, 0, fast, slow)
+	require.NoError(t, err)
+
+	results, err := mp.SendMessage(&ProducerMessage{Topic: "my_topic"})
+	require.NoError(t, err)
+	require.NoError(t, results[0].Err)
+	require.ErrorIs(t, results[1].Err, context.Canceled)
+}
+
+func TestMultiProducerSendsACopyPerCluster(t *testing.T) {
+	first := &stubClusterProducer{sent: make(chan *ProducerMessage, 1)}
+	second := &stubClusterProducer{sent: make(chan *ProducerMessage, 1)}
+
+	mp, err := NewMultiProducer(QuorumAll, 0, first, second)
+	require.NoError(t, err)
+
+	msg := &ProducerMessage{Topic: "my_topic", Key: StringEncoder("key"), Value: StringEncoder("value")}
+	_, err = mp.SendMessage(msg)
+	require.NoError(t, err)
+
+	firstMsg, secondMsg := <-first.sent, <-second.sent
+	require.NotSame(t, msg, firstMsg)
+	require.NotSame(t, firstMsg, secondMsg)
+	for _, m := range []*ProducerMessage{firstMsg, secondMsg} {
+		require.Equal(t, msg.Topic, m.Topic)
+		require.Equal(t, msg.Key, m.Key)
+		require.Equal(t, msg.Value, m.Value)
+	}
+}
+
+func TestMultiProducerClose(t *testing.T) {
+	closeErr := errors.New("close failed")
+	first := &stubClusterProducer{closeErr: closeErr}
+	second := &stubClusterProducer{}
+
+	mp, err := NewMultiProducer(QuorumAll, 0, first, second)
+	require.NoError(t, err)
+	require.ErrorIs(t, mp.Close(), closeErr)
+	require.True(t, first.closed)
+	require.True(t, second.closed)
+}
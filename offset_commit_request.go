@@ -75,6 +75,7 @@ type OffsetCommitRequest struct {
 	// - 5&6 (kafka 2.1.0 and later)
 	// - 7 (kafka 2.3.0 and later)
 	// - 8 (kafka 2.4.0 and later, first flexible version)
+	// - 9 (kafka 3.7.0 and later, usable by KIP-848 consumer groups)
 	Version int16
 	blocks  map[string]map[int32]*offsetCommitRequestBlock
 }
@@ -93,7 +94,11 @@ func NewOffsetCommitRequest(conf *Config, group string) *OffsetCommitRequest {
 		ConsumerGroupGeneration: GroupGenerationUndefined,
 	}
 
-	if conf.Version.IsAtLeast(V2_4_0_0) {
+	if conf.Version.IsAtLeast(V3_7_0_0) {
+		// Version 9 is the first version that can be used with the new consumer
+		// group protocol (KIP-848), where the generation is the member epoch.
+		request.Version = 9
+	} else if conf.Version.IsAtLeast(V2_4_0_0) {
 		// Version 8 is the first flexible version.
 		request.Version = 8
 	} else if conf.Version.IsAtLeast(V2_3_0_0) {
@@ -127,7 +132,7 @@ func NewOffsetCommitRequest(conf *Config, group string) *OffsetCommitRequest {
 }
 
 func (r *OffsetCommitRequest) encode(pe packetEncoder) error {
-	if r.Version < 0 || r.Version > 8 {
+	if r.Version < 0 || r.Version > 9 {
 		return PacketEncodingError{"invalid or unsupported OffsetCommitRequest version field"}
 	}
 
@@ -266,7 +271,7 @@ func (r *OffsetCommitRequest) headerVersion() int16 {
 }
 
 func (r *OffsetCommitRequest) isValidVersion() bool {
-	return r.Version >= 0 && r.Version <= 8
+	return r.Version >= 0 && r.Version <= 9
 }
 
 func (r *OffsetCommitRequest) isFlexible() bool {
@@ -279,6 +284,8 @@ func (r *OffsetCommitRequest) isFlexibleVersion(version int16) bool {
 
 func (r *OffsetCommitRequest) requiredVersion() KafkaVersion {
 	switch r.Version {
+	case 9:
+		return V3_7_0_0
 	case 8:
 		return V2_4_0_0
 	case 7:
@@ -294,7 +301,7 @@ func (r *OffsetCommitRequest) requiredVersion() KafkaVersion {
 	case 0, 1:
 		return V0_8_2_0
 	default:
-		return V2_4_0_0
+		return V3_7_0_0
 	}
 }
 
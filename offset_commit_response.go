@@ -119,7 +119,7 @@ func (r *OffsetCommitResponse) headerVersion() int16 {
 }
 
 func (r *OffsetCommitResponse) isValidVersion() bool {
-	return r.Version >= 0 && r.Version <= 8
+	return r.Version >= 0 && r.Version <= 9
 }
 
 func (r *OffsetCommitResponse) isFlexible() bool {
@@ -132,6 +132,8 @@ func (r *OffsetCommitResponse) isFlexibleVersion(version int16) bool {
 
 func (r *OffsetCommitResponse) requiredVersion() KafkaVersion {
 	switch r.Version {
+	case 9:
+		return V3_7_0_0
 	case 8:
 		return V2_4_0_0
 	case 7:
@@ -147,7 +149,7 @@ func (r *OffsetCommitResponse) requiredVersion() KafkaVersion {
 	case 0, 1:
 		return V0_8_2_0
 	default:
-		return V2_4_0_0
+		return V3_7_0_0
 	}
 }
 
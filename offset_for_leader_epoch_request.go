@@ -0,0 +1,199 @@
+package sarama
+
+// OffsetForLeaderEpochRequest (API key 23) looks up the end offset of a
+// leader epoch on a set of partitions (KIP-101, KIP-320). Consumers use it to
+// detect log truncation after an unclean leader election.
+type OffsetForLeaderEpochRequest struct {
+	// Version defines the protocol version to use for encode and decode
+	Version int16
+	// ReplicaID contains the broker ID of the follower, or -1 if this request
+	// is from a consumer. Only sent from version 3.
+	ReplicaID int32
+	// Topics contains the topics and partitions to look up.
+	Topics []*OffsetForLeaderEpochRequestTopic
+}
+
+// OffsetForLeaderEpochRequestTopic contains the partitions of a topic to look up.
+type OffsetForLeaderEpochRequestTopic struct {
+	Name       string
+	Partitions []*OffsetForLeaderEpochRequestPartition
+}
+
+// OffsetForLeaderEpochRequestPartition contains the leader epoch to look up
+// on a single partition.
+type OffsetForLeaderEpochRequestPartition struct {
+	Partition int32
+	// CurrentLeaderEpoch is used by the broker to fence stale requests, -1
+	// disables the check. Only sent from version 2.
+	CurrentLeaderEpoch int32
+	// LeaderEpoch is the epoch to look up the end offset for.
+	LeaderEpoch int32
+}
+
+func NewOffsetForLeaderEpochRequest(version KafkaVersion) *OffsetForLeaderEpochRequest {
+	r := &OffsetForLeaderEpochRequest{ReplicaID: -1}
+	switch {
+	case version.IsAtLeast(V2_8_0_0):
+		r.Version = 4
+	case version.IsAtLeast(V2_3_0_0):
+		r.Version = 3
+	case version.IsAtLeast(V2_1_0_0):
+		r.Version = 2
+	case version.IsAtLeast(V2_0_0_0):
+		r.Version = 1
+	}
+	return r
+}
+
+// AddPartition adds a partition and the leader epoch to look up on it.
+func (r *OffsetForLeaderEpochRequest) AddPartition(topic string, partition, currentLeaderEpoch, leaderEpoch int32) {
+	block := &OffsetForLeaderEpochRequestPartition{
+		Partition:          partition,
+		CurrentLeaderEpoch: currentLeaderEpoch,
+		LeaderEpoch:        leaderEpoch,
+	}
+	for _, t := range r.Topics {
+		if t.Name == topic {
+			t.Partitions = append(t.Partitions, block)
+			return
+		}
+	}
+	r.Topics = append(r.Topics, &OffsetForLeaderEpochRequestTopic{
+		Name:       topic,
+		Partitions: []*OffsetForLeaderEpochRequestPartition{block},
+	})
+}
+
+func (r *OffsetForLeaderEpochRequest) setVersion(v int16) {
+	r.Version = v
+}
+
+func (r *OffsetForLeaderEpochRequest) encode(pe packetEncoder) error {
+	if r.Version >= 3 {
+		pe.putInt32(r.ReplicaID)
+	}
+
+	if err := pe.putArrayLength(len(r.Topics)); err != nil {
+		return err
+	}
+	for _, topic := range r.Topics {
+		if err := pe.putString(topic.Name); err != nil {
+			return err
+		}
+		if err := pe.putArrayLength(len(topic.Partitions)); err != nil {
+			return err
+		}
+		for _, partition := range topic.Partitions {
+			pe.putInt32(partition.Partition)
+			if r.Version >= 2 {
+				pe.putInt32(partition.CurrentLeaderEpoch)
+			}
+			pe.putInt32(partition.LeaderEpoch)
+			pe.putEmptyTaggedFieldArray()
+		}
+		pe.putEmptyTaggedFieldArray()
+	}
+
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *OffsetForLeaderEpochRequest) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
+	r.ReplicaID = -1
+	if r.Version >= 3 {
+		if r.ReplicaID, err = pd.getInt32(); err != nil {
+			return err
+		}
+	}
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	if n == -1 {
+		n = 0
+	}
+	r.Topics = make([]*OffsetForLeaderEpochRequestTopic, n)
+	for i := 0; i < n; i++ {
+		topic := &OffsetForLeaderEpochRequestTopic{}
+		if topic.Name, err = pd.getString(); err != nil {
+			return err
+		}
+		m, err := pd.getArrayLength()
+		if err != nil {
+			return err
+		}
+		if m == -1 {
+			m = 0
+		}
+		topic.Partitions = make([]*OffsetForLeaderEpochRequestPartition, m)
+		for j := 0; j < m; j++ {
+			partition := &OffsetForLeaderEpochRequestPartition{CurrentLeaderEpoch: -1}
+			if partition.Partition, err = pd.getInt32(); err != nil {
+				return err
+			}
+			if r.Version >= 2 {
+				if partition.CurrentLeaderEpoch, err = pd.getInt32(); err != nil {
+					return err
+				}
+			}
+			if partition.LeaderEpoch, err = pd.getInt32(); err != nil {
+				return err
+			}
+			if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+				return err
+			}
+			topic.Partitions[j] = partition
+		}
+		if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+		r.Topics[i] = topic
+	}
+
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *OffsetForLeaderEpochRequest) key() int16 {
+	return apiKeyOffsetForLeaderEpoch
+}
+
+func (r *OffsetForLeaderEpochRequest) version() int16 {
+	return r.Version
+}
+
+func (r *OffsetForLeaderEpochRequest) headerVersion() int16 {
+	if r.Version >= 4 {
+		return 2
+	}
+	return 1
+}
+
+func (r *OffsetForLeaderEpochRequest) isValidVersion() bool {
+	return r.Version >= 0 && r.Version <= 4
+}
+
+func (r *OffsetForLeaderEpochRequest) isFlexible() bool {
+	return r.isFlexibleVersion(r.Version)
+}
+
+func (r *OffsetForLeaderEpochRequest) isFlexibleVersion(version int16) bool {
+	return version >= 4
+}
+
+func (r *OffsetForLeaderEpochRequest) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 4:
+		return V2_8_0_0
+	case 3:
+		return V2_3_0_0
+	case 2:
+		return V2_1_0_0
+	case 1:
+		return V2_0_0_0
+	default:
+		return V0_11_0_0
+	}
+}
@@ -0,0 +1,69 @@
+//go:build !functional
+
+package sarama
+
+import "testing"
+
+var (
+	offsetForLeaderEpochRequestV0 = []byte{
+		0, 0, 0, 1, // 1 topic
+		0, 3, 'f', 'o', 'o', // topic name
+		0, 0, 0, 1, // 1 partition
+		0, 0, 0, 2, // partition 2
+		0, 0, 0, 5, // leader epoch 5
+	}
+
+	offsetForLeaderEpochRequestV2 = []byte{
+		0, 0, 0, 1, // 1 topic
+		0, 3, 'f', 'o', 'o', // topic name
+		0, 0, 0, 1, // 1 partition
+		0, 0, 0, 2, // partition 2
+		0, 0, 0, 7, // current leader epoch 7
+		0, 0, 0, 5, // leader epoch 5
+	}
+
+	offsetForLeaderEpochRequestV3 = []byte{
+		255, 255, 255, 255, // replica ID -1
+		0, 0, 0, 1, // 1 topic
+		0, 3, 'f', 'o', 'o', // topic name
+		0, 0, 0, 1, // 1 partition
+		0, 0, 0, 2, // partition 2
+		0, 0, 0, 7, // current leader epoch 7
+		0, 0, 0, 5, // leader epoch 5
+	}
+
+	offsetForLeaderEpochRequestV4 = []byte{
+		255, 255, 255, 255, // replica ID -1
+		2,                // 1 topic
+		4, 'f', 'o', 'o', // topic name
+		2,          // 1 partition
+		0, 0, 0, 2, // partition 2
+		0, 0, 0, 7, // current leader epoch 7
+		0, 0, 0, 5, // leader epoch 5
+		0, // empty partition tagged fields
+		0, // empty topic tagged fields
+		0, // empty tagged fields
+	}
+)
+
+func TestOffsetForLeaderEpochRequest(t *testing.T) {
+	request := NewOffsetForLeaderEpochRequest(V0_11_0_0)
+	request.AddPartition("foo", 2, -1, 5)
+	testRequest(t, "v0", request, offsetForLeaderEpochRequestV0)
+
+	request = NewOffsetForLeaderEpochRequest(V2_0_0_0)
+	request.AddPartition("foo", 2, -1, 5)
+	testRequest(t, "v1", request, offsetForLeaderEpochRequestV0)
+
+	request = NewOffsetForLeaderEpochRequest(V2_1_0_0)
+	request.AddPartition("foo", 2, 7, 5)
+	testRequest(t, "v2", request, offsetForLeaderEpochRequestV2)
+
+	request = NewOffsetForLeaderEpochRequest(V2_3_0_0)
+	request.AddPartition("foo", 2, 7, 5)
+	testRequest(t, "v3", request, offsetForLeaderEpochRequestV3)
+
+	request = NewOffsetForLeaderEpochRequest(V2_8_0_0)
+	request.AddPartition("foo", 2, 7, 5)
+	testRequest(t, "v4", request, offsetForLeaderEpochRequestV4)
+}
@@ -0,0 +1,208 @@
+package sarama
+
+import "time"
+
+// OffsetForLeaderEpochResponse is the response to an OffsetForLeaderEpochRequest.
+type OffsetForLeaderEpochResponse struct {
+	// Version defines the protocol version to use for encode and decode
+	Version int16
+	// ThrottleTime contains the duration for which the request was throttled
+	// due to a quota violation. Only sent from version 2.
+	ThrottleTime time.Duration
+	Topics       []*OffsetForLeaderEpochResponseTopic
+}
+
+// OffsetForLeaderEpochResponseTopic contains the results for a single topic.
+type OffsetForLeaderEpochResponseTopic struct {
+	Name       string
+	Partitions []*OffsetForLeaderEpochResponsePartition
+}
+
+// OffsetForLeaderEpochResponsePartition contains the end offset of the
+// requested leader epoch on a single partition.
+type OffsetForLeaderEpochResponsePartition struct {
+	Err       KError
+	Partition int32
+	// LeaderEpoch is the largest epoch not greater than the requested one,
+	// or -1 if unknown. Only sent from version 1.
+	LeaderEpoch int32
+	// EndOffset is the end offset of LeaderEpoch, or -1 if unknown.
+	EndOffset int64
+}
+
+// GetBlock returns the result for the given topic and partition, or nil if
+// the response does not contain it.
+func (r *OffsetForLeaderEpochResponse) GetBlock(topic string, partition int32) *OffsetForLeaderEpochResponsePartition {
+	for _, t := range r.Topics {
+		if t.Name != topic {
+			continue
+		}
+		for _, p := range t.Partitions {
+			if p.Partition == partition {
+				return p
+			}
+		}
+	}
+	return nil
+}
+
+// AddBlock adds the result for the given topic and partition.
+func (r *OffsetForLeaderEpochResponse) AddBlock(topic string, partition int32, err KError, leaderEpoch int32, endOffset int64) {
+	block := &OffsetForLeaderEpochResponsePartition{
+		Err:         err,
+		Partition:   partition,
+		LeaderEpoch: leaderEpoch,
+		EndOffset:   endOffset,
+	}
+	for _, t := range r.Topics {
+		if t.Name == topic {
+			t.Partitions = append(t.Partitions, block)
+			return
+		}
+	}
+	r.Topics = append(r.Topics, &OffsetForLeaderEpochResponseTopic{
+		Name:       topic,
+		Partitions: []*OffsetForLeaderEpochResponsePartition{block},
+	})
+}
+
+func (r *OffsetForLeaderEpochResponse) setVersion(v int16) {
+	r.Version = v
+}
+
+func (r *OffsetForLeaderEpochResponse) encode(pe packetEncoder) error {
+	if r.Version >= 2 {
+		pe.putDurationMs(r.ThrottleTime)
+	}
+
+	if err := pe.putArrayLength(len(r.Topics)); err != nil {
+		return err
+	}
+	for _, topic := range r.Topics {
+		if err := pe.putString(topic.Name); err != nil {
+			return err
+		}
+		if err := pe.putArrayLength(len(topic.Partitions)); err != nil {
+			return err
+		}
+		for _, partition := range topic.Partitions {
+			pe.putKError(partition.Err)
+			pe.putInt32(partition.Partition)
+			if r.Version >= 1 {
+				pe.putInt32(partition.LeaderEpoch)
+			}
+			pe.putInt64(partition.EndOffset)
+			pe.putEmptyTaggedFieldArray()
+		}
+		pe.putEmptyTaggedFieldArray()
+	}
+
+	pe.putEmptyTaggedFieldArray()
+	return nil
+}
+
+func (r *OffsetForLeaderEpochResponse) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
+	if r.Version >= 2 {
+		if r.ThrottleTime, err = pd.getDurationMs(); err != nil {
+			return err
+		}
+	}
+
+	n, err := pd.getArrayLength()
+	if err != nil {
+		return err
+	}
+	if n == -1 {
+		n = 0
+	}
+	r.Topics = make([]*OffsetForLeaderEpochResponseTopic, n)
+	for i := 0; i < n; i++ {
+		topic := &OffsetForLeaderEpochResponseTopic{}
+		if topic.Name, err = pd.getString(); err != nil {
+			return err
+		}
+		m, err := pd.getArrayLength()
+		if err != nil {
+			return err
+		}
+		if m == -1 {
+			m = 0
+		}
+		topic.Partitions = make([]*OffsetForLeaderEpochResponsePartition, m)
+		for j := 0; j < m; j++ {
+			partition := &OffsetForLeaderEpochResponsePartition{LeaderEpoch: -1}
+			if partition.Err, err = pd.getKError(); err != nil {
+				return err
+			}
+			if partition.Partition, err = pd.getInt32(); err != nil {
+				return err
+			}
+			if r.Version >= 1 {
+				if partition.LeaderEpoch, err = pd.getInt32(); err != nil {
+					return err
+				}
+			}
+			if partition.EndOffset, err = pd.getInt64(); err != nil {
+				return err
+			}
+			if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+				return err
+			}
+			topic.Partitions[j] = partition
+		}
+		if _, err = pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
+		r.Topics[i] = topic
+	}
+
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
+}
+
+func (r *OffsetForLeaderEpochResponse) key() int16 {
+	return apiKeyOffsetForLeaderEpoch
+}
+
+func (r *OffsetForLeaderEpochResponse) version() int16 {
+	return r.Version
+}
+
+func (r *OffsetForLeaderEpochResponse) headerVersion() int16 {
+	if r.Version >= 4 {
+		return 1
+	}
+	return 0
+}
+
+func (r *OffsetForLeaderEpochResponse) isValidVersion() bool {
+	return r.Version >= 0 && r.Version <= 4
+}
+
+func (r *OffsetForLeaderEpochResponse) isFlexible() bool {
+	return r.isFlexibleVersion(r.Version)
+}
+
+func (r *OffsetForLeaderEpochResponse) isFlexibleVersion(version int16) bool {
+	return version >= 4
+}
+
+func (r *OffsetForLeaderEpochResponse) requiredVersion() KafkaVersion {
+	switch r.Version {
+	case 4:
+		return V2_8_0_0
+	case 3:
+		return V2_3_0_0
+	case 2:
+		return V2_1_0_0
+	case 1:
+		return V2_0_0_0
+	default:
+		return V0_11_0_0
+	}
+}
+
+func (r *OffsetForLeaderEpochResponse) throttleTime() time.Duration {
+	return r.ThrottleTime
+}
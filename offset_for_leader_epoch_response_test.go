@@ -0,0 +1,68 @@
+//go:build !functional
+
+package sarama
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+var (
+	offsetForLeaderEpochResponseV0 = []byte{
+		0, 0, 0, 1, // 1 topic
+		0, 3, 'f', 'o', 'o', // topic name
+		0, 0, 0, 1, // 1 partition
+		0, 0, // no error
+		0, 0, 0, 2, // partition 2
+		0, 0, 0, 0, 0, 0, 0, 42, // end offset 42
+	}
+
+	offsetForLeaderEpochResponseV2 = []byte{
+		0, 0, 0, 100, // throttle time 100ms
+		0, 0, 0, 1, // 1 topic
+		0, 3, 'f', 'o', 'o', // topic name
+		0, 0, 0, 1, // 1 partition
+		0, 0, // no error
+		0, 0, 0, 2, // partition 2
+		0, 0, 0, 4, // leader epoch 4
+		0, 0, 0, 0, 0, 0, 0, 42, // end offset 42
+	}
+
+	offsetForLeaderEpochResponseV4 = []byte{
+		0, 0, 0, 100, // throttle time 100ms
+		2,                // 1 topic
+		4, 'f', 'o', 'o', // topic name
+		2,    // 1 partition
+		0, 0, // no error
+		0, 0, 0, 2, // partition 2
+		0, 0, 0, 4, // leader epoch 4
+		0, 0, 0, 0, 0, 0, 0, 42, // end offset 42
+		0, // empty partition tagged fields
+		0, // empty topic tagged fields
+		0, // empty tagged fields
+	}
+)
+
+func TestOffsetForLeaderEpochResponse(t *testing.T) {
+	response := &OffsetForLeaderEpochResponse{Version: 0}
+	response.AddBlock("foo", 2, ErrNoError, -1, 42)
+	testResponse(t, "v0", response, offsetForLeaderEpochResponseV0)
+
+	for _, version := range []int16{2, 3} {
+		response = &OffsetForLeaderEpochResponse{Version: version, ThrottleTime: 100 * time.Millisecond}
+		response.AddBlock("foo", 2, ErrNoError, 4, 42)
+		testResponse(t, fmt.Sprintf("v%d", version), response, offsetForLeaderEpochResponseV2)
+	}
+
+	response = &OffsetForLeaderEpochResponse{Version: 4, ThrottleTime: 100 * time.Millisecond}
+	response.AddBlock("foo", 2, ErrNoError, 4, 42)
+	testResponse(t, "v4", response, offsetForLeaderEpochResponseV4)
+
+	if block := response.GetBlock("foo", 2); block == nil || block.EndOffset != 42 {
+		t.Errorf("Expected block for foo/2 with end offset 42, got %+v", block)
+	}
+	if block := response.GetBlock("foo", 3); block != nil {
+		t.Errorf("Expected no block for foo/3, got %+v", block)
+	}
+}
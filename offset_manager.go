@@ -1,8 +1,10 @@
 package sarama
 
 import (
+	"cmp"
 	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -22,10 +24,22 @@ type OffsetManager interface {
 	Close() error
 
 	// Commit commits the offsets. This method can be used if AutoCommit.Enable is
-	// set to false.
+	// set to false. Commits failing with a retriable error are retried up to
+	// Consumer.Offsets.Retry.Max times, but not those fenced by a rebalance
+	// (ErrIllegalGeneration, ErrUnknownMemberId). Automatic commits are only
+	// retried until the next one is due.
 	Commit()
 }
 
+// CommitErrorReporter is implemented by the OffsetManager returned by
+// NewOffsetManagerFromClient.
+type CommitErrorReporter interface {
+	// LastCommitError returns the error of the last commit, or nil if it
+	// succeeded. It allows applications to pause processing while commits are
+	// persistently failing.
+	LastCommitError() error
+}
+
 type offsetManager struct {
 	client          Client
 	conf            *Config
@@ -35,7 +49,7 @@ type offsetManager struct {
 
 	memberID        string
 	groupInstanceId *string
-	generation      int32
+	generation      atomic.Int32 // the member epoch when using the consumer group protocol
 
 	broker     *Broker
 	brokerLock sync.RWMutex
@@ -43,6 +57,9 @@ type offsetManager struct {
 	poms     map[string]map[int32]*partitionOffsetManager
 	pomsLock sync.RWMutex
 
+	lastCommitErr     error
+	lastCommitErrLock sync.Mutex
+
 	closeOnce sync.Once
 	closing   chan none
 	closed    chan none
@@ -68,12 +85,12 @@ func newOffsetManagerFromClient(group, memberID string, generation int32, client
 		poms:            make(map[string]map[int32]*partitionOffsetManager),
 		sessionCanceler: sessionCanceler,
 
-		memberID:   memberID,
-		generation: generation,
+		memberID: memberID,
 
 		closing: make(chan none),
 		closed:  make(chan none),
 	}
+	om.generation.Store(generation)
 	if conf.Consumer.Group.InstanceId != "" {
 		om.groupInstanceId = &conf.Consumer.Group.InstanceId
 	}
@@ -122,7 +139,7 @@ func (om *offsetManager) Close() error {
 		// flush one last time
 		if om.conf.Consumer.Offsets.AutoCommit.Enable {
 			for attempt := 0; attempt <= om.conf.Consumer.Offsets.Retry.Max; attempt++ {
-				om.flushToBroker()
+				_, _ = om.flushToBroker(false)
 				if om.releasePOMs(false) == 0 {
 					break
 				}
@@ -156,6 +173,7 @@ func (om *offsetManager) fetchInitialOffset(topic string, partition int32, retri
 
 	partitions := map[string][]int32{topic: {partition}}
 	req := NewOffsetFetchRequest(om.conf.Version, om.group, partitions)
+	req.RequireStable = om.conf.Consumer.Offsets.RequireStable
 	resp, err := broker.FetchOffset(req)
 	if err != nil {
 		if retries <= 0 {
@@ -183,7 +201,7 @@ func (om *offsetManager) fetchInitialOffset(topic string, partition int32, retri
 		}
 		om.releaseCoordinator(broker)
 		return om.fetchInitialOffset(topic, partition, retries-1)
-	case ErrOffsetsLoadInProgress:
+	case ErrOffsetsLoadInProgress, ErrUnstableOffsetCommit:
 		if retries <= 0 {
 			return 0, 0, "", block.Err
 		}
@@ -243,7 +261,9 @@ func (om *offsetManager) mainLoop() {
 	for {
 		select {
 		case <-om.ticker.C:
-			om.Commit()
+			// retrying past the next tick would only delay it
+			om.commit(time.Now().Add(om.conf.Consumer.Offsets.AutoCommit.Interval))
+			om.releasePOMs(false)
 		case <-om.closing:
 			return
 		}
@@ -251,15 +271,59 @@ func (om *offsetManager) mainLoop() {
 }
 
 func (om *offsetManager) Commit() {
-	om.flushToBroker()
+	om.commit(time.Time{})
 	om.releasePOMs(false)
 }
 
-func (om *offsetManager) flushToBroker() {
+func (om *offsetManager) LastCommitError() error {
+	om.lastCommitErrLock.Lock()
+	defer om.lastCommitErrLock.Unlock()
+	return om.lastCommitErr
+}
+
+// commit flushes the offsets to the coordinator, retrying up to
+// Consumer.Offsets.Retry.Max times when it fails with a retriable error, and
+// not past the deadline unless it is zero. The retries stop once the offset
+// manager is closing, as Close flushes the offsets one last time.
+func (om *offsetManager) commit(deadline time.Time) {
+	backoff := om.conf.Consumer.Offsets.Retry.Backoff
+	var err error
+attempts:
+	for attempt := 0; ; attempt++ {
+		retriable := attempt < om.conf.Consumer.Offsets.Retry.Max &&
+			(deadline.IsZero() || time.Until(deadline) > backoff)
+		var retry bool
+		retry, err = om.flushToBroker(retriable)
+		if !retry {
+			break
+		}
+		DebugLogger.Printf("client/offsets retrying commit of group %s after %dms: %s\n", om.group, backoff/time.Millisecond, err)
+		if backoff > 0 {
+			timer := time.NewTimer(backoff)
+			select {
+			case <-timer.C:
+			case <-om.closing:
+				timer.Stop()
+				break attempts
+			}
+		}
+	}
+
+	om.lastCommitErrLock.Lock()
+	om.lastCommitErr = err
+	om.lastCommitErrLock.Unlock()
+}
+
+// flushToBroker commits the dirty offsets and returns the first error met. If
+// retriable is true, the errors a retry may fix are not reported to the
+// partition offset managers, and retry tells whether one is needed.
+func (om *offsetManager) flushToBroker(retriable bool) (retry bool, err error) {
 	broker, err := om.coordinator()
 	if err != nil {
-		om.handleError(err)
-		return
+		if !retriable {
+			om.handleError(err)
+		}
+		return retriable, err
 	}
 
 	// Care needs to be taken to unlock this. Don't want to defer the unlock as this would
@@ -268,28 +332,25 @@ func (om *offsetManager) flushToBroker() {
 	req := om.constructRequest()
 	if req == nil {
 		broker.lock.Unlock()
-		return
+		return false, nil
 	}
 	resp, rp, err := sendOffsetCommit(broker, req)
 	broker.lock.Unlock()
 
-	if err != nil {
-		om.handleError(err)
-		om.releaseCoordinator(broker)
-		_ = broker.Close()
-		return
+	if err == nil {
+		err = handleResponsePromise(req, resp, rp, nil)
 	}
-
-	err = handleResponsePromise(req, resp, rp, nil)
 	if err != nil {
-		om.handleError(err)
+		if !retriable {
+			om.handleError(err)
+		}
 		om.releaseCoordinator(broker)
 		_ = broker.Close()
-		return
+		return retriable, err
 	}
 
 	broker.handleThrottledResponse(resp)
-	om.handleResponse(broker, req, resp)
+	return om.handleResponse(broker, req, resp, retriable)
 }
 
 func sendOffsetCommit(coordinator *Broker, req *OffsetCommitRequest) (*OffsetCommitResponse, *responsePromise, error) {
@@ -308,7 +369,7 @@ func (om *offsetManager) constructRequest() *OffsetCommitRequest {
 		Version:                 1,
 		ConsumerGroup:           om.group,
 		ConsumerID:              om.memberID,
-		ConsumerGroupGeneration: om.generation,
+		ConsumerGroupGeneration: om.generation.Load(),
 	}
 	// Version 1 adds timestamp and group membership information, as well as the commit timestamp.
 	//
@@ -338,6 +399,11 @@ func (om *offsetManager) constructRequest() *OffsetCommitRequest {
 	if om.conf.Version.IsAtLeast(V2_4_0_0) {
 		r.Version = 8
 	}
+	// Version 9 is the first version that can be used with the new consumer
+	// group protocol (KIP-848), where the generation is the member epoch.
+	if om.conf.Version.IsAtLeast(V3_7_0_0) {
+		r.Version = 9
+	}
 
 	// commit timestamp was only briefly supported in V1 where we set it to
 	// ReceiveTime (-1) to tell the broker to set it to the time when the commit
@@ -378,9 +444,11 @@ func (om *offsetManager) constructRequest() *OffsetCommitRequest {
 	return nil
 }
 
-func (om *offsetManager) handleResponse(broker *Broker, req *OffsetCommitRequest, resp *OffsetCommitResponse) {
+// handleResponse processes the result of a commit, see flushToBroker.
+func (om *offsetManager) handleResponse(broker *Broker, req *OffsetCommitRequest, resp *OffsetCommitResponse, retriable bool) (retry bool, commitErr error) {
 	// release coordinator after dropping pomsLock to avoid lock inversion (#3191)
 	shouldRelease := false
+	fenced := false
 
 	om.pomsLock.RLock()
 	for _, topicManagers := range om.poms {
@@ -394,12 +462,17 @@ func (om *offsetManager) handleResponse(broker *Broker, req *OffsetCommitRequest
 
 			if resp.Errors[pom.topic] == nil {
 				pom.handleError(ErrIncompleteResponse)
+				commitErr = cmp.Or(commitErr, error(ErrIncompleteResponse))
 				continue
 			}
 			if err, ok = resp.Errors[pom.topic][pom.partition]; !ok {
 				pom.handleError(ErrIncompleteResponse)
+				commitErr = cmp.Or(commitErr, error(ErrIncompleteResponse))
 				continue
 			}
+			if err != ErrNoError {
+				commitErr = cmp.Or(commitErr, error(err))
+			}
 
 			switch err {
 			case ErrNoError:
@@ -409,13 +482,31 @@ func (om *offsetManager) handleResponse(broker *Broker, req *OffsetCommitRequest
 				ErrConsumerCoordinatorNotAvailable, ErrNotCoordinatorForConsumer:
 				// not a critical error, we just need to redispatch
 				shouldRelease = true
+				retry = true
+			case ErrRequestTimedOut:
+				// transient, retry before telling the user
+				if retriable {
+					retry = true
+				} else {
+					pom.handleError(err)
+				}
 			case ErrOffsetMetadataTooLarge, ErrInvalidCommitOffsetSize:
 				// nothing we can do about this, just tell the user and carry on
 				pom.handleError(err)
 			case ErrOffsetsLoadInProgress:
 				// nothing wrong but we didn't commit, we'll get it next time round
+				retry = true
+			case ErrStaleMemberEpoch:
+				// the member epoch was bumped by a heartbeat while the commit was in
+				// flight, we'll get it next time round with the new epoch
+				retry = true
+			case ErrIllegalGeneration, ErrUnknownMemberId:
+				// a rebalance fenced us, retrying can not help
+				pom.handleError(err)
+				fenced = true
 			case ErrFencedInstancedId:
 				pom.handleError(err)
+				fenced = true
 				// TODO close the whole consumer for instance fenced....
 				om.tryCancelSession()
 			case ErrUnknownTopicOrPartition:
@@ -436,6 +527,7 @@ func (om *offsetManager) handleResponse(broker *Broker, req *OffsetCommitRequest
 	if shouldRelease {
 		om.releaseCoordinator(broker)
 	}
+	return retry && retriable && !fenced, commitErr
 }
 
 func (om *offsetManager) handleError(err error) {
@@ -476,6 +476,138 @@ func TestNewOffsetManagerOffsetsManualCommit(t *testing.T) {
 	safeClose(t, testClient)
 }
 
+func TestOffsetManagerCommitRetry(t *testing.T) {
+	for _, d := range []struct {
+		name     string
+		errs     []KError // returned by the successive commits
+		requests int
+		err      error
+	}{
+		{"transient timeout", []KError{ErrRequestTimedOut, ErrNoError}, 2, nil},
+		{"offsets loading", []KError{ErrOffsetsLoadInProgress, ErrOffsetsLoadInProgress, ErrNoError}, 3, nil},
+		{"retries exhausted", []KError{ErrRequestTimedOut}, 4, ErrRequestTimedOut},
+		{"illegal generation", []KError{ErrIllegalGeneration}, 1, ErrIllegalGeneration},
+		{"unknown member", []KError{ErrUnknownMemberId}, 1, ErrUnknownMemberId},
+	} {
+		t.Run(d.name, func(t *testing.T) {
+			config := NewTestConfig()
+			config.Consumer.Offsets.AutoCommit.Enable = false
+			config.Consumer.Offsets.Retry.Max = 3
+			config.Consumer.Return.Errors = true
+
+			om, testClient, broker, coordinator := initOffsetManagerWithBackoffFunc(t, 0, nil, config)
+			defer broker.Close()
+			defer coordinator.Close()
+			pom := initPartitionOffsetManager(t, om, coordinator, 5, "meta")
+
+			var requests int32
+			coordinator.setHandler(func(req *request) (res encoderWithHeader) {
+				n := int(atomic.AddInt32(&requests, 1))
+				ocResponse := new(OffsetCommitResponse)
+				ocResponse.AddError("my_topic", 0, d.errs[min(n, len(d.errs))-1])
+				return ocResponse
+			})
+
+			pom.MarkOffset(100, "modified_meta")
+			om.Commit()
+
+			require.Equal(t, d.requests, int(atomic.LoadInt32(&requests)))
+			require.Equal(t, d.err, om.(CommitErrorReporter).LastCommitError())
+			if d.err == nil {
+				require.Empty(t, pom.Errors())
+			} else {
+				select {
+				case cErr := <-pom.Errors():
+					require.Equal(t, d.err, cErr.Err)
+				default:
+					t.Fatal("expected the commit error to be reported")
+				}
+				require.Empty(t, pom.Errors())
+			}
+
+			safeClose(t, om)
+			safeClose(t, pom)
+			safeClose(t, testClient)
+		})
+	}
+}
+
+func TestOffsetManagerAutoCommitRetryStopsAtNextTick(t *testing.T) {
+	config := NewTestConfig()
+	config.Consumer.Offsets.AutoCommit.Interval = 50 * time.Millisecond
+	config.Consumer.Offsets.Retry.Max = 3
+	config.Consumer.Offsets.Retry.Backoff = time.Minute
+	config.Consumer.Return.Errors = true
+
+	om, testClient, broker, coordinator := initOffsetManagerWithBackoffFunc(t, 0, nil, config)
+	defer broker.Close()
+	defer coordinator.Close()
+	pom := initPartitionOffsetManager(t, om, coordinator, 5, "meta")
+
+	coordinator.setHandler(func(req *request) (res encoderWithHeader) {
+		ocResponse := new(OffsetCommitResponse)
+		ocResponse.AddError("my_topic", 0, ErrRequestTimedOut)
+		return ocResponse
+	})
+
+	// the backoff outlasts the auto-commit interval, so the tick fails
+	// straight away instead of blocking the next ones
+	pom.MarkOffset(100, "modified_meta")
+	select {
+	case cErr := <-pom.Errors():
+		require.ErrorIs(t, cErr.Err, ErrRequestTimedOut)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the auto-commit error")
+	}
+	require.ErrorIs(t, om.(CommitErrorReporter).LastCommitError(), ErrRequestTimedOut)
+
+	safeClose(t, om)
+	// the following ticks failed as well
+	_ = pom.Close()
+	safeClose(t, testClient)
+}
+
+func TestOffsetManagerCloseInterruptsCommitRetry(t *testing.T) {
+	config := NewTestConfig()
+	config.Consumer.Offsets.AutoCommit.Enable = false
+	config.Consumer.Offsets.Retry.Max = 3
+	config.Consumer.Offsets.Retry.Backoff = time.Minute
+
+	om, testClient, broker, coordinator := initOffsetManagerWithBackoffFunc(t, 0, nil, config)
+	defer broker.Close()
+	defer coordinator.Close()
+	pom := initPartitionOffsetManager(t, om, coordinator, 5, "meta")
+
+	committing := make(chan none, 1)
+	coordinator.setHandler(func(req *request) (res encoderWithHeader) {
+		select {
+		case committing <- none{}:
+		default:
+		}
+		ocResponse := new(OffsetCommitResponse)
+		ocResponse.AddError("my_topic", 0, ErrRequestTimedOut)
+		return ocResponse
+	})
+
+	pom.MarkOffset(100, "modified_meta")
+	committed := make(chan none)
+	go func() {
+		om.Commit()
+		close(committed)
+	}()
+	<-committing
+
+	// the commit is now waiting for its backoff to retry
+	safeClose(t, om)
+	select {
+	case <-committed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for Close to interrupt the commit retry backoff")
+	}
+	safeClose(t, pom)
+	safeClose(t, testClient)
+}
+
 // Test recovery from ErrNotCoordinatorForConsumer
 // on first fetchInitialOffset call
 func TestOffsetManagerFetchInitialFail(t *testing.T) {
@@ -620,6 +752,60 @@ func TestOffsetManagerFetchInitialTopLevelErr(t *testing.T) {
 	safeClose(t, testClient)
 }
 
+// With Consumer.Offsets.RequireStable, the OffsetFetch request carries the
+// require_stable flag and is retried while the coordinator reports an unstable
+// offset commit.
+func TestOffsetManagerFetchInitialRequireStable(t *testing.T) {
+	config := NewTestConfig()
+	config.Version = V2_5_0_0
+	config.Metadata.Retry.Max = 2
+	config.Metadata.Retry.Backoff = 0
+	config.Consumer.Offsets.RequireStable = true
+
+	broker := NewMockBroker(t, 1)
+	defer broker.Close()
+
+	broker.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader("my_topic", 0, broker.BrokerID()),
+		"FindCoordinatorRequest": NewMockFindCoordinatorResponse(t).
+			SetCoordinator(CoordinatorGroup, "group", broker),
+		"OffsetFetchRequest": NewMockSequence(
+			NewMockOffsetFetchResponse(t).
+				SetOffset("group", "my_topic", 0, -1, "", ErrUnstableOffsetCommit),
+			NewMockOffsetFetchResponse(t).
+				SetOffset("group", "my_topic", 0, 5, "test_meta", ErrNoError),
+		),
+	})
+
+	testClient, err := NewClient([]string{broker.Addr()}, config)
+	require.NoError(t, err)
+	defer safeClose(t, testClient)
+	om, err := NewOffsetManagerFromClient("group", testClient)
+	require.NoError(t, err)
+	defer safeClose(t, om)
+
+	pom, err := om.ManagePartition("my_topic", 0)
+	require.NoError(t, err)
+	defer safeClose(t, pom)
+
+	offset, meta := pom.NextOffset()
+	require.Equal(t, int64(5), offset)
+	require.Equal(t, "test_meta", meta)
+
+	var requests []*OffsetFetchRequest
+	for _, rr := range broker.History() {
+		if req, ok := rr.Request.(*OffsetFetchRequest); ok {
+			requests = append(requests, req)
+		}
+	}
+	require.Len(t, requests, 2, "expected the unstable offset fetch to be retried")
+	for _, req := range requests {
+		require.True(t, req.RequireStable, "expected the require_stable flag to be encoded")
+	}
+}
+
 func TestPartitionOffsetManagerInitialOffset(t *testing.T) {
 	om, testClient, broker, coordinator := initOffsetManager(t, 0)
 	defer broker.Close()
@@ -20,6 +20,7 @@ func (b *offsetRequestBlock) encode(pe packetEncoder, version int16) error {
 		pe.putInt32(b.maxNumOffsets)
 	}
 
+	pe.putEmptyTaggedFieldArray()
 	return nil
 }
 
@@ -41,7 +42,8 @@ func (b *offsetRequestBlock) decode(pd packetDecoder, version int16) (err error)
 		}
 	}
 
-	return nil
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
 }
 
 type OffsetRequest struct {
@@ -54,7 +56,13 @@ type OffsetRequest struct {
 
 func NewOffsetRequest(version KafkaVersion) *OffsetRequest {
 	request := &OffsetRequest{}
-	if version.IsAtLeast(V2_2_0_0) {
+	if version.IsAtLeast(V3_0_0_0) {
+		// Version 7 adds the MAX_TIMESTAMP lookup (KIP-734).
+		request.Version = 7
+	} else if version.IsAtLeast(V2_8_0_0) {
+		// Version 6 enables flexible versions.
+		request.Version = 6
+	} else if version.IsAtLeast(V2_2_0_0) {
 		// Version 5 adds a new error code, OFFSET_NOT_AVAILABLE.
 		request.Version = 5
 	} else if version.IsAtLeast(V2_1_0_0) {
@@ -109,7 +117,9 @@ func (r *OffsetRequest) encode(pe packetEncoder) error {
 				return err
 			}
 		}
+		pe.putEmptyTaggedFieldArray()
 	}
+	pe.putEmptyTaggedFieldArray()
 	return nil
 }
 
@@ -141,7 +151,8 @@ func (r *OffsetRequest) decode(pd packetDecoder, version int16) error {
 		return err
 	}
 	if blockCount == 0 {
-		return nil
+		_, err = pd.getEmptyTaggedFieldArray()
+		return err
 	}
 	r.blocks = make(map[string]map[int32]*offsetRequestBlock)
 	for i := 0; i < blockCount; i++ {
@@ -165,8 +176,12 @@ func (r *OffsetRequest) decode(pd packetDecoder, version int16) error {
 			}
 			r.blocks[topic][partition] = block
 		}
+		if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
 	}
-	return nil
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
 }
 
 func (r *OffsetRequest) key() int16 {
@@ -178,15 +193,30 @@ func (r *OffsetRequest) version() int16 {
 }
 
 func (r *OffsetRequest) headerVersion() int16 {
+	if r.Version >= 6 {
+		return 2
+	}
 	return 1
 }
 
 func (r *OffsetRequest) isValidVersion() bool {
-	return r.Version >= 0 && r.Version <= 5
+	return r.Version >= 0 && r.Version <= 7
+}
+
+func (r *OffsetRequest) isFlexible() bool {
+	return r.isFlexibleVersion(r.Version)
+}
+
+func (r *OffsetRequest) isFlexibleVersion(version int16) bool {
+	return version >= 6
 }
 
 func (r *OffsetRequest) requiredVersion() KafkaVersion {
 	switch r.Version {
+	case 7:
+		return V3_0_0_0
+	case 6:
+		return V2_8_0_0
 	case 5:
 		return V2_2_0_0
 	case 4:
@@ -217,6 +247,12 @@ func (r *OffsetRequest) ReplicaID() int32 {
 }
 
 func (r *OffsetRequest) AddBlock(topic string, partitionID int32, timestamp int64, maxOffsets int32) {
+	r.addBlock(topic, partitionID, timestamp, maxOffsets, -1)
+}
+
+// addBlock is AddBlock with the current leader epoch of the partition, which
+// the broker uses from version 4 to fence requests sent to a stale leader.
+func (r *OffsetRequest) addBlock(topic string, partitionID int32, timestamp int64, maxOffsets int32, currentLeaderEpoch int32) {
 	if r.blocks == nil {
 		r.blocks = make(map[string]map[int32]*offsetRequestBlock)
 	}
@@ -226,7 +262,7 @@ func (r *OffsetRequest) AddBlock(topic string, partitionID int32, timestamp int6
 	}
 
 	tmp := new(offsetRequestBlock)
-	tmp.currentLeaderEpoch = -1
+	tmp.currentLeaderEpoch = currentLeaderEpoch
 	tmp.timestamp = timestamp
 	if r.Version == 0 {
 		tmp.maxNumOffsets = maxOffsets
@@ -2,7 +2,10 @@
 
 package sarama
 
-import "testing"
+import (
+	"fmt"
+	"testing"
+)
 
 var (
 	offsetRequestNoBlocksV1 = []byte{
@@ -60,6 +63,20 @@ var (
 		0xff, 0xff, 0xff, 0xff, // leader epoch
 		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, // timestamp
 	}
+
+	offsetRequestV6 = []byte{
+		0xff, 0xff, 0xff, 0xff, // replicaID
+		0x01,                         // IsolationLevel
+		0x02,                         // compact array length of topics
+		0x05, 0x64, 0x6e, 0x77, 0x65, // topic name
+		0x02,                   // compact array length of partitions
+		0x00, 0x00, 0x00, 0x09, // partitionID
+		0x00, 0x00, 0x00, 0x07, // leader epoch
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, // timestamp
+		0x00, // partition tagged fields
+		0x00, // topic tagged fields
+		0x00, // tagged fields
+	}
 )
 
 func TestOffsetRequest(t *testing.T) {
@@ -108,3 +125,26 @@ func TestOffsetRequestV4(t *testing.T) {
 	request.AddBlock("dnwe", 9, -1, -1)
 	testRequest(t, "V4", request, offsetRequestV4)
 }
+
+func TestOffsetRequestV6AndV7(t *testing.T) {
+	for _, version := range []int16{6, 7} {
+		request := new(OffsetRequest)
+		request.Version = version
+		request.IsolationLevel = ReadCommitted
+		request.addBlock("dnwe", 9, -1, -1, 7)
+		testRequest(t, fmt.Sprintf("V%d", version), request, offsetRequestV6)
+	}
+}
+
+func TestNewOffsetRequestVersion(t *testing.T) {
+	for version, expected := range map[KafkaVersion]int16{
+		V2_2_0_0: 5,
+		V2_8_0_0: 6,
+		V3_0_0_0: 7,
+		V4_0_0_0: 7,
+	} {
+		if found := NewOffsetRequest(version).Version; found != expected {
+			t.Errorf("version for %s: expected %d, found %d", version, expected, found)
+		}
+	}
+}
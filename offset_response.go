@@ -21,7 +21,10 @@ func (b *OffsetResponseBlock) decode(pd packetDecoder, version int16) (err error
 	}
 
 	if version == 0 {
-		b.Offsets, err = pd.getInt64Array()
+		if b.Offsets, err = pd.getInt64Array(); err != nil {
+			return err
+		}
+		_, err = pd.getEmptyTaggedFieldArray()
 		return err
 	}
 
@@ -46,14 +49,19 @@ func (b *OffsetResponseBlock) decode(pd packetDecoder, version int16) (err error
 		}
 	}
 
-	return nil
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
 }
 
 func (b *OffsetResponseBlock) encode(pe packetEncoder, version int16) (err error) {
 	pe.putKError(b.Err)
 
 	if version == 0 {
-		return pe.putInt64Array(b.Offsets)
+		if err = pe.putInt64Array(b.Offsets); err != nil {
+			return err
+		}
+		pe.putEmptyTaggedFieldArray()
+		return nil
 	}
 
 	if version >= 1 {
@@ -65,6 +73,7 @@ func (b *OffsetResponseBlock) encode(pe packetEncoder, version int16) (err error
 		pe.putInt32(b.LeaderEpoch)
 	}
 
+	pe.putEmptyTaggedFieldArray()
 	return nil
 }
 
@@ -79,6 +88,7 @@ func (r *OffsetResponse) setVersion(v int16) {
 }
 
 func (r *OffsetResponse) decode(pd packetDecoder, version int16) (err error) {
+	r.Version = version
 	if version >= 2 {
 		r.ThrottleTimeMs, err = pd.getInt32()
 		if err != nil {
@@ -118,9 +128,14 @@ func (r *OffsetResponse) decode(pd packetDecoder, version int16) (err error) {
 			}
 			r.Blocks[name][id] = block
 		}
+
+		if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
 }
 
 func (r *OffsetResponse) GetBlock(topic string, partition int32) *OffsetResponseBlock {
@@ -171,8 +186,10 @@ func (r *OffsetResponse) encode(pe packetEncoder) (err error) {
 				return err
 			}
 		}
+		pe.putEmptyTaggedFieldArray()
 	}
 
+	pe.putEmptyTaggedFieldArray()
 	return nil
 }
 
@@ -185,15 +202,30 @@ func (r *OffsetResponse) version() int16 {
 }
 
 func (r *OffsetResponse) headerVersion() int16 {
+	if r.Version >= 6 {
+		return 1
+	}
 	return 0
 }
 
 func (r *OffsetResponse) isValidVersion() bool {
-	return r.Version >= 0 && r.Version <= 5
+	return r.Version >= 0 && r.Version <= 7
+}
+
+func (r *OffsetResponse) isFlexible() bool {
+	return r.isFlexibleVersion(r.Version)
+}
+
+func (r *OffsetResponse) isFlexibleVersion(version int16) bool {
+	return version >= 6
 }
 
 func (r *OffsetResponse) requiredVersion() KafkaVersion {
 	switch r.Version {
+	case 7:
+		return V3_0_0_0
+	case 6:
+		return V2_8_0_0
 	case 5:
 		return V2_2_0_0
 	case 4:
@@ -4,6 +4,7 @@ package sarama
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 )
 
@@ -52,6 +53,21 @@ var (
 		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // offset
 		0xff, 0xff, 0xff, 0xff, // leaderEpoch
 	}
+
+	offsetResponseV6 = []byte{
+		0x00, 0x00, 0x00, 0x00, // throttle time
+		0x02,                         // compact array length of topics
+		0x05, 0x64, 0x6e, 0x77, 0x65, // topic name
+		0x02,                   // compact array length of partitions
+		0x00, 0x00, 0x00, 0x09, // partitionID
+		0x00, 0x00, // err
+		0x00, 0x00, 0x01, 0x58, 0x1A, 0xE6, 0x48, 0x86, // timestamp
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x2a, // offset
+		0x00, 0x00, 0x00, 0x07, // leaderEpoch
+		0x00, // partition tagged fields
+		0x00, // topic tagged fields
+		0x00, // tagged fields
+	}
 )
 
 func TestEmptyOffsetResponse(t *testing.T) {
@@ -135,3 +151,20 @@ func TestOffsetResponseV4(t *testing.T) {
 
 	testVersionDecodable(t, "v4", &response, offsetResponseV4, 4)
 }
+
+func TestOffsetResponseV6AndV7(t *testing.T) {
+	for _, version := range []int16{6, 7} {
+		response := &OffsetResponse{
+			Version: version,
+			Blocks: map[string]map[int32]*OffsetResponseBlock{
+				"dnwe": {9: {
+					Timestamp:   0x0000_0158_1AE6_4886,
+					Offset:      42,
+					Offsets:     []int64{42},
+					LeaderEpoch: 7,
+				}},
+			},
+		}
+		testResponse(t, fmt.Sprintf("v%d", version), response, offsetResponseV6)
+	}
+}
@@ -0,0 +1,205 @@
+package sarama
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// PartitionReader reads the messages of a single partition synchronously, on
+// the goroutine calling ReadMessage. Unlike a PartitionConsumer it starts no
+// goroutine and uses no channel, and it buffers the messages of a single fetch
+// response at a time, which suits simple tools reading a partition in a loop.
+// A PartitionReader must not be used concurrently.
+type PartitionReader interface {
+	// ReadMessage returns the next message of the partition, fetching more
+	// from the broker once the previously fetched ones have been read. It
+	// blocks until a message is available, or returns ctx.Err() once ctx is
+	// done. A fetch in progress is not interrupted, so cancellation is noticed
+	// within Consumer.MaxWaitTime. Other errors, such as a leader change, are
+	// returned as is and the next call fetches again, from the new leader if
+	// need be.
+	ReadMessage(ctx context.Context) (*ConsumerMessage, error)
+
+	// HighWaterMarkOffset returns the high water mark offset of the partition,
+	// as of the last fetch.
+	HighWaterMarkOffset() int64
+
+	// Close stops the reader. ReadMessage returns ErrClosedConsumer afterwards.
+	Close() error
+}
+
+type partitionReader struct {
+	// child holds the consumption state of the partition and parses the fetch
+	// responses, its dispatcher and feeder goroutines are never started
+	child    *partitionConsumer
+	buffered []*ConsumerMessage
+	closed   bool
+}
+
+func (c *consumer) NewPartitionReader(topic string, partition int32, offset int64) (PartitionReader, error) {
+	child := &partitionConsumer{
+		consumer:  c,
+		conf:      c.conf,
+		topic:     topic,
+		partition: partition,
+		// parseResponse reports the messages too large to be fetched and the
+		// end of the partition on these, ReadMessage drains them
+		errors:               make(chan *ConsumerError, 1),
+		eofs:                 make(chan int64, 1),
+		dying:                make(chan none),
+		leaderEpoch:          invalidLeaderEpoch,
+		lastFetchedEpoch:     invalidLeaderEpoch,
+		preferredReadReplica: invalidPreferredReplicaID,
+		fetchSize:            c.conf.Consumer.Fetch.Default,
+		isolationLevel:       c.conf.Consumer.IsolationLevel,
+		eofOffset:            -1,
+	}
+	if err := child.chooseStartingOffset(offset); err != nil {
+		return nil, err
+	}
+	return &partitionReader{child: child}, nil
+}
+
+func (r *partitionReader) ReadMessage(ctx context.Context) (*ConsumerMessage, error) {
+	for len(r.buffered) == 0 {
+		if r.closed {
+			return nil, ErrClosedConsumer
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := r.fetch(); err != nil {
+			return nil, err
+		}
+	}
+
+	msg := r.buffered[0]
+	r.buffered[0] = nil
+	r.buffered = r.buffered[1:]
+	r.child.interceptors(msg)
+	return msg, nil
+}
+
+// fetch sends a single FetchRequest for the partition and buffers the messages
+// of its response.
+func (r *partitionReader) fetch() error {
+	child := r.child
+	if child.broker == nil {
+		broker, epoch, err := child.preferredBroker()
+		if err != nil {
+			return err
+		}
+		child.leaderEpoch = epoch
+		// only the broker is used, the brokerConsumer is not started
+		child.broker = &brokerConsumer{consumer: child.consumer, broker: broker}
+	}
+
+	request := newFetchRequest(child.conf, child.isolationLevel)
+	request.AddBlock(child.topic, child.partition, child.offset, child.fetchSize, child.leaderEpoch)
+	request.setLastFetchedEpoch(child.topic, child.partition, child.lastFetchedEpoch)
+	response, err := child.broker.broker.fetch(request, &FetchResponse{zstdDict: child.consumer.zstdDict})
+	if err != nil {
+		Logger.Printf("consumer/broker/%d disconnecting due to error processing FetchRequest: %s\n", child.broker.broker.ID(), err)
+		_ = child.broker.broker.Close()
+		r.abandonBroker()
+		return err
+	}
+
+	msgs, err := child.parseResponse(response)
+	select {
+	case <-child.eofs:
+	default:
+	}
+	if err == nil {
+		select {
+		case cErr := <-child.errors:
+			err = cErr
+		default:
+		}
+	}
+
+	switch {
+	case err == nil:
+		if preferredBroker, _, err := child.preferredBroker(); err == nil && preferredBroker.ID() != child.broker.broker.ID() {
+			// consume from the preferred replica from the next fetch
+			child.broker = nil
+		}
+	case errors.Is(err, ErrOffsetOutOfRange):
+		switch policy := child.conf.Consumer.Offsets.OutOfRangeReset; policy {
+		case OffsetResetEarliest, OffsetResetLatest:
+			offset, _ := policy.resetOffset(child.conf.Consumer.Offsets.Initial)
+			if err := child.chooseStartingOffset(offset); err != nil {
+				return err
+			}
+			Logger.Printf("consumer/%s/%d reset out of range offset to %d\n", child.topic, child.partition, child.offset)
+		case OffsetResetError:
+			return &OffsetOutOfRangeError{Topic: child.topic, Partition: child.partition, Offset: child.offset}
+		default:
+			return err
+		}
+	case errors.Is(err, ErrLogTruncated), errors.Is(err, ErrMessageTooLarge):
+		// the offset was already moved past the error if possible
+		return err
+	default:
+		r.abandonBroker()
+		return err
+	}
+
+	r.buffered = msgs
+	return nil
+}
+
+// readUntil returns the messages before the end offset. Unlike ReadMessage it
+// returns as soon as the position of the reader reaches end, or stops moving
+// forward, even if the last offsets hold no message.
+func (r *partitionReader) readUntil(end int64) ([]*ConsumerMessage, error) {
+	var msgs []*ConsumerMessage
+	for {
+		for _, msg := range r.buffered {
+			if msg.Offset >= end {
+				return msgs, nil
+			}
+			r.child.interceptors(msg)
+			msgs = append(msgs, msg)
+		}
+		r.buffered = nil
+
+		offset, fetchSize := r.child.offset, r.child.fetchSize
+		if offset >= end {
+			return msgs, nil
+		}
+		if err := r.fetch(); err != nil {
+			return nil, err
+		}
+		if len(r.buffered) == 0 && r.child.offset == offset && r.child.fetchSize == fetchSize {
+			// e.g. the records up to end are not committed yet
+			return msgs, nil
+		}
+	}
+}
+
+// abandonBroker discards the current broker and replica preference, so that
+// the next fetch is sent to the leader as of fresh metadata.
+func (r *partitionReader) abandonBroker() {
+	child := r.child
+	child.broker = nil
+	child.preferredReadReplica = invalidPreferredReplicaID
+	child.preferredReadReplicaExpiry = time.Time{}
+	_ = child.consumer.client.RefreshMetadata(child.topic)
+}
+
+func (r *partitionReader) HighWaterMarkOffset() int64 {
+	return r.child.highWaterMarkOffset.Load()
+}
+
+func (r *partitionReader) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	r.buffered = nil
+	close(r.child.dying)
+	r.child.unregisterLag()
+	return nil
+}
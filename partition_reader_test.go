@@ -0,0 +1,115 @@
+//go:build !functional
+
+package sarama
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestPartitionReaderReadMessage(t *testing.T) {
+	broker0 := NewMockBroker(t, 0)
+	defer broker0.Close()
+
+	mockFetchResponse := NewMockFetchResponse(t, 2)
+	for i := int64(0); i < 5; i++ {
+		mockFetchResponse.SetMessage("my_topic", 0, i+10, testMsg)
+	}
+	mockFetchResponse.SetHighWaterMark("my_topic", 0, 15)
+
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetOldest, 0).
+			SetOffset("my_topic", 0, OffsetNewest, 15),
+		"FetchRequest": mockFetchResponse,
+	})
+
+	master, err := NewConsumer([]string{broker0.Addr()}, NewTestConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, master)
+
+	reader, err := master.NewPartitionReader("my_topic", 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := int64(0); i < 5; i++ {
+		msg, err := reader.ReadMessage(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertMessageOffset(t, msg, i+10)
+	}
+	if hwmo := reader.HighWaterMarkOffset(); hwmo != 15 {
+		t.Errorf("Expected high water mark offset 15, found %d", hwmo)
+	}
+
+	// a batch of two messages is buffered per fetch
+	fetches := 0
+	for _, rr := range broker0.History() {
+		if _, ok := rr.Request.(*FetchRequest); ok {
+			fetches++
+		}
+	}
+	if fetches != 3 {
+		t.Errorf("Expected 3 fetch requests, got %d", fetches)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := reader.ReadMessage(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+
+	safeClose(t, reader)
+	if _, err := reader.ReadMessage(context.Background()); !errors.Is(err, ErrClosedConsumer) {
+		t.Errorf("Expected ErrClosedConsumer, got %v", err)
+	}
+}
+
+func TestPartitionReaderLeaderChange(t *testing.T) {
+	broker0 := NewMockBroker(t, 0)
+	defer broker0.Close()
+
+	notLeader := &FetchResponse{}
+	notLeader.AddError("my_topic", 0, ErrNotLeaderForPartition)
+	fetchResponse := &FetchResponse{}
+	fetchResponse.AddMessage("my_topic", 0, nil, testMsg, 0)
+
+	broker0.SetHandlerByMap(map[string]MockResponse{
+		"MetadataRequest": NewMockMetadataResponse(t).
+			SetBroker(broker0.Addr(), broker0.BrokerID()).
+			SetLeader("my_topic", 0, broker0.BrokerID()),
+		"OffsetRequest": NewMockOffsetResponse(t).
+			SetOffset("my_topic", 0, OffsetOldest, 0).
+			SetOffset("my_topic", 0, OffsetNewest, 1),
+		"FetchRequest": NewMockSequence(notLeader, fetchResponse),
+	})
+
+	master, err := NewConsumer([]string{broker0.Addr()}, NewTestConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, master)
+
+	reader, err := master.NewPartitionReader("my_topic", 0, OffsetOldest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer safeClose(t, reader)
+
+	if _, err := reader.ReadMessage(context.Background()); !errors.Is(err, ErrNotLeaderForPartition) {
+		t.Fatalf("Expected ErrNotLeaderForPartition, got %v", err)
+	}
+	msg, err := reader.ReadMessage(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertMessageOffset(t, msg, 0)
+}
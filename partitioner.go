@@ -77,6 +77,19 @@ func WithCustomFallbackPartitioner(randomHP Partitioner) HashPartitionerOption {
 	}
 }
 
+// WithHeaderKey makes the partitioner hash the value of the message header
+// named key instead of the message key. If the header is repeated the value of
+// the last one is hashed, in line with ProducerMessage.Header; earlier versions
+// hashed the first one, so messages with repeated headers may be routed to a
+// different partition after upgrading. Messages without such a header are sent
+// to the partition chosen by the fallback partitioner.
+func WithHeaderKey(key string) HashPartitionerOption {
+	return func(hp *hashPartitioner) {
+		hp.headerKey = []byte(key)
+		hp.useHeader = true
+	}
+}
+
 // NewManualPartitioner returns a Partitioner which uses the partition manually set in the provided
 // ProducerMessage's Partition field as the partition to produce to.
 func NewManualPartitioner(topic string) Partitioner {
@@ -137,6 +150,8 @@ type hashPartitioner struct {
 	hasher       hash.Hash32
 	referenceAbs bool
 	hashUnsigned bool
+	useHeader    bool
+	headerKey    []byte
 }
 
 // NewCustomHashPartitioner is a wrapper around NewHashPartitioner, allowing the use of custom hasher.
@@ -181,6 +196,16 @@ func NewHashPartitioner(topic string) Partitioner {
 	return p
 }
 
+// NewHeaderHashPartitioner returns a PartitionerConstructor for partitioners
+// that behave like NewHashPartitioner but hash the value of the message header
+// named key instead of the message key, so that the routing value does not have
+// to be duplicated into the key. If the message has no such header a random
+// partition is chosen. Options further customize the partitioners, e.g.
+// WithAbsFirst to be compatible with the reference Java implementation.
+func NewHeaderHashPartitioner(key string, options ...HashPartitionerOption) PartitionerConstructor {
+	return NewCustomPartitioner(append([]HashPartitionerOption{WithHeaderKey(key)}, options...)...)
+}
+
 // NewReferenceHashPartitioner is like NewHashPartitioner except that it handles absolute values
 // in the same way as the reference Java implementation. NewHashPartitioner was supposed to do
 // that but it had a mistake and now there are people depending on both behaviors. This will
@@ -206,16 +231,31 @@ func NewConsistentCRCHashPartitioner(topic string) Partitioner {
 	return p
 }
 
+// headerValue returns the value of the header p hashes, false if message has
+// no such header.
+func (p *hashPartitioner) headerValue(message *ProducerMessage) ([]byte, bool) {
+	return message.Header(p.headerKey)
+}
+
 func (p *hashPartitioner) Partition(message *ProducerMessage, numPartitions int32) (int32, error) {
-	if message.Key == nil {
-		return p.random.Partition(message, numPartitions)
-	}
-	bytes, err := message.Key.Encode()
-	if err != nil {
-		return -1, err
+	var key []byte
+	if p.useHeader {
+		value, ok := p.headerValue(message)
+		if !ok {
+			return p.random.Partition(message, numPartitions)
+		}
+		key = value
+	} else {
+		if message.Key == nil {
+			return p.random.Partition(message, numPartitions)
+		}
+		var err error
+		if key, err = message.Key.Encode(); err != nil {
+			return -1, err
+		}
 	}
 	p.hasher.Reset()
-	_, err = p.hasher.Write(bytes)
+	_, err := p.hasher.Write(key)
 	if err != nil {
 		return -1, err
 	}
@@ -244,6 +284,10 @@ func (p *hashPartitioner) RequiresConsistency() bool {
 }
 
 func (p *hashPartitioner) MessageRequiresConsistency(message *ProducerMessage) bool {
+	if p.useHeader {
+		_, ok := p.headerValue(message)
+		return ok
+	}
 	return message.Key != nil
 }
 
@@ -319,6 +319,89 @@ func TestWithCustomFallbackPartitioner(t *testing.T) {
 	}
 }
 
+func TestHeaderHashPartitioner(t *testing.T) {
+	partitioner := NewHeaderHashPartitioner("tenant")("mytopic")
+	keyPartitioner := NewHashPartitioner("mytopic")
+
+	if !partitioner.RequiresConsistency() {
+		t.Error("Header hash partitioner should require consistency")
+	}
+
+	for i := 0; i < 50; i++ {
+		tenant := fmt.Sprintf("tenant-%d", i)
+		msg := &ProducerMessage{
+			Key: StringEncoder("ignored"),
+			Headers: []RecordHeader{
+				{Key: []byte("trace"), Value: []byte("abc")},
+				{Key: []byte("tenant"), Value: []byte(tenant)},
+			},
+		}
+		assertPartitioningConsistent(t, partitioner, msg, 50)
+
+		// the header value is hashed exactly like a key would be
+		choice, err := partitioner.Partition(msg, 50)
+		if err != nil {
+			t.Error(partitioner, err)
+		}
+		expected, err := keyPartitioner.Partition(&ProducerMessage{Key: StringEncoder(tenant)}, 50)
+		if err != nil {
+			t.Error(keyPartitioner, err)
+		}
+		if choice != expected {
+			t.Error(partitioner, "returned partition", choice, "for", tenant, "but expected", expected, ".")
+		}
+	}
+
+	// without the header a random partition is chosen, whatever the key
+	for i := 0; i < 50; i++ {
+		choice, err := partitioner.Partition(&ProducerMessage{Key: StringEncoder("key")}, 50)
+		if err != nil {
+			t.Error(partitioner, err)
+		}
+		if choice < 0 || choice >= 50 {
+			t.Error(partitioner, "returned partition", choice, "outside of range.")
+		}
+	}
+}
+
+func TestHeaderHashPartitionerConsistency(t *testing.T) {
+	ep, ok := NewHeaderHashPartitioner("tenant")("mytopic").(DynamicConsistencyPartitioner)
+	if !ok {
+		t.Fatal("Header hash partitioner does not implement DynamicConsistencyPartitioner")
+	}
+
+	withHeader := &ProducerMessage{Headers: []RecordHeader{{Key: []byte("tenant"), Value: []byte("acme")}}}
+	if !ep.MessageRequiresConsistency(withHeader) {
+		t.Error("Messages with the header should require consistency")
+	}
+	withKeyOnly := &ProducerMessage{Key: StringEncoder("acme")}
+	if ep.MessageRequiresConsistency(withKeyOnly) {
+		t.Error("Messages without the header should not require consistency")
+	}
+}
+
+func TestHeaderHashPartitionerWithOptions(t *testing.T) {
+	partitioner := NewHeaderHashPartitioner("tenant", WithAbsFirst())("mytopic")
+	reference := NewReferenceHashPartitioner("mytopic")
+
+	for i := 0; i < 50; i++ {
+		tenant := fmt.Sprintf("tenant-%d", i)
+		choice, err := partitioner.Partition(&ProducerMessage{
+			Headers: []RecordHeader{{Key: []byte("tenant"), Value: []byte(tenant)}},
+		}, 7)
+		if err != nil {
+			t.Error(partitioner, err)
+		}
+		expected, err := reference.Partition(&ProducerMessage{Key: StringEncoder(tenant)}, 7)
+		if err != nil {
+			t.Error(reference, err)
+		}
+		if choice != expected {
+			t.Error(partitioner, "returned partition", choice, "for", tenant, "but expected", expected, ".")
+		}
+	}
+}
+
 func TestMurmur2(t *testing.T) {
 	// expected values verified against the Apache Kafka Java client's
 	// org.apache.kafka.common.utils.Utils.murmur2() implementation
@@ -20,6 +20,8 @@ type produceSet struct {
 
 	bufferBytes int
 	bufferCount int
+	// fullPartitions counts the partitions holding Flush.MaxMessagesPerPartition messages
+	fullPartitions int
 }
 
 func newProduceSet(parent *asyncProducer) *produceSet {
@@ -69,13 +71,16 @@ func (ps *produceSet) add(msg *ProducerMessage) error {
 	set := partitions[msg.Partition]
 	if set == nil {
 		if ps.parent.conf.Version.IsAtLeast(V0_11_0_0) {
+			codec, level := ps.parent.conf.producerCompression(msg.Topic)
 			batch := &RecordBatch{
 				FirstTimestamp:   timestamp,
 				Version:          2,
-				Codec:            ps.parent.conf.Producer.Compression,
-				CompressionLevel: ps.parent.conf.Producer.CompressionLevel,
+				Codec:            codec,
+				CompressionLevel: level,
 				ProducerID:       ps.producerID,
 				ProducerEpoch:    ps.producerEpoch,
+				LogAppendTime:    ps.parent.conf.Producer.TimestampType == LogAppendTime,
+				zstdDict:         ps.parent.zstdDict,
 			}
 			if ps.parent.conf.Producer.Idempotent {
 				batch.FirstSequence = msg.sequenceNumber
@@ -119,6 +124,7 @@ func (ps *produceSet) add(msg *ProducerMessage) error {
 		if ps.parent.conf.Version.IsAtLeast(V0_10_0_0) {
 			msgToSend.Timestamp = timestamp
 			msgToSend.Version = 1
+			msgToSend.LogAppendTime = ps.parent.conf.Producer.TimestampType == LogAppendTime
 		}
 		set.recordsToSend.MsgSet.addMessage(msgToSend)
 		size = producerMessageOverhead + len(key) + len(val)
@@ -127,6 +133,9 @@ func (ps *produceSet) add(msg *ProducerMessage) error {
 	set.bufferBytes += size
 	ps.bufferBytes += size
 	ps.bufferCount++
+	if limit := ps.parent.conf.Producer.Flush.MaxMessagesPerPartition; limit > 0 && len(set.msgs) == limit {
+		ps.fullPartitions++
+	}
 
 	return nil
 }
@@ -149,6 +158,10 @@ func (ps *produceSet) takePartitions(predicate func(topic string, partition int3
 			out.bufferCount += len(set.msgs)
 			ps.bufferBytes -= set.bufferBytes
 			ps.bufferCount -= len(set.msgs)
+			if ps.isFull(set) {
+				out.fullPartitions++
+				ps.fullPartitions--
+			}
 			delete(partitions, partition)
 		}
 		if len(partitions) == 0 {
@@ -232,7 +245,8 @@ func (ps *produceSet) buildRequest() *ProduceRequest {
 				req.AddBatch(topic, partition, rb)
 				continue
 			}
-			if ps.parent.conf.Producer.Compression == CompressionNone {
+			codec, level := ps.parent.conf.producerCompression(topic)
+			if codec == CompressionNone {
 				req.AddSet(topic, partition, set.recordsToSend.MsgSet)
 			} else {
 				// When compression is enabled, the entire set for each partition is compressed
@@ -256,8 +270,8 @@ func (ps *produceSet) buildRequest() *ProduceRequest {
 					panic(err)
 				}
 				compMsg := &Message{
-					Codec:            ps.parent.conf.Producer.Compression,
-					CompressionLevel: ps.parent.conf.Producer.CompressionLevel,
+					Codec:            codec,
+					CompressionLevel: level,
 					Key:              nil,
 					Value:            payload,
 					Set:              set.recordsToSend.MsgSet, // Provide the underlying message set for accurate metrics
@@ -265,6 +279,7 @@ func (ps *produceSet) buildRequest() *ProduceRequest {
 				if ps.parent.conf.Version.IsAtLeast(V0_10_0_0) {
 					compMsg.Version = 1
 					compMsg.Timestamp = set.recordsToSend.MsgSet.Messages[0].Msg.Timestamp
+					compMsg.LogAppendTime = ps.parent.conf.Producer.TimestampType == LogAppendTime
 				}
 				req.AddMessage(topic, partition, compMsg)
 			}
@@ -303,10 +318,19 @@ func (ps *produceSet) dropPartition(topic string, partition int32) []*ProducerMe
 	}
 	ps.bufferBytes -= set.bufferBytes
 	ps.bufferCount -= len(set.msgs)
+	if ps.isFull(set) {
+		ps.fullPartitions--
+	}
 	delete(ps.msgs[topic], partition)
 	return set.msgs
 }
 
+// isFull reports whether the partition set holds Flush.MaxMessagesPerPartition messages.
+func (ps *produceSet) isFull(set *partitionSet) bool {
+	limit := ps.parent.conf.Producer.Flush.MaxMessagesPerPartition
+	return limit > 0 && len(set.msgs) >= limit
+}
+
 func (ps *produceSet) wouldOverflow(msg *ProducerMessage) bool {
 	version := 1
 	if ps.parent.conf.Version.IsAtLeast(V0_11_0_0) {
@@ -334,12 +358,16 @@ func (ps *produceSet) readyToFlush() bool {
 	// If we don't have any messages, nothing else matters
 	case ps.empty():
 		return false
-	// If all three config values are 0, we always flush as-fast-as-possible
-	case ps.parent.conf.Producer.Flush.Frequency == 0 && ps.parent.conf.Producer.Flush.Bytes == 0 && ps.parent.conf.Producer.Flush.Messages == 0:
+	// If all four config values are 0, we always flush as-fast-as-possible
+	case ps.parent.conf.Producer.Flush.Frequency == 0 && ps.parent.conf.Producer.Flush.Bytes == 0 &&
+		ps.parent.conf.Producer.Flush.Messages == 0 && ps.parent.conf.Producer.Flush.MaxMessagesPerPartition == 0:
 		return true
 	// If we've passed the message trigger-point
 	case ps.parent.conf.Producer.Flush.Messages > 0 && ps.bufferCount >= ps.parent.conf.Producer.Flush.Messages:
 		return true
+	// If a partition has passed its message trigger-point
+	case ps.fullPartitions > 0:
+		return true
 	// If we've passed the byte trigger-point
 	case ps.parent.conf.Producer.Flush.Bytes > 0 && ps.bufferBytes >= ps.parent.conf.Producer.Flush.Bytes:
 		return true
@@ -351,3 +379,40 @@ func (ps *produceSet) readyToFlush() bool {
 func (ps *produceSet) empty() bool {
 	return ps.bufferCount == 0
 }
+
+// EstimateRecordBatchSize returns the size in bytes of msgs once encoded into
+// a single batch compressed with codec, as a producer configured with version
+// would send it, without sending anything. This is the size the broker checks
+// against its message.max.bytes, which makes it useful to tune
+// Producer.MaxMessageBytes or to split batches before enqueueing them. The
+// topic and partition of the messages are ignored. Messages without a
+// timestamp are estimated as if sent now.
+func EstimateRecordBatchSize(msgs []*ProducerMessage, codec CompressionCodec, version KafkaVersion) (int, error) {
+	conf := NewConfig()
+	conf.Version = version
+	conf.Producer.Compression = codec
+	if err := conf.validateCompression("", codec, CompressionLevelDefault); err != nil {
+		return 0, err
+	}
+	txnmgr, err := newTransactionManager(conf, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	ps := newProduceSet(&asyncProducer{conf: conf, txnmgr: txnmgr})
+	for _, msg := range msgs {
+		// batch every message together, whatever its destination
+		m := *msg
+		m.Topic, m.Partition = "", 0
+		if err := ps.add(&m); err != nil {
+			return 0, err
+		}
+	}
+
+	records := ps.buildRequest().records[""][0]
+	encoded, err := encode(&records, nil)
+	if err != nil {
+		return 0, err
+	}
+	return len(encoded), nil
+}
@@ -4,8 +4,11 @@ package sarama
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/stretchr/testify/require"
 )
 
 func makeProduceSet() (*asyncProducer, *produceSet) {
@@ -88,6 +91,44 @@ func TestProduceSetAddingMessagesOverflowBytesLimit(t *testing.T) {
 	}
 }
 
+func TestProduceSetMaxMessagesPerPartition(t *testing.T) {
+	parent, ps := makeProduceSet()
+	parent.conf.Producer.Flush.Frequency = time.Hour
+	parent.conf.Producer.Flush.Messages = 100
+	parent.conf.Producer.Flush.MaxMessagesPerPartition = 3
+
+	// spread messages thinly over four partitions, partition 0 getting its third one last
+	for i := 0; i < 9; i++ {
+		if ps.readyToFlush() {
+			t.Fatal("set shouldn't be ready to flush after only", i, "messages")
+		}
+		safeAddMessage(t, ps, &ProducerMessage{Topic: "t1", Partition: int32(i % 4), Value: StringEncoder(TestMessage)})
+	}
+	if !ps.readyToFlush() {
+		t.Error("set should be ready to flush once a partition holds 3 messages")
+	}
+
+	// the full partition is taken out of the set, e.g. to be retried
+	if msgs := ps.dropPartition("t1", 0); len(msgs) != 3 {
+		t.Fatal("expected 3 messages for partition 0, got", len(msgs))
+	}
+	if ps.readyToFlush() {
+		t.Error("set shouldn't be ready to flush once its full partition is dropped")
+	}
+
+	safeAddMessage(t, ps, &ProducerMessage{Topic: "t1", Partition: 1, Value: StringEncoder(TestMessage)})
+	if !ps.readyToFlush() {
+		t.Error("set should be ready to flush once partition 1 holds 3 messages")
+	}
+	taken := ps.takePartitions(func(_ string, partition int32) bool { return partition == 1 })
+	if ps.readyToFlush() {
+		t.Error("set shouldn't be ready to flush once its full partition is taken")
+	}
+	if taken == nil || !taken.readyToFlush() {
+		t.Error("the taken partitions should be ready to flush")
+	}
+}
+
 func TestProduceSetPartitionTracking(t *testing.T) {
 	_, ps := makeProduceSet()
 
@@ -218,6 +259,72 @@ func TestProduceSetCompressedRequestBuilding(t *testing.T) {
 	}
 }
 
+func TestProduceSetCompressionByTopic(t *testing.T) {
+	t.Run("record batches", func(t *testing.T) {
+		parent, ps := makeProduceSet()
+		parent.conf.Version = V2_1_0_0
+		parent.conf.Producer.Compression = CompressionGZIP
+		parent.conf.Producer.CompressionLevel = 9
+		parent.conf.Producer.CompressionByTopic = map[string]CompressionCodec{
+			"json":   CompressionZSTD,
+			"binary": CompressionNone,
+		}
+		parent.conf.Producer.CompressionLevelByTopic = map[string]int{"json": 3}
+
+		for _, topic := range []string{"json", "binary", "other"} {
+			for partition := int32(0); partition < 2; partition++ {
+				safeAddMessage(t, ps, &ProducerMessage{Topic: topic, Partition: partition, Value: StringEncoder(TestMessage)})
+			}
+		}
+
+		req := ps.buildRequest()
+		expected := map[string]struct {
+			codec CompressionCodec
+			level int
+		}{
+			"json":   {CompressionZSTD, 3},
+			"binary": {CompressionNone, CompressionLevelDefault},
+			"other":  {CompressionGZIP, 9},
+		}
+		for topic, want := range expected {
+			for partition := int32(0); partition < 2; partition++ {
+				batch := req.records[topic][partition].RecordBatch
+				if batch.Codec != want.codec || batch.CompressionLevel != want.level {
+					t.Errorf("%s/%d: expected codec %s level %d, got codec %s level %d",
+						topic, partition, want.codec, want.level, batch.Codec, batch.CompressionLevel)
+				}
+			}
+		}
+	})
+
+	t.Run("message sets", func(t *testing.T) {
+		parent, ps := makeProduceSet()
+		parent.conf.Version = V0_10_0_0
+		parent.conf.Producer.CompressionByTopic = map[string]CompressionCodec{"json": CompressionGZIP}
+
+		for _, topic := range []string{"json", "binary"} {
+			for i := 0; i < 3; i++ {
+				safeAddMessage(t, ps, &ProducerMessage{Topic: topic, Partition: 0, Value: StringEncoder(TestMessage)})
+			}
+		}
+
+		req := ps.buildRequest()
+		jsonMessages := req.records["json"][0].MsgSet.Messages
+		if len(jsonMessages) != 1 || jsonMessages[0].Msg.Codec != CompressionGZIP {
+			t.Errorf("expected a single gzip compressed message for json, got %d messages", len(jsonMessages))
+		}
+		binaryMessages := req.records["binary"][0].MsgSet.Messages
+		if len(binaryMessages) != 3 {
+			t.Fatalf("expected 3 uncompressed messages for binary, got %d", len(binaryMessages))
+		}
+		for _, msgBlock := range binaryMessages {
+			if msgBlock.Msg.Codec != CompressionNone {
+				t.Errorf("expected binary to be uncompressed, got %s", msgBlock.Msg.Codec)
+			}
+		}
+	})
+}
+
 func TestProduceSetV3RequestBuilding(t *testing.T) {
 	parent, ps := makeProduceSet()
 	parent.conf.Producer.RequiredAcks = WaitForAll
@@ -287,6 +394,70 @@ func TestProduceSetV3RequestBuilding(t *testing.T) {
 	}
 }
 
+func TestProduceSetTombstones(t *testing.T) {
+	parent, ps := makeProduceSet()
+	parent.conf.Producer.RequiredAcks = WaitForAll
+	parent.conf.Version = V0_11_0_0
+
+	safeAddMessage(t, ps, &ProducerMessage{Topic: "t1", Partition: 0, Key: StringEncoder("deleted")})
+	safeAddMessage(t, ps, &ProducerMessage{Topic: "t1", Partition: 0, Key: StringEncoder("empty"), Value: ByteEncoder{}})
+
+	req := ps.buildRequest()
+	packet, err := encode(req, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := new(ProduceRequest)
+	if err := versionedDecode(packet, decoded, req.Version, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	records := decoded.records["t1"][0].RecordBatch.Records
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+	if records[0].Value != nil {
+		t.Errorf("Expected a null value for the tombstone, got %q", records[0].Value)
+	}
+	if records[1].Value == nil || len(records[1].Value) != 0 {
+		t.Errorf("Expected an empty value, got %#v", records[1].Value)
+	}
+}
+
+func TestProduceSetTimestampType(t *testing.T) {
+	for _, version := range []KafkaVersion{V0_10_0_0, V0_11_0_0} {
+		for _, timestampType := range []TimestampType{CreateTime, LogAppendTime} {
+			parent, ps := makeProduceSet()
+			parent.conf.Producer.RequiredAcks = WaitForAll
+			parent.conf.Producer.TimestampType = timestampType
+			parent.conf.Version = version
+
+			safeAddMessage(t, ps, &ProducerMessage{Topic: "t1", Partition: 0, Value: StringEncoder("value")})
+
+			req := ps.buildRequest()
+			packet, err := encode(req, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded := new(ProduceRequest)
+			if err := versionedDecode(packet, decoded, req.Version, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			records := decoded.records["t1"][0]
+			var logAppendTime bool
+			if version.IsAtLeast(V0_11_0_0) {
+				logAppendTime = records.RecordBatch.LogAppendTime
+			} else {
+				logAppendTime = records.MsgSet.Messages[0].Msg.LogAppendTime
+			}
+			if logAppendTime != (timestampType == LogAppendTime) {
+				t.Errorf("Expected %s timestamps with version %s, got LogAppendTime %t", timestampType, version, logAppendTime)
+			}
+		}
+	}
+}
+
 func TestProduceSetIdempotentRequestBuilding(t *testing.T) {
 	const pID = 1000
 	const pEpoch = 1234
@@ -431,3 +602,69 @@ func TestProduceSetConsistentTimestamps(t *testing.T) {
 		t.Errorf("Message timestamps do not match: %v, %v", time1, time2)
 	}
 }
+
+func TestEstimateRecordBatchSize(t *testing.T) {
+	now := time.Now().Truncate(time.Millisecond)
+	msgs := make([]*ProducerMessage, 10)
+	for i := range msgs {
+		msgs[i] = &ProducerMessage{
+			Topic:     "my_topic",
+			Key:       StringEncoder(fmt.Sprintf("key-%d", i)),
+			Value:     StringEncoder(strings.Repeat(TestMessage, i+1)),
+			Headers:   []RecordHeader{{Key: []byte("trace"), Value: []byte(fmt.Sprintf("%08d", i))}},
+			Timestamp: now.Add(time.Duration(i) * time.Second),
+		}
+	}
+
+	for _, d := range []struct {
+		codec   CompressionCodec
+		version KafkaVersion
+	}{
+		{CompressionNone, V0_8_2_0},
+		{CompressionGZIP, V0_8_2_0},
+		{CompressionSnappy, V0_10_0_0},
+		{CompressionNone, V0_11_0_0},
+		{CompressionLZ4, V1_0_0_0},
+		{CompressionZSTD, V2_1_0_0},
+		{CompressionGZIP, V2_4_0_0},
+	} {
+		t.Run(fmt.Sprintf("%s %s", d.codec, d.version), func(t *testing.T) {
+			estimate, err := EstimateRecordBatchSize(msgs, d.codec, d.version)
+			require.NoError(t, err)
+
+			parent, ps := makeProduceSet()
+			parent.conf.Version = d.version
+			parent.conf.Producer.Compression = d.codec
+			for _, msg := range msgs {
+				require.NoError(t, ps.add(msg))
+			}
+			req := ps.buildRequest()
+			withRecords, err := encode(req, nil)
+			require.NoError(t, err)
+			// the rest of the request is the same without the records
+			req.records["my_topic"][0] = Records{}
+			withoutRecords, err := encode(req, nil)
+			require.NoError(t, err)
+
+			require.Equal(t, len(withRecords)-len(withoutRecords), estimate)
+		})
+	}
+}
+
+func TestEstimateRecordBatchSizeEdgeCases(t *testing.T) {
+	size, err := EstimateRecordBatchSize(nil, CompressionNone, V2_1_0_0)
+	require.NoError(t, err)
+	require.Zero(t, size)
+
+	msg := &ProducerMessage{Value: StringEncoder(TestMessage)}
+	_, err = EstimateRecordBatchSize([]*ProducerMessage{msg}, CompressionZSTD, V2_0_0_0)
+	require.ErrorAs(t, err, new(ConfigurationError))
+
+	// messages of several partitions still end up in a single batch
+	single, err := EstimateRecordBatchSize([]*ProducerMessage{msg, msg}, CompressionNone, V2_1_0_0)
+	require.NoError(t, err)
+	other := &ProducerMessage{Topic: "other", Partition: 3, Value: StringEncoder(TestMessage)}
+	spread, err := EstimateRecordBatchSize([]*ProducerMessage{msg, other}, CompressionNone, V2_1_0_0)
+	require.NoError(t, err)
+	require.Equal(t, single, spread)
+}
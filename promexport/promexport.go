@@ -0,0 +1,225 @@
+/*
+Package promexport exposes the metrics Sarama records in its go-metrics
+registry (see Config.MetricRegistry) in the Prometheus text exposition format,
+so that they can be scraped without a go-metrics to Prometheus bridge.
+
+Metric names are prefixed with a namespace and have '-' replaced by '_'. The
+suffixes Sarama appends to the name of a metric become labels:
+
+	-for-broker-<broker-id>      broker="<broker-id>"
+	-for-topic-<topic>           topic="<topic>" ('.' in topic names are reported as '_')
+	-partition-<partition>       partition="<partition>"
+	protocol-requests-rate-<key> api_key="<key>"
+	consumer-group-*-<group-id>  group="<group-id>"
+
+so that e.g. record-send-rate-for-topic-orders is exported as
+sarama_record_send_rate_total{topic="orders"}.
+
+go-metrics types map to Prometheus types as follows:
+
+	meter      counter  <name>_total, the number of events marked
+	counter    gauge    go-metrics counters can be decremented (e.g. requests-in-flight)
+	gauge      gauge
+	histogram  summary  the 0.5, 0.75, 0.95, 0.99 and 0.999 quantiles, and <name>_count
+
+The summary quantiles are the percentiles of the histogram's exponentially
+decaying sample, which is biased to the last 5 minutes of measurements, rather
+than of every observation. <name>_count is the total number of observations.
+No <name>_sum is exported as go-metrics only sums the sampled values.
+
+Per-broker metrics are unregistered when the connection to the broker is
+closed, so they stop being exported along with it.
+
+NOTE: this package currently does not fall under the API stability
+guarantee of Sarama as it is still considered experimental.
+*/
+package promexport
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rcrowley/go-metrics"
+)
+
+// DefaultNamespace is the prefix of the exported metric names used by Handler.
+const DefaultNamespace = "sarama"
+
+// Quantiles are the summary objectives exported for every histogram.
+var Quantiles = []float64{0.5, 0.75, 0.95, 0.99, 0.999}
+
+var groupMetricPrefixes = []string{
+	"consumer-group-join-total-",
+	"consumer-group-join-failed-",
+	"consumer-group-sync-total-",
+	"consumer-group-sync-failed-",
+}
+
+// Handler returns an http.Handler serving the metrics of registry under the
+// DefaultNamespace.
+func Handler(registry metrics.Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		var buf bytes.Buffer
+		if err := Write(&buf, registry, DefaultNamespace); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = buf.WriteTo(w)
+	})
+}
+
+// Write writes the metrics of registry to w in the Prometheus text exposition
+// format, prefixing their names with namespace.
+func Write(w io.Writer, registry metrics.Registry, namespace string) error {
+	families := map[string]*family{}
+	add := func(name, typ string, s series) {
+		f, ok := families[name]
+		if !ok {
+			f = &family{typ: typ}
+			families[name] = f
+		} else if f.typ != typ {
+			// a metric of another type already uses this name
+			return
+		}
+		f.series = append(f.series, s)
+	}
+
+	registry.Each(func(name string, i interface{}) {
+		base, labels := parseName(name)
+		base = sanitizeName(namespace + "_" + base)
+
+		switch m := i.(type) {
+		case metrics.Meter:
+			add(base+"_total", "counter", series{labels: labels, value: float64(m.Snapshot().Count())})
+		case metrics.Counter:
+			add(base, "gauge", series{labels: labels, value: float64(m.Snapshot().Count())})
+		case metrics.Gauge:
+			add(base, "gauge", series{labels: labels, value: float64(m.Snapshot().Value())})
+		case metrics.GaugeFloat64:
+			add(base, "gauge", series{labels: labels, value: m.Snapshot().Value()})
+		case metrics.Histogram:
+			h := m.Snapshot()
+			add(base, "summary", series{labels: labels, quantiles: h.Percentiles(Quantiles), count: h.Count()})
+		}
+	})
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	for _, name := range names {
+		f := families[name]
+		sort.Slice(f.series, func(i, j int) bool {
+			return formatLabels(f.series[i].labels) < formatLabels(f.series[j].labels)
+		})
+
+		bw.WriteString("# TYPE " + name + " " + f.typ + "\n")
+		for _, s := range f.series {
+			if f.typ != "summary" {
+				bw.WriteString(name + formatLabels(s.labels) + " " + formatValue(s.value) + "\n")
+				continue
+			}
+			for i, q := range Quantiles {
+				labels := append(append([]label{}, s.labels...), label{"quantile", formatValue(q)})
+				bw.WriteString(name + formatLabels(labels) + " " + formatValue(s.quantiles[i]) + "\n")
+			}
+			bw.WriteString(name + "_count" + formatLabels(s.labels) + " " + strconv.FormatInt(s.count, 10) + "\n")
+		}
+	}
+	return bw.Flush()
+}
+
+type label struct {
+	name, value string
+}
+
+type series struct {
+	labels    []label
+	value     float64
+	quantiles []float64
+	count     int64
+}
+
+type family struct {
+	typ    string
+	series []series
+}
+
+// parseName splits the labels Sarama encodes in the suffixes of a metric name
+// from its base name.
+func parseName(name string) (string, []label) {
+	for _, prefix := range groupMetricPrefixes {
+		if group, ok := strings.CutPrefix(name, prefix); ok {
+			return strings.TrimSuffix(prefix, "-"), []label{{"group", group}}
+		}
+	}
+
+	var labels []label
+	if i := strings.LastIndex(name, "-for-broker-"); i >= 0 {
+		labels = append(labels, label{"broker", name[i+len("-for-broker-"):]})
+		name = name[:i]
+	}
+	if i := strings.Index(name, "-for-topic-"); i >= 0 {
+		topic := name[i+len("-for-topic-"):]
+		name = name[:i]
+		if j := strings.LastIndex(topic, "-partition-"); j >= 0 && isNumber(topic[j+len("-partition-"):]) {
+			labels = append(labels, label{"partition", topic[j+len("-partition-"):]})
+			topic = topic[:j]
+		}
+		labels = append(labels, label{"topic", topic})
+	}
+	if key, ok := strings.CutPrefix(name, "protocol-requests-rate-"); ok && isNumber(key) {
+		labels = append(labels, label{"api_key", key})
+		name = "protocol-requests-rate"
+	}
+
+	sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+	return name, labels
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.ParseInt(s, 10, 64)
+	return err == nil
+}
+
+// sanitizeName replaces the characters that are not valid in a Prometheus
+// metric name with '_'.
+func sanitizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r == ':' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+var labelValueEscaper = strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n")
+
+func formatLabels(labels []label) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, l := range labels {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(l.name + "=\"" + labelValueEscaper.Replace(l.value) + "\"")
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,115 @@
+//go:build !functional
+
+package promexport
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rcrowley/go-metrics"
+
+	"github.com/IBM/sarama"
+)
+
+func TestWrite(t *testing.T) {
+	registry := metrics.NewRegistry()
+	metrics.GetOrRegisterMeter("record-send-rate", registry).Mark(3)
+	metrics.GetOrRegisterMeter("record-send-rate-for-topic-my_topic", registry).Mark(2)
+	metrics.GetOrRegisterCounter("requests-in-flight-for-broker-1", registry).Inc(4)
+	metrics.GetOrRegisterGauge("consumer-lag-for-topic-my_topic-partition-0", registry).Update(7)
+	metrics.GetOrRegisterCounter("consumer-group-join-total-my-group", registry).Inc(1)
+	metrics.GetOrRegisterMeter("protocol-requests-rate-3-for-broker-1", registry).Mark(1)
+	histogram := metrics.GetOrRegisterHistogram("request-latency-in-ms", registry, metrics.NewUniformSample(10))
+	for _, v := range []int64{1, 2, 3, 4} {
+		histogram.Update(v)
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, registry, "sarama"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# TYPE sarama_consumer_group_join_total gauge
+sarama_consumer_group_join_total{group="my-group"} 1
+# TYPE sarama_consumer_lag gauge
+sarama_consumer_lag{partition="0",topic="my_topic"} 7
+# TYPE sarama_protocol_requests_rate_total counter
+sarama_protocol_requests_rate_total{api_key="3",broker="1"} 1
+# TYPE sarama_record_send_rate_total counter
+sarama_record_send_rate_total 3
+sarama_record_send_rate_total{topic="my_topic"} 2
+# TYPE sarama_request_latency_in_ms summary
+sarama_request_latency_in_ms{quantile="0.5"} 2.5
+sarama_request_latency_in_ms{quantile="0.75"} 3.75
+sarama_request_latency_in_ms{quantile="0.95"} 4
+sarama_request_latency_in_ms{quantile="0.99"} 4
+sarama_request_latency_in_ms{quantile="0.999"} 4
+sarama_request_latency_in_ms_count 4
+# TYPE sarama_requests_in_flight gauge
+sarama_requests_in_flight{broker="1"} 4
+`
+	if buf.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestWriteEscapesLabelValues(t *testing.T) {
+	registry := metrics.NewRegistry()
+	metrics.GetOrRegisterCounter(`consumer-group-sync-failed-a"b\c`, registry).Inc(1)
+
+	var buf bytes.Buffer
+	if err := Write(&buf, registry, "sarama"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `sarama_consumer_group_sync_failed{group="a\"b\\c"} 1`) {
+		t.Errorf("expected the group label to be escaped, got:\n%s", buf.String())
+	}
+}
+
+func TestHandlerDropsClosedBrokerMetrics(t *testing.T) {
+	seedBroker := sarama.NewMockBroker(t, 1)
+	defer seedBroker.Close()
+	seedBroker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(seedBroker.Addr(), seedBroker.BrokerID()),
+	})
+
+	config := sarama.NewConfig()
+	config.ApiVersionsRequest = false
+	config.MetricRegistry = metrics.NewRegistry()
+	client, err := sarama.NewClient([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+
+	broker := client.Brokers()[0]
+	if err := broker.Open(config); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := broker.GetMetadata(&sarama.MetadataRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	scrape := func() string {
+		rec := httptest.NewRecorder()
+		Handler(config.MetricRegistry).ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+			t.Errorf("unexpected content type %q", ct)
+		}
+		return rec.Body.String()
+	}
+
+	if body := scrape(); !strings.Contains(body, `sarama_request_rate_total{broker="1"} 1`) {
+		t.Fatalf("expected per-broker metrics to be exported, got:\n%s", body)
+	}
+
+	if err := broker.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if body := scrape(); strings.Contains(body, `broker="1"`) {
+		t.Errorf("expected per-broker metrics to be removed once the broker is closed, got:\n%s", body)
+	}
+}
@@ -19,3 +19,10 @@ const (
 	QuotaMatchDefault
 	QuotaMatchAny
 )
+
+// ref: https://github.com/apache/kafka/blob/trunk/clients/src/main/java/org/apache/kafka/common/config/internals/QuotaConfigs.java
+const (
+	QuotaKeyProducerByteRate  = "producer_byte_rate"
+	QuotaKeyConsumerByteRate  = "consumer_byte_rate"
+	QuotaKeyRequestPercentage = "request_percentage"
+)
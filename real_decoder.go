@@ -555,6 +555,28 @@ func (rd *realFlexibleDecoder) getInt32Array() ([]int32, error) {
 	return ret, nil
 }
 
+func (rd *realFlexibleDecoder) getInt64Array() ([]int64, error) {
+	n, err := rd.getArrayLength()
+	if err != nil {
+		return nil, err
+	}
+	if n <= 0 {
+		return nil, nil
+	}
+
+	if rd.remaining()/8 < n {
+		rd.off = len(rd.raw)
+		return nil, ErrInsufficientData
+	}
+
+	ret := make([]int64, n)
+	for i := range ret {
+		ret[i] = int64(binary.BigEndian.Uint64(rd.raw[rd.off:]))
+		rd.off += 8
+	}
+	return ret, nil
+}
+
 func (rd *realFlexibleDecoder) getStringArray() ([]string, error) {
 	n, err := rd.getArrayLength()
 	if err != nil {
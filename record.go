@@ -18,7 +18,12 @@ type RecordHeader struct {
 }
 
 func (h *RecordHeader) encode(pe packetEncoder) error {
-	if err := pe.putVarintBytes(h.Key); err != nil {
+	key := h.Key
+	if key == nil {
+		// header keys are not nullable, unlike values
+		key = []byte{}
+	}
+	if err := pe.putVarintBytes(key); err != nil {
 		return err
 	}
 	return pe.putVarintBytes(h.Value)
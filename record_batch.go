@@ -48,8 +48,15 @@ type RecordBatch struct {
 	PartialTrailingRecord bool
 	IsTransactional       bool
 
-	compressedRecords []byte
-	recordsLen        int // uncompressed records size
+	compressedRecords    []byte
+	recordsLen           int // uncompressed records size
+	compressedRecordsLen int // compressed records size, set when decoding compressed records
+	// deferDecompression makes decode keep the compressed records in
+	// pendingRecords, to be decoded later by decodeRecords.
+	deferDecompression bool
+	pendingRecords     []byte
+	// zstdDict is the dictionary zstd compressed records are compressed with
+	zstdDict *zstdDictionary
 	// partialSize is the total on-wire size of this batch (FirstOffset + length
 	// field + body) when PartialTrailingRecord is true and the partial state was
 	// caused by truncated bytes. Zero otherwise.
@@ -186,7 +193,29 @@ func (b *RecordBatch) decode(pd packetDecoder) (err error) {
 		return err
 	}
 
-	recBuffer, err = decompress(b.Codec, recBuffer)
+	if b.deferDecompression && b.Codec != CompressionNone {
+		b.pendingRecords = recBuffer
+		return nil
+	}
+	return b.decodeRecordBuffer(recBuffer)
+}
+
+// decodeRecords decompresses and decodes the records of a batch whose
+// decompression was deferred. It does nothing for other batches.
+func (b *RecordBatch) decodeRecords() error {
+	if b.pendingRecords == nil {
+		return nil
+	}
+	recBuffer := b.pendingRecords
+	b.pendingRecords = nil
+	return b.decodeRecordBuffer(recBuffer)
+}
+
+func (b *RecordBatch) decodeRecordBuffer(recBuffer []byte) (err error) {
+	if b.Codec != CompressionNone {
+		b.compressedRecordsLen = len(recBuffer)
+	}
+	recBuffer, err = decompress(b.Codec, b.zstdDict, recBuffer)
 	if err != nil {
 		return err
 	}
@@ -209,7 +238,7 @@ func (b *RecordBatch) encodeRecords(pe packetEncoder) error {
 	}
 	b.recordsLen = len(raw)
 
-	b.compressedRecords, err = compress(b.Codec, b.CompressionLevel, raw)
+	b.compressedRecords, err = compress(b.Codec, b.CompressionLevel, b.zstdDict, raw)
 	return err
 }
 
@@ -73,6 +73,31 @@ func recordBatchTestCases() []struct {
 				0, 0, 0, 0, // Number of Records
 			},
 		},
+		{
+			name: "log append time batch",
+			batch: RecordBatch{
+				Version:        2,
+				LogAppendTime:  true,
+				FirstTimestamp: time.Unix(0, 0),
+				MaxTimestamp:   time.Unix(0, 0),
+				Records:        []*Record{},
+			},
+			encoded: []byte{
+				0, 0, 0, 0, 0, 0, 0, 0, // First Offset
+				0, 0, 0, 49, // Length
+				0, 0, 0, 0, // Partition Leader Epoch
+				2,                // Version
+				91, 67, 202, 220, // CRC
+				0, 8, // Attributes
+				0, 0, 0, 0, // Last Offset Delta
+				0, 0, 0, 0, 0, 0, 0, 0, // First Timestamp
+				0, 0, 0, 0, 0, 0, 0, 0, // Max Timestamp
+				0, 0, 0, 0, 0, 0, 0, 0, // Producer ID
+				0, 0, // Producer Epoch
+				0, 0, 0, 0, // First Sequence
+				0, 0, 0, 0, // Number of Records
+			},
+		},
 		{
 			name: "uncompressed record",
 			batch: RecordBatch{
@@ -256,6 +281,8 @@ func TestRecordBatchDecoding(t *testing.T) {
 		// The compression level is not restored on decoding. It is not needed
 		// anyway. We only set it here to ensure that comparison succeeds.
 		batch.CompressionLevel = tc.batch.CompressionLevel
+		// The compressed size is only recorded on decoding, for the metrics.
+		batch.compressedRecordsLen = 0
 		if !reflect.DeepEqual(batch, tc.batch) {
 			t.Error(spew.Sprintf("invalid decode of %s\ngot %+v\nwanted %+v", tc.name, batch, tc.batch))
 		}
@@ -308,3 +335,46 @@ func TestRecordBatchLargeNumRecords(t *testing.T) {
 		t.Fatal("received error while decoding record batch", err)
 	}
 }
+
+func TestRecordHeadersBinaryKeys(t *testing.T) {
+	binaryKey := []byte{0xff, 0x00, 0xfe}
+	msg := &ProducerMessage{}
+	msg.SetHeader(binaryKey, []byte("first"))
+	msg.SetHeader([]byte("trace"), []byte("abc"))
+	msg.SetHeader(binaryKey, []byte{0x00})
+	msg.SetHeader(nil, nil)
+
+	record := &Record{}
+	for i := range msg.Headers {
+		record.Headers = append(record.Headers, &msg.Headers[i])
+	}
+	buf, err := encode(&RecordBatch{Version: 2, Records: []*Record{record}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := RecordBatch{}
+	if err := decode(buf, &batch, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	consumed := &ConsumerMessage{Headers: batch.Records[0].Headers}
+	if len(consumed.Headers) != 4 {
+		t.Fatalf("expected the 4 headers to be kept, got %d", len(consumed.Headers))
+	}
+	if value, ok := consumed.Header(binaryKey); !ok || !reflect.DeepEqual(value, []byte{0x00}) {
+		t.Errorf("expected the last value of the repeated binary key, got %v %v", value, ok)
+	}
+	if value, ok := consumed.Header([]byte("trace")); !ok || string(value) != "abc" {
+		t.Errorf("unexpected trace header %q %v", value, ok)
+	}
+	// a nil key is sent as an empty one, header keys are not nullable
+	if value, ok := consumed.Header([]byte{}); !ok || value != nil || consumed.Headers[3].Key == nil {
+		t.Errorf("unexpected empty key header %v %v", value, ok)
+	}
+	if _, ok := consumed.Header([]byte{0xff}); ok {
+		t.Error("expected no header for a prefix of a key")
+	}
+	if value, ok := msg.Header(binaryKey); !ok || !reflect.DeepEqual(value, []byte{0x00}) {
+		t.Errorf("expected the producer message to return the last value, got %v %v", value, ok)
+	}
+}
@@ -15,6 +15,10 @@ type Records struct {
 	recordsType int
 	MsgSet      *MessageSet
 	RecordBatch *RecordBatch
+
+	// deferDecompression and zstdDict are passed on to the decoded RecordBatch
+	deferDecompression bool
+	zstdDict           *zstdDictionary
 }
 
 func newLegacyRecords(msgSet *MessageSet) Records {
@@ -91,7 +95,7 @@ func (r *Records) decode(pd packetDecoder) error {
 		r.MsgSet = &MessageSet{}
 		return r.MsgSet.decode(pd)
 	case defaultRecords:
-		r.RecordBatch = &RecordBatch{}
+		r.RecordBatch = &RecordBatch{deferDecompression: r.deferDecompression, zstdDict: r.zstdDict}
 		return r.RecordBatch.decode(pd)
 	}
 	return fmt.Errorf("unknown records type: %v", r.recordsType)
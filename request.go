@@ -166,7 +166,8 @@ func allocateBody(key, version int16) protocolBody {
 		return &DeleteRecordsRequest{Version: version}
 	case apiKeyInitProducerId:
 		return &InitProducerIDRequest{Version: version}
-	// 23: OffsetForLeaderEpochRequest
+	case apiKeyOffsetForLeaderEpoch:
+		return &OffsetForLeaderEpochRequest{Version: version}
 	case apiKeyAddPartitionsToTxn:
 		return &AddPartitionsToTxnRequest{Version: version}
 	case apiKeyAddOffsetsToTxn:
@@ -228,14 +229,18 @@ func allocateBody(key, version int16) protocolBody {
 		// 58: EnvelopeRequest
 		// 59: FetchSnapshotRequest
 		// 60: DescribeClusterRequest
-		// 61: DescribeProducersRequest
 		// 62: BrokerRegistrationRequest
 		// 63: BrokerHeartbeatRequest
 		// 64: UnregisterBrokerRequest
-		// 65: DescribeTransactionsRequest
-		// 66: ListTransactionsRequest
 		// 67: AllocateProducerIdsRequest
-		// 68: ConsumerGroupHeartbeatRequest
+	case apiKeyDescribeProducers:
+		return &DescribeProducersRequest{Version: version}
+	case apiKeyDescribeTransactions:
+		return &DescribeTransactionsRequest{Version: version}
+	case apiKeyListTransactions:
+		return &ListTransactionsRequest{Version: version}
+	case apiKeyConsumerGroupHeartbeat:
+		return &ConsumerGroupHeartbeatRequest{Version: version}
 	}
 	return nil
 }
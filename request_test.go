@@ -75,14 +75,14 @@ var names = map[int16]string{
 	58:                                 "EnvelopeRequest",
 	59:                                 "FetchSnapshotRequest",
 	apiKeyDescribeCluster:              "DescribeClusterRequest",
-	61:                                 "DescribeProducersRequest",
+	apiKeyDescribeProducers:            "DescribeProducersRequest",
 	62:                                 "BrokerRegistrationRequest",
 	63:                                 "BrokerHeartbeatRequest",
 	64:                                 "UnregisterBrokerRequest",
-	65:                                 "DescribeTransactionsRequest",
-	66:                                 "ListTransactionsRequest",
+	apiKeyDescribeTransactions:         "DescribeTransactionsRequest",
+	apiKeyListTransactions:             "ListTransactionsRequest",
 	67:                                 "AllocateProducerIdsRequest",
-	68:                                 "ConsumerGroupHeartbeatRequest",
+	apiKeyConsumerGroupHeartbeat:       "ConsumerGroupHeartbeatRequest",
 }
 
 // allocateResponseBody is a test-only clone of allocateBody. There's no
@@ -131,6 +131,8 @@ func allocateResponseBody(req protocolBody) protocolBody {
 		return &DeleteRecordsResponse{Version: version}
 	case apiKeyInitProducerId:
 		return &InitProducerIDResponse{Version: version}
+	case apiKeyOffsetForLeaderEpoch:
+		return &OffsetForLeaderEpochResponse{Version: version}
 	case apiKeyAddPartitionsToTxn:
 		return &AddPartitionsToTxnResponse{Version: version}
 	case apiKeyAddOffsetsToTxn:
@@ -177,6 +179,14 @@ func allocateResponseBody(req protocolBody) protocolBody {
 		return &AlterUserScramCredentialsResponse{Version: version}
 	case apiKeyDescribeCluster:
 		return &DescribeClusterResponse{Version: version}
+	case apiKeyDescribeProducers:
+		return &DescribeProducersResponse{Version: version}
+	case apiKeyDescribeTransactions:
+		return &DescribeTransactionsResponse{Version: version}
+	case apiKeyListTransactions:
+		return &ListTransactionsResponse{Version: version}
+	case apiKeyConsumerGroupHeartbeat:
+		return &ConsumerGroupHeartbeatResponse{Version: version}
 	}
 	return nil
 }
@@ -335,6 +345,7 @@ func TestAllocateBodyProtocolVersions(t *testing.T) {
 				apiKeyHeartbeat:               3,  // up from 2
 				apiKeySyncGroup:               3,  // up from 2
 				apiKeyDescribeGroups:          3,  // up from 2
+				apiKeyOffsetForLeaderEpoch:    3,  // up from 2
 				apiKeyIncrementalAlterConfigs: 0,  // new in 2.3
 			},
 		},
@@ -380,8 +391,7 @@ func TestAllocateBodyProtocolVersions(t *testing.T) {
 				apiKeySASLAuth:         2, // up from 1
 				apiKeyCreatePartitions: 2, // up from 1
 				apiKeySyncGroup:        5, // up from 4
-				// TODO: TxnOffsetCommitRequest v3 is not supported, but expected for KafkaVersion 2.5.0
-				// apiKeyTxnOffsetCommit:         3, // up from 2
+				apiKeyTxnOffsetCommit:  3, // up from 2
 			},
 		},
 		{
@@ -400,14 +410,13 @@ func TestAllocateBodyProtocolVersions(t *testing.T) {
 		{
 			V2_7_0_0,
 			map[int16]int16{
-				apiKeyInitProducerId:               4, // up from 3
-				apiKeyAddPartitionsToTxn:           2, // up from 1
-				apiKeyAddOffsetsToTxn:              2, // up from 1
-				apiKeyEndTxn:                       2, // up from 1
-				apiKeyDescribeUserScramCredentials: 0, // new in 2.7
-				apiKeyAlterUserScramCredentials:    0, // new in 2.7
-				// TODO: FetchRequest v12 is not supported, but expected for KafkaVersion 2.7.0
-				// apiKeyFetch:            12, // up from 11
+				apiKeyInitProducerId:               4,  // up from 3
+				apiKeyAddPartitionsToTxn:           2,  // up from 1
+				apiKeyAddOffsetsToTxn:              2,  // up from 1
+				apiKeyEndTxn:                       2,  // up from 1
+				apiKeyDescribeUserScramCredentials: 0,  // new in 2.7
+				apiKeyAlterUserScramCredentials:    0,  // new in 2.7
+				apiKeyFetch:                        12, // up from 11
 				// TODO: CreateTopicsRequest v6 is not supported, but expected for KafkaVersion 2.7.0
 				// apiKeyCreateTopics:     6, // up from 5
 				// TODO: DeleteTopicsRequest v5 is not supported, but expected for KafkaVersion 2.7.0
@@ -421,37 +430,35 @@ func TestAllocateBodyProtocolVersions(t *testing.T) {
 			map[int16]int16{
 				apiKeyMetadata:             10, // up from 9
 				apiKeyDescribeClientQuotas: 1,  // up from 0
+				apiKeyAlterClientQuotas:    1,  // up from 0
 				apiKeyDescribeCluster:      0,  // new in 2.8
+				apiKeyDescribeProducers:    0,  // new in 2.8
+				apiKeyAddPartitionsToTxn:   3,  // up from 2
+				apiKeyListOffsets:          6,  // up from 5
+				apiKeyOffsetForLeaderEpoch: 4,  // up from 3
 				// TODO: ProduceRequest v9 is not supported, but expected for KafkaVersion 2.8.0
 				// apiKeyProduce:              9, // up from 8
-				// TODO: ListOffsetsRequest v6 is not supported, but expected for KafkaVersion 2.8.0
-				// apiKeyListOffsets:          6, // up from 5
 				// TODO: MetadataRequest v11 is not supported, but expected for KafkaVersion 2.8.0
 				// apiKeyMetadata:             11, // up from 9
-				// TODO: AddPartitionsToTxnRequest v3 is not supported, but expected for KafkaVersion 2.8.0
-				// apiKeyAddPartitionsToTxn:   3, // up from 2
 				// TODO: AddOffsetsToTxnRequest v3 is not supported, but expected for KafkaVersion 2.8.0
 				// apiKeyAddOffsetsToTxn:      3, // up from 2
 				// TODO: EndTxnRequest v3 is not supported, but expected for KafkaVersion 2.8.0
 				// apiKeyEndTxn:               3, // up from 2
 				// TODO: AlterConfigsRequest v2 is not supported, but expected for KafkaVersion 2.8.0
 				// apiKeyAlterConfigs:         2, // up from 1
-				// TODO: AlterClientQuotasRequest v1 is not supported, but expected for KafkaVersion 2.8.0
-				// apiKeyAlterClientQuotas:    1, // up from 0
 				// TODO: CreateTopicsRequest v7 is not supported, but expected for KafkaVersion 2.8.0
 				// apiKeyCreateTopics:         7, // up from 6
 				// TODO: DeleteTopicsRequest v6 is not supported, but expected for KafkaVersion 2.8.0
 				// apiKeyDeleteTopics:         6, // up from 5
-				// TODO: DescribeProducersRequest v0 is not supported, but expected for KafkaVersion 2.8.0
-				// apiKeyDescribeProducers /* (61) */: 0, // new in 2.8
 			},
 		},
 		{
 			V3_0_0_0,
 			map[int16]int16{
-				apiKeyOffsetFetch: 8, // up from 7
-				// TODO: ListOffsetsRequest v7 is not supported, but expected for KafkaVersion 3.0.0
-				// apiKeyListOffsets:     7, // up from 6
+				apiKeyOffsetFetch:          8, // up from 7
+				apiKeyListOffsets:          7, // up from 6
+				apiKeyDescribeTransactions: 0, // new in 3.0
+				apiKeyListTransactions:     0, // new in 3.0
 				// TODO: FindCoordinatorRequest v4 is not supported, but expected for KafkaVersion 3.0.0
 				// apiKeyFindCoordinator: 4, // up from 3
 			},
@@ -459,8 +466,7 @@ func TestAllocateBodyProtocolVersions(t *testing.T) {
 		{
 			V3_1_0_0,
 			map[int16]int16{
-				// TODO: FetchRequest v13 is not supported, but expected for KafkaVersion 3.1.0
-				// apiKeyFetch:    13, // up from 12
+				apiKeyFetch: 13, // up from 12
 				// TODO: MetadataRequest v12 is not supported, but expected for KafkaVersion 3.1.0
 				// apiKeyMetadata: 12, // up from 11
 			},
@@ -487,10 +493,24 @@ func TestAllocateBodyProtocolVersions(t *testing.T) {
 				// apiKeyDeleteAcls:   3, // up from 2
 			},
 		},
+		{
+			V3_5_0_0,
+			map[int16]int16{
+				apiKeyFetch: 15, // up from 13
+			},
+		},
+		{
+			V3_6_0_0,
+			map[int16]int16{
+				apiKeyAddPartitionsToTxn: 4, // up from 3
+			},
+		},
 		{
 			V3_7_0_0,
 			map[int16]int16{
-				apiKeyDescribeCluster: 1, // up from 0
+				apiKeyFetch:           16, // up from 15
+				apiKeyOffsetCommit:    9,  // up from 8
+				apiKeyDescribeCluster: 1,  // up from 0
 			},
 		},
 		{
@@ -502,7 +522,8 @@ func TestAllocateBodyProtocolVersions(t *testing.T) {
 		{
 			V4_0_0_0,
 			map[int16]int16{
-				apiKeyDescribeCluster: 2, // up from 1
+				apiKeyDescribeCluster:        2, // up from 1
+				apiKeyConsumerGroupHeartbeat: 0, // GA in 4.0
 			},
 		},
 		{
@@ -551,6 +572,7 @@ func TestAllocateBodyProtocolVersions(t *testing.T) {
 				apiKeyDeleteTopics:                 maxVersion(&DeleteTopicsRequest{}),
 				apiKeyDeleteRecords:                maxVersion(&DeleteRecordsRequest{}),
 				apiKeyInitProducerId:               maxVersion(&InitProducerIDRequest{}),
+				apiKeyOffsetForLeaderEpoch:         maxVersion(&OffsetForLeaderEpochRequest{}),
 				apiKeyAddPartitionsToTxn:           maxVersion(&AddPartitionsToTxnRequest{}),
 				apiKeyAddOffsetsToTxn:              maxVersion(&AddOffsetsToTxnRequest{}),
 				apiKeyEndTxn:                       maxVersion(&EndTxnRequest{}),
@@ -574,6 +596,10 @@ func TestAllocateBodyProtocolVersions(t *testing.T) {
 				apiKeyDescribeUserScramCredentials: maxVersion(&DescribeUserScramCredentialsRequest{}),
 				apiKeyAlterUserScramCredentials:    maxVersion(&AlterUserScramCredentialsRequest{}),
 				apiKeyDescribeCluster:              maxVersion(&DescribeClusterRequest{}),
+				apiKeyDescribeProducers:            maxVersion(&DescribeProducersRequest{}),
+				apiKeyDescribeTransactions:         maxVersion(&DescribeTransactionsRequest{}),
+				apiKeyListTransactions:             maxVersion(&ListTransactionsRequest{}),
+				apiKeyConsumerGroupHeartbeat:       maxVersion(&ConsumerGroupHeartbeatRequest{}),
 			},
 		},
 	}
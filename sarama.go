@@ -19,6 +19,7 @@ exactly with the protocol fields documented by Kafka at
 https://cwiki.apache.org/confluence/display/KAFKA/A+Guide+To+The+Kafka+Protocol
 
 Metrics are exposed through https://github.com/rcrowley/go-metrics library in a local registry.
+The promexport subpackage serves that registry in the Prometheus text exposition format.
 
 Broker related metrics:
 
@@ -47,6 +48,7 @@ Broker related metrics:
 	|                                                         |            | https://kafka.apache.org/protocol.html#protocol_api_keys      |                                        |
 	| protocol-requests-rate-<api-key>-for-broker-<broker-id> | meter      | Number of packets sent to the brokers by api-key for a given  |
 	|                                                         |            | broker                                                        |
+	| reaped-connections                                      | meter      | Connections closed after being idle for Net.MaxIdleTime       |
 	+---------------------------------------------------------+------------+---------------------------------------------------------------+
 
 Note that we do not gather specific metrics for seed brokers but they are part of the "all brokers" metrics.
@@ -64,23 +66,32 @@ Producer related metrics:
 	| records-per-request-for-topic-<topic>     | histogram  | Distribution of the number of records sent per request for a given topic             |
 	| compression-ratio                         | histogram  | Distribution of the compression ratio times 100 of record batches for all topics     |
 	| compression-ratio-for-topic-<topic>       | histogram  | Distribution of the compression ratio times 100 of record batches for a given topic  |
+	| producer-retry-buffer-bytes               | gauge      | Total size in bytes of the messages waiting in the retry buffer                      |
+	| producer-retry-buffer-length              | gauge      | Number of messages waiting in the retry buffer                                       |
 	+-------------------------------------------+------------+--------------------------------------------------------------------------------------+
 
 Consumer related metrics:
 
-	+-------------------------------------------+------------+--------------------------------------------------------------------------------------+
-	| Name                                      | Type       | Description                                                                          |
-	+-------------------------------------------+------------+--------------------------------------------------------------------------------------+
-	| consumer-batch-size                       | histogram  | Distribution of the number of messages in a batch                                    |
-	| consumer-fetch-rate                       | meter      | Fetch requests/second sent to all brokers                                            |
-	| consumer-fetch-rate-for-broker-<broker>   | meter      | Fetch requests/second sent to a given broker                                         |
-	| consumer-fetch-rate-for-topic-<topic>     | meter      | Fetch requests/second sent for a given topic                                         |
-	| consumer-fetch-response-size              | histogram  | Distribution of the fetch response size in bytes                                     |
-	| consumer-group-join-total-<GroupID>       | counter    | Total count of consumer group join attempts                                          |
-	| consumer-group-join-failed-<GroupID>      | counter    | Total count of consumer group join failures                                          |
-	| consumer-group-sync-total-<GroupID>       | counter    | Total count of consumer group sync attempts                                          |
-	| consumer-group-sync-failed-<GroupID>      | counter    | Total count of consumer group sync failures                                          |
-	+-------------------------------------------+------------+--------------------------------------------------------------------------------------+
+	+------------------------------------------------------+------------+--------------------------------------------------------------------------------------+
+	| Name                                                 | Type       | Description                                                                          |
+	+------------------------------------------------------+------------+--------------------------------------------------------------------------------------+
+	| consumer-batch-size                                  | histogram  | Distribution of the number of messages in a batch                                    |
+	| consumer-fetch-rate                                  | meter      | Fetch requests/second sent to all brokers                                            |
+	| consumer-fetch-rate-for-broker-<broker>              | meter      | Fetch requests/second sent to a given broker                                         |
+	| consumer-fetch-rate-for-topic-<topic>                | meter      | Fetch requests/second sent for a given topic                                         |
+	| consumer-fetch-response-size                         | histogram  | Distribution of the fetch response size in bytes                                     |
+	| fetch-response-bytes                                 | histogram  | Distribution of the fetched records size in bytes per partition for all topics       |
+	| fetch-response-bytes-for-topic-<topic>               | histogram  | Distribution of the fetched records size in bytes per partition for a given topic    |
+	| fetch-records-per-batch                              | histogram  | Distribution of the number of records per fetched batch for all topics               |
+	| fetch-records-per-batch-for-topic-<topic>            | histogram  | Distribution of the number of records per fetched batch for a given topic            |
+	| decompression-ratio                                  | histogram  | Distribution of the decompression ratio times 100 of fetched batches for all topics  |
+	| decompression-ratio-for-topic-<topic>                | histogram  | Distribution of the decompression ratio times 100 of fetched batches for a topic     |
+	| consumer-lag-for-topic-<topic>-partition-<partition> | gauge      | Messages between the next fetch offset and the high water mark for a partition       |
+	| consumer-group-join-total-<GroupID>                  | counter    | Total count of consumer group join attempts                                          |
+	| consumer-group-join-failed-<GroupID>                 | counter    | Total count of consumer group join failures                                          |
+	| consumer-group-sync-total-<GroupID>                  | counter    | Total count of consumer group sync attempts                                          |
+	| consumer-group-sync-failed-<GroupID>                 | counter    | Total count of consumer group sync failures                                          |
+	+------------------------------------------------------+------------+--------------------------------------------------------------------------------------+
 */
 package sarama
 
@@ -109,7 +120,7 @@ var (
 	// a broker returns a response message larger than this value, Sarama will return a PacketDecodingError to
 	// protect the client from running out of memory. Please note that brokers do not have any natural limit on
 	// the size of responses they send. In particular, they can send arbitrarily large fetch responses to consumers
-	// (see https://issues.apache.org/jira/browse/KAFKA-2063).
+	// (see https://issues.apache.org/jira/browse/KAFKA-2063). Config.Net.MaxResponseSize lowers it per client.
 	MaxResponseSize int32 = 100 * 1024 * 1024
 )
 
@@ -1,6 +1,9 @@
 package sarama
 
-import "sync"
+import (
+	"context"
+	"sync"
+)
 
 var expectationsPool = sync.Pool{
 	New: func() interface{} {
@@ -31,6 +34,23 @@ type SyncProducer interface {
 	// SendMessages will return an error.
 	SendMessages(msgs []*ProducerMessage) error
 
+	// SendMessageContext behaves like SendMessage, but stops waiting and returns
+	// ctx.Err() if the context is done before the message is acknowledged. A message
+	// that was already handed to the producer when the context expired stays in
+	// flight: it is still delivered or failed as usual, and a late failure is logged
+	// rather than returned since nobody is waiting for it any more. Such a message
+	// must not be modified or sent again until its outcome is known, i.e. until
+	// it shows up in the logs or the producer is closed.
+	SendMessageContext(ctx context.Context, msg *ProducerMessage) (partition int32, offset int64, err error)
+
+	// SendMessagesContext behaves like SendMessages, but stops waiting once the
+	// context is done. In that case it returns ProducerErrors holding the failures
+	// observed so far plus one entry carrying ctx.Err() for every message whose
+	// outcome is unknown; messages absent from the error have been acknowledged.
+	// As with SendMessageContext, messages whose outcome is unknown must not be
+	// reused until the producer is done with them.
+	SendMessagesContext(ctx context.Context, msgs []*ProducerMessage) error
+
 	// Close shuts down the producer; you must call this function before a producer
 	// object passes out of scope, as it may otherwise leak memory.
 	// You must call this before calling Close on the underlying client.
@@ -158,19 +178,121 @@ func (sp *syncProducer) SendMessages(msgs []*ProducerMessage) error {
 	return nil
 }
 
+func (sp *syncProducer) SendMessageContext(ctx context.Context, msg *ProducerMessage) (partition int32, offset int64, err error) {
+	expectation := expectationsPool.Get().(chan *ProducerError)
+	msg.expectation = expectation
+	select {
+	case sp.producer.Input() <- msg:
+	case <-ctx.Done():
+		msg.expectation = nil
+		expectationsPool.Put(expectation)
+		return -1, -1, ctx.Err()
+	}
+
+	select {
+	case pErr := <-expectation:
+		msg.expectation = nil
+		expectationsPool.Put(expectation)
+		if pErr != nil {
+			return -1, -1, pErr.Err
+		}
+		return msg.Partition, msg.Offset, nil
+	case <-ctx.Done():
+		sp.abandon(msg)
+		return -1, -1, ctx.Err()
+	}
+}
+
+func (sp *syncProducer) SendMessagesContext(ctx context.Context, msgs []*ProducerMessage) error {
+	indices := make(chan int, len(msgs))
+	go func() {
+		defer close(indices)
+		for i, msg := range msgs {
+			expectation := expectationsPool.Get().(chan *ProducerError)
+			msg.expectation = expectation
+			select {
+			case sp.producer.Input() <- msg:
+				indices <- i
+			case <-ctx.Done():
+				msg.expectation = nil
+				expectationsPool.Put(expectation)
+				return
+			}
+		}
+	}()
+
+	var errors ProducerErrors
+	next := 0
+	for i := range indices {
+		select {
+		case pErr := <-msgs[i].expectation:
+			expectationsPool.Put(msgs[i].expectation)
+			msgs[i].expectation = nil
+			if pErr != nil {
+				errors = append(errors, pErr)
+			}
+			next = i + 1
+			continue
+		case <-ctx.Done():
+		}
+
+		// the context expired: hand every message that is still in flight over
+		// to the background, and report it along with those never enqueued
+		sp.abandon(msgs[i])
+		for j := range indices {
+			sp.abandon(msgs[j])
+		}
+		for _, msg := range msgs[i:] {
+			errors = append(errors, &ProducerError{Msg: msg, Err: ctx.Err()})
+		}
+		return errors
+	}
+
+	if next < len(msgs) {
+		// the feeder stopped before enqueuing every message
+		for _, msg := range msgs[next:] {
+			errors = append(errors, &ProducerError{Msg: msg, Err: ctx.Err()})
+		}
+	}
+
+	if len(errors) > 0 {
+		return errors
+	}
+	return nil
+}
+
+// abandon waits in the background for the outcome of a message whose sender
+// gave up waiting, so that its expectation can be returned to the pool once the
+// producer is done with it instead of receiving a stale result later on. The
+// message itself is never written from the background goroutine.
+func (sp *syncProducer) abandon(msg *ProducerMessage) {
+	expectation, topic := msg.expectation, msg.Topic
+	go withRecover(func() {
+		pErr := <-expectation
+		expectationsPool.Put(expectation)
+		if pErr != nil {
+			Logger.Printf("producer/sync message to %s abandoned by its caller failed to produce: %v\n",
+				topic, pErr.Err)
+		}
+	})
+}
+
 func (sp *syncProducer) handleSuccesses() {
 	defer sp.wg.Done()
 	for msg := range sp.producer.Successes() {
-		expectation := msg.expectation
-		expectation <- nil
+		// messages added by Producer.InterceptorsV2 are not waited for
+		if expectation := msg.expectation; expectation != nil {
+			expectation <- nil
+		}
 	}
 }
 
 func (sp *syncProducer) handleErrors() {
 	defer sp.wg.Done()
 	for err := range sp.producer.Errors() {
-		expectation := err.Msg.expectation
-		expectation <- err
+		if expectation := err.Msg.expectation; expectation != nil {
+			expectation <- err
+		}
 	}
 }
 
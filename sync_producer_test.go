@@ -3,10 +3,12 @@
 package sarama
 
 import (
+	"context"
 	"errors"
 	"log"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestSyncProducer(t *testing.T) {
@@ -210,6 +212,107 @@ func TestSyncProducerBatch(t *testing.T) {
 	seedBroker.Close()
 }
 
+func TestSyncProducerSendMessageContext(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	leader := NewMockBroker(t, 2)
+
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker(leader.Addr(), leader.BrokerID())
+	metadataResponse.AddTopicPartition("my_topic", 0, leader.BrokerID(), nil, nil, nil, ErrNoError)
+	seedBroker.Returns(metadataResponse)
+
+	prodSuccess := new(ProduceResponse)
+	prodSuccess.AddTopicPartition("my_topic", 0, ErrNoError)
+	leader.Returns(prodSuccess)
+
+	config := NewTestConfig()
+	config.Producer.Flush.Messages = 2
+	config.Producer.Return.Successes = true
+	producer, err := NewSyncProducer([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a single message is held back until a second one arrives, so the
+	// context expires while it is in flight
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	late := &ProducerMessage{Topic: "my_topic", Value: StringEncoder(TestMessage)}
+	if _, _, err := producer.SendMessageContext(ctx, late); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+
+	// the abandoned message is still delivered alongside the next one
+	partition, offset, err := producer.SendMessageContext(context.Background(), &ProducerMessage{
+		Topic: "my_topic",
+		Value: StringEncoder(TestMessage),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if partition != 0 || offset != 1 {
+		t.Errorf("Unexpected partition/offset %d/%d", partition, offset)
+	}
+
+	safeClose(t, producer)
+	leader.Close()
+	seedBroker.Close()
+}
+
+func TestSyncProducerSendMessagesContext(t *testing.T) {
+	seedBroker := NewMockBroker(t, 1)
+	leader := NewMockBroker(t, 2)
+
+	metadataResponse := new(MetadataResponse)
+	metadataResponse.AddBroker(leader.Addr(), leader.BrokerID())
+	metadataResponse.AddTopicPartition("my_topic", 0, leader.BrokerID(), nil, nil, nil, ErrNoError)
+	seedBroker.Returns(metadataResponse)
+
+	prodSuccess := new(ProduceResponse)
+	prodSuccess.AddTopicPartition("my_topic", 0, ErrNoError)
+	leader.Returns(prodSuccess)
+
+	config := NewTestConfig()
+	config.Producer.Flush.Messages = 3
+	config.Producer.Return.Successes = true
+	producer, err := NewSyncProducer([]string{seedBroker.Addr()}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	msgs := []*ProducerMessage{
+		{Topic: "my_topic", Value: StringEncoder(TestMessage)},
+		{Topic: "my_topic", Value: StringEncoder(TestMessage)},
+	}
+	err = producer.SendMessagesContext(ctx, msgs)
+
+	var pErrs ProducerErrors
+	if !errors.As(err, &pErrs) {
+		t.Fatalf("Expected ProducerErrors, got %v", err)
+	}
+	if len(pErrs) != len(msgs) {
+		t.Fatalf("Expected %d errors, got %d", len(msgs), len(pErrs))
+	}
+	for i, pErr := range pErrs {
+		if pErr.Msg != msgs[i] || !errors.Is(pErr, context.DeadlineExceeded) {
+			t.Errorf("Unexpected error %d: %v", i, pErr)
+		}
+	}
+
+	// completing the batch flushes the abandoned messages as well
+	if err := producer.SendMessagesContext(context.Background(), []*ProducerMessage{
+		{Topic: "my_topic", Value: StringEncoder(TestMessage)},
+	}); err != nil {
+		t.Error(err)
+	}
+
+	safeClose(t, producer)
+	leader.Close()
+	seedBroker.Close()
+}
+
 func TestConcurrentSyncProducer(t *testing.T) {
 	seedBroker := NewMockBroker(t, 1)
 	leader := NewMockBroker(t, 2)
@@ -1,6 +1,7 @@
 package sarama
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -101,6 +102,19 @@ type transactionManager struct {
 
 	// Offsets to add to transaction.
 	offsetsInCurrentTxn map[string]topicPartitionOffsets
+	// Whether offsets have already been sent to the transaction by sendOffsetsToTxn.
+	offsetsSentInCurrentTxn bool
+}
+
+// ConsumerGroupMetadata identifies the consumer group member that consumed the
+// offsets sent to a transaction, so that the group coordinator can fence the
+// commits of a member that is no longer part of the group (KIP-447). The
+// GenerationID and MemberID of a ConsumerGroupSession can be used.
+type ConsumerGroupMetadata struct {
+	GroupID         string
+	GenerationID    int32
+	MemberID        string
+	GroupInstanceID *string
 }
 
 const (
@@ -246,6 +260,17 @@ func (t *transactionManager) bumpEpoch() {
 	}
 }
 
+// setProducerID replaces the producer ID and epoch, resetting the sequence
+// numbers.
+func (t *transactionManager) setProducerID(producerID int64, producerEpoch int16) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.producerID, t.producerEpoch = producerID, producerEpoch
+	for k := range t.sequenceNumbers {
+		t.sequenceNumbers[k] = 0
+	}
+}
+
 func (t *transactionManager) getProducerID() (int64, int16) {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
@@ -293,8 +318,41 @@ func (t *transactionManager) addOffsetsToTxn(offsetsToAdd map[string][]*Partitio
 	return nil
 }
 
+// send the specified offsets to the current transaction straight away.
+func (t *transactionManager) sendOffsetsToTxn(ctx context.Context, offsetsToSend map[string][]*PartitionOffsetMetadata, groupMetadata *ConsumerGroupMetadata) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.currentTxnStatus()&ProducerTxnFlagInTransaction == 0 {
+		return ErrTransactionNotReady
+	}
+
+	if t.currentTxnStatus()&ProducerTxnFlagInError != 0 {
+		return t.lastError
+	}
+
+	offsets := topicPartitionOffsets{}
+	for topic, partitionOffsets := range offsetsToSend {
+		for _, offset := range partitionOffsets {
+			offsets[topicPartition{topic: topic, partition: offset.Partition}] = offset
+		}
+	}
+
+	if _, err := t.publishOffsetsToTxn(ctx, offsets, groupMetadata); err != nil {
+		return err
+	}
+	t.offsetsSentInCurrentTxn = true
+
+	// a produce request may have failed in the meantime
+	if t.currentTxnStatus()&ProducerTxnFlagInError != 0 {
+		return t.lastError
+	}
+	return nil
+}
+
 // send txnmgnr save offsets to transaction coordinator.
-func (t *transactionManager) publishOffsetsToTxn(offsets topicPartitionOffsets, groupId string) (topicPartitionOffsets, error) {
+func (t *transactionManager) publishOffsetsToTxn(ctx context.Context, offsets topicPartitionOffsets, groupMetadata *ConsumerGroupMetadata) (topicPartitionOffsets, error) {
+	groupId := groupMetadata.GroupID
 	// First AddOffsetsToTxn
 	attemptsRemaining := t.client.Config().Producer.Transaction.Retry.Max
 	exec := func(run func() (bool, error), err error) error {
@@ -307,7 +365,11 @@ func (t *transactionManager) publishOffsetsToTxn(offsets topicPartitionOffsets,
 			backoff := t.computeBackoff(attemptsRemaining)
 			Logger.Printf("txnmgr/add-offset-to-txn [%s] retrying after %dms... (%d attempts remaining) (%s)\n",
 				t.transactionalID, backoff/time.Millisecond, attemptsRemaining, err)
-			time.Sleep(backoff)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
 			attemptsRemaining--
 		}
 		return err
@@ -390,7 +452,11 @@ func (t *transactionManager) publishOffsetsToTxn(offsets topicPartitionOffsets,
 			backoff := t.computeBackoff(attemptsRemaining)
 			Logger.Printf("txnmgr/txn-offset-commit [%s] retrying after %dms... (%d attempts remaining) (%s)\n",
 				t.transactionalID, backoff/time.Millisecond, attemptsRemaining, err)
-			time.Sleep(backoff)
+			select {
+			case <-ctx.Done():
+				return r, ctx.Err()
+			case <-time.After(backoff):
+			}
 			attemptsRemaining--
 		}
 		return r, err
@@ -405,9 +471,15 @@ func (t *transactionManager) publishOffsetsToTxn(offsets topicPartitionOffsets,
 			ProducerEpoch:   t.producerEpoch,
 			ProducerID:      t.producerID,
 			GroupID:         groupId,
+			GenerationID:    groupMetadata.GenerationID,
+			MemberID:        groupMetadata.MemberID,
+			GroupInstanceID: groupMetadata.GroupInstanceID,
 			Topics:          offsets.mapToRequest(),
 		}
-		if t.client.Config().Version.IsAtLeast(V2_1_0_0) {
+		if t.client.Config().Version.IsAtLeast(V2_5_0_0) {
+			// Version 3 adds the consumer group member to fence zombie consumers.
+			request.Version = 3
+		} else if t.client.Config().Version.IsAtLeast(V2_1_0_0) {
 			// Version 2 adds the committed leader epoch.
 			request.Version = 2
 		} else if t.client.Config().Version.IsAtLeast(V2_0_0_0) {
@@ -611,6 +683,7 @@ func (t *transactionManager) completeTransaction() error {
 	t.partitionsInCurrentTxn = topicPartitionSet{}
 	t.pendingPartitionsInCurrentTxn = topicPartitionSet{}
 	t.offsetsInCurrentTxn = map[string]topicPartitionOffsets{}
+	t.offsetsSentInCurrentTxn = false
 
 	return nil
 }
@@ -703,8 +776,8 @@ func (t *transactionManager) finishTransaction(commit bool) error {
 		return t.lastError
 	}
 
-	// if no records has been sent don't do anything.
-	if len(t.partitionsInCurrentTxn) == 0 {
+	// if no records nor offsets have been sent don't do anything.
+	if len(t.partitionsInCurrentTxn) == 0 && !t.offsetsSentInCurrentTxn {
 		return t.completeTransaction()
 	}
 
@@ -712,7 +785,7 @@ func (t *transactionManager) finishTransaction(commit bool) error {
 	// If we're aborting the transaction, so there should be no need to add offsets.
 	if commit && len(t.offsetsInCurrentTxn) > 0 {
 		for group, offsets := range t.offsetsInCurrentTxn {
-			newOffsets, err := t.publishOffsetsToTxn(offsets, group)
+			newOffsets, err := t.publishOffsetsToTxn(context.Background(), offsets, &ConsumerGroupMetadata{GroupID: group, GenerationID: -1})
 			if err != nil {
 				t.offsetsInCurrentTxn[group] = newOffsets
 				return err
@@ -815,19 +888,7 @@ func (t *transactionManager) publishTxnPartitions() error {
 		if err != nil {
 			return true, err
 		}
-		request := &AddPartitionsToTxnRequest{
-			TransactionalID: t.transactionalID,
-			ProducerID:      t.producerID,
-			ProducerEpoch:   t.producerEpoch,
-			TopicPartitions: t.pendingPartitionsInCurrentTxn.mapToRequest(),
-		}
-		if t.client.Config().Version.IsAtLeast(V2_7_0_0) {
-			// Version 2 adds the support for new error code PRODUCER_FENCED.
-			request.Version = 2
-		} else if t.client.Config().Version.IsAtLeast(V2_0_0_0) {
-			// Version 1 is the same as version 0.
-			request.Version = 1
-		}
+		request := t.newAddPartitionsToTxnRequest(coordinator)
 		addPartResponse, err := coordinator.AddPartitionsToTxn(request)
 		if err != nil {
 			_ = coordinator.Close()
@@ -839,9 +900,23 @@ func (t *transactionManager) publishTxnPartitions() error {
 			return true, ErrTxnUnableToParseResponse
 		}
 
+		topicResults := addPartResponse.Errors
+		if request.Version >= 4 {
+			topicResults = addPartResponse.ResultsByTransaction[t.transactionalID]
+			if addPartResponse.ErrorCode != ErrNoError {
+				// a top level error applies to every partition of the request
+				topicResults = make(map[string][]*PartitionError)
+				for topic, partitions := range request.Transactions[0].TopicPartitions {
+					for _, partition := range partitions {
+						topicResults[topic] = append(topicResults[topic], &PartitionError{Partition: partition, Err: addPartResponse.ErrorCode})
+					}
+				}
+			}
+		}
+
 		// remove from the list partitions that have been successfully updated
 		var responseErrors []error
-		for topic, results := range addPartResponse.Errors {
+		for topic, results := range topicResults {
 			for _, response := range results {
 				tp := topicPartition{topic: topic, partition: response.Partition}
 				switch response.Err {
@@ -893,6 +968,46 @@ func (t *transactionManager) publishTxnPartitions() error {
 	}, nil)
 }
 
+// newAddPartitionsToTxnRequest builds the request adding the pending partitions
+// to the transaction. It uses the batched version 4 format of KIP-890 when
+// Producer.Transaction.BatchedAddPartitions is set and the coordinator supports
+// it, and the single transaction format otherwise.
+func (t *transactionManager) newAddPartitionsToTxnRequest(coordinator *Broker) *AddPartitionsToTxnRequest {
+	conf := t.client.Config()
+	topicPartitions := t.pendingPartitionsInCurrentTxn.mapToRequest()
+
+	if conf.Producer.Transaction.BatchedAddPartitions && conf.Version.IsAtLeast(V3_6_0_0) {
+		request := &AddPartitionsToTxnRequest{Version: 4}
+		if _, ok := coordinator.negotiateApiVersion(request, 4); ok {
+			request.Transactions = []*AddPartitionsToTxnTransaction{{
+				TransactionalID: t.transactionalID,
+				ProducerID:      t.producerID,
+				ProducerEpoch:   t.producerEpoch,
+				TopicPartitions: topicPartitions,
+			}}
+			return request
+		}
+	}
+
+	request := &AddPartitionsToTxnRequest{
+		TransactionalID: t.transactionalID,
+		ProducerID:      t.producerID,
+		ProducerEpoch:   t.producerEpoch,
+		TopicPartitions: topicPartitions,
+	}
+	if conf.Version.IsAtLeast(V2_8_0_0) {
+		// Version 3 enables flexible versions.
+		request.Version = 3
+	} else if conf.Version.IsAtLeast(V2_7_0_0) {
+		// Version 2 adds the support for new error code PRODUCER_FENCED.
+		request.Version = 2
+	} else if conf.Version.IsAtLeast(V2_0_0_0) {
+		// Version 1 is the same as version 0.
+		request.Version = 1
+	}
+	return request
+}
+
 // Build a new transaction manager sharing producer client.
 func newTransactionManager(conf *Config, client Client) (*transactionManager, error) {
 	txnmgr := &transactionManager{
@@ -3,6 +3,7 @@
 package sarama
 
 import (
+	"context"
 	"errors"
 	"testing"
 
@@ -482,7 +483,7 @@ func TestAddOffsetsToTxn(t *testing.T) {
 				})
 			}
 
-			newOffsets, err := txmng.publishOffsetsToTxn(offsets, "test-group")
+			newOffsets, err := txmng.publishOffsetsToTxn(context.Background(), offsets, &ConsumerGroupMetadata{GroupID: "test-group", GenerationID: -1})
 			if tc.expectedError != nil {
 				require.Equal(t, tc.expectedError.Error(), err.Error())
 			} else {
@@ -719,7 +720,7 @@ func TestTxnOffsetsCommit(t *testing.T) {
 				})
 			}
 
-			newOffsets, err := txmng.publishOffsetsToTxn(tc.initialOffsets, "test-group")
+			newOffsets, err := txmng.publishOffsetsToTxn(context.Background(), tc.initialOffsets, &ConsumerGroupMetadata{GroupID: "test-group", GenerationID: -1})
 			if tc.expectedError != nil {
 				require.Equal(t, tc.expectedError.Error(), err.Error())
 			} else {
@@ -1032,3 +1033,102 @@ func TestPublishPartitionToTxn(t *testing.T) {
 		}()
 	}
 }
+
+func TestPublishPartitionToTxnBatched(t *testing.T) {
+	for _, tc := range []struct {
+		name            string
+		batched         bool
+		maxVersion      int16
+		topLevelErr     KError
+		expectedVersion int16
+		expectedFlags   ProducerTxnStatusFlag
+		expectedError   error
+	}{
+		{"coordinator supports v4", true, 4, ErrNoError, 4, ProducerTxnFlagInTransaction, nil},
+		{"coordinator without v4", true, 3, ErrNoError, 3, ProducerTxnFlagInTransaction, nil},
+		{"batching disabled", false, 4, ErrNoError, 3, ProducerTxnFlagInTransaction, nil},
+		{"top level error", true, 4, ErrClusterAuthorizationFailed, 4, ProducerTxnFlagFatalError, ErrClusterAuthorizationFailed},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			broker := NewMockBroker(t, 1)
+			defer broker.Close()
+
+			requests := make(chan *AddPartitionsToTxnRequest, 1)
+			broker.SetHandlerFuncByMap(map[string]requestHandlerFunc{
+				"ApiVersionsRequest": func(req *request) encoderWithHeader {
+					return NewMockApiVersionsResponse(t).SetApiKeys([]ApiVersionsResponseKey{
+						{ApiKey: apiKeyAddPartitionsToTxn, MinVersion: 0, MaxVersion: tc.maxVersion},
+					}).For(req.body)
+				},
+				"MetadataRequest": func(req *request) encoderWithHeader {
+					return NewMockMetadataResponse(t).
+						SetBroker(broker.Addr(), broker.BrokerID()).
+						SetLeader("test-topic", 0, broker.BrokerID()).
+						For(req.body)
+				},
+				"FindCoordinatorRequest": func(req *request) encoderWithHeader {
+					return NewMockFindCoordinatorResponse(t).
+						SetCoordinator(CoordinatorTransaction, "test", broker).
+						For(req.body)
+				},
+				"InitProducerIDRequest": func(req *request) encoderWithHeader {
+					return NewMockInitProducerIDResponse(t).SetProducerID(1000).For(req.body)
+				},
+				"AddPartitionsToTxnRequest": func(req *request) encoderWithHeader {
+					addPartitions := req.body.(*AddPartitionsToTxnRequest)
+					requests <- addPartitions
+					errs := map[string][]*PartitionError{"test-topic": {{Partition: 0, Err: ErrNoError}}}
+					res := &AddPartitionsToTxnResponse{Version: addPartitions.Version}
+					if addPartitions.Version >= 4 {
+						res.ErrorCode = tc.topLevelErr
+						res.ResultsByTransaction = map[string]map[string][]*PartitionError{"test": errs}
+					} else {
+						res.Errors = errs
+					}
+					return res
+				},
+			})
+
+			config := NewTestConfig()
+			config.Producer.Idempotent = true
+			config.Producer.Transaction.ID = "test"
+			config.Producer.Transaction.BatchedAddPartitions = tc.batched
+			config.Producer.Transaction.Retry.Max = 0
+			config.Producer.RequiredAcks = WaitForAll
+			config.Net.MaxOpenRequests = 1
+			config.Version = V3_6_0_0
+			config.ApiVersionsRequest = true
+
+			client, err := NewClient([]string{broker.Addr()}, config)
+			require.NoError(t, err)
+			defer client.Close()
+
+			txmng, err := newTransactionManager(config, client)
+			require.NoError(t, err)
+			txmng.status = ProducerTxnFlagInTransaction
+			txmng.pendingPartitionsInCurrentTxn = topicPartitionSet{
+				{topic: "test-topic", partition: 0}: struct{}{},
+			}
+
+			err = txmng.publishTxnPartitions()
+			require.ErrorIs(t, err, tc.expectedError)
+			require.NotZero(t, txmng.status&tc.expectedFlags)
+
+			req := <-requests
+			require.Equal(t, tc.expectedVersion, req.Version)
+			if req.Version >= 4 {
+				require.Len(t, req.Transactions, 1)
+				require.Equal(t, "test", req.Transactions[0].TransactionalID)
+				require.Equal(t, int64(1000), req.Transactions[0].ProducerID)
+				require.False(t, req.Transactions[0].VerifyOnly)
+				require.Equal(t, map[string][]int32{"test-topic": {0}}, req.Transactions[0].TopicPartitions)
+			} else {
+				require.Equal(t, "test", req.TransactionalID)
+				require.Equal(t, map[string][]int32{"test-topic": {0}}, req.TopicPartitions)
+			}
+			if tc.expectedError == nil {
+				require.Contains(t, txmng.partitionsInCurrentTxn, topicPartition{topic: "test-topic", partition: 0})
+			}
+		})
+	}
+}
@@ -1,11 +1,33 @@
 package sarama
 
+// TxnOffsetCommit Request (Version: 3) => transactional_id group_id producer_id producer_epoch generation_id member_id group_instance_id [topics] _tagged_fields
+//   transactional_id => COMPACT_STRING
+//   group_id => COMPACT_STRING
+//   producer_id => INT64
+//   producer_epoch => INT16
+//   generation_id => INT32
+//   member_id => COMPACT_STRING
+//   group_instance_id => COMPACT_NULLABLE_STRING
+//   topics => name [partitions] _tagged_fields
+//     name => COMPACT_STRING
+//     partitions => partition_index committed_offset committed_leader_epoch committed_metadata _tagged_fields
+//       partition_index => INT32
+//       committed_offset => INT64
+//       committed_leader_epoch => INT32
+//       committed_metadata => COMPACT_NULLABLE_STRING
+
 type TxnOffsetCommitRequest struct {
 	Version         int16
 	TransactionalID string
 	GroupID         string
 	ProducerID      int64
 	ProducerEpoch   int16
+	// GenerationID, MemberID and GroupInstanceID identify the consumer group
+	// member the offsets were consumed by, so that the group coordinator can
+	// fence zombie consumers (KIP-447). Only sent from version 3.
+	GenerationID    int32
+	MemberID        string
+	GroupInstanceID *string
 	Topics          map[string][]*PartitionOffsetMetadata
 }
 
@@ -23,6 +45,16 @@ func (t *TxnOffsetCommitRequest) encode(pe packetEncoder) error {
 	pe.putInt64(t.ProducerID)
 	pe.putInt16(t.ProducerEpoch)
 
+	if t.Version >= 3 {
+		pe.putInt32(t.GenerationID)
+		if err := pe.putString(t.MemberID); err != nil {
+			return err
+		}
+		if err := pe.putNullableString(t.GroupInstanceID); err != nil {
+			return err
+		}
+	}
+
 	if err := pe.putArrayLength(len(t.Topics)); err != nil {
 		return err
 	}
@@ -38,8 +70,10 @@ func (t *TxnOffsetCommitRequest) encode(pe packetEncoder) error {
 				return err
 			}
 		}
+		pe.putEmptyTaggedFieldArray()
 	}
 
+	pe.putEmptyTaggedFieldArray()
 	return nil
 }
 
@@ -58,6 +92,18 @@ func (t *TxnOffsetCommitRequest) decode(pd packetDecoder, version int16) (err er
 		return err
 	}
 
+	if version >= 3 {
+		if t.GenerationID, err = pd.getInt32(); err != nil {
+			return err
+		}
+		if t.MemberID, err = pd.getString(); err != nil {
+			return err
+		}
+		if t.GroupInstanceID, err = pd.getNullableString(); err != nil {
+			return err
+		}
+	}
+
 	n, err := pd.getArrayLength()
 	if err != nil {
 		return err
@@ -84,9 +130,13 @@ func (t *TxnOffsetCommitRequest) decode(pd packetDecoder, version int16) (err er
 			}
 			t.Topics[topic][j] = partitionOffsetMetadata
 		}
+		if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
 }
 
 func (a *TxnOffsetCommitRequest) key() int16 {
@@ -98,15 +148,28 @@ func (a *TxnOffsetCommitRequest) version() int16 {
 }
 
 func (a *TxnOffsetCommitRequest) headerVersion() int16 {
+	if a.isFlexible() {
+		return 2
+	}
 	return 1
 }
 
 func (a *TxnOffsetCommitRequest) isValidVersion() bool {
-	return a.Version >= 0 && a.Version <= 2
+	return a.Version >= 0 && a.Version <= 3
+}
+
+func (a *TxnOffsetCommitRequest) isFlexible() bool {
+	return a.isFlexibleVersion(a.Version)
+}
+
+func (a *TxnOffsetCommitRequest) isFlexibleVersion(version int16) bool {
+	return version >= 3
 }
 
 func (a *TxnOffsetCommitRequest) requiredVersion() KafkaVersion {
 	switch a.Version {
+	case 3:
+		return V2_5_0_0
 	case 2:
 		return V2_1_0_0
 	case 1:
@@ -114,7 +177,7 @@ func (a *TxnOffsetCommitRequest) requiredVersion() KafkaVersion {
 	case 0:
 		return V0_11_0_0
 	default:
-		return V2_1_0_0
+		return V2_5_0_0
 	}
 }
 
@@ -141,6 +204,7 @@ func (p *PartitionOffsetMetadata) encode(pe packetEncoder, version int16) error
 		return err
 	}
 
+	pe.putEmptyTaggedFieldArray()
 	return nil
 }
 
@@ -162,5 +226,6 @@ func (p *PartitionOffsetMetadata) decode(pd packetDecoder, version int16) (err e
 		return err
 	}
 
-	return nil
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
 }
@@ -31,6 +31,26 @@ var (
 		0, 0, 0, 9, // leader epoch
 		255, 255, // no meta data
 	}
+
+	txnOffsetCommitRequestV3 = []byte{
+		4, 't', 'x', 'n',
+		8, 'g', 'r', 'o', 'u', 'p', 'i', 'd',
+		0, 0, 0, 0, 0, 0, 31, 64, // producer ID
+		0, 1, // producer epoch
+		0, 0, 0, 5, // generation ID
+		7, 'm', 'e', 'm', 'b', 'e', 'r', // member ID
+		9, 'i', 'n', 's', 't', 'a', 'n', 'c', 'e', // group instance ID
+		2, // 1 topic
+		6, 't', 'o', 'p', 'i', 'c',
+		2,          // 1 partition
+		0, 0, 0, 2, // partition no 2
+		0, 0, 0, 0, 0, 0, 0, 123,
+		0, 0, 0, 9, // leader epoch
+		0, // no meta data
+		0, // empty partition tagged fields
+		0, // empty topic tagged fields
+		0, // empty tagged fields
+	}
 )
 
 func TestTxnOffsetCommitRequest(t *testing.T) {
@@ -68,3 +88,26 @@ func TestTxnOffsetCommitRequestV2(t *testing.T) {
 
 	testRequest(t, "V2", req, txnOffsetCommitRequestV2)
 }
+
+func TestTxnOffsetCommitRequestV3(t *testing.T) {
+	instance := "instance"
+	req := &TxnOffsetCommitRequest{
+		Version:         3,
+		TransactionalID: "txn",
+		GroupID:         "groupid",
+		ProducerID:      8000,
+		ProducerEpoch:   1,
+		GenerationID:    5,
+		MemberID:        "member",
+		GroupInstanceID: &instance,
+		Topics: map[string][]*PartitionOffsetMetadata{
+			"topic": {{
+				Offset:      123,
+				Partition:   2,
+				LeaderEpoch: 9,
+			}},
+		},
+	}
+
+	testRequest(t, "V3", req, txnOffsetCommitRequestV3)
+}
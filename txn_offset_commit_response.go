@@ -4,6 +4,14 @@ import (
 	"time"
 )
 
+// TxnOffsetCommit Response (Version: 3) => throttle_time_ms [topics] _tagged_fields
+//   throttle_time_ms => INT32
+//   topics => name [partitions] _tagged_fields
+//     name => COMPACT_STRING
+//     partitions => partition_index error_code _tagged_fields
+//       partition_index => INT32
+//       error_code => INT16
+
 type TxnOffsetCommitResponse struct {
 	Version      int16
 	ThrottleTime time.Duration
@@ -32,8 +40,10 @@ func (t *TxnOffsetCommitResponse) encode(pe packetEncoder) error {
 				return err
 			}
 		}
+		pe.putEmptyTaggedFieldArray()
 	}
 
+	pe.putEmptyTaggedFieldArray()
 	return nil
 }
 
@@ -71,9 +81,13 @@ func (t *TxnOffsetCommitResponse) decode(pd packetDecoder, version int16) (err e
 				return err
 			}
 		}
+		if _, err := pd.getEmptyTaggedFieldArray(); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	_, err = pd.getEmptyTaggedFieldArray()
+	return err
 }
 
 func (a *TxnOffsetCommitResponse) key() int16 {
@@ -85,15 +99,28 @@ func (a *TxnOffsetCommitResponse) version() int16 {
 }
 
 func (a *TxnOffsetCommitResponse) headerVersion() int16 {
+	if a.isFlexible() {
+		return 1
+	}
 	return 0
 }
 
 func (a *TxnOffsetCommitResponse) isValidVersion() bool {
-	return a.Version >= 0 && a.Version <= 2
+	return a.Version >= 0 && a.Version <= 3
+}
+
+func (a *TxnOffsetCommitResponse) isFlexible() bool {
+	return a.isFlexibleVersion(a.Version)
+}
+
+func (a *TxnOffsetCommitResponse) isFlexibleVersion(version int16) bool {
+	return version >= 3
 }
 
 func (a *TxnOffsetCommitResponse) requiredVersion() KafkaVersion {
 	switch a.Version {
+	case 3:
+		return V2_5_0_0
 	case 2:
 		return V2_1_0_0
 	case 1:
@@ -101,7 +128,7 @@ func (a *TxnOffsetCommitResponse) requiredVersion() KafkaVersion {
 	case 0:
 		return V0_11_0_0
 	default:
-		return V2_1_0_0
+		return V2_5_0_0
 	}
 }
 
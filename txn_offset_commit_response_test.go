@@ -7,14 +7,28 @@ import (
 	"time"
 )
 
-var txnOffsetCommitResponse = []byte{
-	0, 0, 0, 100,
-	0, 0, 0, 1, // 1 topic
-	0, 5, 't', 'o', 'p', 'i', 'c',
-	0, 0, 0, 1, // 1 partition response
-	0, 0, 0, 2, // partition number 2
-	0, 47, // err
-}
+var (
+	txnOffsetCommitResponse = []byte{
+		0, 0, 0, 100,
+		0, 0, 0, 1, // 1 topic
+		0, 5, 't', 'o', 'p', 'i', 'c',
+		0, 0, 0, 1, // 1 partition response
+		0, 0, 0, 2, // partition number 2
+		0, 47, // err
+	}
+
+	txnOffsetCommitResponseV3 = []byte{
+		0, 0, 0, 100,
+		2, // 1 topic
+		6, 't', 'o', 'p', 'i', 'c',
+		2,          // 1 partition response
+		0, 0, 0, 2, // partition number 2
+		0, 82, // err
+		0, // empty partition tagged fields
+		0, // empty topic tagged fields
+		0, // empty tagged fields
+	}
+)
 
 func TestTxnOffsetCommitResponse(t *testing.T) {
 	resp := &TxnOffsetCommitResponse{
@@ -29,3 +43,18 @@ func TestTxnOffsetCommitResponse(t *testing.T) {
 
 	testResponse(t, "", resp, txnOffsetCommitResponse)
 }
+
+func TestTxnOffsetCommitResponseV3(t *testing.T) {
+	resp := &TxnOffsetCommitResponse{
+		Version:      3,
+		ThrottleTime: 100 * time.Millisecond,
+		Topics: map[string][]*PartitionError{
+			"topic": {{
+				Partition: 2,
+				Err:       ErrFencedInstancedId,
+			}},
+		},
+	}
+
+	testResponse(t, "V3", resp, txnOffsetCommitResponseV3)
+}
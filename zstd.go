@@ -1,16 +1,103 @@
 package sarama
 
 import (
+	"bytes"
+	"errors"
 	"runtime"
 	"sync"
 
 	"github.com/klauspost/compress/zstd"
 )
 
+// zstd compression levels accepted by Config.Producer.CompressionLevel. They
+// are the standard zstd levels, which the encoder maps onto its own speed
+// levels: 1-2 is zstd.SpeedFastest, 3-5 zstd.SpeedDefault, 6-9
+// zstd.SpeedBetterCompression and 10-22 zstd.SpeedBestCompression.
+const (
+	zstdMinCompressionLevel = 1
+	zstdMaxCompressionLevel = 22
+)
+
 type ZstdEncoderParams struct {
 	Level int
+	dict  *zstdDictionary
 }
 type ZstdDecoderParams struct {
+	dict *zstdDictionary
+}
+
+var errZstdDictionaryClosed = errors.New("kafka: zstd dictionary used after its producer or consumer was closed")
+
+// zstdDictionary is a zstd dictionary set in Config.Producer.ZstdDictionary,
+// owned by a single producer or consumer. Its encoders and decoder are kept
+// with it, instead of the global pools, so that they are released by close.
+type zstdDictionary struct {
+	raw []byte
+
+	lock     sync.Mutex
+	closed   bool
+	encoders map[int]chan *zstd.Encoder
+	decoder  *zstd.Decoder
+}
+
+// newZstdDictionary returns the zstdDictionary of raw, nil if raw is empty.
+func newZstdDictionary(raw []byte) *zstdDictionary {
+	if len(raw) == 0 {
+		return nil
+	}
+	return &zstdDictionary{raw: bytes.Clone(raw), encoders: make(map[int]chan *zstd.Encoder)}
+}
+
+// encoderChannel returns the channel retaining the idle encoders of the
+// given level, nil once the dictionary is closed.
+func (d *zstdDictionary) encoderChannel(level int) chan *zstd.Encoder {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.closed {
+		return nil
+	}
+	ch := d.encoders[level]
+	if ch == nil {
+		ch = make(chan *zstd.Encoder, max(runtime.GOMAXPROCS(0), 1))
+		d.encoders[level] = ch
+	}
+	return ch
+}
+
+// getDecoder returns the decoder of the dictionary, creating it on first use.
+func (d *zstdDictionary) getDecoder() (*zstd.Decoder, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.closed {
+		return nil, errZstdDictionaryClosed
+	}
+	if d.decoder == nil {
+		dec, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(0), zstd.WithDecoderDicts(d.raw))
+		if err != nil {
+			return nil, err
+		}
+		d.decoder = dec
+	}
+	return d.decoder, nil
+}
+
+// close releases the encoders and decoder of the dictionary. A nil dictionary
+// is ignored.
+func (d *zstdDictionary) close() {
+	if d == nil {
+		return
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.closed = true
+	d.encoders = nil
+	if d.decoder != nil {
+		d.decoder.Close()
+		d.decoder = nil
+	}
 }
 
 var zstdDecMap sync.Map
@@ -26,8 +113,12 @@ var (
 // arriving for the same not-yet-seen ZstdEncoderParams cannot create
 // multiple competing channels. The channel is sized to GOMAXPROCS so that
 // the previous size-1 cap can no longer force concurrent callers to
-// allocate a fresh encoder per batch.
+// allocate a fresh encoder per batch. Encoders using a dictionary are kept
+// with it instead.
 func getZstdEncoderChannel(params ZstdEncoderParams) chan *zstd.Encoder {
+	if params.dict != nil {
+		return params.dict.encoderChannel(params.Level)
+	}
 	if c, ok := zstdAvailableEncoders.Load(params); ok {
 		return c.(chan *zstd.Encoder)
 	}
@@ -44,22 +135,26 @@ func getZstdEncoderChannel(params ZstdEncoderParams) chan *zstd.Encoder {
 	return ch
 }
 
-func newZstdEncoder(params ZstdEncoderParams) *zstd.Encoder {
+func newZstdEncoder(params ZstdEncoderParams) (*zstd.Encoder, error) {
 	encoderLevel := zstd.SpeedDefault
 	if params.Level != CompressionLevelDefault {
 		encoderLevel = zstd.EncoderLevelFromZstd(params.Level)
 	}
-	enc, _ := zstd.NewWriter(nil,
+	options := []zstd.EOption{
 		zstd.WithZeroFrames(true),
 		zstd.WithEncoderLevel(encoderLevel),
-		zstd.WithEncoderConcurrency(1))
-	return enc
+		zstd.WithEncoderConcurrency(1),
+	}
+	if params.dict != nil {
+		options = append(options, zstd.WithEncoderDict(params.dict.raw))
+	}
+	return zstd.NewWriter(nil, options...)
 }
 
-func getZstdEncoder(params ZstdEncoderParams) *zstd.Encoder {
+func getZstdEncoder(params ZstdEncoderParams) (*zstd.Encoder, error) {
 	select {
 	case enc := <-getZstdEncoderChannel(params):
-		return enc
+		return enc, nil
 	default:
 		return newZstdEncoder(params)
 	}
@@ -73,23 +168,36 @@ func releaseEncoder(params ZstdEncoderParams, enc *zstd.Encoder) {
 	}
 }
 
-func getDecoder(params ZstdDecoderParams) *zstd.Decoder {
+func getDecoder(params ZstdDecoderParams) (*zstd.Decoder, error) {
+	if params.dict != nil {
+		return params.dict.getDecoder()
+	}
 	if ret, ok := zstdDecMap.Load(params); ok {
-		return ret.(*zstd.Decoder)
+		return ret.(*zstd.Decoder), nil
 	}
 	// It's possible to race and create multiple new readers.
 	// Only one will survive GC after use.
-	zstdDec, _ := zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+	zstdDec, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+	if err != nil {
+		return nil, err
+	}
 	zstdDecMap.Store(params, zstdDec)
-	return zstdDec
+	return zstdDec, nil
 }
 
 func zstdDecompress(params ZstdDecoderParams, dst, src []byte) ([]byte, error) {
-	return getDecoder(params).DecodeAll(src, dst)
+	dec, err := getDecoder(params)
+	if err != nil {
+		return nil, err
+	}
+	return dec.DecodeAll(src, dst)
 }
 
 func zstdCompress(params ZstdEncoderParams, dst, src []byte) ([]byte, error) {
-	enc := getZstdEncoder(params)
+	enc, err := getZstdEncoder(params)
+	if err != nil {
+		return nil, err
+	}
 	out := enc.EncodeAll(src, dst)
 	releaseEncoder(params, enc)
 	return out, nil
@@ -3,7 +3,12 @@
 package sarama
 
 import (
+	"bytes"
+	"fmt"
 	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
 )
 
 func BenchmarkZstdMemoryConsumption(b *testing.B) {
@@ -25,7 +30,7 @@ func BenchmarkZstdMemoryConsumption(b *testing.B) {
 		b.ReportAllocs()
 		for b.Loop() {
 			_, _ = zstdCompress(params, nil, buf)
-			_ = getZstdEncoder(params)
+			_, _ = getZstdEncoder(params)
 		}
 	})
 
@@ -43,3 +48,89 @@ func BenchmarkZstdMemoryConsumption(b *testing.B) {
 		})
 	})
 }
+
+// Compares the throughput and compression ratio of the zstd encoder levels
+// selectable through Config.Producer.CompressionLevel.
+func BenchmarkZstdCompressionLevels(b *testing.B) {
+	// moderately compressible payload resembling JSON events
+	var buf []byte
+	for i := 0; len(buf) < 1024*1024; i++ {
+		buf = fmt.Appendf(buf, `{"id":%d,"user":"user-%d","event":"page_view","ts":%d}`, i, i%1000, 1700000000+i*7)
+	}
+
+	for _, level := range []int{1, 3, 6, 10} {
+		params := ZstdEncoderParams{Level: level}
+		b.Run(fmt.Sprintf("level_%d", level), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(buf)))
+			var out []byte
+			for b.Loop() {
+				out, _ = zstdCompress(params, out[:0], buf)
+			}
+			b.ReportMetric(float64(len(buf))/float64(len(out)), "ratio")
+		})
+	}
+}
+
+func testZstdDictionaryMessage(i int) []byte {
+	return []byte(fmt.Sprintf(`{"id":%d,"type":"order","customer":{"name":"customer-%d","country":"FR"},"items":[{"sku":"sku-%d","quantity":%d}]}`,
+		i, i%7, i%13, i%5))
+}
+
+// newTestZstdDictionary builds a zstd dictionary out of messages alike the
+// ones of testZstdDictionaryMessage.
+func newTestZstdDictionary(tb testing.TB) []byte {
+	tb.Helper()
+	samples := make([][]byte, 200)
+	for i := range samples {
+		samples[i] = testZstdDictionaryMessage(i)
+	}
+	dict, err := zstd.BuildDict(zstd.BuildDictOptions{
+		ID:       42,
+		Contents: samples,
+		History:  bytes.Join(samples[:10], nil),
+		Offsets:  [3]int{1, 4, 8},
+	})
+	require.NoError(tb, err)
+	return dict
+}
+
+func TestZstdDictionary(t *testing.T) {
+	raw := newTestZstdDictionary(t)
+	dict := newZstdDictionary(raw)
+	require.Nil(t, newZstdDictionary(nil))
+
+	msg := testZstdDictionaryMessage(1000)
+	withDict, err := compress(CompressionZSTD, CompressionLevelDefault, dict, msg)
+	require.NoError(t, err)
+	withoutDict, err := compress(CompressionZSTD, CompressionLevelDefault, nil, msg)
+	require.NoError(t, err)
+	require.Less(t, len(withDict), len(withoutDict), "expected the dictionary to improve the compression ratio")
+
+	decompressed, err := decompress(CompressionZSTD, dict, withDict)
+	require.NoError(t, err)
+	require.Equal(t, msg, decompressed)
+
+	// messages compressed without the dictionary can still be read with it
+	decompressed, err = decompress(CompressionZSTD, dict, withoutDict)
+	require.NoError(t, err)
+	require.Equal(t, msg, decompressed)
+
+	_, err = decompress(CompressionZSTD, nil, withDict)
+	require.Error(t, err, "expected messages compressed with a dictionary to require it")
+
+	// the encoders and decoder are released with the dictionary
+	dict.close()
+	require.Nil(t, dict.encoders)
+	require.Nil(t, dict.decoder)
+	_, err = decompress(CompressionZSTD, dict, withDict)
+	require.ErrorIs(t, err, errZstdDictionaryClosed)
+}
+
+func TestZstdDictionaryInvalid(t *testing.T) {
+	dict := newZstdDictionary([]byte("not a dictionary"))
+	_, err := compress(CompressionZSTD, CompressionLevelDefault, dict, []byte("message"))
+	require.Error(t, err)
+	_, err = decompress(CompressionZSTD, dict, []byte("message"))
+	require.Error(t, err)
+}